@@ -0,0 +1,243 @@
+// Package audit keeps a durable record of the requests an MCP server handles.
+// Its FileLogger is a core.AuditSink writing JSON lines to a file that is
+// rotated by size, for use with server.WithAuditSink.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Redacted replaces the values of redacted keys in recorded parameters.
+const Redacted = "[REDACTED]"
+
+// Options holds configuration parameters for a FileLogger.
+type Options struct {
+	MaxSize    int64    // Bytes a file may reach before it is rotated; 0 disables rotation
+	MaxBackups int      // Rotated files kept, newest first as path.1, path.2, ...; 0 keeps none
+	Params     bool     // Record a redacted copy of each request's parameters
+	Redact     []string // Keys and parameter names whose values are masked in recorded parameters
+	Sync       bool     // Sync the file to disk after every entry
+}
+
+// DefaultOptions returns the default logger options.
+func DefaultOptions() Options {
+	return Options{
+		MaxSize:    100 << 20,
+		MaxBackups: 5,
+	}
+}
+
+// Option is a function type that modifies Options.
+type Option func(*Options)
+
+// WithMaxSize sets the size in bytes at which the file is rotated, or
+// disables rotation if 0.
+func WithMaxSize(size int64) Option {
+	return func(o *Options) {
+		o.MaxSize = size
+	}
+}
+
+// WithMaxBackups sets how many rotated files are kept.
+func WithMaxBackups(n int) Option {
+	return func(o *Options) {
+		o.MaxBackups = n
+	}
+}
+
+// WithParams records a copy of each request's parameters in its entry, with
+// the values of the given keys replaced by Redacted. A key matches fields of
+// any object in the parameters, such as ModelRequest.ModelData, and the
+// values of ModelRequest.Parameters with that name.
+func WithParams(redact ...string) Option {
+	return func(o *Options) {
+		o.Params = true
+		o.Redact = append(o.Redact, redact...)
+	}
+}
+
+// WithSync syncs the file to disk after every entry, so entries survive a
+// crash of the machine at the cost of a write to disk per request.
+func WithSync(sync bool) Option {
+	return func(o *Options) {
+		o.Sync = sync
+	}
+}
+
+// Entry is a line of the audit file: the event, with the request's redacted
+// parameters if the logger records them.
+type Entry struct {
+	core.AuditEvent
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// FileLogger appends an Entry to a file for every event it receives. When the
+// file reaches MaxSize it is renamed to path.1, shifting older files along
+// and removing those beyond MaxBackups, and a new file is started.
+type FileLogger struct {
+	path   string
+	opts   Options
+	redact map[string]bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileLogger opens path for appending, creating it if needed, and returns
+// a logger writing to it.
+func NewFileLogger(path string, options ...Option) (*FileLogger, error) {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	l := &FileLogger{path: path, opts: opts, redact: make(map[string]bool)}
+	for _, key := range opts.Redact {
+		l.redact[key] = true
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Audit implements core.AuditSink.
+func (l *FileLogger) Audit(event core.AuditEvent) error {
+	entry := Entry{AuditEvent: event}
+	if l.opts.Params && len(event.Params) > 0 {
+		params, err := l.redactParams(event.Params)
+		if err != nil {
+			return fmt.Errorf("failed to redact parameters: %w", err)
+		}
+		entry.Params = params
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	if l.opts.MaxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.opts.MaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if l.opts.Sync {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+// Close closes the file. Later events fail with os.ErrClosed.
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// open opens the file at l.path for appending. The caller must hold l.mu or
+// be the only user of l.
+func (l *FileLogger) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current file to path.1, after shifting the backups, and
+// starts a new one. The caller must hold l.mu.
+func (l *FileLogger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	l.file = nil
+
+	if l.opts.MaxBackups == 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+		return l.open()
+	}
+	if err := os.Remove(l.backup(l.opts.MaxBackups)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	for i := l.opts.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(l.backup(i), l.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	if err := os.Rename(l.path, l.backup(1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return l.open()
+}
+
+// backup returns the path of the i-th most recent rotated file.
+func (l *FileLogger) backup(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// redactParams returns a copy of params with the values of redacted keys
+// replaced by Redacted.
+func (l *FileLogger) redactParams(params json.RawMessage) (json.RawMessage, error) {
+	if len(l.redact) == 0 {
+		return params, nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(params, &decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(l.redactValue(decoded))
+}
+
+func (l *FileLogger) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// A core.Parameter is masked by its name
+		if name, ok := v["name"].(string); ok && l.redact[name] {
+			if _, ok := v["value"]; ok {
+				v["value"] = Redacted
+			}
+		}
+		for key, field := range v {
+			if l.redact[key] {
+				v[key] = Redacted
+			} else {
+				v[key] = l.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = l.redactValue(item)
+		}
+	}
+	return value
+}
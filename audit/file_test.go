@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEntries parses every line of the file at path
+func readEntries(t *testing.T, path string) []Entry {
+	file, err := os.Open(path)
+	require.NoError(t, err, "Audit file should exist")
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "Line should be JSON")
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err(), "File should be readable")
+	return entries
+}
+
+func TestFileLoggerRecordsRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewFileLogger(path, WithParams("apiKey", "password"))
+	require.NoError(t, err, "Logger should open its file")
+
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithAuditSink(logger))
+			require.NoError(t, srv.RegisterHandler(&testutil.FlakyModelHandler{}), "Handler registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		req := testutil.CreateTestModelRequest()
+		req.ModelData["apiKey"] = "s3cret"
+		req.Parameters = append(req.Parameters, core.Parameter{Name: "password", Type: "string", Value: "hunter2"})
+		_, err := c.ProcessModel(ctx, req)
+		require.NoError(t, err, "ProcessModel should succeed")
+	}
+	failing := testutil.CreateTestModelRequest()
+	failing.ModelData["fail"] = true
+	_, err = c.ProcessModel(ctx, failing)
+	require.Error(t, err, "Failing request should return an error")
+
+	// The server audits its side just after replying
+	var entries []Entry
+	require.Eventually(t, func() bool {
+		entries = nil
+		for _, entry := range readEntries(t, path) {
+			if entry.Method == core.MethodProcessModel {
+				entries = append(entries, entry)
+			}
+		}
+		return len(entries) == 4
+	}, 2*time.Second, 10*time.Millisecond, "Every request should be recorded")
+	require.NoError(t, logger.Close(), "Logger should close")
+
+	for i, entry := range entries {
+		assert.NotEmpty(t, entry.ConnectionID, "Entry should name the connection")
+		assert.NotEmpty(t, entry.RequestID, "Entry should carry the request ID")
+		assert.False(t, entry.Time.IsZero(), "Entry should be timestamped")
+		assert.Positive(t, entry.Duration, "Entry should be timed")
+		assert.Equal(t, i < 3, entry.Success, "Only the last request should have failed")
+
+		var params core.ModelRequest
+		require.NoError(t, json.Unmarshal(entry.Params, &params), "Parameters should be recorded")
+		if i < 3 {
+			assert.Equal(t, Redacted, params.ModelData["apiKey"], "Secret model data should be masked")
+			assert.Equal(t, Redacted, params.Parameters[len(params.Parameters)-1].Value, "Secret parameter should be masked")
+		} else {
+			assert.Equal(t, true, params.ModelData["fail"], "Other model data should be kept")
+		}
+	}
+	assert.NotContains(t, readFile(t, path), "s3cret", "Secrets should not reach the file")
+	assert.NotContains(t, readFile(t, path), "hunter2", "Secrets should not reach the file")
+}
+
+func TestFileLoggerRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewFileLogger(path, WithMaxSize(200), WithMaxBackups(2))
+	require.NoError(t, err, "Logger should open its file")
+	defer logger.Close()
+
+	event := core.AuditEvent{Time: time.Now().UTC(), Method: core.MethodProcessModel, RequestID: "1", Success: true}
+	for i := 0; i < 20; i++ {
+		require.NoError(t, logger.Audit(event), "Audit should succeed")
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err, "%s should exist", name)
+		assert.LessOrEqual(t, info.Size(), int64(200), "%s should not exceed the maximum size", name)
+		assert.NotEmpty(t, readEntries(t, name), "%s should hold entries", name)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "Backups beyond the maximum should be removed")
+	assert.Empty(t, readEntries(t, path)[0].Params, "Parameters should not be recorded by default")
+}
+
+func TestFileLoggerClosed(t *testing.T) {
+	logger, err := NewFileLogger(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err, "Logger should open its file")
+	require.NoError(t, logger.Close(), "Logger should close")
+	assert.ErrorIs(t, logger.Audit(core.AuditEvent{}), os.ErrClosed, "Closed logger should refuse events")
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err, "Audit file should be readable")
+	return string(data)
+}
@@ -0,0 +1,481 @@
+// Package authjwt verifies JSON Web Tokens, such as OpenID Connect ID and
+// access tokens, for the server's auth scheme registry. Signing keys are
+// fetched from the issuer's JWKS endpoint, cached and refreshed in the
+// background. It uses only the standard library, so accepting tokens does not
+// add dependencies to programs that register it.
+//
+// Tokens are read from the "token" credential, so clients configured with
+// client.WithAuthToken or client.WithAuth work unchanged:
+//
+//	verifier := authjwt.New("https://issuer.example.com/.well-known/jwks.json",
+//		authjwt.WithIssuer("https://issuer.example.com/"),
+//		authjwt.WithAudience("mcp-server"),
+//	)
+//	if err := verifier.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer verifier.Stop()
+//	srv.RegisterAuthScheme("jwt", verifier)
+package authjwt
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Options holds configuration parameters for a Verifier.
+type Options struct {
+	Issuer             string        // Required "iss" claim; empty accepts any issuer
+	Audiences          []string      // Accepted "aud" values, any one of which must be present; empty accepts any audience
+	ClockSkew          time.Duration // Tolerance applied to the "exp", "nbf" and "iat" claims
+	SubjectClaim       string        // Claim mapped to Principal.ID; dots select nested claims
+	RolesClaim         string        // Claim mapped to Principal.Roles, a list or space-separated string; empty maps none
+	RefreshInterval    time.Duration // How often Start refetches the key set in the background
+	MinRefreshInterval time.Duration // Minimum time between fetches triggered by tokens signed with unknown keys
+	HTTPClient         *http.Client  // Client used to fetch the key set
+	Logger             core.Logger   // Receives background refresh failures
+}
+
+// DefaultOptions returns the default verifier options.
+func DefaultOptions() Options {
+	return Options{
+		ClockSkew:          time.Minute,
+		SubjectClaim:       "sub",
+		RolesClaim:         "roles",
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: time.Minute,
+		HTTPClient:         &http.Client{Timeout: 10 * time.Second},
+		Logger:             core.DefaultLogger(),
+	}
+}
+
+// Option is a function type that modifies Options.
+type Option func(*Options)
+
+// WithIssuer requires tokens to carry the given "iss" claim.
+func WithIssuer(issuer string) Option {
+	return func(o *Options) {
+		o.Issuer = issuer
+	}
+}
+
+// WithAudience requires tokens to be issued for at least one of the given
+// audiences.
+func WithAudience(audiences ...string) Option {
+	return func(o *Options) {
+		o.Audiences = audiences
+	}
+}
+
+// WithClockSkew sets how far the server clock may be off from the issuer's
+// when checking expiry and validity times.
+func WithClockSkew(skew time.Duration) Option {
+	return func(o *Options) {
+		o.ClockSkew = skew
+	}
+}
+
+// WithClaims sets the claims mapped to the principal's ID and roles, e.g.
+// "email" and "realm_access.roles". An empty roles claim maps no roles.
+func WithClaims(subject, roles string) Option {
+	return func(o *Options) {
+		o.SubjectClaim = subject
+		o.RolesClaim = roles
+	}
+}
+
+// WithRefreshInterval sets how often the key set is refetched in the
+// background, and the minimum time between fetches for unknown key IDs.
+func WithRefreshInterval(interval, min time.Duration) Option {
+	return func(o *Options) {
+		o.RefreshInterval = interval
+		o.MinRefreshInterval = min
+	}
+}
+
+// WithHTTPClient sets the client used to fetch the key set.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.HTTPClient = client
+	}
+}
+
+// WithLogger sets the logger receiving background refresh failures.
+func WithLogger(logger core.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// Verifier verifies JWTs presented in the "token" credential against the key
+// set published at a JWKS URL. It implements server.AuthVerifier. Rejected
+// tokens are reported as *core.AuthError, whose reason the server passes on
+// to the client; the details, which never include the token, are only logged.
+type Verifier struct {
+	jwksURL string
+	options Options
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time
+	fetchErr error // Failure of the last fetch, if it failed
+
+	fetchMu  sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a verifier for tokens signed with the keys published at jwksURL.
+// Keys are fetched when the first token arrives, or up front by Start.
+func New(jwksURL string, options ...Option) *Verifier {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return &Verifier{
+		jwksURL: jwksURL,
+		options: opts,
+		keys:    make(map[string]crypto.PublicKey),
+	}
+}
+
+// Start fetches the key set and refreshes it every RefreshInterval until Stop
+// is called, so rotated keys are picked up and removed keys stop verifying.
+// A failed background refresh keeps the previous keys.
+func (v *Verifier) Start(ctx context.Context) error {
+	if err := v.Refresh(ctx); err != nil {
+		return err
+	}
+
+	v.stop = make(chan struct{})
+	v.done = make(chan struct{})
+	go v.refreshLoop()
+	return nil
+}
+
+// Stop ends background refreshing.
+func (v *Verifier) Stop() {
+	if v.stop == nil {
+		return
+	}
+	v.stopOnce.Do(func() { close(v.stop) })
+	<-v.done
+}
+
+func (v *Verifier) refreshLoop() {
+	defer close(v.done)
+
+	ticker := time.NewTicker(v.options.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), v.options.RefreshInterval)
+			if err := v.Refresh(ctx); err != nil {
+				v.options.Logger.Warn("Failed to refresh JWT signing keys", "url", v.jwksURL, core.LogFieldError, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Refresh fetches the key set now, replacing the cached keys.
+func (v *Verifier) Refresh(ctx context.Context) error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+	return v.refreshLocked(ctx)
+}
+
+func (v *Verifier) refreshLocked(ctx context.Context) error {
+	keys, err := fetchKeySet(ctx, v.options.HTTPClient, v.jwksURL)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fetched, v.fetchErr = time.Now(), err
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	return nil
+}
+
+// lookup returns the cached keys usable for kid: the key with that ID, or
+// every key if the token names none. A kid that is not cached triggers a
+// fetch, at most once per MinRefreshInterval.
+func (v *Verifier) lookup(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	if keys := v.cached(kid); len(keys) > 0 {
+		return keys, nil
+	}
+
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+	if keys := v.cached(kid); len(keys) > 0 {
+		return keys, nil
+	}
+	v.mu.RLock()
+	recent := !v.fetched.IsZero() && time.Since(v.fetched) < v.options.MinRefreshInterval
+	unavailable := len(v.keys) == 0 && v.fetchErr != nil
+	fetchErr := v.fetchErr
+	v.mu.RUnlock()
+	if recent {
+		if unavailable {
+			return nil, fetchErr
+		}
+		return nil, nil
+	}
+	if err := v.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	return v.cached(kid), nil
+}
+
+func (v *Verifier) cached(kid string) []crypto.PublicKey {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if kid != "" {
+		if key, ok := v.keys[kid]; ok {
+			return []crypto.PublicKey{key}
+		}
+		return nil
+	}
+	keys := make([]crypto.PublicKey, 0, len(v.keys))
+	for _, key := range v.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// header holds the JOSE header fields the verifier reads.
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verify implements server.AuthVerifier. The principal expires with the
+// token, so the server verifies the session again once the token has lapsed.
+func (v *Verifier) Verify(ctx context.Context, credentials map[string]string) (*core.Principal, error) {
+	parts := strings.Split(credentials[core.CredentialToken], ".")
+	if len(parts) != 3 {
+		return nil, reject(core.AuthReasonMalformed, "token is not a signed JWT")
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, reject(core.AuthReasonMalformed, "invalid header: %v", err)
+	}
+	alg, ok := algorithms[hdr.Algorithm]
+	if !ok {
+		return nil, reject(core.AuthReasonBadSignature, "unsupported algorithm %q", hdr.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, reject(core.AuthReasonMalformed, "invalid signature encoding")
+	}
+
+	keys, err := v.lookup(ctx, hdr.KeyID)
+	if err != nil {
+		return nil, &core.AuthError{Reason: core.AuthReasonUnavailable, Err: fmt.Errorf("failed to fetch signing keys: %w", err)}
+	}
+	if len(keys) == 0 {
+		return nil, reject(core.AuthReasonBadSignature, "no signing key with ID %q", hdr.KeyID)
+	}
+	verified := false
+	for _, key := range keys {
+		if alg.verify(key, []byte(parts[0]+"."+parts[1]), signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, reject(core.AuthReasonBadSignature, "signature does not verify")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, reject(core.AuthReasonMalformed, "invalid claims: %v", err)
+	}
+	return v.principal(claims)
+}
+
+// principal checks the registered claims and maps the configured claims to a
+// principal.
+func (v *Verifier) principal(claims map[string]interface{}) (*core.Principal, error) {
+	now := time.Now()
+	skew := v.options.ClockSkew
+
+	exp, ok, err := timeClaim(claims, "exp")
+	switch {
+	case err != nil:
+		return nil, reject(core.AuthReasonMalformed, "invalid exp claim")
+	case !ok:
+		return nil, reject(core.AuthReasonMalformed, "missing exp claim")
+	case !now.Before(exp.Add(skew)):
+		return nil, reject(core.AuthReasonExpired, "token expired %s ago", now.Sub(exp).Round(time.Second))
+	}
+	for _, name := range []string{"nbf", "iat"} {
+		at, ok, err := timeClaim(claims, name)
+		if err != nil {
+			return nil, reject(core.AuthReasonMalformed, "invalid %s claim", name)
+		}
+		if ok && at.After(now.Add(skew)) {
+			return nil, reject(core.AuthReasonNotYetValid, "%s claim is %s in the future", name, at.Sub(now).Round(time.Second))
+		}
+	}
+
+	if v.options.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != v.options.Issuer {
+			return nil, reject(core.AuthReasonWrongIssuer, "issuer does not match")
+		}
+	}
+	if len(v.options.Audiences) > 0 && !hasAudience(claims["aud"], v.options.Audiences) {
+		return nil, reject(core.AuthReasonWrongAudience, "token is not issued for an accepted audience")
+	}
+
+	subject, _ := lookupClaim(claims, v.options.SubjectClaim).(string)
+	if subject == "" {
+		return nil, reject(core.AuthReasonMalformed, "missing %s claim", v.options.SubjectClaim)
+	}
+	principal := &core.Principal{ID: subject, ExpiresAt: exp}
+	if v.options.RolesClaim != "" {
+		principal.Roles = stringList(lookupClaim(claims, v.options.RolesClaim))
+	}
+	return principal, nil
+}
+
+// reject builds the error for a token rejected for reason.
+func reject(reason, format string, args ...interface{}) error {
+	return &core.AuthError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a token into v.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("invalid encoding")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// timeClaim returns the NumericDate claim name, reporting whether it is present.
+func timeClaim(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, true, errors.New("not a number")
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, true, err
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*float64(time.Second))), true, nil
+}
+
+// hasAudience reports whether the "aud" claim, a string or a list of strings,
+// names one of the accepted audiences.
+func hasAudience(aud interface{}, accepted []string) bool {
+	for _, audience := range stringList(aud) {
+		for _, want := range accepted {
+			if audience == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupClaim returns the claim at a dotted path through nested objects.
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// stringList converts a claim holding a list of strings, or a single
+// space-separated string such as "scope", to a slice.
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// algorithm verifies signatures of one JWS algorithm.
+type algorithm struct {
+	hash  crypto.Hash
+	curve string // Required curve of ECDSA keys; empty for RSA
+}
+
+// algorithms are the JWS algorithms the verifier accepts. Symmetric
+// algorithms and "none" are deliberately absent: keys come from a public key
+// set and must never be used as shared secrets.
+var algorithms = map[string]algorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, curve: "P-256"},
+	"ES384": {hash: crypto.SHA384, curve: "P-384"},
+	"ES512": {hash: crypto.SHA512, curve: "P-521"},
+}
+
+// verify reports whether signature is a valid signature of input by key.
+func (a algorithm) verify(key crypto.PublicKey, input, signature []byte) bool {
+	h := a.hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return a.curve == "" && rsa.VerifyPKCS1v15(key, a.hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().Name != a.curve {
+			return false
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
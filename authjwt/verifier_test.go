@@ -0,0 +1,333 @@
+package authjwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://issuer.test/"
+	testAudience = "mcp-test"
+)
+
+// signingKey is a private key published in the test key set under its ID
+type signingKey struct {
+	id  string
+	alg string
+	key crypto.Signer
+}
+
+func newRSAKey(t *testing.T, id string) signingKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err, "RSA key generation should succeed")
+	return signingKey{id: id, alg: "RS256", key: key}
+}
+
+func newECKey(t *testing.T, id string) signingKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "EC key generation should succeed")
+	return signingKey{id: id, alg: "ES256", key: key}
+}
+
+// jwk returns the public half of the key as a JSON Web Key
+func (k signingKey) jwk() map[string]string {
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{"kty": "RSA", "kid": k.id, "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))}
+	case *ecdsa.PrivateKey:
+		return map[string]string{"kty": "EC", "kid": k.id, "crv": "P-256", "x": encode(key.X), "y": encode(key.Y)}
+	}
+	return nil
+}
+
+// sign issues a token with the given claims
+func (k signingKey) sign(t *testing.T, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": k.alg, "kid": k.id, "typ": "JWT"})
+	require.NoError(t, err, "Header should encode")
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err, "Claims should encode")
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err, "RSA signing should succeed")
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err, "ECDSA signing should succeed")
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims returns valid claims for subject, modified by overrides
+func claims(subject string, overrides map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	c := map[string]interface{}{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   subject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": []string{"reader"},
+	}
+	for name, value := range overrides {
+		c[name] = value
+	}
+	return c
+}
+
+// keyServer serves a JWKS document whose keys can be rotated
+type keyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    []signingKey
+	status  int
+	fetches atomic.Int32
+}
+
+func startKeyServer(t *testing.T, keys ...signingKey) *keyServer {
+	ks := &keyServer{keys: keys, status: http.StatusOK}
+	ks.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ks.fetches.Add(1)
+		ks.mu.Lock()
+		defer ks.mu.Unlock()
+		if ks.status != http.StatusOK {
+			w.WriteHeader(ks.status)
+			return
+		}
+		set := struct {
+			Keys []map[string]string `json:"keys"`
+		}{}
+		for _, key := range ks.keys {
+			set.Keys = append(set.Keys, key.jwk())
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(ks.Close)
+	return ks
+}
+
+// publish replaces the published keys
+func (ks *keyServer) publish(keys ...signingKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+}
+
+func newTestVerifier(ks *keyServer, options ...Option) *Verifier {
+	return New(ks.URL, append([]Option{WithIssuer(testIssuer), WithAudience(testAudience)}, options...)...)
+}
+
+func verify(v *Verifier, token string) (*core.Principal, error) {
+	return v.Verify(context.Background(), map[string]string{core.CredentialToken: token})
+}
+
+// reason returns the reason of an AuthError, or "" for any other error
+func reason(err error) string {
+	var authErr *core.AuthError
+	if errors.As(err, &authErr) {
+		return authErr.Reason
+	}
+	return ""
+}
+
+func TestVerify(t *testing.T) {
+	for _, key := range []signingKey{newRSAKey(t, "rsa-1"), newECKey(t, "ec-1")} {
+		t.Run(key.alg, func(t *testing.T) {
+			v := newTestVerifier(startKeyServer(t, key))
+
+			exp := time.Now().Add(time.Hour).Truncate(time.Second)
+			principal, err := verify(v, key.sign(t, claims("alice", map[string]interface{}{"exp": exp.Unix()})))
+			require.NoError(t, err, "Valid token should verify")
+			assert.Equal(t, "alice", principal.ID, "Principal should come from the subject claim")
+			assert.Equal(t, []string{"reader"}, principal.Roles, "Roles should come from the roles claim")
+			assert.True(t, exp.Equal(principal.ExpiresAt), "Principal should expire with the token")
+		})
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	key := newRSAKey(t, "current")
+	impostor := newRSAKey(t, "current")
+	unpublished := newECKey(t, "unpublished")
+	v := newTestVerifier(startKeyServer(t, key))
+
+	hour := time.Hour
+	cases := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{"Expired", key.sign(t, claims("alice", map[string]interface{}{"exp": time.Now().Add(-hour).Unix()})), core.AuthReasonExpired},
+		{"NotYetValid", key.sign(t, claims("alice", map[string]interface{}{"nbf": time.Now().Add(hour).Unix()})), core.AuthReasonNotYetValid},
+		{"BadSignature", impostor.sign(t, claims("alice", nil)), core.AuthReasonBadSignature},
+		{"UnknownKey", unpublished.sign(t, claims("alice", nil)), core.AuthReasonBadSignature},
+		{"WrongAudience", key.sign(t, claims("alice", map[string]interface{}{"aud": []string{"other-service"}})), core.AuthReasonWrongAudience},
+		{"WrongIssuer", key.sign(t, claims("alice", map[string]interface{}{"iss": "https://evil.test/"})), core.AuthReasonWrongIssuer},
+		{"MissingExpiry", key.sign(t, claims("alice", map[string]interface{}{"exp": nil})), core.AuthReasonMalformed},
+		{"MissingSubject", key.sign(t, claims("", nil)), core.AuthReasonMalformed},
+		{"Malformed", "not-a-jwt", core.AuthReasonMalformed},
+		{"AlgorithmNone", "eyJhbGciOiJub25lIn0.eyJzdWIiOiJhbGljZSJ9.", core.AuthReasonBadSignature},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			principal, err := verify(v, tc.token)
+			require.Error(t, err, "Token should be rejected")
+			assert.Nil(t, principal, "Rejected token should yield no principal")
+			assert.Equal(t, tc.reason, reason(err), "Rejection should report its category")
+			assert.NotContains(t, err.Error(), tc.token, "Error should not include the token")
+		})
+	}
+}
+
+func TestVerifyClockSkew(t *testing.T) {
+	key := newECKey(t, "ec-1")
+	ks := startKeyServer(t, key)
+	token := key.sign(t, claims("alice", map[string]interface{}{"exp": time.Now().Add(-30 * time.Second).Unix()}))
+
+	_, err := verify(newTestVerifier(ks), token)
+	assert.NoError(t, err, "Token expired within the default skew should verify")
+
+	_, err = verify(newTestVerifier(ks, WithClockSkew(0)), token)
+	assert.Equal(t, core.AuthReasonExpired, reason(err), "Token should expire without skew tolerance")
+}
+
+func TestVerifyClaimMapping(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	v := newTestVerifier(startKeyServer(t, key), WithClaims("email", "realm_access.roles"))
+
+	principal, err := verify(v, key.sign(t, claims("user-123", map[string]interface{}{
+		"email":        "alice@example.com",
+		"realm_access": map[string]interface{}{"roles": []string{"admin", "auditor"}},
+	})))
+	require.NoError(t, err, "Token should verify")
+	assert.Equal(t, "alice@example.com", principal.ID, "Principal ID should come from the configured claim")
+	assert.Equal(t, []string{"admin", "auditor"}, principal.Roles, "Roles should come from the nested claim")
+
+	v = newTestVerifier(startKeyServer(t, key), WithClaims("sub", "scope"))
+	principal, err = verify(v, key.sign(t, claims("alice", map[string]interface{}{"scope": "models:read models:write"})))
+	require.NoError(t, err, "Token should verify")
+	assert.Equal(t, []string{"models:read", "models:write"}, principal.Roles, "Space-separated claims should split into roles")
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	old, next := newRSAKey(t, "old"), newECKey(t, "next")
+	ks := startKeyServer(t, old)
+	v := newTestVerifier(ks, WithRefreshInterval(20*time.Millisecond, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, v.Start(ctx), "Verifier should fetch the key set")
+	defer v.Stop()
+
+	oldToken := old.sign(t, claims("alice", nil))
+	_, err := verify(v, oldToken)
+	require.NoError(t, err, "Token signed with the published key should verify")
+
+	// The issuer rotates: the old key is removed in favour of the next one
+	ks.publish(next)
+	require.Eventually(t, func() bool {
+		_, err := verify(v, oldToken)
+		return reason(err) == core.AuthReasonBadSignature
+	}, 2*time.Second, 10*time.Millisecond, "Token signed with the removed key should stop verifying")
+
+	_, err = verify(v, next.sign(t, claims("alice", nil)))
+	assert.NoError(t, err, "Token signed with the new key should verify")
+}
+
+func TestVerifyUnknownKeyRefetches(t *testing.T) {
+	first, added := newRSAKey(t, "first"), newECKey(t, "added")
+	ks := startKeyServer(t, first)
+	v := newTestVerifier(ks, WithRefreshInterval(time.Hour, 0))
+
+	_, err := verify(v, first.sign(t, claims("alice", nil)))
+	require.NoError(t, err, "First token should fetch the key set and verify")
+	assert.Equal(t, int32(1), ks.fetches.Load(), "Keys should be fetched once")
+
+	ks.publish(first, added)
+	_, err = verify(v, added.sign(t, claims("alice", nil)))
+	require.NoError(t, err, "Token signed with a newly published key should verify")
+	assert.Equal(t, int32(2), ks.fetches.Load(), "Unknown key ID should trigger a fetch")
+
+	_, err = verify(v, first.sign(t, claims("bob", nil)))
+	require.NoError(t, err, "Cached key should verify")
+	assert.Equal(t, int32(2), ks.fetches.Load(), "Known key IDs should be served from the cache")
+}
+
+func TestVerifyKeysUnavailable(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	ks := startKeyServer(t, key)
+	ks.status = http.StatusInternalServerError
+	v := newTestVerifier(ks)
+
+	_, err := verify(v, key.sign(t, claims("alice", nil)))
+	assert.Equal(t, core.AuthReasonUnavailable, reason(err), "Failed fetch should be reported as unavailable")
+	_, err = verify(v, key.sign(t, claims("alice", nil)))
+	assert.Equal(t, core.AuthReasonUnavailable, reason(err), "Retries within the refresh interval should stay unavailable")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.Error(t, v.Start(ctx), "Start should fail without a key set")
+}
+
+func TestVerifierAuthScheme(t *testing.T) {
+	key := newECKey(t, "ec-1")
+	v := newTestVerifier(startKeyServer(t, key))
+
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.RegisterAuthScheme("jwt", v), "Scheme registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	defer srv.Stop()
+
+	c := client.New(client.WithTransport(transport), client.WithAuth("jwt", map[string]string{
+		core.CredentialToken: key.sign(t, claims("alice", nil)),
+	}))
+	require.NoError(t, c.Start(), "Client with a valid token should authenticate")
+	defer c.Stop()
+	assert.Equal(t, "alice", c.Principal().ID, "Client should learn its principal")
+
+	expired := client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithAuth("jwt", map[string]string{
+		core.CredentialToken: key.sign(t, claims("alice", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+	}))
+	err := expired.Start()
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Rejection should be a JSON-RPC error")
+	assert.Equal(t, core.CodeUnauthenticated, rpcErr.Code, "Rejection should use the unauthenticated code")
+
+	var data core.AuthErrorData
+	require.NotNil(t, rpcErr.Data, "Rejection should carry error data")
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &data), "Error data should decode")
+	assert.Equal(t, core.AuthReasonExpired, data.Reason, "Client should learn why the token was rejected")
+}
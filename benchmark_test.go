@@ -2,33 +2,55 @@ package mcp
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/narcolepticfox/mcp/client"
 	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/mcpcompress"
+	"github.com/narcolepticfox/mcp/msgpack"
 	"github.com/narcolepticfox/mcp/server"
 	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
 )
 
-// BenchmarkLocalRequestResponse measures the round-trip time for local requests.
-func BenchmarkLocalRequestResponse(b *testing.B) {
-	// Get a free port for testing
-	port, err := testutil.GetFreePort()
-	if err != nil {
-		b.Fatalf("Failed to get free port: %v", err)
+// benchCodec selects the wire codec of the request benchmarks, e.g.
+// go test -bench RequestSizes -codec msgpack.
+var benchCodec = flag.String("codec", "json", "wire codec of the request benchmarks: json or msgpack")
+
+// codecOptions returns the server and client options that select benchCodec.
+func codecOptions(b *testing.B) ([]server.Option, []client.Option) {
+	switch *benchCodec {
+	case "json":
+		return nil, nil
+	case "msgpack":
+		return []server.Option{server.WithCodec(msgpack.Codec)}, []client.Option{client.WithCodec(msgpack.Codec)}
 	}
+	b.Fatalf("Unknown codec %q", *benchCodec)
+	return nil, nil
+}
 
+// BenchmarkLocalRequestResponse measures the round-trip time for local requests.
+func BenchmarkLocalRequestResponse(b *testing.B) {
 	// Create and start server
-	srv := server.New(
-		server.WithPort(port),
+	serverCodec, clientCodec := codecOptions(b)
+	srv := server.New(append([]server.Option{
+		server.WithPort(0),
 		server.WithMaxConcurrentClients(100),
-	)
+	}, serverCodec...)...)
 
 	// Register default handler
 	handler := server.NewDefaultModelHandler()
-	err = srv.RegisterHandler(handler)
+	err := srv.RegisterHandler(handler)
 	if err != nil {
 		b.Fatalf("Failed to register handler: %v", err)
 	}
@@ -40,10 +62,10 @@ func BenchmarkLocalRequestResponse(b *testing.B) {
 	}
 
 	// Create and start client
-	c := client.New(
-		client.WithServerPort(port),
-		client.WithConnectionTimeout(5*time.Second),
-	)
+	c := client.New(append([]client.Option{
+		client.WithServerAddr(srv.Addr().String()),
+		client.WithConnectionTimeout(5 * time.Second),
+	}, clientCodec...)...)
 
 	err = c.Start()
 	if err != nil {
@@ -102,18 +124,13 @@ func BenchmarkRequestSizes(b *testing.B) {
 
 	for _, size := range payloadSizes {
 		b.Run(fmt.Sprintf("Payload-%dKB", size), func(b *testing.B) {
-			// Get a free port for testing
-			port, err := testutil.GetFreePort()
-			if err != nil {
-				b.Fatalf("Failed to get free port: %v", err)
-			}
-
 			// Create and start server
-			srv := server.New(server.WithPort(port))
+			serverCodec, clientCodec := codecOptions(b)
+			srv := server.New(append([]server.Option{server.WithPort(0)}, serverCodec...)...)
 
 			// Register default handler
 			handler := server.NewDefaultModelHandler()
-			err = srv.RegisterHandler(handler)
+			err := srv.RegisterHandler(handler)
 			if err != nil {
 				b.Fatalf("Failed to register handler: %v", err)
 			}
@@ -125,7 +142,7 @@ func BenchmarkRequestSizes(b *testing.B) {
 			}
 
 			// Create and start client
-			c := client.New(client.WithServerPort(port))
+			c := client.New(append([]client.Option{client.WithServerAddr(srv.Addr().String())}, clientCodec...)...)
 			err = c.Start()
 			if err != nil {
 				b.Fatalf("Failed to start client: %v", err)
@@ -145,6 +162,7 @@ func BenchmarkRequestSizes(b *testing.B) {
 			ctx := context.Background()
 
 			// Reset the benchmark timer to exclude setup time
+			b.ReportAllocs()
 			b.ResetTimer()
 
 			// Run the benchmark
@@ -168,81 +186,690 @@ func BenchmarkRequestSizes(b *testing.B) {
 	}
 }
 
-// BenchmarkConcurrentRequests measures performance with different levels of concurrency.
+// BenchmarkBinaryPayload measures the payloads of BenchmarkRequestSizes sent
+// as core.Binary, which the msgpack codec carries as raw bytes.
+func BenchmarkBinaryPayload(b *testing.B) {
+	for _, size := range []int{10, 1000, 10000} {
+		b.Run(fmt.Sprintf("Payload-%dKB", size), func(b *testing.B) {
+			serverCodec, clientCodec := codecOptions(b)
+			srv := server.New(append([]server.Option{server.WithPort(0)}, serverCodec...)...)
+			if err := srv.RegisterHandler(server.NewDefaultModelHandler()); err != nil {
+				b.Fatalf("Failed to register handler: %v", err)
+			}
+			if err := srv.Start(); err != nil {
+				b.Fatalf("Failed to start server: %v", err)
+			}
+			defer srv.Stop()
+
+			c := client.New(append([]client.Option{client.WithServerAddr(srv.Addr().String())}, clientCodec...)...)
+			if err := c.Start(); err != nil {
+				b.Fatalf("Failed to start client: %v", err)
+			}
+			defer c.Stop()
+
+			payload := make([]byte, size*1024)
+			for i := range payload {
+				payload[i] = byte(i % 256)
+			}
+			req := core.NewModelRequest()
+			req.ModelData["payload"] = core.Binary(payload)
+
+			ctx := context.Background()
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.ProcessModel(ctx, req); err != nil {
+					b.Fatalf("ProcessModel failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCompression measures the payloads of BenchmarkRequestSizes sent
+// plain and with each compression algorithm negotiated. Payloads under the
+// default threshold of 1KiB are sent plain whatever the algorithm.
+func BenchmarkCompression(b *testing.B) {
+	modes := []struct {
+		name        string
+		compression core.Compression
+	}{
+		{"Plain", core.CompressionNone},
+		{"Gzip", core.CompressionGzip},
+		{"Zstd", mcpcompress.Zstd},
+		{"Snappy", mcpcompress.Snappy},
+	}
+
+	for _, size := range []int{1, 10, 100, 1000, 10000} {
+		// Create a string payload of the specified size (roughly in KB)
+		payload := make([]byte, size*1024)
+		for i := range payload {
+			payload[i] = byte(i % 256)
+		}
+		req := core.NewModelRequest()
+		req.ModelData["payload"] = string(payload)
+
+		for _, mode := range modes {
+			b.Run(fmt.Sprintf("%s/Payload-%dKB", mode.name, size), func(b *testing.B) {
+				serverCodec, clientCodec := codecOptions(b)
+				srv := server.New(append([]server.Option{server.WithPort(0), server.WithCompression(mode.compression)}, serverCodec...)...)
+				if err := srv.RegisterHandler(server.NewDefaultModelHandler()); err != nil {
+					b.Fatalf("Failed to register handler: %v", err)
+				}
+				if err := srv.Start(); err != nil {
+					b.Fatalf("Failed to start server: %v", err)
+				}
+				defer srv.Stop()
+
+				c := client.New(append([]client.Option{client.WithServerAddr(srv.Addr().String()), client.WithCompression(mode.compression)}, clientCodec...)...)
+				if err := c.Start(); err != nil {
+					b.Fatalf("Failed to start client: %v", err)
+				}
+				defer c.Stop()
+
+				ctx := context.Background()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := c.ProcessModel(ctx, req); err != nil {
+						b.Fatalf("ProcessModel failed: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkConcurrentRequests measures performance with different levels of
+// concurrency, with handling unbounded and bounded by a request pool.
 func BenchmarkConcurrentRequests(b *testing.B) {
 	// Define concurrency levels to test
 	concurrencyLevels := []int{1, 5, 10, 25, 50, 100}
 
-	for _, concurrency := range concurrencyLevels {
-		b.Run(fmt.Sprintf("Concurrency-%d", concurrency), func(b *testing.B) {
-			// Get a free port for testing
-			port, err := testutil.GetFreePort()
-			if err != nil {
-				b.Fatalf("Failed to get free port: %v", err)
+	// Bounded runs allow 4 handlers at once and queue the rest
+	modes := []struct {
+		name    string
+		options func(concurrency int) []server.Option
+	}{
+		{"Unbounded", func(int) []server.Option { return nil }},
+		{"Bounded", func(concurrency int) []server.Option {
+			return []server.Option{server.WithMaxConcurrentRequests(4), server.WithRequestQueueSize(concurrency)}
+		}},
+	}
+
+	for _, mode := range modes {
+		for _, concurrency := range concurrencyLevels {
+			benchmarkConcurrentRequests(b, mode.name, concurrency, mode.options(concurrency)...)
+		}
+	}
+}
+
+// benchmarkConcurrentRequests runs the concurrent request benchmark at one
+// concurrency level against a server configured with options.
+func benchmarkConcurrentRequests(b *testing.B, mode string, concurrency int, options ...server.Option) {
+	b.Run(fmt.Sprintf("%s/Concurrency-%d", mode, concurrency), func(b *testing.B) {
+		// Create and start server with appropriate max clients setting
+		srv := server.New(append([]server.Option{
+			server.WithPort(0),
+			server.WithMaxConcurrentClients(concurrency * 2), // Extra headroom
+		}, options...)...)
+
+		// Register default handler
+		handler := server.NewDefaultModelHandler()
+		err := srv.RegisterHandler(handler)
+		if err != nil {
+			b.Fatalf("Failed to register handler: %v", err)
+		}
+
+		// Start server
+		err = srv.Start()
+		if err != nil {
+			b.Fatalf("Failed to start server: %v", err)
+		}
+
+		// Create and start client
+		c := client.New(client.WithServerAddr(srv.Addr().String()))
+		err = c.Start()
+		if err != nil {
+			b.Fatalf("Failed to start client: %v", err)
+		}
+
+		// Create a standard request
+		req := core.NewModelRequest()
+		req.ModelData["name"] = "Concurrent Benchmark"
+		req.Parameters = append(req.Parameters, core.Parameter{
+			Name:  "benchmark",
+			Value: "concurrent",
+			Type:  "string",
+		})
+
+		// Use a background context
+		ctx := context.Background()
+
+		// Set parallelism to our concurrency level
+		b.SetParallelism(concurrency)
+
+		// Reset the benchmark timer to exclude setup time
+		b.ResetTimer()
+
+		// Run the benchmark
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, err := c.ProcessModel(ctx, req)
+				if err != nil {
+					b.Fatalf("ProcessModel failed: %v", err)
+				}
 			}
+		})
 
-			// Create and start server with appropriate max clients setting
-			srv := server.New(
-				server.WithPort(port),
-				server.WithMaxConcurrentClients(concurrency*2), // Extra headroom
-			)
+		err = c.Stop()
+		if err != nil {
+			b.Fatalf("Failed to stop client: %v", err)
+		}
 
-			// Register default handler
-			handler := server.NewDefaultModelHandler()
-			err = srv.RegisterHandler(handler)
-			if err != nil {
-				b.Fatalf("Failed to register handler: %v", err)
+		err = srv.Stop()
+		if err != nil {
+			b.Fatalf("Failed to stop server: %v", err)
+		}
+	})
+}
+
+// BenchmarkBatch compares 100 individual ProcessModel calls with one
+// ProcessModelBatch call carrying the same 100 requests.
+func BenchmarkBatch(b *testing.B) {
+	const size = 100
+
+	srv := server.New(
+		server.WithPort(0),
+		server.WithBatchParallelism(8),
+	)
+	if err := srv.RegisterHandler(server.NewDefaultModelHandler()); err != nil {
+		b.Fatalf("Failed to register handler: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		b.Fatalf("Failed to start server: %v", err)
+	}
+	defer srv.Stop()
+
+	c := client.New(client.WithServerAddr(srv.Addr().String()))
+	if err := c.Start(); err != nil {
+		b.Fatalf("Failed to start client: %v", err)
+	}
+	defer c.Stop()
+
+	reqs := make([]*core.ModelRequest, size)
+	for i := range reqs {
+		reqs[i] = core.NewModelRequest()
+		reqs[i].ModelData["name"] = "Batch Benchmark"
+		reqs[i].ModelData["value"] = i
+	}
+	ctx := context.Background()
+
+	b.Run("Individual-100", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, req := range reqs {
+				if _, err := c.ProcessModel(ctx, req); err != nil {
+					b.Fatalf("ProcessModel failed: %v", err)
+				}
 			}
+		}
+	})
 
-			// Start server
-			err = srv.Start()
+	b.Run("Batch-100", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			resps, err := c.ProcessModelBatch(ctx, reqs)
 			if err != nil {
-				b.Fatalf("Failed to start server: %v", err)
+				b.Fatalf("ProcessModelBatch failed: %v", err)
+			}
+			if len(resps) != size {
+				b.Fatalf("Expected %d responses, got %d", size, len(resps))
 			}
+		}
+	})
+}
 
-			// Create and start client
-			c := client.New(client.WithServerPort(port))
-			err = c.Start()
-			if err != nil {
+// BenchmarkConnectionPool measures 50 concurrent callers sharing a single
+// connection and spread over client connection pools of different sizes.
+func BenchmarkConnectionPool(b *testing.B) {
+	const concurrency = 50
+
+	srv := server.New(
+		server.WithPort(0),
+		server.WithMaxConcurrentClients(32),
+	)
+	if err := srv.RegisterHandler(server.NewDefaultModelHandler()); err != nil {
+		b.Fatalf("Failed to register handler: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		b.Fatalf("Failed to start server: %v", err)
+	}
+	defer srv.Stop()
+
+	req := core.NewModelRequest()
+	req.ModelData["name"] = "Pool Benchmark"
+	ctx := context.Background()
+
+	for _, size := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Pool-%d", size), func(b *testing.B) {
+			c := client.New(client.WithServerAddr(srv.Addr().String()), client.WithConnectionPoolSize(size))
+			if err := c.Start(); err != nil {
 				b.Fatalf("Failed to start client: %v", err)
 			}
+			defer c.Stop()
 
-			// Create a standard request
-			req := core.NewModelRequest()
-			req.ModelData["name"] = "Concurrent Benchmark"
-			req.Parameters = append(req.Parameters, core.Parameter{
-				Name:  "benchmark",
-				Value: "concurrent",
-				Type:  "string",
+			b.SetParallelism(concurrency)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := c.ProcessModel(ctx, req); err != nil {
+						b.Fatalf("ProcessModel failed: %v", err)
+					}
+				}
 			})
+		})
+	}
+}
 
-			// Use a background context
+// BenchmarkConnectionSetup measures connect plus first round trip, with and
+// without port sharing, to show that protocol sniffing adds no measurable latency.
+func BenchmarkConnectionSetup(b *testing.B) {
+	cases := []struct {
+		name    string
+		options []server.Option
+	}{
+		{"Direct", nil},
+		{"PortSharing", []server.Option{server.WithPortSharing(http.NewServeMux())}},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			// Create and start server
+			srv := server.New(append([]server.Option{server.WithPort(0)}, tc.options...)...)
+			if err := srv.RegisterHandler(server.NewDefaultModelHandler()); err != nil {
+				b.Fatalf("Failed to register handler: %v", err)
+			}
+			if err := srv.Start(); err != nil {
+				b.Fatalf("Failed to start server: %v", err)
+			}
+
+			req := core.NewModelRequest()
 			ctx := context.Background()
 
-			// Set parallelism to our concurrency level
-			b.SetParallelism(concurrency)
+			// Reset the benchmark timer to exclude setup time
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				c := client.New(client.WithServerAddr(srv.Addr().String()), client.WithAutoReconnect(false))
+				if err := c.Start(); err != nil {
+					b.Fatalf("Failed to start client: %v", err)
+				}
+				if _, err := c.ProcessModel(ctx, req); err != nil {
+					b.Fatalf("ProcessModel failed: %v", err)
+				}
+				if err := c.Stop(); err != nil {
+					b.Fatalf("Failed to stop client: %v", err)
+				}
+			}
+
+			b.StopTimer()
+			if err := srv.Stop(); err != nil {
+				b.Fatalf("Failed to stop server: %v", err)
+			}
+		})
+	}
+}
+
+// BenchmarkTLSConnectionSetup measures connect plus first round trip over TLS,
+// comparing a full handshake with one resuming a cached session.
+func BenchmarkTLSConnectionSetup(b *testing.B) {
+	certPath, keyPath, pool := testutil.GenerateTestCertificate(b)
+
+	cases := []struct {
+		name  string
+		cache tls.ClientSessionCache
+	}{
+		{"FullHandshake", nil},
+		{"Resumed", tls.NewLRUClientSessionCache(0)},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			// Create and start server
+			srv := server.New(server.WithPort(0), server.WithTLSFiles(certPath, keyPath))
+			if err := srv.RegisterHandler(server.NewDefaultModelHandler()); err != nil {
+				b.Fatalf("Failed to register handler: %v", err)
+			}
+			if err := srv.Start(); err != nil {
+				b.Fatalf("Failed to start server: %v", err)
+			}
+
+			// Share one session cache across clients so each new client can resume
+			tlsConfig := &tls.Config{RootCAs: pool, ClientSessionCache: tc.cache}
+			options := []client.Option{
+				client.WithServerAddr(srv.Addr().String()),
+				client.WithAutoReconnect(false),
+				client.WithTLSConfig(tlsConfig),
+				client.WithTLSSessionResumption(tc.cache != nil),
+			}
+
+			req := core.NewModelRequest()
+			ctx := context.Background()
 
 			// Reset the benchmark timer to exclude setup time
 			b.ResetTimer()
 
-			// Run the benchmark
-			b.RunParallel(func(pb *testing.PB) {
-				for pb.Next() {
-					_, err := c.ProcessModel(ctx, req)
-					if err != nil {
-						b.Fatalf("ProcessModel failed: %v", err)
-					}
+			for i := 0; i < b.N; i++ {
+				c := client.New(options...)
+				if err := c.Start(); err != nil {
+					b.Fatalf("Failed to start client: %v", err)
 				}
-			})
+				if _, err := c.ProcessModel(ctx, req); err != nil {
+					b.Fatalf("ProcessModel failed: %v", err)
+				}
+				if err := c.Stop(); err != nil {
+					b.Fatalf("Failed to stop client: %v", err)
+				}
+			}
 
-			err = c.Stop()
+			b.StopTimer()
+			if err := srv.Stop(); err != nil {
+				b.Fatalf("Failed to stop server: %v", err)
+			}
+		})
+	}
+}
+
+// BenchmarkRequestTemplate compares instantiating a compiled template with
+// rebuilding the same request from its prototype on every call, walking it to
+// copy the maps and replacing placeholders as they are found.
+func BenchmarkRequestTemplate(b *testing.B) {
+	proto := &core.ModelRequest{
+		ModelData: map[string]interface{}{
+			"run":   "${runID}",
+			"label": "run ${runID} of ${suite}",
+			"input": map[string]interface{}{
+				"features": []interface{}{"${f1}", "${f2}", 0.5, 0.25},
+				"mode":     "fast",
+				"limits":   map[string]interface{}{"tokens": 512, "seconds": 30},
+			},
+		},
+		Parameters: []core.Parameter{
+			{Name: "threshold", Type: "float", Value: "${threshold}"},
+			{Name: "verbose", Type: "bool", Value: true},
+		},
+	}
+	vars := map[string]interface{}{"runID": 42, "suite": "nightly", "f1": 1.5, "f2": 2.5, "threshold": 0.9}
+
+	b.Run("Template", func(b *testing.B) {
+		tmpl, err := core.NewRequestTemplate(proto)
+		if err != nil {
+			b.Fatalf("Failed to compile template: %v", err)
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := tmpl.Instantiate(vars); err != nil {
+				b.Fatalf("Instantiate failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("Rebuild", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := core.NewModelRequest()
+			for key, value := range proto.ModelData {
+				req.ModelData[key] = substitute(value, vars)
+			}
+			for _, param := range proto.Parameters {
+				param.Value = substitute(param.Value, vars)
+				req.Parameters = append(req.Parameters, param)
+			}
+		}
+	})
+}
+
+// substitute copies value, replacing the placeholders in its strings with vars.
+func substitute(value interface{}, vars map[string]interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		for name, v := range vars {
+			placeholder := "${" + name + "}"
+			if value == placeholder {
+				return v
+			}
+			value = strings.ReplaceAll(value, placeholder, fmt.Sprint(v))
+		}
+		return value
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			m[key] = substitute(field, vars)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, item := range value {
+			s[i] = substitute(item, vars)
+		}
+		return s
+	}
+	return value
+}
+
+// fairnessTolerance is how many times worse a huge response being written
+// to one client may make the p99 latency of other clients' small requests in
+// BenchmarkFairness.
+const fairnessTolerance = 3
+
+// hugeResponseHandler answers requests whose model data asks for it with a
+// 50MB response.
+type hugeResponseHandler struct{}
+
+func (hugeResponseHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (hugeResponseHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	if req.ModelData["huge"] == true {
+		resp.Results["blob"] = strings.Repeat("x", 50<<20)
+	}
+	return resp, nil
+}
+
+// BenchmarkFairness measures small requests from 8 clients sharing a single
+// handler slot, alone and while a 50MB response is being written to a client
+// that does not read it, and reports their p99 latency. The huge response
+// must leave that p99 within fairnessTolerance of the baseline.
+func BenchmarkFairness(b *testing.B) {
+	var baseline time.Duration
+	b.Run("Baseline", func(b *testing.B) {
+		baseline = benchmarkFairness(b, false)
+	})
+	b.Run("BesideHugeResponse", func(b *testing.B) {
+		p99 := benchmarkFairness(b, true)
+		if limit := fairnessTolerance*baseline + time.Millisecond; baseline > 0 && p99 > limit {
+			b.Errorf("Small request p99 of %v beside a huge response exceeds %v, against a baseline of %v", p99, limit, baseline)
+		}
+	})
+}
+
+// benchmarkFairness runs b.N small requests, with a huge response being
+// written meanwhile if huge is set, and returns their p99 latency.
+func benchmarkFairness(b *testing.B, huge bool) time.Duration {
+	const clients = 8
+
+	srv := server.New(
+		server.WithPort(0),
+		server.WithMaxConcurrentClients(clients*2),
+		server.WithMaxConcurrentRequests(1),
+		server.WithRequestQueueSize(clients),
+		server.WithLogger(core.NopLogger()),
+	)
+	if err := srv.RegisterHandler(hugeResponseHandler{}); err != nil {
+		b.Fatalf("Failed to register handler: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		b.Fatalf("Failed to start server: %v", err)
+	}
+	defer srv.Stop()
+
+	pool := make(chan *client.Client, clients)
+	for i := 0; i < clients; i++ {
+		c := client.New(client.WithServerAddr(srv.Addr().String()), client.WithLogger(core.NopLogger()))
+		if err := c.Start(); err != nil {
+			b.Fatalf("Failed to start client: %v", err)
+		}
+		defer c.Stop()
+		pool <- c
+	}
+
+	// The huge response is encoded before timing starts and then stays
+	// unread, more than the socket buffers hold, until the benchmark ends
+	if huge {
+		conn, err := net.Dial("tcp", srv.Addr().String())
+		if err != nil {
+			b.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+
+		req := core.NewModelRequest()
+		req.ModelData["huge"] = true
+		call := &jsonrpc2.Request{Method: core.MethodProcessModel, ID: jsonrpc2.ID{Num: 1}}
+		if err := call.SetParams(req); err != nil {
+			b.Fatalf("Failed to encode request: %v", err)
+		}
+		if err := (jsonrpc2.VSCodeObjectCodec{}).WriteObject(conn, call); err != nil {
+			b.Fatalf("Failed to send request: %v", err)
+		}
+		if !testutil.WaitForCondition(10*time.Second, 10*time.Millisecond, func() bool {
+			for _, info := range srv.Connections() {
+				if info.Outbound > 0 {
+					return true
+				}
+			}
+			return false
+		}) {
+			b.Fatal("Huge response was not queued")
+		}
+		time.Sleep(time.Second) // For the writer to encode it and fill the socket buffers
+	}
+
+	req := core.NewModelRequest()
+	req.ModelData["name"] = "Fairness Benchmark"
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	latencies := make([]time.Duration, 0, b.N)
+	b.SetParallelism(clients)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c := <-pool
+			start := time.Now()
+			_, err := c.ProcessModel(ctx, req)
+			elapsed := time.Since(start)
+			pool <- c
 			if err != nil {
-				b.Fatalf("Failed to stop client: %v", err)
+				b.Errorf("ProcessModel failed: %v", err)
+				return
 			}
+			mu.Lock()
+			latencies = append(latencies, elapsed)
+			mu.Unlock()
+		}
+	})
+	b.StopTimer()
 
-			err = srv.Stop()
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[len(latencies)*99/100]
+	b.ReportMetric(float64(p99.Microseconds()), "p99-µs")
+	return p99
+}
+
+// rawPayloadSize is the approximate encoded size of the model data of
+// BenchmarkRawPayload.
+const rawPayloadSize = 10 << 20
+
+// forwardingHandler answers requests with their payload as results, decoding
+// them as ModelHandler does.
+type forwardingHandler struct{}
+
+func (forwardingHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (forwardingHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	resp.Results["payload"] = req.ModelData["payload"]
+	return resp, nil
+}
+
+// rawForwardingHandler answers requests with their model data as results,
+// never decoding it.
+type rawForwardingHandler struct{ forwardingHandler }
+
+func (rawForwardingHandler) ProcessModelRaw(ctx context.Context, id string, raw json.RawMessage, params []core.Parameter) (json.RawMessage, error) {
+	return raw, nil
+}
+
+// BenchmarkRawPayload measures a 10MB payload sent back by its handler,
+// decoded into a ModelRequest and ModelResponse at both ends, and passed
+// through undecoded by ProcessModelRaw and a RawModelHandler.
+func BenchmarkRawPayload(b *testing.B) {
+	modes := []struct {
+		name    string
+		handler server.Handler
+	}{
+		{"Decoded", forwardingHandler{}},
+		{"Raw", rawForwardingHandler{}},
+	}
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			srv := server.New(server.WithPort(0))
+			if err := srv.RegisterHandler(mode.handler); err != nil {
+				b.Fatalf("Failed to register handler: %v", err)
+			}
+			if err := srv.Start(); err != nil {
+				b.Fatalf("Failed to start server: %v", err)
+			}
+			defer srv.Stop()
+
+			c := client.New(client.WithServerAddr(srv.Addr().String()))
+			if err := c.Start(); err != nil {
+				b.Fatalf("Failed to start client: %v", err)
+			}
+			defer c.Stop()
+
+			// A tensor of numbers encoded in about six bytes each, as
+			// costly to decode as model data gets
+			payload := make([]interface{}, 0, rawPayloadSize/6)
+			for i := 0; i < cap(payload); i++ {
+				payload = append(payload, float64(i%1000)/8)
+			}
+			req := core.NewModelRequest()
+			req.ModelData["payload"] = payload
+			raw, err := json.Marshal(req.ModelData)
 			if err != nil {
-				b.Fatalf("Failed to stop server: %v", err)
+				b.Fatalf("Failed to encode payload: %v", err)
+			}
+
+			ctx := context.Background()
+			b.SetBytes(int64(len(raw)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if mode.name == "Raw" {
+					_, err = c.ProcessModelRaw(ctx, req.ID, raw, nil)
+				} else {
+					_, err = c.ProcessModel(ctx, req)
+				}
+				if err != nil {
+					b.Fatalf("Request failed: %v", err)
+				}
 			}
 		})
 	}
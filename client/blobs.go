@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// UploadBlob uploads what r yields as a new blob described by meta, in
+// chunks of the tuned blob chunk size, and returns the ID model requests refer to it by
+// in BlobRefs. A chunk whose connection is lost is sent again once the
+// client reconnects, so the upload carries on where it stopped; without
+// auto-reconnect, or once the reconnection attempts run out, the upload
+// fails and the server discards what it received.
+func (c *Client) UploadBlob(ctx context.Context, r io.Reader, meta core.BlobMeta) (core.BlobID, error) {
+	id := newBlobID()
+	digest := sha256.New()
+	var buf []byte
+	var offset int64
+	for {
+		// The chunk size may be tuned between chunks
+		size := c.tuner.blobChunkSize()
+		if cap(buf) < size {
+			buf = make([]byte, size)
+		}
+		chunk := buf[:size]
+		n, err := io.ReadFull(r, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", fmt.Errorf("failed to read blob: %w", err)
+		}
+		digest.Write(chunk[:n])
+
+		put := core.BlobPutRequest{
+			ID:       id,
+			Offset:   offset,
+			Data:     chunk[:n],
+			Checksum: core.BlobChecksum(chunk[:n]),
+			Final:    err != nil,
+		}
+		if offset == 0 {
+			put.Meta = &meta
+		}
+		if put.Final {
+			put.SHA256 = hex.EncodeToString(digest.Sum(nil))
+		}
+
+		var resp core.BlobPutResponse
+		if err := c.blobCall(ctx, core.MethodBlobPut, put, &resp); err != nil {
+			return "", err
+		}
+		offset += int64(n)
+		if put.Final {
+			return id, nil
+		}
+	}
+}
+
+// DownloadBlob writes the content of the blob id to w, in chunks of the
+// tuned blob chunk size, and returns its metadata. Each chunk, and the blob as a
+// whole, is checked against its checksum, failing with an error wrapping
+// core.ErrBlobChecksum if they differ. Blobs the server does not store fail
+// with an error wrapping core.ErrBlobNotFound.
+func (c *Client) DownloadBlob(ctx context.Context, id core.BlobID, w io.Writer) (core.BlobMeta, error) {
+	digest := sha256.New()
+	var offset int64
+	for {
+		var resp core.BlobGetResponse
+		get := core.BlobGetRequest{ID: id, Offset: offset, Length: c.tuner.blobChunkSize()}
+		if err := c.blobCall(ctx, core.MethodBlobGet, get, &resp); err != nil {
+			return core.BlobMeta{}, err
+		}
+		if core.BlobChecksum(resp.Data) != resp.Checksum {
+			return core.BlobMeta{}, fmt.Errorf("chunk at %d of blob %s: %w", offset, id, core.ErrBlobChecksum)
+		}
+		if _, err := w.Write(resp.Data); err != nil {
+			return core.BlobMeta{}, err
+		}
+		digest.Write(resp.Data)
+		offset += int64(len(resp.Data))
+
+		if resp.EOF {
+			if sum := hex.EncodeToString(digest.Sum(nil)); sum != resp.Meta.SHA256 {
+				return core.BlobMeta{}, fmt.Errorf("blob %s: %w", id, core.ErrBlobChecksum)
+			}
+			return resp.Meta, nil
+		}
+		if len(resp.Data) == 0 {
+			return core.BlobMeta{}, fmt.Errorf("blob %s ended at %d of %d bytes", id, offset, resp.Meta.Size)
+		}
+	}
+}
+
+// blobCall calls method, calling it again whenever it fails without a reply,
+// as when the connection carrying it is lost, while auto-reconnect is on, up
+// to MaxReconnectAttempts times. Blob calls can be repeated, as the server
+// acknowledges a chunk it already has.
+func (c *Client) blobCall(ctx context.Context, method string, params, result interface{}) error {
+	for attempt := 0; ; attempt++ {
+		err := c.call(ctx, method, params, result)
+		var rpcErr *jsonrpc2.Error
+		var modelErr *core.ModelError
+		if err == nil || errors.As(err, &rpcErr) || errors.As(err, &modelErr) || ctx.Err() != nil {
+			return err
+		}
+		if !c.options.AutoReconnect || attempt == c.options.MaxReconnectAttempts {
+			return err
+		}
+		c.options.Logger.Debug("Blob transfer interrupted, retrying", core.LogFieldMethod, method, core.LogFieldError, err)
+
+		// Give the client time to reconnect
+		delay := c.tasks.NewTimer(core.TaskReconnect, c.options.ReconnectDelay)
+		select {
+		case <-ctx.Done():
+			delay.Stop()
+			return ctx.Err()
+		case <-delay.C:
+			delay.Stop()
+		}
+	}
+}
+
+// newBlobID returns a random blob ID.
+func newBlobID() core.BlobID {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate blob ID: %v", err))
+	}
+	return core.BlobID("blob-" + hex.EncodeToString(b[:]))
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// CacheStats reports how the response cache set up with WithResponseCache
+// has been used.
+type CacheStats struct {
+	Entries int    // Responses currently cached
+	Hits    uint64 // Requests answered from the cache
+	Misses  uint64 // Requests sent to the server, then cached if they succeeded
+}
+
+// responseCache keeps the successful responses to model requests by their
+// content, dropping the least recently used beyond its size and those older
+// than its TTL. Responses are kept encoded, so callers never share one.
+type responseCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Of *cacheEntry, most recently used first
+	hits    uint64
+	misses  uint64
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	key      string
+	response []byte
+	expires  time.Time // Zero if the entry does not expire
+}
+
+// newResponseCache returns a cache of up to size responses, each kept for
+// ttl, or nil if size is not positive.
+func newResponseCache(size int, ttl time.Duration) *responseCache {
+	if size <= 0 {
+		return nil
+	}
+	return &responseCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// wrap answers requests to method from the cache where it can, sending the
+// rest with process and caching their responses if they succeeded. Requests
+// choose otherwise with core.MetadataCache. A nil cache returns process.
+func (r *responseCache) wrap(method string, process core.ProcessFunc) core.ProcessFunc {
+	if r == nil {
+		return process
+	}
+	return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		if req == nil || req.Metadata[core.MetadataCache] == core.CacheBypass {
+			return process(ctx, req)
+		}
+		key, err := cacheKey(method, req)
+		if err != nil {
+			return process(ctx, req)
+		}
+		if req.Metadata[core.MetadataCache] != core.CacheRefresh {
+			if resp, ok := r.get(key); ok {
+				resp.ID = req.ID
+				return resp, nil
+			}
+		}
+
+		resp, err := process(ctx, req)
+		if err == nil && resp != nil && resp.Success {
+			r.put(key, resp)
+		}
+		return resp, err
+	}
+}
+
+// cacheKey identifies the requests to method that get the same response as
+// req: those with the same model data and parameters, whatever their ID and
+// metadata. Maps are encoded with sorted keys, so the key is stable.
+func cacheKey(method string, req *core.ModelRequest) (string, error) {
+	content, err := json.Marshal(struct {
+		Method     string                 `json:"method"`
+		ModelData  map[string]interface{} `json:"modelData"`
+		Parameters []core.Parameter       `json:"parameters"`
+		BlobRefs   []core.BlobID          `json:"blobRefs,omitempty"`
+	}{method, req.ModelData, req.Parameters, req.BlobRefs})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// get returns a copy of the response cached under key, if it has not expired.
+func (r *responseCache) get(key string) (*core.ModelResponse, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elem, ok := r.entries[key]
+	if ok && !elem.Value.(*cacheEntry).expired(time.Now()) {
+		var resp core.ModelResponse
+		if json.Unmarshal(elem.Value.(*cacheEntry).response, &resp) == nil {
+			r.order.MoveToFront(elem)
+			r.hits++
+			return &resp, true
+		}
+	}
+	if ok {
+		r.remove(elem)
+	}
+	r.misses++
+	return nil, false
+}
+
+// put caches resp under key, dropping the least recently used entry if the
+// cache is full.
+func (r *responseCache) put(key string, resp *core.ModelResponse) {
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	entry := &cacheEntry{key: key, response: encoded}
+	if r.ttl > 0 {
+		entry.expires = time.Now().Add(r.ttl)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.entries[key]; ok {
+		r.remove(elem)
+	}
+	r.entries[key] = r.order.PushFront(entry)
+	for r.order.Len() > r.size {
+		r.remove(r.order.Back())
+	}
+}
+
+// remove drops a cached entry. The caller must hold mu.
+func (r *responseCache) remove(elem *list.Element) {
+	delete(r.entries, elem.Value.(*cacheEntry).key)
+	r.order.Remove(elem)
+}
+
+// stats reports the cache's use, or nothing for a nil cache.
+func (r *responseCache) stats() CacheStats {
+	if r == nil {
+		return CacheStats{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return CacheStats{Entries: r.order.Len(), Hits: r.hits, Misses: r.misses}
+}
+
+// expired reports whether the entry has expired at now.
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startCachingClient connects a client with a response cache to a mock
+// server counting the requests it answers. Requests whose model data has
+// "fail" set get a failed response.
+func startCachingClient(t *testing.T, maxEntries int, ttl time.Duration) (*Client, *atomic.Int32) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	t.Cleanup(func() { mockServer.Close() })
+
+	var calls atomic.Int32
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		n := calls.Add(1)
+		if fail, _ := req.GetBool("fail"); fail {
+			return core.ErrorResponse(req, assert.AnError), nil
+		}
+		resp := core.NewModelResponse(req)
+		resp.Results["call"] = n
+		return resp, nil
+	})
+
+	c := New(WithServerHost("localhost"), WithServerPort(mockServer.Port()),
+		WithAutoReconnect(false), WithResponseCache(maxEntries, ttl))
+	require.NoError(t, c.Start(), "Client should start")
+	t.Cleanup(func() { c.Stop() })
+	return c, &calls
+}
+
+func TestResponseCacheHit(t *testing.T) {
+	c, calls := startCachingClient(t, 10, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first := testutil.CreateTestModelRequest()
+	resp, err := c.ProcessModel(ctx, first)
+	require.NoError(t, err, "First request should succeed")
+
+	// An identical request with another ID is answered from the cache
+	second := testutil.CreateTestModelRequest()
+	second.ID = "another"
+	cached, err := c.ProcessModel(ctx, second)
+	require.NoError(t, err, "Repeated request should succeed")
+	assert.Equal(t, int32(1), calls.Load(), "Repeated request should not reach the server")
+	assert.Equal(t, "another", cached.ID, "Cached response should carry the request's ID")
+	assert.Equal(t, resp.Results, cached.Results, "Cached response should have the first response's results")
+
+	// Callers get copies, so changing one leaves the cache alone
+	cached.Results["call"] = "changed"
+	again, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Repeated request should succeed")
+	assert.Equal(t, resp.Results["call"], again.Results["call"], "Cached response should be unchanged")
+
+	// Differing parameters miss
+	other := testutil.CreateTestModelRequest()
+	other.Parameters[0].Value = "value2"
+	_, err = c.ProcessModel(ctx, other)
+	require.NoError(t, err, "Request with other parameters should succeed")
+	assert.Equal(t, int32(2), calls.Load(), "Request with other parameters should reach the server")
+
+	stats := c.Stats().ResponseCache
+	assert.Equal(t, CacheStats{Entries: 2, Hits: 2, Misses: 2}, stats, "Cache use should be reported")
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	c, calls := startCachingClient(t, 10, 50*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "First request should succeed")
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Repeated request should succeed")
+	assert.Equal(t, int32(1), calls.Load(), "Repeated request should be answered from the cache")
+
+	time.Sleep(60 * time.Millisecond)
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request after expiry should succeed")
+	assert.Equal(t, int32(2), calls.Load(), "Expired response should be fetched again")
+	assert.Equal(t, float64(2), resp.Results["call"], "Fresh response should be returned")
+}
+
+func TestResponseCacheSkipsFailures(t *testing.T) {
+	c, calls := startCachingClient(t, 10, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["fail"] = true
+	for i := 0; i < 2; i++ {
+		resp, err := c.ProcessModel(ctx, req)
+		require.NoError(t, err, "Failed response should be returned")
+		assert.False(t, resp.Success, "Response should report the failure")
+	}
+	assert.Equal(t, int32(2), calls.Load(), "Failed responses should not be cached")
+}
+
+func TestResponseCacheMetadata(t *testing.T) {
+	c, calls := startCachingClient(t, 10, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "First request should succeed")
+
+	// Bypassing sends the request without touching the cache
+	bypass := testutil.CreateTestModelRequest()
+	bypass.Metadata = map[string]string{core.MetadataCache: core.CacheBypass}
+	resp, err := c.ProcessModel(ctx, bypass)
+	require.NoError(t, err, "Bypassing request should succeed")
+	assert.Equal(t, float64(2), resp.Results["call"], "Bypassing request should reach the server")
+
+	resp, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Repeated request should succeed")
+	assert.Equal(t, float64(1), resp.Results["call"], "Bypassing should leave the cached response")
+
+	// Refreshing replaces the cached response, also when asked through the context
+	refreshCtx := core.ContextWithMetadata(ctx, map[string]string{core.MetadataCache: core.CacheRefresh})
+	resp, err = c.ProcessModel(refreshCtx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Refreshing request should succeed")
+	assert.Equal(t, float64(3), resp.Results["call"], "Refreshing request should reach the server")
+
+	resp, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Repeated request should succeed")
+	assert.Equal(t, float64(3), resp.Results["call"], "Refreshed response should be cached")
+	assert.Equal(t, int32(3), calls.Load(), "Only bypassing and refreshing requests should reach the server")
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newResponseCache(2, 0)
+	resp := &core.ModelResponse{Success: true}
+	cache.put("a", resp)
+	cache.put("b", resp)
+
+	_, ok := cache.get("a")
+	require.True(t, ok, "Entry should be cached")
+	cache.put("c", resp)
+
+	_, ok = cache.get("b")
+	assert.False(t, ok, "Least recently used entry should be evicted")
+	_, ok = cache.get("a")
+	assert.True(t, ok, "Recently used entry should be kept")
+	_, ok = cache.get("c")
+	assert.True(t, ok, "New entry should be kept")
+	assert.Equal(t, 2, cache.stats().Entries, "Cache should hold at most its size")
+
+	assert.Nil(t, newResponseCache(0, time.Minute), "Zero size should disable the cache")
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// initialize performs the handshake over conn to the server at addr and
+// returns the capabilities negotiated with it, or nil for a server predating
+// the handshake.
+func (c *Client) initialize(ctx context.Context, conn *jsonrpc2.Conn, addr string) (*core.Capabilities, error) {
+	own := core.Capabilities{ProtocolVersion: core.ProtocolVersion, Features: c.options.Features}
+	var serverCaps core.Capabilities
+	err := conn.Call(ctx, core.MethodInitialize, own, &serverCaps)
+
+	var rpcErr *jsonrpc2.Error
+	switch {
+	case err == nil:
+	// A server predating the handshake refuses it like any unknown method,
+	// once the client authenticated if it requires that
+	case errors.As(err, &rpcErr) && (rpcErr.Code == jsonrpc2.CodeMethodNotFound || rpcErr.Code == core.CodeUnauthenticated):
+		c.options.Logger.Debug("Server does not initialize, continuing without capabilities", core.LogFieldRemoteAddr, addr)
+		return nil, nil
+	case errors.As(err, &rpcErr) && rpcErr.Code == core.CodeUnsupportedVersion:
+		return nil, fmt.Errorf("%w: %s", core.ErrUnsupportedVersion, rpcErr.Message)
+	default:
+		return nil, err
+	}
+
+	negotiated := core.Negotiate(own, serverCaps)
+	if negotiated.ProtocolVersion < core.ProtocolVersion {
+		c.options.Logger.Info("Server speaks an older protocol version",
+			core.LogFieldRemoteAddr, addr,
+			"version", serverCaps.ProtocolVersion,
+			"features", negotiated.Features)
+	}
+	return &negotiated, nil
+}
+
+// ServerCapabilities returns the capabilities negotiated with the server on
+// the most recent connection: the lower of the two protocol versions and the
+// features both sides support. It returns nil before the client connects and
+// for servers that predate the handshake, whose features are not checked.
+func (c *Client) ServerCapabilities() *core.Capabilities {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.capabilities
+}
+
+// requireFeature returns an error wrapping core.ErrUnsupportedCapability if
+// feature was not negotiated with the server.
+func (c *Client) requireFeature(feature core.Feature) error {
+	if caps := c.ServerCapabilities(); caps != nil && !caps.Supports(feature) {
+		return fmt.Errorf("%w: %s was not negotiated with the server", core.ErrUnsupportedCapability, feature)
+	}
+	return nil
+}
@@ -4,11 +4,14 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/narcolepticfox/mcp/core"
@@ -19,18 +22,43 @@ import (
 // It manages the connection, handles request/response communication, and
 // provides methods for model processing operations.
 type Client struct {
-	options          Options
-	status           core.Status
-	statusMu         sync.RWMutex
-	conn             *jsonrpc2.Conn
-	connMu           sync.RWMutex
-	callbacks        []func(core.StatusChangeEvent)
-	reconnectAttempt int
-	isConnected      bool
-
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	options       Options
+	status        core.Status
+	statusMu      sync.RWMutex
+	conns         []*pooledConn // One slot per pooled connection; nil while it is down
+	next          uint64        // Round-robin offset for pick; accessed atomically
+	callSeq       uint64        // Source of call IDs; accessed atomically
+	remoteAddr    net.Addr
+	connMu        sync.RWMutex
+	statusEvents  *core.StatusNotifier
+	connEvents    *connectionNotifier
+	connSince     time.Time // When the client last went from no open connection to one, or back; guarded by connMu
+	reconnecting  int       // Reconnection attempt in progress; guarded by connMu
+	lastConnErr   error     // Why the last connection dropped or reconnection attempt failed; guarded by connMu
+	tlsState      *tls.ConnectionState
+	frames        core.FrameCodec    // Codec negotiated on the most recent connection
+	capabilities  *core.Capabilities // Negotiated in the handshake on the most recent connection; nil if the server predates it
+	principal     *core.Principal
+	sessionCache  tls.ClientSessionCache
+	stats         Stats
+	tasks         *core.TaskTracker
+	sinks         *core.SinkSet
+	metrics       core.MetricsCollector
+	schemas       schemaCache
+	notifications *core.NotificationRouter
+	link          linkMonitor
+	tuner         *linkTuner
+	streams       streamRegistry
+	topics        topicSet
+	watches       watchRegistry
+	resources     resourceWatches
+	hooks         requestHooks
+	cache         *responseCache // Nil without WithResponseCache
+
+	lifecycleMu sync.Mutex         // Serializes Start and Stop
+	ctx         context.Context    // Context of the current run, made afresh by Start; guarded by statusMu
+	cancel      context.CancelFunc // Cancels ctx; guarded by statusMu
+	wg          sync.WaitGroup
 }
 
 // New creates a new MCP client with the given options.
@@ -43,163 +71,577 @@ func New(options ...Option) *Client {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-
-	return &Client{
-		options:   opts,
-		status:    core.StatusStopped,
-		callbacks: make([]func(core.StatusChangeEvent), 0),
-		ctx:       ctx,
-		cancel:    cancel,
+	tasks := core.NewTaskTracker(opts.Logger, opts.TaskBudgets)
+	sinks := core.NewSinkSet(opts.Logger, tasks)
+
+	c := &Client{
+		options:       opts,
+		status:        core.StatusStopped,
+		conns:         make([]*pooledConn, max(opts.ConnectionPoolSize, 1)),
+		statusEvents:  core.NewStatusNotifier(opts.Logger, tasks),
+		connEvents:    newConnectionNotifier(opts.Logger, tasks),
+		sessionCache:  tls.NewLRUClientSessionCache(0),
+		tasks:         tasks,
+		sinks:         sinks,
+		metrics:       sinks.Metrics("metrics", opts.Metrics),
+		notifications: core.NewNotificationRouter(tasks),
+		cache:         newResponseCache(opts.ResponseCacheSize, opts.ResponseCacheTTL),
+		tuner:         newLinkTuner(opts),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	if opts.addrErr != nil {
+		opts.Logger.Error("Ignoring server address", core.LogFieldError, opts.addrErr)
+	}
+	if opts.importErr != nil {
+		opts.Logger.Error("Ignoring imported client state", core.LogFieldError, opts.importErr)
+	}
+	if opts.ImportedState != nil {
+		c.link.restore(opts.ImportedState.Link)
+	}
+	if opts.TuningStateFile != "" && !opts.StaticTuning {
+		if err := c.tuner.load(opts.TuningStateFile); err != nil {
+			opts.Logger.Warn("Ignoring tuning state", core.LogFieldError, err)
+		}
 	}
+	return c
 }
 
 // Start connects to the server and starts the client.
-// It establishes a connection to the configured server and initializes
-// the JSON-RPC communication channel. Returns an error if the client
-// is already running or if connection fails.
+// It establishes every pooled connection to the configured server and
+// initializes the JSON-RPC communication channels. Returns an error if the
+// options do not validate, if the client is already running or if any
+// connection fails.
 func (c *Client) Start() error {
+	if err := c.options.Validate(); err != nil {
+		return fmt.Errorf("invalid client options: %w", err)
+	}
+
+	c.lifecycleMu.Lock()
+	defer c.lifecycleMu.Unlock()
+
 	c.statusMu.Lock()
 	if c.status != core.StatusStopped {
 		c.statusMu.Unlock()
 		return fmt.Errorf("cannot start client in %s state", c.status)
 	}
+	// Each run gets a context of its own, since Stop cancels the last one
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 	c.updateStatusLocked(core.StatusStarting, nil)
 	c.statusMu.Unlock()
 
-	if err := c.connect(); err != nil {
-		c.updateStatus(core.StatusFailed, err)
-		return err
+	for slot := range c.conns {
+		if err := c.connect(slot, 0, c.address()); err != nil {
+			c.closeConns()
+			c.updateStatus(core.StatusFailed, err)
+			return err
+		}
 	}
 
 	c.updateStatus(core.StatusRunning, nil)
-	log.Printf("MCP client connected to %s:%d", c.options.ServerHost, c.options.ServerPort)
+	c.options.Logger.Info("MCP client connected", core.LogFieldRemoteAddr, c.remoteAddrString())
 
 	return nil
 }
 
-// connect establishes a TCP connection to the MCP server and sets up the JSON-RPC communication.
-// It creates the necessary streams and handlers, and starts a background goroutine to monitor
-// the connection status.
-func (c *Client) connect() error {
-	// Create TCP connection
-	addr := fmt.Sprintf("%s:%d", c.options.ServerHost, c.options.ServerPort)
-
-	dialer := &net.Dialer{
-		Timeout: c.options.ConnectionTimeout,
-	}
+// connect establishes the pooled connection in slot to the MCP server at addr and sets up the
+// JSON-RPC communication. It creates the necessary streams and handlers, and starts a background
+// goroutine to monitor the connection status. Attempt is the reconnection attempt it is, or zero.
+func (c *Client) connect(slot, attempt int, addr string) error {
+	// Dial the server on the configured transport
+	ctx, cancel := context.WithTimeout(c.runContext(), c.options.ConnectionTimeout)
+	defer cancel()
 
-	netConn, err := dialer.Dial("tcp", addr)
+	netConn, err := c.options.Transport.Dial(ctx, addr)
 	if err != nil {
 		return fmt.Errorf("failed to connect to %s: %w", addr, err)
 	}
 
+	// Secure the connection before any protocol traffic
+	var tlsState *tls.ConnectionState
+	if c.options.EnableTLS {
+		tlsConn := tls.Client(netConn, c.tlsConfig())
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return fmt.Errorf("TLS handshake with %s failed: %w", addr, err)
+		}
+		state := tlsConn.ConnectionState()
+		tlsState = &state
+		if state.DidResume {
+			atomic.AddUint64(&c.stats.ResumedHandshakes, 1)
+		}
+		netConn = tlsConn
+	}
+	// Agree on compression and codec before any other protocol traffic
+	var frames core.FrameCodec
+	if len(core.CompressionPreference(c.options.Compression, c.options.CompressionFallbacks)) > 0 || c.options.Codec != nil {
+		negotiated, chosen, err := c.negotiate(ctx, netConn)
+		if err != nil {
+			netConn.Close()
+			return fmt.Errorf("negotiation with %s failed: %w", addr, err)
+		}
+		netConn, frames = negotiated, chosen
+	}
+	frames.MaxBytes = c.options.MaxResponseBytes
+	if c.options.ReadTimeout > 0 || c.options.WriteTimeout > 0 {
+		netConn = &deadlineConn{Conn: netConn, readTimeout: c.options.ReadTimeout, writeTimeout: c.options.WriteTimeout}
+	}
+	codec := &limitedCodec{FrameCodec: frames, logger: c.options.Logger, tuner: c.tuner}
+	atomic.AddUint64(&c.stats.Connections, 1)
+
 	// Create JSON-RPC stream
-	stream := jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{})
+	stream := jsonrpc2.NewBufferedStream(netConn, codec)
 
 	// Create JSON-RPC handler
 	handler := &rpcHandler{client: c}
 
 	// Create JSON-RPC connection
+	conn := jsonrpc2.NewConn(c.runContext(), stream, handler)
+
+	// Agree on the protocol version and features before anything else is called
+	caps, err := c.initialize(ctx, conn, addr)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("handshake with %s failed: %w", addr, err)
+	}
+
+	// Authenticate before the connection is handed out
+	if c.options.AuthScheme != "" {
+		if err := c.authenticate(ctx, conn); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
 	c.connMu.Lock()
-	c.conn = jsonrpc2.NewConn(c.ctx, stream, handler)
-	c.isConnected = true
+	if c.openLocked() == 0 {
+		c.connSince = time.Now()
+	}
+	c.conns[slot] = &pooledConn{conn: conn}
+	c.remoteAddr = netConn.RemoteAddr()
+	c.tlsState = tlsState
+	c.frames = frames
+	c.capabilities = caps
 	c.connMu.Unlock()
+	remote := netConn.RemoteAddr().String()
+	c.metrics.ConnectionOpened(remote)
+	c.connEvents.publish(true, core.ConnectionEvent{RemoteAddr: remote, Connection: slot, Attempt: attempt})
+
+	// The server may have changed while we were away
+	c.invalidateSchemas()
+
+	// Subscriptions belong to the primary connection, so renew them and catch
+	// up on what watched jobs did in the meantime
+	if slot == 0 {
+		c.resubscribe(ctx, conn)
+		c.watches.resync()
+	}
 
 	// Monitor connection
 	c.wg.Add(1)
-	go c.monitorConnection()
+	c.tasks.Go(core.TaskConnection, func() { c.monitorConnection(slot, conn, remote) })
+
+	// Probe the connection so a half-open socket is detected
+	if c.options.HeartbeatInterval > 0 {
+		c.wg.Add(1)
+		c.tasks.Go(core.TaskKeepalive, func() { c.heartbeat(conn) })
+	}
 
 	return nil
 }
 
-func (c *Client) monitorConnection() {
-	defer c.wg.Done()
+// authenticate presents the configured credentials over conn and records the
+// principal the server returns.
+func (c *Client) authenticate(ctx context.Context, conn *jsonrpc2.Conn) error {
+	credentials, err := c.options.AuthCredentials(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain credentials: %w", err)
+	}
 
-	c.connMu.RLock()
-	conn := c.conn
-	c.connMu.RUnlock()
+	var resp core.AuthResponse
+	req := core.AuthRequest{Scheme: c.options.AuthScheme, Credentials: credentials}
+	if err := conn.Call(ctx, core.MethodAuthenticate, req, &resp); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
 
+	c.connMu.Lock()
+	c.principal = &resp.Principal
+	c.connMu.Unlock()
+	return nil
+}
+
+// call invokes method on the server over the pooled connection with the
+// fewest calls in flight. If the server reports that the session is no longer
+// authenticated, the client authenticates again and retries once.
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	pc := c.pick()
+	if pc == nil {
+		return errors.New("not connected to server")
+	}
+	defer pc.release()
+	return c.callOn(ctx, pc.conn, method, params, result)
+}
+
+// invoke calls method over conn, waiting for the reply no longer than the
+// RequestTimeout if ctx has no deadline. If ctx ends before the reply
+// arrives, the server is sent core.MethodCancelRequest so it stops handling
+// the call.
+func (c *Client) invoke(ctx context.Context, conn *jsonrpc2.Conn, method string, payload []byte, reply *json.RawMessage) error {
+	ctx, cancel := c.withRequestTimeout(ctx, method)
+	defer cancel()
+
+	// Calls carry IDs of the client's choosing so a cancellation can name
+	// them; strings never collide with the connection's own numeric IDs
+	id := jsonrpc2.ID{Str: strconv.FormatUint(atomic.AddUint64(&c.callSeq, 1), 10), IsString: true}
+	call, err := conn.DispatchCall(ctx, method, json.RawMessage(payload), jsonrpc2.PickID(id))
+	if err != nil {
+		return err
+	}
+
+	err = call.Wait(ctx, reply)
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		// The caller is not kept waiting on a connection slow to take it
+		c.tasks.Go(core.TaskConnection, func() {
+			conn.Notify(context.Background(), core.MethodCancelRequest, core.CancelRequestParams{ID: id})
+		})
+		var timeoutErr *RequestTimeoutError
+		if errors.As(context.Cause(ctx), &timeoutErr) {
+			return timeoutErr
+		}
+	}
+	return err
+}
+
+// callOn invokes method on the server over conn, as call does.
+func (c *Client) callOn(ctx context.Context, conn *jsonrpc2.Conn, method string, params, result interface{}) error {
 	if conn == nil {
-		return
+		return errors.New("not connected to server")
 	}
 
+	// Encode and decode here so the metrics see the payload sizes
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode params: %w", err)
+	}
+
+	metrics := c.metrics
+	metrics.RequestStarted(method)
+	start := time.Now()
+
+	var reply json.RawMessage
+	err = c.invoke(ctx, conn, method, payload, &reply)
+
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == core.CodeUnauthenticated && c.options.AuthScheme != "" {
+		c.options.Logger.Info("Server requires re-authentication",
+			core.LogFieldMethod, method,
+			"reason", rpcErr.Message)
+		if authErr := c.authenticate(ctx, conn); authErr != nil {
+			metrics.RequestCompleted(method, time.Since(start), false)
+			return fmt.Errorf("RPC error: %w", authErr)
+		}
+		err = c.invoke(ctx, conn, method, payload, &reply)
+	}
+
+	rtt := time.Since(start)
+	metrics.RequestCompleted(method, rtt, err == nil)
+	metrics.PayloadSize(method, len(reply), len(payload))
+	if err != nil {
+		if !errors.As(err, &rpcErr) && ctx.Err() == nil {
+			c.tuner.interrupted(method)
+		}
+		if errors.As(err, &rpcErr) {
+			if modelErr := core.ModelErrorFromRPC(rpcErr); modelErr != nil {
+				err = modelErr
+			}
+			switch rpcErr.Code {
+			case core.CodeUnsupportedCapability:
+				err = fmt.Errorf("%w: %w", core.ErrUnsupportedCapability, rpcErr)
+			case core.CodeToolNotFound:
+				err = fmt.Errorf("%w: %w", core.ErrToolNotFound, rpcErr)
+			case core.CodeResourceNotFound:
+				err = fmt.Errorf("%w: %w", core.ErrResourceNotFound, rpcErr)
+			case core.CodeBlobNotFound:
+				err = fmt.Errorf("%w: %w", core.ErrBlobNotFound, rpcErr)
+			}
+		}
+		return fmt.Errorf("RPC error: %w", err)
+	}
+	sample := LinkSample{Time: time.Now(), Method: method, RTT: rtt, BytesOut: len(payload), BytesIn: len(reply)}
+	c.link.record(sample)
+	c.tuner.observe(sample)
+	if err := json.Unmarshal(reply, result); err != nil {
+		return fmt.Errorf("failed to decode result: %w", err)
+	}
+	return nil
+}
+
+// address returns the server address passed to the transport.
+func (c *Client) address() string {
+	return net.JoinHostPort(c.options.ServerHost, strconv.Itoa(c.options.ServerPort))
+}
+
+// tlsConfig returns the TLS configuration for dialing, defaulting the server
+// name to the configured host so certificate verification works out of the box.
+func (c *Client) tlsConfig() *tls.Config {
+	config := &tls.Config{}
+	if c.options.TLSConfig != nil {
+		config = c.options.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = c.options.ServerHost
+	}
+	if config.ClientSessionCache == nil && c.options.TLSSessionResumption {
+		config.ClientSessionCache = c.sessionCache
+	}
+	return config
+}
+
+// resolveAddress looks up the server host so a reconnect can dial an IP
+// address directly. It falls back to the configured address if the transport
+// is not TCP, the host is already an IP, or the lookup fails.
+func (c *Client) resolveAddress() string {
+	addr := c.address()
+	if _, ok := c.options.Transport.(core.TCPTransport); !ok {
+		return addr
+	}
+	if net.ParseIP(c.options.ServerHost) != nil {
+		return addr
+	}
+
+	ctx, cancel := context.WithTimeout(c.runContext(), c.options.ConnectionTimeout)
+	defer cancel()
+
+	hosts, err := net.DefaultResolver.LookupHost(ctx, c.options.ServerHost)
+	if err != nil || len(hosts) == 0 {
+		return addr
+	}
+	return net.JoinHostPort(hosts[0], strconv.Itoa(c.options.ServerPort))
+}
+
+// monitorConnection waits for the pooled connection in slot, to the server at
+// remote, to drop, takes it out of the pool and, if enabled, dials a
+// replacement.
+func (c *Client) monitorConnection(slot int, conn *jsonrpc2.Conn, remote string) {
+	defer c.wg.Done()
+
 	// Wait for disconnection
 	<-conn.DisconnectNotify()
 
+	// A connection Stop closes is not lost
+	var err error
+	if c.Status() != core.StatusStopping {
+		err = errConnectionLost
+	}
+
 	c.connMu.Lock()
-	c.isConnected = false
+	if pc := c.conns[slot]; pc != nil && pc.conn == conn {
+		c.conns[slot] = nil
+		if c.openLocked() == 0 {
+			c.connSince = time.Now()
+		}
+	}
+	if err != nil {
+		c.lastConnErr = err
+	}
 	c.connMu.Unlock()
 
-	log.Printf("Disconnected from server")
+	c.metrics.ConnectionClosed(remote)
+	c.connEvents.publish(false, core.ConnectionEvent{RemoteAddr: remote, Connection: slot, Error: err})
+	c.options.Logger.Debug("Disconnected from server", core.LogFieldRemoteAddr, remote, "connection", slot)
 
 	// Handle reconnection if enabled
-	if c.options.AutoReconnect && c.status == core.StatusRunning {
-		c.attemptReconnect()
+	if c.options.AutoReconnect && c.Status() == core.StatusRunning {
+		c.attemptReconnect(slot)
+		return
+	}
+	if slot == 0 {
+		c.watches.fail(errors.New("disconnected from server"))
 	}
 }
 
-func (c *Client) attemptReconnect() {
-	for c.reconnectAttempt < c.options.MaxReconnectAttempts {
-		c.reconnectAttempt++
-
-		log.Printf("Attempting to reconnect (%d/%d)...",
-			c.reconnectAttempt, c.options.MaxReconnectAttempts)
+// heartbeat pings the server over conn every HeartbeatInterval and closes the
+// connection once MaxMissedHeartbeats consecutive pings go unanswered. Closing
+// wakes monitorConnection, which takes the regular auto-reconnect path.
+func (c *Client) heartbeat(conn *jsonrpc2.Conn) {
+	defer c.wg.Done()
 
-		// Wait before reconnecting
-		time.Sleep(c.options.ReconnectDelay)
+	ticker := c.tasks.NewTicker(core.TaskKeepalive, c.options.HeartbeatInterval)
+	defer ticker.Stop()
 
-		// Check if we're shutting down
+	missed := 0
+	for {
 		select {
-		case <-c.ctx.Done():
+		case <-c.runContext().Done():
+			return
+		case <-conn.DisconnectNotify():
 			return
-		default:
-			// Continue with reconnection
+		case <-ticker.C:
 		}
 
-		if err := c.connect(); err != nil {
-			log.Printf("Reconnection attempt failed: %v", err)
+		if err := c.ping(conn); err != nil {
+			missed++
+			c.options.Logger.Warn("Heartbeat missed",
+				"missed", missed,
+				"max_missed", c.options.MaxMissedHeartbeats,
+				core.LogFieldError, err)
+			if missed >= c.options.MaxMissedHeartbeats {
+				c.options.Logger.Error("Server stopped answering heartbeats, closing connection",
+					core.LogFieldRemoteAddr, c.remoteAddrString())
+				conn.Close()
+				return
+			}
+			continue
+		}
+		missed = 0
+	}
+}
+
+// ping sends a single keepalive probe and waits up to HeartbeatTimeout for the reply.
+func (c *Client) ping(conn *jsonrpc2.Conn) error {
+	ctx, cancel := context.WithTimeout(c.runContext(), c.options.HeartbeatTimeout)
+	defer cancel()
+
+	var pong core.PingResponse
+	err := conn.Call(ctx, core.MethodPing, nil, &pong)
+
+	// Any reply, even an error from a server without ping support, proves the peer is alive
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		return nil
+	}
+	return err
+}
+
+// attemptReconnect dials a replacement for the pooled connection in slot. The
+// client fails once the attempts are exhausted with no connection left open;
+// while others remain, only the slot is given up.
+func (c *Client) attemptReconnect(slot int) {
+	defer c.setReconnecting(0)
+
+	for attempt := 1; attempt <= c.options.MaxReconnectAttempts; attempt++ {
+		c.setReconnecting(attempt)
+		c.options.Logger.Debug("Attempting to reconnect",
+			"attempt", attempt,
+			"max_attempts", c.options.MaxReconnectAttempts,
+			"connection", slot)
+
+		// Resolve the server while waiting so the dial starts as soon as the delay ends
+		resolved := make(chan string, 1)
+		c.tasks.Go(core.TaskReconnect, func() { resolved <- c.resolveAddress() })
+
+		// Wait before reconnecting, unless we're shutting down
+		delay := c.tasks.NewTimer(core.TaskReconnect, c.reconnectDelay(attempt))
+		select {
+		case <-c.runContext().Done():
+			delay.Stop()
+			return
+		case <-delay.C:
+			delay.Stop()
+		}
+		addr := <-resolved
+
+		if err := c.connect(slot, attempt, addr); err != nil {
+			c.connMu.Lock()
+			c.lastConnErr = err
+			c.connMu.Unlock()
+			c.connEvents.publish(false, core.ConnectionEvent{RemoteAddr: addr, Connection: slot, Attempt: attempt, Error: err})
+			c.options.Logger.Warn("Reconnection attempt failed", "attempt", attempt, "connection", slot, core.LogFieldError, err)
 		} else {
-			log.Printf("Reconnected to server")
-			c.reconnectAttempt = 0
+			c.options.Logger.Info("Reconnected to server", core.LogFieldRemoteAddr, c.remoteAddrString(), "connection", slot)
 			return
 		}
 	}
 
-	log.Printf("Max reconnection attempts reached")
-	c.updateStatus(core.StatusFailed, errors.New("max reconnection attempts reached"))
+	c.options.Logger.Error("Max reconnection attempts reached", "max_attempts", c.options.MaxReconnectAttempts, "connection", slot)
+	if slot == 0 {
+		c.watches.fail(errors.New("max reconnection attempts reached"))
+	}
+	if !c.IsConnected() {
+		c.updateStatus(core.StatusFailed, errors.New("max reconnection attempts reached"))
+	}
+}
+
+// reconnectDelay returns the time to wait before reconnection attempt, from
+// 1: ReconnectDelay, doubled for each attempt before it up to the
+// MaxReconnectDelay, if any.
+func (c *Client) reconnectDelay(attempt int) time.Duration {
+	delay := c.options.ReconnectDelay
+	for ; attempt > 1 && delay < c.options.MaxReconnectDelay; attempt-- {
+		delay *= 2
+	}
+	if c.options.MaxReconnectDelay > 0 {
+		delay = min(delay, c.options.MaxReconnectDelay)
+	}
+	return delay
+}
+
+// setReconnecting records the reconnection attempt in progress, or zero.
+func (c *Client) setReconnecting(attempt int) {
+	c.connMu.Lock()
+	c.reconnecting = attempt
+	c.connMu.Unlock()
 }
 
-// Stop disconnects from the server and stops the client.
+// Stop disconnects from the server and stops the client. A client that
+// failed, to start or after running out of reconnection attempts, or is
+// starting, is stopped too, once Start returns; stopping a stopped client
+// does nothing. A stopped client can be started again.
 func (c *Client) Stop() error {
+	c.lifecycleMu.Lock()
+	defer c.lifecycleMu.Unlock()
+
 	c.statusMu.Lock()
-	if c.status != core.StatusRunning {
+	switch c.status {
+	case core.StatusStopped:
+		c.statusMu.Unlock()
+		return nil
+	case core.StatusRunning, core.StatusFailed:
+	default:
 		c.statusMu.Unlock()
 		return fmt.Errorf("cannot stop client in %s state", c.status)
 	}
 	c.updateStatusLocked(core.StatusStopping, nil)
+	cancel := c.cancel
 	c.statusMu.Unlock()
 
 	// Cancel the context to signal shutdown
-	c.cancel()
+	cancel()
 
-	// Close the connection
-	c.connMu.Lock()
-	if c.conn != nil {
-		c.conn.Close()
-		c.conn = nil
-	}
-	c.isConnected = false
-	c.connMu.Unlock()
+	// Close the connections
+	c.closeConns()
 
 	// Wait for all goroutines to finish
 	c.wg.Wait()
 
+	if c.options.TuningStateFile != "" && !c.options.StaticTuning {
+		if err := c.tuner.save(c.options.TuningStateFile); err != nil {
+			c.options.Logger.Warn("Failed to save tuning state", core.LogFieldError, err)
+		}
+	}
+
 	c.updateStatus(core.StatusStopped, nil)
-	log.Printf("MCP client stopped")
+	c.options.Logger.Info("MCP client stopped")
 
 	return nil
 }
 
+// runContext returns the context of the client's current run, which Stop
+// cancels.
+func (c *Client) runContext() context.Context {
+	c.statusMu.RLock()
+	defer c.statusMu.RUnlock()
+	return c.ctx
+}
+
+// Options returns a copy of the options the client was created with, after
+// defaults and every Option were applied, for example to log the effective
+// configuration at startup. Changing the copy, including its TLSConfig, does
+// not change the client.
+func (c *Client) Options() Options {
+	return c.options.clone()
+}
+
 // Status returns the current client status.
 func (c *Client) Status() core.Status {
 	c.statusMu.RLock()
@@ -207,37 +649,269 @@ func (c *Client) Status() core.Status {
 	return c.status
 }
 
-// IsConnected returns whether the client is currently connected.
+// IsConnected returns whether the client is currently connected, i.e. at
+// least one pooled connection is open.
 func (c *Client) IsConnected() bool {
+	return c.openConns() > 0
+}
+
+// RemoteAddr returns the address of the server for the most recent connection,
+// or nil if the client has never connected.
+func (c *Client) RemoteAddr() net.Addr {
 	c.connMu.RLock()
 	defer c.connMu.RUnlock()
-	return c.isConnected
+	return c.remoteAddr
 }
 
-// OnStatusChange registers a callback for status changes.
-func (c *Client) OnStatusChange(callback func(core.StatusChangeEvent)) {
-	c.callbacks = append(c.callbacks, callback)
+// remoteAddrString returns the server address for log records, or "" when never connected.
+func (c *Client) remoteAddrString() string {
+	if addr := c.RemoteAddr(); addr != nil {
+		return addr.String()
+	}
+	return ""
 }
 
-// ProcessModel sends a model processing request to the server.
-func (c *Client) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+// ConnectionState returns details of the most recent connection to the
+// server, and whether the client is connected now.
+func (c *Client) ConnectionState() ConnectionState {
 	c.connMu.RLock()
-	conn := c.conn
-	c.connMu.RUnlock()
+	defer c.connMu.RUnlock()
+	return ConnectionState{
+		RemoteAddr:       c.remoteAddr,
+		TLS:              c.tlsState,
+		Compression:      c.frames.Compression,
+		Codec:            c.codecType(),
+		Connected:        c.openLocked() > 0,
+		Since:            c.connSince,
+		ReconnectAttempt: c.reconnecting,
+		LastError:        c.lastConnErr,
+	}
+}
 
-	if conn == nil {
-		return nil, errors.New("not connected to server")
+// codecType returns the content type of the negotiated codec, or empty for
+// JSON. connMu must be held.
+func (c *Client) codecType() string {
+	if c.frames.Codec == nil {
+		return ""
+	}
+	return c.frames.Codec.ContentType()
+}
+
+// Principal returns the principal the server authenticated the client as,
+// or nil if the client has not authenticated.
+func (c *Client) Principal() *core.Principal {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.principal
+}
+
+// Stats returns a snapshot of the client's connection counters and background tasks.
+func (c *Client) Stats() Stats {
+	return Stats{
+		Connections:         atomic.LoadUint64(&c.stats.Connections),
+		OpenConnections:     c.openConns(),
+		ResumedHandshakes:   atomic.LoadUint64(&c.stats.ResumedHandshakes),
+		Tasks:               c.tasks.Counts(),
+		ObservabilityErrors: c.sinks.Errors(),
+		Link:                c.link.stats(),
+		Tuning:              c.tuner.stats(),
+		ResponseCache:       c.cache.stats(),
+		DuplicatesDropped:   atomic.LoadUint64(&c.stats.DuplicatesDropped),
+	}
+}
+
+// Health reports whether the metrics collector is accepting measurements. A
+// collector that panics is reported as degraded instead of failing calls.
+func (c *Client) Health() core.Health {
+	return c.sinks.Health()
+}
+
+// Ping asks the server for its health. It returns the server's answer and an
+// error if the server could not be reached or reports it is not running.
+func (c *Client) Ping(ctx context.Context) (*core.HealthResponse, error) {
+	var health core.HealthResponse
+	if err := c.call(ctx, core.MethodHealth, nil, &health); err != nil {
+		return nil, err
+	}
+	if health.Status != core.StatusRunning {
+		return &health, fmt.Errorf("server is %s", health.Status)
+	}
+	return &health, nil
+}
+
+// OnError registers a callback invoked when the metrics collector starts
+// failing and again when it recovers, rather than for every failed call.
+func (c *Client) OnError(callback func(core.SinkEvent)) {
+	c.sinks.OnChange(callback)
+}
+
+// OnStatusChange registers a callback for status changes and returns a
+// function that removes it. Callbacks run one at a time, in registration
+// order, and see changes in the order they happened.
+func (c *Client) OnStatusChange(callback func(core.StatusChangeEvent)) (cancel func()) {
+	return c.statusEvents.Subscribe(callback)
+}
+
+// ProcessModel sends a model processing request to the server. When ctx has
+// a deadline, the time left is sent as core.MetadataTimeout so the server
+// stops the handler once the call gives up. With WithRetry, a request whose
+// connection fails is sent again under the same core.MetadataIdempotencyKey,
+// and the call still resolves once, however many attempts are answered.
+func (c *Client) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	requestID := ""
+	if req != nil {
+		requestID = req.ID
+	}
+	ctx, cancel := c.withRequestTimeout(ctx, core.MethodProcessModel)
+	defer cancel()
+	ctx, endSpan := c.options.Tracer.StartSpan(ctx, core.SpanClient, core.MethodProcessModel, requestID)
+
+	req, err := c.hooks.beforeSend(ctx, withTimeout(ctx, c.withMetadata(ctx, c.withIdempotencyKey(req))))
+	if err != nil {
+		endSpan(err)
+		return nil, err
+	}
+	roundTrip := c.retrying(core.MethodProcessModel, c.roundTrip(core.MethodProcessModel))
+	resp, err := c.intercepted(core.MethodProcessModel, c.cache.wrap(core.MethodProcessModel, roundTrip))(ctx, req)
+	if err != nil {
+		endSpan(err)
+		return nil, err
+	}
+	if err := c.hooks.afterReceive(ctx, resp); err != nil {
+		endSpan(err)
+		return nil, err
+	}
+
+	endSpan(resp.Err())
+	return resp, nil
+}
+
+// ProcessModelRaw sends an mcp.processModel request whose model data, raw, is
+// already encoded as JSON, and returns the results as the server encoded
+// them, so that neither end decodes a payload it only passes on. A server's
+// server.RawModelHandler gets raw as sent, compacted; other handlers decode
+// it, and need it to be an object. A failed response is returned as an
+// error. Metadata is filled in as for ProcessModel, but interceptors, hooks
+// and the response cache only see ProcessModel.
+func (c *Client) ProcessModelRaw(ctx context.Context, id string, raw json.RawMessage, params []core.Parameter) (json.RawMessage, error) {
+	ctx, cancel := c.withRequestTimeout(ctx, core.MethodProcessModel)
+	defer cancel()
+	ctx, endSpan := c.options.Tracer.StartSpan(ctx, core.SpanClient, core.MethodProcessModel, id)
+
+	req := &core.RawModelRequest{ID: id, ModelData: raw, Parameters: params}
+	req.Metadata = withTimeout(ctx, c.withMetadata(ctx, &core.ModelRequest{ID: id})).Metadata
+	var resp core.RawModelResponse
+	err := c.call(ctx, core.MethodProcessModel, req, &resp)
+	if err == nil {
+		err = resp.Err()
+	}
+	endSpan(err)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// withMetadata returns req with the metadata of ctx and the configured default
+// metadata filled in. Keys already set on req win, then those from ctx. The
+// caller's request is not modified.
+func (c *Client) withMetadata(ctx context.Context, req *core.ModelRequest) *core.ModelRequest {
+	fromCtx := core.MetadataFromContext(ctx)
+	if req == nil || (len(fromCtx) == 0 && len(c.options.DefaultMetadata) == 0) {
+		return req
+	}
+
+	md := make(map[string]string, len(req.Metadata)+len(fromCtx)+len(c.options.DefaultMetadata))
+	for _, source := range []map[string]string{c.options.DefaultMetadata, fromCtx, req.Metadata} {
+		for key, value := range source {
+			md[key] = value
+		}
+	}
+
+	out := *req
+	out.Metadata = md
+	return &out
+}
+
+// withTimeout returns req with the time left before the deadline of ctx, if
+// it has one, set as its core.MetadataTimeout, so the server stops the
+// handler when the caller gives up. The deadline replaces any timeout
+// already in the metadata, which may be stale. The caller's request is not
+// modified.
+func withTimeout(ctx context.Context, req *core.ModelRequest) *core.ModelRequest {
+	deadline, ok := ctx.Deadline()
+	if req == nil || !ok {
+		return req
+	}
+
+	md := make(map[string]string, len(req.Metadata)+1)
+	for key, value := range req.Metadata {
+		md[key] = value
+	}
+	md[core.MetadataTimeout] = core.FormatTimeout(time.Until(deadline))
+
+	out := *req
+	out.Metadata = md
+	return &out
+}
+
+// ProcessBatch sends several model requests in a single mcp.processModelBatch call.
+// When the batch has no Timeout of its own, the deadline of ctx is passed on so the
+// server can divide it among the items according to the batch's DeadlineStrategy.
+func (c *Client) ProcessBatch(ctx context.Context, batch *core.BatchRequest) (*core.BatchResponse, error) {
+	if err := c.requireFeature(core.FeatureBatch); err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.withRequestTimeout(ctx, core.MethodProcessModelBatch)
+	defer cancel()
+	ctx, endSpan := c.options.Tracer.StartSpan(ctx, core.SpanClient, core.MethodProcessModelBatch, "")
+
+	params := *batch
+	if deadline, ok := ctx.Deadline(); ok && params.Timeout == 0 {
+		params.Timeout = time.Until(deadline)
+	}
+	params.Requests = make([]*core.ModelRequest, len(batch.Requests))
+	for i, req := range batch.Requests {
+		req, err := c.hooks.beforeSend(ctx, c.withMetadata(ctx, req))
+		if err != nil {
+			endSpan(err)
+			return nil, err
+		}
+		params.Requests[i] = req
 	}
 
-	var resp core.ModelResponse
-	err := conn.Call(ctx, "mcp.processModel", req, &resp)
+	var resp core.BatchResponse
+	err := c.call(ctx, core.MethodProcessModelBatch, &params, &resp)
+	if err == nil {
+		for _, item := range resp.Responses {
+			if err = c.hooks.afterReceive(ctx, item); err != nil {
+				break
+			}
+		}
+	}
+	endSpan(err)
 	if err != nil {
-		return nil, fmt.Errorf("RPC error: %w", err)
+		return nil, err
 	}
 
 	return &resp, nil
 }
 
+// ProcessModelBatch processes several model requests in a single round trip
+// and returns their responses in request order. An item the handler fails is
+// returned as an unsuccessful response rather than failing the whole batch;
+// the error reports only failures of the call itself.
+func (c *Client) ProcessModelBatch(ctx context.Context, reqs []*core.ModelRequest) ([]*core.ModelResponse, error) {
+	resp, err := c.ProcessBatch(ctx, &core.BatchRequest{Requests: reqs})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) != len(reqs) {
+		return nil, fmt.Errorf("batch returned %d responses for %d requests", len(resp.Responses), len(reqs))
+	}
+	return resp.Responses, nil
+}
+
 func (c *Client) updateStatus(newStatus core.Status, err error) {
 	c.statusMu.Lock()
 	defer c.statusMu.Unlock()
@@ -253,12 +927,10 @@ func (c *Client) updateStatusLocked(newStatus core.Status, err error) {
 		NewStatus: newStatus,
 		Timestamp: time.Now(),
 		Error:     err,
+		Source:    core.StatusSource{Kind: core.ComponentClient, Name: c.options.Name},
 	}
 
-	// Notify callbacks
-	for _, callback := range c.callbacks {
-		go callback(event)
-	}
+	c.statusEvents.Publish(event)
 }
 
 // rpcHandler implements jsonrpc2.Handler for the client.
@@ -268,9 +940,44 @@ type rpcHandler struct {
 
 // Handle handles JSON-RPC requests from the server.
 func (h *rpcHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	// Handle notifications or requests from the server
-	// In this simplified example, we just log them
-	log.Printf("Received request from server: %s", req.Method)
+	// Requests from the server are only logged; notifications are dispatched
+	// to the handlers registered for them
+	h.client.options.Logger.Debug("Received request from server", core.LogFieldMethod, req.Method, core.LogFieldRequestID, req.ID.String())
+
+	if !req.Notif {
+		return
+	}
 
-	// We could dispatch to registered handlers here, similar to the server
+	// Chunks are delivered here, in order, before the reply they precede
+	if req.Method == core.NotifyModelChunk {
+		h.client.deliverChunk(req.Params)
+		return
+	}
+
+	// Job progress reaches the watches of the job as well as any handlers
+	if req.Method == core.NotifyJobProgress {
+		h.client.deliverJobProgress(req.Params)
+	}
+
+	// Resource changes reach the callbacks of SubscribeResource too
+	if req.Method == core.NotifyResourceChanged {
+		h.client.deliverResourceChange(req.Params)
+	}
+
+	// Cached method descriptions are stale once the server announces a change
+	if req.Method == core.MethodsChanged.Method() {
+		h.client.invalidateSchemas()
+	}
+
+	// Notifications replayed for a durable subscription say so in their meta
+	if req.Meta != nil {
+		var meta core.NotificationMeta
+		if err := json.Unmarshal(*req.Meta, &meta); err == nil {
+			ctx = core.ContextWithNotificationMeta(ctx, meta)
+		}
+	}
+
+	if err := h.client.notifications.Dispatch(ctx, req.Method, req.Params); err != nil {
+		h.client.options.Logger.Warn("Invalid notification from server", core.LogFieldMethod, req.Method, core.LogFieldError, err)
+	}
 }
@@ -1,11 +1,22 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
 	"github.com/narcolepticfox/mcp/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,10 +30,18 @@ func TestClientLifecycle(t *testing.T) {
 	assert.Equal(t, core.StatusStopped, client.Status(), "Client should start in idle state")
 
 	// Register for status change events
+	var eventsMu sync.Mutex
 	var statusEvents []core.StatusChangeEvent
 	client.OnStatusChange(func(event core.StatusChangeEvent) {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
 		statusEvents = append(statusEvents, event)
 	})
+	eventCount := func() int {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		return len(statusEvents)
+	}
 
 	// Start the client (this should fail since there's no server running)
 	err := client.Start() // lint:ignore ineffassign this error is used in the following assertion
@@ -32,7 +51,73 @@ func TestClientLifecycle(t *testing.T) {
 	assert.Equal(t, core.StatusFailed, client.Status(), "Client should be in failed state after failed start")
 
 	// Check that at least one status event was recorded
-	assert.NotEmpty(t, statusEvents, "At least one status event should have been emitted")
+	assert.Eventually(t, func() bool { return eventCount() >= 1 }, time.Second, 10*time.Millisecond, "At least one status event should have been emitted")
+}
+
+func TestClientRestartAfterFailedStart(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	client := New(WithTransport(transport), WithAutoReconnect(false))
+
+	// With no server listening the client fails to start
+	require.Error(t, client.Start(), "Start should fail when server is not available")
+	require.Equal(t, core.StatusFailed, client.Status(), "Client should be in failed state after failed start")
+	assert.Error(t, client.Start(), "Failed client should not start until stopped")
+
+	// A failed client can be stopped, and stopping it again does nothing
+	require.NoError(t, client.Stop(), "Failed client should stop")
+	assert.Equal(t, core.StatusStopped, client.Status(), "Client should be stopped")
+	assert.NoError(t, client.Stop(), "Stopping a stopped client should succeed")
+
+	// Once the server is up the same client starts and serves calls
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	require.NoError(t, client.Start(), "Stopped client should start again")
+	defer client.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Restarted client should serve calls")
+}
+
+func TestClientRestart(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	client := New(WithTransport(transport), WithAutoReconnect(false))
+	var eventsMu sync.Mutex
+	var statuses []core.Status
+	client.OnStatusChange(func(event core.StatusChangeEvent) {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		statuses = append(statuses, event.NewStatus)
+	})
+
+	// Each run connects afresh and serves calls
+	for run := 1; run <= 2; run++ {
+		require.NoError(t, client.Start(), "Run %d should start", run)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		cancel()
+		assert.NoError(t, err, "Run %d should serve calls", run)
+		require.NoError(t, client.Stop(), "Run %d should stop", run)
+		assert.False(t, client.IsConnected(), "Stopped client should have no connection")
+	}
+
+	want := []core.Status{
+		core.StatusStarting, core.StatusRunning, core.StatusStopping, core.StatusStopped,
+		core.StatusStarting, core.StatusRunning, core.StatusStopping, core.StatusStopped,
+	}
+	assert.Eventually(t, func() bool {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		return assert.ObjectsAreEqual(want, statuses)
+	}, time.Second, 10*time.Millisecond, "Each run should report starting, running, stopping and stopped in order")
 }
 
 func TestClientWithMockServer(t *testing.T) {
@@ -74,6 +159,7 @@ func TestClientWithMockServer(t *testing.T) {
 	assert.True(t, testutil.WaitForCondition(2*time.Second, 100*time.Millisecond, func() bool {
 		return client.Status() == core.StatusRunning
 	}), "Client should enter running state")
+	assert.Nil(t, client.ServerCapabilities(), "A server without the handshake should leave capabilities unknown")
 
 	// Process a model request
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
@@ -93,12 +179,13 @@ func TestClientWithMockServer(t *testing.T) {
 }
 
 func TestClientReconnect(t *testing.T) {
-	// Only run this test if reconnect feature is implemented
-	t.Skip("Reconnect test requires implementation of auto-reconnect feature")
-
 	// Create a mock server
 	mockServer, err := testutil.NewMockServer(t)
 	require.NoError(t, err, "Failed to start mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
 
 	// Create a client with auto-reconnect
 	client := New(
@@ -106,38 +193,53 @@ func TestClientReconnect(t *testing.T) {
 		WithServerPort(mockServer.Port()),
 		WithConnectionTimeout(2*time.Second),
 		WithAutoReconnect(true),
-		WithMaxReconnectAttempts(5),
-		WithReconnectDelay(500*time.Millisecond),
+		WithMaxReconnectAttempts(50),
+		WithReconnectDelay(50*time.Millisecond),
+		WithLogger(core.NopLogger()),
 	)
 
 	// Start the client
 	err = client.Start()
-	assert.NoError(t, err, "Client should start successfully")
-	err = client.Stop()
-	assert.NoError(t, err, "Client should stop successfully")
-
-	// Wait for the client to connect
-	assert.True(t, testutil.WaitForCondition(2*time.Second, 100*time.Millisecond, func() bool {
-		return client.Status() == core.StatusRunning
-	}), "Client should enter running state")
+	require.NoError(t, err, "Client should start successfully")
+	defer client.Stop()
+	assert.Equal(t, core.StatusRunning, client.Status(), "Client should enter running state")
+	assert.True(t, client.IsConnected(), "Client should be connected")
 
 	// Stop the server to simulate disconnection
 	err = mockServer.Stop()
 	require.NoError(t, err, "Failed to stop mock server")
-
-	// Wait a bit for the client to detect disconnection
-	time.Sleep(100 * time.Millisecond)
+	assert.True(t, testutil.WaitForCondition(2*time.Second, 10*time.Millisecond, func() bool {
+		return !client.IsConnected()
+	}), "Client should notice the server is gone")
+	assert.Equal(t, core.StatusRunning, client.Status(), "Client should keep running while it reconnects")
 
 	// Restart the server
 	err = mockServer.Start()
 	require.NoError(t, err, "Failed to restart mock server")
-	err = mockServer.Stop()
-	require.NoError(t, err, "Failed to stop mock server")
 
 	// Client should auto-reconnect
-	assert.True(t, testutil.WaitForCondition(5*time.Second, 500*time.Millisecond, func() bool {
-		return client.Status() == core.StatusRunning
-	}), "Client should reconnect and return to running state")
+	assert.True(t, testutil.WaitForCondition(5*time.Second, 10*time.Millisecond, func() bool {
+		return client.IsConnected()
+	}), "Client should reconnect to the restarted server")
+	assert.Equal(t, core.StatusRunning, client.Status(), "Client should still be running")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "ProcessModel should succeed after reconnecting")
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	fixed := New(WithReconnectDelay(100 * time.Millisecond))
+	assert.Equal(t, 100*time.Millisecond, fixed.reconnectDelay(5), "Without backoff every attempt should wait the reconnect delay")
+
+	backoff := New(WithReconnectDelay(100*time.Millisecond), WithReconnectBackoff(time.Second))
+	var delays []time.Duration
+	for attempt := 1; attempt <= 6; attempt++ {
+		delays = append(delays, backoff.reconnectDelay(attempt))
+	}
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second},
+		delays, "Delays should double from the reconnect delay up to the maximum")
 }
 
 func TestClientContextCancellation(t *testing.T) {
@@ -159,16 +261,7 @@ func TestClientContextCancellation(t *testing.T) {
 
 	// Configure mock server to delay response
 	testReq := testutil.CreateTestModelRequest()
-	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
-		// Check if context is canceled
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(2 * time.Second):
-			// This should not happen due to context cancellation
-			return core.NewModelResponse(req), nil
-		}
-	})
+	mockServer.SetLatency(2 * time.Second)
 
 	// Create a context with immediate cancellation
 	ctx, cancel := context.WithCancel(context.Background())
@@ -185,3 +278,866 @@ func TestClientContextCancellation(t *testing.T) {
 	err = mockServer.Stop()
 	require.NoError(t, err, "Failed to stop mock server")
 }
+
+// startMockClient starts a client of mockServer that reconnects quickly.
+func startMockClient(t *testing.T, mockServer *testutil.MockServer, options ...Option) *Client {
+	client := New(append([]Option{
+		WithServerHost("localhost"),
+		WithServerPort(mockServer.Port()),
+		WithReconnectDelay(20 * time.Millisecond),
+		WithMaxReconnectAttempts(20),
+		WithLogger(core.NopLogger()),
+	}, options...)...)
+	require.NoError(t, client.Start(), "Client should start successfully")
+	t.Cleanup(func() { client.Stop() })
+	return client
+}
+
+func TestClientCallTimeout(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	client := startMockClient(t, mockServer)
+
+	// A server slower than the deadline fails the call
+	mockServer.SetLatency(500 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "A call outlasting its deadline should time out")
+
+	// The connection stays usable once the server speeds up
+	mockServer.SetLatency(0)
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "A call within its deadline should succeed")
+	assert.Len(t, mockServer.RecordedRequests(), 2, "Both calls should reach the server")
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	client := startMockClient(t, mockServer, WithRequestTimeout(100*time.Millisecond))
+
+	// A stalled server fails a call made without a deadline within the timeout
+	mockServer.SetLatency(time.Second)
+	start := time.Now()
+	_, err = client.ProcessModel(context.Background(), testutil.CreateTestModelRequest())
+	elapsed := time.Since(start)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "A call outlasting the request timeout should time out")
+	var timeoutErr *RequestTimeoutError
+	require.ErrorAs(t, err, &timeoutErr, "The error should say the request timeout passed")
+	assert.Equal(t, core.MethodProcessModel, timeoutErr.Method, "The error should name the method")
+	assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout, "The error should give the timeout")
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond, "The call should wait for the request timeout")
+	assert.Less(t, elapsed, 500*time.Millisecond, "The call should not wait for the stalled server")
+
+	recorded := mockServer.RecordedRequests()
+	require.NotEmpty(t, recorded, "The call should reach the server")
+	_, ok := core.TimeoutFromMetadata(recorded[0].Metadata)
+	assert.True(t, ok, "The request timeout should be sent to the server")
+
+	// A deadline on the context wins over the request timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "A call outlasting its deadline should time out")
+	assert.False(t, errors.As(err, &timeoutErr), "The caller's own deadline should not be reported as the request timeout")
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond, "The call should wait for its own deadline")
+}
+
+func TestClientRequestTimeoutCoversEveryCall(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterTool("wait", "", nil, func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}), "Tool registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+	client := New(WithTransport(transport), WithAutoReconnect(false), WithRequestTimeout(100*time.Millisecond))
+	require.NoError(t, client.Start(), "Client should start")
+	defer client.Stop()
+
+	start := time.Now()
+	err := client.CallTool(context.Background(), "wait", nil, nil)
+	var timeoutErr *RequestTimeoutError
+	require.ErrorAs(t, err, &timeoutErr, "A tool call outlasting the request timeout should time out")
+	assert.Equal(t, core.MethodCallTool, timeoutErr.Method, "The error should name the method")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "The call should not wait for the tool")
+
+	_, err = client.ListTools(context.Background())
+	assert.NoError(t, err, "Calls answered in time should succeed")
+}
+
+// pausingStreamHandler waits before streaming count chunks, pausing between
+// them.
+type pausingStreamHandler struct {
+	wait  time.Duration
+	count int
+	pause time.Duration
+}
+
+func (h *pausingStreamHandler) Methods() []string {
+	return []string{core.MethodProcessModelStream}
+}
+
+func (h *pausingStreamHandler) ProcessModelStream(ctx context.Context, req *core.ModelRequest, emit func(core.ModelChunk) error) (*core.ModelResponse, error) {
+	time.Sleep(h.wait)
+	for i := 0; i < h.count; i++ {
+		if err := emit(core.ModelChunk{Data: map[string]interface{}{"index": i}}); err != nil {
+			return nil, err
+		}
+		time.Sleep(h.pause)
+	}
+	return core.NewModelResponse(req), nil
+}
+
+func TestClientRequestTimeoutBoundsStreamHandshake(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	handler := &pausingStreamHandler{count: 6, pause: 50 * time.Millisecond}
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+	client := New(WithTransport(transport), WithAutoReconnect(false), WithRequestTimeout(100*time.Millisecond))
+	require.NoError(t, client.Start(), "Client should start")
+	defer client.Stop()
+
+	// A stream that starts in time may run past the request timeout
+	chunks := 0
+	_, err := client.ProcessModelStream(context.Background(), testutil.CreateTestModelRequest(), func(core.ModelChunk) {
+		chunks++
+	})
+	require.NoError(t, err, "A stream that started within the request timeout should finish")
+	assert.Equal(t, 6, chunks, "Every chunk should be delivered")
+
+	// One whose first chunk does not arrive in time fails
+	handler.wait = 300 * time.Millisecond
+	_, err = client.ProcessModelStream(context.Background(), testutil.CreateTestModelRequest(), func(core.ModelChunk) {})
+	var timeoutErr *RequestTimeoutError
+	require.ErrorAs(t, err, &timeoutErr, "A stream that does not start within the request timeout should time out")
+	assert.Equal(t, core.MethodProcessModelStream, timeoutErr.Method, "The error should name the method")
+}
+
+func TestClientDroppedCall(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	client := startMockClient(t, mockServer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The call in flight when the connection drops fails
+	mockServer.DropNextConnection()
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.Error(t, err, "A call whose connection drops should fail")
+
+	// Auto-reconnect brings the client back for the next call
+	require.Eventually(t, client.IsConnected, 2*time.Second, 10*time.Millisecond, "Client should reconnect")
+	resp, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "A call after reconnecting should succeed")
+	assert.True(t, resp.Success, "The response should come from the handler")
+	assert.Len(t, mockServer.RecordedRequests(), 2, "Both calls should reach the server")
+}
+
+func TestClientRetriedCall(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	client := startMockClient(t, mockServer, WithRetry(10, 20*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The call in flight when the connection drops is sent again once the
+	// client reconnects, under the same idempotency key
+	mockServer.DropNextConnection()
+	req := testutil.CreateTestModelRequest()
+	resp, err := client.ProcessModel(ctx, req)
+	require.NoError(t, err, "A retried call should succeed")
+	assert.True(t, resp.Success, "The response should come from the handler")
+	assert.Empty(t, req.Metadata[core.MetadataIdempotencyKey], "The caller's request should not be changed")
+
+	recorded := mockServer.RecordedRequests()
+	require.Len(t, recorded, 2, "The call should reach the server twice")
+	key := recorded[0].Metadata[core.MetadataIdempotencyKey]
+	assert.NotEmpty(t, key, "The call should carry an idempotency key")
+	assert.Equal(t, key, recorded[1].Metadata[core.MetadataIdempotencyKey], "The retry should carry the same idempotency key")
+
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Another call should succeed")
+	assert.NotEqual(t, key, mockServer.RecordedRequests()[2].Metadata[core.MetadataIdempotencyKey], "Each call should get its own idempotency key")
+}
+
+// gatedAttempts sets mockServer to answer the nth model request only once
+// gates[n] is closed, with the attempt number as its "attempt" result, and
+// to report each request on arrived as it comes in. The gates are closed
+// when the test ends.
+func gatedAttempts(t *testing.T, mockServer *testutil.MockServer, gates ...chan struct{}) (arrived <-chan int) {
+	var attempts atomic.Int32
+	arrivals := make(chan int, len(gates))
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		attempt := int(attempts.Add(1))
+		arrivals <- attempt
+		<-gates[attempt-1]
+		resp := core.NewModelResponse(req)
+		resp.Results["attempt"] = strconv.Itoa(attempt)
+		return resp, nil
+	})
+	t.Cleanup(func() {
+		for _, gate := range gates {
+			select {
+			case <-gate:
+			default:
+				close(gate)
+			}
+		}
+	})
+	return arrivals
+}
+
+func TestClientRetryResolvesOnce(t *testing.T) {
+	t.Run("late reply to an outrun attempt", func(t *testing.T) {
+		mockServer, err := testutil.NewMockServer(t)
+		require.NoError(t, err, "Failed to create mock server")
+		defer mockServer.Close()
+		first, second := make(chan struct{}), make(chan struct{})
+		arrived := gatedAttempts(t, mockServer, first, second)
+		close(second)
+		client := startMockClient(t, mockServer, WithConnectionPoolSize(2), WithRetry(1, 0), WithRetryAttemptTimeout(50*time.Millisecond))
+		var received atomic.Int32
+		client.OnAfterReceive(func(context.Context, *core.ModelResponse) error {
+			received.Add(1)
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// The first attempt is held past the attempt timeout, so the retry
+		// answers the call while it is still running
+		resp, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "The retry should answer the call")
+		assert.Equal(t, "2", resp.Results["attempt"], "The call should resolve with the retry's reply")
+		assert.Equal(t, 1, <-arrived, "The first attempt should reach the server")
+		assert.Equal(t, 2, <-arrived, "The retry should reach the server")
+		assert.Zero(t, client.Stats().DuplicatesDropped, "Nothing should be dropped before the late reply")
+
+		close(first)
+		require.Eventually(t, func() bool { return client.Stats().DuplicatesDropped == 1 }, 2*time.Second, 10*time.Millisecond,
+			"The late reply to the first attempt should be dropped and counted")
+		assert.Equal(t, int32(1), received.Load(), "The call should resolve exactly once")
+		recorded := mockServer.RecordedRequests()
+		require.Len(t, recorded, 2, "The call should reach the server twice")
+		assert.Equal(t, recorded[0].Metadata[core.MetadataIdempotencyKey], recorded[1].Metadata[core.MetadataIdempotencyKey],
+			"The attempts should carry the same idempotency key")
+	})
+
+	t.Run("late reply overtaking the retry", func(t *testing.T) {
+		mockServer, err := testutil.NewMockServer(t)
+		require.NoError(t, err, "Failed to create mock server")
+		defer mockServer.Close()
+		first, second := make(chan struct{}), make(chan struct{})
+		arrived := gatedAttempts(t, mockServer, first, second)
+		client := startMockClient(t, mockServer, WithConnectionPoolSize(2), WithRetry(1, 0), WithRetryAttemptTimeout(50*time.Millisecond))
+		var received atomic.Int32
+		client.OnAfterReceive(func(context.Context, *core.ModelResponse) error {
+			received.Add(1)
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// The first attempt answers once the retry is sent, before the retry
+		// does
+		go func() {
+			<-arrived
+			<-arrived
+			close(first)
+		}()
+		resp, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "The first attempt should answer the call")
+		assert.Equal(t, "1", resp.Results["attempt"], "The call should resolve with the first reply to arrive")
+
+		close(second)
+		require.Eventually(t, func() bool { return client.Stats().DuplicatesDropped == 1 }, 2*time.Second, 10*time.Millisecond,
+			"The retry's reply should be dropped and counted")
+		assert.Equal(t, int32(1), received.Load(), "The call should resolve exactly once")
+	})
+}
+
+func TestClientScriptedFailures(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	client := startMockClient(t, mockServer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Scripted answers come first, in order
+	mockServer.EnqueueResponse(nil, core.NewModelError(core.ErrInvalidModel, errors.New("bad model")))
+	mockServer.EnqueueResponse(&core.ModelResponse{ID: "scripted", Success: true}, nil)
+
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var modelErr *core.ModelError
+	require.ErrorAs(t, err, &modelErr, "A scripted model error should reach the client as one")
+	assert.Equal(t, core.ErrInvalidModel, modelErr.Code, "The model error should keep its code")
+
+	resp, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "A scripted response should be returned")
+	assert.Equal(t, "scripted", resp.ID, "The scripted response should be returned as is")
+
+	req := testutil.CreateTestModelRequest()
+	resp, err = client.ProcessModel(ctx, req)
+	require.NoError(t, err, "The handler should answer once the script runs out")
+	assert.Equal(t, req.ID, resp.ID, "The handler should answer the request")
+
+	// Injected faults fail every call while the rate is one
+	mockServer.SetFailureRate(1)
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.ErrorContains(t, err, testutil.ErrMockFailure.Error(), "An injected failure should fail the call")
+	mockServer.SetFailureRate(0)
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Calls should succeed once faults stop")
+	assert.True(t, client.IsConnected(), "Failed calls should leave the connection open")
+}
+
+// bulkWriteTransport dials TCP and reports on started when a connection is
+// first given a write of at least bulk bytes, so timings can begin once a
+// large request has been encoded and is being sent.
+type bulkWriteTransport struct {
+	core.TCPTransport
+	bulk    int
+	started chan time.Time
+}
+
+func (t *bulkWriteTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	conn, err := t.TCPTransport.Dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return &bulkWriteConn{Conn: conn, transport: t}, nil
+}
+
+type bulkWriteConn struct {
+	net.Conn
+	transport *bulkWriteTransport
+}
+
+func (c *bulkWriteConn) Write(p []byte) (int, error) {
+	if len(p) >= c.transport.bulk {
+		select {
+		case c.transport.started <- time.Now():
+		default:
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+func TestClientWriteTimeout(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	transport := &bulkWriteTransport{bulk: 1 << 20, started: make(chan time.Time, 1)}
+	client := startMockClient(t, mockServer,
+		WithTransport(transport),
+		WithAutoReconnect(false),
+		WithWriteTimeout(200*time.Millisecond),
+		WithHeartbeatInterval(20*time.Millisecond),
+		WithHeartbeatTimeout(time.Minute),
+	)
+
+	// A stalled ping blocks the server's read loop, so it stops taking
+	// anything the client writes
+	mockServer.SetStalled(true)
+	defer mockServer.SetStalled(false)
+	time.Sleep(100 * time.Millisecond)
+
+	// A request larger than the socket buffers cannot be written in full
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["blob"] = strings.Repeat("x", 16<<20)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = client.ProcessModel(ctx, req)
+	failed := time.Now()
+	assert.Error(t, err, "A request the server never takes should fail")
+	assert.NotErrorIs(t, err, context.DeadlineExceeded, "The write timeout, not the call's deadline, should end the call")
+	// Timed from the start of the write, as encoding the request takes
+	// longer than the timeout itself under the race detector
+	select {
+	case started := <-transport.started:
+		assert.Less(t, failed.Sub(started), time.Second, "The call should fail within the write timeout window")
+	default:
+		t.Error("The request should have been written to the connection")
+	}
+	assert.True(t, testutil.WaitForCondition(time.Second, 10*time.Millisecond, func() bool {
+		return !client.IsConnected()
+	}), "The client should drop the connection whose write timed out")
+}
+
+func TestClientReadTimeout(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+
+	// Heartbeats answered by the server keep a quiet connection open
+	client := startMockClient(t, mockServer,
+		WithAutoReconnect(false),
+		WithReadTimeout(200*time.Millisecond),
+		WithHeartbeatInterval(20*time.Millisecond),
+		WithHeartbeatTimeout(time.Minute),
+	)
+	time.Sleep(500 * time.Millisecond)
+	assert.True(t, client.IsConnected(), "A server answering heartbeats should stay connected")
+
+	// A stalled ping blocks the server, so nothing more is read from it
+	mockServer.SetStalled(true)
+	defer mockServer.SetStalled(false)
+	assert.True(t, testutil.WaitForCondition(2*time.Second, 10*time.Millisecond, func() bool {
+		return !client.IsConnected()
+	}), "The client should drop a connection the server sends nothing on")
+}
+
+func TestClientHeartbeatReconnect(t *testing.T) {
+	// Create a mock server
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+
+	// Create a client that pings aggressively and reconnects quickly
+	client := New(
+		WithServerHost("localhost"),
+		WithServerPort(mockServer.Port()),
+		WithConnectionTimeout(2*time.Second),
+		WithHeartbeatInterval(50*time.Millisecond),
+		WithHeartbeatTimeout(50*time.Millisecond),
+		WithMaxMissedHeartbeats(2),
+		WithReconnectDelay(300*time.Millisecond),
+	)
+
+	err = client.Start()
+	require.NoError(t, err, "Client should start successfully")
+
+	// A healthy server keeps the connection up across several heartbeats
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, client.IsConnected(), "Client should stay connected while pings are answered")
+
+	// Stop answering pings to simulate a half-open connection
+	mockServer.SetStalled(true)
+	assert.True(t, testutil.WaitForCondition(2*time.Second, 10*time.Millisecond, func() bool {
+		return !client.IsConnected()
+	}), "Client should drop the connection after missed heartbeats")
+
+	// Let the server respond again and wait for the reconnect
+	mockServer.SetStalled(false)
+	assert.True(t, testutil.WaitForCondition(2*time.Second, 10*time.Millisecond, func() bool {
+		return client.IsConnected()
+	}), "Client should reconnect once the server responds")
+
+	// The new connection must be usable
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "ProcessModel should succeed after reconnecting")
+	assert.NotNil(t, resp, "Response should not be nil")
+
+	err = client.Stop()
+	assert.NoError(t, err, "Client should stop successfully")
+}
+
+// startTLSServer starts a TLS server with the default handler and returns its port
+// along with a client TLS configuration trusting its certificate
+func startTLSServer(t *testing.T, options ...server.Option) (int, *tls.Config) {
+	certPath, keyPath, pool := testutil.GenerateTestCertificate(t)
+	port, err := testutil.GetFreePort()
+	require.NoError(t, err, "Failed to get free port")
+
+	srv := server.New(append([]server.Option{server.WithPort(port), server.WithTLSFiles(certPath, keyPath)}, options...)...)
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+
+	return port, &tls.Config{RootCAs: pool}
+}
+
+// reconnectOverTLS makes a round trip, drops the connection and waits for the client to reconnect
+func reconnectOverTLS(t *testing.T, client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// A round trip ensures the session ticket sent after the handshake has been read
+	_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed over TLS")
+
+	client.connMu.RLock()
+	client.conns[0].conn.Close()
+	client.connMu.RUnlock()
+
+	require.True(t, testutil.WaitForCondition(2*time.Second, 10*time.Millisecond, func() bool {
+		return client.Stats().Connections == 2 && client.IsConnected()
+	}), "Client should reconnect after the connection drops")
+
+	_, err = client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed after reconnecting")
+}
+
+func TestClientTLSSessionResumption(t *testing.T) {
+	port, tlsConfig := startTLSServer(t)
+
+	client := New(
+		WithServerHost("localhost"),
+		WithServerPort(port),
+		WithTLSConfig(tlsConfig),
+		WithReconnectDelay(10*time.Millisecond),
+	)
+	require.NoError(t, client.Start(), "Client should connect over TLS")
+	defer client.Stop()
+
+	state := client.ConnectionState()
+	require.NotNil(t, state.TLS, "ConnectionState should carry TLS details")
+	assert.False(t, state.TLS.DidResume, "First connection should use a full handshake")
+
+	reconnectOverTLS(t, client)
+
+	state = client.ConnectionState()
+	require.NotNil(t, state.TLS, "ConnectionState should carry TLS details")
+	assert.True(t, state.TLS.DidResume, "Reconnect should resume the TLS session")
+	assert.Equal(t, uint64(1), client.Stats().ResumedHandshakes, "Stats should count the resumed handshake")
+}
+
+func TestClientTLSSessionResumptionDisabled(t *testing.T) {
+	cases := []struct {
+		name          string
+		serverOptions []server.Option
+		clientOptions []Option
+	}{
+		{"Client", nil, []Option{WithTLSSessionResumption(false)}},
+		{"Server", []server.Option{server.WithTLSSessionTickets(false)}, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			port, tlsConfig := startTLSServer(t, tc.serverOptions...)
+
+			client := New(append([]Option{
+				WithServerHost("localhost"),
+				WithServerPort(port),
+				WithTLSConfig(tlsConfig),
+				WithReconnectDelay(10 * time.Millisecond),
+			}, tc.clientOptions...)...)
+			require.NoError(t, client.Start(), "Client should connect over TLS")
+			defer client.Stop()
+
+			reconnectOverTLS(t, client)
+
+			state := client.ConnectionState()
+			require.NotNil(t, state.TLS, "ConnectionState should carry TLS details")
+			assert.False(t, state.TLS.DidResume, "Reconnect should use a full handshake")
+			assert.Zero(t, client.Stats().ResumedHandshakes, "No handshake should be counted as resumed")
+		})
+	}
+}
+
+func TestClientResolveAddress(t *testing.T) {
+	// A hostname is resolved ahead of the dial
+	client := New(WithServerHost("localhost"), WithServerPort(5000))
+	host, port, err := net.SplitHostPort(client.resolveAddress())
+	require.NoError(t, err, "Resolved address should be host:port")
+	assert.NotNil(t, net.ParseIP(host), "Hostname should be resolved to an IP address")
+	assert.Equal(t, "5000", port, "Port should be preserved")
+
+	// Transports that ignore the address are left alone
+	client = New(WithServerHost("localhost"), WithUnixSocket("/tmp/mcp.sock"))
+	assert.Equal(t, client.address(), client.resolveAddress(), "Non-TCP transports should not resolve")
+}
+
+func TestClientConnectLogging(t *testing.T) {
+	clientLogger := testutil.NewCaptureLogger()
+	serverLogger := testutil.NewCaptureLogger()
+
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(serverLogger))
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	client := New(WithTransport(transport), WithAutoReconnect(false), WithLogger(clientLogger))
+	require.NoError(t, client.Start(), "Client should connect")
+	defer client.Stop()
+
+	assert.Equal(t, []testutil.LogRecord{
+		{Level: "info", Message: "MCP client connected", Fields: map[string]any{core.LogFieldRemoteAddr: "pipe"}},
+	}, clientLogger.Records(), "Client should log exactly one connect record")
+
+	want := []testutil.LogRecord{
+		{Level: "info", Message: "MCP server listening", Fields: map[string]any{"addr": "inprocess", "transport": "inprocess"}},
+		{Level: "debug", Message: "Client connected", Fields: map[string]any{core.LogFieldRemoteAddr: "pipe"}},
+	}
+	assert.Eventually(t, func() bool {
+		return len(serverLogger.Records()) == len(want)
+	}, time.Second, 10*time.Millisecond, "Server should log the accepted connection")
+	assert.Equal(t, want, serverLogger.Records(), "Server should log exactly the listen and connect records")
+}
+
+func TestClientNopLogger(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.Start(), "Server should start")
+
+	client := New(WithTransport(transport), WithAutoReconnect(false), WithLogger(core.NopLogger()))
+	require.NoError(t, client.Start(), "Client should connect")
+	require.NoError(t, client.Stop(), "Client should stop")
+	require.NoError(t, srv.Stop(), "Server should stop")
+
+	assert.Empty(t, buf.String(), "Nop logger should produce no output")
+
+	// The default logger writes to slog.Default
+	srv = server.New(server.WithTransport(transport))
+	require.NoError(t, srv.Start(), "Server should start")
+	client = New(WithTransport(transport), WithAutoReconnect(false))
+	require.NoError(t, client.Start(), "Client should connect")
+	client.Stop()
+	srv.Stop()
+	assert.Contains(t, buf.String(), "MCP client connected", "Default logger should write through slog")
+	assert.Contains(t, buf.String(), "remote_addr=pipe", "Default logger should keep structured fields")
+}
+
+func TestClientIdleTasks(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	// A default client only monitors its connection
+	client := New(WithTransport(transport), WithLogger(core.NopLogger()))
+	require.NoError(t, client.Start(), "Client should connect")
+	assert.Equal(t, map[core.TaskFeature]core.TaskCount{
+		core.TaskConnection: {Goroutines: 1},
+	}, client.Stats().Tasks, "Idle client should run one connection goroutine")
+	require.NoError(t, client.Stop(), "Client should stop")
+	assert.Empty(t, client.Stats().Tasks, "Stopped client should run nothing")
+
+	// Keepalive adds a goroutine and its ticker
+	client = New(WithTransport(transport), WithLogger(core.NopLogger()), WithHeartbeatInterval(time.Hour))
+	require.NoError(t, client.Start(), "Client should connect")
+	defer client.Stop()
+	assert.Eventually(t, func() bool {
+		return client.Stats().Tasks[core.TaskKeepalive] == core.TaskCount{Goroutines: 1, Timers: 1}
+	}, time.Second, 10*time.Millisecond, "Keepalive should run one goroutine and one ticker")
+	assert.Equal(t, core.TaskCount{Goroutines: 1}, client.Stats().Tasks[core.TaskConnection], "Connection monitoring should be unchanged")
+}
+
+func TestClientMetricsDegradation(t *testing.T) {
+	sink := testutil.NewFlakySink()
+	sink.SetFailing(true)
+
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	// A collector that panics on every call does not affect the client
+	client := New(WithTransport(transport), WithLogger(core.NopLogger()), WithMetrics(sink))
+	require.NoError(t, client.Start(), "Client should connect")
+	defer client.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed")
+
+	assert.Equal(t, core.Health{
+		Status:   core.HealthDegraded,
+		Degraded: map[string]string{"metrics": "sink panicked: sink failing"},
+	}, client.Health(), "Client should report the failing collector")
+	assert.Positive(t, client.Stats().ObservabilityErrors, "Failures should be counted")
+
+	sink.SetFailing(false)
+	assert.Eventually(t, func() bool {
+		client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		return client.Health().Status == core.HealthHealthy
+	}, time.Second, 20*time.Millisecond, "Client should recover when the collector does")
+}
+
+func TestClientLinkStats(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	client := New(WithTransport(transport), WithLogger(core.NopLogger()))
+	require.NoError(t, client.Start(), "Client should connect")
+	defer client.Stop()
+	assert.Empty(t, client.Stats().Link.History, "No calls should mean no samples")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for i := 0; i < linkHistorySize+5; i++ {
+		_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "ProcessModel should succeed")
+	}
+
+	link := client.Stats().Link
+	require.Len(t, link.History, linkHistorySize, "History should keep the most recent calls")
+	for i, sample := range link.History {
+		assert.Equal(t, core.MethodProcessModel, sample.Method, "Sample should name the method")
+		assert.Positive(t, sample.BytesOut, "Sample should measure the request")
+		assert.Positive(t, sample.BytesIn, "Sample should measure the reply")
+		if i > 0 {
+			assert.False(t, sample.Time.Before(link.History[i-1].Time), "History should be oldest first")
+		}
+	}
+	assert.Positive(t, link.RTT, "Smoothed RTT should be measured")
+	assert.Positive(t, link.Throughput, "Smoothed throughput should be measured")
+}
+
+// startPoolServer starts an in-process server with the default handler and a
+// client with a pool of size connections to it
+func startPoolServer(t *testing.T, size int, options ...Option) (*server.Server, *Client) {
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	t.Cleanup(func() { srv.Stop() })
+
+	client := New(append([]Option{
+		WithTransport(transport),
+		WithLogger(core.NopLogger()),
+		WithConnectionPoolSize(size),
+	}, options...)...)
+	require.NoError(t, client.Start(), "Client should connect")
+	return srv, client
+}
+
+// dropPooledConn closes the client's connection in slot and waits for the client to notice
+func dropPooledConn(t *testing.T, client *Client, slot int) {
+	client.connMu.RLock()
+	conn := client.conns[slot].conn
+	client.connMu.RUnlock()
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		client.connMu.RLock()
+		defer client.connMu.RUnlock()
+		return client.conns[slot] == nil || client.conns[slot].conn != conn
+	}, 2*time.Second, 10*time.Millisecond, "Client should take the dropped connection out of the pool")
+}
+
+func TestClientConnectionPool(t *testing.T) {
+	srv, client := startPoolServer(t, 3, WithAutoReconnect(false))
+
+	assert.Equal(t, 3, client.Stats().OpenConnections, "Client should open every pooled connection")
+	assert.Eventually(t, func() bool {
+		return len(srv.Connections()) == 3
+	}, 2*time.Second, 10*time.Millisecond, "Server should see every pooled connection")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for i := 0; i < 6; i++ {
+		_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "ProcessModel should succeed")
+	}
+
+	// The others carry on when one connection dies
+	dropPooledConn(t, client, 1)
+	assert.True(t, client.IsConnected(), "Client should stay connected while connections remain")
+	assert.Equal(t, 2, client.Stats().OpenConnections, "Dropped connection should not be replaced without AutoReconnect")
+	for i := 0; i < 4; i++ {
+		_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "ProcessModel should fail over to the remaining connections")
+	}
+
+	dropPooledConn(t, client, 0)
+	dropPooledConn(t, client, 2)
+	assert.False(t, client.IsConnected(), "Client should be disconnected once every connection is gone")
+	_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.EqualError(t, err, "not connected to server", "Calls should fail with no connection open")
+}
+
+func TestClientConnectionPoolReconnect(t *testing.T) {
+	srv, client := startPoolServer(t, 2, WithReconnectDelay(100*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Calls keep succeeding while the replacement is dialed
+	dropPooledConn(t, client, 1)
+	assert.True(t, client.IsConnected(), "Client should stay connected while a replacement is dialed")
+	for i := 0; i < 4; i++ {
+		_, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "ProcessModel should succeed on the remaining connection")
+	}
+
+	require.Eventually(t, func() bool {
+		return client.Stats().OpenConnections == 2
+	}, 2*time.Second, 10*time.Millisecond, "Client should replace the dropped connection")
+	assert.Equal(t, uint64(3), client.Stats().Connections, "Only the dropped connection should be dialed again")
+	assert.Equal(t, core.StatusRunning, client.Status(), "Client should keep running")
+
+	// Stop closes every connection
+	require.NoError(t, client.Stop(), "Client should stop")
+	assert.Zero(t, client.Stats().OpenConnections, "Stopped client should hold no connections")
+	assert.Eventually(t, func() bool {
+		return len(srv.Connections()) == 0
+	}, 2*time.Second, 10*time.Millisecond, "Server should see every pooled connection close")
+}
+
+func TestClientPick(t *testing.T) {
+	busy := &pooledConn{outstanding: 2}
+	idle := &pooledConn{outstanding: 0}
+	client := &Client{conns: []*pooledConn{busy, nil, idle}}
+
+	pc := client.pick()
+	assert.Same(t, idle, pc, "Pick should prefer the connection with the fewest calls in flight")
+	assert.Equal(t, int64(1), idle.outstanding, "Pick should count the call")
+	pc.release()
+	assert.Zero(t, idle.outstanding, "Release should end the call")
+
+	// Ties rotate so an idle pool uses every connection
+	client.conns = []*pooledConn{{}, {}, {}}
+	seen := make(map[*pooledConn]bool)
+	for i := 0; i < 3; i++ {
+		pc := client.pick()
+		seen[pc] = true
+		pc.release()
+	}
+	assert.Len(t, seen, 3, "Idle pool should spread calls round-robin")
+
+	client.conns = []*pooledConn{nil, nil}
+	assert.Nil(t, client.pick(), "Pick should return nil with no connection open")
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// fileConfig is the client configuration read by OptionsFromFile and
+// OptionsFromEnv. Fields are nil unless set.
+type fileConfig struct {
+	ServerHost           *string        `config:"serverHost" env:"MCP_SERVER_HOST"`
+	ServerPort           *int           `config:"serverPort" env:"MCP_SERVER_PORT"`
+	ConnectionTimeout    *time.Duration `config:"connectionTimeout" env:"MCP_CONNECTION_TIMEOUT"`
+	ConnectionPoolSize   *int           `config:"connectionPoolSize" env:"MCP_CONNECTION_POOL_SIZE"`
+	AutoReconnect        *bool          `config:"autoReconnect" env:"MCP_AUTO_RECONNECT"`
+	MaxReconnectAttempts *int           `config:"maxReconnectAttempts" env:"MCP_MAX_RECONNECT_ATTEMPTS"`
+	ReconnectDelay       *time.Duration `config:"reconnectDelay" env:"MCP_RECONNECT_DELAY"`
+	TLS                  *bool          `config:"tls" env:"MCP_TLS"`
+	TLSCA                *string        `config:"tlsCA" env:"MCP_TLS_CA"`
+	HeartbeatInterval    *time.Duration `config:"heartbeatInterval" env:"MCP_HEARTBEAT_INTERVAL"`
+	HeartbeatTimeout     *time.Duration `config:"heartbeatTimeout" env:"MCP_HEARTBEAT_TIMEOUT"`
+	MaxMissedHeartbeats  *int           `config:"maxMissedHeartbeats" env:"MCP_MAX_MISSED_HEARTBEATS"`
+	AuthToken            *string        `config:"authToken" env:"MCP_AUTH_TOKEN"`
+	Compression          *[]string      `config:"compression" env:"MCP_COMPRESSION"`
+	MaxResponseBytes     *int64         `config:"maxResponseBytes" env:"MCP_MAX_RESPONSE_BYTES"`
+}
+
+// OptionsFromFile reads client options from the YAML or JSON file at path,
+// named by their extension. Keys mirror the Options they set: serverHost,
+// serverPort, connectionTimeout, connectionPoolSize, autoReconnect,
+// maxReconnectAttempts, reconnectDelay, tls, tlsCA (a PEM file of
+// certificate authorities to trust, which implies tls), heartbeatInterval,
+// heartbeatTimeout, maxMissedHeartbeats, authToken, compression and
+// maxResponseBytes. Durations are strings such as "30s". An unknown key
+// fails, naming the closest known one.
+//
+// Options passed to New apply in order, so explicit options placed after
+// those read override them:
+//
+//	fileOpts, err := client.OptionsFromFile("mcp.yaml")
+//	envOpts, err := client.OptionsFromEnv()
+//	c := client.New(append(append(fileOpts, envOpts...), client.WithLogger(logger))...)
+func OptionsFromFile(path string) ([]Option, error) {
+	var cfg fileConfig
+	if err := core.LoadConfigFile(path, &cfg); err != nil {
+		return nil, err
+	}
+	options, err := cfg.options()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return options, nil
+}
+
+// OptionsFromEnv reads client options from the environment: MCP_SERVER_HOST,
+// MCP_SERVER_PORT, MCP_CONNECTION_TIMEOUT, MCP_CONNECTION_POOL_SIZE,
+// MCP_AUTO_RECONNECT, MCP_MAX_RECONNECT_ATTEMPTS, MCP_RECONNECT_DELAY,
+// MCP_TLS, MCP_TLS_CA, MCP_HEARTBEAT_INTERVAL, MCP_HEARTBEAT_TIMEOUT,
+// MCP_MAX_MISSED_HEARTBEATS, MCP_AUTH_TOKEN, MCP_COMPRESSION (comma
+// separated) and MCP_MAX_RESPONSE_BYTES, set as OptionsFromFile does.
+// Variables that are not set leave their options alone.
+func OptionsFromEnv() ([]Option, error) {
+	var cfg fileConfig
+	if err := core.LoadConfigEnv(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.options()
+}
+
+// options returns the options setting what the configuration sets, failing
+// for values the options would not accept.
+func (c *fileConfig) options() ([]Option, error) {
+	for name, n := range map[string]*int{
+		"max reconnect attempts": c.MaxReconnectAttempts,
+		"max missed heartbeats":  c.MaxMissedHeartbeats,
+	} {
+		if n != nil && *n < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %d", name, *n)
+		}
+	}
+	for name, d := range map[string]*time.Duration{
+		"connection timeout": c.ConnectionTimeout,
+		"reconnect delay":    c.ReconnectDelay,
+		"heartbeat interval": c.HeartbeatInterval,
+		"heartbeat timeout":  c.HeartbeatTimeout,
+	} {
+		if d != nil && *d < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %s", name, *d)
+		}
+	}
+	if c.MaxResponseBytes != nil && *c.MaxResponseBytes < 0 {
+		return nil, fmt.Errorf("max response bytes must not be negative, got %d", *c.MaxResponseBytes)
+	}
+
+	var options []Option
+	if c.ServerHost != nil {
+		options = append(options, WithServerHost(*c.ServerHost))
+	}
+	if c.ServerPort != nil {
+		if *c.ServerPort < 1 || *c.ServerPort > 65535 {
+			return nil, fmt.Errorf("server port must be between 1 and 65535, got %d", *c.ServerPort)
+		}
+		options = append(options, WithServerPort(*c.ServerPort))
+	}
+	if c.ConnectionTimeout != nil {
+		options = append(options, WithConnectionTimeout(*c.ConnectionTimeout))
+	}
+	if c.ConnectionPoolSize != nil {
+		if *c.ConnectionPoolSize < 1 {
+			return nil, fmt.Errorf("connection pool size must be positive, got %d", *c.ConnectionPoolSize)
+		}
+		options = append(options, WithConnectionPoolSize(*c.ConnectionPoolSize))
+	}
+	if c.AutoReconnect != nil {
+		options = append(options, WithAutoReconnect(*c.AutoReconnect))
+	}
+	if c.MaxReconnectAttempts != nil {
+		options = append(options, WithMaxReconnectAttempts(*c.MaxReconnectAttempts))
+	}
+	if c.ReconnectDelay != nil {
+		options = append(options, WithReconnectDelay(*c.ReconnectDelay))
+	}
+	if c.TLSCA != nil {
+		if c.TLS != nil && !*c.TLS {
+			return nil, errors.New("a TLS certificate authority is set with TLS disabled")
+		}
+		pem, err := os.ReadFile(*c.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS certificate authority: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", *c.TLSCA)
+		}
+		options = append(options, WithTLSConfig(&tls.Config{RootCAs: pool}))
+	} else if c.TLS != nil {
+		options = append(options, WithTLSEnabled(*c.TLS))
+	}
+	if c.HeartbeatInterval != nil {
+		options = append(options, WithHeartbeatInterval(*c.HeartbeatInterval))
+	}
+	if c.HeartbeatTimeout != nil {
+		options = append(options, WithHeartbeatTimeout(*c.HeartbeatTimeout))
+	}
+	if c.MaxMissedHeartbeats != nil {
+		options = append(options, WithMaxMissedHeartbeats(*c.MaxMissedHeartbeats))
+	}
+	if c.AuthToken != nil {
+		options = append(options, WithAuthToken(*c.AuthToken))
+	}
+	if c.Compression != nil {
+		algorithms := make([]core.Compression, len(*c.Compression))
+		for i, name := range *c.Compression {
+			algorithms[i] = core.Compression(name)
+		}
+		options = append(options, WithCompression(algorithms...))
+	}
+	if c.MaxResponseBytes != nil {
+		options = append(options, WithMaxResponseBytes(*c.MaxResponseBytes))
+	}
+	return options, nil
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFromFileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`serverHost: mcp.internal
+serverPort: 6000
+autoReconnect: true
+reconnectDelay: 250ms
+compression: [zstd, gzip]
+`), 0o600), "Writing the configuration should succeed")
+	t.Setenv("MCP_SERVER_PORT", "7000")
+	t.Setenv("MCP_TLS", "true")
+	t.Setenv("MCP_AUTH_TOKEN", "secret")
+
+	fileOpts, err := OptionsFromFile(path)
+	require.NoError(t, err, "Reading the file should succeed")
+	envOpts, err := OptionsFromEnv()
+	require.NoError(t, err, "Reading the environment should succeed")
+
+	options := DefaultOptions()
+	for _, opt := range append(append(fileOpts, envOpts...), WithReconnectDelay(time.Second)) {
+		opt(&options)
+	}
+
+	assert.Equal(t, "mcp.internal", options.ServerHost, "ServerHost should come from the file")
+	assert.Equal(t, 7000, options.ServerPort, "The environment should override the file")
+	assert.True(t, options.AutoReconnect, "AutoReconnect should come from the file")
+	assert.Equal(t, time.Second, options.ReconnectDelay, "An explicit option should override the file")
+	assert.True(t, options.EnableTLS, "EnableTLS should come from the environment")
+	assert.Equal(t, core.AuthSchemeToken, options.AuthScheme, "The auth token should come from the environment")
+	assert.Equal(t, core.Compression("zstd"), options.Compression, "Compression should come from the file")
+	assert.Equal(t, []core.Compression{core.CompressionGzip}, options.CompressionFallbacks, "CompressionFallbacks should come from the file")
+	assert.Equal(t, DefaultOptions().ConnectionTimeout, options.ConnectionTimeout, "Options left out should keep their defaults")
+}
+
+func TestOptionsFromFileErrors(t *testing.T) {
+	for content, want := range map[string]string{
+		"serverPrt: 6000\n":           `unknown key "serverPrt", did you mean "serverPort"?`,
+		"serverPort: 0\n":             "server port must be between 1 and 65535",
+		"autoReconnect: sometimes\n":  "invalid autoReconnect",
+		"connectionPoolSize: 0\n":     "connection pool size must be positive",
+		"reconnectDelay: -1s\n":       "reconnect delay must not be negative",
+		"tlsCA: missing.pem\n":        "failed to read TLS certificate authority",
+		"tls: false\ntlsCA: ca.pem\n": "TLS disabled",
+	} {
+		path := filepath.Join(t.TempDir(), "mcp.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600), "Writing the configuration should succeed")
+
+		_, err := OptionsFromFile(path)
+		require.Error(t, err, "Reading %q should fail", content)
+		assert.Contains(t, err.Error(), want, "The error for %q should say what is wrong", content)
+	}
+}
+
+func TestOptionsFromEnvErrors(t *testing.T) {
+	t.Setenv("MCP_RECONNECT_DELAY", "5")
+	_, err := OptionsFromEnv()
+	require.Error(t, err, "A duration without a unit should fail")
+	assert.Contains(t, err.Error(), "invalid MCP_RECONNECT_DELAY", "The error should name the variable")
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// connectionQueueSize is how many connection events a connectionNotifier
+// holds for its callbacks before dropping the oldest.
+const connectionQueueSize = 64
+
+// errConnectionLost is the error of disconnect events for connections that
+// dropped while the client was running.
+var errConnectionLost = errors.New("connection to server lost")
+
+// OnConnect registers a callback told of every connection the client opens,
+// when it starts and whenever it reconnects, and returns a function that
+// removes it. Connection callbacks, this and OnDisconnect's, run one at a
+// time, in registration order, and see events in the order they happened.
+func (c *Client) OnConnect(callback func(core.ConnectionEvent)) (cancel func()) {
+	return c.connEvents.subscribe(true, callback)
+}
+
+// OnDisconnect registers a callback told of every connection the client
+// loses, and of every reconnection attempt that fails, and returns a function
+// that removes it. Unlike OnStatusChange, which reports the client running
+// throughout an auto-reconnect, it shows when the client is actually cut off.
+func (c *Client) OnDisconnect(callback func(core.ConnectionEvent)) (cancel func()) {
+	return c.connEvents.subscribe(false, callback)
+}
+
+// connectionCallback is a registered OnConnect or OnDisconnect callback.
+type connectionCallback struct {
+	connect   bool // Whether it is told of connects rather than disconnects
+	callback  func(core.ConnectionEvent)
+	cancelled bool // Guarded by connectionNotifier.mu
+}
+
+// queuedConnectionEvent is an event waiting for its callbacks.
+type queuedConnectionEvent struct {
+	connect bool
+	event   core.ConnectionEvent
+}
+
+// connectionNotifier delivers connection events to callbacks, as
+// core.StatusNotifier does status changes: one at a time, in the order they
+// were published, from a goroutine that runs while events are pending.
+type connectionNotifier struct {
+	mu         sync.Mutex
+	logger     core.Logger
+	tasks      *core.TaskTracker
+	callbacks  []*connectionCallback
+	queue      []queuedConnectionEvent
+	delivering bool
+}
+
+// newConnectionNotifier creates a notifier that runs its dispatcher under
+// tasks and logs dropped events and panicking callbacks to logger.
+func newConnectionNotifier(logger core.Logger, tasks *core.TaskTracker) *connectionNotifier {
+	return &connectionNotifier{logger: logger, tasks: tasks}
+}
+
+// subscribe registers callback for the connects, or the disconnects,
+// published after it returns.
+func (n *connectionNotifier) subscribe(connect bool, callback func(core.ConnectionEvent)) (cancel func()) {
+	cb := &connectionCallback{connect: connect, callback: callback}
+	n.mu.Lock()
+	n.callbacks = append(n.callbacks, cb)
+	n.mu.Unlock()
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if cb.cancelled {
+			return
+		}
+		cb.cancelled = true
+		for i, registered := range n.callbacks {
+			if registered == cb {
+				n.callbacks = append(n.callbacks[:i:i], n.callbacks[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// publish queues event for the connect or disconnect callbacks, stamping it
+// with the current time, and returns without waiting for them.
+func (n *connectionNotifier) publish(connect bool, event core.ConnectionEvent) {
+	event.Timestamp = time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.callbacks) == 0 {
+		return
+	}
+	if len(n.queue) == connectionQueueSize {
+		n.queue = n.queue[1:]
+		n.logger.Warn("Connection callbacks are falling behind, dropping event")
+	}
+	n.queue = append(n.queue, queuedConnectionEvent{connect: connect, event: event})
+	if !n.delivering {
+		n.delivering = true
+		n.tasks.Go(core.TaskEvents, n.dispatch)
+	}
+}
+
+// dispatch delivers queued events until none are left.
+func (n *connectionNotifier) dispatch() {
+	for {
+		n.mu.Lock()
+		if len(n.queue) == 0 {
+			n.delivering = false
+			n.queue = nil
+			n.mu.Unlock()
+			return
+		}
+		queued := n.queue[0]
+		n.queue = n.queue[1:]
+		callbacks := append([]*connectionCallback(nil), n.callbacks...)
+		n.mu.Unlock()
+
+		for _, cb := range callbacks {
+			n.mu.Lock()
+			cancelled := cb.cancelled
+			n.mu.Unlock()
+			if !cancelled && cb.connect == queued.connect {
+				n.deliver(cb.callback, queued.event)
+			}
+		}
+	}
+}
+
+// deliver calls callback with event, logging rather than propagating a panic
+// so one callback cannot stop delivery to the others.
+func (n *connectionNotifier) deliver(callback func(core.ConnectionEvent), event core.ConnectionEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			n.logger.Error("Connection callback panicked", core.LogFieldError, r)
+		}
+	}()
+	callback(event)
+}
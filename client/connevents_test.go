@@ -0,0 +1,148 @@
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectionLog records the events of OnConnect and OnDisconnect in the order
+// they are delivered.
+type connectionLog struct {
+	mu      sync.Mutex
+	connect []bool
+	events  []core.ConnectionEvent
+}
+
+func watchConnections(c *Client) *connectionLog {
+	log := &connectionLog{}
+	record := func(connect bool) func(core.ConnectionEvent) {
+		return func(event core.ConnectionEvent) {
+			log.mu.Lock()
+			defer log.mu.Unlock()
+			log.connect = append(log.connect, connect)
+			log.events = append(log.events, event)
+		}
+	}
+	c.OnConnect(record(true))
+	c.OnDisconnect(record(false))
+	return log
+}
+
+func (l *connectionLog) snapshot() ([]bool, []core.ConnectionEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]bool(nil), l.connect...), append([]core.ConnectionEvent(nil), l.events...)
+}
+
+func (l *connectionLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.events)
+}
+
+func TestClientConnectionEvents(t *testing.T) {
+	port, err := testutil.GetFreePort()
+	require.NoError(t, err, "Failed to get free port")
+	srv := server.New(server.WithPort(port), server.WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	defer srv.Stop()
+
+	client := New(
+		WithServerHost("127.0.0.1"),
+		WithServerPort(port),
+		WithReconnectDelay(50*time.Millisecond),
+		WithMaxReconnectAttempts(100),
+		WithLogger(core.NopLogger()),
+	)
+	log := watchConnections(client)
+	require.NoError(t, client.Start(), "Client should start successfully")
+
+	require.Eventually(t, func() bool { return log.len() == 1 }, 2*time.Second, 10*time.Millisecond, "Starting should report a connect")
+	state := client.ConnectionState()
+	assert.True(t, state.Connected, "The client should be connected")
+	assert.False(t, state.Since.IsZero(), "The state should say since when")
+	assert.Zero(t, state.ReconnectAttempt, "No reconnection should be in progress")
+
+	// Drop the server and watch the client try to get back
+	require.NoError(t, srv.Stop(), "Server should stop successfully")
+	require.Eventually(t, func() bool { return log.len() >= 4 }, 2*time.Second, 10*time.Millisecond, "The drop and failed attempts should be reported")
+	state = client.ConnectionState()
+	assert.False(t, state.Connected, "The client should be disconnected")
+	assert.Equal(t, core.StatusRunning, client.Status(), "The client should keep running while it reconnects")
+	assert.NotZero(t, state.ReconnectAttempt, "A reconnection should be in progress")
+	assert.Error(t, state.LastError, "The state should say why the client is disconnected")
+
+	// Bring the server back and wait for the reconnect
+	require.NoError(t, srv.Start(), "Server should restart successfully")
+	require.Eventually(t, func() bool {
+		connect, _ := log.snapshot()
+		return connect[len(connect)-1]
+	}, 2*time.Second, 10*time.Millisecond, "Reconnecting should report a connect")
+
+	connect, events := log.snapshot()
+	require.GreaterOrEqual(t, len(events), 4, "There should be a connect, a drop, failed attempts and a reconnect")
+	assert.True(t, connect[0], "The first event should be the connect")
+	assert.Zero(t, events[0].Attempt, "The first connect should not be a reconnection")
+	assert.NoError(t, events[0].Error, "A connect should have no error")
+	assert.Equal(t, srv.Addr().String(), events[0].RemoteAddr, "The connect should name the server")
+
+	assert.False(t, connect[1], "The second event should be the drop")
+	assert.Zero(t, events[1].Attempt, "The drop should not be a reconnection attempt")
+	assert.ErrorIs(t, events[1].Error, errConnectionLost, "The drop should say the connection was lost")
+
+	last := len(events) - 1
+	for i := 2; i < last; i++ {
+		assert.False(t, connect[i], "Event %d should be a failed attempt", i)
+		assert.Equal(t, i-1, events[i].Attempt, "Failed attempts should be reported in order")
+		assert.Error(t, events[i].Error, "Failed attempt %d should say why", i)
+	}
+	assert.True(t, connect[last], "The last event should be the reconnect")
+	assert.Equal(t, last-1, events[last].Attempt, "The reconnect should follow the failed attempts")
+	assert.NoError(t, events[last].Error, "A connect should have no error")
+	for i := 1; i < len(events); i++ {
+		assert.False(t, events[i].Timestamp.Before(events[i-1].Timestamp), "Event %d should not precede the one before", i)
+	}
+
+	state = client.ConnectionState()
+	assert.True(t, state.Connected, "The client should be connected again")
+	assert.Zero(t, state.ReconnectAttempt, "No reconnection should be in progress")
+
+	// A connection the client closes itself is not lost
+	require.NoError(t, client.Stop(), "Client should stop successfully")
+	require.Eventually(t, func() bool { return log.len() == len(events)+1 }, 2*time.Second, 10*time.Millisecond, "Stopping should report a disconnect")
+	_, events = log.snapshot()
+	assert.NoError(t, events[len(events)-1].Error, "Stopping should not count as losing the connection")
+	assert.False(t, client.ConnectionState().Connected, "A stopped client should not be connected")
+}
+
+func TestClientConnectionEventsCancel(t *testing.T) {
+	client, _ := testutil.StartInProcessPair(t,
+		func(tr core.Transport) *Client { return New(WithTransport(tr), WithLogger(core.NopLogger())) },
+		func(tr core.Transport) *server.Server {
+			return server.New(server.WithTransport(tr), server.WithLogger(core.NopLogger()))
+		})
+
+	var mu sync.Mutex
+	disconnects := 0
+	cancel := client.OnDisconnect(func(core.ConnectionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		disconnects++
+	})
+	cancel()
+	cancel()
+
+	require.NoError(t, client.Stop(), "Client should stop successfully")
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Zero(t, disconnects, "A removed callback should not be called")
+}
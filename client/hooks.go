@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// requestHooks holds the hooks registered with OnBeforeSend and
+// OnAfterReceive, in registration order.
+type requestHooks struct {
+	mu     sync.RWMutex
+	before []func(context.Context, *core.ModelRequest) error
+	after  []func(context.Context, *core.ModelResponse) error
+}
+
+// OnBeforeSend registers a hook run on every ModelRequest the client sends,
+// with ProcessModel, ProcessModelStream, SubmitModel or in a batch, after the
+// metadata and timeout are filled in. Hooks run in registration order and may
+// modify the request, which is a copy of the caller's with a non-nil
+// Metadata. An error from a hook fails the call without sending anything.
+func (c *Client) OnBeforeSend(hook func(ctx context.Context, req *core.ModelRequest) error) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.before = append(c.hooks.before, hook)
+}
+
+// OnAfterReceive registers a hook run on every ModelResponse the client
+// receives, from ProcessModel, ProcessModelStream, a batch, or the status of
+// a job, before it is returned. Hooks run in registration order and may
+// modify the response. An error from a hook fails the call.
+func (c *Client) OnAfterReceive(hook func(ctx context.Context, resp *core.ModelResponse) error) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.after = append(c.hooks.after, hook)
+}
+
+// beforeSend runs the OnBeforeSend hooks on a copy of req, returning the copy.
+// Without hooks req is returned as it is.
+func (h *requestHooks) beforeSend(ctx context.Context, req *core.ModelRequest) (*core.ModelRequest, error) {
+	h.mu.RLock()
+	hooks := h.before
+	h.mu.RUnlock()
+	if len(hooks) == 0 || req == nil {
+		return req, nil
+	}
+
+	out := cloneRequest(req)
+	for _, hook := range hooks {
+		if err := hook(ctx, out); err != nil {
+			return nil, fmt.Errorf("before send hook failed: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// afterReceive runs the OnAfterReceive hooks on resp.
+func (h *requestHooks) afterReceive(ctx context.Context, resp *core.ModelResponse) error {
+	h.mu.RLock()
+	hooks := h.after
+	h.mu.RUnlock()
+	if resp == nil {
+		return nil
+	}
+
+	for _, hook := range hooks {
+		if err := hook(ctx, resp); err != nil {
+			return fmt.Errorf("after receive hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// cloneRequest copies req so that hooks can change model data, parameters
+// and metadata, at any depth, without touching the caller's request. The
+// copy always has a Metadata map, so hooks can add to it directly.
+func cloneRequest(req *core.ModelRequest) *core.ModelRequest {
+	out := req.Clone()
+	if out.Metadata == nil {
+		out.Metadata = make(map[string]string)
+	}
+	return out
+}
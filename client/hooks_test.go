@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataHandler records the metadata of each request it receives
+type metadataHandler struct {
+	mu       sync.Mutex
+	received []map[string]string
+}
+
+func (h *metadataHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *metadataHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	h.mu.Lock()
+	h.received = append(h.received, req.Metadata)
+	h.mu.Unlock()
+	return core.NewModelResponse(req), nil
+}
+
+func (h *metadataHandler) requests() []map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.received
+}
+
+// startHookPair starts a server with a metadataHandler and a client
+func startHookPair(t *testing.T) (*Client, *metadataHandler) {
+	handler := &metadataHandler{}
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *Client {
+			return New(WithTransport(transport), WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c, handler
+}
+
+func TestOnBeforeSend(t *testing.T) {
+	c, handler := startHookPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Hooks run in order, each seeing what the last one set
+	c.OnBeforeSend(func(_ context.Context, req *core.ModelRequest) error {
+		req.Metadata["client-version"] = "1.2.3"
+		return nil
+	})
+	c.OnBeforeSend(func(_ context.Context, req *core.ModelRequest) error {
+		req.Metadata["signature"] = "signed:" + req.Metadata["client-version"]
+		return nil
+	})
+
+	req := testutil.CreateTestModelRequest()
+	_, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should succeed")
+	_, err = c.ProcessModelBatch(ctx, []*core.ModelRequest{testutil.CreateTestModelRequest()})
+	require.NoError(t, err, "Batch should succeed")
+
+	received := handler.requests()
+	require.Len(t, received, 2, "Both requests should reach the handler")
+	for _, md := range received {
+		assert.Equal(t, "1.2.3", md["client-version"], "Server should see the version set by the hook")
+		assert.Equal(t, "signed:1.2.3", md["signature"], "Server should see the signature set by the hook")
+	}
+	assert.Nil(t, req.Metadata, "Caller's request should not be modified")
+}
+
+func TestOnBeforeSendError(t *testing.T) {
+	c, handler := startHookPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	errUnsigned := errors.New("no signing key")
+	later := false
+	c.OnBeforeSend(func(context.Context, *core.ModelRequest) error { return errUnsigned })
+	c.OnBeforeSend(func(context.Context, *core.ModelRequest) error {
+		later = true
+		return nil
+	})
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.ErrorIs(t, err, errUnsigned, "Hook error should fail the call")
+	_, err = c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	assert.ErrorIs(t, err, errUnsigned, "Hook error should fail the submission")
+	_, err = c.ProcessModelBatch(ctx, []*core.ModelRequest{testutil.CreateTestModelRequest()})
+	assert.ErrorIs(t, err, errUnsigned, "Hook error should fail the batch")
+
+	assert.False(t, later, "Hooks after a failing one should not run")
+	assert.Empty(t, handler.requests(), "Nothing should be sent")
+}
+
+func TestOnAfterReceive(t *testing.T) {
+	c, _ := startHookPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Normalize timestamps to whole seconds in UTC
+	c.OnAfterReceive(func(_ context.Context, resp *core.ModelResponse) error {
+		resp.Timestamp = resp.Timestamp.UTC().Truncate(time.Second)
+		return nil
+	})
+
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request should succeed")
+	assert.Equal(t, time.UTC, resp.Timestamp.Location(), "Timestamp should be in UTC")
+	assert.Zero(t, resp.Timestamp.Nanosecond(), "Timestamp should be truncated")
+
+	responses, err := c.ProcessModelBatch(ctx, []*core.ModelRequest{testutil.CreateTestModelRequest()})
+	require.NoError(t, err, "Batch should succeed")
+	assert.Zero(t, responses[0].Timestamp.Nanosecond(), "Batch responses should pass through the hook")
+
+	// A failing hook fails the call
+	errStale := errors.New("stale response")
+	c.OnAfterReceive(func(context.Context, *core.ModelResponse) error { return errStale })
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.ErrorIs(t, err, errStale, "Hook error should fail the call")
+}
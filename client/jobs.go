@@ -0,0 +1,322 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// SubmitModel starts processing req asynchronously with mcp.submitModel and
+// returns the job's ID without waiting for the handler. Use JobStatus or
+// WaitForJob for the result; the job keeps running if the client disconnects.
+func (c *Client) SubmitModel(ctx context.Context, req *core.ModelRequest) (core.JobID, error) {
+	requestID := ""
+	if req != nil {
+		requestID = req.ID
+	}
+	ctx, endSpan := c.options.Tracer.StartSpan(ctx, core.SpanClient, core.MethodSubmitModel, requestID)
+
+	req, err := c.hooks.beforeSend(ctx, c.withMetadata(ctx, req))
+	if err != nil {
+		endSpan(err)
+		return "", err
+	}
+	validated, err := c.validateLocally(ctx, core.MethodProcessModel, req)
+	if err != nil {
+		endSpan(err)
+		return "", err
+	}
+
+	var resp core.SubmitModelResponse
+	err = c.call(ctx, core.MethodSubmitModel, req, &resp)
+	if err != nil && validated {
+		c.checkDrift(core.MethodProcessModel, requestID, err)
+	}
+	endSpan(err)
+	if err != nil {
+		return "", err
+	}
+	return resp.JobID, nil
+}
+
+// JobStatus returns the status of a job started with SubmitModel. Unknown
+// and expired jobs fail with a core.CodeJobNotFound error.
+func (c *Client) JobStatus(ctx context.Context, id core.JobID) (*core.JobStatus, error) {
+	var status core.JobStatus
+	if err := c.call(ctx, core.MethodJobStatus, core.JobRequest{JobID: id}, &status); err != nil {
+		return nil, err
+	}
+	if err := c.hooks.afterReceive(ctx, status.Response); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// WaitForJob polls the status of a job every JobPollInterval until it is done
+// or cancelled, or ctx ends, and returns its final status.
+func (c *Client) WaitForJob(ctx context.Context, id core.JobID) (*core.JobStatus, error) {
+	ticker := c.tasks.NewTicker(core.TaskJobs, c.options.JobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.JobStatus(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if status.State.Finished() {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CancelJob cancels the context of a job started with SubmitModel and returns
+// its status. The job is reported as cancelled once its handler returns;
+// cancelling a finished job has no effect.
+func (c *Client) CancelJob(ctx context.Context, id core.JobID) (*core.JobStatus, error) {
+	var status core.JobStatus
+	if err := c.call(ctx, core.MethodCancelJob, core.JobRequest{JobID: id}, &status); err != nil {
+		return nil, err
+	}
+	if err := c.hooks.afterReceive(ctx, status.Response); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// JobEvent is an update delivered by WatchJob.
+type JobEvent struct {
+	Progress core.JobProgress // Latest progress snapshot of the job
+	Status   *core.JobStatus  // Final status, on the last event once the job has finished
+	Err      error            // Why watching ended before the job finished, on the last event
+}
+
+// jobWatch is a WatchJob call waiting for the progress of a job.
+type jobWatch struct {
+	id   core.JobID
+	wake chan struct{} // Signalled when any field below changes
+
+	mu     sync.Mutex
+	seen   int               // Sequence of the newest snapshot accepted; -1 before the first
+	latest *core.JobProgress // Newest snapshot not yet delivered
+	resync bool              // Status must be fetched again, after a reconnect
+	err    error             // Watching must end
+}
+
+// offer accepts progress if it is newer than any snapshot seen so far.
+func (w *jobWatch) offer(progress core.JobProgress) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if progress.Sequence <= w.seen {
+		return
+	}
+	w.seen = progress.Sequence
+	w.latest = &progress
+	w.signal()
+}
+
+func (w *jobWatch) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// watchRegistry routes received progress snapshots to the WatchJob calls
+// waiting for them, by job ID.
+type watchRegistry struct {
+	mu      sync.Mutex
+	watches map[*jobWatch]struct{}
+}
+
+func (r *watchRegistry) add(w *jobWatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watches == nil {
+		r.watches = make(map[*jobWatch]struct{})
+	}
+	r.watches[w] = struct{}{}
+}
+
+func (r *watchRegistry) remove(w *jobWatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.watches, w)
+}
+
+// each calls fn for every watch in progress.
+func (r *watchRegistry) each(fn func(*jobWatch)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for w := range r.watches {
+		fn(w)
+	}
+}
+
+// resync makes every watch fetch the status of its job again, to catch up on
+// progress published while the client was disconnected.
+func (r *watchRegistry) resync() {
+	r.each(func(w *jobWatch) {
+		w.mu.Lock()
+		w.resync = true
+		w.signal()
+		w.mu.Unlock()
+	})
+}
+
+// fail ends every watch with err.
+func (r *watchRegistry) fail(err error) {
+	r.each(func(w *jobWatch) {
+		w.mu.Lock()
+		w.err = err
+		w.signal()
+		w.mu.Unlock()
+	})
+}
+
+// deliverJobProgress hands a received progress snapshot to the watches of its job.
+func (c *Client) deliverJobProgress(params *json.RawMessage) {
+	progress, err := core.JobProgressUpdates.Decode(params)
+	if err != nil {
+		return
+	}
+	c.watches.each(func(w *jobWatch) {
+		if w.id == progress.JobID {
+			w.offer(progress)
+		}
+	})
+}
+
+// WatchJob subscribes to the progress of a job, which need not have been
+// submitted by this client, and returns a channel of its progress snapshots.
+// The last event carries the final status once the job has finished, or an
+// error if watching ended first, and the channel is closed after it. Watching
+// a finished job yields its final status straight away; watching an unknown
+// one fails with a core.CodeJobNotFound error.
+//
+// Snapshots are delivered newest first: one that arrives while the previous
+// event is still unread replaces any other waiting snapshot. After a
+// reconnect the watch resumes from the latest progress the server recorded.
+// Watching ends when ctx does, closing the channel.
+func (c *Client) WatchJob(ctx context.Context, id core.JobID) (<-chan JobEvent, error) {
+	topic := core.JobProgressTopic(id)
+	w := &jobWatch{id: id, wake: make(chan struct{}, 1), seen: -1}
+	c.watches.add(w)
+	if err := c.Subscribe(ctx, topic); err != nil {
+		c.watches.remove(w)
+		return nil, err
+	}
+	status, err := c.JobStatus(ctx, id)
+	if err != nil {
+		c.watches.remove(w)
+		c.unsubscribeLater(topic)
+		return nil, err
+	}
+
+	events := make(chan JobEvent)
+	c.tasks.Go(core.TaskJobs, func() {
+		defer close(events)
+		defer c.unsubscribeLater(topic)
+		defer c.watches.remove(w)
+		c.watchJob(ctx, w, status, events)
+	})
+	return events, nil
+}
+
+// watchJob delivers the events of w, starting from status, until the job
+// finishes or watching ends.
+func (c *Client) watchJob(ctx context.Context, w *jobWatch, status *core.JobStatus, events chan<- JobEvent) {
+	send := func(event JobEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		case <-c.runContext().Done():
+			return false
+		}
+	}
+
+	needStatus := false
+	for {
+		if status != nil {
+			if status.State.Finished() {
+				event := JobEvent{Status: status}
+				if status.Progress != nil {
+					event.Progress = *status.Progress
+				}
+				send(event)
+				return
+			}
+			if status.Progress != nil {
+				w.offer(*status.Progress)
+			}
+			status = nil
+		}
+
+		w.mu.Lock()
+		latest, err := w.latest, w.err
+		w.latest = nil
+		if w.resync {
+			needStatus, w.resync = true, false
+		}
+		w.mu.Unlock()
+
+		if err != nil {
+			send(JobEvent{Err: err})
+			return
+		}
+		if latest != nil {
+			// The final snapshot announces the result, which the status carries
+			if latest.State.Finished() {
+				needStatus = true
+			} else if !send(JobEvent{Progress: *latest}) {
+				return
+			}
+			continue
+		}
+		if needStatus {
+			var fetchErr error
+			status, fetchErr = c.JobStatus(ctx, w.id)
+			needStatus = false
+			if fetchErr == nil {
+				continue
+			}
+			// Wait for the reconnect to resync the watch unless the server answered
+			var rpcErr *jsonrpc2.Error
+			if ctx.Err() != nil || errors.As(fetchErr, &rpcErr) || !c.options.AutoReconnect {
+				send(JobEvent{Err: fetchErr})
+				return
+			}
+		}
+
+		select {
+		case <-w.wake:
+		case <-ctx.Done():
+			return
+		case <-c.runContext().Done():
+			send(JobEvent{Err: errors.New("client stopped")})
+			return
+		}
+	}
+}
+
+// unsubscribeLater ends a subscription without holding up the caller.
+func (c *Client) unsubscribeLater(topic string) {
+	c.tasks.Go(core.TaskJobs, func() {
+		ctx, cancel := context.WithTimeout(c.runContext(), c.options.ConnectionTimeout)
+		defer cancel()
+		if err := c.Unsubscribe(ctx, topic); err != nil {
+			c.options.Logger.Debug("Failed to unsubscribe", "topic", topic, core.LogFieldError, err)
+		}
+	})
+}
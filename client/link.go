@@ -0,0 +1,78 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// linkHistorySize is how many recent calls the link measurements keep.
+const linkHistorySize = 32
+
+// linkSmoothing is the weight of a new sample in the smoothed RTT and throughput.
+const linkSmoothing = 0.2
+
+// LinkSample is the measurement of one call to the server.
+type LinkSample struct {
+	Time     time.Time     `json:"time"`     // When the call completed
+	Method   string        `json:"method"`   // JSON-RPC method called
+	RTT      time.Duration `json:"rtt"`      // Time from sending the request to decoding the reply
+	BytesOut int           `json:"bytesOut"` // Size of the encoded request
+	BytesIn  int           `json:"bytesIn"`  // Size of the encoded reply
+}
+
+// LinkStats summarizes the performance of the link to the server as seen by
+// recent calls.
+type LinkStats struct {
+	RTT        time.Duration `json:"rtt"`        // Smoothed round trip time
+	Throughput float64       `json:"throughput"` // Smoothed bytes per second, both directions combined
+	History    []LinkSample  `json:"history"`    // Most recent calls, oldest first
+}
+
+// linkMonitor accumulates LinkStats from completed calls.
+type linkMonitor struct {
+	mu         sync.Mutex
+	rtt        float64
+	throughput float64
+	history    []LinkSample
+	next       int
+}
+
+// record adds the measurement of a successful call.
+func (m *linkMonitor) record(sample LinkSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rtt := float64(sample.RTT)
+	throughput := 0.0
+	if sample.RTT > 0 {
+		throughput = float64(sample.BytesOut+sample.BytesIn) / sample.RTT.Seconds()
+	}
+	if len(m.history) == 0 {
+		m.rtt, m.throughput = rtt, throughput
+	} else {
+		m.rtt += linkSmoothing * (rtt - m.rtt)
+		m.throughput += linkSmoothing * (throughput - m.throughput)
+	}
+
+	if len(m.history) < linkHistorySize {
+		m.history = append(m.history, sample)
+		return
+	}
+	m.history[m.next] = sample
+	m.next = (m.next + 1) % linkHistorySize
+}
+
+// stats returns a snapshot of the measurements.
+func (m *linkMonitor) stats() LinkStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := make([]LinkSample, 0, len(m.history))
+	history = append(history, m.history[m.next:]...)
+	history = append(history, m.history[:m.next]...)
+	return LinkStats{
+		RTT:        time.Duration(m.rtt),
+		Throughput: m.throughput,
+		History:    history,
+	}
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// intercepted wraps process, the round trip of model requests to method, in
+// the configured interceptors.
+func (c *Client) intercepted(method string, process core.ProcessFunc) core.ProcessFunc {
+	return core.ChainMiddleware(method, process, c.options.Interceptors...)
+}
+
+// roundTrip returns the round trip of a model request to method, checked
+// against the server's schema first when local validation is on.
+func (c *Client) roundTrip(method string) core.ProcessFunc {
+	return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		validated, err := c.validateLocally(ctx, method, req)
+		if err != nil {
+			return nil, err
+		}
+		var resp core.ModelResponse
+		if err := c.call(ctx, method, req, &resp); err != nil {
+			if validated {
+				c.checkDrift(method, req.ID, err)
+			}
+			return nil, err
+		}
+		return &resp, nil
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// negotiate asks the server over conn, before any other traffic, to
+// compress messages and encode them with the configured codec, and returns
+// the connection to use from then on with the codec the server agreed to. A
+// server that rejects the request leaves the connection plain JSON.
+func (c *Client) negotiate(ctx context.Context, conn net.Conn) (net.Conn, core.FrameCodec, error) {
+	chosen := core.FrameCodec{Threshold: c.options.CompressionThreshold}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	offer := core.NegotiateRequest{Compression: c.compressions()}
+	if c.options.Codec != nil {
+		offer.Codecs = []string{c.options.Codec.ContentType()}
+	}
+	params, err := json.Marshal(offer)
+	if err != nil {
+		return nil, chosen, err
+	}
+	raw := json.RawMessage(params)
+	codec := jsonrpc2.VSCodeObjectCodec{}
+	req := &jsonrpc2.Request{Method: core.MethodNegotiate, Params: &raw}
+	if err := codec.WriteObject(conn, req); err != nil {
+		return nil, chosen, err
+	}
+
+	// The reply is read through a buffer that the connection drains first, so
+	// nothing the server sends after it is lost
+	r := bufio.NewReader(conn)
+	for {
+		var resp struct {
+			ID     *jsonrpc2.ID     `json:"id"`
+			Method string           `json:"method"`
+			Result *json.RawMessage `json:"result"`
+			Error  *jsonrpc2.Error  `json:"error"`
+		}
+		if err := codec.ReadObject(r, &resp); err != nil {
+			return nil, chosen, err
+		}
+		if resp.Method != "" || resp.ID == nil || *resp.ID != req.ID {
+			c.options.Logger.Debug("Dropping message received before negotiation", core.LogFieldMethod, resp.Method)
+			continue
+		}
+
+		conn = &bufferedConn{Conn: conn, r: r}
+		if resp.Error != nil || resp.Result == nil {
+			c.options.Logger.Debug("Server does not negotiate, continuing with plain JSON", core.LogFieldRemoteAddr, conn.RemoteAddr().String())
+			return conn, chosen, nil
+		}
+
+		var result core.NegotiateResponse
+		if err := json.Unmarshal(*resp.Result, &result); err != nil {
+			return nil, chosen, fmt.Errorf("invalid negotiation reply: %w", err)
+		}
+		if result.Compression != core.CompressionNone && !slices.Contains(offer.Compression, result.Compression) {
+			return nil, chosen, fmt.Errorf("server chose compression %q, which was not offered", result.Compression)
+		}
+		chosen.Compression = result.Compression
+		switch {
+		case result.Codec == "":
+			if c.options.Codec != nil && c.options.Codec.ContentType() != core.ContentTypeJSON {
+				c.options.Logger.Info("Server does not support the codec, falling back to JSON",
+					core.LogFieldRemoteAddr, conn.RemoteAddr().String(), "codec", c.options.Codec.ContentType())
+			}
+		case c.options.Codec != nil && result.Codec == c.options.Codec.ContentType():
+			chosen.Codec = c.options.Codec
+		default:
+			return nil, chosen, fmt.Errorf("server chose codec %q, which was not offered", result.Codec)
+		}
+		return conn, chosen, nil
+	}
+}
+
+// compressions returns the configured algorithms to offer the server, most
+// preferred first, skipping those not registered with core.RegisterCompressor.
+func (c *Client) compressions() []core.Compression {
+	var offered []core.Compression
+	for _, algorithm := range core.CompressionPreference(c.options.Compression, c.options.CompressionFallbacks) {
+		if !algorithm.Supported() {
+			c.options.Logger.Warn("Skipping unregistered compression", "compression", algorithm)
+			continue
+		}
+		offered = append(offered, algorithm)
+	}
+	return offered
+}
+
+// bufferedConn is a net.Conn whose reads drain the negotiation buffer first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read implements io.Reader.
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// limitedCodec reads frames with a size limit, turning an oversized response
+// into an error reply to its call so that the connection survives it, and
+// compresses the messages it writes from the tuned threshold.
+type limitedCodec struct {
+	core.FrameCodec
+	logger core.Logger
+	tuner  *linkTuner
+}
+
+// WriteObject implements jsonrpc2.ObjectCodec.
+func (c *limitedCodec) WriteObject(stream io.Writer, obj interface{}) error {
+	frames := c.FrameCodec
+	frames.Threshold = c.tuner.compressionThreshold()
+	return frames.WriteObject(stream, obj)
+}
+
+// ReadObject implements jsonrpc2.ObjectCodec.
+func (c *limitedCodec) ReadObject(stream *bufio.Reader, v interface{}) error {
+	for {
+		err := c.FrameCodec.ReadObject(stream, v)
+		var tooLarge *core.FrameTooLargeError
+		if !errors.As(err, &tooLarge) {
+			return err
+		}
+		c.logger.Warn("Dropping message over the size limit", "size", tooLarge.Size, "limit", tooLarge.Limit)
+		if tooLarge.ID == nil {
+			continue
+		}
+		reply, err := json.Marshal(&jsonrpc2.Response{ID: *tooLarge.ID, Error: tooLarge.RPCError()})
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(reply, v)
+	}
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Notify sends a notification described by n to the server. Notifications
+// all go over the client's first pooled connection, so they arrive in order.
+func Notify[T any](ctx context.Context, c *Client, n core.Notification[T], payload T) error {
+	if err := n.Check(); err != nil {
+		return err
+	}
+
+	conn := c.primary()
+	if conn == nil {
+		return errors.New("not connected to server")
+	}
+	return conn.Notify(ctx, n.Method(), payload)
+}
+
+// OnNotificationTyped registers handler for notifications described by n
+// that the server sends. Handlers run on their own goroutines. Payloads that
+// fail to decode are reported to the callbacks registered with
+// Client.OnNotificationError instead of being dropped.
+func OnNotificationTyped[T any](c *Client, n core.Notification[T], handler func(ctx context.Context, payload T)) {
+	core.HandleNotification(c.notifications, n, handler)
+}
+
+// OnNotification registers a handler for server notifications that have no
+// typed handler, receiving the raw payload.
+func (c *Client) OnNotification(handler func(ctx context.Context, method string, params json.RawMessage)) {
+	c.notifications.OnUnknown(handler)
+}
+
+// OnNotificationError registers a callback for server notifications whose
+// payload could not be decoded.
+func (c *Client) OnNotificationError(callback func(*core.NotificationError)) {
+	c.notifications.OnError(callback)
+}
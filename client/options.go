@@ -1,39 +1,275 @@
 // Package client provides a client implementation for the Model Context Protocol (MCP).
 package client
 
-import "time"
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
 
 // Options holds configuration parameters for the MCP client.
 // It defines connection settings, reconnection behavior, and security options.
 type Options struct {
-	ServerHost           string        // Hostname or IP address of the MCP server
-	ServerPort           int           // TCP port of the MCP server
-	ConnectionTimeout    time.Duration // Timeout for establishing a connection
-	AutoReconnect        bool          // Whether to automatically attempt reconnection on disconnect
-	MaxReconnectAttempts int           // Maximum number of reconnection attempts before giving up
-	ReconnectDelay       time.Duration // Time to wait between reconnection attempts
-	EnableTLS            bool          // Whether to use TLS for server connections
+	Name                       string                   // Names the client in the Source of its status change events
+	Logger                     core.Logger              // Receives structured log records; defaults to slog.Default
+	Metrics                    core.MetricsCollector    // Receives connection and call measurements
+	DefaultMetadata            map[string]string        // Metadata added to every request unless already set
+	Tracer                     core.Tracer              // Starts a span around every call to the server
+	TaskBudgets                map[core.TaskFeature]int // Per-feature goroutine and timer limits that log a warning when exceeded
+	Transport                  core.Transport           // Network used to reach the server; defaults to TCP on ServerHost:ServerPort
+	ServerHost                 string                   // Hostname or IP address of the MCP server
+	ServerPort                 int                      // TCP port of the MCP server
+	ConnectionTimeout          time.Duration            // Timeout for establishing a connection
+	ConnectionPoolSize         int                      // Number of connections calls are spread over
+	AutoReconnect              bool                     // Whether to automatically attempt reconnection on disconnect
+	MaxReconnectAttempts       int                      // Maximum number of reconnection attempts before giving up
+	ReconnectDelay             time.Duration            // Time to wait between reconnection attempts
+	MaxReconnectDelay          time.Duration            // Longest wait, which doubles from ReconnectDelay with each attempt; zero keeps ReconnectDelay fixed
+	RequestTimeout             time.Duration            // Time a call whose context has no deadline waits for its reply; zero waits as long as the context allows
+	ReadTimeout                time.Duration            // Drop connections the server sends nothing on for this long; zero disables
+	WriteTimeout               time.Duration            // Drop connections whose writes the server takes nothing of for this long; zero disables
+	RetryAttempts              int                      // Times ProcessModel is sent again after its connection fails; zero disables retries
+	RetryDelay                 time.Duration            // Time to wait before each retry
+	RetryAttemptTimeout        time.Duration            // Time an attempt waits for its reply before a retry is sent alongside it; zero retries only failed attempts
+	EnableTLS                  bool                     // Whether to use TLS for server connections
+	TLSConfig                  *tls.Config              // TLS settings used when EnableTLS is set; nil uses system defaults
+	TLSSessionResumption       bool                     // Whether to resume TLS sessions on reconnect instead of a full handshake
+	HeartbeatInterval          time.Duration            // Interval between keepalive pings; zero disables heartbeats
+	HeartbeatTimeout           time.Duration            // Time to wait for a pong before counting the ping as missed
+	MaxMissedHeartbeats        int                      // Consecutive missed pongs before the connection is closed
+	AuthScheme                 string                   // Auth scheme to authenticate with after connecting; empty disables auth
+	AuthCredentials            CredentialsFunc          // Supplies credentials for AuthScheme on each authentication
+	LocalValidation            bool                     // Whether to validate requests against the server's method schemas before sending
+	Interceptors               []core.Middleware        // Wrap every model request sent, the first outermost
+	ResponseCacheSize          int                      // Successful ProcessModel responses cached by request content; zero disables the cache
+	ResponseCacheTTL           time.Duration            // How long a cached response is used; zero keeps it until evicted
+	JobPollInterval            time.Duration            // Interval between status checks while WaitForJob waits
+	BlobChunkSize              int                      // Bytes sent or fetched in each call of UploadBlob and DownloadBlob
+	Compression                core.Compression         // Algorithm to negotiate with the server on connect; empty keeps connections plain
+	CompressionFallbacks       []core.Compression       // Algorithms to try, in order, if the server does not offer Compression
+	CompressionThreshold       int                      // Smallest message, in bytes, that is compressed
+	StaticTuning               bool                     // Keep CompressionThreshold and BlobChunkSize as configured instead of tuning them to the link
+	CompressionThresholdBounds TuningBounds             // Range the compression threshold is tuned within
+	BlobChunkSizeBounds        TuningBounds             // Range the blob chunk size is tuned within
+	TuningStateFile            string                   // File the tuned values are loaded from by New and saved to by Stop; empty keeps them in memory
+	Codec                      core.Codec               // Codec to negotiate with the server on connect; nil keeps connections JSON
+	MaxResponseBytes           int64                    // Largest message body, in bytes, the client reads; zero is unlimited
+	Features                   []core.Feature           // Features announced to the server in the handshake
+	ImportedState              *ExportedState           // State loaded by WithImportedState, whose link measurements the client starts with
+
+	importErr error // Why the document given to WithImportedState was ignored
+	addrErr   error // Why the address given to WithServerAddr was ignored
 }
 
+// CredentialsFunc returns the credentials to present when authenticating.
+// It is called on every connect and whenever the server asks the client to
+// authenticate again, so it can mint fresh signatures or tokens.
+type CredentialsFunc func(ctx context.Context) (map[string]string, error)
+
 // DefaultOptions returns the default client options.
 // These defaults provide sensible starting values that work for most local deployments,
 // with automatic reconnection enabled but limited to 3 attempts.
 func DefaultOptions() Options {
 	return Options{
-		ServerHost:           "localhost",
-		ServerPort:           5000,
-		ConnectionTimeout:    30 * time.Second,
-		AutoReconnect:        true,
-		MaxReconnectAttempts: 3,
-		ReconnectDelay:       time.Second,
-		EnableTLS:            false,
+		Logger:                     core.DefaultLogger(),
+		Metrics:                    core.NopMetrics(),
+		Tracer:                     core.NopTracer(),
+		Transport:                  core.TCPTransport{},
+		ServerHost:                 "localhost",
+		ServerPort:                 5000,
+		ConnectionTimeout:          30 * time.Second,
+		ConnectionPoolSize:         1,
+		AutoReconnect:              true,
+		MaxReconnectAttempts:       3,
+		ReconnectDelay:             time.Second,
+		EnableTLS:                  false,
+		TLSSessionResumption:       true,
+		HeartbeatInterval:          0,
+		HeartbeatTimeout:           5 * time.Second,
+		MaxMissedHeartbeats:        3,
+		JobPollInterval:            500 * time.Millisecond,
+		BlobChunkSize:              1 << 20,
+		CompressionThreshold:       1 << 10,
+		CompressionThresholdBounds: TuningBounds{Min: 256, Max: 64 << 10},
+		BlobChunkSizeBounds:        TuningBounds{Min: 64 << 10, Max: 8 << 20},
+		MaxResponseBytes:           core.DefaultMaxMessageBytes,
+		Features:                   core.AllFeatures(),
+	}
+}
+
+// clone returns a copy of the options that shares no slices, maps, TLS
+// configuration or imported state with them. Interfaces and functions, such
+// as the logger and the credentials, are shared.
+func (o Options) clone() Options {
+	o.DefaultMetadata = maps.Clone(o.DefaultMetadata)
+	o.TaskBudgets = maps.Clone(o.TaskBudgets)
+	if o.TLSConfig != nil {
+		o.TLSConfig = o.TLSConfig.Clone()
+	}
+	o.Interceptors = slices.Clone(o.Interceptors)
+	o.CompressionFallbacks = slices.Clone(o.CompressionFallbacks)
+	o.Features = slices.Clone(o.Features)
+	if o.ImportedState != nil {
+		o.ImportedState = o.ImportedState.clone()
+	}
+	return o
+}
+
+// Validate reports the settings the client cannot run with: an empty
+// ServerHost, a ServerPort outside 0-65535, auto-reconnect without a
+// positive ReconnectDelay, a MaxReconnectDelay below it, heartbeats without a positive HeartbeatTimeout or
+// MaxMissedHeartbeats, a BlobChunkSize below one, tuning bounds below one or
+// with a Min above their Max, and negative counts, sizes and durations. Each problem found is joined into the error. Start calls it
+// before connecting.
+func (o Options) Validate() error {
+	var errs []error
+	if o.ServerHost == "" {
+		errs = append(errs, errors.New("server host is empty"))
+	}
+	if o.ServerPort < 0 || o.ServerPort > 65535 {
+		errs = append(errs, fmt.Errorf("server port %d is outside 0-65535", o.ServerPort))
 	}
+	if o.AutoReconnect && o.ReconnectDelay <= 0 {
+		errs = append(errs, fmt.Errorf("auto-reconnect needs a positive reconnect delay, got %s", o.ReconnectDelay))
+	}
+	if o.MaxReconnectDelay > 0 && o.MaxReconnectDelay < o.ReconnectDelay {
+		errs = append(errs, fmt.Errorf("max reconnect delay must be at least the reconnect delay %s, got %s", o.ReconnectDelay, o.MaxReconnectDelay))
+	}
+	if o.HeartbeatInterval > 0 && o.HeartbeatTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("heartbeats need a positive heartbeat timeout, got %s", o.HeartbeatTimeout))
+	}
+	if o.HeartbeatInterval > 0 && o.MaxMissedHeartbeats < 1 {
+		errs = append(errs, fmt.Errorf("heartbeats need at least 1 max missed heartbeat, got %d", o.MaxMissedHeartbeats))
+	}
+	if o.BlobChunkSize < 1 {
+		errs = append(errs, fmt.Errorf("blob chunk size must be at least 1, got %d", o.BlobChunkSize))
+	}
+	for _, bounds := range []struct {
+		name  string
+		value TuningBounds
+	}{
+		{"compression threshold bounds", o.CompressionThresholdBounds},
+		{"blob chunk size bounds", o.BlobChunkSizeBounds},
+	} {
+		if bounds.value.Min < 1 || bounds.value.Min > bounds.value.Max {
+			errs = append(errs, fmt.Errorf("%s must be at least 1, with Min no greater than Max, got %d-%d", bounds.name, bounds.value.Min, bounds.value.Max))
+		}
+	}
+	for _, limit := range []struct {
+		name  string
+		value int64
+	}{
+		{"max reconnect attempts", int64(o.MaxReconnectAttempts)},
+		{"retry attempts", int64(o.RetryAttempts)},
+		{"connection pool size", int64(o.ConnectionPoolSize)},
+		{"response cache size", int64(o.ResponseCacheSize)},
+		{"compression threshold", int64(o.CompressionThreshold)},
+		{"max response bytes", o.MaxResponseBytes},
+	} {
+		if limit.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", limit.name, limit.value))
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"connection timeout", o.ConnectionTimeout},
+		{"heartbeat interval", o.HeartbeatInterval},
+		{"request timeout", o.RequestTimeout},
+		{"read timeout", o.ReadTimeout},
+		{"write timeout", o.WriteTimeout},
+		{"max reconnect delay", o.MaxReconnectDelay},
+		{"retry delay", o.RetryDelay},
+		{"retry attempt timeout", o.RetryAttemptTimeout},
+		{"response cache TTL", o.ResponseCacheTTL},
+		{"job poll interval", o.JobPollInterval},
+	} {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", d.name, d.value))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Option is a function type that modifies Options.
 // It implements the functional options pattern for configuring the client.
 type Option func(*Options)
 
+// WithName names the client in the core.StatusSource of its status change
+// events, telling it apart from other components, e.g. in a
+// core.StatusAggregator.
+func WithName(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+// WithLogger sets the logger for client events. Connection events are logged at
+// Debug, lifecycle changes at Info and failures at Error. Use core.NopLogger to
+// silence the client.
+func WithLogger(logger core.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithMetrics sets a collector notified of every connection to the server and
+// every outgoing call, mirroring server.WithMetrics. Heartbeat pings and
+// authentication are not counted as calls.
+func WithMetrics(collector core.MetricsCollector) Option {
+	return func(o *Options) {
+		o.Metrics = collector
+	}
+}
+
+// WithTaskBudgets turns on strict task accounting: starting a goroutine or
+// timer that takes a feature over its budget logs a warning with the stacks
+// that started the feature's live tasks. Features without a budget are only
+// counted, as they are by default. See Stats for the counts.
+func WithTaskBudgets(budgets map[core.TaskFeature]int) Option {
+	return func(o *Options) {
+		o.TaskBudgets = budgets
+	}
+}
+
+// WithTracer sets the tracer that starts a client span around ProcessModel
+// and ProcessBatch and propagates it to the server in the request metadata,
+// e.g. an otelmcp.Tracer.
+func WithTracer(tracer core.Tracer) Option {
+	return func(o *Options) {
+		o.Tracer = tracer
+	}
+}
+
+// WithDefaultMetadata sets metadata added to every model request, e.g. a tenant
+// ID. Metadata set on the request itself or carried by the call's context
+// (see core.ContextWithMetadata) takes precedence.
+func WithDefaultMetadata(md map[string]string) Option {
+	return func(o *Options) {
+		o.DefaultMetadata = md
+	}
+}
+
+// WithLocalValidation enables validating ProcessModel requests against the
+// schemas the server declares through mcp.listMethods before sending them.
+// Invalid requests fail without a round trip, with the same error the server
+// would return. Descriptions are fetched on first use and again after the
+// server announces a change.
+func WithLocalValidation(enabled bool) Option {
+	return func(o *Options) {
+		o.LocalValidation = enabled
+	}
+}
+
 // WithServerHost sets the hostname or IP address of the MCP server.
 // This can be a domain name, IPv4 address, or IPv6 address.
 func WithServerHost(host string) Option {
@@ -50,6 +286,46 @@ func WithServerPort(port int) Option {
 	}
 }
 
+// WithServerAddr sets the host and TCP port of the MCP server from an address
+// of the form "host:port", such as the one Server.Addr reports. An address
+// that cannot be parsed is ignored, with an error logged when the client is
+// created.
+func WithServerAddr(addr string) Option {
+	return func(o *Options) {
+		host, portText, err := net.SplitHostPort(addr)
+		port := 0
+		if err == nil {
+			port, err = strconv.Atoi(portText)
+		}
+		if err != nil {
+			o.addrErr = fmt.Errorf("invalid server address %q: %w", addr, err)
+			return
+		}
+		o.ServerHost, o.ServerPort = host, port
+	}
+}
+
+// WithTransport sets the transport used to reach the server, e.g. the
+// core.InProcessTransport a server in the same process listens on.
+func WithTransport(transport core.Transport) Option {
+	return func(o *Options) {
+		o.Transport = transport
+	}
+}
+
+// WithInProcessConn makes the client connect over pipe, which a server in the
+// same process got from server.NewInProcessListener and listens on.
+func WithInProcessConn(pipe *core.InProcessTransport) Option {
+	return WithTransport(pipe)
+}
+
+// WithUnixSocket makes the client dial the unix domain socket at path instead of TCP.
+func WithUnixSocket(path string) Option {
+	return func(o *Options) {
+		o.Transport = core.UnixTransport{Path: path}
+	}
+}
+
 // WithConnectionTimeout sets the maximum time to wait when connecting to the server.
 // If the connection isn't established within this time, the attempt is aborted.
 func WithConnectionTimeout(timeout time.Duration) Option {
@@ -58,6 +334,18 @@ func WithConnectionTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithConnectionPoolSize sets the number of connections the client keeps to
+// the server. Calls go to the connection with the fewest calls awaiting a
+// reply, so concurrent callers are not held up behind one connection's read
+// loop. With AutoReconnect each connection that drops is replaced on its own,
+// and calls move to the others meanwhile. Subscriptions and notifications use
+// the first connection.
+func WithConnectionPoolSize(n int) Option {
+	return func(o *Options) {
+		o.ConnectionPoolSize = n
+	}
+}
+
 // WithAutoReconnect enables or disables automatic reconnection.
 func WithAutoReconnect(enable bool) Option {
 	return func(o *Options) {
@@ -79,9 +367,304 @@ func WithReconnectDelay(delay time.Duration) Option {
 	}
 }
 
+// WithReconnectBackoff makes the delay between reconnection attempts double
+// with each attempt, from the ReconnectDelay up to max, so a server that is
+// down for long is not dialed at the rate one briefly restarting is. Zero,
+// the default, waits ReconnectDelay before every attempt.
+func WithReconnectBackoff(max time.Duration) Option {
+	return func(o *Options) {
+		o.MaxReconnectDelay = max
+	}
+}
+
+// WithRequestTimeout bounds calls made with a context that has no deadline,
+// such as context.Background(), so they fail instead of waiting forever on a
+// server that stopped answering. It covers every call to the server; a
+// ProcessModelStream is bounded only until its first chunk arrives, and a
+// multi-call operation such as UploadBlob gets it for each call. A deadline
+// on the context always wins. A call that runs out of time fails with a
+// *RequestTimeoutError, which wraps context.DeadlineExceeded. Zero, the
+// default, sets no timeout.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.RequestTimeout = timeout
+	}
+}
+
+// WithReadTimeout sets how long a connection may go without anything read
+// from the server before it is dropped, so a server that has gone without
+// closing its socket does not leave the client waiting forever. The deadline
+// is renewed for every read, so pair it with a shorter WithHeartbeatInterval
+// to keep quiet connections to a healthy server open. The dropped connection
+// is replaced if AutoReconnect is set. Zero, the default, disables the check.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ReadTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets how long a write to the server may wait for it to
+// be taken before the connection is dropped, so a call does not block
+// forever sending to a server that stopped reading. The deadline is renewed
+// for every write, so quiet connections are not affected. The dropped
+// connection is replaced if AutoReconnect is set. Zero, the default,
+// disables the check.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.WriteTimeout = timeout
+	}
+}
+
+// WithRetry makes ProcessModel send a request again, up to attempts more
+// times, waiting delay before each, when the connection carrying it fails
+// before the reply arrives. Failures the server reports are not retried.
+// Every request then carries a core.MetadataIdempotencyKey, the same on each
+// attempt, so a server with middleware.Idempotency runs it once even if the
+// reply, not the request, was lost. Pair it with auto-reconnect, and a delay
+// no shorter than the reconnect delay, so that retries find a connection.
+func WithRetry(attempts int, delay time.Duration) Option {
+	return func(o *Options) {
+		o.RetryAttempts = attempts
+		o.RetryDelay = delay
+	}
+}
+
+// WithRetryAttemptTimeout makes a ProcessModel attempt that has had no reply
+// for timeout be sent again, within the attempts allowed by WithRetry,
+// while it keeps waiting. The first attempt to be answered resolves the
+// call; later answers are dropped and counted in Stats.DuplicatesDropped.
+// Zero, the default, retries an attempt only once its connection fails.
+func WithRetryAttemptTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.RetryAttemptTimeout = timeout
+	}
+}
+
+// WithTLSEnabled controls whether connections use TLS, with the TLSConfig
+// set by WithTLSConfig or, without one, the system's certificate authorities.
+func WithTLSEnabled(enabled bool) Option {
+	return func(o *Options) {
+		o.EnableTLS = enabled
+	}
+}
+
 // WithTLS enables TLS.
+//
+// Deprecated: Use WithTLSEnabled(true).
 func WithTLS() Option {
+	return WithTLSEnabled(true)
+}
+
+// WithTLSConfig enables TLS using the given configuration, for example to
+// trust a private certificate authority via RootCAs.
+func WithTLSConfig(config *tls.Config) Option {
 	return func(o *Options) {
 		o.EnableTLS = true
+		o.TLSConfig = config
+	}
+}
+
+// WithTLSSessionResumption controls whether reconnects resume the previous TLS
+// session, which skips most of the handshake. It is enabled by default; disable it
+// for deployments that require a full handshake on every connection. A
+// ClientSessionCache set on the TLSConfig takes precedence over the built-in cache.
+func WithTLSSessionResumption(enabled bool) Option {
+	return func(o *Options) {
+		o.TLSSessionResumption = enabled
+	}
+}
+
+// WithAuth authenticates with the named scheme using fixed credentials,
+// e.g. {"token": "..."} for a static token scheme.
+func WithAuth(scheme string, credentials map[string]string) Option {
+	return WithAuthProvider(scheme, func(context.Context) (map[string]string, error) {
+		return credentials, nil
+	})
+}
+
+// WithAuthToken authenticates with a bearer token under core.AuthSchemeToken
+// right after every connect, including reconnects.
+func WithAuthToken(token string) Option {
+	return WithAuth(core.AuthSchemeToken, map[string]string{core.CredentialToken: token})
+}
+
+// WithAuthProvider authenticates with the named scheme using credentials
+// produced on demand, e.g. HMAC signatures over the current time.
+func WithAuthProvider(scheme string, credentials CredentialsFunc) Option {
+	return func(o *Options) {
+		o.AuthScheme = scheme
+		o.AuthCredentials = credentials
+	}
+}
+
+// WithHeartbeatInterval enables keepalive pings sent at the given interval.
+// This detects half-open connections that would otherwise go unnoticed until
+// the next request hangs. Zero disables heartbeats.
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.HeartbeatInterval = interval
+	}
+}
+
+// WithHeartbeatTimeout sets how long to wait for each ping to be answered.
+func WithHeartbeatTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.HeartbeatTimeout = timeout
+	}
+}
+
+// WithMaxMissedHeartbeats sets the number of consecutive unanswered pings after
+// which the connection is considered dead, closed, and handed to auto-reconnect.
+func WithMaxMissedHeartbeats(max int) Option {
+	return func(o *Options) {
+		o.MaxMissedHeartbeats = max
+	}
+}
+
+// WithJobPollInterval sets how often WaitForJob asks the server for the status
+// of the job it waits for.
+func WithJobPollInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.JobPollInterval = interval
+	}
+}
+
+// WithBlobChunkSize sets how many bytes each call of UploadBlob and
+// DownloadBlob carries at first, before it is tuned to the link. Chunks are
+// base64 encoded in JSON, so they must stay well within the peer's message
+// size limit. The default is 1MiB.
+func WithBlobChunkSize(bytes int) Option {
+	return func(o *Options) {
+		o.BlobChunkSize = bytes
+	}
+}
+
+// WithCompression asks the server to compress messages with the first of
+// the given algorithms it offers, e.g. mcpcompress.Zstd then
+// core.CompressionGzip, negotiated on every connect. Algorithms this client
+// has not registered are skipped. A server that offers none of them, or
+// predates compression, is talked to plain; see ConnectionState for the
+// outcome.
+func WithCompression(algorithms ...core.Compression) Option {
+	return func(o *Options) {
+		o.Compression, o.CompressionFallbacks = core.CompressionNone, nil
+		if len(algorithms) > 0 {
+			o.Compression, o.CompressionFallbacks = algorithms[0], algorithms[1:]
+		}
+	}
+}
+
+// WithMaxResponseBytes sets the largest message body, in bytes, the client
+// reads. A larger response is discarded unread and the call fails with
+// core.CodeRequestTooLarge, leaving the connection usable; larger
+// notifications are dropped. The default is core.DefaultMaxMessageBytes; zero
+// disables the limit.
+func WithMaxResponseBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxResponseBytes = n
+	}
+}
+
+// WithCodec asks the server to encode messages with codec, e.g.
+// msgpack.Codec, negotiated on every connect. A server that does not offer the
+// codec is talked to in JSON, and the fallback is logged.
+func WithCodec(codec core.Codec) Option {
+	return func(o *Options) {
+		o.Codec = codec
+	}
+}
+
+// WithCompressionThreshold sets the size, in bytes, below which messages are
+// sent plain on compressed connections, until it is tuned to the link. The
+// default is 1KiB.
+func WithCompressionThreshold(bytes int) Option {
+	return func(o *Options) {
+		o.CompressionThreshold = bytes
+	}
+}
+
+// WithStaticTuning keeps the compression threshold and the blob chunk size
+// at the values set by WithCompressionThreshold and WithBlobChunkSize, for
+// links whose best settings are known or tests that count messages. By
+// default the client tunes them to the link: calls on a link slower than
+// 1MiB/s halve the threshold, so more messages are compressed, and faster
+// ones raise it by 256 bytes; blob chunks taking longer than a second, or
+// lost with their connection, halve the chunk size, and quicker ones raise
+// it by 64KiB. Stats reports the values in use.
+func WithStaticTuning() Option {
+	return func(o *Options) {
+		o.StaticTuning = true
+	}
+}
+
+// WithTuningBounds sets the ranges the compression threshold and the blob
+// chunk size are tuned within. The configured values are moved into them.
+// The defaults are 256B-64KiB for the threshold and 64KiB-8MiB for chunks.
+func WithTuningBounds(compressionThreshold, blobChunkSize TuningBounds) Option {
+	return func(o *Options) {
+		o.CompressionThresholdBounds, o.BlobChunkSizeBounds = compressionThreshold, blobChunkSize
+	}
+}
+
+// WithTuningStateFile keeps the tuned compression threshold and blob chunk
+// size in the file at path, so a restarted client starts from what it
+// learned about its link rather than from the configured values. New loads
+// the file if it exists, and Stop saves it.
+func WithTuningStateFile(path string) Option {
+	return func(o *Options) {
+		o.TuningStateFile = path
+	}
+}
+
+// WithInterceptors adds middleware wrapping every model request the client
+// sends with ProcessModel and ProcessModelStream. Interceptors run in the
+// order given, the first outermost, after the OnBeforeSend hooks and before
+// local validation, and see the response before the OnAfterReceive hooks.
+func WithInterceptors(interceptors ...core.Middleware) Option {
+	return func(o *Options) {
+		o.Interceptors = append(o.Interceptors, interceptors...)
+	}
+}
+
+// WithResponseCache caches the responses to ProcessModel, so a request with
+// the same model data and parameters as an earlier one is answered without a
+// round trip. Up to maxEntries responses are kept, the least recently used
+// dropped first, each for ttl, or until dropped if ttl is zero. Only
+// successful responses are cached, and the request ID and metadata play no
+// part in matching, so handlers whose results depend on metadata such as the
+// tenant should not be cached. A request can set core.MetadataCache to
+// core.CacheBypass or core.CacheRefresh to skip the cached response.
+func WithResponseCache(maxEntries int, ttl time.Duration) Option {
+	return func(o *Options) {
+		o.ResponseCacheSize = maxEntries
+		o.ResponseCacheTTL = ttl
+	}
+}
+
+// WithFeatures sets the features the client announces in the handshake on
+// every connect. Calls needing a feature left out, or one the server does not
+// announce, fail with core.ErrUnsupportedCapability without being sent.
+func WithFeatures(features ...core.Feature) Option {
+	return func(o *Options) {
+		o.Features = features
+	}
+}
+
+// WithImportedState configures the client from a document written by
+// Client.ExportState, as if its recorded options were set at this point of
+// the option list, and starts the client with the link measurements it
+// records. Settings the document records only by name, such as the
+// transport, the codec and the credentials, must be set again with their
+// options. A document that cannot be parsed is ignored, with an error logged
+// when the client is created; ParseState reports why.
+func WithImportedState(data []byte) Option {
+	return func(o *Options) {
+		state, err := decodeState(data, *o)
+		if err != nil {
+			o.importErr = err
+			return
+		}
+		importOptions(o, state.Options)
+		o.ImportedState = state
 	}
 }
@@ -1,23 +1,217 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/metrics"
+	"github.com/narcolepticfox/mcp/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDefaultOptions(t *testing.T) {
 	options := DefaultOptions()
 
 	// Check that default options are set correctly
+	assert.Equal(t, core.DefaultLogger(), options.Logger, "Default Logger should write to slog")
+	assert.Equal(t, core.NopMetrics(), options.Metrics, "Default Metrics should discard measurements")
+	assert.Equal(t, core.TCPTransport{}, options.Transport, "Default Transport should be TCP")
 	assert.Equal(t, "localhost", options.ServerHost, "Default ServerHost should be localhost")
 	assert.Equal(t, 5000, options.ServerPort, "Default ServerPort should be 5000")
 	assert.Equal(t, 30*time.Second, options.ConnectionTimeout, "Default ConnectionTimeout should be 30s")
+	assert.Equal(t, 1, options.ConnectionPoolSize, "Default ConnectionPoolSize should be 1")
 	assert.True(t, options.AutoReconnect, "Default AutoReconnect should be true")
 	assert.Equal(t, 3, options.MaxReconnectAttempts, "Default MaxReconnectAttempts should be 3")
 	assert.Equal(t, time.Second, options.ReconnectDelay, "Default ReconnectDelay should be 1s")
 	assert.False(t, options.EnableTLS, "Default EnableTLS should be false")
+	assert.True(t, options.TLSSessionResumption, "Default TLSSessionResumption should be true")
+	assert.Empty(t, options.AuthScheme, "Default AuthScheme should be empty")
+	assert.False(t, options.LocalValidation, "Default LocalValidation should be false")
+	assert.Zero(t, options.ResponseCacheSize, "Default should not cache responses")
+	assert.Zero(t, options.HeartbeatInterval, "Default HeartbeatInterval should disable heartbeats")
+	assert.Equal(t, 5*time.Second, options.HeartbeatTimeout, "Default HeartbeatTimeout should be 5s")
+	assert.Equal(t, 3, options.MaxMissedHeartbeats, "Default MaxMissedHeartbeats should be 3")
+	assert.Equal(t, 500*time.Millisecond, options.JobPollInterval, "Default JobPollInterval should be 500ms")
+	assert.Equal(t, 1<<20, options.BlobChunkSize, "Default BlobChunkSize should be 1MiB")
+	assert.Equal(t, core.CompressionNone, options.Compression, "Default Compression should be none")
+	assert.Empty(t, options.CompressionFallbacks, "Default CompressionFallbacks should be empty")
+	assert.Equal(t, 1024, options.CompressionThreshold, "Default CompressionThreshold should be 1KiB")
+	assert.Nil(t, options.Codec, "Default Codec should be nil")
+	assert.Equal(t, core.DefaultMaxMessageBytes, options.MaxResponseBytes, "Default MaxResponseBytes should be 32MiB")
+	assert.Empty(t, options.DefaultMetadata, "Default DefaultMetadata should be empty")
+	assert.Equal(t, core.NopTracer(), options.Tracer, "Default Tracer should record nothing")
+	assert.Empty(t, options.TaskBudgets, "Default TaskBudgets should not limit any feature")
+	assert.Nil(t, options.ImportedState, "Default ImportedState should be nil")
+}
+
+func TestWithName(t *testing.T) {
+	options := DefaultOptions()
+	assert.Empty(t, options.Name, "Default Name should be empty")
+
+	WithName("primary")(&options)
+	assert.Equal(t, "primary", options.Name, "Name should be updated")
+}
+
+func TestWithLogger(t *testing.T) {
+	options := DefaultOptions()
+	logger := testutil.NewCaptureLogger()
+	option := WithLogger(logger)
+	option(&options)
+
+	assert.Same(t, logger, options.Logger, "Logger should be updated")
+}
+
+func TestWithMetrics(t *testing.T) {
+	options := DefaultOptions()
+	collector := metrics.NewExpvarCollector("mcp_options_test_client")
+	option := WithMetrics(collector)
+	option(&options)
+
+	assert.Same(t, collector, options.Metrics, "Metrics should be updated")
+}
+
+func TestWithAuthToken(t *testing.T) {
+	options := DefaultOptions()
+	option := WithAuthToken("secret")
+	option(&options)
+
+	assert.Equal(t, core.AuthSchemeToken, options.AuthScheme, "AuthScheme should be the token scheme")
+	credentials, err := options.AuthCredentials(context.Background())
+	assert.NoError(t, err, "Credentials should be available")
+	assert.Equal(t, map[string]string{core.CredentialToken: "secret"}, credentials, "Credentials should carry the token")
+}
+
+func TestWithLocalValidation(t *testing.T) {
+	options := DefaultOptions()
+	option := WithLocalValidation(true)
+	option(&options)
+
+	assert.True(t, options.LocalValidation, "LocalValidation should be enabled")
+}
+
+func TestWithJobPollInterval(t *testing.T) {
+	options := DefaultOptions()
+	option := WithJobPollInterval(50 * time.Millisecond)
+	option(&options)
+
+	assert.Equal(t, 50*time.Millisecond, options.JobPollInterval, "JobPollInterval should be updated")
+}
+
+func TestWithBlobChunkSize(t *testing.T) {
+	options := DefaultOptions()
+	option := WithBlobChunkSize(64 << 10)
+	option(&options)
+
+	assert.Equal(t, 64<<10, options.BlobChunkSize, "BlobChunkSize should be updated")
+}
+
+func TestWithCompression(t *testing.T) {
+	options := DefaultOptions()
+	option := WithCompression(core.CompressionGzip)
+	option(&options)
+
+	assert.Equal(t, core.CompressionGzip, options.Compression, "Compression should be updated")
+	assert.Empty(t, options.CompressionFallbacks, "A single algorithm should have no fallbacks")
+
+	WithCompression("zstd", core.CompressionGzip)(&options)
+	assert.Equal(t, core.Compression("zstd"), options.Compression, "First algorithm should be preferred")
+	assert.Equal(t, []core.Compression{core.CompressionGzip}, options.CompressionFallbacks, "Later algorithms should be fallbacks")
+
+	WithCompression()(&options)
+	assert.Equal(t, core.CompressionNone, options.Compression, "No algorithms should disable compression")
+	assert.Empty(t, options.CompressionFallbacks, "No algorithms should leave no fallbacks")
+}
+
+func TestWithCompressionThreshold(t *testing.T) {
+	options := DefaultOptions()
+	option := WithCompressionThreshold(4096)
+	option(&options)
+
+	assert.Equal(t, 4096, options.CompressionThreshold, "CompressionThreshold should be updated")
+}
+
+func TestWithFeatures(t *testing.T) {
+	options := DefaultOptions()
+	option := WithFeatures(core.FeatureBatch)
+	option(&options)
+
+	assert.Equal(t, []core.Feature{core.FeatureBatch}, options.Features, "Features should be updated")
+}
+
+func TestWithResponseCache(t *testing.T) {
+	options := DefaultOptions()
+	option := WithResponseCache(100, time.Minute)
+	option(&options)
+
+	assert.Equal(t, 100, options.ResponseCacheSize, "ResponseCacheSize should be updated")
+	assert.Equal(t, time.Minute, options.ResponseCacheTTL, "ResponseCacheTTL should be updated")
+}
+
+func TestWithInterceptors(t *testing.T) {
+	options := DefaultOptions()
+	nop := func(method string, next core.ProcessFunc) core.ProcessFunc { return next }
+	WithInterceptors(nop)(&options)
+	WithInterceptors(nop, nop)(&options)
+
+	assert.Len(t, options.Interceptors, 3, "Interceptors should be appended")
+}
+
+func TestWithMaxResponseBytes(t *testing.T) {
+	options := DefaultOptions()
+	option := WithMaxResponseBytes(0)
+	option(&options)
+
+	assert.Zero(t, options.MaxResponseBytes, "MaxResponseBytes should be disabled")
+}
+
+func TestWithCodec(t *testing.T) {
+	options := DefaultOptions()
+	option := WithCodec(core.JSONCodec)
+	option(&options)
+
+	assert.Equal(t, core.JSONCodec, options.Codec, "Codec should be updated")
+}
+
+func TestWithImportedState(t *testing.T) {
+	options := DefaultOptions()
+	option := WithImportedState([]byte(`{"version": 1, "options": {"serverPort": 7000}}`))
+	option(&options)
+
+	assert.NotNil(t, options.ImportedState, "ImportedState should be set")
+	assert.Equal(t, 7000, options.ServerPort, "Imported options should be applied")
+	assert.Equal(t, "localhost", options.ServerHost, "Options missing from the state should be kept")
+}
+
+func TestWithTracer(t *testing.T) {
+	options := DefaultOptions()
+	tracer := &struct{ core.Tracer }{core.NopTracer()}
+	option := WithTracer(tracer)
+	option(&options)
+
+	assert.Same(t, tracer, options.Tracer, "Tracer should be updated")
+}
+
+func TestWithTaskBudgets(t *testing.T) {
+	options := DefaultOptions()
+	budgets := map[core.TaskFeature]int{core.TaskEvents: 4}
+	option := WithTaskBudgets(budgets)
+	option(&options)
+
+	assert.Equal(t, budgets, options.TaskBudgets, "TaskBudgets should be updated")
+}
+
+func TestWithDefaultMetadata(t *testing.T) {
+	options := DefaultOptions()
+	md := map[string]string{core.MetadataTenantID: "acme"}
+	option := WithDefaultMetadata(md)
+	option(&options)
+
+	assert.Equal(t, md, options.DefaultMetadata, "DefaultMetadata should be updated")
 }
 
 func TestWithServerHost(t *testing.T) {
@@ -36,6 +230,50 @@ func TestWithServerPort(t *testing.T) {
 	assert.Equal(t, 9999, options.ServerPort, "ServerPort should be updated")
 }
 
+func TestWithServerAddr(t *testing.T) {
+	options := DefaultOptions()
+	option := WithServerAddr("[::1]:9999")
+	option(&options)
+
+	assert.Equal(t, "::1", options.ServerHost, "ServerHost should be updated")
+	assert.Equal(t, 9999, options.ServerPort, "ServerPort should be updated")
+	assert.NoError(t, options.addrErr, "Valid address should be accepted")
+
+	// An address without a numeric port leaves the server unchanged
+	option = WithServerAddr("example.com:http")
+	option(&options)
+
+	assert.Equal(t, "::1", options.ServerHost, "ServerHost should be unchanged")
+	assert.Equal(t, 9999, options.ServerPort, "ServerPort should be unchanged")
+	assert.Error(t, options.addrErr, "Invalid address should be reported")
+}
+
+func TestWithTransport(t *testing.T) {
+	options := DefaultOptions()
+	transport := core.NewInProcessTransport()
+	option := WithTransport(transport)
+	option(&options)
+
+	assert.Same(t, transport, options.Transport, "Transport should be updated")
+}
+
+func TestWithInProcessConn(t *testing.T) {
+	options := DefaultOptions()
+	pipe := core.NewInProcessTransport()
+	option := WithInProcessConn(pipe)
+	option(&options)
+
+	assert.Same(t, pipe, options.Transport, "Transport should be the pipe")
+}
+
+func TestWithUnixSocket(t *testing.T) {
+	options := DefaultOptions()
+	option := WithUnixSocket("/tmp/mcp.sock")
+	option(&options)
+
+	assert.Equal(t, core.UnixTransport{Path: "/tmp/mcp.sock"}, options.Transport, "Transport should be a unix socket")
+}
+
 func TestWithConnectionTimeout(t *testing.T) {
 	options := DefaultOptions()
 	timeout := 10 * time.Second
@@ -45,6 +283,14 @@ func TestWithConnectionTimeout(t *testing.T) {
 	assert.Equal(t, timeout, options.ConnectionTimeout, "ConnectionTimeout should be updated")
 }
 
+func TestWithConnectionPoolSize(t *testing.T) {
+	options := DefaultOptions()
+	option := WithConnectionPoolSize(4)
+	option(&options)
+
+	assert.Equal(t, 4, options.ConnectionPoolSize, "ConnectionPoolSize should be updated")
+}
+
 func TestWithAutoReconnect(t *testing.T) {
 	options := DefaultOptions()
 	option := WithAutoReconnect(false)
@@ -70,6 +316,84 @@ func TestWithReconnectDelay(t *testing.T) {
 	assert.Equal(t, delay, options.ReconnectDelay, "ReconnectDelay should be updated")
 }
 
+func TestWithRequestTimeout(t *testing.T) {
+	options := DefaultOptions()
+	assert.Zero(t, options.RequestTimeout, "Calls should not time out by default")
+
+	WithRequestTimeout(10 * time.Second)(&options)
+	assert.Equal(t, 10*time.Second, options.RequestTimeout, "RequestTimeout should be updated")
+}
+
+func TestWithReadTimeout(t *testing.T) {
+	options := DefaultOptions()
+	assert.Zero(t, options.ReadTimeout, "Reads should not time out by default")
+
+	WithReadTimeout(30 * time.Second)(&options)
+	assert.Equal(t, 30*time.Second, options.ReadTimeout, "ReadTimeout should be updated")
+}
+
+func TestWithStaticTuning(t *testing.T) {
+	options := DefaultOptions()
+	assert.False(t, options.StaticTuning, "Values should be tuned to the link by default")
+
+	WithStaticTuning()(&options)
+	assert.True(t, options.StaticTuning, "StaticTuning should be updated")
+}
+
+func TestWithTuningBounds(t *testing.T) {
+	options := DefaultOptions()
+	threshold, chunkSize := TuningBounds{Min: 128, Max: 4096}, TuningBounds{Min: 1 << 10, Max: 1 << 20}
+	WithTuningBounds(threshold, chunkSize)(&options)
+
+	assert.Equal(t, threshold, options.CompressionThresholdBounds, "CompressionThresholdBounds should be updated")
+	assert.Equal(t, chunkSize, options.BlobChunkSizeBounds, "BlobChunkSizeBounds should be updated")
+}
+
+func TestWithTuningStateFile(t *testing.T) {
+	options := DefaultOptions()
+	WithTuningStateFile("/var/lib/mcp/tuning.json")(&options)
+
+	assert.Equal(t, "/var/lib/mcp/tuning.json", options.TuningStateFile, "TuningStateFile should be updated")
+}
+
+func TestWithReconnectBackoff(t *testing.T) {
+	options := DefaultOptions()
+	assert.Zero(t, options.MaxReconnectDelay, "Reconnect delays should be fixed by default")
+
+	WithReconnectBackoff(time.Minute)(&options)
+	assert.Equal(t, time.Minute, options.MaxReconnectDelay, "MaxReconnectDelay should be updated")
+}
+
+func TestWithRetry(t *testing.T) {
+	options := DefaultOptions()
+	assert.Zero(t, options.RetryAttempts, "Retries should be disabled by default")
+
+	WithRetry(3, 100*time.Millisecond)(&options)
+	assert.Equal(t, 3, options.RetryAttempts, "RetryAttempts should be updated")
+	assert.Equal(t, 100*time.Millisecond, options.RetryDelay, "RetryDelay should be updated")
+}
+
+func TestWithRetryAttemptTimeout(t *testing.T) {
+	options := DefaultOptions()
+	assert.Zero(t, options.RetryAttemptTimeout, "Slow attempts should not be retried by default")
+
+	WithRetryAttemptTimeout(2 * time.Second)(&options)
+	assert.Equal(t, 2*time.Second, options.RetryAttemptTimeout, "RetryAttemptTimeout should be updated")
+}
+
+func TestWithTLSEnabled(t *testing.T) {
+	options := DefaultOptions()
+	config := &tls.Config{ServerName: "mcp.example.com"}
+	WithTLSConfig(config)(&options)
+	WithTLSEnabled(false)(&options)
+
+	assert.False(t, options.EnableTLS, "EnableTLS should be updated")
+	assert.Same(t, config, options.TLSConfig, "Disabling TLS should keep the TLSConfig")
+
+	WithTLSEnabled(true)(&options)
+	assert.True(t, options.EnableTLS, "EnableTLS should be updated")
+}
+
 func TestWithTLS(t *testing.T) {
 	options := DefaultOptions()
 	option := WithTLS()
@@ -78,6 +402,48 @@ func TestWithTLS(t *testing.T) {
 	assert.True(t, options.EnableTLS, "EnableTLS should be updated")
 }
 
+func TestWithTLSConfig(t *testing.T) {
+	options := DefaultOptions()
+	config := &tls.Config{ServerName: "mcp.example.com"}
+	option := WithTLSConfig(config)
+	option(&options)
+
+	assert.True(t, options.EnableTLS, "EnableTLS should be updated")
+	assert.Same(t, config, options.TLSConfig, "TLSConfig should be updated")
+}
+
+func TestWithTLSSessionResumption(t *testing.T) {
+	options := DefaultOptions()
+	option := WithTLSSessionResumption(false)
+	option(&options)
+
+	assert.False(t, options.TLSSessionResumption, "TLSSessionResumption should be updated")
+}
+
+func TestWithHeartbeatInterval(t *testing.T) {
+	options := DefaultOptions()
+	option := WithHeartbeatInterval(15 * time.Second)
+	option(&options)
+
+	assert.Equal(t, 15*time.Second, options.HeartbeatInterval, "HeartbeatInterval should be updated")
+}
+
+func TestWithHeartbeatTimeout(t *testing.T) {
+	options := DefaultOptions()
+	option := WithHeartbeatTimeout(2 * time.Second)
+	option(&options)
+
+	assert.Equal(t, 2*time.Second, options.HeartbeatTimeout, "HeartbeatTimeout should be updated")
+}
+
+func TestWithMaxMissedHeartbeats(t *testing.T) {
+	options := DefaultOptions()
+	option := WithMaxMissedHeartbeats(5)
+	option(&options)
+
+	assert.Equal(t, 5, options.MaxMissedHeartbeats, "MaxMissedHeartbeats should be updated")
+}
+
 func TestOptionChaining(t *testing.T) {
 	// Test applying multiple options
 	client := New(
@@ -88,10 +454,121 @@ func TestOptionChaining(t *testing.T) {
 	)
 
 	// Extract options from client for testing
-	options := client.options
+	options := client.Options()
 
 	assert.Equal(t, "custom-host", options.ServerHost, "ServerHost should be updated")
 	assert.Equal(t, 8888, options.ServerPort, "ServerPort should be updated")
 	assert.Equal(t, 15*time.Second, options.ConnectionTimeout, "ConnectionTimeout should be updated")
 	assert.False(t, options.AutoReconnect, "AutoReconnect should be updated")
 }
+
+func TestClientOptionsCopy(t *testing.T) {
+	config := &tls.Config{ServerName: "mcp.example.com"}
+	client := New(
+		WithTLSConfig(config),
+		WithDefaultMetadata(map[string]string{"tenant": "acme"}),
+		WithCompression(core.CompressionGzip, core.Compression("zstd")),
+	)
+
+	options := client.Options()
+	assert.NotSame(t, config, options.TLSConfig, "The copy should have its own TLS configuration")
+	assert.Equal(t, "mcp.example.com", options.TLSConfig.ServerName, "The copy should keep the TLS settings")
+
+	options.TLSConfig.ServerName = "evil.example.com"
+	options.DefaultMetadata["tenant"] = "other"
+	options.CompressionFallbacks[0] = core.CompressionNone
+
+	live := client.Options()
+	assert.Equal(t, "mcp.example.com", live.TLSConfig.ServerName, "Changing the copy should not change the client's TLS configuration")
+	assert.Equal(t, "mcp.example.com", config.ServerName, "Changing the copy should not change the configuration passed in")
+	assert.Equal(t, map[string]string{"tenant": "acme"}, live.DefaultMetadata, "Changing the copy should not change the client's metadata")
+	assert.Equal(t, []core.Compression{"zstd"}, live.CompressionFallbacks, "Changing the copy should not change the client's fallbacks")
+}
+
+func TestWithAuth(t *testing.T) {
+	options := DefaultOptions()
+	credentials := map[string]string{"token": "secret"}
+	option := WithAuth("token", credentials)
+	option(&options)
+
+	assert.Equal(t, "token", options.AuthScheme, "AuthScheme should be updated")
+	got, err := options.AuthCredentials(context.Background())
+	assert.NoError(t, err, "AuthCredentials should not fail")
+	assert.Equal(t, credentials, got, "AuthCredentials should return the fixed credentials")
+}
+
+func TestWithAuthProvider(t *testing.T) {
+	options := DefaultOptions()
+	calls := 0
+	option := WithAuthProvider("hmac", func(context.Context) (map[string]string, error) {
+		calls++
+		return map[string]string{"n": strconv.Itoa(calls)}, nil
+	})
+	option(&options)
+
+	assert.Equal(t, "hmac", options.AuthScheme, "AuthScheme should be updated")
+	first, _ := options.AuthCredentials(context.Background())
+	second, _ := options.AuthCredentials(context.Background())
+	assert.NotEqual(t, first, second, "AuthCredentials should be produced on each call")
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.NoError(t, DefaultOptions().Validate(), "The default options should be valid")
+
+	for name, tc := range map[string]struct {
+		options []Option
+		want    string
+	}{
+		"empty host":             {[]Option{WithServerHost("")}, "server host is empty"},
+		"negative port":          {[]Option{WithServerPort(-1)}, "server port -1 is outside 0-65535"},
+		"port too large":         {[]Option{WithServerPort(65536)}, "server port 65536 is outside 0-65535"},
+		"reconnect without wait": {[]Option{WithReconnectDelay(0)}, "auto-reconnect needs a positive reconnect delay, got 0s"},
+		"negative attempts":      {[]Option{WithMaxReconnectAttempts(-5)}, "max reconnect attempts must not be negative, got -5"},
+		"backoff under delay":    {[]Option{WithReconnectBackoff(time.Millisecond)}, "max reconnect delay must be at least the reconnect delay 1s, got 1ms"},
+		"negative backoff":       {[]Option{WithReconnectBackoff(-time.Second)}, "max reconnect delay must not be negative, got -1s"},
+		"heartbeat timeout":      {[]Option{WithHeartbeatInterval(time.Second), WithHeartbeatTimeout(0)}, "heartbeats need a positive heartbeat timeout"},
+		"heartbeat misses":       {[]Option{WithHeartbeatInterval(time.Second), WithMaxMissedHeartbeats(0)}, "heartbeats need at least 1 max missed heartbeat"},
+		"negative heartbeat":     {[]Option{WithHeartbeatInterval(-time.Second)}, "heartbeat interval must not be negative"},
+		"empty blob chunks":      {[]Option{WithBlobChunkSize(0)}, "blob chunk size must be at least 1, got 0"},
+		"negative pool":          {[]Option{WithConnectionPoolSize(-1)}, "connection pool size must not be negative"},
+		"negative timeout":       {[]Option{WithConnectionTimeout(-time.Second)}, "connection timeout must not be negative, got -1s"},
+		"negative response size": {[]Option{WithMaxResponseBytes(-1)}, "max response bytes must not be negative"},
+		"negative request time":  {[]Option{WithRequestTimeout(-time.Second)}, "request timeout must not be negative, got -1s"},
+		"inverted tuning bounds": {[]Option{WithTuningBounds(TuningBounds{Min: 2, Max: 1}, TuningBounds{Min: 1, Max: 1})}, "compression threshold bounds must be at least 1, with Min no greater than Max, got 2-1"},
+		"empty tuning bounds":    {[]Option{WithTuningBounds(TuningBounds{Min: 1, Max: 1}, TuningBounds{})}, "blob chunk size bounds must be at least 1, with Min no greater than Max, got 0-0"},
+		"negative read timeout":  {[]Option{WithReadTimeout(-time.Second)}, "read timeout must not be negative, got -1s"},
+		"negative write timeout": {[]Option{WithWriteTimeout(-time.Second)}, "write timeout must not be negative, got -1s"},
+		"negative retries":       {[]Option{WithRetry(-1, time.Second)}, "retry attempts must not be negative, got -1"},
+		"negative retry delay":   {[]Option{WithRetry(1, -time.Second)}, "retry delay must not be negative, got -1s"},
+		"negative attempt time":  {[]Option{WithRetryAttemptTimeout(-time.Second)}, "retry attempt timeout must not be negative, got -1s"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			options := DefaultOptions()
+			for _, opt := range tc.options {
+				opt(&options)
+			}
+			err := options.Validate()
+			require.Error(t, err, "The options should not validate")
+			assert.Contains(t, err.Error(), tc.want, "The error should say what is wrong")
+		})
+	}
+}
+
+func TestOptionsValidateWithoutReconnect(t *testing.T) {
+	options := DefaultOptions()
+	WithAutoReconnect(false)(&options)
+	WithReconnectDelay(0)(&options)
+
+	assert.NoError(t, options.Validate(), "A zero reconnect delay should be valid without auto-reconnect")
+}
+
+func TestStartValidatesOptions(t *testing.T) {
+	c := New(WithServerPort(-1), WithMaxReconnectAttempts(-5), WithLogger(core.NopLogger()))
+
+	err := c.Start()
+	require.Error(t, err, "Start should fail with invalid options")
+	assert.Contains(t, err.Error(), "invalid client options", "The error should say the options are invalid")
+	assert.Contains(t, err.Error(), "server port -1 is outside 0-65535", "The error should name the port")
+	assert.Contains(t, err.Error(), "max reconnect attempts must not be negative", "The error should name the reconnect attempts")
+	assert.Equal(t, core.StatusStopped, c.Status(), "The client should stay stopped")
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// pooledConn is one of the client's connections to the server.
+type pooledConn struct {
+	conn        *jsonrpc2.Conn
+	outstanding int64 // Calls awaiting a reply; accessed atomically
+}
+
+// pick returns the open connection with the fewest calls awaiting a reply and
+// counts a call against it; release must be called once the call completes.
+// Ties are broken round-robin so an idle pool spreads calls over every
+// connection. It returns nil when no connection is open.
+func (c *Client) pick() *pooledConn {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+
+	n := len(c.conns)
+	start := int(atomic.AddUint64(&c.next, 1) % uint64(n))
+	var best *pooledConn
+	for i := 0; i < n; i++ {
+		pc := c.conns[(start+i)%n]
+		if pc == nil {
+			continue
+		}
+		if best == nil || atomic.LoadInt64(&pc.outstanding) < atomic.LoadInt64(&best.outstanding) {
+			best = pc
+		}
+	}
+	if best != nil {
+		atomic.AddInt64(&best.outstanding, 1)
+	}
+	return best
+}
+
+// release ends a call counted against pc by pick.
+func (pc *pooledConn) release() {
+	atomic.AddInt64(&pc.outstanding, -1)
+}
+
+// primary returns the first connection of the pool, which carries the
+// client's subscriptions and notifications, or nil while it is down.
+func (c *Client) primary() *jsonrpc2.Conn {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	if pc := c.conns[0]; pc != nil {
+		return pc.conn
+	}
+	return nil
+}
+
+// openConns returns the number of pooled connections currently open.
+func (c *Client) openConns() int {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.openLocked()
+}
+
+// openLocked returns the number of pooled connections open. connMu must be
+// held.
+func (c *Client) openLocked() int {
+	open := 0
+	for _, pc := range c.conns {
+		if pc != nil {
+			open++
+		}
+	}
+	return open
+}
+
+// closeConns closes every pooled connection.
+func (c *Client) closeConns() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.openLocked() > 0 {
+		c.connSince = time.Now()
+	}
+	for slot, pc := range c.conns {
+		if pc != nil {
+			pc.conn.Close()
+			c.conns[slot] = nil
+		}
+	}
+}
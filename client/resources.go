@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ListResources retrieves the descriptions of the resources the server
+// offers.
+func (c *Client) ListResources(ctx context.Context) ([]core.Resource, error) {
+	var resp core.ListResourcesResponse
+	if err := c.call(ctx, core.MethodListResources, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Resources, nil
+}
+
+// ReadResource retrieves the content of the resource uri. Resources the
+// server does not offer fail with an error wrapping core.ErrResourceNotFound.
+func (c *Client) ReadResource(ctx context.Context, uri string) (*core.ResourceContent, error) {
+	var content core.ResourceContent
+	if err := c.call(ctx, core.MethodReadResource, core.ReadResourceRequest{URI: uri}, &content); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// resourceWatch is a SubscribeResource call waiting for changes to a resource.
+type resourceWatch struct {
+	uri      string
+	onChange func()
+}
+
+// resourceWatches routes received resource changes to the SubscribeResource
+// calls waiting for them, by URI.
+type resourceWatches struct {
+	mu      sync.Mutex
+	watches map[*resourceWatch]struct{}
+}
+
+func (r *resourceWatches) add(w *resourceWatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watches == nil {
+		r.watches = make(map[*resourceWatch]struct{})
+	}
+	r.watches[w] = struct{}{}
+}
+
+func (r *resourceWatches) remove(w *resourceWatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.watches, w)
+}
+
+// matching returns the watches of uri.
+func (r *resourceWatches) matching(uri string) []*resourceWatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []*resourceWatch
+	for w := range r.watches {
+		if w.uri == uri {
+			matched = append(matched, w)
+		}
+	}
+	return matched
+}
+
+// deliverResourceChange runs the callbacks of the watches of a changed
+// resource, each on its own goroutine.
+func (c *Client) deliverResourceChange(params *json.RawMessage) {
+	change, err := core.ResourceChanges.Decode(params)
+	if err != nil {
+		return
+	}
+	for _, w := range c.resources.matching(change.URI) {
+		w := w
+		c.tasks.Go(core.TaskEvents, w.onChange)
+	}
+}
+
+// SubscribeResource subscribes to changes to the resource uri, calling
+// onChange on its own goroutine whenever the server reports one. The
+// subscription is renewed whenever the client reconnects, though changes
+// made while it was disconnected are not reported. Call the returned
+// function to end the subscription.
+func (c *Client) SubscribeResource(ctx context.Context, uri string, onChange func()) (cancel func(), err error) {
+	topic := core.ResourceTopic(uri)
+	w := &resourceWatch{uri: uri, onChange: onChange}
+	c.resources.add(w)
+	if err := c.Subscribe(ctx, topic); err != nil {
+		c.resources.remove(w)
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.resources.remove(w)
+			c.unsubscribeLater(topic)
+		})
+	}, nil
+}
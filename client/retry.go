@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// withIdempotencyKey returns req with a new core.MetadataIdempotencyKey if
+// retries are enabled and it has none. The caller's request is not modified.
+func (c *Client) withIdempotencyKey(req *core.ModelRequest) *core.ModelRequest {
+	if req == nil || c.options.RetryAttempts == 0 || req.Metadata[core.MetadataIdempotencyKey] != "" {
+		return req
+	}
+	md := make(map[string]string, len(req.Metadata)+1)
+	for key, value := range req.Metadata {
+		md[key] = value
+	}
+	md[core.MetadataIdempotencyKey] = newIdempotencyKey()
+
+	out := *req
+	out.Metadata = md
+	return &out
+}
+
+// retryDedupWindow bounds how long the attempts still running once a retried
+// call has resolved are watched for answers to drop.
+const retryDedupWindow = 5 * time.Second
+
+// attemptResult is the outcome of one attempt at a retried call.
+type attemptResult struct {
+	resp *core.ModelResponse
+	err  error
+}
+
+// fromServer reports whether the attempt got an answer from the server,
+// a response or a failure it reported.
+func (r attemptResult) fromServer() bool {
+	var rpcErr *jsonrpc2.Error
+	var modelErr *core.ModelError
+	return r.err == nil || errors.As(r.err, &rpcErr) || errors.As(r.err, &modelErr)
+}
+
+// retrying returns process sending the request again, up to RetryAttempts
+// times, when it fails without an answer from the server, as when the
+// connection carrying it is lost, or when its reply takes longer than a
+// RetryAttemptTimeout. Each retry after a failure waits RetryDelay, and
+// each sends the time left before the deadline of ctx afresh. An attempt
+// outrun by a retry keeps running, and the call resolves once, with the
+// first answer of any attempt; answers to the others are dropped and
+// counted in Stats.DuplicatesDropped.
+func (c *Client) retrying(method string, process core.ProcessFunc) core.ProcessFunc {
+	if c.options.RetryAttempts == 0 {
+		return process
+	}
+	return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		results := make(chan attemptResult, c.options.RetryAttempts+1)
+		var next *core.Timer // Fires when the next attempt is due; nil if none is
+		schedule := func(d time.Duration) {
+			if next != nil {
+				next.Stop()
+			}
+			next = c.tasks.NewTimer(core.TaskReconnect, d)
+		}
+		defer func() {
+			if next != nil {
+				next.Stop()
+			}
+		}()
+
+		sent, inFlight := 0, 0
+		send := func(req *core.ModelRequest) {
+			sent++
+			inFlight++
+			c.tasks.Go(core.TaskConnection, func() {
+				resp, err := process(ctx, req)
+				results <- attemptResult{resp, err}
+			})
+			if next != nil {
+				next.Stop()
+				next = nil
+			}
+			if c.options.RetryAttemptTimeout > 0 && sent <= c.options.RetryAttempts {
+				schedule(c.options.RetryAttemptTimeout)
+			}
+		}
+
+		send(req)
+		for {
+			var due <-chan time.Time
+			if next != nil {
+				due = next.C
+			}
+			// Attempts in flight end with ctx, reporting why
+			var done <-chan struct{}
+			if inFlight == 0 {
+				done = ctx.Done()
+			}
+
+			select {
+			case result := <-results:
+				inFlight--
+				switch {
+				case result.fromServer() || ctx.Err() != nil:
+					c.dropLate(method, results, inFlight)
+					return result.resp, result.err
+				case inFlight > 0:
+					// An attempt still in flight may yet answer
+				case sent > c.options.RetryAttempts:
+					return nil, result.err
+				default:
+					c.options.Logger.Debug("Call interrupted, retrying", core.LogFieldMethod, method, core.LogFieldError, result.err)
+					schedule(c.options.RetryDelay)
+				}
+			case <-due:
+				if inFlight > 0 {
+					c.options.Logger.Debug("Reply overdue, retrying", core.LogFieldMethod, method)
+				}
+				send(withTimeout(ctx, req))
+			case <-done:
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// dropLate drops the answers of the inFlight attempts still running once a
+// retried call to method has resolved, counting those that arrive within
+// retryDedupWindow.
+func (c *Client) dropLate(method string, results <-chan attemptResult, inFlight int) {
+	if inFlight == 0 {
+		return
+	}
+	c.tasks.Go(core.TaskConnection, func() {
+		window := c.tasks.NewTimer(core.TaskConnection, retryDedupWindow)
+		defer window.Stop()
+		for ; inFlight > 0; inFlight-- {
+			select {
+			case result := <-results:
+				if result.fromServer() {
+					atomic.AddUint64(&c.stats.DuplicatesDropped, 1)
+					c.options.Logger.Debug("Dropped duplicate reply", core.LogFieldMethod, method)
+				}
+			case <-window.C:
+				return
+			}
+		}
+	})
+}
+
+// newIdempotencyKey returns a random idempotency key.
+func newIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate idempotency key: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
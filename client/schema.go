@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// schemaCache holds the method descriptions last fetched from the server.
+type schemaCache struct {
+	mu         sync.Mutex
+	methods    map[string]core.MethodDescription // nil until fetched
+	generation uint64                            // Incremented on every invalidation
+}
+
+// FetchMethodSchemas retrieves the descriptions of the methods the server
+// serves and caches them for local validation. The cache is dropped when the
+// server announces a change or the client reconnects.
+func (c *Client) FetchMethodSchemas(ctx context.Context) ([]core.MethodDescription, error) {
+	generation := c.schemaGeneration()
+
+	var resp core.ListMethodsResponse
+	if err := c.call(ctx, core.MethodListMethods, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	methods := make(map[string]core.MethodDescription, len(resp.Methods))
+	for _, desc := range resp.Methods {
+		methods[desc.Method] = desc
+	}
+	c.storeSchemas(generation, methods)
+	return resp.Methods, nil
+}
+
+// DescribeMethod retrieves the description of a single method the server
+// serves. Methods the server does not serve fail with a
+// jsonrpc2.CodeMethodNotFound error.
+func (c *Client) DescribeMethod(ctx context.Context, method string) (*core.MethodDescription, error) {
+	var desc core.MethodDescription
+	if err := c.call(ctx, core.MethodDescribeMethod, core.DescribeMethodRequest{Method: method}, &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// schemaGeneration returns the current cache generation.
+func (c *Client) schemaGeneration() uint64 {
+	c.schemas.mu.Lock()
+	defer c.schemas.mu.Unlock()
+	return c.schemas.generation
+}
+
+// storeSchemas caches methods unless the cache was invalidated since
+// generation, in which case they may already be stale.
+func (c *Client) storeSchemas(generation uint64, methods map[string]core.MethodDescription) {
+	c.schemas.mu.Lock()
+	defer c.schemas.mu.Unlock()
+	if c.schemas.generation == generation {
+		c.schemas.methods = methods
+	}
+}
+
+// invalidateSchemas drops the cached method descriptions.
+func (c *Client) invalidateSchemas() {
+	c.schemas.mu.Lock()
+	defer c.schemas.mu.Unlock()
+	c.schemas.generation++
+	c.schemas.methods = nil
+}
+
+// methodSchema returns the cached description of method, fetching the
+// descriptions first if none are cached. It reports false if the method
+// declares nothing or the descriptions cannot be fetched.
+func (c *Client) methodSchema(ctx context.Context, method string) (core.MethodDescription, bool) {
+	c.schemas.mu.Lock()
+	methods, generation := c.schemas.methods, c.schemas.generation
+	c.schemas.mu.Unlock()
+
+	if methods == nil {
+		if _, err := c.FetchMethodSchemas(ctx); err != nil {
+			var rpcErr *jsonrpc2.Error
+			if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+				// The server predates method descriptions; don't ask it again
+				c.storeSchemas(generation, map[string]core.MethodDescription{})
+			} else {
+				c.options.Logger.Warn("Failed to fetch method schemas, skipping local validation",
+					core.LogFieldMethod, method,
+					core.LogFieldError, err)
+			}
+			return core.MethodDescription{}, false
+		}
+		c.schemas.mu.Lock()
+		methods = c.schemas.methods
+		c.schemas.mu.Unlock()
+	}
+
+	desc, ok := methods[method]
+	return desc, ok
+}
+
+// validateLocally checks req against the server's description of method when
+// local validation is enabled. It returns the error the server would have
+// returned for an invalid request, and reports whether the request was
+// checked and passed.
+func (c *Client) validateLocally(ctx context.Context, method string, req *core.ModelRequest) (bool, error) {
+	if !c.options.LocalValidation || req == nil {
+		return false, nil
+	}
+	desc, ok := c.methodSchema(ctx, method)
+	if !ok {
+		return false, nil
+	}
+
+	// Validate the request as the server will decode it
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return false, nil
+	}
+	var decoded core.ModelRequest
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return false, nil
+	}
+
+	if result := desc.Validate(&decoded); !result.Valid {
+		return false, fmt.Errorf("RPC error: %w", core.InvalidParamsError(result))
+	}
+	return true, nil
+}
+
+// checkDrift logs a schema drift warning if the server rejected, as invalid,
+// a request that passed local validation, and drops the cached descriptions
+// so the next request validates against fresh ones.
+func (c *Client) checkDrift(method, requestID string, err error) {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != jsonrpc2.CodeInvalidParams || rpcErr.Data == nil || core.ModelResponseFromRPC(rpcErr) != nil {
+		return
+	}
+	c.options.Logger.Warn("Schema drift: server rejected a request that passed local validation",
+		core.LogFieldMethod, method,
+		core.LogFieldRequestID, requestID,
+		core.LogFieldError, rpcErr.Message)
+	c.invalidateSchemas()
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxValueSchema accepts the model data of testutil.CreateTestModelRequest as
+// long as the value is at most maxValue
+func maxValueSchema(maxValue float64) *core.Schema {
+	return &core.Schema{
+		Type:       "object",
+		Required:   []string{"name", "value"},
+		Properties: map[string]*core.Schema{"value": {Type: "number", Maximum: &maxValue}},
+	}
+}
+
+// startValidatingPair starts a server with a schema handler and a client
+// validating locally, logging to logger
+func startValidatingPair(t *testing.T, logger core.Logger) (*Client, *server.Server, *testutil.SchemaHandler) {
+	handler := testutil.NewSchemaHandler(maxValueSchema(100))
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *Client {
+			return New(WithTransport(transport), WithAutoReconnect(false), WithLocalValidation(true), WithLogger(logger))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c, srv, handler
+}
+
+func TestClientLocalValidation(t *testing.T) {
+	c, _, handler := startValidatingPair(t, core.NopLogger())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Matching request should be processed")
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["value"] = 500
+	_, err = c.ProcessModel(ctx, req)
+
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Local rejection should be a JSON-RPC error")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Local rejection should use the invalid params code")
+	assert.Equal(t, 1, handler.Calls(), "Invalid request should not be sent")
+
+	// The local error matches what the server returns for the same request
+	remote := New(WithTransport(c.Options().Transport), WithAutoReconnect(false))
+	require.NoError(t, remote.Start(), "Second client should connect")
+	defer remote.Stop()
+	_, remoteErr := remote.ProcessModel(ctx, req)
+	var serverErr *jsonrpc2.Error
+	require.ErrorAs(t, remoteErr, &serverErr, "Server rejection should be a JSON-RPC error")
+	assert.Equal(t, serverErr.Message, rpcErr.Message, "Local and server messages should match")
+	assert.JSONEq(t, string(*serverErr.Data), string(*rpcErr.Data), "Local and server error data should match")
+}
+
+func TestClientSchemaRefresh(t *testing.T) {
+	logger := testutil.NewCaptureLogger()
+	c, srv, handler := startValidatingPair(t, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.FetchMethodSchemas(ctx)
+	require.NoError(t, err, "Fetching schemas should succeed")
+	generation := c.schemaGeneration()
+
+	// Tighten the schema and announce it
+	handler.SetSchema(maxValueSchema(10))
+	srv.NotifyMethodsChanged()
+	assert.Eventually(t, func() bool {
+		return c.schemaGeneration() != generation
+	}, 2*time.Second, 10*time.Millisecond, "Change notification should invalidate the cache")
+
+	// The next request is checked against the new schema without a drift warning
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Request should be rejected under the new schema")
+	assert.Equal(t, 0, handler.Calls(), "Rejected request should not be sent")
+	_, drifted := logger.Find("Schema drift: server rejected a request that passed local validation")
+	assert.False(t, drifted, "Refreshed cache should not drift")
+}
+
+func TestClientSchemaDrift(t *testing.T) {
+	logger := testutil.NewCaptureLogger()
+	c, _, handler := startValidatingPair(t, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.FetchMethodSchemas(ctx)
+	require.NoError(t, err, "Fetching schemas should succeed")
+
+	// Tighten the schema without telling the client
+	handler.SetSchema(maxValueSchema(10))
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Server should reject the request")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Server should use the invalid params code")
+
+	record, ok := logger.Find("Schema drift: server rejected a request that passed local validation")
+	require.True(t, ok, "Drift should be logged")
+	assert.Equal(t, "warn", record.Level, "Drift should be a warning")
+
+	// The next request validates against the refreshed schema
+	generation := c.schemaGeneration()
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.Error(t, err, "Request should be rejected locally")
+	assert.Equal(t, generation, c.schemaGeneration(), "Refetch should not invalidate again")
+	assert.Equal(t, 0, handler.Calls(), "No request should reach the handler")
+}
+
+func TestClientFetchResultSchema(t *testing.T) {
+	c, _, handler := startValidatingPair(t, core.NopLogger())
+	handler.SetResultSchema(&core.Schema{Type: "object"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	methods, err := c.FetchMethodSchemas(ctx)
+	require.NoError(t, err, "Fetching schemas should succeed")
+	require.Len(t, methods, 1, "The handler's method should be described")
+	assert.Equal(t, &core.Schema{Type: "object"}, methods[0].ResultSchema, "Result schema should reach the client")
+	assert.Equal(t, maxValueSchema(100), methods[0].ModelSchema, "Model schema should still be described")
+}
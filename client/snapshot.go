@@ -0,0 +1,228 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// StateVersion is the version of the document ExportState writes. It grows
+// when a field changes meaning; fields are only ever added within a version.
+const StateVersion = 1
+
+// redacted stands in for exported settings that may hold secrets.
+const redacted = "[REDACTED]"
+
+// ExportedState is the document ExportState writes: how a client was
+// configured and what it had learned about its server, for support bundles
+// and for reproducing a client's behavior in a test.
+type ExportedState struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exportedAt"`
+	Options    StateOptions      `json:"options"`
+	Link       LinkStats         `json:"link"`    // Measurements restored by WithImportedState
+	History    ConnectionHistory `json:"history"` // Informational; not restored
+}
+
+// StateOptions are the Options recorded in an ExportedState. Settings that
+// cannot be written as JSON, such as the logger, the transport and the
+// codec, are recorded by name only, and those that may hold secrets, the
+// credentials and the TLS configuration, as "[REDACTED]" when set; none of
+// these are restored by WithImportedState.
+type StateOptions struct {
+	DefaultMetadata      map[string]string  `json:"defaultMetadata,omitempty"`
+	Transport            string             `json:"transport"`
+	ServerHost           string             `json:"serverHost"`
+	ServerPort           int                `json:"serverPort"`
+	ConnectionTimeout    time.Duration      `json:"connectionTimeout"`
+	ConnectionPoolSize   int                `json:"connectionPoolSize"`
+	AutoReconnect        bool               `json:"autoReconnect"`
+	MaxReconnectAttempts int                `json:"maxReconnectAttempts"`
+	ReconnectDelay       time.Duration      `json:"reconnectDelay"`
+	EnableTLS            bool               `json:"enableTLS"`
+	TLSConfig            string             `json:"tlsConfig,omitempty"`
+	TLSSessionResumption bool               `json:"tlsSessionResumption"`
+	HeartbeatInterval    time.Duration      `json:"heartbeatInterval"`
+	HeartbeatTimeout     time.Duration      `json:"heartbeatTimeout"`
+	MaxMissedHeartbeats  int                `json:"maxMissedHeartbeats"`
+	AuthScheme           string             `json:"authScheme,omitempty"`
+	AuthCredentials      string             `json:"authCredentials,omitempty"`
+	LocalValidation      bool               `json:"localValidation"`
+	JobPollInterval      time.Duration      `json:"jobPollInterval"`
+	ResponseCacheSize    int                `json:"responseCacheSize"`
+	ResponseCacheTTL     time.Duration      `json:"responseCacheTTL"`
+	Compression          core.Compression   `json:"compression,omitempty"`
+	CompressionFallbacks []core.Compression `json:"compressionFallbacks,omitempty"`
+	CompressionThreshold int                `json:"compressionThreshold"`
+	Codec                string             `json:"codec,omitempty"`
+	MaxResponseBytes     int64              `json:"maxResponseBytes"`
+	Features             []core.Feature     `json:"features"`
+}
+
+// ConnectionHistory summarizes the connections a client made.
+type ConnectionHistory struct {
+	Connections       uint64             `json:"connections"`            // Successful connections, including reconnects
+	ResumedHandshakes uint64             `json:"resumedHandshakes"`      // TLS handshakes that resumed an earlier session
+	RemoteAddr        string             `json:"remoteAddr,omitempty"`   // Address of the most recent connection
+	TLSVersion        string             `json:"tlsVersion,omitempty"`   // TLS version of the most recent connection
+	Compression       core.Compression   `json:"compression,omitempty"`  // Negotiated on the most recent connection
+	Codec             string             `json:"codec,omitempty"`        // Negotiated on the most recent connection
+	Capabilities      *core.Capabilities `json:"capabilities,omitempty"` // Negotiated on the most recent connection
+	Principal         string             `json:"principal,omitempty"`    // Who the client authenticated as
+}
+
+// clone returns a copy of the state that shares no slices, maps or pointers
+// with it.
+func (s *ExportedState) clone() *ExportedState {
+	c := *s
+	c.Options.DefaultMetadata = maps.Clone(s.Options.DefaultMetadata)
+	c.Options.CompressionFallbacks = slices.Clone(s.Options.CompressionFallbacks)
+	c.Options.Features = slices.Clone(s.Options.Features)
+	c.Link.History = slices.Clone(s.Link.History)
+	if s.History.Capabilities != nil {
+		capabilities := *s.History.Capabilities
+		capabilities.Features = slices.Clone(capabilities.Features)
+		c.History.Capabilities = &capabilities
+	}
+	return &c
+}
+
+// ExportState returns the client's configuration and learned state as an
+// ExportedState JSON document, which WithImportedState loads into another
+// client.
+func (c *Client) ExportState() ([]byte, error) {
+	state := ExportedState{
+		Version:    StateVersion,
+		ExportedAt: time.Now().UTC(),
+		Options:    exportOptions(c.options),
+		Link:       c.link.stats(),
+		History: ConnectionHistory{
+			Connections:       atomic.LoadUint64(&c.stats.Connections),
+			ResumedHandshakes: atomic.LoadUint64(&c.stats.ResumedHandshakes),
+		},
+	}
+
+	c.connMu.RLock()
+	if c.remoteAddr != nil {
+		state.History.RemoteAddr = c.remoteAddr.String()
+	}
+	if c.tlsState != nil {
+		state.History.TLSVersion = tls.VersionName(c.tlsState.Version)
+	}
+	state.History.Compression = c.frames.Compression
+	state.History.Codec = c.codecType()
+	state.History.Capabilities = c.capabilities
+	if c.principal != nil {
+		state.History.Principal = c.principal.ID
+	}
+	c.connMu.RUnlock()
+
+	return json.MarshalIndent(state, "", "  ")
+}
+
+// ParseState decodes a document written by ExportState. Documents from later
+// versions are accepted, ignoring what this version does not know; settings
+// missing from the document take their default values.
+func ParseState(data []byte) (*ExportedState, error) {
+	return decodeState(data, DefaultOptions())
+}
+
+// decodeState decodes data over the settings in base.
+func decodeState(data []byte, base Options) (*ExportedState, error) {
+	state := &ExportedState{Options: exportOptions(base)}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid client state: %w", err)
+	}
+	if state.Version < 1 {
+		return nil, errors.New("invalid client state: missing version")
+	}
+	return state, nil
+}
+
+// exportOptions returns the settings of o recorded in an ExportedState.
+func exportOptions(o Options) StateOptions {
+	exported := StateOptions{
+		DefaultMetadata:      o.DefaultMetadata,
+		Transport:            fmt.Sprintf("%T", o.Transport),
+		ServerHost:           o.ServerHost,
+		ServerPort:           o.ServerPort,
+		ConnectionTimeout:    o.ConnectionTimeout,
+		ConnectionPoolSize:   o.ConnectionPoolSize,
+		AutoReconnect:        o.AutoReconnect,
+		MaxReconnectAttempts: o.MaxReconnectAttempts,
+		ReconnectDelay:       o.ReconnectDelay,
+		EnableTLS:            o.EnableTLS,
+		TLSSessionResumption: o.TLSSessionResumption,
+		HeartbeatInterval:    o.HeartbeatInterval,
+		HeartbeatTimeout:     o.HeartbeatTimeout,
+		MaxMissedHeartbeats:  o.MaxMissedHeartbeats,
+		AuthScheme:           o.AuthScheme,
+		LocalValidation:      o.LocalValidation,
+		JobPollInterval:      o.JobPollInterval,
+		ResponseCacheSize:    o.ResponseCacheSize,
+		ResponseCacheTTL:     o.ResponseCacheTTL,
+		Compression:          o.Compression,
+		CompressionFallbacks: o.CompressionFallbacks,
+		CompressionThreshold: o.CompressionThreshold,
+		MaxResponseBytes:     o.MaxResponseBytes,
+		Features:             o.Features,
+	}
+	if o.TLSConfig != nil {
+		exported.TLSConfig = redacted
+	}
+	if o.AuthCredentials != nil {
+		exported.AuthCredentials = redacted
+	}
+	if o.Codec != nil {
+		exported.Codec = o.Codec.ContentType()
+	}
+	return exported
+}
+
+// importOptions applies the restorable settings of exported to o.
+func importOptions(o *Options, exported StateOptions) {
+	o.DefaultMetadata = exported.DefaultMetadata
+	o.ServerHost = exported.ServerHost
+	o.ServerPort = exported.ServerPort
+	o.ConnectionTimeout = exported.ConnectionTimeout
+	o.ConnectionPoolSize = exported.ConnectionPoolSize
+	o.AutoReconnect = exported.AutoReconnect
+	o.MaxReconnectAttempts = exported.MaxReconnectAttempts
+	o.ReconnectDelay = exported.ReconnectDelay
+	o.EnableTLS = exported.EnableTLS
+	o.TLSSessionResumption = exported.TLSSessionResumption
+	o.HeartbeatInterval = exported.HeartbeatInterval
+	o.HeartbeatTimeout = exported.HeartbeatTimeout
+	o.MaxMissedHeartbeats = exported.MaxMissedHeartbeats
+	o.AuthScheme = exported.AuthScheme
+	o.LocalValidation = exported.LocalValidation
+	o.JobPollInterval = exported.JobPollInterval
+	o.ResponseCacheSize = exported.ResponseCacheSize
+	o.ResponseCacheTTL = exported.ResponseCacheTTL
+	o.Compression = exported.Compression
+	o.CompressionFallbacks = exported.CompressionFallbacks
+	o.CompressionThreshold = exported.CompressionThreshold
+	o.MaxResponseBytes = exported.MaxResponseBytes
+	o.Features = exported.Features
+}
+
+// restore replaces the measurements with those of an exported client.
+func (m *linkMonitor) restore(stats LinkStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := stats.History
+	if len(history) > linkHistorySize {
+		history = history[len(history)-linkHistorySize:]
+	}
+	m.rtt, m.throughput = float64(stats.RTT), stats.Throughput
+	m.history = append([]LinkSample(nil), history...)
+	m.next = 0
+}
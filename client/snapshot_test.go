@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStateRoundTrip(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()), server.WithCompression(core.CompressionGzip))
+	require.NoError(t, srv.RegisterHandler(server.NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.RegisterAuthScheme(core.AuthSchemeToken, server.NewStaticTokenVerifier(map[string]core.Principal{
+		"hunter2": {ID: "ci"},
+	}, time.Hour)), "Token scheme registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	original := New(WithTransport(transport), WithLogger(core.NopLogger()),
+		WithCompression(core.CompressionGzip),
+		WithCompressionThreshold(64),
+		WithReconnectDelay(250*time.Millisecond),
+		WithDefaultMetadata(map[string]string{"tenant": "acme"}),
+		WithAuthToken("hunter2"))
+	require.NoError(t, original.Start(), "Client should connect")
+	defer original.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		_, err := original.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "ProcessModel should succeed")
+	}
+
+	data, err := original.ExportState()
+	require.NoError(t, err, "Export should succeed")
+	assert.NotContains(t, string(data), "hunter2", "Credentials should not be exported")
+
+	state, err := ParseState(data)
+	require.NoError(t, err, "Exported state should parse")
+	assert.Equal(t, StateVersion, state.Version, "Document should be versioned")
+	assert.Equal(t, redacted, state.Options.AuthCredentials, "Credentials should be marked as redacted")
+	assert.Equal(t, uint64(1), state.History.Connections, "Connections should be summarized")
+	assert.Equal(t, core.CompressionGzip, state.History.Compression, "Negotiated compression should be recorded")
+	require.NotNil(t, state.History.Capabilities, "Negotiated capabilities should be recorded")
+	assert.Equal(t, "ci", state.History.Principal, "Principal should be recorded")
+
+	// The transport and credentials are recorded by name only and set again
+	restored := New(WithImportedState(data), WithTransport(transport), WithLogger(core.NopLogger()))
+	assert.Equal(t, 250*time.Millisecond, restored.Options().ReconnectDelay, "Reconnect delay should carry over")
+	assert.Equal(t, 64, restored.Options().CompressionThreshold, "Compression threshold should carry over")
+	assert.Equal(t, map[string]string{"tenant": "acme"}, restored.Options().DefaultMetadata, "Metadata should carry over")
+	assert.Equal(t, core.AuthSchemeToken, restored.Options().AuthScheme, "Auth scheme should carry over")
+	assert.Nil(t, restored.Options().AuthCredentials, "Credentials should not carry over")
+	WithAuthToken("hunter2")(&restored.options)
+
+	link, want := restored.Stats().Link, original.Stats().Link
+	assert.Equal(t, want.RTT, link.RTT, "Smoothed RTT should carry over")
+	assert.Equal(t, want.Throughput, link.Throughput, "Smoothed throughput should carry over")
+	assert.Len(t, link.History, 5, "Link history should carry over")
+
+	require.NoError(t, restored.Start(), "Restored client should connect")
+	defer restored.Stop()
+	assert.Equal(t, core.CompressionGzip, restored.ConnectionState().Compression, "Restored client should negotiate the same compression")
+}
+
+func TestClientStateForwardCompatible(t *testing.T) {
+	data := []byte(`{
+		"version": 9,
+		"options": {"reconnectDelay": 1000000, "breakerThreshold": 5},
+		"tuner": {"chunkSize": 4096}
+	}`)
+	state, err := ParseState(data)
+	require.NoError(t, err, "Later versions should parse")
+	assert.Equal(t, time.Millisecond, state.Options.ReconnectDelay, "Known settings should be read")
+	assert.Equal(t, DefaultOptions().ServerPort, state.Options.ServerPort, "Missing settings should take their defaults")
+
+	restored := New(WithServerPort(7000), WithImportedState(data))
+	assert.Equal(t, 7000, restored.Options().ServerPort, "Missing settings should keep earlier options")
+	assert.Equal(t, time.Millisecond, restored.Options().ReconnectDelay, "Known settings should be imported")
+
+	for _, invalid := range []string{`{"options": {}}`, `not json`} {
+		_, err := ParseState([]byte(invalid))
+		assert.Error(t, err, "%s should be refused", invalid)
+		var options Options
+		WithImportedState([]byte(invalid))(&options)
+		assert.Nil(t, options.ImportedState, "%s should be ignored", invalid)
+	}
+
+	var decoded map[string]interface{}
+	exported, err := New(WithLogger(core.NopLogger())).ExportState()
+	require.NoError(t, err, "Export should succeed")
+	require.NoError(t, json.Unmarshal(exported, &decoded), "Export should be JSON")
+	assert.Contains(t, decoded, "version", "Export should carry its version")
+}
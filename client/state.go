@@ -0,0 +1,36 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ConnectionState describes the client's most recent connection to the
+// server, and whether it is connected now.
+type ConnectionState struct {
+	RemoteAddr       net.Addr             // Address of the server, or nil before the first connection
+	TLS              *tls.ConnectionState // TLS handshake details, including DidResume; nil without TLS
+	Compression      core.Compression     // Algorithm negotiated with the server; empty for a plain connection
+	Codec            string               // Content type of the codec negotiated with the server; empty for JSON
+	Connected        bool                 // Whether any pooled connection is open
+	Since            time.Time            // When Connected last changed; zero before the first connection
+	ReconnectAttempt int                  // Reconnection attempt in progress, from 1; zero when none is
+	LastError        error                // Why the last connection dropped or reconnection attempt failed; nil if none has
+}
+
+// Stats holds counters accumulated over the lifetime of a client, and the
+// background goroutines and timers it has running.
+type Stats struct {
+	Connections         uint64                              // Successful connections, including reconnects
+	OpenConnections     int                                 // Pooled connections currently open
+	ResumedHandshakes   uint64                              // TLS handshakes that resumed an earlier session
+	Tasks               map[core.TaskFeature]core.TaskCount // Live goroutines and timers by feature
+	ObservabilityErrors uint64                              // Measurements the metrics collector failed to take
+	Link                LinkStats                           // Round trip times and throughput of recent calls
+	Tuning              TuningStats                         // Compression threshold and blob chunk size tuned to the link
+	ResponseCache       CacheStats                          // Use of the response cache; zero without one
+	DuplicatesDropped   uint64                              // Answers to retried calls dropped as another attempt had answered first
+}
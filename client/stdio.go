@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// processExitTimeout is how long a closed server process may take to exit
+// after its stdin is closed before it is killed.
+const processExitTimeout = 2 * time.Second
+
+// NewStdioClient creates a client that spawns cmd and speaks JSON-RPC over its
+// standard input and output, for servers that run as a subprocess. The process
+// is started by Start. If AutoReconnect is enabled and the process exits, a new
+// process is spawned with the same path, arguments, environment and directory.
+// A stdio client always has a single connection, whatever WithConnectionPoolSize says.
+func NewStdioClient(cmd *exec.Cmd, options ...Option) *Client {
+	return New(append(options, WithTransport(&processTransport{cmd: cmd}), WithConnectionPoolSize(1))...)
+}
+
+// processTransport dials by spawning a server process.
+type processTransport struct {
+	mu      sync.Mutex
+	cmd     *exec.Cmd // Template for the next process; used as-is the first time
+	started bool
+	current *processConn
+}
+
+// Listen implements core.Transport. Processes can only be dialed.
+func (t *processTransport) Listen(string) (net.Listener, error) {
+	return nil, errors.New("stdio transport cannot listen")
+}
+
+// Dial implements core.Transport by starting a new server process.
+func (t *processTransport) Dial(ctx context.Context, _ string) (net.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// An exec.Cmd can only run once, so later dials start a copy
+	cmd := t.cmd
+	if t.started {
+		cmd = exec.Command(t.cmd.Path, t.cmd.Args[1:]...)
+		cmd.Env = t.cmd.Env
+		cmd.Dir = t.cmd.Dir
+		cmd.Stderr = t.cmd.Stderr
+	}
+	t.started = true
+
+	// Own the pipe ends so exit handling never closes them under a pending read
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, err
+	}
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+
+	err = cmd.Start()
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, err
+	}
+
+	t.current = newProcessConn(cmd, stdinW, stdoutR)
+	return t.current, nil
+}
+
+// String returns the transport name.
+func (t *processTransport) String() string {
+	return "stdio"
+}
+
+// processConn adapts a server process's stdin and stdout to a net.Conn.
+type processConn struct {
+	cmd       *exec.Cmd
+	stdin     *os.File
+	stdout    *os.File
+	exited    chan struct{}
+	closeOnce sync.Once
+}
+
+func newProcessConn(cmd *exec.Cmd, stdin, stdout *os.File) *processConn {
+	c := &processConn{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+		exited: make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait()
+		close(c.exited)
+	}()
+	return c
+}
+
+func (c *processConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *processConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Close closes the process's stdin so it can exit cleanly, killing it if it
+// has not exited within processExitTimeout.
+func (c *processConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		select {
+		case <-c.exited:
+		case <-time.After(processExitTimeout):
+			c.cmd.Process.Kill()
+			<-c.exited
+		}
+		c.stdout.Close()
+	})
+	return nil
+}
+
+func (c *processConn) LocalAddr() net.Addr  { return processAddr{pid: os.Getpid()} }
+func (c *processConn) RemoteAddr() net.Addr { return processAddr{pid: c.cmd.Process.Pid} }
+
+func (c *processConn) SetDeadline(t time.Time) error {
+	if err := c.stdout.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.stdin.SetWriteDeadline(t)
+}
+
+func (c *processConn) SetReadDeadline(t time.Time) error  { return c.stdout.SetReadDeadline(t) }
+func (c *processConn) SetWriteDeadline(t time.Time) error { return c.stdin.SetWriteDeadline(t) }
+
+// processAddr identifies one end of a stdio connection by process ID.
+type processAddr struct {
+	pid int
+}
+
+func (a processAddr) Network() string { return "stdio" }
+func (a processAddr) String() string  { return "pid:" + strconv.Itoa(a.pid) }
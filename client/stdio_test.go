@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildStdioServer compiles the stdio example server into a temporary directory
+func buildStdioServer(t *testing.T) string {
+	goTool := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(goTool); err != nil {
+		t.Skip("go tool not available to build the example server")
+	}
+
+	binary := filepath.Join(t.TempDir(), "stdio-server")
+	build := exec.Command(goTool, "build", "-o", binary, "../examples/stdio/server")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, "Example server should build: %s", out)
+	return binary
+}
+
+func TestStdioClient(t *testing.T) {
+	binary := buildStdioServer(t)
+
+	client := NewStdioClient(exec.Command(binary), WithAutoReconnect(false))
+	require.NoError(t, client.Start(), "Client should spawn the server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	resp, err := client.ProcessModel(ctx, req)
+	require.NoError(t, err, "ProcessModel should succeed over stdio")
+	assert.Equal(t, req.ID, resp.ID, "Response ID should match request ID")
+	assert.Equal(t, "processed", resp.Results["status"], "Status should be set to 'processed'")
+
+	transport := client.Options().Transport.(*processTransport)
+	require.NoError(t, client.Stop(), "Client should stop successfully")
+
+	select {
+	case <-transport.current.exited:
+	case <-time.After(time.Second):
+		t.Fatal("Server process should exit when the client stops")
+	}
+}
+
+func TestStdioClientRestartsProcess(t *testing.T) {
+	binary := buildStdioServer(t)
+
+	client := NewStdioClient(exec.Command(binary), WithReconnectDelay(10*time.Millisecond))
+	require.NoError(t, client.Start(), "Client should spawn the server")
+	defer client.Stop()
+
+	first := client.RemoteAddr().String()
+
+	// Kill the server process out from under the client
+	transport := client.Options().Transport.(*processTransport)
+	transport.mu.Lock()
+	require.NoError(t, transport.current.cmd.Process.Kill(), "Server process should be killed")
+	transport.mu.Unlock()
+
+	require.True(t, testutil.WaitForCondition(2*time.Second, 10*time.Millisecond, func() bool {
+		return client.IsConnected() && client.RemoteAddr().String() != first
+	}), "Client should spawn a new server process")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed after the restart")
+	assert.True(t, resp.Success, "Response should indicate success")
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// streamRegistry routes received chunks to the ProcessModelStream call
+// waiting for them, by request ID.
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*chunkStream
+}
+
+// chunkStream is a ProcessModelStream call waiting for chunks.
+type chunkStream struct {
+	onChunk func(core.ModelChunk)
+	next    int // Sequence number of the next chunk
+}
+
+// add registers onChunk for the chunks of requestID. It fails if a stream
+// for the same request ID is already in progress.
+func (r *streamRegistry) add(requestID string, onChunk func(core.ModelChunk)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.streams[requestID]; ok {
+		return fmt.Errorf("stream for request %s already in progress", requestID)
+	}
+	if r.streams == nil {
+		r.streams = make(map[string]*chunkStream)
+	}
+	r.streams[requestID] = &chunkStream{onChunk: onChunk}
+	return nil
+}
+
+func (r *streamRegistry) remove(requestID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.streams, requestID)
+}
+
+// accept returns the callback for chunk if it is the next chunk of a stream
+// in progress. Chunks out of sequence belong to an earlier, abandoned call
+// with the same request ID.
+func (r *streamRegistry) accept(chunk core.ModelChunk) (func(core.ModelChunk), bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stream, ok := r.streams[chunk.RequestID]
+	if !ok || chunk.Sequence != stream.next {
+		return nil, false
+	}
+	stream.next++
+	return stream.onChunk, true
+}
+
+// ProcessModelStream sends req with mcp.processModelStream, calling onChunk
+// with each partial result the handler emits, in order, and returns the final
+// response once every chunk has been delivered. Streams are told apart by
+// request ID, so concurrent streams need distinct IDs. The RequestTimeout
+// bounds only the wait for the first chunk, so a stream may go on for as
+// long as ctx allows.
+//
+// onChunk runs on the connection's read loop: it should return quickly and
+// must not call the client, which cannot receive replies while it runs.
+func (c *Client) ProcessModelStream(ctx context.Context, req *core.ModelRequest, onChunk func(core.ModelChunk)) (*core.ModelResponse, error) {
+	requestID := ""
+	if req != nil {
+		requestID = req.ID
+	}
+	if err := c.requireFeature(core.FeatureStreaming); err != nil {
+		return nil, err
+	}
+	ctx, started, cancel := c.withHandshakeTimeout(ctx, core.MethodProcessModelStream)
+	defer cancel()
+	ctx, endSpan := c.options.Tracer.StartSpan(ctx, core.SpanClient, core.MethodProcessModelStream, requestID)
+
+	req, err := c.hooks.beforeSend(ctx, withTimeout(ctx, c.withMetadata(ctx, req)))
+	if err != nil {
+		endSpan(err)
+		return nil, err
+	}
+
+	if err := c.streams.add(requestID, func(chunk core.ModelChunk) {
+		started()
+		onChunk(chunk)
+	}); err != nil {
+		endSpan(err)
+		return nil, err
+	}
+	defer c.streams.remove(requestID)
+
+	resp, err := c.intercepted(core.MethodProcessModelStream, c.roundTrip(core.MethodProcessModelStream))(ctx, req)
+	if err != nil {
+		endSpan(err)
+		return nil, err
+	}
+	if err := c.hooks.afterReceive(ctx, resp); err != nil {
+		endSpan(err)
+		return nil, err
+	}
+
+	endSpan(resp.Err())
+	return resp, nil
+}
+
+// deliverChunk passes a received chunk to the stream it belongs to. Chunks of
+// streams that have ended, e.g. because their call was cancelled, are dropped.
+func (c *Client) deliverChunk(params *json.RawMessage) {
+	chunk, err := core.ModelChunks.Decode(params)
+	if err != nil {
+		c.options.Logger.Warn("Invalid notification from server", core.LogFieldMethod, core.NotifyModelChunk, core.LogFieldError, err)
+		return
+	}
+	onChunk, ok := c.streams.accept(chunk)
+	if !ok {
+		c.options.Logger.Debug("Dropping chunk of finished stream", core.LogFieldRequestID, chunk.RequestID, "sequence", chunk.Sequence)
+		return
+	}
+	onChunk(chunk)
+}
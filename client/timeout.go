@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// RequestTimeoutError is the error of a call that ran past the timeout set
+// with WithRequestTimeout. It wraps context.DeadlineExceeded, and tells such
+// calls apart from those whose own context's deadline passed.
+type RequestTimeoutError struct {
+	Method  string        // Method of the call
+	Timeout time.Duration // The request timeout that passed
+}
+
+func (e *RequestTimeoutError) Error() string {
+	return fmt.Sprintf("%s: no reply within the request timeout of %s", e.Method, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *RequestTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// handshakeKey marks the context of a call whose request timeout has been
+// replaced by withHandshakeTimeout.
+type handshakeKey struct{}
+
+// withRequestTimeout returns ctx bounded by the RequestTimeout if it has no
+// deadline of its own, and a function releasing it. Calls on the returned
+// context that run out of time fail with a *RequestTimeoutError. Every call
+// gets it in invoke; calls that send their deadline to the server apply it
+// earlier, so the deadline is known when the request is built.
+func (c *Client) withRequestTimeout(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	timeout := c.options.RequestTimeout
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 || ctx.Value(handshakeKey{}) != nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, &RequestTimeoutError{Method: method, Timeout: timeout})
+}
+
+// withHandshakeTimeout is withRequestTimeout for calls that answer as they
+// go, such as streams, which may run for as long as the server has more to
+// send. The RequestTimeout bounds only the wait for the server to begin
+// answering, and stops applying once started is called. Calls that run out
+// of time before then fail with a *RequestTimeoutError.
+func (c *Client) withHandshakeTimeout(ctx context.Context, method string) (_ context.Context, started func(), cancel func()) {
+	timeout := c.options.RequestTimeout
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}, func() {}
+	}
+	ctx, cancelCause := context.WithCancelCause(context.WithValue(ctx, handshakeKey{}, struct{}{}))
+	timer := time.AfterFunc(timeout, func() {
+		cancelCause(&RequestTimeoutError{Method: method, Timeout: timeout})
+	})
+	return ctx, func() { timer.Stop() }, func() {
+		timer.Stop()
+		cancelCause(nil)
+	}
+}
+
+// deadlineConn wraps a net.Conn and pushes its deadlines forward before every
+// read and write. A server that sends nothing for the read timeout, or takes
+// nothing written to it for the write timeout, is dropped instead of blocking
+// the client forever, while a quiet but healthy one answering heartbeats
+// stays connected. Zero timeouts leave that direction without a deadline.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// Read implements io.Reader, refreshing the read deadline first.
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if c.readTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(p)
+}
+
+// Write implements io.Writer, refreshing the write deadline first. A write
+// that times out may have sent part of a frame, so the connection is closed
+// and, with AutoReconnect, replaced.
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.writeTimeout <= 0 {
+		return c.Conn.Write(p)
+	}
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		c.Conn.Close()
+	}
+	return n, err
+}
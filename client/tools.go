@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ListTools retrieves the descriptions of the tools the server offers,
+// sorted by name.
+func (c *Client) ListTools(ctx context.Context) ([]core.ToolInfo, error) {
+	var resp core.ListToolsResponse
+	if err := c.call(ctx, core.MethodListTools, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tools, nil
+}
+
+// CallTool runs the tool name on the server with args, encoded as JSON, and
+// decodes its result into result, which may be nil to discard it. Tools the
+// server does not offer fail with an error wrapping core.ErrToolNotFound.
+func (c *Client) CallTool(ctx context.Context, name string, args, result interface{}) error {
+	req := core.CallToolRequest{Name: name}
+	if args != nil {
+		encoded, err := json.Marshal(args)
+		if err != nil {
+			return fmt.Errorf("failed to encode tool arguments: %w", err)
+		}
+		req.Args = encoded
+	}
+	if result == nil {
+		result = &json.RawMessage{}
+	}
+	return c.call(ctx, core.MethodCallTool, req, result)
+}
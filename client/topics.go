@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// topicSet counts the subscriptions held to each topic, so the server is only
+// asked to subscribe once and unsubscribe when the last one ends.
+type topicSet struct {
+	mu     sync.Mutex
+	topics map[string]*topicSubscription
+}
+
+// topicSubscription is the client's subscription to one topic.
+type topicSubscription struct {
+	count int
+	req   core.SubscribeRequest // Sent to subscribe, again on every reconnect
+}
+
+// add counts a subscription made with req and reports whether it is the
+// first to its topic. It returns an error if the topic is already subscribed
+// to with a different filter or durability.
+func (s *topicSet) add(req core.SubscribeRequest) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.topics == nil {
+		s.topics = make(map[string]*topicSubscription)
+	}
+	sub, ok := s.topics[req.Topic]
+	if !ok {
+		s.topics[req.Topic] = &topicSubscription{count: 1, req: req}
+		return true, nil
+	}
+	if sub.req != req {
+		return false, fmt.Errorf("already subscribed to %s with other options", req.Topic)
+	}
+	sub.count++
+	return false, nil
+}
+
+// remove ends a subscription to topic. It returns the request the
+// subscription was made with, if it was the last.
+func (s *topicSet) remove(topic string) (core.SubscribeRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.topics[topic]
+	if !ok {
+		return core.SubscribeRequest{}, false
+	}
+	sub.count--
+	if sub.count > 0 {
+		return core.SubscribeRequest{}, false
+	}
+	delete(s.topics, topic)
+	return sub.req, true
+}
+
+// list returns the requests renewing every subscription.
+func (s *topicSet) list() []core.SubscribeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs := make([]core.SubscribeRequest, 0, len(s.topics))
+	for _, sub := range s.topics {
+		reqs = append(reqs, sub.req)
+	}
+	return reqs
+}
+
+// SubscribeOption configures a subscription made with Subscribe.
+type SubscribeOption func(*core.SubscribeRequest)
+
+// WithSubscribeFilter has the server send only the notifications whose
+// payload matches filter, an expression in the language of core.Filter. Every
+// subscription the client holds to a topic must use the same filter.
+func WithSubscribeFilter(filter string) SubscribeOption {
+	return func(req *core.SubscribeRequest) {
+		req.Filter = filter
+	}
+}
+
+// WithSubscribeDurable makes the subscription durable under id, which must be
+// unique among the server's clients and stay the same across restarts of the
+// client. When the client reconnects, or a new client subscribes with the
+// same id, the server first replays the notifications it buffered while the
+// subscription was disconnected; their handlers find
+// core.NotificationMetaFromContext(ctx).Replayed set.
+func WithSubscribeDurable(id string) SubscribeOption {
+	return func(req *core.SubscribeRequest) {
+		req.Durable = true
+		req.SubscriptionID = id
+	}
+}
+
+// Subscribe subscribes the client to notifications the server publishes on
+// topic, which arrive at the handlers registered with OnNotificationTyped.
+// Subscriptions are held on the client's first pooled connection and renewed
+// whenever it reconnects. Each call must be matched by one to Unsubscribe.
+// With WithSubscribeFilter, the server sends only the matching notifications;
+// with WithSubscribeDurable, it keeps those published while the client is
+// disconnected.
+func (c *Client) Subscribe(ctx context.Context, topic string, opts ...SubscribeOption) error {
+	req := core.SubscribeRequest{Topic: topic}
+	for _, opt := range opts {
+		opt(&req)
+	}
+	if req.Filter != "" {
+		// Refuse a filter the server would reject before counting the subscription
+		if _, err := core.ParseFilter(req.Filter); err != nil {
+			return fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	first, err := c.topics.add(req)
+	if err != nil || !first {
+		return err
+	}
+	if err := c.callOn(ctx, c.primary(), core.MethodSubscribe, req, &struct{}{}); err != nil {
+		c.topics.remove(topic)
+		return err
+	}
+	return nil
+}
+
+// Unsubscribe ends a subscription made with Subscribe. The server stops
+// publishing to the client once every subscription to topic has ended, and
+// discards a durable subscription's buffer.
+func (c *Client) Unsubscribe(ctx context.Context, topic string) error {
+	req, last := c.topics.remove(topic)
+	if !last {
+		return nil
+	}
+	return c.callOn(ctx, c.primary(), core.MethodUnsubscribe, req, &struct{}{})
+}
+
+// resubscribe renews the client's subscriptions on a new connection.
+func (c *Client) resubscribe(ctx context.Context, conn *jsonrpc2.Conn) {
+	for _, req := range c.topics.list() {
+		if err := conn.Call(ctx, core.MethodSubscribe, req, &struct{}{}); err != nil {
+			c.options.Logger.Warn("Failed to renew subscription", "topic", req.Topic, core.LogFieldError, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Constants of the AIMD scheme the tuner adjusts its values with: a link
+// doing well raises a value by its step, one struggling halves it.
+const (
+	tuningSlowThroughput = 1 << 20         // Bytes per second below which a link counts as slow, so compressing more messages pays
+	tuningMinSample      = 16 << 10        // Smallest call, in bytes both ways, whose throughput is taken as the link's
+	tuningThresholdStep  = 256             // Bytes the compression threshold rises by on a fast link
+	tuningChunkStep      = 64 << 10        // Bytes the blob chunk size rises by while chunks are quick
+	tuningChunkTarget    = 1 * time.Second // Longest a blob chunk call should take before the chunk size is halved
+)
+
+// TuningBounds is the range, inclusive, the tuner moves a value within.
+type TuningBounds struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// clamp returns v moved into the bounds.
+func (b TuningBounds) clamp(v int) int {
+	return min(max(v, b.Min), b.Max)
+}
+
+// TuningStats reports the values the client tunes to its link.
+type TuningStats struct {
+	Static               bool `json:"static"`               // Whether the values are kept as configured
+	CompressionThreshold int  `json:"compressionThreshold"` // Smallest message, in bytes, now compressed
+	BlobChunkSize        int  `json:"blobChunkSize"`        // Bytes now carried by each blob transfer call
+}
+
+// tuningState is the document a tuning state file holds.
+type tuningState struct {
+	CompressionThreshold int       `json:"compressionThreshold"`
+	BlobChunkSize        int       `json:"blobChunkSize"`
+	SavedAt              time.Time `json:"savedAt"`
+}
+
+// linkTuner adjusts the compression threshold and the blob chunk size to
+// the link, from the measurements of completed calls. Calls on a slow link
+// lower the threshold, so more messages are compressed, and those on a fast
+// one raise it; blob calls taking longer than chunkTarget, or lost with
+// their connection, halve the chunk size, and quicker ones raise it.
+type linkTuner struct {
+	mu          sync.Mutex // Serializes adjustments
+	static      bool
+	thresholds  TuningBounds
+	chunkSizes  TuningBounds
+	chunkTarget time.Duration
+	threshold   atomic.Int64
+	chunkSize   atomic.Int64
+}
+
+// newLinkTuner returns a tuner starting from the configured values, moved
+// into their bounds unless tuning is static.
+func newLinkTuner(o Options) *linkTuner {
+	t := &linkTuner{
+		static:      o.StaticTuning,
+		thresholds:  o.CompressionThresholdBounds,
+		chunkSizes:  o.BlobChunkSizeBounds,
+		chunkTarget: tuningChunkTarget,
+	}
+	threshold, chunkSize := o.CompressionThreshold, o.BlobChunkSize
+	if !t.static {
+		threshold, chunkSize = t.thresholds.clamp(threshold), t.chunkSizes.clamp(chunkSize)
+	}
+	t.threshold.Store(int64(threshold))
+	t.chunkSize.Store(int64(chunkSize))
+	return t
+}
+
+// compressionThreshold returns the smallest message, in bytes, to compress.
+func (t *linkTuner) compressionThreshold() int {
+	return int(t.threshold.Load())
+}
+
+// blobChunkSize returns the bytes each blob transfer call should carry.
+func (t *linkTuner) blobChunkSize() int {
+	return int(t.chunkSize.Load())
+}
+
+// observe adjusts the values to the measurement of a successful call.
+func (t *linkTuner) observe(sample LinkSample) {
+	if t.static || sample.RTT <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if size := sample.BytesOut + sample.BytesIn; size >= tuningMinSample {
+		threshold := t.compressionThreshold()
+		if float64(size)/sample.RTT.Seconds() < tuningSlowThroughput {
+			threshold /= 2
+		} else {
+			threshold += tuningThresholdStep
+		}
+		t.threshold.Store(int64(t.thresholds.clamp(threshold)))
+	}
+	if isBlobMethod(sample.Method) {
+		chunkSize := t.blobChunkSize()
+		if sample.RTT > t.chunkTarget {
+			chunkSize /= 2
+		} else {
+			chunkSize += tuningChunkStep
+		}
+		t.chunkSize.Store(int64(t.chunkSizes.clamp(chunkSize)))
+	}
+}
+
+// interrupted halves the blob chunk size after a blob call to method was
+// lost with its connection.
+func (t *linkTuner) interrupted(method string) {
+	if t.static || !isBlobMethod(method) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chunkSize.Store(int64(t.chunkSizes.clamp(t.blobChunkSize() / 2)))
+}
+
+// stats returns the current values.
+func (t *linkTuner) stats() TuningStats {
+	return TuningStats{
+		Static:               t.static,
+		CompressionThreshold: t.compressionThreshold(),
+		BlobChunkSize:        t.blobChunkSize(),
+	}
+}
+
+// load starts the tuner from the values saved in path, moved into their
+// bounds. A missing file leaves the values as they are.
+func (t *linkTuner) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state tuningState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid tuning state %s: %w", path, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state.CompressionThreshold > 0 {
+		t.threshold.Store(int64(t.thresholds.clamp(state.CompressionThreshold)))
+	}
+	if state.BlobChunkSize > 0 {
+		t.chunkSize.Store(int64(t.chunkSizes.clamp(state.BlobChunkSize)))
+	}
+	return nil
+}
+
+// save writes the current values to path, replacing it in one step so a
+// crash leaves either the old state or the new.
+func (t *linkTuner) save(path string) error {
+	data, err := json.MarshalIndent(tuningState{
+		CompressionThreshold: t.compressionThreshold(),
+		BlobChunkSize:        t.blobChunkSize(),
+		SavedAt:              time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".tuning-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// isBlobMethod reports whether method transfers a blob chunk.
+func isBlobMethod(method string) bool {
+	return method == core.MethodBlobPut || method == core.MethodBlobGet
+}
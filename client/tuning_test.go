@@ -0,0 +1,185 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shapedTransport limits what clients dialing through it write to rate
+// bytes per second, as a slow uplink would.
+type shapedTransport struct {
+	core.Transport
+	rate int
+}
+
+func (t *shapedTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	conn, err := t.Transport.Dial(ctx, address)
+	if err != nil || t.rate == 0 {
+		return conn, err
+	}
+	return &shapedConn{Conn: conn, rate: t.rate}, nil
+}
+
+type shapedConn struct {
+	net.Conn
+	rate int
+}
+
+func (c *shapedConn) Write(p []byte) (int, error) {
+	time.Sleep(time.Duration(len(p)) * time.Second / time.Duration(c.rate))
+	return c.Conn.Write(p)
+}
+
+// startTuningPair starts a server storing blobs and a client dialing it at
+// rate bytes per second, or unshaped if rate is zero, whose blob chunk calls
+// should take no longer than chunkTarget.
+func startTuningPair(t *testing.T, rate int, chunkTarget time.Duration, options ...Option) *Client {
+	transport := core.NewInProcessTransport()
+	store, err := server.NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()), server.WithBlobStore(store))
+	require.NoError(t, srv.Start(), "Server should start")
+	t.Cleanup(func() { srv.Stop() })
+
+	client := New(append([]Option{
+		WithTransport(&shapedTransport{Transport: transport, rate: rate}),
+		WithAutoReconnect(false),
+		WithLogger(core.NopLogger()),
+	}, options...)...)
+	client.tuner.chunkTarget = chunkTarget
+	require.NoError(t, client.Start(), "Client should start")
+	t.Cleanup(func() { client.Stop() })
+	return client
+}
+
+// uploadRandom uploads size random bytes through client.
+func uploadRandom(t *testing.T, client *Client, size int) {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err, "Generating the blob should succeed")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = client.UploadBlob(ctx, bytes.NewReader(data), core.BlobMeta{})
+	require.NoError(t, err, "Upload should succeed")
+}
+
+func TestLinkTuner(t *testing.T) {
+	options := DefaultOptions()
+	WithCompressionThreshold(1024)(&options)
+	WithBlobChunkSize(128 << 10)(&options)
+	WithTuningBounds(TuningBounds{Min: 512, Max: 1536}, TuningBounds{Min: 64 << 10, Max: 256 << 10})(&options)
+	tuner := newLinkTuner(options)
+	tuner.chunkTarget = 100 * time.Millisecond
+
+	fast := LinkSample{Method: core.MethodBlobPut, RTT: 10 * time.Millisecond, BytesOut: 64 << 10}
+	tuner.observe(fast)
+	assert.Equal(t, 1024+tuningThresholdStep, tuner.compressionThreshold(), "A fast call should raise the threshold by a step")
+	assert.Equal(t, 128<<10+tuningChunkStep, tuner.blobChunkSize(), "A quick chunk should raise the chunk size by a step")
+	for i := 0; i < 10; i++ {
+		tuner.observe(fast)
+	}
+	assert.Equal(t, 1536, tuner.compressionThreshold(), "The threshold should stop at its upper bound")
+	assert.Equal(t, 256<<10, tuner.blobChunkSize(), "The chunk size should stop at its upper bound")
+
+	slow := LinkSample{Method: core.MethodBlobPut, RTT: time.Second, BytesOut: 64 << 10}
+	tuner.observe(slow)
+	assert.Equal(t, 768, tuner.compressionThreshold(), "A slow call should halve the threshold")
+	assert.Equal(t, 128<<10, tuner.blobChunkSize(), "A slow chunk should halve the chunk size")
+	tuner.observe(slow)
+	tuner.observe(slow)
+	assert.Equal(t, 512, tuner.compressionThreshold(), "The threshold should stop at its lower bound")
+	assert.Equal(t, 64<<10, tuner.blobChunkSize(), "The chunk size should stop at its lower bound")
+
+	tuner.observe(LinkSample{Method: core.MethodProcessModel, RTT: time.Second, BytesOut: 100})
+	assert.Equal(t, 512, tuner.compressionThreshold(), "Small calls should not be taken as the link's throughput")
+	tuner.observe(fast)
+	tuner.interrupted(core.MethodBlobPut)
+	assert.Equal(t, 64<<10, tuner.blobChunkSize(), "A chunk lost with its connection should halve the chunk size")
+	tuner.interrupted(core.MethodProcessModel)
+	assert.Equal(t, 64<<10, tuner.blobChunkSize(), "Other lost calls should leave the chunk size alone")
+}
+
+func TestLinkTunerStatic(t *testing.T) {
+	options := DefaultOptions()
+	WithCompressionThreshold(1 << 20)(&options)
+	WithStaticTuning()(&options)
+	tuner := newLinkTuner(options)
+
+	tuner.observe(LinkSample{Method: core.MethodBlobPut, RTT: time.Second, BytesOut: 64 << 10})
+	tuner.interrupted(core.MethodBlobPut)
+	assert.Equal(t, TuningStats{Static: true, CompressionThreshold: 1 << 20, BlobChunkSize: 1 << 20}, tuner.stats(),
+		"Static values should stay as configured, even outside the bounds")
+}
+
+func TestClientTuningFollowsBandwidth(t *testing.T) {
+	bounds := []Option{
+		WithCompressionThreshold(4 << 10),
+		WithBlobChunkSize(256 << 10),
+		WithTuningBounds(TuningBounds{Min: 1 << 10, Max: 16 << 10}, TuningBounds{Min: 64 << 10, Max: 1 << 20}),
+	}
+
+	t.Run("fast", func(t *testing.T) {
+		client := startTuningPair(t, 0, time.Second, bounds...)
+		uploadRandom(t, client, 2<<20)
+
+		stats := client.Stats()
+		assert.Greater(t, stats.Tuning.BlobChunkSize, 256<<10, "A fast link should get larger chunks")
+		assert.LessOrEqual(t, stats.Tuning.BlobChunkSize, 1<<20, "Chunks should stay within their bounds")
+		assert.Greater(t, stats.Tuning.CompressionThreshold, 4<<10, "A fast link should compress fewer messages")
+		assert.LessOrEqual(t, stats.Tuning.CompressionThreshold, 16<<10, "The threshold should stay within its bounds")
+		assert.NotEmpty(t, stats.Link.History, "The measurements should be reported alongside")
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		client := startTuningPair(t, 1<<20, 50*time.Millisecond, bounds...)
+		uploadRandom(t, client, 768<<10)
+
+		// The last chunk, being short, is quick
+		stats := client.Stats()
+		assert.Less(t, stats.Tuning.BlobChunkSize, 256<<10, "A slow link should get smaller chunks")
+		assert.GreaterOrEqual(t, stats.Tuning.BlobChunkSize, 64<<10, "Chunks should stay within their bounds")
+		assert.Equal(t, 1<<10, stats.Tuning.CompressionThreshold, "A slow link should compress down to the threshold's lower bound")
+	})
+
+	t.Run("static", func(t *testing.T) {
+		client := startTuningPair(t, 0, time.Second, append(bounds, WithStaticTuning())...)
+		uploadRandom(t, client, 2<<20)
+
+		stats := client.Stats()
+		assert.True(t, stats.Tuning.Static, "Static tuning should be reported")
+		assert.Equal(t, 256<<10, stats.Tuning.BlobChunkSize, "Static chunks should keep their size")
+		assert.Equal(t, 4<<10, stats.Tuning.CompressionThreshold, "A static threshold should stay put")
+	})
+}
+
+func TestTuningStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tuning.json")
+	client := startTuningPair(t, 0, time.Second, WithBlobChunkSize(256<<10), WithTuningStateFile(path))
+	uploadRandom(t, client, 2<<20)
+	learned := client.Stats().Tuning
+	require.NoError(t, client.Stop(), "Client should stop")
+	_, err := os.Stat(path)
+	require.NoError(t, err, "Stop should save the tuning state")
+
+	restarted := New(WithBlobChunkSize(256<<10), WithTuningStateFile(path))
+	assert.Equal(t, learned, restarted.Stats().Tuning, "A new client should start from the saved values")
+
+	clamped := New(WithTuningStateFile(path), WithTuningBounds(TuningBounds{Min: 1, Max: 512}, TuningBounds{Min: 1, Max: 128 << 10}))
+	assert.Equal(t, 512, clamped.Stats().Tuning.CompressionThreshold, "Saved values should be moved into the bounds")
+	assert.Equal(t, 128<<10, clamped.Stats().Tuning.BlobChunkSize, "Saved values should be moved into the bounds")
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600), "Writing the state should succeed")
+	corrupt := New(WithLogger(core.NopLogger()), WithTuningStateFile(path))
+	assert.Equal(t, DefaultOptions().BlobChunkSize, corrupt.Stats().Tuning.BlobChunkSize, "An unreadable state should be ignored")
+}
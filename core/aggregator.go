@@ -0,0 +1,174 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "sync"
+
+// Kinds of component named in a StatusSource.
+const (
+	ComponentClient = "client" // A client.Client
+	ComponentServer = "server" // A server.Server
+)
+
+// StatusSource identifies the component a StatusChangeEvent came from, so
+// callbacks shared by several components can tell them apart.
+type StatusSource struct {
+	Kind string // Kind of component, such as ComponentClient
+	Name string // Name given to the component with WithName; empty if none was
+}
+
+// String returns the kind and name of the source, such as "server api", or
+// the kind alone for a component without a name.
+func (s StatusSource) String() string {
+	if s.Name == "" {
+		return s.Kind
+	}
+	return s.Kind + " " + s.Name
+}
+
+// StatusAggregator watches the status of several components, such as the
+// clients and servers of one application, to supervise them together. It
+// passes their status changes on to a single channel and reports whether
+// all of them are running or any has failed. It is safe for concurrent use.
+type StatusAggregator struct {
+	mu         sync.Mutex
+	components map[*aggregatedComponent]struct{}
+	events     chan StatusChangeEvent
+	closed     bool
+	dropped    uint64
+}
+
+// aggregatedComponent is a component added to a StatusAggregator.
+type aggregatedComponent struct {
+	component Component
+	cancel    func()
+}
+
+// NewStatusAggregator creates an aggregator watching no components.
+func NewStatusAggregator() *StatusAggregator {
+	return &StatusAggregator{
+		components: make(map[*aggregatedComponent]struct{}),
+		events:     make(chan StatusChangeEvent, statusQueueSize),
+	}
+}
+
+// Add watches component, passing its status changes on to Events from now
+// on. Calling remove stops watching it; calling it again does nothing.
+// Adding to a closed aggregator does nothing.
+func (a *StatusAggregator) Add(component Component) (remove func()) {
+	member := &aggregatedComponent{component: component}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return func() {}
+	}
+	a.components[member] = struct{}{}
+	a.mu.Unlock()
+
+	// Subscribed outside the lock, as a component may deliver events while
+	// it is being added
+	cancel := component.OnStatusChange(a.forward)
+	a.mu.Lock()
+	if _, ok := a.components[member]; !ok {
+		// Removed or closed meanwhile
+		a.mu.Unlock()
+		cancel()
+		return func() {}
+	}
+	member.cancel = cancel
+	a.mu.Unlock()
+
+	return func() {
+		a.mu.Lock()
+		_, ok := a.components[member]
+		delete(a.components, member)
+		a.mu.Unlock()
+		if ok {
+			cancel()
+		}
+	}
+}
+
+// forward passes event on to Events, dropping it if the channel is full so
+// a reader that falls behind cannot hold up the components' callbacks.
+func (a *StatusAggregator) forward(event StatusChangeEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.events <- event:
+	default:
+		a.dropped++
+	}
+}
+
+// Events returns the channel the status changes of every component are
+// sent on, each with the Source of the component it came from. Changes
+// arriving while the channel is full are dropped and counted by Dropped.
+// The channel is closed by Close.
+func (a *StatusAggregator) Events() <-chan StatusChangeEvent {
+	return a.events
+}
+
+// Dropped returns the number of status changes dropped because Events was
+// full.
+func (a *StatusAggregator) Dropped() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.dropped
+}
+
+// AllRunning reports whether every component is StatusRunning. It reports
+// false when no component has been added, so an empty aggregator is never
+// taken for a healthy one.
+func (a *StatusAggregator) AllRunning() bool {
+	components := a.watched()
+	for _, component := range components {
+		if component.Status() != StatusRunning {
+			return false
+		}
+	}
+	return len(components) > 0
+}
+
+// AnyFailed reports whether any component is StatusFailed.
+func (a *StatusAggregator) AnyFailed() bool {
+	for _, component := range a.watched() {
+		if component.Status() == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// watched returns the components being watched.
+func (a *StatusAggregator) watched() []Component {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	components := make([]Component, 0, len(a.components))
+	for member := range a.components {
+		components = append(components, member.component)
+	}
+	return components
+}
+
+// Close stops watching every component and closes Events.
+func (a *StatusAggregator) Close() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	members := a.components
+	a.components = make(map[*aggregatedComponent]struct{})
+	close(a.events)
+	a.mu.Unlock()
+
+	for member := range members {
+		if member.cancel != nil {
+			member.cancel()
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusSourceString(t *testing.T) {
+	assert.Equal(t, "server api", StatusSource{Kind: ComponentServer, Name: "api"}.String(), "A named source should give its kind and name")
+	assert.Equal(t, "client", StatusSource{Kind: ComponentClient}.String(), "An unnamed source should give its kind")
+}
+
+func TestStatusAggregator(t *testing.T) {
+	aggregator := NewStatusAggregator()
+	assert.False(t, aggregator.AllRunning(), "An empty aggregator should not report all running")
+	assert.False(t, aggregator.AnyFailed(), "An empty aggregator should not report a failure")
+
+	first, second := NewMockComponent(StatusStopped), NewMockComponent(StatusStopped)
+	aggregator.Add(first)
+	aggregator.Add(second)
+	require.NoError(t, first.Start(), "First component should start")
+	assert.False(t, aggregator.AllRunning(), "One stopped component should keep the aggregator from reporting all running")
+	require.NoError(t, second.Start(), "Second component should start")
+	assert.True(t, aggregator.AllRunning(), "Both components should be reported running")
+
+	second.status = StatusFailed
+	second.notifyStatusChange(StatusRunning, StatusFailed, nil)
+	assert.False(t, aggregator.AllRunning(), "A failed component should keep the aggregator from reporting all running")
+	assert.True(t, aggregator.AnyFailed(), "The failed component should be reported")
+
+	var statuses []Status
+	for i := 0; i < 3; i++ {
+		statuses = append(statuses, (<-aggregator.Events()).NewStatus)
+	}
+	assert.Equal(t, []Status{StatusRunning, StatusRunning, StatusFailed}, statuses, "Events of both components should arrive on one channel in order")
+
+	aggregator.Close()
+	_, open := <-aggregator.Events()
+	assert.False(t, open, "Close should close the events channel")
+	assert.NotPanics(t, func() { first.Stop() }, "Changes after Close should be ignored")
+	aggregator.Close()
+}
+
+func TestStatusAggregatorDropsWhenFull(t *testing.T) {
+	aggregator := NewStatusAggregator()
+	defer aggregator.Close()
+	component := NewMockComponent(StatusStopped)
+	aggregator.Add(component)
+
+	for i := 0; i < statusQueueSize+5; i++ {
+		component.notifyStatusChange(StatusStopped, StatusRunning, nil)
+	}
+	assert.Len(t, aggregator.Events(), statusQueueSize, "The channel should hold as many events as it can")
+	assert.Equal(t, uint64(5), aggregator.Dropped(), "Events arriving while the channel is full should be dropped")
+}
+
+func TestStatusAggregatorRemove(t *testing.T) {
+	aggregator := NewStatusAggregator()
+	defer aggregator.Close()
+	running := NewMockComponent(StatusStopped)
+	require.NoError(t, running.Start(), "Component should start")
+	aggregator.Add(running)
+
+	remove := aggregator.Add(NewMockComponent(StatusStopped))
+	assert.False(t, aggregator.AllRunning(), "The stopped component should be counted")
+	remove()
+	remove()
+	assert.True(t, aggregator.AllRunning(), "A removed component should no longer be counted")
+}
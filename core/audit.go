@@ -0,0 +1,53 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEvent records a request a server has replied to.
+type AuditEvent struct {
+	Time         time.Time     `json:"time"`                   // When the reply was sent
+	ConnectionID string        `json:"connectionId,omitempty"` // ClientInfo.ID of the connection the request arrived on
+	RemoteAddr   string        `json:"remoteAddr"`             // Address of the client
+	Method       string        `json:"method"`                 // Method that was called
+	RequestID    string        `json:"requestId"`              // JSON-RPC ID assigned by the client
+	Principal    string        `json:"principal,omitempty"`    // Authenticated caller, if any
+	Success      bool          `json:"success"`                // False when the reply was a JSON-RPC error
+	Duration     time.Duration `json:"duration"`               // Time from arrival to reply
+	CancelCause  Cause         `json:"cancelCause,omitempty"`  // Why the server cancelled the request; empty if it did not
+
+	// Params are the request's parameters as received. They may hold secrets,
+	// so they are left out of the JSON form; sinks that record them should
+	// redact them first. Sinks must not modify or retain them.
+	Params json.RawMessage `json:"-"`
+}
+
+// AuditSink receives an event for every request a server replies to. Audit is
+// called synchronously once the reply is encoded and should not block; an
+// error marks the sink as degraded without affecting the request.
+type AuditSink interface {
+	Audit(event AuditEvent) error
+}
+
+// auditLog writes audit events as JSON lines.
+type auditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewAuditLog returns an AuditSink writing one JSON object per line to w,
+// typically an append-only file.
+func NewAuditLog(w io.Writer) AuditSink {
+	return &auditLog{enc: json.NewEncoder(w)}
+}
+
+// Audit implements AuditSink.
+func (l *auditLog) Audit(event AuditEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(event)
+}
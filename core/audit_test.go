@@ -0,0 +1,33 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf)
+
+	event := AuditEvent{
+		Time:      time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Method:    MethodProcessModel,
+		RequestID: "7",
+		Principal: "ci",
+		Success:   true,
+		Duration:  time.Millisecond,
+	}
+	require.NoError(t, log.Audit(event), "Audit should succeed")
+	require.NoError(t, log.Audit(event), "Audit should succeed")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2, "Each event should be one line")
+	var decoded AuditEvent
+	require.NoError(t, json.Unmarshal(lines[0], &decoded), "Line should be JSON")
+	assert.Equal(t, event, decoded, "Event should round-trip")
+}
@@ -0,0 +1,150 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MethodAuthenticate authenticates the connection with one of the server's
+// registered auth schemes. When a server has any scheme registered, every
+// other method except MethodPing requires a successful authentication first.
+const MethodAuthenticate = "mcp.authenticate"
+
+// CodeUnauthenticated is the JSON-RPC error code returned when a request is
+// not authenticated, the credentials are rejected, or the session expired.
+const CodeUnauthenticated int64 = -32001
+
+// CodeUnauthorized is the JSON-RPC error code returned when the caller is
+// authenticated but not allowed to call the method.
+const CodeUnauthorized int64 = -32003
+
+// Reasons an AuthError gives for rejecting credentials.
+const (
+	AuthReasonMalformed     = "malformed"      // Credentials could not be parsed
+	AuthReasonBadSignature  = "bad_signature"  // Signature did not verify against a known key
+	AuthReasonExpired       = "expired"        // Credentials are past their expiry
+	AuthReasonNotYetValid   = "not_yet_valid"  // Credentials are not valid yet
+	AuthReasonWrongIssuer   = "wrong_issuer"   // Credentials were issued by an untrusted party
+	AuthReasonWrongAudience = "wrong_audience" // Credentials were issued for another service
+	AuthReasonUnavailable   = "unavailable"    // The verifier could not check the credentials, e.g. its keys could not be fetched
+)
+
+// AuthError is returned by an auth verifier to report why it rejected
+// credentials. The server tells the client only the Reason category, as
+// AuthErrorData; Err, which may describe the credentials, is only logged.
+type AuthError struct {
+	Reason string
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	if e.Err == nil {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// AuthErrorData is the error data of a CodeUnauthenticated error returned
+// for credentials whose verifier reported an AuthError.
+type AuthErrorData struct {
+	Reason string `json:"reason"`
+}
+
+// AuthSchemeToken is the scheme a client configured with a bearer token
+// authenticates with.
+const AuthSchemeToken = "token"
+
+// Credential keys understood by the built-in auth schemes.
+const (
+	CredentialToken     = "token"     // Static token
+	CredentialKeyID     = "key_id"    // HMAC key identifier
+	CredentialTimestamp = "timestamp" // HMAC signing time in Unix milliseconds
+	CredentialSignature = "signature" // Hex-encoded HMAC-SHA256 signature
+)
+
+// AuthRequest carries the parameters of a MethodAuthenticate call.
+type AuthRequest struct {
+	Scheme      string            `json:"scheme"`
+	Credentials map[string]string `json:"credentials"`
+}
+
+// Credentials are what a client presents to authenticate: the scheme it
+// chose and the scheme's credential values.
+type Credentials struct {
+	Scheme string
+	Values map[string]string
+}
+
+// Token returns the bearer token presented with the token scheme, or "".
+func (c Credentials) Token() string {
+	return c.Values[CredentialToken]
+}
+
+// AuthResponse is the result of a successful MethodAuthenticate call.
+type AuthResponse struct {
+	Principal Principal `json:"principal"`
+}
+
+// Principal identifies an authenticated caller.
+type Principal struct {
+	ID        string    `json:"id"`
+	Roles     []string  `json:"roles,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"` // Zero means the principal does not expire
+}
+
+// Expired reports whether the principal's authentication has lapsed at now.
+func (p *Principal) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// HasRole reports whether the principal holds the given role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a context carrying the authenticated principal.
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal for the request,
+// or nil if the connection is not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// SignHMAC builds credentials for the HMAC auth scheme, signing the key ID and
+// timestamp with the shared secret.
+func SignHMAC(keyID string, secret []byte, at time.Time) map[string]string {
+	timestamp := strconv.FormatInt(at.UnixMilli(), 10)
+	return map[string]string{
+		CredentialKeyID:     keyID,
+		CredentialTimestamp: timestamp,
+		CredentialSignature: hex.EncodeToString(HMACSignature(secret, keyID, timestamp)),
+	}
+}
+
+// HMACSignature computes the HMAC-SHA256 signature over a key ID and timestamp.
+func HMACSignature(secret []byte, keyID, timestamp string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(keyID + "\n" + timestamp))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalExpired(t *testing.T) {
+	now := time.Now()
+
+	forever := &Principal{ID: "ci"}
+	assert.False(t, forever.Expired(now), "Principal without expiry should never expire")
+
+	expiring := &Principal{ID: "human", ExpiresAt: now.Add(time.Minute)}
+	assert.False(t, expiring.Expired(now), "Principal should be valid before its expiry")
+	assert.True(t, expiring.Expired(now.Add(time.Minute)), "Principal should expire at its expiry")
+}
+
+func TestPrincipalHasRole(t *testing.T) {
+	principal := &Principal{ID: "ci", Roles: []string{"builder", "reader"}}
+	assert.True(t, principal.HasRole("reader"), "Principal should hold its roles")
+	assert.False(t, principal.HasRole("admin"), "Principal should not hold other roles")
+}
+
+func TestPrincipalContext(t *testing.T) {
+	assert.Nil(t, PrincipalFromContext(context.Background()), "Plain context should carry no principal")
+
+	principal := &Principal{ID: "ci"}
+	ctx := ContextWithPrincipal(context.Background(), principal)
+	assert.Same(t, principal, PrincipalFromContext(ctx), "Context should carry the principal")
+}
+
+func TestSignHMAC(t *testing.T) {
+	secret := []byte("secret")
+	at := time.UnixMilli(1700000000123)
+
+	credentials := SignHMAC("svc", secret, at)
+	assert.Equal(t, "svc", credentials[CredentialKeyID], "Credentials should carry the key ID")
+	assert.Equal(t, "1700000000123", credentials[CredentialTimestamp], "Credentials should carry the signing time")
+	assert.Equal(t, hex.EncodeToString(HMACSignature(secret, "svc", "1700000000123")), credentials[CredentialSignature], "Signature should cover key ID and timestamp")
+	assert.NotEqual(t, credentials[CredentialSignature], SignHMAC("svc", []byte("other"), at)[CredentialSignature], "Signature should depend on the secret")
+}
@@ -0,0 +1,43 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "time"
+
+// DeadlineStrategy controls how a batch's overall deadline is divided among its items.
+type DeadlineStrategy string
+
+const (
+	// DeadlineFirstComeAll gives every item whatever remains of the batch deadline,
+	// so one slow item can consume the budget of all items after it.
+	DeadlineFirstComeAll DeadlineStrategy = "firstComeAll"
+
+	// DeadlineEqual gives each item an equal share of the remaining deadline.
+	DeadlineEqual DeadlineStrategy = "equal"
+
+	// DeadlineWeighted shares the remaining deadline in proportion to each item's
+	// MetadataDeadlineWeight, defaulting to a weight of 1.
+	DeadlineWeighted DeadlineStrategy = "weighted"
+)
+
+// MetadataDeadlineWeight is the metadata key carrying an item's weight for DeadlineWeighted.
+const MetadataDeadlineWeight = "deadline_weight"
+
+// BatchRequest carries several model requests processed in a single round trip.
+type BatchRequest struct {
+	Requests         []*ModelRequest  `json:"requests"`
+	Timeout          time.Duration    `json:"timeout,omitempty"`          // Overall budget for the batch; zero means unbounded
+	DeadlineStrategy DeadlineStrategy `json:"deadlineStrategy,omitempty"` // Overrides the server's strategy when set
+}
+
+// BatchResponse holds one response per request, in request order.
+type BatchResponse struct {
+	Responses []*ModelResponse  `json:"responses"`
+	Timings   []BatchItemTiming `json:"timings"`
+}
+
+// BatchItemTiming reports how long a batch item was allowed to run and how long it took.
+type BatchItemTiming struct {
+	Budget           time.Duration `json:"budget"`           // Time allotted to the item; zero means unbounded
+	Elapsed          time.Duration `json:"elapsed"`          // Time the item actually ran
+	DeadlineExceeded bool          `json:"deadlineExceeded"` // Whether the item was cut off by its deadline
+}
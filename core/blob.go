@@ -0,0 +1,110 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// Method names for blobs, files such as model artifacts that are too large
+// for a single message. Blobs are sent in chunks, each with its own checksum,
+// and named by a BlobID that model requests refer to in BlobRefs.
+const (
+	// MethodBlobPut appends the chunk of a BlobPutRequest to the blob it
+	// names, creating the blob with the first chunk and storing it once the
+	// final chunk arrives. It returns a BlobPutResponse.
+	MethodBlobPut = "mcp.blob.put"
+
+	// MethodBlobGet returns a BlobGetResponse holding the chunk of a stored
+	// blob named by a BlobGetRequest.
+	MethodBlobGet = "mcp.blob.get"
+)
+
+// CodeBlobNotFound is the JSON-RPC error code returned for a blob the server
+// does not store, whether named by a MethodBlobGet call or in the BlobRefs of
+// a model request. Clients report it as ErrBlobNotFound.
+const CodeBlobNotFound int64 = -32013
+
+// ErrBlobNotFound is returned for a blob the server does not store.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrBlobChecksum is returned for a chunk or blob whose content does not
+// match its checksum.
+var ErrBlobChecksum = errors.New("blob checksum mismatch")
+
+// BlobID names a blob on the server storing it. IDs are chosen by the
+// uploader, and are made of letters, digits, '-' and '_'.
+type BlobID string
+
+// maxBlobIDLength bounds the length of a valid BlobID.
+const maxBlobIDLength = 128
+
+// Valid reports whether the ID is one a server accepts, safe to use as a
+// file name.
+func (id BlobID) Valid() bool {
+	if id == "" || len(id) > maxBlobIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// BlobMeta describes a blob. Name and MimeType are the uploader's; the
+// server fills in Size and SHA256 once the blob is stored.
+type BlobMeta struct {
+	Name     string            `json:"name,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Size     int64             `json:"size,omitempty"`   // Bytes in the blob
+	SHA256   string            `json:"sha256,omitempty"` // Hex encoded
+}
+
+// BlobChecksum returns the checksum of a chunk or blob, its SHA-256 digest
+// hex encoded.
+func BlobChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// BlobPutRequest carries a chunk of a blob in a MethodBlobPut call. Chunks
+// are sent in order; one starting before the end of what the server has
+// already received is taken to be sent again, and is acknowledged without
+// being written.
+type BlobPutRequest struct {
+	ID       BlobID    `json:"id"`
+	Offset   int64     `json:"offset"`         // Position of the chunk in the blob
+	Data     []byte    `json:"data,omitempty"` // Base64 encoded in JSON
+	Checksum string    `json:"checksum"`       // BlobChecksum of Data
+	Meta     *BlobMeta `json:"meta,omitempty"` // Sent with the first chunk
+	Final    bool      `json:"final,omitempty"`
+	SHA256   string    `json:"sha256,omitempty"` // BlobChecksum of the whole blob, sent with the final chunk
+}
+
+// BlobPutResponse is the result returned for a MethodBlobPut call.
+type BlobPutResponse struct {
+	ID       BlobID    `json:"id"`
+	Received int64     `json:"received"`       // Bytes of the blob received so far
+	Meta     *BlobMeta `json:"meta,omitempty"` // Set once the blob is stored
+}
+
+// BlobGetRequest names the chunk of a blob a MethodBlobGet call reads.
+type BlobGetRequest struct {
+	ID     BlobID `json:"id"`
+	Offset int64  `json:"offset"`
+	Length int    `json:"length"` // Largest chunk to return
+}
+
+// BlobGetResponse is the result returned for a MethodBlobGet call.
+type BlobGetResponse struct {
+	Data     []byte   `json:"data,omitempty"` // Base64 encoded in JSON
+	Checksum string   `json:"checksum"`       // BlobChecksum of Data
+	Meta     BlobMeta `json:"meta"`
+	EOF      bool     `json:"eof,omitempty"` // Whether the chunk ends the blob
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobIDValid(t *testing.T) {
+	assert.True(t, BlobID("blob-0aF_9").Valid(), "Letters, digits, '-' and '_' should be valid")
+	assert.False(t, BlobID("").Valid(), "Empty ID should be invalid")
+	assert.False(t, BlobID("../etc").Valid(), "Path elements should be invalid")
+	assert.False(t, BlobID("a b").Valid(), "Spaces should be invalid")
+	assert.False(t, BlobID(strings.Repeat("a", 129)).Valid(), "Overlong ID should be invalid")
+}
+
+func TestBlobChecksum(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", BlobChecksum(nil), "Checksum should be the hex encoded SHA-256")
+	assert.NotEqual(t, BlobChecksum([]byte("a")), BlobChecksum([]byte("b")), "Different data should have different checksums")
+}
@@ -0,0 +1,66 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// MethodCancelRequest is the notification a client sends, as in the Language
+// Server Protocol, when it gives up on a call it has not been answered yet.
+// The server cancels the context of the call with CauseClientCancel. Calls
+// already answered, or unknown to the server, are ignored.
+const MethodCancelRequest = "$/cancelRequest"
+
+// CodeRequestCancelled is the JSON-RPC error code of a call that failed
+// because the client cancelled it with MethodCancelRequest. It is the one the
+// Language Server Protocol uses.
+const CodeRequestCancelled int64 = -32800
+
+// CodeDeadlineExceeded is the JSON-RPC error code of a call that failed
+// because its handler ran longer than the server's handler timeout allows.
+const CodeDeadlineExceeded int64 = -32014
+
+// CancelRequestParams names the call a MethodCancelRequest notification
+// cancels by its JSON-RPC ID.
+type CancelRequestParams struct {
+	ID jsonrpc2.ID `json:"id"`
+}
+
+// Cause says why a server cancelled the context of a request. Servers cancel
+// request contexts with a Cause, so handlers can clean up according to it,
+// e.g. discarding partial work the client no longer wants but checkpointing
+// it on shutdown. Cause implements error, as context.WithCancelCause needs.
+type Cause string
+
+// Causes a server cancels request contexts with.
+const (
+	CauseClientCancel Cause = "client_cancel" // The client cancelled the request, with mcp.cancelJob or MethodCancelRequest
+	CauseDeadline     Cause = "deadline"      // The request ran out of time
+	CauseShutdown     Cause = "shutdown"      // The server is stopping
+	CauseAdminKill    Cause = "admin_kill"    // An operator cancelled the request with Server.CancelRequest
+	CauseDisconnect   Cause = "disconnect"    // The client's connection ended
+)
+
+func (c Cause) Error() string {
+	return "request cancelled: " + string(c)
+}
+
+// CancelCause returns why ctx was cancelled: the Cause it was cancelled with,
+// CauseDeadline if its deadline passed, or "" if it is not done or was
+// cancelled for another reason.
+func CancelCause(ctx context.Context) Cause {
+	if ctx.Err() == nil {
+		return ""
+	}
+	var cause Cause
+	if errors.As(context.Cause(ctx), &cause) {
+		return cause
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return CauseDeadline
+	}
+	return ""
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelCause(t *testing.T) {
+	assert.Equal(t, Cause(""), CancelCause(context.Background()), "Live context should have no cause")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	assert.Equal(t, Cause(""), CancelCause(ctx), "Context not yet cancelled should have no cause")
+	cancel(CauseDisconnect)
+	assert.Equal(t, CauseDisconnect, CancelCause(ctx), "Cause should be returned")
+
+	child, stop := context.WithCancel(ctx)
+	defer stop()
+	assert.Equal(t, CauseDisconnect, CancelCause(child), "Children should inherit the cause")
+
+	ctx, cancel = context.WithCancelCause(context.Background())
+	cancel(fmt.Errorf("draining: %w", CauseShutdown))
+	assert.Equal(t, CauseShutdown, CancelCause(ctx), "Wrapped cause should be found")
+
+	ctx, stop = context.WithCancel(context.Background())
+	stop()
+	assert.Equal(t, Cause(""), CancelCause(ctx), "Plain cancellation should have no cause")
+
+	ctx, stop = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer stop()
+	<-ctx.Done()
+	assert.Equal(t, CauseDeadline, CancelCause(ctx), "Expired deadline should be reported as one")
+}
@@ -0,0 +1,102 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// ProtocolVersion is the version of the protocol this package implements. It
+// grows whenever a change would confuse a peer implementing an older one.
+const ProtocolVersion = 1
+
+// MethodInitialize is the handshake a client performs on every new
+// connection, before any other request except MethodNegotiate. The client
+// sends its Capabilities and the server replies with its own; both sides then
+// use what Negotiate makes of the two. A server that does not know the method
+// replies with an error, and the client continues without gating anything.
+const MethodInitialize = "mcp.initialize"
+
+// CodeUnsupportedCapability is the JSON-RPC error code returned for a request
+// that needs a feature the connection did not negotiate. Clients report it as
+// ErrUnsupportedCapability.
+const CodeUnsupportedCapability int64 = -32009
+
+// CodeUnsupportedVersion is the JSON-RPC error code returned when the client's
+// protocol version is below the server's minimum, or the client did not
+// initialize a server that requires a minimum. Clients report it as
+// ErrUnsupportedVersion.
+const CodeUnsupportedVersion int64 = -32010
+
+// ErrUnsupportedCapability is returned for a call that needs a feature the
+// peer does not support.
+var ErrUnsupportedCapability = errors.New("unsupported capability")
+
+// ErrUnsupportedVersion is returned when the peer refused the handshake
+// because of the protocol version.
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// Feature names an optional part of the protocol that peers negotiate.
+type Feature string
+
+// Features defined by the protocol.
+const (
+	FeatureStreaming   Feature = "streaming"   // MethodProcessModelStream
+	FeatureCompression Feature = "compression" // Compressed frames, agreed on by MethodNegotiate
+	FeatureBatch       Feature = "batch"       // MethodProcessModelBatch
+)
+
+// AllFeatures returns every feature this package implements, which peers
+// announce unless configured otherwise.
+func AllFeatures() []Feature {
+	return []Feature{FeatureBatch, FeatureCompression, FeatureStreaming}
+}
+
+// Capabilities is what a peer announces in the handshake, and what a
+// connection negotiated once it is over. It is the parameters and result of
+// a MethodInitialize call.
+type Capabilities struct {
+	ProtocolVersion int       `json:"protocolVersion"`
+	Features        []Feature `json:"features,omitempty"` // Sorted
+}
+
+// Supports reports whether feature is among the capabilities.
+func (c *Capabilities) Supports(feature Feature) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate returns the capabilities two peers share: the lower of their
+// protocol versions and the features both support.
+func Negotiate(a, b Capabilities) Capabilities {
+	negotiated := Capabilities{ProtocolVersion: min(a.ProtocolVersion, b.ProtocolVersion)}
+	for _, feature := range a.Features {
+		if b.Supports(feature) && !negotiated.Supports(feature) {
+			negotiated.Features = append(negotiated.Features, feature)
+		}
+	}
+	sort.Slice(negotiated.Features, func(i, j int) bool { return negotiated.Features[i] < negotiated.Features[j] })
+	return negotiated
+}
+
+// capabilitiesKey is the context key of the negotiated capabilities.
+type capabilitiesKey struct{}
+
+// ContextWithCapabilities returns a copy of ctx carrying the capabilities
+// negotiated on the connection a request arrived on.
+func ContextWithCapabilities(ctx context.Context, caps *Capabilities) context.Context {
+	return context.WithValue(ctx, capabilitiesKey{}, caps)
+}
+
+// CapabilitiesFromContext returns the capabilities negotiated on the
+// connection of the request being handled, or nil if the client did not
+// initialize.
+func CapabilitiesFromContext(ctx context.Context) *Capabilities {
+	caps, _ := ctx.Value(capabilitiesKey{}).(*Capabilities)
+	return caps
+}
@@ -0,0 +1,37 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// ClientInfo describes a client connected to a server.
+type ClientInfo struct {
+	ID          string     `json:"id"`                  // Identifies the connection for as long as it lasts; unique within the server
+	RemoteAddr  string     `json:"remoteAddr"`          // Peer of the connection; empty for in-process and stdio connections
+	ConnectedAt time.Time  `json:"connectedAt"`         // When the server began serving the connection
+	Principal   *Principal `json:"principal,omitempty"` // Who the client authenticated as; nil until it authenticates
+}
+
+// PeerInfo describes the connection a request arrived on, for handlers that
+// apply per-client quotas or log who is calling.
+type PeerInfo struct {
+	ClientInfo
+	Capabilities *Capabilities // Negotiated in the handshake; nil if the client did not initialize
+}
+
+type peerKey struct{}
+
+// ContextWithPeer returns a copy of ctx carrying the connection a request
+// arrived on.
+func ContextWithPeer(ctx context.Context, peer PeerInfo) context.Context {
+	return context.WithValue(ctx, peerKey{}, peer)
+}
+
+// PeerFromContext returns the connection the request being handled arrived
+// on, reporting false outside a request.
+func PeerFromContext(ctx context.Context) (PeerInfo, bool) {
+	peer, ok := ctx.Value(peerKey{}).(PeerInfo)
+	return peer, ok
+}
@@ -0,0 +1,382 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentTypeJSON is the content type of JSON message bodies, the default.
+const ContentTypeJSON = "application/vscode-jsonrpc; charset=utf-8"
+
+// Codec turns JSON-RPC messages into frame bodies and back. Messages reach a
+// codec in the form the JSON-RPC library produces, so a codec other than JSON
+// transcodes them; see the msgpack package for one that carries Binary values
+// as raw bytes.
+type Codec interface {
+	// ContentType names the codec in frame headers and negotiation.
+	ContentType() string
+
+	// Marshal encodes obj, a JSON-RPC request or response.
+	Marshal(obj interface{}) ([]byte, error)
+
+	// Unmarshal decodes a frame body into v, which accepts JSON-RPC messages.
+	// The body is reused once it returns, so v must not keep it.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes messages as JSON, as jsonrpc2.VSCodeObjectCodec does.
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) Marshal(obj interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if ok, err := writeMessage(&buf, obj); ok || err != nil {
+		return buf.Bytes(), err
+	}
+	return json.Marshal(obj)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if ok, err := readMessage(data, v); ok {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// MethodNegotiate is the first request a client sends on a new connection
+// when it wants compression or a codec other than JSON, before any other
+// traffic. The server answers with what both sides use from then on. A server
+// that does not know the method replies with an error, and the connection
+// stays plain JSON.
+const MethodNegotiate = "mcp.negotiate"
+
+// NegotiateRequest is the parameters of a MethodNegotiate call.
+type NegotiateRequest struct {
+	Compression []Compression `json:"compression,omitempty"` // Algorithms the client accepts, in order of preference
+	Codecs      []string      `json:"codecs,omitempty"`      // Content types of the codecs the client accepts besides JSON
+}
+
+// NegotiateResponse is the result of a MethodNegotiate call.
+type NegotiateResponse struct {
+	Compression Compression `json:"compression,omitempty"` // Algorithm chosen by the server; CompressionNone to stay uncompressed
+	Codec       string      `json:"codec,omitempty"`       // Content type of the codec chosen by the server; empty for JSON
+}
+
+// FrameCodec is a jsonrpc2.ObjectCodec that frames messages with a
+// Content-Length header, like jsonrpc2.VSCodeObjectCodec. Bodies are encoded
+// with Codec, and those of at least Threshold bytes are compressed with
+// Compression. Frames name a codec other than JSON in a Content-Type header
+// and a compression in a Content-Encoding header, so a peer that expects
+// another codec fails with a clear error rather than garbage. Incoming bodies
+// over MaxBytes are discarded without being read into memory, and reported
+// with a FrameTooLargeError. An Advisor, if set, has the last word on which
+// messages are compressed.
+type FrameCodec struct {
+	Codec       Codec              // Codec of message bodies; nil means JSONCodec
+	Compression Compression        // Algorithm outgoing messages are compressed with; CompressionNone sends them uncompressed
+	Threshold   int                // Smallest encoded message, in bytes, that is compressed
+	MaxBytes    int64              // Largest incoming body, in bytes, before and after decompression; zero is unlimited
+	Advisor     CompressionAdvisor // Consulted on every outgoing message when Compression is set; nil compresses all those of at least Threshold bytes
+}
+
+func (c FrameCodec) codec() Codec {
+	if c.Codec == nil {
+		return JSONCodec
+	}
+	return c.Codec
+}
+
+// frameBuffers reuses the buffers frames are read into and their headers
+// written with, as most messages are small and many are sent.
+var frameBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// maxPooledFrame bounds the buffers kept in frameBuffers, so that one large
+// message does not keep its memory for the life of the process.
+const maxPooledFrame = 1 << 20
+
+// getFrameBuffer returns an empty buffer from frameBuffers.
+func getFrameBuffer() *bytes.Buffer {
+	buf := frameBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putFrameBuffer returns buf to frameBuffers unless it has grown too large.
+func putFrameBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledFrame {
+		frameBuffers.Put(buf)
+	}
+}
+
+// marshal encodes obj, a JSON-RPC message, with codec. JSON messages are
+// written to buf, a pooled buffer, so that encoding one allocates nothing.
+func marshal(codec Codec, obj interface{}, buf *bytes.Buffer) ([]byte, error) {
+	if codec == JSONCodec {
+		if ok, err := writeMessage(buf, obj); ok || err != nil {
+			return buf.Bytes(), err
+		}
+	}
+	return codec.Marshal(obj)
+}
+
+// WriteObject implements jsonrpc2.ObjectCodec.
+func (c FrameCodec) WriteObject(stream io.Writer, obj interface{}) error {
+	codec := c.codec()
+	body := getFrameBuffer()
+	defer putFrameBuffer(body)
+	data, err := marshal(codec, obj, body)
+	if err != nil {
+		return err
+	}
+
+	compressed := c.Compression != CompressionNone && len(data) >= c.Threshold
+	var advised func(int, time.Duration)
+	if c.Advisor != nil && c.Compression != CompressionNone {
+		advised = c.Advisor.Advise(obj, data)
+		compressed = compressed && advised != nil
+	}
+	if compressed {
+		start := time.Now()
+		if data, err = compress(c.Compression, data); err != nil {
+			return err
+		}
+		if advised != nil {
+			advised(len(data), time.Since(start))
+		}
+	}
+	header := getFrameBuffer()
+	defer putFrameBuffer(header)
+	header.WriteString("Content-Length: ")
+	header.Write(strconv.AppendInt(header.AvailableBuffer(), int64(len(data)), 10))
+	header.WriteString("\r\n")
+	if compressed {
+		header.WriteString("Content-Encoding: ")
+		header.WriteString(string(c.Compression))
+		header.WriteString("\r\n")
+	}
+	if contentType := codec.ContentType(); contentType != ContentTypeJSON {
+		header.WriteString("Content-Type: ")
+		header.WriteString(contentType)
+		header.WriteString("\r\n")
+	}
+	header.WriteString("\r\n")
+
+	if _, err := stream.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err = stream.Write(data)
+	return err
+}
+
+// ReadObject implements jsonrpc2.ObjectCodec. A plain JSON frame read into a
+// *json.RawMessage is handed over as read; any other is read into a reused
+// buffer.
+func (c FrameCodec) ReadObject(stream *bufio.Reader, v interface{}) error {
+	var contentLength uint64
+	var compression Compression
+	contentType := ContentTypeJSON
+	for {
+		line, err := readHeaderLine(stream)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(line, []byte("\r\n")) {
+			return fmt.Errorf(`jsonrpc2: line endings must be \r\n`)
+		}
+		line = line[:len(line)-2]
+		if len(line) == 0 {
+			break
+		}
+		name, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimSpace(value)
+		switch string(name) {
+		case "Content-Length":
+			if contentLength, err = parseContentLength(value); err != nil {
+				return err
+			}
+		case "Content-Encoding":
+			compression = Compression(value)
+		case "Content-Type":
+			contentType = string(value)
+		}
+	}
+	if contentLength == 0 {
+		return fmt.Errorf("jsonrpc2: no Content-Length header found")
+	}
+	codec := c.codec()
+	if contentType != codec.ContentType() && !(codec.ContentType() == ContentTypeJSON && isJSON(contentType)) {
+		return fmt.Errorf("jsonrpc2: peer sent a %s message, expected %s", contentType, codec.ContentType())
+	}
+	if c.MaxBytes > 0 && int64(contentLength) > c.MaxBytes {
+		id, err := discardFrame(stream, int64(contentLength))
+		if err != nil {
+			return err
+		}
+		return &FrameTooLargeError{ID: id, Size: int64(contentLength), Limit: c.MaxBytes}
+	}
+
+	raw, isRaw := v.(*json.RawMessage)
+	if isRaw && compression == CompressionNone && codec == JSONCodec {
+		body := make([]byte, contentLength)
+		if _, err := io.ReadFull(stream, body); err != nil {
+			return err
+		}
+		if !json.Valid(body) {
+			return codec.Unmarshal(body, v)
+		}
+		*raw = body
+		return nil
+	}
+
+	buf := getFrameBuffer()
+	defer putFrameBuffer(buf)
+	buf.Grow(int(contentLength))
+	body := buf.AvailableBuffer()[:contentLength]
+	if _, err := io.ReadFull(stream, body); err != nil {
+		return err
+	}
+	if compression == CompressionNone {
+		return codec.Unmarshal(body, v)
+	}
+	compressor, ok := LookupCompressor(compression)
+	if !ok {
+		return fmt.Errorf("jsonrpc2: unsupported Content-Encoding %q", compression)
+	}
+	r, err := compressor.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("jsonrpc2: invalid %s body: %w", compression, err)
+	}
+	defer r.Close()
+	var decompressed io.Reader = r
+	if c.MaxBytes > 0 {
+		decompressed = io.LimitReader(r, c.MaxBytes+1)
+	}
+	out := getFrameBuffer()
+	defer putFrameBuffer(out)
+	if _, err := out.ReadFrom(decompressed); err != nil {
+		return fmt.Errorf("jsonrpc2: invalid %s body: %w", compression, err)
+	}
+	if body = out.Bytes(); c.MaxBytes > 0 && int64(len(body)) > c.MaxBytes {
+		// Look for the ID in the rest of the body too, but only so far, as it
+		// may expand without bound
+		rest := io.LimitReader(io.MultiReader(bytes.NewReader(body), r), 16*c.MaxBytes)
+		id := parseID(scanID(bufio.NewReader(rest)))
+		return &FrameTooLargeError{ID: id, Size: int64(len(body)), Limit: c.MaxBytes}
+	}
+	return codec.Unmarshal(body, v)
+}
+
+// readHeaderLine reads a header line, up to and including its '\n', from
+// stream. The line may be part of the stream's buffer, valid until the next
+// read.
+func readHeaderLine(stream *bufio.Reader) ([]byte, error) {
+	line, err := stream.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+	long := append([]byte(nil), line...)
+	rest, err := stream.ReadBytes('\n')
+	return append(long, rest...), err
+}
+
+// parseContentLength parses a Content-Length header value as
+// strconv.ParseUint(value, 10, 32) does, without allocating for valid ones.
+func parseContentLength(value []byte) (uint64, error) {
+	var n uint64
+	for _, b := range value {
+		if b < '0' || b > '9' {
+			return strconv.ParseUint(string(value), 10, 32)
+		}
+		if n = n*10 + uint64(b-'0'); n > math.MaxUint32 {
+			return strconv.ParseUint(string(value), 10, 32)
+		}
+	}
+	if len(value) == 0 {
+		return strconv.ParseUint(string(value), 10, 32)
+	}
+	return n, nil
+}
+
+// isJSON reports whether contentType names a JSON body, as peers using
+// jsonrpc2.VSCodeObjectCodec-style framing may send.
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/vscode-jsonrpc") || strings.HasPrefix(contentType, "application/json")
+}
+
+// BinaryKey is the single key of the JSON object a Binary value encodes to.
+const BinaryKey = "$binary"
+
+// Binary is raw data to carry in ModelData or Results. In JSON it is an
+// object holding the data in base64 under BinaryKey; binary encodings such as
+// msgpack carry it as raw bytes instead. Receivers decoding into
+// map[string]interface{} get the object back; AsBinary recovers the data.
+type Binary []byte
+
+// MarshalJSON implements json.Marshaler. The object is written directly, as
+// encoding the data to a string and that into a map would copy it twice.
+func (b Binary) MarshalJSON() ([]byte, error) {
+	const prefix = `{"` + BinaryKey + `":"`
+	n := base64.StdEncoding.EncodedLen(len(b))
+	out := make([]byte, len(prefix)+n+2)
+	copy(out, prefix)
+	base64.StdEncoding.Encode(out[len(prefix):], b)
+	copy(out[len(prefix)+n:], `"}`)
+	return out, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Binary) UnmarshalJSON(data []byte) error {
+	var obj map[string]string
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	encoded, ok := obj[BinaryKey]
+	if !ok || len(obj) != 1 {
+		return fmt.Errorf("binary value must be an object with a single %q key", BinaryKey)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// AsBinary returns the data of v if it is a Binary, a []byte, or a Binary as
+// decoded into an interface{}.
+func AsBinary(v interface{}) ([]byte, bool) {
+	switch value := v.(type) {
+	case Binary:
+		return value, true
+	case []byte:
+		return value, true
+	case map[string]interface{}:
+		encoded, ok := value[BinaryKey].(string)
+		if !ok || len(value) != 1 {
+			return nil, false
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, false
+		}
+		return decoded, true
+	}
+	return nil, false
+}
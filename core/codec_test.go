@@ -0,0 +1,389 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameCodecCompression(t *testing.T) {
+	codec := FrameCodec{Compression: CompressionGzip, Threshold: 64}
+	small := map[string]string{"name": "small"}
+	large := map[string]string{"name": strings.Repeat("large ", 100)}
+
+	var buf bytes.Buffer
+	require.NoError(t, codec.WriteObject(&buf, small), "Writing a small message should succeed")
+	assert.NotContains(t, buf.String(), "Content-Encoding", "Messages under the threshold should be sent plain")
+	plainSize := buf.Len()
+
+	require.NoError(t, codec.WriteObject(&buf, large), "Writing a large message should succeed")
+	assert.Contains(t, buf.String(), "Content-Encoding: gzip\r\n", "Messages over the threshold should be compressed")
+	assert.Less(t, buf.Len()-plainSize, len(large["name"]), "Compressed frame should be smaller than its body")
+
+	r := bufio.NewReader(&buf)
+	var got map[string]string
+	require.NoError(t, codec.ReadObject(r, &got), "Reading a plain frame should succeed")
+	assert.Equal(t, small, got, "Plain frame should round trip")
+	got = nil
+	require.NoError(t, codec.ReadObject(r, &got), "Reading a compressed frame should succeed")
+	assert.Equal(t, large, got, "Compressed frame should round trip")
+}
+
+// advisorFunc adapts a function to CompressionAdvisor.
+type advisorFunc func(obj interface{}, data []byte) func(int, time.Duration)
+
+func (f advisorFunc) Advise(obj interface{}, data []byte) func(int, time.Duration) {
+	return f(obj, data)
+}
+
+func TestFrameCodecAdvisor(t *testing.T) {
+	var asked []string
+	var sizes []int
+	advisor := advisorFunc(func(obj interface{}, data []byte) func(int, time.Duration) {
+		name := obj.(map[string]string)["name"]
+		asked = append(asked, name[:5])
+		if strings.HasPrefix(name, "plain") {
+			return nil
+		}
+		return func(size int, elapsed time.Duration) {
+			sizes = append(sizes, size)
+			assert.Positive(t, elapsed, "The time compressing took should be reported")
+		}
+	})
+	codec := FrameCodec{Compression: CompressionGzip, Threshold: 64, Advisor: advisor}
+
+	var buf bytes.Buffer
+	require.NoError(t, codec.WriteObject(&buf, map[string]string{"name": "small"}), "Writing a small message should succeed")
+	require.NoError(t, codec.WriteObject(&buf, map[string]string{"name": strings.Repeat("plain ", 100)}), "Writing a declined message should succeed")
+	assert.NotContains(t, buf.String(), "Content-Encoding", "Small and declined messages should be sent plain")
+	require.NoError(t, codec.WriteObject(&buf, map[string]string{"name": strings.Repeat("large ", 100)}), "Writing a large message should succeed")
+	assert.Contains(t, buf.String(), "Content-Encoding: gzip\r\n", "Messages the advisor accepts should be compressed")
+
+	assert.Equal(t, []string{"small", "plain", "large"}, asked, "The advisor should be asked about every message")
+	require.Len(t, sizes, 1, "Only the compressed message should be reported")
+	_, body, _ := strings.Cut(buf.String()[strings.LastIndex(buf.String(), "Content-Length"):], "\r\n\r\n")
+	assert.Equal(t, len(body), sizes[0], "The compressed size should be the body on the wire")
+
+	asked = nil
+	require.NoError(t, FrameCodec{Advisor: advisor}.WriteObject(&buf, map[string]string{"name": "small"}), "Writing uncompressed should succeed")
+	assert.Empty(t, asked, "The advisor should not be asked without compression")
+}
+
+func TestFrameCodecPlainInterop(t *testing.T) {
+	msg := map[string]string{"name": strings.Repeat("plain ", 100)}
+
+	// Frames from a plain peer are read as they are
+	var buf bytes.Buffer
+	require.NoError(t, jsonrpc2.VSCodeObjectCodec{}.WriteObject(&buf, msg), "Writing a plain frame should succeed")
+	var got map[string]string
+	require.NoError(t, FrameCodec{Compression: CompressionGzip}.ReadObject(bufio.NewReader(&buf), &got), "Reading a plain frame should succeed")
+	assert.Equal(t, msg, got, "Plain frame should round trip")
+
+	// Without an algorithm the codec writes frames a plain peer can read
+	buf.Reset()
+	require.NoError(t, FrameCodec{}.WriteObject(&buf, msg), "Writing without compression should succeed")
+	got = nil
+	require.NoError(t, jsonrpc2.VSCodeObjectCodec{}.ReadObject(bufio.NewReader(&buf), &got), "Plain peer should read the frame")
+	assert.Equal(t, msg, got, "Frame should round trip to a plain peer")
+}
+
+func TestFrameCodecMatchesPlainFraming(t *testing.T) {
+	var params json.RawMessage = []byte(`{"name":"<model>","value":42}`)
+	msgs := []interface{}{
+		&jsonrpc2.Request{Method: MethodProcessModel, Params: &params, ID: jsonrpc2.ID{Str: "7", IsString: true}},
+		&jsonrpc2.Response{ID: jsonrpc2.ID{Num: 3}, Result: &params},
+	}
+	for _, msg := range msgs {
+		var plain, framed bytes.Buffer
+		require.NoError(t, jsonrpc2.VSCodeObjectCodec{}.WriteObject(&plain, msg), "Plain codec should write the message")
+		require.NoError(t, FrameCodec{}.WriteObject(&framed, msg), "Frame codec should write the message")
+		assert.Equal(t, plain.String(), framed.String(), "JSON frames should match a plain peer's byte for byte")
+	}
+}
+
+// recordingHandler passes the requests a jsonrpc2.Conn receives to a channel,
+// replying to calls with their params, or with recordedError to "fail".
+type recordingHandler chan *jsonrpc2.Request
+
+var recordedError = &jsonrpc2.Error{Code: 7, Message: "<failed>"}
+
+func (h recordingHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	h <- req
+	switch {
+	case req.Notif:
+	case req.Method == "fail":
+		conn.ReplyWithError(ctx, req.ID, recordedError)
+	default:
+		conn.Reply(ctx, req.ID, req.Params)
+	}
+}
+
+func TestFrameCodecMessages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	local, remote := net.Pipe()
+	defer remote.Close()
+	received := make(recordingHandler, 8)
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(local, FrameCodec{}), received)
+	defer conn.Close()
+	peer := bufio.NewReader(remote)
+	plain := jsonrpc2.VSCodeObjectCodec{}
+
+	// expectFrame reads the next frame the conn writes, which should be the
+	// JSON encoding jsonrpc2 gives want
+	expectFrame := func(want interface{}, msg string) {
+		var got json.RawMessage
+		require.NoError(t, plain.ReadObject(peer, &got), "Peer should read the frame: %s", msg)
+		encoded, err := json.Marshal(want)
+		require.NoError(t, err, "Expected message should encode: %s", msg)
+		assert.Equal(t, string(encoded), string(got), "Frame should match jsonrpc2's encoding byte for byte: %s", msg)
+	}
+
+	// Requests the conn sends
+	params := json.RawMessage(`{"text": "<a & b>"}`)
+	meta := json.RawMessage(`{"trace":"t1"}`)
+	sent := make(chan error, 1)
+	go func() { sent <- conn.Notify(ctx, "notify\tme", params, jsonrpc2.Meta(meta)) }()
+	expectFrame(&jsonrpc2.Request{Method: "notify\tme", Params: &params, Meta: &meta, Notif: true}, "notification")
+	require.NoError(t, <-sent, "Notification should be sent")
+
+	replies := make(chan error, 1)
+	var result json.RawMessage
+	go func() {
+		replies <- conn.Call(ctx, "call", nil, &result, jsonrpc2.PickID(jsonrpc2.ID{Str: "id 1", IsString: true}))
+	}()
+	null := json.RawMessage("null")
+	expectFrame(&jsonrpc2.Request{Method: "call", Params: &null, ID: jsonrpc2.ID{Str: "id 1", IsString: true}}, "call")
+
+	// Responses the conn reads
+	require.NoError(t, plain.WriteObject(remote, &jsonrpc2.Response{ID: jsonrpc2.ID{Str: "id 1", IsString: true}, Result: &params}), "Peer should reply")
+	require.NoError(t, <-replies, "Call should succeed")
+	assert.JSONEq(t, string(params), string(result), "Call should get the result")
+
+	go func() {
+		replies <- conn.Call(ctx, "fail", nil, nil, jsonrpc2.PickID(jsonrpc2.ID{Num: 9}))
+	}()
+	expectFrame(&jsonrpc2.Request{Method: "fail", Params: &null, ID: jsonrpc2.ID{Num: 9}}, "failing call")
+	data := json.RawMessage(`{"why":"no"}`)
+	require.NoError(t, plain.WriteObject(remote, &jsonrpc2.Response{ID: jsonrpc2.ID{Num: 9}, Error: &jsonrpc2.Error{Code: 42, Message: "failed", Data: &data}}), "Peer should reply with an error")
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, <-replies, &rpcErr, "Call should get the error")
+	assert.Equal(t, int64(42), rpcErr.Code, "Error should keep its code")
+	assert.JSONEq(t, string(data), string(*rpcErr.Data), "Error should keep its data")
+
+	// Requests the conn reads, decoded as jsonrpc2 decodes them, and the
+	// replies it writes
+	for _, frame := range []string{
+		`{"jsonrpc":"2.0","method":"a","params":null,"id":"7"}`,
+		`{"jsonrpc":"2.0","method":"b","params":[1,2],"id":12,"meta":{"k":"v"}}`,
+		`{"jsonrpc":"2.0","method":"c"}`,
+		`{"jsonrpc":"2.0","method":"d","id":null}`,
+		`{"jsonrpc":"2.0","method":"fail","id":"e"}`,
+	} {
+		var want jsonrpc2.Request
+		require.NoError(t, json.Unmarshal([]byte(frame), &want), "Request should decode: %s", frame)
+		require.NoError(t, plain.WriteObject(remote, json.RawMessage(frame)), "Peer should send the request: %s", frame)
+		got := <-received
+		assert.Equal(t, want.Method, got.Method, "Method should be decoded: %s", frame)
+		assert.Equal(t, want.ID, got.ID, "ID should be decoded: %s", frame)
+		assert.Equal(t, want.Notif, got.Notif, "Notifications should be told from calls: %s", frame)
+		assert.Equal(t, want.Params, got.Params, "Params should be decoded: %s", frame)
+		assert.Equal(t, want.Meta, got.Meta, "Meta should be decoded: %s", frame)
+		if !want.Notif {
+			reply := &jsonrpc2.Response{ID: want.ID, Result: want.Params}
+			if want.Method == "fail" {
+				reply = &jsonrpc2.Response{ID: want.ID, Error: recordedError}
+			} else if want.Params == nil {
+				reply.Result = &null
+			}
+			expectFrame(reply, frame)
+		}
+	}
+
+	// A frame that is neither is refused as jsonrpc2 refuses it
+	require.NoError(t, plain.WriteObject(remote, json.RawMessage(`{"jsonrpc":"2.0","id":1}`)), "Peer should send the message")
+	select {
+	case <-conn.DisconnectNotify():
+	case <-ctx.Done():
+		t.Fatal("A message that is neither a request nor a response should end the connection")
+	}
+}
+
+func TestFrameCodecReadsReuseNoData(t *testing.T) {
+	codec := FrameCodec{Compression: CompressionGzip, Threshold: 64}
+	first := map[string]string{"name": strings.Repeat("first ", 100)}
+	second := map[string]string{"name": strings.Repeat("second ", 100)}
+	var buf bytes.Buffer
+	for _, msg := range []interface{}{first, second, first, second} {
+		require.NoError(t, codec.WriteObject(&buf, msg), "Writing a message should succeed")
+	}
+
+	// Messages read earlier are unchanged by the reads after them
+	r := bufio.NewReader(&buf)
+	var raws [2]json.RawMessage
+	var decoded [2]map[string]string
+	for i := range raws {
+		require.NoError(t, codec.ReadObject(r, &raws[i]), "Reading a raw message should succeed")
+	}
+	for i := range decoded {
+		require.NoError(t, codec.ReadObject(r, &decoded[i]), "Reading a message should succeed")
+	}
+	for i, want := range []map[string]string{first, second} {
+		var got map[string]string
+		require.NoError(t, json.Unmarshal(raws[i], &got), "Raw message should hold valid JSON")
+		assert.Equal(t, want, got, "Raw message should be kept intact")
+		assert.Equal(t, want, decoded[i], "Decoded message should be kept intact")
+	}
+}
+
+func TestFrameCodecHeaders(t *testing.T) {
+	read := func(frame string, v interface{}) error {
+		// A small buffer makes long header lines span several reads
+		return FrameCodec{}.ReadObject(bufio.NewReaderSize(strings.NewReader(frame), 16), v)
+	}
+
+	var got map[string]string
+	require.NoError(t, read("Content-Length:  12 \r\nX-Padding: "+strings.Repeat("p", 64)+"\r\n\r\n{\"name\":\"a\"}", &got),
+		"Long header lines should be read")
+	assert.Equal(t, map[string]string{"name": "a"}, got, "Body should follow long headers")
+
+	_, parseErr := strconv.ParseUint("12a", 10, 32)
+	assert.EqualError(t, read("Content-Length: 12a\r\n\r\n{}", &got), parseErr.Error(), "Malformed lengths should be rejected")
+	_, parseErr = strconv.ParseUint("4294967296", 10, 32)
+	assert.EqualError(t, read("Content-Length: 4294967296\r\n\r\n{}", &got), parseErr.Error(), "Lengths over 32 bits should be rejected")
+	assert.EqualError(t, read("Content-Length: 2\n\n{}", &got), `jsonrpc2: line endings must be \r\n`, "Bare line feeds should be rejected")
+
+	var raw json.RawMessage
+	assert.Error(t, read("Content-Length: 2\r\n\r\n{,", &raw), "Invalid JSON should be rejected as a raw message too")
+}
+
+func TestFrameCodecUnsupportedEncoding(t *testing.T) {
+	frame := "Content-Length: 2\r\nContent-Encoding: br\r\n\r\n{}"
+	var got map[string]string
+	err := FrameCodec{}.ReadObject(bufio.NewReader(strings.NewReader(frame)), &got)
+	assert.EqualError(t, err, `jsonrpc2: unsupported Content-Encoding "br"`, "Unknown encodings should be rejected")
+}
+
+// xorCompressor "compresses" by flipping every bit, a registered algorithm
+// no other peer knows.
+type xorCompressor struct{}
+
+func (xorCompressor) Compress(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = ^b
+	}
+	return out, nil
+}
+
+func (c xorCompressor) NewReader(data []byte) (io.ReadCloser, error) {
+	out, _ := c.Compress(data)
+	return io.NopCloser(bytes.NewReader(out)), nil
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	const xor Compression = "x-xor"
+	assert.False(t, xor.Supported(), "Unregistered algorithm should not be supported")
+	assert.True(t, CompressionGzip.Supported(), "Gzip should always be supported")
+	assert.True(t, CompressionNone.Supported(), "No compression should always be supported")
+
+	RegisterCompressor(xor, xorCompressor{})
+	assert.True(t, xor.Supported(), "Registered algorithm should be supported")
+	_, ok := LookupCompressor(xor)
+	assert.True(t, ok, "Registered algorithm should be found")
+
+	codec := FrameCodec{Compression: xor}
+	msg := map[string]string{"name": "flipped"}
+	var buf bytes.Buffer
+	require.NoError(t, codec.WriteObject(&buf, msg), "Writing with a registered algorithm should succeed")
+	assert.Contains(t, buf.String(), "Content-Encoding: x-xor\r\n", "Frame should name the algorithm")
+	var got map[string]string
+	require.NoError(t, FrameCodec{}.ReadObject(bufio.NewReader(&buf), &got), "Any codec should read a registered algorithm")
+	assert.Equal(t, msg, got, "Frame should round trip")
+
+	assert.Panics(t, func() { RegisterCompressor(CompressionNone, xorCompressor{}) }, "Registering no compression should panic")
+}
+
+func TestCompressionPreference(t *testing.T) {
+	assert.Empty(t, CompressionPreference(CompressionNone, nil), "No compression should accept nothing")
+	assert.Equal(t, []Compression{"zstd", CompressionGzip},
+		CompressionPreference("zstd", []Compression{CompressionNone, CompressionGzip, "zstd"}),
+		"Preference should keep the order and drop none and repeats")
+}
+
+// reversedCodec is JSON written backwards, a codec no plain peer can read.
+type reversedCodec struct{}
+
+func (reversedCodec) ContentType() string { return "application/x-reversed-json" }
+
+func (reversedCodec) Marshal(obj interface{}) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	return reverse(data), err
+}
+
+func (reversedCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(reverse(append([]byte(nil), data...)), v)
+}
+
+func reverse(data []byte) []byte {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	return data
+}
+
+func TestFrameCodecContentType(t *testing.T) {
+	codec := FrameCodec{Codec: reversedCodec{}, Compression: CompressionGzip, Threshold: 64}
+	msg := map[string]string{"name": strings.Repeat("reversed ", 100)}
+
+	var buf bytes.Buffer
+	require.NoError(t, codec.WriteObject(&buf, msg), "Writing with a codec should succeed")
+	assert.Contains(t, buf.String(), "Content-Type: application/x-reversed-json\r\n", "Frames should name a codec other than JSON")
+	frame := buf.String()
+
+	var got map[string]string
+	require.NoError(t, codec.ReadObject(bufio.NewReader(&buf), &got), "Reading with the same codec should succeed")
+	assert.Equal(t, msg, got, "Frame should round trip through the codec")
+
+	err := FrameCodec{}.ReadObject(bufio.NewReader(strings.NewReader(frame)), &got)
+	assert.EqualError(t, err, fmt.Sprintf("jsonrpc2: peer sent a application/x-reversed-json message, expected %s", ContentTypeJSON), "A JSON reader should reject other codecs")
+
+	// JSON frames carry no Content-Type, as jsonrpc2.VSCodeObjectCodec writes them
+	buf.Reset()
+	require.NoError(t, FrameCodec{Codec: JSONCodec}.WriteObject(&buf, msg), "Writing JSON should succeed")
+	assert.NotContains(t, buf.String(), "Content-Type", "JSON frames should not name their codec")
+}
+
+func TestBinary(t *testing.T) {
+	data := []byte{0, 1, 2, 0xff}
+
+	encoded, err := json.Marshal(map[string]interface{}{"blob": Binary(data)})
+	require.NoError(t, err, "Marshaling binary data should succeed")
+	assert.JSONEq(t, `{"blob":{"$binary":"AAEC/w=="}}`, string(encoded), "Binary should encode as a base64 object")
+
+	var typed struct{ Blob Binary }
+	require.NoError(t, json.Unmarshal(encoded, &typed), "Unmarshaling into Binary should succeed")
+	assert.Equal(t, Binary(data), typed.Blob, "Binary should round trip")
+
+	var generic map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &generic), "Unmarshaling into a map should succeed")
+	got, ok := AsBinary(generic["blob"])
+	assert.True(t, ok, "AsBinary should recognise a decoded Binary")
+	assert.Equal(t, data, got, "AsBinary should recover the data")
+
+	_, ok = AsBinary(map[string]interface{}{BinaryKey: "AA==", "other": 1})
+	assert.False(t, ok, "Objects with other keys should not be binary")
+	assert.Error(t, json.Unmarshal([]byte(`{"data":"AA=="}`), &typed.Blob), "Binary should reject other objects")
+}
@@ -0,0 +1,142 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Compression names an algorithm that message bodies are compressed with.
+type Compression string
+
+const (
+	// CompressionNone sends every message uncompressed.
+	CompressionNone Compression = ""
+
+	// CompressionGzip compresses message bodies with gzip.
+	CompressionGzip Compression = "gzip"
+)
+
+// Compressor implements a compression algorithm for message bodies. Clients
+// and servers negotiate only the algorithms registered with
+// RegisterCompressor; gzip always is, and the mcpcompress package registers
+// zstd and snappy.
+type Compressor interface {
+	// Compress returns data compressed.
+	Compress(data []byte) ([]byte, error)
+
+	// NewReader returns a reader of data decompressed, which the caller
+	// closes once done. The reader is read only as far as the caller's size
+	// limit, so it should not decompress everything up front.
+	NewReader(data []byte) (io.ReadCloser, error)
+}
+
+// compressors holds the registered algorithms by name.
+var compressors = struct {
+	sync.RWMutex
+	byName map[Compression]Compressor
+}{byName: map[Compression]Compressor{CompressionGzip: gzipCompressor{}}}
+
+// RegisterCompressor makes compressor available as the algorithm name,
+// replacing any registered under it. It is meant to be called from init
+// functions. Registering CompressionNone panics.
+func RegisterCompressor(name Compression, compressor Compressor) {
+	if name == CompressionNone {
+		panic("core: cannot register a compressor without a name")
+	}
+	compressors.Lock()
+	defer compressors.Unlock()
+	compressors.byName[name] = compressor
+}
+
+// LookupCompressor returns the compressor registered as name.
+func LookupCompressor(name Compression) (Compressor, bool) {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	compressor, ok := compressors.byName[name]
+	return compressor, ok
+}
+
+// Supported reports whether the algorithm is CompressionNone or registered.
+func (c Compression) Supported() bool {
+	if c == CompressionNone {
+		return true
+	}
+	_, ok := LookupCompressor(c)
+	return ok
+}
+
+// CompressionPreference returns preferred followed by fallbacks, leaving
+// out CompressionNone and repeats: the algorithms a client or server
+// configured with them accepts, most preferred first.
+func CompressionPreference(preferred Compression, fallbacks []Compression) []Compression {
+	var algorithms []Compression
+	for _, algorithm := range append([]Compression{preferred}, fallbacks...) {
+		if algorithm == CompressionNone || slices.Contains(algorithms, algorithm) {
+			continue
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms
+}
+
+// CompressionAdvisor chooses which messages a FrameCodec compresses and is
+// told what compressing them achieved, so its owner can account for the
+// bytes and time spent, and stop compressing messages that do not shrink.
+type CompressionAdvisor interface {
+	// Advise is asked about every message the codec writes, obj encoded as
+	// data, before it is compressed. It returns nil to send the message
+	// uncompressed, or a function the codec calls once it has compressed it,
+	// with the size of the compressed body and the time compressing took.
+	// Messages under the codec's Threshold are sent uncompressed whatever it
+	// returns. data is reused once the message is written, so neither Advise
+	// nor the function it returns may keep it.
+	Advise(obj interface{}, data []byte) (compressed func(size int, elapsed time.Duration))
+}
+
+// compress returns data compressed with algorithm.
+func compress(algorithm Compression, data []byte) ([]byte, error) {
+	compressor, ok := LookupCompressor(algorithm)
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression %q", algorithm)
+	}
+	return compressor.Compress(data)
+}
+
+// gzipWriters reuses gzip writers across messages, as allocating one is
+// more expensive than compressing a small body.
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	},
+}
+
+// gzipCompressor implements CompressionGzip.
+type gzipCompressor struct{}
+
+// Compress implements Compressor.
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 4)
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewReader implements Compressor.
+func (gzipCompressor) NewReader(data []byte) (io.ReadCloser, error) {
+	return gzip.NewReader(bytes.NewReader(data))
+}
@@ -0,0 +1,263 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile decodes the configuration file at path into cfg, a pointer
+// to a struct whose fields are pointers named by a config tag. The file is
+// YAML if its extension is .yaml or .yml and JSON if it is .json. Fields
+// whose key the file leaves out stay nil. Keys cfg does not name fail,
+// suggesting the closest key it does, as do values of the wrong type.
+// Durations are written as strings such as "30s", lists of strings may be
+// written as a single comma separated string, and fields of types that
+// implement encoding.TextUnmarshaler, such as Status, as their text.
+func LoadConfigFile(path string, cfg interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("%s: unsupported configuration format %q, want .yaml, .yml or .json", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	fields := configFields(cfg)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("%s: %s", path, unknownKey(key, fields))
+		}
+		if err := setConfigField(field.value, values[key]); err != nil {
+			return fmt.Errorf("%s: invalid %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// LoadConfigEnv sets the fields of cfg, as described for LoadConfigFile,
+// that have an env tag naming a variable set in the environment.
+func LoadConfigEnv(cfg interface{}) error {
+	fields := configFields(cfg)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := fields[name]
+		if field.env == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(field.env)
+		if !ok {
+			continue
+		}
+		if err := setConfigField(field.value, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", field.env, err)
+		}
+	}
+	return nil
+}
+
+// configField is a field of a configuration struct.
+type configField struct {
+	value reflect.Value // The pointer field
+	env   string        // Environment variable setting the field, if any
+}
+
+// configFields returns the fields of the struct cfg points to by key.
+func configFields(cfg interface{}) map[string]configField {
+	v := reflect.ValueOf(cfg).Elem()
+	fields := make(map[string]configField, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag
+		if key := tag.Get("config"); key != "" {
+			fields[key] = configField{value: v.Field(i), env: tag.Get("env")}
+		}
+	}
+	return fields
+}
+
+// unknownKey describes the key no field is named by, suggesting the closest
+// key that is.
+func unknownKey(key string, fields map[string]configField) string {
+	best, bestDistance := "", len(key)/2+1
+	for known := range fields {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(known)); d < bestDistance || (d == bestDistance && known < best) {
+			best, bestDistance = known, d
+		}
+	}
+	if best == "" {
+		return fmt.Sprintf("unknown key %q", key)
+	}
+	return fmt.Sprintf("unknown key %q, did you mean %q?", key, best)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// durationType is the type of time.Duration fields.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// textUnmarshalerType is the interface of fields, such as Status, that
+// parse their own text.
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setConfigField sets the pointer field to value, as decoded from a file or
+// read from the environment as a string.
+func setConfigField(field reflect.Value, value interface{}) error {
+	target := reflect.New(field.Type().Elem())
+	elem := target.Elem()
+	text, isText := value.(string)
+
+	switch {
+	case target.Type().Implements(textUnmarshalerType):
+		if !isText {
+			return fmt.Errorf("want a string, got %v", value)
+		}
+		if err := target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+			return err
+		}
+	case elem.Type() == durationType:
+		if !isText {
+			return fmt.Errorf("want a duration such as \"30s\", got %v", value)
+		}
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		elem.SetInt(int64(d))
+	case elem.Kind() == reflect.String:
+		if !isText {
+			return fmt.Errorf("want a string, got %v", value)
+		}
+		elem.SetString(text)
+	case elem.Kind() == reflect.Bool:
+		b, ok := value.(bool)
+		if isText {
+			var err error
+			if b, err = strconv.ParseBool(text); err != nil {
+				return fmt.Errorf("want true or false, got %q", text)
+			}
+		} else if !ok {
+			return fmt.Errorf("want true or false, got %v", value)
+		}
+		elem.SetBool(b)
+	case elem.Kind() == reflect.Int || elem.Kind() == reflect.Int64:
+		n, err := configInt(value)
+		if err != nil {
+			return err
+		}
+		if elem.OverflowInt(n) {
+			return fmt.Errorf("%d is out of range", n)
+		}
+		elem.SetInt(n)
+	case elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() == reflect.String:
+		list, err := configStrings(value)
+		if err != nil {
+			return err
+		}
+		elem.Set(reflect.ValueOf(list).Convert(elem.Type()))
+	default:
+		return fmt.Errorf("unsupported field type %s", elem.Type())
+	}
+	field.Set(target)
+	return nil
+}
+
+// configInt returns value as a whole number.
+func configInt(value interface{}) (int64, error) {
+	switch n := value.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint64:
+		if n > 1<<63-1 {
+			return 0, fmt.Errorf("%d is out of range", n)
+		}
+		return int64(n), nil
+	case float64:
+		if n != float64(int64(n)) {
+			return 0, fmt.Errorf("want a whole number, got %v", n)
+		}
+		return int64(n), nil
+	case string:
+		parsed, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("want a whole number, got %q", n)
+		}
+		return parsed, nil
+	}
+	return 0, fmt.Errorf("want a whole number, got %v", value)
+}
+
+// configStrings returns value as a list of strings.
+func configStrings(value interface{}) ([]string, error) {
+	switch list := value.(type) {
+	case string:
+		var items []string
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	case []interface{}:
+		items := make([]string, len(list))
+		for i, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("want a list of strings, got %v", item)
+			}
+			items[i] = s
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("want a list of strings, got %v", value)
+}
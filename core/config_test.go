@@ -0,0 +1,146 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Name    *string        `config:"name" env:"MCP_TEST_NAME"`
+	Port    *int           `config:"port" env:"MCP_TEST_PORT"`
+	Enabled *bool          `config:"enabled" env:"MCP_TEST_ENABLED"`
+	Timeout *time.Duration `config:"timeout" env:"MCP_TEST_TIMEOUT"`
+	Addrs   *[]string      `config:"addrs" env:"MCP_TEST_ADDRS"`
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600), "Writing the configuration should succeed")
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.yaml": "name: edge\nport: 6000\nenabled: true\ntimeout: 5s\naddrs: [a, b]\n",
+		"config.json": `{"name": "edge", "port": 6000, "enabled": true, "timeout": "5s", "addrs": ["a", "b"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var cfg testConfig
+			require.NoError(t, LoadConfigFile(writeConfig(t, name, content), &cfg), "Loading the configuration should succeed")
+
+			require.NotNil(t, cfg.Name, "Name should be set")
+			assert.Equal(t, "edge", *cfg.Name, "Name should be read")
+			require.NotNil(t, cfg.Port, "Port should be set")
+			assert.Equal(t, 6000, *cfg.Port, "Port should be read")
+			require.NotNil(t, cfg.Enabled, "Enabled should be set")
+			assert.True(t, *cfg.Enabled, "Enabled should be read")
+			require.NotNil(t, cfg.Timeout, "Timeout should be set")
+			assert.Equal(t, 5*time.Second, *cfg.Timeout, "Timeout should be parsed")
+			require.NotNil(t, cfg.Addrs, "Addrs should be set")
+			assert.Equal(t, []string{"a", "b"}, *cfg.Addrs, "Addrs should be read")
+		})
+	}
+}
+
+func TestLoadConfigFileLeavesMissingKeysUnset(t *testing.T) {
+	var cfg testConfig
+	require.NoError(t, LoadConfigFile(writeConfig(t, "config.yml", "port: 6000\n"), &cfg), "Loading the configuration should succeed")
+
+	assert.NotNil(t, cfg.Port, "Port should be set")
+	assert.Nil(t, cfg.Name, "Name should be left unset")
+	assert.Nil(t, cfg.Timeout, "Timeout should be left unset")
+}
+
+func TestLoadConfigFileUnknownKey(t *testing.T) {
+	var cfg testConfig
+	err := LoadConfigFile(writeConfig(t, "config.yaml", "prot: 6000\n"), &cfg)
+
+	require.Error(t, err, "An unknown key should fail")
+	assert.Contains(t, err.Error(), `unknown key "prot", did you mean "port"?`, "The error should suggest the closest key")
+
+	err = LoadConfigFile(writeConfig(t, "config.yaml", "verbosity: 3\n"), &cfg)
+	require.Error(t, err, "An unknown key should fail")
+	assert.Contains(t, err.Error(), `unknown key "verbosity"`, "The error should name the key")
+	assert.NotContains(t, err.Error(), "did you mean", "A key close to none should not get a suggestion")
+}
+
+func TestLoadConfigFileInvalidValues(t *testing.T) {
+	for content, want := range map[string]string{
+		"port: high\n":      "invalid port",
+		"port: 1.5\n":       "invalid port",
+		"timeout: 30\n":     "invalid timeout",
+		"timeout: soon\n":   "invalid timeout",
+		"enabled: maybe\n":  "invalid enabled",
+		"name: [a]\n":       "invalid name",
+		"addrs: [1, 2]\n":   "invalid addrs",
+		"port: [6000]\n":    "invalid port",
+		"name: edge\n\tx\n": "config.yaml",
+	} {
+		var cfg testConfig
+		err := LoadConfigFile(writeConfig(t, "config.yaml", content), &cfg)
+		require.Error(t, err, "Loading %q should fail", content)
+		assert.Contains(t, err.Error(), want, "The error for %q should say what is wrong", content)
+	}
+}
+
+func TestLoadConfigFileFormat(t *testing.T) {
+	var cfg testConfig
+	err := LoadConfigFile(writeConfig(t, "config.toml", "port = 6000\n"), &cfg)
+	require.Error(t, err, "An unsupported extension should fail")
+	assert.Contains(t, err.Error(), "unsupported configuration format", "The error should name the problem")
+
+	err = LoadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), &cfg)
+	assert.ErrorIs(t, err, os.ErrNotExist, "A missing file should fail")
+}
+
+func TestLoadConfigFileTextValues(t *testing.T) {
+	var cfg struct {
+		Status *Status `config:"status" env:"MCP_TEST_STATUS"`
+	}
+	require.NoError(t, LoadConfigFile(writeConfig(t, "config.yaml", "status: running\n"), &cfg), "Loading the configuration should succeed")
+	require.NotNil(t, cfg.Status, "Status should be set")
+	assert.Equal(t, StatusRunning, *cfg.Status, "Status should be parsed from its name")
+
+	t.Setenv("MCP_TEST_STATUS", "Stopping")
+	require.NoError(t, LoadConfigEnv(&cfg), "Loading the environment should succeed")
+	assert.Equal(t, StatusStopping, *cfg.Status, "Status should be parsed from its name")
+
+	err := LoadConfigFile(writeConfig(t, "config.yaml", "status: sleeping\n"), &cfg)
+	require.Error(t, err, "An unknown status should fail")
+	assert.Contains(t, err.Error(), `invalid status: unknown status "sleeping"`, "The error should name the key and the value")
+
+	err = LoadConfigFile(writeConfig(t, "config.yaml", "status: 2\n"), &cfg)
+	require.Error(t, err, "A status that is not text should fail")
+	assert.Contains(t, err.Error(), "want a string", "The error should say what is wrong")
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("MCP_TEST_PORT", "7000")
+	t.Setenv("MCP_TEST_ENABLED", "false")
+	t.Setenv("MCP_TEST_TIMEOUT", "1m")
+	t.Setenv("MCP_TEST_ADDRS", "a:1, b:2")
+
+	var cfg testConfig
+	require.NoError(t, LoadConfigEnv(&cfg), "Loading the environment should succeed")
+
+	assert.Nil(t, cfg.Name, "Name should be left unset")
+	require.NotNil(t, cfg.Port, "Port should be set")
+	assert.Equal(t, 7000, *cfg.Port, "Port should be parsed")
+	require.NotNil(t, cfg.Enabled, "Enabled should be set")
+	assert.False(t, *cfg.Enabled, "Enabled should be parsed")
+	require.NotNil(t, cfg.Timeout, "Timeout should be set")
+	assert.Equal(t, time.Minute, *cfg.Timeout, "Timeout should be parsed")
+	require.NotNil(t, cfg.Addrs, "Addrs should be set")
+	assert.Equal(t, []string{"a:1", "b:2"}, *cfg.Addrs, "Addrs should be split on commas")
+
+	t.Setenv("MCP_TEST_PORT", "seven")
+	err := LoadConfigEnv(&cfg)
+	require.Error(t, err, "An invalid variable should fail")
+	assert.Contains(t, err.Error(), "invalid MCP_TEST_PORT", "The error should name the variable")
+}
@@ -0,0 +1,112 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// CodeModelError is the JSON-RPC error code returned when a handler fails a
+// request with a ModelError. The error data is the ModelError.
+const CodeModelError int64 = -32008
+
+// ErrorCode classifies why a request failed, so callers need not match
+// error messages.
+type ErrorCode string
+
+// Error codes defined by the protocol. Handlers may use codes of their own.
+const (
+	ErrInvalidModel     ErrorCode = "invalid_model"     // The model data is malformed or unsupported
+	ErrInvalidParameter ErrorCode = "invalid_parameter" // A parameter is missing or out of range
+	ErrNotFound         ErrorCode = "not_found"         // The model or a resource it refers to does not exist
+	ErrTimeout          ErrorCode = "timeout"           // Processing took longer than allowed
+	ErrInternal         ErrorCode = "internal"          // The handler failed for a reason of its own
+)
+
+// ModelError is an error with an ErrorCode and details. A handler returning
+// one fails the request with CodeModelError, carrying the code and details
+// to the client. A failed ModelResponse carries them in ErrorCode and
+// Details, and its Err returns a ModelError. On the client, Err holds the
+// *jsonrpc2.Error the ModelError was reconstructed from.
+type ModelError struct {
+	Code    ErrorCode              `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Err     error                  `json:"-"`
+}
+
+// NewModelError returns a ModelError with code wrapping err.
+func NewModelError(code ErrorCode, err error) *ModelError {
+	return &ModelError{Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *ModelError) Error() string {
+	if e.Message == "" {
+		return string(e.Code)
+	}
+	return e.Message
+}
+
+func (e *ModelError) Unwrap() error {
+	return e.Err
+}
+
+// RPCError returns the CodeModelError error to reply with.
+func (e *ModelError) RPCError() *jsonrpc2.Error {
+	rpcErr := &jsonrpc2.Error{Code: CodeModelError, Message: e.Error()}
+	rpcErr.SetError(e)
+	return rpcErr
+}
+
+// ModelErrorFromRPC reconstructs the ModelError carried by a CodeModelError
+// error. It returns nil for other errors.
+func ModelErrorFromRPC(rpcErr *jsonrpc2.Error) *ModelError {
+	if rpcErr.Code != CodeModelError || rpcErr.Data == nil {
+		return nil
+	}
+	var modelErr ModelError
+	if err := json.Unmarshal(*rpcErr.Data, &modelErr); err != nil || modelErr.Code == "" {
+		return nil
+	}
+	modelErr.Err = rpcErr
+	return &modelErr
+}
+
+// ModelResponseFromRPC returns the failed ModelResponse carried by a
+// CodeInvalidParams error that a server replies with when it cannot decode a
+// model request. It returns nil for other errors, including schema
+// violations, whose data lists the violations instead.
+func ModelResponseFromRPC(rpcErr *jsonrpc2.Error) *ModelResponse {
+	if rpcErr.Code != jsonrpc2.CodeInvalidParams || rpcErr.Data == nil {
+		return nil
+	}
+	data := bytes.TrimSpace(*rpcErr.Data)
+	if len(data) == 0 || data[0] != '{' {
+		return nil
+	}
+	var resp ModelResponse
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Success {
+		return nil
+	}
+	return &resp
+}
+
+// ErrorResponseWithCode creates an error response for req with the given
+// code and the message of err. Details of a ModelError in err are kept.
+func ErrorResponseWithCode(req *ModelRequest, code ErrorCode, err error) *ModelResponse {
+	resp := ErrorResponse(req, err)
+	resp.ErrorCode = code
+	return resp
+}
+
+// asModelError returns the ModelError in err's chain, if any.
+func asModelError(err error) *ModelError {
+	var modelErr *ModelError
+	if errors.As(err, &modelErr) {
+		return modelErr
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelErrorRPCRoundTrip(t *testing.T) {
+	modelErr := NewModelError(ErrInvalidParameter, errors.New("temperature must be at most 2"))
+	modelErr.Details = map[string]interface{}{"parameter": "temperature"}
+
+	rpcErr := modelErr.RPCError()
+	assert.Equal(t, CodeModelError, rpcErr.Code, "Typed errors should use CodeModelError")
+	assert.Equal(t, modelErr.Message, rpcErr.Message, "Message should be the error's")
+
+	got := ModelErrorFromRPC(rpcErr)
+	require.NotNil(t, got, "ModelError should be reconstructed")
+	assert.Equal(t, ErrInvalidParameter, got.Code, "Code should survive")
+	assert.Equal(t, modelErr.Message, got.Message, "Message should survive")
+	assert.Equal(t, modelErr.Details, got.Details, "Details should survive")
+	assert.Same(t, rpcErr, got.Err, "Reconstructed error should wrap the JSON-RPC error")
+
+	assert.Nil(t, ModelErrorFromRPC(&jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: "boom"}), "Other errors should not be reconstructed")
+}
+
+func TestModelResponseFromRPC(t *testing.T) {
+	rpcErr := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "invalid params: bad JSON"}
+	rpcErr.SetError(ErrorResponseWithCode(&ModelRequest{ID: "req-1"}, ErrInvalidModel, errors.New("bad JSON")))
+
+	resp := ModelResponseFromRPC(rpcErr)
+	require.NotNil(t, resp, "The response should be reconstructed")
+	assert.Equal(t, "req-1", resp.ID, "ID should survive")
+	assert.False(t, resp.Success, "The response should be a failure")
+	assert.Equal(t, ErrInvalidModel, resp.ErrorCode, "Code should survive")
+	assert.Equal(t, "bad JSON", resp.ErrorMessage, "Message should survive")
+
+	violations := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "invalid"}
+	violations.SetError([]map[string]string{{"field": "modelData.value"}})
+	assert.Nil(t, ModelResponseFromRPC(violations), "Schema violations should not be a response")
+	assert.Nil(t, ModelResponseFromRPC(&jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "invalid"}), "Errors without data should not be a response")
+	assert.Nil(t, ModelResponseFromRPC(NewModelError(ErrInternal, errors.New("boom")).RPCError()), "Other errors should not be a response")
+}
+
+func TestErrorResponseWithCode(t *testing.T) {
+	req := NewModelRequest()
+
+	resp := ErrorResponseWithCode(req, ErrTimeout, errors.New("took too long"))
+	assert.False(t, resp.Success, "Response should be marked as unsuccessful")
+	assert.Equal(t, ErrTimeout, resp.ErrorCode, "Code should be set")
+	assert.Equal(t, "took too long", resp.ErrorMessage, "Message should be set")
+
+	var modelErr *ModelError
+	require.True(t, errors.As(resp.Err(), &modelErr), "Coded failure should be a ModelError")
+	assert.Equal(t, ErrTimeout, modelErr.Code, "Err should carry the code")
+
+	// ErrorResponse picks up a wrapped ModelError's code and details
+	wrapped := fmt.Errorf("processing: %w", &ModelError{Code: ErrNotFound, Message: "no such model", Details: map[string]interface{}{"model": "m"}})
+	resp = ErrorResponse(req, wrapped)
+	assert.Equal(t, ErrNotFound, resp.ErrorCode, "Code should come from the ModelError")
+	assert.Equal(t, map[string]interface{}{"model": "m"}, resp.Details, "Details should come from the ModelError")
+	assert.Equal(t, "processing: no such model", resp.ErrorMessage, "Message should be the whole error")
+}
@@ -0,0 +1,441 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits keeping filters cheap to compile and evaluate.
+const (
+	maxFilterLength = 4096 // Bytes of a filter expression
+	maxFilterDepth  = 32   // Nesting of parentheses
+)
+
+// Filter is a compiled subscription filter, selecting the notifications on a
+// topic a subscriber receives by their payload. Expressions compare fields of
+// the payload, named by dot-separated paths into its JSON form, with literals,
+// and combine comparisons with AND, OR and parentheses:
+//
+//	status == "failed" AND (job.tenant == "acme" OR attempts >= 3)
+//
+// The operators are ==, !=, <, <=, >, >= and contains. Literals are quoted
+// strings, numbers, true, false and null. Path segments that are numbers
+// index arrays. Ordering compares numbers with numbers and strings with
+// strings; contains tests for a substring of a string or an element of an
+// array. A comparison with a missing field or a value of another type is
+// false, whatever the operator. AND binds more tightly than OR, and the
+// keywords are case-insensitive.
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+// filterNode is a compiled part of a filter expression.
+type filterNode interface {
+	match(payload interface{}) bool
+}
+
+type filterAnd []filterNode
+type filterOr []filterNode
+
+// filterCompare compares the field at path with value.
+type filterCompare struct {
+	path  []string
+	op    string
+	value interface{} // string, float64, bool or nil
+}
+
+// ParseFilter compiles a filter expression. It returns an error describing
+// the first problem found, with its offset in expr.
+func ParseFilter(expr string) (*Filter, error) {
+	if len(expr) > maxFilterLength {
+		return nil, fmt.Errorf("filter longer than %d bytes", maxFilterLength)
+	}
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, end: len(expr)}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		return nil, fmt.Errorf("offset %d: unexpected %q", tok.offset, tok.text)
+	}
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// String returns the expression the filter was compiled from.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Match reports whether payload, a value decoded from JSON into
+// interface{}, passes the filter.
+func (f *Filter) Match(payload interface{}) bool {
+	return f.root.match(payload)
+}
+
+// MatchJSON reports whether the JSON document data passes the filter. A
+// document that is not valid JSON does not.
+func (f *Filter) MatchJSON(data []byte) bool {
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false
+	}
+	return f.Match(payload)
+}
+
+func (n filterAnd) match(payload interface{}) bool {
+	for _, node := range n {
+		if !node.match(payload) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n filterOr) match(payload interface{}) bool {
+	for _, node := range n {
+		if node.match(payload) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n filterCompare) match(payload interface{}) bool {
+	field, ok := filterLookup(payload, n.path)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "==":
+		return filterEqual(field, n.value)
+	case "!=":
+		return filterComparable(field, n.value) && !filterEqual(field, n.value)
+	case "contains":
+		switch field := field.(type) {
+		case string:
+			s, ok := n.value.(string)
+			return ok && strings.Contains(field, s)
+		case []interface{}:
+			for _, item := range field {
+				if filterEqual(item, n.value) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var cmp int
+	switch field := field.(type) {
+	case float64:
+		value, ok := n.value.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case field < value:
+			cmp = -1
+		case field > value:
+			cmp = 1
+		}
+	case string:
+		value, ok := n.value.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(field, value)
+	default:
+		return false
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// filterLookup returns the value at path within payload.
+func filterLookup(payload interface{}, path []string) (interface{}, bool) {
+	for _, segment := range path {
+		switch v := payload.(type) {
+		case map[string]interface{}:
+			field, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			payload = field
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			payload = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return payload, true
+}
+
+// filterComparable reports whether a and b are scalars of the same JSON type.
+func filterComparable(a, b interface{}) bool {
+	switch a.(type) {
+	case string:
+		_, ok := b.(string)
+		return ok
+	case float64:
+		_, ok := b.(float64)
+		return ok
+	case bool:
+		_, ok := b.(bool)
+		return ok
+	case nil:
+		return b == nil
+	}
+	return false
+}
+
+// filterEqual reports whether a and b are equal scalars.
+func filterEqual(a, b interface{}) bool {
+	return filterComparable(a, b) && a == b
+}
+
+// filterToken is a lexical element of a filter expression.
+type filterToken struct {
+	kind   filterTokenKind
+	text   string
+	offset int
+}
+
+type filterTokenKind int
+
+const (
+	filterWord   filterTokenKind = iota // A path or keyword
+	filterString                        // A quoted string, text unquoted
+	filterNumber
+	filterOp // A comparison operator other than contains
+	filterOpen
+	filterClose
+)
+
+// lexFilter splits expr into tokens.
+func lexFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: filterOpen, text: "(", offset: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: filterClose, text: ")", offset: i})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("offset %d: unterminated string", i)
+			}
+			text, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("offset %d: invalid string %s", i, expr[i:end+1])
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: text, offset: i})
+			i = end + 1
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			op := expr[i : i+1]
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				op = expr[i : i+2]
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("offset %d: unknown operator %q", i, op)
+			}
+			tokens = append(tokens, filterToken{kind: filterOp, text: op, offset: i})
+			i += len(op)
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(expr) && strings.IndexByte("0123456789.eE+-", expr[end]) >= 0 {
+				end++
+			}
+			if _, err := strconv.ParseFloat(expr[i:end], 64); err != nil {
+				return nil, fmt.Errorf("offset %d: invalid number %s", i, expr[i:end])
+			}
+			tokens = append(tokens, filterToken{kind: filterNumber, text: expr[i:end], offset: i})
+			i = end
+		case isFilterWordByte(c) && c != '.':
+			end := i + 1
+			for end < len(expr) && isFilterWordByte(expr[end]) {
+				end++
+			}
+			tokens = append(tokens, filterToken{kind: filterWord, text: expr[i:end], offset: i})
+			i = end
+		default:
+			return nil, fmt.Errorf("offset %d: unexpected character %q", i, c)
+		}
+	}
+	return tokens, nil
+}
+
+func isFilterWordByte(c byte) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// filterParser builds the tree of a filter expression from its tokens.
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	end    int // Length of the expression, the offset reported at its end
+}
+
+// keyword reports whether the next token is the given keyword, consuming it if so.
+func (p *filterParser) keyword(word string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == filterWord && strings.EqualFold(p.tokens[p.pos].text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// next returns the next token, or an error naming what was expected at the
+// end of the expression.
+func (p *filterParser) next(expected string) (filterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, fmt.Errorf("offset %d: expected %s", p.end, expected)
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) parseOr(depth int) (filterNode, error) {
+	var terms filterOr
+	for {
+		term, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		if !p.keyword("or") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *filterParser) parseAnd(depth int) (filterNode, error) {
+	var terms filterAnd
+	for {
+		term, err := p.parseTerm(depth)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		if !p.keyword("and") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parseTerm parses a parenthesised expression or a comparison.
+func (p *filterParser) parseTerm(depth int) (filterNode, error) {
+	tok, err := p.next("a field")
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == filterOpen {
+		if depth >= maxFilterDepth {
+			return nil, fmt.Errorf("offset %d: parentheses nested more than %d deep", tok.offset, maxFilterDepth)
+		}
+		inner, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		closing, err := p.next(`")"`)
+		if err != nil {
+			return nil, err
+		}
+		if closing.kind != filterClose {
+			return nil, fmt.Errorf("offset %d: expected \")\", found %q", closing.offset, closing.text)
+		}
+		return inner, nil
+	}
+
+	if tok.kind != filterWord || isFilterKeyword(tok.text) {
+		return nil, fmt.Errorf("offset %d: expected a field, found %q", tok.offset, tok.text)
+	}
+	path := strings.Split(tok.text, ".")
+	for _, segment := range path {
+		if segment == "" {
+			return nil, fmt.Errorf("offset %d: empty segment in field %q", tok.offset, tok.text)
+		}
+	}
+
+	opTok, err := p.next("an operator after " + tok.text)
+	if err != nil {
+		return nil, err
+	}
+	op := opTok.text
+	switch {
+	case opTok.kind == filterOp:
+	case opTok.kind == filterWord && strings.EqualFold(op, "contains"):
+		op = "contains"
+	default:
+		return nil, fmt.Errorf("offset %d: expected an operator after %s, found %q", opTok.offset, tok.text, opTok.text)
+	}
+
+	valueTok, err := p.next("a value after " + op)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch {
+	case valueTok.kind == filterString:
+		value = valueTok.text
+	case valueTok.kind == filterNumber:
+		value, _ = strconv.ParseFloat(valueTok.text, 64)
+	case valueTok.kind == filterWord && valueTok.text == "true":
+		value = true
+	case valueTok.kind == filterWord && valueTok.text == "false":
+		value = false
+	case valueTok.kind == filterWord && valueTok.text == "null":
+	default:
+		return nil, fmt.Errorf("offset %d: expected a value after %s, found %q", valueTok.offset, op, valueTok.text)
+	}
+	return filterCompare{path: path, op: op, value: value}, nil
+}
+
+// isFilterKeyword reports whether word is reserved by the filter language.
+func isFilterKeyword(word string) bool {
+	switch strings.ToLower(word) {
+	case "and", "or", "contains":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterMatch(t *testing.T) {
+	payload := []byte(`{
+		"status": "failed",
+		"attempts": 3,
+		"retry": false,
+		"owner": null,
+		"message": "disk full on node-7",
+		"job": {"tenant": "acme", "tags": ["nightly", "gpu"]},
+		"steps": [{"name": "fetch"}, {"name": "train"}]
+	}`)
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`status == "failed"`, true},
+		{`status != "failed"`, false},
+		{`attempts == 3`, true},
+		{`attempts > 2 AND attempts <= 3`, true},
+		{`attempts < 3`, false},
+		{`attempts >= 3.5`, false},
+		{`retry == false`, true},
+		{`owner == null`, true},
+		{`message contains "disk"`, true},
+		{`job.tenant == "acme"`, true},
+		{`job.tags contains "gpu"`, true},
+		{`job.tags contains "cpu"`, false},
+		{`steps.1.name == "train"`, true},
+		{`steps.5.name == "train"`, false},
+		{`status > "a"`, true},
+		{`status == "ok" OR job.tenant == "acme"`, true},
+		{`status == "ok" OR job.tenant == "other" AND attempts == 3`, false},
+		{`(status == "ok" OR job.tenant == "acme") and attempts == 3`, true},
+		{`missing == "x"`, false},
+		{`missing != "x"`, false},
+		{`attempts == "3"`, false},
+		{`attempts != "3"`, false},
+		{`job > 1`, false},
+	}
+	for _, tc := range cases {
+		filter, err := ParseFilter(tc.expr)
+		require.NoError(t, err, "Filter %q should compile", tc.expr)
+		assert.Equal(t, tc.want, filter.MatchJSON(payload), "Filter %q", tc.expr)
+		assert.Equal(t, tc.expr, filter.String(), "Filter should keep its expression")
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{``, "offset 0: expected a field"},
+		{`status`, "offset 6: expected an operator after status"},
+		{`status = "failed"`, `offset 7: unknown operator "="`},
+		{`status == `, "offset 10: expected a value after =="},
+		{`status == failed`, `offset 10: expected a value after ==, found "failed"`},
+		{`status == "failed`, "offset 10: unterminated string"},
+		{`status == "a" AND`, "offset 17: expected a field"},
+		{`(status == "a"`, `offset 14: expected ")"`},
+		{`status == "a")`, `offset 13: unexpected ")"`},
+		{`job..tenant == 1`, `offset 0: empty segment in field "job..tenant"`},
+		{`and == 1`, `offset 0: expected a field, found "and"`},
+		{`status == 1.2.3`, "offset 10: invalid number 1.2.3"},
+		{`status ~ 1`, `offset 7: unexpected character '~'`},
+	}
+	for _, tc := range cases {
+		_, err := ParseFilter(tc.expr)
+		assert.EqualError(t, err, tc.want, "Filter %q should be refused", tc.expr)
+	}
+}
+
+func TestParseFilterLimits(t *testing.T) {
+	deep := ""
+	for i := 0; i <= maxFilterDepth; i++ {
+		deep += "("
+	}
+	_, err := ParseFilter(deep + `a == 1`)
+	assert.Error(t, err, "Deeply nested filters should be refused")
+
+	long := make([]byte, maxFilterLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	_, err = ParseFilter(string(long))
+	assert.Error(t, err, "Overlong filters should be refused")
+}
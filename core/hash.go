@@ -0,0 +1,123 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// HashOptions controls what ModelRequest.HashWith leaves out of a request's
+// fingerprint besides its ID.
+type HashOptions struct {
+	ExcludeMetadata []string // Metadata keys that vary between otherwise equal requests, such as MetadataTraceID
+}
+
+// Hash returns a fingerprint of the request, the hex SHA-256 of its
+// canonical form, for deduplicating and caching requests. Requests with the
+// same model data, parameters, blob references and metadata hash alike,
+// whatever their IDs and the order their maps were filled in. Parameters
+// are ordered by name, and numbers compare by value, so 1 and 1.0 hash
+// alike, as do a request built in process and one decoded from the wire.
+// It fails if the request holds values that cannot be encoded as JSON.
+func (r *ModelRequest) Hash() (string, error) {
+	return r.HashWith(HashOptions{})
+}
+
+// HashWith returns the fingerprint Hash does, leaving out what opts names.
+func (r *ModelRequest) HashWith(opts HashOptions) (string, error) {
+	canonical, err := r.canonical(opts)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Equal reports whether r and other have the same canonical form, as Hash
+// compares them: their IDs may differ. Requests that cannot be encoded are
+// equal to none.
+func (r *ModelRequest) Equal(other *ModelRequest) bool {
+	if r == nil || other == nil {
+		return r == other
+	}
+	a, err := r.canonical(HashOptions{})
+	if err != nil {
+		return false
+	}
+	b, err := other.canonical(HashOptions{})
+	return err == nil && bytes.Equal(a, b)
+}
+
+// canonical returns the encoding of the request that Hash fingerprints:
+// JSON with sorted keys and normalized numbers, without the ID. Empty maps
+// and slices encode as missing ones do.
+func (r *ModelRequest) canonical(opts HashOptions) ([]byte, error) {
+	if r == nil {
+		return nil, errors.New("cannot hash a nil request")
+	}
+	params := slices.Clone(r.Parameters)
+	sort.SliceStable(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	metadata := maps.Clone(r.Metadata)
+	for _, key := range opts.ExcludeMetadata {
+		delete(metadata, key)
+	}
+
+	form := struct {
+		ModelData  map[string]interface{} `json:"modelData,omitempty"`
+		Parameters []Parameter            `json:"parameters,omitempty"`
+		BlobRefs   []BlobID               `json:"blobRefs,omitempty"`
+		Metadata   map[string]string      `json:"metadata,omitempty"`
+	}{r.ModelData, params, r.BlobRefs, metadata}
+	encoded, err := json.Marshal(form)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode and encode again so that numbers, whatever their Go type, are
+	// written one way
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalizeNumbers(value))
+}
+
+// normalizeNumbers rewrites the numbers in a value decoded with UseNumber so
+// that equal numbers are written alike: whole numbers in the range of int64
+// without a fraction or exponent, and others in the shortest form that
+// reads back as the same float64.
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10))
+		}
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return v
+		}
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return json.Number(strconv.FormatInt(int64(f), 10))
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return value
+}
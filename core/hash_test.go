@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hashRequest returns the hash of req, failing the test if it cannot be hashed
+func hashRequest(t *testing.T, req *ModelRequest) string {
+	t.Helper()
+	hash, err := req.Hash()
+	require.NoError(t, err, "Hashing should succeed")
+	return hash
+}
+
+func TestHashIgnoresInsertionOrder(t *testing.T) {
+	keys := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"}
+	build := func(order []string) *ModelRequest {
+		req := NewModelRequest()
+		nested := make(map[string]interface{})
+		for i, key := range order {
+			req.ModelData[key] = i
+			nested[key] = fmt.Sprintf("value-%s", key)
+		}
+		for _, key := range order {
+			req.ModelData[key] = len(key)
+		}
+		req.ModelData["nested"] = nested
+		req.Metadata = map[string]string{"tenant": "acme", "region": "eu"}
+		return req
+	}
+
+	reversed := make([]string, len(keys))
+	for i, key := range keys {
+		reversed[len(keys)-1-i] = key
+	}
+	first, second := build(keys), build(reversed)
+	second.ID = "another-id"
+
+	hash := hashRequest(t, first)
+	assert.Len(t, hash, 64, "The hash should be a hex SHA-256")
+	assert.Equal(t, hash, hashRequest(t, second), "Insertion order and IDs should not change the hash")
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, hash, hashRequest(t, first), "Hashing again should give the same hash")
+	}
+	assert.True(t, first.Equal(second), "Requests with the same content should be equal")
+}
+
+func TestHashChangesWithContent(t *testing.T) {
+	build := func() *ModelRequest {
+		req := NewModelRequest()
+		req.ModelData["input"] = map[string]interface{}{"tokens": []interface{}{"a", "b"}, "depth": 2}
+		req.Parameters = []Parameter{{Name: "temperature", Value: 0.5, Type: "float"}}
+		return req
+	}
+	hash := hashRequest(t, build())
+
+	for name, change := range map[string]func(*ModelRequest){
+		"nested value":    func(r *ModelRequest) { r.ModelData["input"].(map[string]interface{})["depth"] = 3 },
+		"nested list":     func(r *ModelRequest) { r.ModelData["input"].(map[string]interface{})["tokens"] = []interface{}{"b", "a"} },
+		"new key":         func(r *ModelRequest) { r.ModelData["extra"] = nil },
+		"parameter value": func(r *ModelRequest) { r.Parameters[0].Value = 0.6 },
+		"parameter type":  func(r *ModelRequest) { r.Parameters[0].Type = "string" },
+		"blob reference":  func(r *ModelRequest) { r.BlobRefs = []BlobID{"blob-1"} },
+		"metadata":        func(r *ModelRequest) { r.Metadata = map[string]string{"tenant": "acme"} },
+	} {
+		req := build()
+		change(req)
+		assert.NotEqual(t, hash, hashRequest(t, req), "Changing the %s should change the hash", name)
+		assert.False(t, build().Equal(req), "Changing the %s should make the requests unequal", name)
+	}
+}
+
+func TestHashNormalizesNumbersAndParameters(t *testing.T) {
+	local := NewModelRequest()
+	local.ModelData["count"] = 3
+	local.ModelData["ratio"] = float32(0.5)
+	local.ModelData["big"] = int64(1 << 40)
+	local.Parameters = []Parameter{
+		{Name: "top_k", Value: 40, Type: "int"},
+		{Name: "max_tokens", Value: uint(256), Type: "int"},
+	}
+
+	// The same request after a trip over the wire holds float64s, with its
+	// parameters in another order
+	encoded, err := json.Marshal(local)
+	require.NoError(t, err, "Encoding should succeed")
+	var remote ModelRequest
+	require.NoError(t, json.Unmarshal(encoded, &remote), "Decoding should succeed")
+	remote.Parameters[0], remote.Parameters[1] = remote.Parameters[1], remote.Parameters[0]
+	remote.ModelData["count"] = 3.0
+
+	assert.Equal(t, hashRequest(t, local), hashRequest(t, &remote), "Numbers should compare by value and parameters by name")
+	assert.True(t, local.Equal(&remote), "Numbers should compare by value and parameters by name")
+
+	empty := &ModelRequest{ID: "a"}
+	initialized := NewModelRequest()
+	assert.Equal(t, hashRequest(t, empty), hashRequest(t, initialized), "Empty maps and slices should hash as missing ones")
+}
+
+func TestHashWithExcludedMetadata(t *testing.T) {
+	first := NewModelRequest()
+	first.Metadata = map[string]string{MetadataTraceID: "trace-1", "tenant": "acme"}
+	second := first.Clone()
+	second.Metadata[MetadataTraceID] = "trace-2"
+
+	assert.NotEqual(t, hashRequest(t, first), hashRequest(t, second), "Metadata should be hashed by default")
+
+	opts := HashOptions{ExcludeMetadata: []string{MetadataTraceID}}
+	a, err := first.HashWith(opts)
+	require.NoError(t, err, "Hashing should succeed")
+	b, err := second.HashWith(opts)
+	require.NoError(t, err, "Hashing should succeed")
+	assert.Equal(t, a, b, "Excluded metadata should not change the hash")
+
+	second.Metadata["tenant"] = "other"
+	b, err = second.HashWith(opts)
+	require.NoError(t, err, "Hashing should succeed")
+	assert.NotEqual(t, a, b, "Metadata that is not excluded should change the hash")
+	assert.Equal(t, "trace-1", first.Metadata[MetadataTraceID], "Hashing should not change the request")
+}
+
+func TestHashFailures(t *testing.T) {
+	_, err := (*ModelRequest)(nil).Hash()
+	assert.Error(t, err, "A nil request should not hash")
+
+	req := NewModelRequest()
+	req.ModelData["callback"] = func() {}
+	_, err = req.Hash()
+	assert.Error(t, err, "A request that cannot be encoded should not hash")
+	assert.False(t, req.Equal(req), "A request that cannot be encoded should equal none")
+
+	assert.True(t, (*ModelRequest)(nil).Equal(nil), "Nil requests should be equal")
+	assert.False(t, NewModelRequest().Equal(nil), "A request should not equal nil")
+}
@@ -0,0 +1,95 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Method names for asynchronous model processing. A job runs the handler
+// registered for MethodProcessModel off the connection, so processing may
+// outlast any single call.
+const (
+	// MethodSubmitModel starts processing a ModelRequest and returns a
+	// SubmitModelResponse with the job's ID straight away.
+	MethodSubmitModel = "mcp.submitModel"
+
+	// MethodJobStatus returns the JobStatus of the job named by a JobRequest.
+	MethodJobStatus = "mcp.jobStatus"
+
+	// MethodCancelJob cancels the context of the job named by a JobRequest and
+	// returns its JobStatus.
+	MethodCancelJob = "mcp.cancelJob"
+)
+
+// NotifyJobProgress is the notification carrying a JobProgress, published
+// on the job's JobProgressTopic whenever the job reports progress or changes
+// state.
+const NotifyJobProgress = "mcp.jobProgress"
+
+// JobProgressUpdates describes the NotifyJobProgress notification.
+var JobProgressUpdates = RegisterNotification[JobProgress](NotifyJobProgress)
+
+// JobProgressTopic returns the topic the progress of job id is published on.
+func JobProgressTopic(id JobID) string {
+	return fmt.Sprintf("jobs/%s/progress", id)
+}
+
+// CodeJobNotFound is the JSON-RPC error code returned for a job the server
+// does not know, either because it never existed or because its result
+// outlived the server's retention and was discarded.
+const CodeJobNotFound int64 = -32006
+
+// JobID identifies an asynchronous job on the server that runs it.
+type JobID string
+
+// JobState is the lifecycle stage of an asynchronous job.
+type JobState string
+
+// Job states. Pending jobs wait for a free slot, running jobs are in their
+// handler, and done and cancelled jobs are finished.
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobDone      JobState = "done"
+	JobCancelled JobState = "cancelled"
+)
+
+// Finished reports whether the job has stopped, successfully or not.
+func (s JobState) Finished() bool {
+	return s == JobDone || s == JobCancelled
+}
+
+// SubmitModelResponse is the result returned for a MethodSubmitModel call.
+type SubmitModelResponse struct {
+	JobID JobID `json:"jobId"`
+}
+
+// JobRequest names the job of a MethodJobStatus or MethodCancelJob call.
+type JobRequest struct {
+	JobID JobID `json:"jobId"`
+}
+
+// JobStatus describes an asynchronous job. Response is set once the job is
+// done; a handler failure is reported as an unsuccessful response.
+type JobStatus struct {
+	JobID       JobID          `json:"jobId"`
+	State       JobState       `json:"state"`
+	Progress    *JobProgress   `json:"progress,omitempty"` // Latest progress; nil until the job starts
+	Response    *ModelResponse `json:"response,omitempty"`
+	SubmittedAt time.Time      `json:"submittedAt"`
+	FinishedAt  time.Time      `json:"finishedAt"` // Zero until the job is done or cancelled
+}
+
+// JobProgress is a snapshot of how far a job has got: the last progress its
+// handler reported and the state it is in. Sequence increases with every
+// snapshot of a job, so receivers can discard snapshots older than one they
+// have already seen.
+type JobProgress struct {
+	JobID     JobID     `json:"jobId"`
+	State     JobState  `json:"state"`
+	Sequence  int       `json:"sequence"`
+	Percent   float64   `json:"percent"` // Share of the work done, from 0 to 100
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,223 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// CodeRequestTooLarge is the JSON-RPC error code returned for a message whose
+// body exceeds the receiver's size limit. The error data is a
+// RequestTooLargeData. The body is discarded unread, and the connection stays
+// usable.
+const CodeRequestTooLarge int64 = -32007
+
+// DefaultMaxMessageBytes is the default limit on the body of a message read
+// by a client or server.
+const DefaultMaxMessageBytes int64 = 32 << 20
+
+// RequestTooLargeData is the error data of a CodeRequestTooLarge error.
+type RequestTooLargeData struct {
+	Size  int64 `json:"size"`  // Bytes in the message body
+	Limit int64 `json:"limit"` // Largest body the receiver accepts
+}
+
+// FrameTooLargeError is returned by FrameCodec.ReadObject for a frame whose
+// body exceeds MaxBytes. The body has been consumed, so the next frame can be
+// read.
+type FrameTooLargeError struct {
+	ID    *jsonrpc2.ID // ID of the oversized request or response; nil for notifications, or if none was found
+	Size  int64        // Content-Length of the frame, or the bytes decompressed before giving up
+	Limit int64        // MaxBytes of the codec
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("jsonrpc2: message of %d bytes exceeds the limit of %d", e.Size, e.Limit)
+}
+
+// RPCError returns the CodeRequestTooLarge error to reply with.
+func (e *FrameTooLargeError) RPCError() *jsonrpc2.Error {
+	rpcErr := &jsonrpc2.Error{
+		Code:    CodeRequestTooLarge,
+		Message: fmt.Sprintf("message of %d bytes exceeds the limit of %d", e.Size, e.Limit),
+	}
+	rpcErr.SetError(RequestTooLargeData{Size: e.Size, Limit: e.Limit})
+	return rpcErr
+}
+
+// discardFrame consumes a body of size bytes from stream, keeping nothing but
+// the message's ID.
+func discardFrame(stream io.Reader, size int64) (*jsonrpc2.ID, error) {
+	r := bufio.NewReader(io.LimitReader(stream, size))
+	id := parseID(scanID(r))
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// parseID decodes an ID found by scanID.
+func parseID(raw json.RawMessage) *jsonrpc2.ID {
+	if raw == nil {
+		return nil
+	}
+	var id jsonrpc2.ID
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return nil
+	}
+	return &id
+}
+
+// maxIDBytes bounds the ID scanID keeps, as JSON-RPC IDs are short.
+const maxIDBytes = 256
+
+// scanID returns the "id" member of the JSON object read from r, skipping
+// every other value without buffering it. It returns nil if the object has no
+// usable ID or is not valid JSON.
+func scanID(r *bufio.Reader) json.RawMessage {
+	if c, ok := nextToken(r); !ok || c != '{' {
+		return nil
+	}
+	for {
+		c, ok := nextToken(r)
+		if !ok || c != '"' {
+			return nil
+		}
+		key, ok := readString(r, 8)
+		if !ok {
+			return nil
+		}
+		if c, ok := nextToken(r); !ok || c != ':' {
+			return nil
+		}
+		if key == `"id"` {
+			return readID(r)
+		}
+		if !skipValue(r) {
+			return nil
+		}
+		if c, ok := nextToken(r); !ok || c != ',' {
+			return nil
+		}
+	}
+}
+
+// nextToken returns the next byte of r that is not whitespace.
+func nextToken(r *bufio.Reader) (byte, bool) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, false
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c, true
+	}
+}
+
+// readString reads the rest of a string whose opening quote has been read,
+// returning it quoted if it is at most keep bytes long and "" otherwise.
+func readString(r *bufio.Reader, keep int) (string, bool) {
+	buf := []byte{'"'}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", false
+		}
+		if len(buf) <= keep+1 {
+			buf = append(buf, c)
+		}
+		switch c {
+		case '\\':
+			escaped, err := r.ReadByte()
+			if err != nil {
+				return "", false
+			}
+			if len(buf) <= keep+1 {
+				buf = append(buf, escaped)
+			}
+		case '"':
+			if len(buf) > keep+1 {
+				return "", true
+			}
+			return string(buf), true
+		}
+	}
+}
+
+// readID reads an ID value: a short string, a number or null.
+func readID(r *bufio.Reader) json.RawMessage {
+	c, ok := nextToken(r)
+	if !ok {
+		return nil
+	}
+	if c == '"' {
+		id, ok := readString(r, maxIDBytes)
+		if !ok || id == "" {
+			return nil
+		}
+		return json.RawMessage(id)
+	}
+	buf := []byte{c}
+	for len(buf) <= maxIDBytes {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil
+		}
+		if c == ',' || c == '}' || c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			if string(buf) == "null" {
+				return nil
+			}
+			return json.RawMessage(buf)
+		}
+		buf = append(buf, c)
+	}
+	return nil
+}
+
+// skipValue consumes the next value of r.
+func skipValue(r *bufio.Reader) bool {
+	c, ok := nextToken(r)
+	if !ok {
+		return false
+	}
+	switch c {
+	case '"':
+		_, ok := readString(r, 0)
+		return ok
+	case '{', '[':
+		for depth := 1; depth > 0; {
+			c, err := r.ReadByte()
+			if err != nil {
+				return false
+			}
+			switch c {
+			case '"':
+				if _, ok := readString(r, 0); !ok {
+					return false
+				}
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		return true
+	}
+	// Numbers and literals run up to the next delimiter, which is left unread
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		if c == ',' || c == '}' || c == ']' || c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			return r.UnreadByte() == nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanID(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		id   string
+	}{
+		{"NumberFirst", `{"id":7,"method":"m"}`, `7`},
+		{"StringLast", `{"method":"m","params":{"id":1,"data":["}",{"x":"\"id\""}]},"id":"req-1"}`, `"req-1"`},
+		{"Whitespace", "{ \"jsonrpc\" : \"2.0\" ,\r\n \"id\" : 42 }", `42`},
+		{"EscapedKey", `{"i\"d":1,"id":2}`, `2`},
+		{"Notification", `{"method":"m","params":{}}`, ``},
+		{"NullID", `{"id":null}`, ``},
+		{"NotAnObject", `["id",1]`, ``},
+		{"Truncated", `{"method":"m","params":{"data":"`, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.id, string(scanID(bufio.NewReader(strings.NewReader(tt.body)))), "ID should be found without decoding other values")
+		})
+	}
+}
+
+func TestFrameCodecMaxBytes(t *testing.T) {
+	codec := FrameCodec{MaxBytes: 1 << 10}
+	under := map[string]interface{}{"id": 1, "data": strings.Repeat("u", 900)}
+	over := map[string]interface{}{"id": "big", "data": strings.Repeat("o", 2000)}
+	notification := map[string]interface{}{"method": "m", "data": strings.Repeat("n", 2000)}
+
+	var buf bytes.Buffer
+	for _, msg := range []interface{}{under, over, notification, under} {
+		require.NoError(t, FrameCodec{}.WriteObject(&buf, msg), "Writing a frame should succeed")
+	}
+	r := bufio.NewReader(&buf)
+
+	var got map[string]interface{}
+	require.NoError(t, codec.ReadObject(r, &got), "Frame under the limit should be read")
+	assert.Equal(t, under["data"], got["data"], "Frame under the limit should round trip")
+
+	err := codec.ReadObject(r, &got)
+	var tooLarge *FrameTooLargeError
+	require.True(t, errors.As(err, &tooLarge), "Frame over the limit should be refused")
+	require.NotNil(t, tooLarge.ID, "Refusal should carry the request's ID")
+	assert.Equal(t, jsonrpc2.ID{Str: "big", IsString: true}, *tooLarge.ID, "Refusal should carry the request's ID")
+	assert.Greater(t, tooLarge.Size, int64(2000), "Refusal should carry the declared size")
+	assert.Equal(t, int64(1<<10), tooLarge.Limit, "Refusal should carry the limit")
+	assert.Equal(t, CodeRequestTooLarge, tooLarge.RPCError().Code, "Refusal should reply with CodeRequestTooLarge")
+
+	err = codec.ReadObject(r, &got)
+	require.True(t, errors.As(err, &tooLarge), "Oversized notification should be refused")
+	assert.Nil(t, tooLarge.ID, "Notifications have no ID to reply to")
+
+	got = nil
+	require.NoError(t, codec.ReadObject(r, &got), "Frames after refused ones should be read")
+	assert.Equal(t, under["data"], got["data"], "Frame after refused ones should round trip")
+}
+
+func TestFrameCodecMaxBytesDecompressed(t *testing.T) {
+	// A body that compresses well is held to the limit once decompressed
+	var buf bytes.Buffer
+	msg := map[string]interface{}{"id": 3, "data": strings.Repeat("z", 1<<12)}
+	require.NoError(t, FrameCodec{Compression: CompressionGzip}.WriteObject(&buf, msg), "Writing a compressed frame should succeed")
+	require.Less(t, buf.Len(), 1<<10, "Compressed frame should fit under the limit")
+
+	err := FrameCodec{MaxBytes: 1 << 10}.ReadObject(bufio.NewReader(&buf), &msg)
+	var tooLarge *FrameTooLargeError
+	require.True(t, errors.As(err, &tooLarge), "Body over the limit once decompressed should be refused")
+	require.NotNil(t, tooLarge.ID, "Refusal should carry the request's ID")
+	assert.Equal(t, jsonrpc2.ID{Num: 3}, *tooLarge.ID, "Refusal should carry the request's ID")
+}
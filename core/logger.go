@@ -0,0 +1,47 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "log/slog"
+
+// Logger receives log records from clients and servers. Arguments after the
+// message are alternating keys and values, as with slog. A *slog.Logger
+// satisfies Logger directly.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Field names used in structured log records.
+const (
+	LogFieldRemoteAddr = "remote_addr"
+	LogFieldMethod     = "method"
+	LogFieldRequestID  = "request_id"
+	LogFieldError      = "error"
+)
+
+// DefaultLogger returns a Logger writing to slog.Default, looked up on every
+// call so that a later slog.SetDefault takes effect.
+func DefaultLogger() Logger {
+	return defaultLogger{}
+}
+
+type defaultLogger struct{}
+
+func (defaultLogger) Debug(msg string, args ...any) { slog.Default().Debug(msg, args...) }
+func (defaultLogger) Info(msg string, args ...any)  { slog.Default().Info(msg, args...) }
+func (defaultLogger) Warn(msg string, args ...any)  { slog.Default().Warn(msg, args...) }
+func (defaultLogger) Error(msg string, args ...any) { slog.Default().Error(msg, args...) }
+
+// NopLogger returns a Logger that discards everything.
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
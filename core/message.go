@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"unsafe"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+var (
+	requestType  = reflect.TypeOf((*jsonrpc2.Request)(nil))
+	responseType = reflect.TypeOf((*jsonrpc2.Response)(nil))
+)
+
+// envelope returns where the request and response of v are kept if v points
+// to the message type jsonrpc2 hands to its ObjectStream, which holds one or
+// the other in unexported fields. Reaching them lets JSON messages be encoded
+// and decoded field by field: jsonrpc2 decodes each one twice, the first time
+// into interface{} values just to tell requests from responses.
+func envelope(v interface{}) (**jsonrpc2.Request, **jsonrpc2.Response, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, nil, false
+	}
+	rv = rv.Elem()
+	t := rv.Type()
+	if t.Kind() != reflect.Struct || t.PkgPath() != requestType.Elem().PkgPath() || t.NumField() != 2 ||
+		t.Field(0).Type != requestType || t.Field(1).Type != responseType {
+		return nil, nil, false
+	}
+	req := (**jsonrpc2.Request)(unsafe.Pointer(rv.Field(0).UnsafeAddr()))
+	resp := (**jsonrpc2.Response)(unsafe.Pointer(rv.Field(1).UnsafeAddr()))
+	return req, resp, true
+}
+
+// writeMessage writes the JSON encoding of obj to buf, as json.Marshal would,
+// reporting false if obj is not a single JSON-RPC message.
+func writeMessage(buf *bytes.Buffer, obj interface{}) (bool, error) {
+	req, resp, ok := envelope(obj)
+	if !ok {
+		return false, nil
+	}
+	if *req != nil {
+		return true, writeRequest(buf, *req)
+	}
+	if *resp != nil {
+		return true, writeResponse(buf, *resp)
+	}
+	return false, nil
+}
+
+// writeRequest writes req with the fields in the order jsonrpc2 marshals
+// them, which compression advisors rely on to find its method.
+func writeRequest(buf *bytes.Buffer, req *jsonrpc2.Request) error {
+	buf.WriteString(`{"method":`)
+	writeString(buf, req.Method)
+	if req.Params != nil {
+		buf.WriteString(`,"params":`)
+		if err := writeRaw(buf, *req.Params); err != nil {
+			return err
+		}
+	}
+	if !req.Notif {
+		buf.WriteString(`,"id":`)
+		writeID(buf, req.ID)
+	}
+	if req.Meta != nil {
+		buf.WriteString(`,"meta":`)
+		if err := writeRaw(buf, *req.Meta); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(`,"jsonrpc":"2.0"}`)
+	return nil
+}
+
+// writeResponse writes resp with the fields in the order jsonrpc2 marshals
+// them, its ID first.
+func writeResponse(buf *bytes.Buffer, resp *jsonrpc2.Response) error {
+	if (resp.Result == nil || len(*resp.Result) == 0) && resp.Error == nil {
+		return errors.New("can't marshal *jsonrpc2.Response (must have result or error)")
+	}
+	buf.WriteString(`{"id":`)
+	writeID(buf, resp.ID)
+	if resp.Result != nil && len(*resp.Result) > 0 {
+		buf.WriteString(`,"result":`)
+		if err := writeRaw(buf, *resp.Result); err != nil {
+			return err
+		}
+	}
+	if resp.Error != nil {
+		buf.WriteString(`,"error":{"code":`)
+		buf.Write(strconv.AppendInt(buf.AvailableBuffer(), resp.Error.Code, 10))
+		buf.WriteString(`,"message":`)
+		writeString(buf, resp.Error.Message)
+		buf.WriteString(`,"data":`)
+		if resp.Error.Data == nil {
+			buf.WriteString("null")
+		} else if err := writeRaw(buf, *resp.Error.Data); err != nil {
+			return err
+		}
+		buf.WriteByte('}')
+	}
+	if resp.Meta != nil {
+		buf.WriteString(`,"meta":`)
+		if err := writeRaw(buf, *resp.Meta); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(`,"jsonrpc":"2.0"}`)
+	return nil
+}
+
+// writeRaw writes a JSON value, checked, compacted and escaped for HTML as
+// json.Marshal does, with an empty one as null.
+func writeRaw(buf *bytes.Buffer, raw json.RawMessage) error {
+	if len(raw) == 0 {
+		buf.WriteString("null")
+		return nil
+	}
+	start := buf.Len()
+	if err := json.Compact(buf, raw); err != nil {
+		return err
+	}
+	if compacted := buf.Bytes()[start:]; bytes.ContainsAny(compacted, "<>&\u2028\u2029") {
+		escaped := bytes.Clone(compacted)
+		buf.Truncate(start)
+		json.HTMLEscape(buf, escaped)
+	}
+	return nil
+}
+
+func writeID(buf *bytes.Buffer, id jsonrpc2.ID) {
+	if id.IsString {
+		writeString(buf, id.Str)
+		return
+	}
+	buf.Write(strconv.AppendUint(buf.AvailableBuffer(), id.Num, 10))
+}
+
+// writeString writes s as a JSON string. Method names and IDs rarely need
+// escaping, so only those that do go through json.Marshal.
+func writeString(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			quoted, _ := json.Marshal(s)
+			buf.Write(quoted)
+			return
+		}
+	}
+	buf.WriteByte('"')
+	buf.WriteString(s)
+	buf.WriteByte('"')
+}
+
+// wireMessage is a request or response as decoded from JSON. Params and
+// Result hold null when they are, and nothing when they are absent.
+type wireMessage struct {
+	Method *string          `json:"method"`
+	ID     wireID           `json:"id"`
+	Params json.RawMessage  `json:"params"`
+	Result json.RawMessage  `json:"result"`
+	Error  *jsonrpc2.Error  `json:"error"`
+	Meta   *json.RawMessage `json:"meta"`
+}
+
+// wireID is a request ID decoded without jsonrpc2.ID's attempt to parse
+// strings as numbers first, which allocates an error for every string ID.
+type wireID struct {
+	id  jsonrpc2.ID
+	set bool // False when absent or null
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *wireID) UnmarshalJSON(data []byte) error {
+	switch {
+	case string(data) == "null":
+		*w = wireID{}
+		return nil
+	case data[0] == '"':
+		w.id.IsString = true
+		if err := json.Unmarshal(data, &w.id.Str); err != nil {
+			return err
+		}
+	default:
+		if err := w.id.UnmarshalJSON(data); err != nil {
+			return err
+		}
+	}
+	w.set = true
+	return nil
+}
+
+// readMessage decodes data into obj if obj is where jsonrpc2 reads a message
+// and data holds a single one, as jsonrpc2 would, reporting whether it did.
+func readMessage(data []byte, obj interface{}) (bool, error) {
+	req, resp, ok := envelope(obj)
+	if !ok || !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("{")) {
+		return false, nil
+	}
+	m := new(wireMessage)
+	if err := json.Unmarshal(data, m); err != nil {
+		return true, err
+	}
+	isRequest := m.Method != nil
+	isResponse := len(m.Result) > 0 || m.Error != nil
+	if isRequest == isResponse {
+		return true, errors.New("jsonrpc2: unable to determine message type (request or response)")
+	}
+
+	if isRequest {
+		r := &jsonrpc2.Request{Method: *m.Method, ID: m.ID.id, Notif: !m.ID.set, Meta: m.Meta}
+		if len(m.Params) > 0 {
+			r.Params = &m.Params
+		}
+		*req, *resp = r, nil
+		return true, nil
+	}
+	r := &jsonrpc2.Response{ID: m.ID.id, Error: m.Error, Meta: m.Meta}
+	if len(m.Result) > 0 {
+		r.Result = &m.Result
+	}
+	*req, *resp = nil, r
+	return true, nil
+}
@@ -0,0 +1,131 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Well-known metadata keys for cross-cutting request information.
+const (
+	// MetadataTraceID carries the distributed trace a request belongs to.
+	MetadataTraceID = "trace_id"
+
+	// MetadataTenantID carries the tenant a request is made on behalf of.
+	MetadataTenantID = "tenant_id"
+
+	// MetadataPriority carries the priority of a request, e.g. PriorityHigh.
+	MetadataPriority = "priority"
+
+	// MetadataTimeout carries how long, in milliseconds, the caller waits for
+	// the response from when it sent the request. The server stops the
+	// handler's context once that time has passed.
+	MetadataTimeout = "timeout_ms"
+
+	// MetadataCache tells a client with a response cache how to treat the
+	// request: CacheBypass or CacheRefresh. Other values, or none, let a
+	// cached response answer it.
+	MetadataCache = "cache"
+
+	// MetadataIdempotencyKey identifies a request across the attempts made to
+	// send it, so a server with middleware.Idempotency runs it once however
+	// often it arrives. Clients with WithRetry set it on every request.
+	MetadataIdempotencyKey = "idempotency_key"
+)
+
+// MetadataCache values.
+const (
+	CacheBypass  = "bypass"  // Send the request and leave the cache as it is
+	CacheRefresh = "refresh" // Send the request and cache the new response
+)
+
+// PriorityHigh is the MetadataPriority of requests that may borrow from the
+// server's reserve when their principal is at its concurrency limit.
+const PriorityHigh = "high"
+
+// metadataKey is the context key holding request metadata.
+type metadataKey struct{}
+
+// metadataCarrier holds the metadata of one request. It is shared by every
+// context derived from the one it was added to, so values appended by a
+// handler are visible to the server when it builds the response.
+type metadataCarrier struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// ContextWithMetadata returns a copy of ctx carrying a copy of md, replacing
+// any metadata already in ctx. The server calls it with the metadata of each
+// incoming request before invoking the handler.
+func ContextWithMetadata(ctx context.Context, md map[string]string) context.Context {
+	carrier := &metadataCarrier{values: make(map[string]string, len(md))}
+	for key, value := range md {
+		carrier.values[key] = value
+	}
+	return context.WithValue(ctx, metadataKey{}, carrier)
+}
+
+// MetadataFromContext returns a copy of the metadata in ctx, or nil if ctx
+// carries none. A client given such a context passes the metadata on to the
+// server it calls.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	carrier, ok := ctx.Value(metadataKey{}).(*metadataCarrier)
+	if !ok {
+		return nil
+	}
+
+	carrier.mu.RLock()
+	defer carrier.mu.RUnlock()
+	md := make(map[string]string, len(carrier.values))
+	for key, value := range carrier.values {
+		md[key] = value
+	}
+	return md
+}
+
+// MetadataValue returns the metadata value for key in ctx.
+func MetadataValue(ctx context.Context, key string) (string, bool) {
+	carrier, ok := ctx.Value(metadataKey{}).(*metadataCarrier)
+	if !ok {
+		return "", false
+	}
+
+	carrier.mu.RLock()
+	defer carrier.mu.RUnlock()
+	value, ok := carrier.values[key]
+	return value, ok
+}
+
+// AppendMetadata sets key to value in the metadata of ctx, so it can be
+// echoed in the response or propagated to downstream calls. It reports false
+// if ctx carries no metadata; use ContextWithMetadata to start some.
+func AppendMetadata(ctx context.Context, key, value string) bool {
+	carrier, ok := ctx.Value(metadataKey{}).(*metadataCarrier)
+	if !ok {
+		return false
+	}
+
+	carrier.mu.Lock()
+	defer carrier.mu.Unlock()
+	carrier.values[key] = value
+	return true
+}
+
+// FormatTimeout returns d as a MetadataTimeout value, rounded up to a whole
+// millisecond so a nearly expired deadline is not sent as none at all.
+func FormatTimeout(d time.Duration) string {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	return strconv.FormatInt(int64(max(ms, 1)), 10)
+}
+
+// TimeoutFromMetadata returns the MetadataTimeout in md. It reports false if
+// md carries none or it is not a positive number of milliseconds.
+func TimeoutFromMetadata(md map[string]string) (time.Duration, bool) {
+	ms, err := strconv.ParseInt(md[MetadataTimeout], 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
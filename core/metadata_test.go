@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataFromContext(t *testing.T) {
+	// A plain context carries no metadata
+	assert.Nil(t, MetadataFromContext(context.Background()), "Plain context should carry no metadata")
+	_, ok := MetadataValue(context.Background(), MetadataTraceID)
+	assert.False(t, ok, "Plain context should have no trace ID")
+
+	md := map[string]string{MetadataTraceID: "trace-1"}
+	ctx := ContextWithMetadata(context.Background(), md)
+	md[MetadataTraceID] = "changed"
+
+	value, ok := MetadataValue(ctx, MetadataTraceID)
+	assert.True(t, ok, "Trace ID should be present")
+	assert.Equal(t, "trace-1", value, "Context should hold a copy of the metadata")
+
+	// The returned map is a copy too
+	MetadataFromContext(ctx)[MetadataTraceID] = "changed"
+	assert.Equal(t, map[string]string{MetadataTraceID: "trace-1"}, MetadataFromContext(ctx), "Returned metadata should be a copy")
+}
+
+func TestAppendMetadata(t *testing.T) {
+	assert.False(t, AppendMetadata(context.Background(), MetadataTenantID, "acme"), "Append should fail without metadata")
+
+	ctx := ContextWithMetadata(context.Background(), nil)
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Values appended through a derived context are visible to the parent
+	assert.True(t, AppendMetadata(child, MetadataTenantID, "acme"), "Append should succeed")
+	value, ok := MetadataValue(ctx, MetadataTenantID)
+	assert.True(t, ok, "Appended value should be present")
+	assert.Equal(t, "acme", value, "Appended value should be shared with the parent context")
+}
+
+func TestTimeoutMetadata(t *testing.T) {
+	assert.Equal(t, "250", FormatTimeout(250*time.Millisecond), "Whole milliseconds should be sent as is")
+	assert.Equal(t, "2", FormatTimeout(1500*time.Microsecond), "Partial milliseconds should be rounded up")
+	assert.Equal(t, "1", FormatTimeout(0), "An expired deadline should still be sent")
+
+	timeout, ok := TimeoutFromMetadata(map[string]string{MetadataTimeout: FormatTimeout(250 * time.Millisecond)})
+	assert.True(t, ok, "Formatted timeout should be read back")
+	assert.Equal(t, 250*time.Millisecond, timeout, "Timeout should survive the round trip")
+
+	for _, value := range []string{"", "soon", "0", "-5"} {
+		_, ok := TimeoutFromMetadata(map[string]string{MetadataTimeout: value})
+		assert.False(t, ok, "Timeout %q should be ignored", value)
+	}
+	_, ok = TimeoutFromMetadata(nil)
+	assert.False(t, ok, "Missing metadata should carry no timeout")
+}
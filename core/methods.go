@@ -0,0 +1,46 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "time"
+
+// Method names for the JSON-RPC methods defined by the protocol.
+const (
+	// MethodProcessModel processes a single model request.
+	MethodProcessModel = "mcp.processModel"
+
+	// MethodProcessModelBatch processes several model requests in one round trip.
+	// It is served by the handler registered for MethodProcessModel.
+	MethodProcessModelBatch = "mcp.processModelBatch"
+
+	// MethodProcessModelStream processes a model request whose handler sends
+	// partial results as NotifyModelChunk notifications before the final
+	// ModelResponse.
+	MethodProcessModelStream = "mcp.processModelStream"
+
+	// MethodPing is a keepalive probe answered by the server itself,
+	// independently of any registered handler.
+	MethodPing = "mcp.ping"
+
+	// MethodHealth reports the server's status and the methods it serves. It
+	// is answered by the server itself once the caller is authorized.
+	MethodHealth = "mcp.health"
+
+	// MethodShutdown is the notification a server sends the child process
+	// serving a handler group over stdio before stopping it, and routes no
+	// more requests to it. The child answers the requests it is handling and
+	// closes its end of the connection once it is idle.
+	MethodShutdown = "mcp.shutdown"
+)
+
+// PingResponse is the result returned for a MethodPing call.
+type PingResponse struct {
+	Timestamp time.Time `json:"timestamp"` // Server time when the ping was answered
+}
+
+// HealthResponse is the result returned for a MethodHealth call.
+type HealthResponse struct {
+	Status      Status              `json:"status"`      // Server status; only StatusRunning serves requests
+	Uptime      time.Duration       `json:"uptime"`      // Time since the server started running
+	Connections int                 `json:"connections"` // Connections being served
+	Handlers    map[string][]string `json:"handlers"`    // Registered methods, sorted, by handler type
+}
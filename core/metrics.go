@@ -0,0 +1,64 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "time"
+
+// MetricsCollector receives measurements from clients and servers. Methods are
+// called synchronously on the connection's goroutines and must not block;
+// implementations must be safe for concurrent use.
+//
+// Addresses identify the peer: the client address on a server, the server
+// address on a client. Payload sizes are the encoded JSON bytes of the params
+// and of the result or error; on a server bytesIn is the request and bytesOut
+// the reply, on a client the other way round.
+type MetricsCollector interface {
+	// ConnectionOpened is called when a connection is established.
+	ConnectionOpened(addr string)
+
+	// ConnectionClosed is called when a connection ends.
+	ConnectionClosed(addr string)
+
+	// RequestStarted is called before a request is dispatched or sent.
+	RequestStarted(method string)
+
+	// RequestCompleted is called once the reply is sent or received.
+	// success is false when the reply is a JSON-RPC error.
+	RequestCompleted(method string, duration time.Duration, success bool)
+
+	// PayloadSize is called with the sizes of each request and its reply.
+	PayloadSize(method string, bytesIn, bytesOut int)
+}
+
+// StallCollector can be implemented by a MetricsCollector to count
+// connections whose peer stopped sending partway through a frame. Servers
+// with stall detection enabled call it for each stall they detect.
+type StallCollector interface {
+	ConnectionStalled(addr string, stalled time.Duration)
+}
+
+// CancelCollector can be implemented by a MetricsCollector to count requests
+// whose context the server cancelled, by the Cause it gave. Servers call it
+// once the reply to such a request is sent.
+type CancelCollector interface {
+	RequestCancelled(method string, cause Cause)
+}
+
+// TimeoutCollector can be implemented by a MetricsCollector to count
+// requests whose handler ran past the server's handler timeout. stuck is
+// true when the handler ignored its context and was abandoned still running.
+type TimeoutCollector interface {
+	HandlerTimedOut(method string, stuck bool)
+}
+
+// NopMetrics returns a MetricsCollector that discards every measurement.
+func NopMetrics() MetricsCollector {
+	return nopMetrics{}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ConnectionOpened(string)                      {}
+func (nopMetrics) ConnectionClosed(string)                      {}
+func (nopMetrics) RequestStarted(string)                        {}
+func (nopMetrics) RequestCompleted(string, time.Duration, bool) {}
+func (nopMetrics) PayloadSize(string, int, int)                 {}
@@ -0,0 +1,25 @@
+package core
+
+import "context"
+
+// ProcessFunc processes a model request: on a server, by validating it and
+// running the handler; on a client, by sending it to the server and waiting
+// for the response.
+type ProcessFunc func(ctx context.Context, req *ModelRequest) (*ModelResponse, error)
+
+// Middleware wraps the processing of model requests to method, such as
+// mcp.processModel, to observe or alter requests, responses and errors. It
+// is installed with server.WithMiddleware or client.WithInterceptors and
+// called once per request. It may return without calling next to answer the
+// request itself; on a server, returning a *jsonrpc2.Error fails the request
+// with that error as-is.
+type Middleware func(method string, next ProcessFunc) ProcessFunc
+
+// ChainMiddleware wraps process in middleware, the first outermost, for
+// requests to method.
+func ChainMiddleware(method string, process ProcessFunc, middleware ...Middleware) ProcessFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		process = middleware[i](method, process)
+	}
+	return process
+}
@@ -0,0 +1,227 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// The accessors below read values of ModelData, Results and parameters
+// whatever Go type they hold. A request that crossed the wire holds numbers
+// as float64, while one built in process may hold ints, so numbers are
+// converted between the two where no precision is lost: a float64 is an int
+// only if it is a whole number in range. Each accessor reports false for a
+// missing key or a value of another type.
+
+// GetString returns the string stored under key in the model data.
+func (r *ModelRequest) GetString(key string) (string, bool) {
+	return asString(r.ModelData[key])
+}
+
+// GetInt returns the whole number stored under key in the model data.
+func (r *ModelRequest) GetInt(key string) (int, bool) {
+	return asInt(r.ModelData[key])
+}
+
+// GetFloat returns the number stored under key in the model data.
+func (r *ModelRequest) GetFloat(key string) (float64, bool) {
+	return asFloat(r.ModelData[key])
+}
+
+// GetBool returns the bool stored under key in the model data.
+func (r *ModelRequest) GetBool(key string) (bool, bool) {
+	return asBool(r.ModelData[key])
+}
+
+// GetStringSlice returns the list of strings stored under key in the model
+// data.
+func (r *ModelRequest) GetStringSlice(key string) ([]string, bool) {
+	return asStringSlice(r.ModelData[key])
+}
+
+// DecodeModelData decodes the model data into v, typically a pointer to a
+// struct with json tags, as if it had been received as JSON.
+func (r *ModelRequest) DecodeModelData(v interface{}) error {
+	return roundTrip(r.ModelData, v)
+}
+
+// GetParameter returns the first parameter with the given name. The
+// parameter returned is the request's own, so changes to it are kept.
+func (r *ModelRequest) GetParameter(name string) (*Parameter, bool) {
+	for i := range r.Parameters {
+		if r.Parameters[i].Name == name {
+			return &r.Parameters[i], true
+		}
+	}
+	return nil, false
+}
+
+// AsString returns the parameter's value if it is a string.
+func (p Parameter) AsString() (string, bool) {
+	return asString(p.Value)
+}
+
+// AsInt returns the parameter's value if it is a whole number.
+func (p Parameter) AsInt() (int, bool) {
+	return asInt(p.Value)
+}
+
+// AsFloat returns the parameter's value if it is a number.
+func (p Parameter) AsFloat() (float64, bool) {
+	return asFloat(p.Value)
+}
+
+// AsBool returns the parameter's value if it is a bool.
+func (p Parameter) AsBool() (bool, bool) {
+	return asBool(p.Value)
+}
+
+// SetResult stores value under key in the results.
+func (r *ModelResponse) SetResult(key string, value interface{}) {
+	if r.Results == nil {
+		r.Results = make(map[string]interface{})
+	}
+	r.Results[key] = value
+}
+
+// GetString returns the string stored under key in the results.
+func (r *ModelResponse) GetString(key string) (string, bool) {
+	return asString(r.Results[key])
+}
+
+// GetInt returns the whole number stored under key in the results.
+func (r *ModelResponse) GetInt(key string) (int, bool) {
+	return asInt(r.Results[key])
+}
+
+// GetFloat returns the number stored under key in the results.
+func (r *ModelResponse) GetFloat(key string) (float64, bool) {
+	return asFloat(r.Results[key])
+}
+
+// GetBool returns the bool stored under key in the results.
+func (r *ModelResponse) GetBool(key string) (bool, bool) {
+	return asBool(r.Results[key])
+}
+
+// GetStringSlice returns the list of strings stored under key in the
+// results.
+func (r *ModelResponse) GetStringSlice(key string) ([]string, bool) {
+	return asStringSlice(r.Results[key])
+}
+
+// DecodeResults decodes the results into v, typically a pointer to a struct
+// with json tags, as if they had been received as JSON.
+func (r *ModelResponse) DecodeResults(v interface{}) error {
+	return roundTrip(r.Results, v)
+}
+
+func asString(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	return s, ok
+}
+
+func asBool(v interface{}) (bool, bool) {
+	b, ok := v.(bool)
+	return b, ok
+}
+
+func asInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		if n < math.MinInt || n > math.MaxInt {
+			return 0, false
+		}
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		if uint64(n) > math.MaxInt {
+			return 0, false
+		}
+		return int(n), true
+	case uint:
+		if uint64(n) > math.MaxInt {
+			return 0, false
+		}
+		return int(n), true
+	case uint64:
+		if n > math.MaxInt {
+			return 0, false
+		}
+		return int(n), true
+	case float32:
+		return floatToInt(float64(n))
+	case float64:
+		return floatToInt(n)
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return 0, false
+		}
+		return asInt(i)
+	}
+	return 0, false
+}
+
+// floatToInt converts f to an int if it is a whole number in range.
+func floatToInt(f float64) (int, bool) {
+	// float64(math.MaxInt) rounds up to 2^63, which is already out of range
+	if f != math.Trunc(f) || f < math.MinInt || f >= math.MaxInt {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func asFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	if i, ok := asInt(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func asStringSlice(v interface{}) ([]string, bool) {
+	switch list := v.(type) {
+	case []string:
+		return list, true
+	case []interface{}:
+		out := make([]string, len(list))
+		for i, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// roundTrip encodes m as JSON and decodes it into v.
+func roundTrip(m map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
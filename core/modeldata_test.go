@@ -0,0 +1,222 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodedRequest returns a request with the given model data as a server
+// receives it, after a round trip through JSON
+func decodedRequest(t *testing.T, data map[string]interface{}) *ModelRequest {
+	req := NewModelRequest()
+	req.ModelData = data
+	raw, err := json.Marshal(req)
+	require.NoError(t, err, "Request should encode")
+	var decoded ModelRequest
+	require.NoError(t, json.Unmarshal(raw, &decoded), "Request should decode")
+	return &decoded
+}
+
+func TestModelDataNumbers(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		wantInt   int
+		intOK     bool
+		wantFloat float64
+		floatOK   bool
+	}{
+		{name: "int", value: 42, wantInt: 42, intOK: true, wantFloat: 42, floatOK: true},
+		{name: "int64", value: int64(-7), wantInt: -7, intOK: true, wantFloat: -7, floatOK: true},
+		{name: "uint8", value: uint8(200), wantInt: 200, intOK: true, wantFloat: 200, floatOK: true},
+		{name: "whole float64", value: 42.0, wantInt: 42, intOK: true, wantFloat: 42, floatOK: true},
+		{name: "fractional float64", value: 2.5, wantFloat: 2.5, floatOK: true},
+		{name: "float32", value: float32(3), wantInt: 3, intOK: true, wantFloat: 3, floatOK: true},
+		{name: "float64 out of range", value: 1e300, wantFloat: 1e300, floatOK: true},
+		{name: "uint64 out of range", value: uint64(math.MaxUint64)},
+		{name: "infinity", value: math.Inf(1), wantFloat: math.Inf(1), floatOK: true},
+		{name: "json.Number", value: json.Number("12"), wantInt: 12, intOK: true, wantFloat: 12, floatOK: true},
+		{name: "string", value: "42"},
+		{name: "bool", value: true},
+		{name: "missing", value: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := NewModelRequest()
+			if tt.value != nil {
+				req.ModelData["n"] = tt.value
+			}
+
+			got, ok := req.GetInt("n")
+			assert.Equal(t, tt.intOK, ok, "GetInt should report whether the value is a whole number")
+			assert.Equal(t, tt.wantInt, got, "GetInt should return the value")
+
+			gotFloat, ok := req.GetFloat("n")
+			assert.Equal(t, tt.floatOK, ok, "GetFloat should report whether the value is a number")
+			assert.Equal(t, tt.wantFloat, gotFloat, "GetFloat should return the value")
+		})
+	}
+}
+
+func TestModelDataAfterJSON(t *testing.T) {
+	req := decodedRequest(t, map[string]interface{}{
+		"count":   3,
+		"ratio":   0.25,
+		"name":    "widget",
+		"enabled": true,
+		"tags":    []string{"a", "b"},
+		"mixed":   []interface{}{"a", 1},
+	})
+
+	// Numbers arrive as float64 but read back as the int they were sent as
+	_, isFloat := req.ModelData["count"].(float64)
+	require.True(t, isFloat, "JSON numbers should decode to float64")
+
+	count, ok := req.GetInt("count")
+	assert.True(t, ok, "Whole number should read as an int")
+	assert.Equal(t, 3, count, "Count should survive the round trip")
+	_, ok = req.GetInt("ratio")
+	assert.False(t, ok, "Fraction should not read as an int")
+
+	name, ok := req.GetString("name")
+	assert.True(t, ok, "String should be found")
+	assert.Equal(t, "widget", name, "String should be returned")
+	_, ok = req.GetString("count")
+	assert.False(t, ok, "Number should not read as a string")
+
+	enabled, ok := req.GetBool("enabled")
+	assert.True(t, ok && enabled, "Bool should be found")
+	_, ok = req.GetBool("name")
+	assert.False(t, ok, "String should not read as a bool")
+
+	tags, ok := req.GetStringSlice("tags")
+	assert.True(t, ok, "String list should be found")
+	assert.Equal(t, []string{"a", "b"}, tags, "String list should be returned")
+	_, ok = req.GetStringSlice("mixed")
+	assert.False(t, ok, "List with a non-string should not read as strings")
+	_, ok = req.GetStringSlice("missing")
+	assert.False(t, ok, "Missing key should not be found")
+}
+
+func TestDecodeModelData(t *testing.T) {
+	type widget struct {
+		Name  string   `json:"name"`
+		Count int      `json:"count"`
+		Tags  []string `json:"tags"`
+	}
+
+	req := decodedRequest(t, map[string]interface{}{"name": "widget", "count": 3, "tags": []string{"a"}})
+	var w widget
+	require.NoError(t, req.DecodeModelData(&w), "Model data should decode")
+	assert.Equal(t, widget{Name: "widget", Count: 3, Tags: []string{"a"}}, w, "Struct should be filled in")
+
+	req.ModelData["count"] = "three"
+	assert.Error(t, req.DecodeModelData(&w), "Wrong type should fail to decode")
+}
+
+func TestGetParameter(t *testing.T) {
+	req := NewModelRequest()
+	req.Parameters = []Parameter{
+		{Name: "limit", Value: float64(10), Type: "int"},
+		{Name: "scale", Value: 1.5, Type: "float"},
+		{Name: "label", Value: "x", Type: "string"},
+		{Name: "strict", Value: true, Type: "bool"},
+	}
+
+	tests := []struct {
+		name     string
+		asInt    interface{}
+		asFloat  interface{}
+		asString interface{}
+		asBool   interface{}
+	}{
+		{name: "limit", asInt: 10, asFloat: 10.0},
+		{name: "scale", asFloat: 1.5},
+		{name: "label", asString: "x"},
+		{name: "strict", asBool: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := req.GetParameter(tt.name)
+			require.True(t, ok, "Parameter should be found")
+
+			i, ok := p.AsInt()
+			assert.Equal(t, tt.asInt != nil, ok, "AsInt should report whether the value is a whole number")
+			if ok {
+				assert.Equal(t, tt.asInt, i, "AsInt should return the value")
+			}
+			f, ok := p.AsFloat()
+			assert.Equal(t, tt.asFloat != nil, ok, "AsFloat should report whether the value is a number")
+			if ok {
+				assert.Equal(t, tt.asFloat, f, "AsFloat should return the value")
+			}
+			s, ok := p.AsString()
+			assert.Equal(t, tt.asString != nil, ok, "AsString should report whether the value is a string")
+			if ok {
+				assert.Equal(t, tt.asString, s, "AsString should return the value")
+			}
+			b, ok := p.AsBool()
+			assert.Equal(t, tt.asBool != nil, ok, "AsBool should report whether the value is a bool")
+			if ok {
+				assert.Equal(t, tt.asBool, b, "AsBool should return the value")
+			}
+		})
+	}
+
+	_, ok := req.GetParameter("missing")
+	assert.False(t, ok, "Missing parameter should not be found")
+
+	// The parameter returned belongs to the request
+	p, _ := req.GetParameter("limit")
+	p.Value = 20
+	limit, _ := req.Parameters[0].AsInt()
+	assert.Equal(t, 20, limit, "Changes to the parameter should be kept")
+}
+
+func TestModelResponseResults(t *testing.T) {
+	resp := &ModelResponse{}
+	resp.SetResult("words", 9)
+	resp.SetResult("language", "en")
+	resp.SetResult("complete", true)
+	resp.SetResult("score", 0.75)
+	resp.SetResult("tokens", []string{"the", "fox"})
+
+	// Results read back the same before and after a round trip through JSON
+	raw, err := json.Marshal(resp)
+	require.NoError(t, err, "Response should encode")
+	var decoded ModelResponse
+	require.NoError(t, json.Unmarshal(raw, &decoded), "Response should decode")
+
+	for name, r := range map[string]*ModelResponse{"local": resp, "decoded": &decoded} {
+		t.Run(name, func(t *testing.T) {
+			words, ok := r.GetInt("words")
+			assert.True(t, ok && words == 9, "Int result should read back")
+			language, ok := r.GetString("language")
+			assert.True(t, ok && language == "en", "String result should read back")
+			complete, ok := r.GetBool("complete")
+			assert.True(t, ok && complete, "Bool result should read back")
+			score, ok := r.GetFloat("score")
+			assert.True(t, ok && score == 0.75, "Float result should read back")
+			tokens, ok := r.GetStringSlice("tokens")
+			assert.True(t, ok, "String list result should read back")
+			assert.Equal(t, []string{"the", "fox"}, tokens, "String list should be returned")
+			_, ok = r.GetInt("missing")
+			assert.False(t, ok, "Missing result should not be found")
+			_, ok = r.GetInt("language")
+			assert.False(t, ok, "String result should not read as an int")
+
+			var out struct {
+				Words int     `json:"words"`
+				Score float64 `json:"score"`
+			}
+			require.NoError(t, r.DecodeResults(&out), "Results should decode")
+			assert.Equal(t, 9, out.Words, "Decoded struct should carry the int")
+			assert.Equal(t, 0.75, out.Score, "Decoded struct should carry the float")
+		})
+	}
+}
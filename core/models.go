@@ -2,33 +2,228 @@
 package core
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
 	"time"
 )
 
 // ModelRequest represents a request to process a model.
-// It contains the request identifier, model data, and processing parameters.
+// It contains the request identifier, model data, processing parameters,
+// the blobs it refers to, and optional cross-cutting metadata.
+// A request is not safe for concurrent use; give each goroutine its own
+// Clone.
 type ModelRequest struct {
 	ID         string                 `json:"id"`
 	ModelData  map[string]interface{} `json:"modelData"`
-	Parameters []Parameter            `json:"parameters"`
+	Parameters Parameters             `json:"parameters"`
+	BlobRefs   []BlobID               `json:"blobRefs,omitempty"` // Blobs uploaded to the server for the handler to open
+	Metadata   map[string]string      `json:"metadata,omitempty"`
 }
 
 // ModelResponse represents the response from processing a model.
 // It includes the request identifier, success status, any error message,
-// processing results, and a timestamp.
+// code and details, processing results, a timestamp, and metadata echoed
+// from the request. Like a ModelRequest, it is not safe for concurrent use.
 type ModelResponse struct {
 	ID           string                 `json:"id"`
 	Success      bool                   `json:"success"`
 	ErrorMessage string                 `json:"errorMessage,omitempty"`
+	ErrorCode    ErrorCode              `json:"errorCode,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
 	Results      map[string]interface{} `json:"results"`
 	Timestamp    time.Time              `json:"timestamp"`
+	Metadata     map[string]string      `json:"metadata,omitempty"`
+}
+
+// Err returns an error carrying ErrorMessage if the response reports a
+// failure, or nil if it succeeded. A failure with an ErrorCode is returned as
+// a *ModelError.
+func (r *ModelResponse) Err() error {
+	if r.Success {
+		return nil
+	}
+	if r.ErrorCode != "" {
+		return &ModelError{Code: r.ErrorCode, Message: r.ErrorMessage, Details: r.Details}
+	}
+	if r.ErrorMessage == "" {
+		return errors.New("model processing failed")
+	}
+	return errors.New(r.ErrorMessage)
+}
+
+// RawModelRequest is a ModelRequest with its model data kept as encoded, for
+// payloads passed on without being decoded. Both encode alike.
+type RawModelRequest struct {
+	ID         string            `json:"id"`
+	ModelData  json.RawMessage   `json:"modelData"`
+	Parameters Parameters        `json:"parameters"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// Clone returns a deep copy of the response, as ModelRequest.Clone does.
+func (r *ModelResponse) Clone() *ModelResponse {
+	if r == nil {
+		return nil
+	}
+	out := *r
+	out.Details = cloneMap(r.Details)
+	out.Results = cloneMap(r.Results)
+	out.Metadata = maps.Clone(r.Metadata)
+	return &out
+}
+
+// Merge adds the partial results of other to r, for handlers that gather
+// one response from several. Results of other replace those of r under the
+// same key, and metadata r lacks is added. r fails if either fails, keeping
+// the first error message, code and details, and takes the later Timestamp.
+// The values merged are copied, so other may be reused. A nil other leaves r
+// as it is.
+func (r *ModelResponse) Merge(other *ModelResponse) {
+	if other == nil {
+		return
+	}
+	if len(other.Results) > 0 && r.Results == nil {
+		r.Results = make(map[string]interface{}, len(other.Results))
+	}
+	for key, value := range other.Results {
+		r.Results[key] = cloneValue(value)
+	}
+	if len(other.Metadata) > 0 && r.Metadata == nil {
+		r.Metadata = make(map[string]string, len(other.Metadata))
+	}
+	for key, value := range other.Metadata {
+		if _, ok := r.Metadata[key]; !ok {
+			r.Metadata[key] = value
+		}
+	}
+	if r.Success && !other.Success {
+		r.Success = false
+		r.ErrorMessage, r.ErrorCode, r.Details = other.ErrorMessage, other.ErrorCode, cloneMap(other.Details)
+	}
+	if other.Timestamp.After(r.Timestamp) {
+		r.Timestamp = other.Timestamp
+	}
+}
+
+// RawModelResponse is a ModelResponse with its results kept as encoded. Both
+// encode alike.
+type RawModelResponse struct {
+	ID           string                 `json:"id"`
+	Success      bool                   `json:"success"`
+	ErrorMessage string                 `json:"errorMessage,omitempty"`
+	ErrorCode    ErrorCode              `json:"errorCode,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	Results      json.RawMessage        `json:"results"`
+	Timestamp    time.Time              `json:"timestamp"`
+	Metadata     map[string]string      `json:"metadata,omitempty"`
+}
+
+// Err returns the error of the response, as ModelResponse.Err does.
+func (r *RawModelResponse) Err() error {
+	failure := ModelResponse{Success: r.Success, ErrorMessage: r.ErrorMessage, ErrorCode: r.ErrorCode, Details: r.Details}
+	return failure.Err()
 }
 
 // Parameter represents a named parameter with type information for model processing.
 type Parameter struct {
 	Name  string      `json:"name"`
 	Value interface{} `json:"value"`
-	Type  string      `json:"type"`
+	Type  ParamType   `json:"type"`
+}
+
+// Clone returns a copy of the parameter whose Value shares no maps or slices
+// with the original.
+func (p Parameter) Clone() Parameter {
+	p.Value = cloneValue(p.Value)
+	return p
+}
+
+// Clone returns a deep copy of the request, for handing to another
+// goroutine. Maps and slices are copied, including those nested in model data
+// and parameter values; other values held in interfaces, such as pointers,
+// are shared. Nil maps and slices stay nil. Clone of a nil request is nil.
+func (r *ModelRequest) Clone() *ModelRequest {
+	if r == nil {
+		return nil
+	}
+	out := *r
+	out.ModelData = cloneMap(r.ModelData)
+	if r.Parameters != nil {
+		out.Parameters = make([]Parameter, len(r.Parameters))
+		for i, param := range r.Parameters {
+			out.Parameters[i] = param.Clone()
+		}
+	}
+	out.BlobRefs = slices.Clone(r.BlobRefs)
+	out.Metadata = maps.Clone(r.Metadata)
+	return &out
+}
+
+// cloneMap returns a deep copy of m, as ModelRequest.Clone makes of model
+// data.
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		out[key] = cloneValue(value)
+	}
+	return out
+}
+
+// cloneValue returns a copy of v sharing no maps or slices with it.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		return cloneMap(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = cloneValue(item)
+		}
+		return out
+	}
+	return cloneReflect(reflect.ValueOf(v)).Interface()
+}
+
+// cloneReflect copies the maps and slices of v, at any depth, for values of
+// types other than those JSON decodes into.
+func cloneReflect(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(cloneReflect(v.Elem()))
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			out.SetMapIndex(iter.Key(), cloneReflect(iter.Value()))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(cloneReflect(v.Index(i)))
+		}
+		return out
+	}
+	return v
 }
 
 // NewModelRequest creates a new request with a generated ID.
@@ -44,9 +239,10 @@ func NewModelRequest() *ModelRequest {
 // NewModelResponse creates a response for a given request.
 // The response contains the same ID as the request and is initialized
 // with a success status, empty results map, and current timestamp.
+// A nil req, such as a request that failed to decode, gives an empty ID.
 func NewModelResponse(req *ModelRequest) *ModelResponse {
 	return &ModelResponse{
-		ID:        req.ID,
+		ID:        requestID(req),
 		Success:   true,
 		Results:   make(map[string]interface{}),
 		Timestamp: time.Now(),
@@ -54,15 +250,41 @@ func NewModelResponse(req *ModelRequest) *ModelResponse {
 }
 
 // ErrorResponse creates an error response for a given request with the provided error.
-// The response is marked as unsuccessful and includes the error message.
+// The response is marked as unsuccessful and includes the error message, and
+// the code and details of a ModelError in err's chain. A nil req gives an
+// empty ID, as for NewModelResponse, and a nil err the message "request
+// failed".
 func ErrorResponse(req *ModelRequest, err error) *ModelResponse {
-	return &ModelResponse{
-		ID:           req.ID,
+	message := "request failed"
+	if err != nil {
+		message = err.Error()
+	}
+	resp := &ModelResponse{
+		ID:           requestID(req),
 		Success:      false,
-		ErrorMessage: err.Error(),
+		ErrorMessage: message,
 		Results:      make(map[string]interface{}),
 		Timestamp:    time.Now(),
 	}
+	if modelErr := asModelError(err); modelErr != nil {
+		resp.ErrorCode, resp.Details = modelErr.Code, modelErr.Details
+	}
+	return resp
+}
+
+// ErrorResponsef creates an error response for req, as ErrorResponse does,
+// with the error formatted by fmt.Errorf, so %w keeps the code and details of
+// a wrapped ModelError.
+func ErrorResponsef(req *ModelRequest, format string, args ...interface{}) *ModelResponse {
+	return ErrorResponse(req, fmt.Errorf(format, args...))
+}
+
+// requestID returns the ID of req, or an empty ID if req is nil.
+func requestID(req *ModelRequest) string {
+	if req == nil {
+		return ""
+	}
+	return req.ID
 }
 
 // generateID creates a new unique ID using a timestamp-based approach.
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -55,13 +57,179 @@ func TestErrorResponse(t *testing.T) {
 	assert.NotNil(t, resp.Results, "Results map should be initialized")
 }
 
+func TestResponsesForNilRequest(t *testing.T) {
+	resp := NewModelResponse(nil)
+	assert.Empty(t, resp.ID, "A response to no request should have an empty ID")
+	assert.True(t, resp.Success, "Response should be marked as successful by default")
+
+	resp = ErrorResponse(nil, errors.New("malformed"))
+	assert.Empty(t, resp.ID, "A response to no request should have an empty ID")
+	assert.False(t, resp.Success, "Response should be marked as unsuccessful")
+	assert.Equal(t, "malformed", resp.ErrorMessage, "Error message should be set correctly")
+
+	resp = ErrorResponseWithCode(nil, ErrInvalidModel, errors.New("malformed"))
+	assert.Equal(t, ErrInvalidModel, resp.ErrorCode, "Code should be set without a request")
+}
+
+func TestErrorResponseWithoutError(t *testing.T) {
+	req := NewModelRequest()
+	resp := ErrorResponse(req, nil)
+
+	assert.Equal(t, req.ID, resp.ID, "Response ID should match Request ID")
+	assert.False(t, resp.Success, "Response should be marked as unsuccessful")
+	assert.Equal(t, "request failed", resp.ErrorMessage, "A nil error should get a generic message")
+	assert.Empty(t, resp.ErrorCode, "A nil error should have no code")
+	assert.Error(t, resp.Err(), "The response should still fail")
+}
+
+func TestErrorResponsef(t *testing.T) {
+	req := NewModelRequest()
+	resp := ErrorResponsef(req, "parameter %q is missing", "temperature")
+
+	assert.Equal(t, req.ID, resp.ID, "Response ID should match Request ID")
+	assert.False(t, resp.Success, "Response should be marked as unsuccessful")
+	assert.Equal(t, `parameter "temperature" is missing`, resp.ErrorMessage, "Error message should be formatted")
+
+	modelErr := &ModelError{Code: ErrNotFound, Message: "no such model", Details: map[string]interface{}{"model": "m"}}
+	resp = ErrorResponsef(nil, "loading %s: %w", "m", modelErr)
+	assert.Empty(t, resp.ID, "A response to no request should have an empty ID")
+	assert.Equal(t, "loading m: no such model", resp.ErrorMessage, "Error message should be formatted")
+	assert.Equal(t, ErrNotFound, resp.ErrorCode, "A wrapped ModelError's code should be kept")
+	assert.Equal(t, modelErr.Details, resp.Details, "A wrapped ModelError's details should be kept")
+}
+
+func TestModelResponseErr(t *testing.T) {
+	req := NewModelRequest()
+
+	assert.NoError(t, NewModelResponse(req).Err(), "Successful response should have no error")
+	assert.EqualError(t, ErrorResponse(req, fmt.Errorf("bad input")).Err(), "bad input", "Error should carry the message")
+	assert.Error(t, (&ModelResponse{}).Err(), "Failed response without a message should still be an error")
+}
+
+func TestModelRequestClone(t *testing.T) {
+	req := NewModelRequest()
+	req.ModelData["input"] = map[string]interface{}{"tokens": []interface{}{"a", "b"}}
+	req.ModelData["labels"] = map[string][]string{"lang": {"en"}}
+	req.Parameters = []Parameter{{Name: "stop", Value: []interface{}{"."}, Type: "array"}}
+	req.BlobRefs = []BlobID{"blob-1"}
+	req.Metadata = map[string]string{"tenant": "acme"}
+
+	clone := req.Clone()
+	assert.Equal(t, req, clone, "The clone should equal the original")
+
+	clone.ModelData["input"].(map[string]interface{})["tokens"].([]interface{})[0] = "z"
+	clone.ModelData["labels"].(map[string][]string)["lang"][0] = "fr"
+	clone.ModelData["extra"] = true
+	clone.Parameters[0].Value.([]interface{})[0] = "!"
+	clone.BlobRefs[0] = "blob-2"
+	clone.Metadata["tenant"] = "other"
+
+	assert.Equal(t, []interface{}{"a", "b"}, req.ModelData["input"].(map[string]interface{})["tokens"], "Nested slices should not be shared")
+	assert.Equal(t, []string{"en"}, req.ModelData["labels"].(map[string][]string)["lang"], "Typed nested maps and slices should not be shared")
+	assert.NotContains(t, req.ModelData, "extra", "Model data should not be shared")
+	assert.Equal(t, []interface{}{"."}, req.Parameters[0].Value, "Parameter values should not be shared")
+	assert.Equal(t, BlobID("blob-1"), req.BlobRefs[0], "Blob references should not be shared")
+	assert.Equal(t, "acme", req.Metadata["tenant"], "Metadata should not be shared")
+
+	assert.Nil(t, (&ModelRequest{ID: "empty"}).Clone().Metadata, "Nil maps should stay nil")
+	assert.Nil(t, (*ModelRequest)(nil).Clone(), "The clone of nil should be nil")
+}
+
+func TestModelRequestCloneFanOut(t *testing.T) {
+	req := NewModelRequest()
+	req.ModelData["input"] = map[string]interface{}{"count": 0, "seen": []interface{}{}}
+
+	// Run with -race: goroutines writing to their own clones must not touch
+	// what the others, or the original, read
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		clone := req.Clone()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := clone.ModelData["input"].(map[string]interface{})
+			for j := 0; j < 100; j++ {
+				input["count"] = j
+				input["seen"] = append(input["seen"].([]interface{}), i)
+				clone.ModelData[fmt.Sprintf("worker-%d", i)] = j
+			}
+		}(i)
+	}
+	for j := 0; j < 100; j++ {
+		_ = req.ModelData["input"].(map[string]interface{})["count"]
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]interface{}{"count": 0, "seen": []interface{}{}}, req.ModelData["input"], "Writes to clones should not reach the original")
+	assert.Len(t, req.ModelData, 1, "Keys added to clones should not reach the original")
+}
+
+func TestModelResponseClone(t *testing.T) {
+	resp := NewModelResponse(NewModelRequest())
+	resp.Results["scores"] = []interface{}{0.5, 0.9}
+	resp.Details = map[string]interface{}{"field": "input"}
+	resp.Metadata = map[string]string{"trace": "t1"}
+
+	clone := resp.Clone()
+	assert.Equal(t, resp, clone, "The clone should equal the original")
+
+	clone.Results["scores"].([]interface{})[0] = 0.0
+	clone.Details["field"] = "other"
+	clone.Metadata["trace"] = "t2"
+	assert.Equal(t, []interface{}{0.5, 0.9}, resp.Results["scores"], "Results should not be shared")
+	assert.Equal(t, "input", resp.Details["field"], "Details should not be shared")
+	assert.Equal(t, "t1", resp.Metadata["trace"], "Metadata should not be shared")
+	assert.Nil(t, (*ModelResponse)(nil).Clone(), "The clone of nil should be nil")
+}
+
+func TestParameterClone(t *testing.T) {
+	param := Parameter{Name: "weights", Value: map[string]interface{}{"a": []interface{}{1.0}}, Type: "object"}
+	clone := param.Clone()
+	assert.Equal(t, param, clone, "The clone should equal the original")
+
+	clone.Value.(map[string]interface{})["a"].([]interface{})[0] = 2.0
+	assert.Equal(t, []interface{}{1.0}, param.Value.(map[string]interface{})["a"], "The value should not be shared")
+
+	scalar := Parameter{Name: "n", Value: 3, Type: "int"}
+	assert.Equal(t, scalar, scalar.Clone(), "Scalar values should be copied as they are")
+}
+
+func TestModelResponseMerge(t *testing.T) {
+	start := time.Now()
+	resp := &ModelResponse{ID: "req-1", Success: true, Results: map[string]interface{}{"a": 1, "shared": "first"}, Timestamp: start, Metadata: map[string]string{"trace": "t1"}}
+	part := &ModelResponse{ID: "part", Success: true, Results: map[string]interface{}{"b": []interface{}{2}, "shared": "second"}, Timestamp: start.Add(time.Second), Metadata: map[string]string{"trace": "t2", "shard": "2"}}
+
+	resp.Merge(part)
+	assert.Equal(t, "req-1", resp.ID, "The ID should be kept")
+	assert.True(t, resp.Success, "Merging successes should succeed")
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": []interface{}{2}, "shared": "second"}, resp.Results, "Results should be combined, the merged ones winning")
+	assert.Equal(t, map[string]string{"trace": "t1", "shard": "2"}, resp.Metadata, "Metadata should be added without replacing")
+	assert.Equal(t, part.Timestamp, resp.Timestamp, "The later timestamp should be kept")
+
+	part.Results["b"].([]interface{})[0] = 3
+	assert.Equal(t, []interface{}{2}, resp.Results["b"], "Merged values should be copied")
+
+	first := ErrorResponseWithCode(nil, ErrTimeout, errors.New("shard 3 timed out"))
+	resp.Merge(first)
+	resp.Merge(ErrorResponseWithCode(nil, ErrInternal, errors.New("shard 4 failed")))
+	assert.False(t, resp.Success, "Merging a failure should fail")
+	assert.Equal(t, "shard 3 timed out", resp.ErrorMessage, "The first failure should be kept")
+	assert.Equal(t, ErrTimeout, resp.ErrorCode, "The first failure's code should be kept")
+
+	empty := &ModelResponse{Success: true}
+	empty.Merge(part)
+	assert.Equal(t, "second", empty.Results["shared"], "Merging into a response without results should create them")
+	empty.Merge(nil)
+	assert.True(t, empty.Success, "Merging nil should change nothing")
+}
+
 func TestParameter(t *testing.T) {
 	// Test parameter creation and value handling
 	testCases := []struct {
 		name  string
 		param Parameter
 		value interface{}
-		typ   string
+		typ   ParamType
 	}{
 		{
 			name: "string parameter",
@@ -0,0 +1,190 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// notificationTypes maps each registered notification method to its payload type.
+var (
+	notificationMu    sync.Mutex
+	notificationTypes = make(map[string]reflect.Type)
+)
+
+// Notification describes a JSON-RPC notification with a payload of type T.
+// Both ends of a connection use the same descriptor, so the method name and
+// payload shape cannot drift apart. Create descriptors with RegisterNotification.
+type Notification[T any] struct {
+	method string
+	strict bool
+}
+
+// RegisterNotification registers method as a notification carrying a T and
+// returns its descriptor. Registering a method again with the same payload
+// type returns an equivalent descriptor; registering it with another type
+// panics, as two meanings for one notification are a programming error.
+func RegisterNotification[T any](method string) Notification[T] {
+	payloadType := reflect.TypeOf((*T)(nil)).Elem()
+
+	notificationMu.Lock()
+	defer notificationMu.Unlock()
+	if registered, ok := notificationTypes[method]; ok && registered != payloadType {
+		panic(fmt.Sprintf("notification %s already registered with payload %s", method, registered))
+	}
+	notificationTypes[method] = payloadType
+	return Notification[T]{method: method}
+}
+
+// NotificationRegistered reports whether method has been registered with
+// RegisterNotification.
+func NotificationRegistered(method string) bool {
+	notificationMu.Lock()
+	defer notificationMu.Unlock()
+	_, ok := notificationTypes[method]
+	return ok
+}
+
+// Method returns the JSON-RPC method of the notification.
+func (n Notification[T]) Method() string {
+	return n.method
+}
+
+// Strict returns a descriptor for the same notification that rejects payloads
+// with fields T does not have. By default unknown fields are ignored, so
+// receivers tolerate newer peers adding fields.
+func (n Notification[T]) Strict() Notification[T] {
+	n.strict = true
+	return n
+}
+
+// Check returns an error unless the descriptor came from RegisterNotification.
+func (n Notification[T]) Check() error {
+	if n.method == "" || !NotificationRegistered(n.method) {
+		return fmt.Errorf("notification %q is not registered", n.method)
+	}
+	return nil
+}
+
+// Decode decodes a payload received for the notification. Missing or null
+// params decode to the zero T.
+func (n Notification[T]) Decode(params *json.RawMessage) (T, error) {
+	var payload T
+	if params == nil || bytes.Equal(bytes.TrimSpace(*params), []byte("null")) {
+		return payload, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(*params))
+	if n.strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&payload); err != nil {
+		return payload, err
+	}
+	return payload, nil
+}
+
+// NotificationError reports a notification that was received but could not
+// be decoded into its registered payload type.
+type NotificationError struct {
+	Method string          // Method of the notification
+	Params json.RawMessage // Payload as received
+	Err    error           // Decoding failure
+}
+
+func (e *NotificationError) Error() string {
+	return fmt.Sprintf("failed to decode %s notification: %v", e.Method, e.Err)
+}
+
+func (e *NotificationError) Unwrap() error {
+	return e.Err
+}
+
+// NotificationRouter dispatches received notifications to the handlers
+// registered for their method, handing unknown methods to fallback handlers
+// and decoding failures to error callbacks. Clients and servers each have one.
+// Handlers run on their own goroutines so they may call back into the peer.
+type NotificationRouter struct {
+	mu       sync.Mutex
+	tasks    *TaskTracker
+	typed    map[string][]func(ctx context.Context, params *json.RawMessage) error
+	fallback []func(ctx context.Context, method string, params json.RawMessage)
+	onError  []func(*NotificationError)
+}
+
+// NewNotificationRouter creates a router running handlers under tasks.
+func NewNotificationRouter(tasks *TaskTracker) *NotificationRouter {
+	return &NotificationRouter{
+		tasks: tasks,
+		typed: make(map[string][]func(context.Context, *json.RawMessage) error),
+	}
+}
+
+// HandleNotification registers handler for notifications described by n.
+// Payloads that do not decode are reported to the router's error callbacks.
+func HandleNotification[T any](r *NotificationRouter, n Notification[T], handler func(ctx context.Context, payload T)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.typed[n.method] = append(r.typed[n.method], func(ctx context.Context, params *json.RawMessage) error {
+		payload, err := n.Decode(params)
+		if err != nil {
+			return err
+		}
+		r.tasks.Go(TaskEvents, func() { handler(ctx, payload) })
+		return nil
+	})
+}
+
+// OnUnknown registers a handler for notifications no typed handler is
+// registered for, receiving the raw payload.
+func (r *NotificationRouter) OnUnknown(handler func(ctx context.Context, method string, params json.RawMessage)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = append(r.fallback, handler)
+}
+
+// OnError registers a callback for notifications that failed to decode.
+func (r *NotificationRouter) OnError(callback func(*NotificationError)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = append(r.onError, callback)
+}
+
+// Dispatch delivers a received notification. It returns the decoding error
+// reported to the error callbacks, if any.
+func (r *NotificationRouter) Dispatch(ctx context.Context, method string, params *json.RawMessage) error {
+	r.mu.Lock()
+	typed := r.typed[method]
+	fallback := r.fallback
+	onError := r.onError
+	r.mu.Unlock()
+
+	var raw json.RawMessage
+	if params != nil {
+		raw = append(raw, *params...)
+	}
+
+	if len(typed) == 0 {
+		for _, handler := range fallback {
+			handler := handler
+			r.tasks.Go(TaskEvents, func() { handler(ctx, method, raw) })
+		}
+		return nil
+	}
+
+	for _, handler := range typed {
+		if err := handler(ctx, params); err != nil {
+			notifErr := &NotificationError{Method: method, Params: raw, Err: err}
+			for _, callback := range onError {
+				callback := callback
+				r.tasks.Go(TaskEvents, func() { callback(notifErr) })
+			}
+			return notifErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testProgress struct {
+	Percent int `json:"percent"`
+}
+
+func TestRegisterNotification(t *testing.T) {
+	n := RegisterNotification[testProgress]("core.test.progress")
+	assert.Equal(t, "core.test.progress", n.Method(), "Descriptor should carry the method")
+	assert.NoError(t, n.Check(), "Registered descriptor should pass the check")
+	assert.True(t, NotificationRegistered("core.test.progress"), "Method should be registered")
+
+	// Registering again with the same type is harmless, with another it panics
+	assert.Equal(t, n, RegisterNotification[testProgress]("core.test.progress"), "Re-registration should return the same descriptor")
+	assert.Panics(t, func() { RegisterNotification[string]("core.test.progress") }, "Conflicting registration should panic")
+
+	assert.Error(t, Notification[testProgress]{}.Check(), "Zero descriptor should fail the check")
+	assert.True(t, NotificationRegistered(NotifyMethodsChanged), "Built-in notifications should be registered")
+}
+
+func TestNotificationDecode(t *testing.T) {
+	n := RegisterNotification[testProgress]("core.test.decode")
+	raw := func(s string) *json.RawMessage {
+		msg := json.RawMessage(s)
+		return &msg
+	}
+
+	payload, err := n.Decode(raw(`{"percent": 40, "eta": "soon"}`))
+	require.NoError(t, err, "Unknown fields should be tolerated")
+	assert.Equal(t, testProgress{Percent: 40}, payload, "Known fields should decode")
+
+	_, err = n.Strict().Decode(raw(`{"percent": 40, "eta": "soon"}`))
+	assert.Error(t, err, "Strict descriptor should reject unknown fields")
+
+	_, err = n.Decode(raw(`{"percent": "half"}`))
+	assert.Error(t, err, "Mismatched types should fail")
+
+	payload, err = n.Decode(nil)
+	assert.NoError(t, err, "Missing params should decode")
+	assert.Zero(t, payload, "Missing params should decode to the zero payload")
+}
+
+func TestNotificationRouter(t *testing.T) {
+	n := RegisterNotification[testProgress]("core.test.router")
+	router := NewNotificationRouter(NewTaskTracker(NopLogger(), nil))
+
+	received := make(chan testProgress, 1)
+	HandleNotification(router, n, func(ctx context.Context, payload testProgress) { received <- payload })
+	unknown := make(chan string, 1)
+	router.OnUnknown(func(ctx context.Context, method string, params json.RawMessage) {
+		unknown <- method + " " + string(params)
+	})
+	failures := make(chan *NotificationError, 1)
+	router.OnError(func(err *NotificationError) { failures <- err })
+
+	good := json.RawMessage(`{"percent": 10}`)
+	require.NoError(t, router.Dispatch(context.Background(), n.Method(), &good), "Valid payload should dispatch")
+	select {
+	case payload := <-received:
+		assert.Equal(t, testProgress{Percent: 10}, payload, "Typed handler should get the decoded payload")
+	case <-time.After(time.Second):
+		t.Fatal("Typed handler was not called")
+	}
+
+	other := json.RawMessage(`[1]`)
+	require.NoError(t, router.Dispatch(context.Background(), "core.test.other", &other), "Unknown method should dispatch")
+	select {
+	case got := <-unknown:
+		assert.Equal(t, "core.test.other [1]", got, "Fallback should get the raw payload")
+	case <-time.After(time.Second):
+		t.Fatal("Fallback handler was not called")
+	}
+
+	bad := json.RawMessage(`{"percent": "half"}`)
+	err := router.Dispatch(context.Background(), n.Method(), &bad)
+	var notifErr *NotificationError
+	require.ErrorAs(t, err, &notifErr, "Decode failure should be returned")
+	select {
+	case reported := <-failures:
+		assert.Equal(t, n.Method(), reported.Method, "Error should name the method")
+		assert.JSONEq(t, string(bad), string(reported.Params), "Error should carry the payload")
+	case <-time.After(time.Second):
+		t.Fatal("Error callback was not called")
+	}
+}
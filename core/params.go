@@ -0,0 +1,266 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/narcolepticfox/mcp/core/tools"
+)
+
+// ParamType is the declared type of a Parameter's value. Parameters.Coerce
+// converts values to their declared type, which JSON decoding loses: a
+// decoded number is a float64 whatever it was sent as. Types other than the
+// defined ones are kept as given and not checked.
+type ParamType string
+
+// Parameter types.
+const (
+	ParamString ParamType = "string" // A string
+	ParamInt    ParamType = "int"    // A whole number, coerced to int64
+	ParamFloat  ParamType = "float"  // A number, coerced to float64
+	ParamBool   ParamType = "bool"   // A boolean
+	ParamJSON   ParamType = "json"   // Any JSON value, left as decoded
+	ParamBytes  ParamType = "bytes"  // Binary data, sent as base64 and coerced to []byte
+)
+
+// paramTypeAliases maps other names in use for the parameter types, in lower
+// case, to the types.
+var paramTypeAliases = map[string]ParamType{
+	"integer": ParamInt,
+	"number":  ParamFloat,
+	"boolean": ParamBool,
+}
+
+// ParseParamType returns the parameter type with the given name, ignoring
+// case. The JSON Schema names "integer", "number" and "boolean" name the
+// types they stand for.
+func ParseParamType(name string) (ParamType, error) {
+	lower := strings.ToLower(name)
+	switch t := ParamType(lower); t {
+	case ParamString, ParamInt, ParamFloat, ParamBool, ParamJSON, ParamBytes:
+		return t, nil
+	}
+	if t, ok := paramTypeAliases[lower]; ok {
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown parameter type %q", name)
+}
+
+// canonical returns the defined type t names, or t itself if it names none.
+func (t ParamType) canonical() ParamType {
+	if parsed, err := ParseParamType(string(t)); err == nil {
+		return parsed
+	}
+	return t
+}
+
+// MarshalJSON writes the type by the name of the defined type it names, so
+// "integer" is sent as "int". Other types are written as they are.
+func (t ParamType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(t.canonical()))
+}
+
+// UnmarshalJSON reads a type name, replacing aliases of the defined types
+// with their names. Other names are kept as they are.
+func (t *ParamType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("parameter type must be a string: %w", err)
+	}
+	*t = ParamType(name).canonical()
+	return nil
+}
+
+// StringParam returns a ParamString parameter.
+func StringParam(name, value string) Parameter {
+	return Parameter{Name: name, Value: value, Type: ParamString}
+}
+
+// IntParam returns a ParamInt parameter.
+func IntParam(name string, value int64) Parameter {
+	return Parameter{Name: name, Value: value, Type: ParamInt}
+}
+
+// FloatParam returns a ParamFloat parameter.
+func FloatParam(name string, value float64) Parameter {
+	return Parameter{Name: name, Value: value, Type: ParamFloat}
+}
+
+// BoolParam returns a ParamBool parameter.
+func BoolParam(name string, value bool) Parameter {
+	return Parameter{Name: name, Value: value, Type: ParamBool}
+}
+
+// JSONParam returns a ParamJSON parameter holding value, which must encode
+// as JSON.
+func JSONParam(name string, value interface{}) Parameter {
+	return Parameter{Name: name, Value: value, Type: ParamJSON}
+}
+
+// BytesParam returns a ParamBytes parameter.
+func BytesParam(name string, value []byte) Parameter {
+	return Parameter{Name: name, Value: value, Type: ParamBytes}
+}
+
+// Parameters are the parameters of a model request.
+type Parameters []Parameter
+
+// Get returns the first parameter with the given name.
+func (p Parameters) Get(name string) (Parameter, bool) {
+	for _, param := range p {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return Parameter{}, false
+}
+
+// MustString returns the value of the named parameter, which must be a
+// string. It panics if there is no such parameter or its value is not a
+// string.
+func (p Parameters) MustString(name string) string {
+	param, ok := p.Get(name)
+	if !ok {
+		panic(fmt.Sprintf("parameter %q is missing", name))
+	}
+	value, ok := param.Value.(string)
+	if !ok {
+		panic(fmt.Sprintf("parameter %q is a %T, not a string", name, param.Value))
+	}
+	return value
+}
+
+// Coerce converts, in place, the value of each parameter of a defined type
+// to that type's Go form: int64 for ParamInt, float64 for ParamFloat and
+// []byte for ParamBytes, decoding base64. JSON-decoded numbers are
+// converted, so an int sent as 3 and decoded as 3.0 is an int64 again.
+// Values that do not fit their type, such as a string declared ParamInt or
+// 2.5 declared ParamInt, are left alone and reported in the result, one
+// error for each. Parameters of other types are not checked.
+func (p Parameters) Coerce() *tools.ValidationResult {
+	result := tools.NewValidationResult()
+	for i, param := range p {
+		typ := param.Type.canonical()
+		value, err := coerceParam(typ, param.Value)
+		if err != nil {
+			result.AddError("parameters."+param.Name, err.Error())
+			continue
+		}
+		p[i].Value = value
+	}
+	return result
+}
+
+// coerceParam returns value converted to typ.
+func coerceParam(typ ParamType, value interface{}) (interface{}, error) {
+	switch typ {
+	case ParamString:
+		if _, ok := value.(string); ok {
+			return value, nil
+		}
+	case ParamInt:
+		if n, ok := toInt64(value); ok {
+			return n, nil
+		}
+	case ParamFloat:
+		if f, ok := toFloat64(value); ok {
+			return f, nil
+		}
+	case ParamBool:
+		if _, ok := value.(bool); ok {
+			return value, nil
+		}
+	case ParamBytes:
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("must be base64 encoded bytes: %v", err)
+			}
+			return b, nil
+		}
+	default:
+		// ParamJSON takes any value, and other types are not checked
+		return value, nil
+	}
+	return nil, fmt.Errorf("must be of type %s, got %s", typ, describeValue(value))
+}
+
+// toInt64 returns value as an int64 if it is a whole number in range.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case float32:
+		return toInt64(float64(v))
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toFloat64 returns value as a float64 if it is a number.
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	if n, ok := toInt64(value); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// describeValue names the JSON type of value for error messages.
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return "boolean"
+	case float64, float32, json.Number:
+		return fmt.Sprintf("number %v", v)
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
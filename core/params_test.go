@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseParamType(t *testing.T) {
+	for name, want := range map[string]ParamType{
+		"string":  ParamString,
+		"int":     ParamInt,
+		"float":   ParamFloat,
+		"bool":    ParamBool,
+		"json":    ParamJSON,
+		"bytes":   ParamBytes,
+		"INT":     ParamInt,
+		"integer": ParamInt,
+		"number":  ParamFloat,
+		"Boolean": ParamBool,
+	} {
+		got, err := ParseParamType(name)
+		require.NoError(t, err, "%q should parse", name)
+		assert.Equal(t, want, got, "%q should name %s", name, want)
+	}
+	_, err := ParseParamType("tensor")
+	assert.Error(t, err, "An unknown type should not parse")
+}
+
+func TestParamTypeJSON(t *testing.T) {
+	encoded, err := json.Marshal(Parameter{Name: "n", Value: 1, Type: "integer"})
+	require.NoError(t, err, "Encoding should succeed")
+	assert.JSONEq(t, `{"name":"n","value":1,"type":"int"}`, string(encoded), "Aliases should be written by the type's name")
+
+	var param Parameter
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"flag","value":true,"type":"boolean"}`), &param), "Decoding should succeed")
+	assert.Equal(t, ParamBool, param.Type, "Aliases should be read as the type they name")
+
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"shape","value":[1,2],"type":"tensor"}`), &param), "Decoding should succeed")
+	assert.Equal(t, ParamType("tensor"), param.Type, "Other types should be kept as sent")
+
+	assert.Error(t, json.Unmarshal([]byte(`{"name":"n","value":1,"type":3}`), &param), "A type that is not a string should not decode")
+}
+
+func TestParamConstructors(t *testing.T) {
+	assert.Equal(t, Parameter{Name: "s", Value: "text", Type: ParamString}, StringParam("s", "text"), "StringParam should build a string parameter")
+	assert.Equal(t, Parameter{Name: "i", Value: int64(7), Type: ParamInt}, IntParam("i", 7), "IntParam should build an int parameter")
+	assert.Equal(t, Parameter{Name: "f", Value: 0.5, Type: ParamFloat}, FloatParam("f", 0.5), "FloatParam should build a float parameter")
+	assert.Equal(t, Parameter{Name: "b", Value: true, Type: ParamBool}, BoolParam("b", true), "BoolParam should build a bool parameter")
+	assert.Equal(t, Parameter{Name: "j", Value: []int{1}, Type: ParamJSON}, JSONParam("j", []int{1}), "JSONParam should build a JSON parameter")
+	assert.Equal(t, Parameter{Name: "x", Value: []byte{1, 2}, Type: ParamBytes}, BytesParam("x", []byte{1, 2}), "BytesParam should build a bytes parameter")
+}
+
+func TestParametersGet(t *testing.T) {
+	params := Parameters{StringParam("mode", "fast"), IntParam("limit", 3)}
+
+	param, ok := params.Get("limit")
+	assert.True(t, ok, "A present parameter should be found")
+	assert.Equal(t, int64(3), param.Value, "The parameter should be returned")
+	_, ok = params.Get("missing")
+	assert.False(t, ok, "A missing parameter should not be found")
+
+	assert.Equal(t, "fast", params.MustString("mode"), "MustString should return a string value")
+	assert.Panics(t, func() { params.MustString("limit") }, "MustString should panic for a value that is not a string")
+	assert.Panics(t, func() { params.MustString("missing") }, "MustString should panic for a missing parameter")
+}
+
+func TestParametersCoerceRoundTrip(t *testing.T) {
+	req := NewModelRequest()
+	req.Parameters = Parameters{
+		StringParam("mode", "fast"),
+		IntParam("max_tokens", 256),
+		FloatParam("temperature", 0.5),
+		FloatParam("scale", 2),
+		BoolParam("verbose", true),
+		JSONParam("layers", map[string]interface{}{"input": "image"}),
+		BytesParam("weights", []byte{0, 1, 254, 255}),
+	}
+
+	// The request as a server decodes it: numbers are float64s and bytes
+	// are base64 strings
+	encoded, err := json.Marshal(req)
+	require.NoError(t, err, "Encoding should succeed")
+	var decoded ModelRequest
+	require.NoError(t, json.Unmarshal(encoded, &decoded), "Decoding should succeed")
+	maxTokens, _ := decoded.Parameters.Get("max_tokens")
+	assert.Equal(t, 256.0, maxTokens.Value, "An int should decode as a float64")
+
+	result := decoded.Parameters.Coerce()
+	require.True(t, result.Valid, "Coercion should succeed: %v", result.Error())
+	for _, want := range req.Parameters {
+		got, ok := decoded.Parameters.Get(want.Name)
+		require.True(t, ok, "%s should be decoded", want.Name)
+		assert.Equal(t, want.Value, got.Value, "%s should be coerced back to its declared type", want.Name)
+	}
+	assert.Equal(t, 2.0, decoded.Parameters[3].Value, "A whole float should stay a float64")
+}
+
+func TestParametersCoerceMismatches(t *testing.T) {
+	params := Parameters{
+		{Name: "count", Value: "12", Type: ParamInt},
+		{Name: "ratio", Value: 2.5, Type: ParamInt},
+		{Name: "name", Value: 3.0, Type: ParamString},
+		{Name: "enabled", Value: "yes", Type: ParamBool},
+		{Name: "weight", Value: true, Type: ParamFloat},
+		{Name: "blob", Value: "not base64!", Type: ParamBytes},
+		{Name: "missing", Value: nil, Type: ParamInt},
+		{Name: "shape", Value: "anything", Type: "tensor"},
+		{Name: "untyped", Value: 1.0},
+		{Name: "limit", Value: 5.0, Type: "integer"},
+	}
+
+	result := params.Coerce()
+	require.False(t, result.Valid, "Values that do not fit their type should fail coercion")
+	fields := make(map[string]string)
+	for _, e := range result.Errors {
+		fields[e.Field] = e.Message
+	}
+	assert.Equal(t, `must be of type int, got string "12"`, fields["parameters.count"], "A declared int with a string value should be reported")
+	for _, name := range []string{"ratio", "name", "enabled", "weight", "blob", "missing"} {
+		assert.Contains(t, fields, "parameters."+name, "%s should be reported", name)
+	}
+	assert.Len(t, result.Errors, 7, "Only the values that do not fit should be reported")
+
+	assert.Equal(t, "12", params[0].Value, "A value that does not fit should be left alone")
+	assert.Equal(t, "anything", params[7].Value, "Values of other types should be left alone")
+	assert.Equal(t, 1.0, params[8].Value, "Untyped values should be left alone")
+	assert.Equal(t, int64(5), params[9].Value, "Aliases should be coerced as the type they name")
+}
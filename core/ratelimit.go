@@ -0,0 +1,25 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+// CodeRateLimited is the JSON-RPC error code returned when a connection
+// exceeds the server's request rate. The error data is a RateLimitData.
+const CodeRateLimited int64 = -32004
+
+// CodeServerBusy is the JSON-RPC error code returned when a server is already
+// handling as many requests as it allows and its queue is full, or as many as
+// it allows the caller's principal. In the latter case the error data is a
+// PrincipalBusyData.
+const CodeServerBusy int64 = -32005
+
+// RateLimitData is the error data of a CodeRateLimited error.
+type RateLimitData struct {
+	RetryAfterMillis int64 `json:"retryAfterMs"` // Time until the server will accept the method again
+}
+
+// PrincipalBusyData is the error data of a CodeServerBusy error refusing a
+// request because its principal is at its concurrency limit.
+type PrincipalBusyData struct {
+	Principal string `json:"principal"` // ID of the principal the request was made as
+	InFlight  int    `json:"inFlight"`  // Requests the principal had in flight, borrowed ones included
+	Limit     int    `json:"limit"`     // Requests the principal may have in flight
+}
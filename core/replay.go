@@ -0,0 +1,106 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Metadata keys used to make handler runs reproducible.
+const (
+	// MetadataReplaySeed carries the seed for the handler's random source.
+	MetadataReplaySeed = "replay_seed"
+
+	// MetadataReplayTime carries the time the handler's clock is pinned to, in RFC 3339 format.
+	MetadataReplayTime = "replay_time"
+)
+
+// Clock supplies the current time to handlers.
+// Handlers that read time through a Clock produce reproducible output under replay.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// fixedClock always reports the same instant.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+type clockKey struct{}
+type randKey struct{}
+
+// ContextWithClock returns a copy of ctx carrying the given clock.
+func ContextWithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// ClockFromContext returns the clock stored in ctx, or the real clock if none is set.
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return realClock{}
+}
+
+// ContextWithRand returns a copy of ctx carrying the given random source.
+func ContextWithRand(ctx context.Context, r *rand.Rand) context.Context {
+	return context.WithValue(ctx, randKey{}, r)
+}
+
+// RandFromContext returns the random source stored in ctx, or a new
+// crypto-seeded source if none is set. The returned *rand.Rand is not safe
+// for concurrent use, so handlers that fan out must not share it.
+func RandFromContext(ctx context.Context) *rand.Rand {
+	if r, ok := ctx.Value(randKey{}).(*rand.Rand); ok {
+		return r
+	}
+	return rand.New(rand.NewSource(NewSeed()))
+}
+
+// ContextWithReplay returns a copy of ctx whose random source is seeded with
+// seed and whose clock is pinned to at.
+func ContextWithReplay(ctx context.Context, seed int64, at time.Time) context.Context {
+	ctx = ContextWithRand(ctx, rand.New(rand.NewSource(seed)))
+	return ContextWithClock(ctx, fixedClock(at))
+}
+
+// NewSeed returns a random seed drawn from crypto/rand.
+func NewSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// SetReplaySeed records the seed and time injected into a handler run in the
+// request's metadata, so the exchange can later be replayed exactly.
+func (r *ModelRequest) SetReplaySeed(seed int64, at time.Time) {
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]string)
+	}
+	r.Metadata[MetadataReplaySeed] = strconv.FormatInt(seed, 10)
+	r.Metadata[MetadataReplayTime] = at.UTC().Format(time.RFC3339Nano)
+}
+
+// ReplaySeed returns the seed and time recorded by SetReplaySeed.
+// The boolean is false if the request carries no valid replay metadata.
+func (r *ModelRequest) ReplaySeed() (int64, time.Time, bool) {
+	seed, err := strconv.ParseInt(r.Metadata[MetadataReplaySeed], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, r.Metadata[MetadataReplayTime])
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return seed, at, true
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaySeedRoundTrip(t *testing.T) {
+	req := NewModelRequest()
+
+	// A request without metadata has no replay information
+	_, _, ok := req.ReplaySeed()
+	assert.False(t, ok, "Request without metadata should not report a seed")
+
+	at := time.Date(2024, 5, 1, 12, 30, 0, 123, time.UTC)
+	req.SetReplaySeed(42, at)
+
+	seed, gotAt, ok := req.ReplaySeed()
+	assert.True(t, ok, "Seed should be recovered from metadata")
+	assert.Equal(t, int64(42), seed, "Seed should round-trip")
+	assert.True(t, at.Equal(gotAt), "Time should round-trip")
+}
+
+func TestContextAccessorDefaults(t *testing.T) {
+	ctx := context.Background()
+
+	// Without injected values, the real clock and an unseeded source are used
+	assert.WithinDuration(t, time.Now(), ClockFromContext(ctx).Now(), time.Second, "Default clock should be real")
+	assert.NotEqual(t, RandFromContext(ctx).Int63(), RandFromContext(ctx).Int63(), "Default sources should be independently seeded")
+}
+
+func TestContextWithReplay(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	a := ContextWithReplay(context.Background(), 7, at)
+	b := ContextWithReplay(context.Background(), 7, at)
+
+	assert.Equal(t, at, ClockFromContext(a).Now(), "Clock should be pinned")
+	assert.Equal(t, RandFromContext(a).Int63(), RandFromContext(b).Int63(), "Equal seeds should yield equal sequences")
+}
@@ -0,0 +1,68 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "errors"
+
+// Method names for resources, named content such as files, datasets or model
+// artifacts that a server exposes for clients to read. Resources are named
+// by URI.
+const (
+	// MethodListResources returns a ListResourcesResponse describing the
+	// resources a server offers.
+	MethodListResources = "mcp.listResources"
+
+	// MethodReadResource returns the ResourceContent of the resource named by
+	// a ReadResourceRequest.
+	MethodReadResource = "mcp.readResource"
+)
+
+// NotifyResourceChanged is the notification carrying a ResourceChanged,
+// published on the resource's ResourceTopic whenever it changes.
+const NotifyResourceChanged = "mcp.resourceChanged"
+
+// ResourceChanges describes the NotifyResourceChanged notification.
+var ResourceChanges = RegisterNotification[ResourceChanged](NotifyResourceChanged)
+
+// ResourceTopic returns the topic changes to the resource uri are published on.
+func ResourceTopic(uri string) string {
+	return "resources/" + uri
+}
+
+// CodeResourceNotFound is the JSON-RPC error code returned for a resource the
+// server does not offer. Clients report it as ErrResourceNotFound.
+const CodeResourceNotFound int64 = -32012
+
+// ErrResourceNotFound is returned for a resource the server does not offer.
+var ErrResourceNotFound = errors.New("resource not found")
+
+// Resource describes a resource a server offers.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContent is the content of a resource. Text content is carried in
+// Text, anything else in Blob.
+type ResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     []byte `json:"blob,omitempty"` // Base64 encoded in JSON
+}
+
+// ListResourcesResponse is the result returned for a MethodListResources call.
+type ListResourcesResponse struct {
+	Resources []Resource `json:"resources"`
+}
+
+// ReadResourceRequest names the resource of a MethodReadResource call.
+type ReadResourceRequest struct {
+	URI string `json:"uri"`
+}
+
+// ResourceChanged is the payload of NotifyResourceChanged.
+type ResourceChanged struct {
+	URI string `json:"uri"`
+}
@@ -0,0 +1,212 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/narcolepticfox/mcp/core/tools"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// MethodListMethods returns a ListMethodsResponse describing the methods a
+	// server serves, including any parameter specs and schemas they declare.
+	MethodListMethods = "mcp.listMethods"
+
+	// MethodDescribeMethod returns the MethodDescription of the single method
+	// named in a DescribeMethodRequest.
+	MethodDescribeMethod = "mcp.describeMethod"
+
+	// NotifyMethodsChanged is the notification a server sends its clients when
+	// the methods it serves, or their declared schemas, have changed.
+	NotifyMethodsChanged = "mcp.methodsChanged"
+)
+
+// MethodsChanged describes the NotifyMethodsChanged notification, which has no payload.
+var MethodsChanged = RegisterNotification[struct{}](NotifyMethodsChanged)
+
+// ParameterSpec declares a parameter a method accepts in ModelRequest.Parameters.
+type ParameterSpec struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type,omitempty"`     // Required Parameter.Type; empty accepts any
+	Required    bool      `json:"required,omitempty"` // Whether requests must include the parameter
+	Description string    `json:"description,omitempty"`
+}
+
+// MethodDescription describes a method, the requests it accepts and the
+// results it returns. Methods without parameter specs or a schema accept any
+// request.
+type MethodDescription struct {
+	Method       string          `json:"method"`
+	Description  string          `json:"description,omitempty"`
+	Parameters   []ParameterSpec `json:"parameters,omitempty"`
+	ModelSchema  *Schema         `json:"modelSchema,omitempty"`  // JSON Schema for ModelRequest.ModelData
+	ResultSchema *Schema         `json:"resultSchema,omitempty"` // JSON Schema for the Results of successful responses
+}
+
+// ListMethodsResponse is the result returned for a MethodListMethods call.
+type ListMethodsResponse struct {
+	Methods []MethodDescription `json:"methods"`
+}
+
+// DescribeMethodRequest names the method of a MethodDescribeMethod call.
+type DescribeMethodRequest struct {
+	Method string `json:"method"`
+}
+
+// Schema is the subset of JSON Schema that method descriptions use to
+// constrain model data: types, object properties, required properties, array
+// items, enumerations and numeric bounds.
+type Schema struct {
+	Type       string             `json:"type,omitempty"` // "object", "array", "string", "number", "integer", "boolean" or "null"
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Enum       []interface{}      `json:"enum,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	Maximum    *float64           `json:"maximum,omitempty"`
+}
+
+// Validate checks a request against the description's parameter specs and
+// model schema. Values are expected in their decoded JSON form, as a server
+// receives them.
+func (d *MethodDescription) Validate(req *ModelRequest) *tools.ValidationResult {
+	result := tools.NewValidationResult()
+
+	params := make(map[string]Parameter, len(req.Parameters))
+	for _, param := range req.Parameters {
+		params[param.Name] = param
+	}
+	for _, spec := range d.Parameters {
+		param, ok := params[spec.Name]
+		field := "parameters." + spec.Name
+		switch {
+		case !ok && spec.Required:
+			result.AddError(field, "is required")
+		case ok && spec.Type != "" && param.Type.canonical() != spec.Type.canonical():
+			result.AddError(field, fmt.Sprintf("must be of type %s", spec.Type))
+		}
+	}
+
+	if d.ModelSchema != nil {
+		var data interface{}
+		if req.ModelData != nil {
+			data = req.ModelData
+		}
+		d.ModelSchema.validate("modelData", data, result)
+	}
+	return result
+}
+
+// ValidateResults checks the Results of a successful response against the
+// description's result schema. Results are checked in their JSON form, as a
+// client decodes them. Unsuccessful responses and methods without a result
+// schema always pass.
+func (d *MethodDescription) ValidateResults(resp *ModelResponse) *tools.ValidationResult {
+	result := tools.NewValidationResult()
+	if d.ResultSchema == nil || resp == nil || !resp.Success {
+		return result
+	}
+
+	var results interface{}
+	if resp.Results != nil {
+		data, err := json.Marshal(resp.Results)
+		if err != nil {
+			result.AddError("results", "cannot be encoded: "+err.Error())
+			return result
+		}
+		if err := json.Unmarshal(data, &results); err != nil {
+			result.AddError("results", "cannot be decoded: "+err.Error())
+			return result
+		}
+	}
+	d.ResultSchema.validate("results", results, result)
+	return result
+}
+
+// validate checks value at path against the schema, adding any violations to result.
+func (s *Schema) validate(path string, value interface{}, result *tools.ValidationResult) {
+	if s.Type != "" && !hasSchemaType(value, s.Type) {
+		result.AddError(path, fmt.Sprintf("must be of type %s", s.Type))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		allowed := false
+		for _, option := range s.Enum {
+			if reflect.DeepEqual(option, value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			result.AddError(path, fmt.Sprintf("must be one of %v", s.Enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				result.AddError(path+"."+name, "is required")
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := v[name]; ok {
+				s.Properties[name].validate(path+"."+name, property, result)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, result)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			result.AddError(path, fmt.Sprintf("must be at least %v", *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			result.AddError(path, fmt.Sprintf("must be at most %v", *s.Maximum))
+		}
+	}
+}
+
+// hasSchemaType reports whether a decoded JSON value has the named JSON Schema type.
+func hasSchemaType(value interface{}, schemaType string) bool {
+	switch v := value.(type) {
+	case nil:
+		return schemaType == "null"
+	case map[string]interface{}:
+		return schemaType == "object"
+	case []interface{}:
+		return schemaType == "array"
+	case string:
+		return schemaType == "string"
+	case bool:
+		return schemaType == "boolean"
+	case float64:
+		return schemaType == "number" || (schemaType == "integer" && v == float64(int64(v)))
+	}
+	return false
+}
+
+// InvalidParamsError converts a failed validation into the JSON-RPC error a
+// server returns for it: CodeInvalidParams, with the individual
+// tools.ValidationError entries as the error data.
+func InvalidParamsError(result *tools.ValidationResult) *jsonrpc2.Error {
+	rpcErr := &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidParams,
+		Message: result.Error().Error(),
+	}
+	rpcErr.SetError(result.Errors)
+	return rpcErr
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/narcolepticfox/mcp/core/tools"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethodDescriptionValidate(t *testing.T) {
+	maxValue := 100.0
+	desc := &MethodDescription{
+		Method: MethodProcessModel,
+		Parameters: []ParameterSpec{
+			{Name: "mode", Type: "string", Required: true},
+			{Name: "limit", Type: "int"},
+		},
+		ModelSchema: &Schema{
+			Type:     "object",
+			Required: []string{"name"},
+			Properties: map[string]*Schema{
+				"name":  {Type: "string"},
+				"value": {Type: "integer", Maximum: &maxValue},
+				"tags":  {Type: "array", Items: &Schema{Enum: []interface{}{"a", "b"}}},
+			},
+		},
+	}
+
+	valid := &ModelRequest{
+		ModelData:  map[string]interface{}{"name": "model", "value": 42.0, "tags": []interface{}{"a"}},
+		Parameters: []Parameter{{Name: "mode", Value: "fast", Type: "string"}},
+	}
+	assert.True(t, desc.Validate(valid).Valid, "Matching request should be valid")
+
+	invalid := &ModelRequest{
+		ModelData:  map[string]interface{}{"value": 4.5, "tags": []interface{}{"c"}},
+		Parameters: []Parameter{{Name: "limit", Value: "ten", Type: "string"}},
+	}
+	result := desc.Validate(invalid)
+	assert.False(t, result.Valid, "Mismatched request should be invalid")
+	assert.Equal(t, []tools.ValidationError{
+		{Field: "parameters.mode", Message: "is required"},
+		{Field: "parameters.limit", Message: "must be of type int"},
+		{Field: "modelData.name", Message: "is required"},
+		{Field: "modelData.tags[0]", Message: "must be one of [a b]"},
+		{Field: "modelData.value", Message: "must be of type integer"},
+	}, result.Errors, "Every violation should be reported")
+
+	tooLarge := &ModelRequest{ModelData: map[string]interface{}{"name": "model", "value": 500.0}, Parameters: valid.Parameters}
+	assert.Equal(t, []tools.ValidationError{{Field: "modelData.value", Message: "must be at most 100"}},
+		desc.Validate(tooLarge).Errors, "Numeric bounds should be checked")
+
+	// Methods that declare nothing accept any request
+	assert.True(t, (&MethodDescription{Method: MethodProcessModel}).Validate(invalid).Valid, "Empty description should accept anything")
+}
+
+func TestMethodDescriptionValidateResults(t *testing.T) {
+	desc := &MethodDescription{
+		Method: MethodProcessModel,
+		ResultSchema: &Schema{
+			Type:       "object",
+			Required:   []string{"score"},
+			Properties: map[string]*Schema{"score": {Type: "number"}, "labels": {Type: "array", Items: &Schema{Type: "string"}}},
+		},
+	}
+
+	// Results are checked in their JSON form, so Go ints and slices pass
+	valid := &ModelResponse{Success: true, Results: map[string]interface{}{"score": 3, "labels": []string{"cat"}}}
+	assert.True(t, desc.ValidateResults(valid).Valid, "Matching results should be valid")
+
+	invalid := &ModelResponse{Success: true, Results: map[string]interface{}{"labels": []int{1}}}
+	assert.Equal(t, []tools.ValidationError{
+		{Field: "results.score", Message: "is required"},
+		{Field: "results.labels[0]", Message: "must be of type string"},
+	}, desc.ValidateResults(invalid).Errors, "Every violation should be reported")
+
+	failed := &ModelResponse{Success: false, ErrorMessage: "failed"}
+	assert.True(t, desc.ValidateResults(failed).Valid, "Unsuccessful responses should not be checked")
+	assert.True(t, (&MethodDescription{Method: MethodProcessModel}).ValidateResults(invalid).Valid, "Empty description should accept anything")
+}
+
+func TestInvalidParamsError(t *testing.T) {
+	result := tools.NewValidationResult()
+	result.AddError("modelData.name", "is required")
+
+	rpcErr := InvalidParamsError(result)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Code should be invalid params")
+	assert.Equal(t, "validation failed: modelData.name: is required;", rpcErr.Message, "Message should summarize the errors")
+
+	require.NotNil(t, rpcErr.Data, "Data should carry the errors")
+	var errs []tools.ValidationError
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &errs), "Data should decode")
+	assert.Equal(t, result.Errors, errs, "Data should round-trip the errors")
+}
@@ -0,0 +1,94 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// sessionCount numbers sessions, giving each a distinct ID.
+var sessionCount atomic.Uint64
+
+// Session holds the state a server keeps for one client connection across
+// requests, such as a model built up incrementally. Handlers reach it with
+// SessionFromContext. A session lasts until its connection closes, or until
+// it expires after sitting idle when the server sets a session TTL; the next
+// request then starts a new one. It is safe for concurrent use.
+type Session struct {
+	id       string
+	clientID string
+
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+// NewSession creates an empty session for the client with the given ID,
+// with an ID unique within the process.
+func NewSession(clientID string) *Session {
+	id := "session-" + strconv.FormatUint(sessionCount.Add(1), 10)
+	return &Session{id: id, clientID: clientID, values: make(map[string]interface{})}
+}
+
+// ID identifies the session. A connection whose session expired gets a new
+// one with another ID.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// ClientID returns the ID of the client connection the session belongs to,
+// as reported in ClientInfo.
+func (s *Session) ClientID() string {
+	return s.clientID
+}
+
+// Get returns the value stored under key, reporting false if there is none.
+func (s *Session) Get(key string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Set stores value under key, replacing any value already there.
+func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// Delete removes the value stored under key, if any.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+// Keys returns the keys that have a value, sorted.
+func (s *Session) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type sessionKey struct{}
+
+// ContextWithSession returns a copy of ctx carrying the session of the
+// connection a request arrived on.
+func ContextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session of the connection the request
+// being handled arrived on, reporting false outside a request.
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	return session, ok && session != nil
+}
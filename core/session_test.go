@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	session := NewSession("7")
+	assert.Equal(t, "7", session.ClientID(), "Session should belong to the client")
+	assert.NotEqual(t, session.ID(), NewSession("7").ID(), "Sessions should have distinct IDs")
+
+	_, ok := session.Get("model")
+	assert.False(t, ok, "New session should be empty")
+
+	session.Set("model", "draft")
+	session.Set("layers", 3)
+	value, ok := session.Get("model")
+	assert.True(t, ok, "Stored value should be found")
+	assert.Equal(t, "draft", value, "Stored value should be returned")
+	assert.Equal(t, []string{"layers", "model"}, session.Keys(), "Keys should be listed in order")
+
+	session.Delete("model")
+	_, ok = session.Get("model")
+	assert.False(t, ok, "Deleted value should be gone")
+	session.Delete("missing")
+	assert.Equal(t, []string{"layers"}, session.Keys(), "Other values should remain")
+}
+
+func TestSessionConcurrentUse(t *testing.T) {
+	session := NewSession("1")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := strconv.Itoa(i)
+			for j := 0; j < 100; j++ {
+				session.Set(key, j)
+				session.Get(key)
+				session.Keys()
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, session.Keys(), 8, "Every writer's key should be stored")
+}
+
+func TestSessionFromContext(t *testing.T) {
+	_, ok := SessionFromContext(context.Background())
+	assert.False(t, ok, "Plain context should carry no session")
+
+	session := NewSession("1")
+	found, ok := SessionFromContext(ContextWithSession(context.Background(), session))
+	assert.True(t, ok, "Session should be found")
+	assert.Same(t, session, found, "Session should be the one stored")
+
+	_, ok = SessionFromContext(ContextWithSession(context.Background(), nil))
+	assert.False(t, ok, "Nil session should not be reported")
+}
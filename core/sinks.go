@@ -0,0 +1,184 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backoff bounds for retrying a degraded sink.
+const (
+	sinkRetryMin = 50 * time.Millisecond
+	sinkRetryMax = 30 * time.Second
+)
+
+// HealthStatus summarizes whether a component is working as configured.
+type HealthStatus string
+
+const (
+	// HealthHealthy means every observability sink is accepting events.
+	HealthHealthy HealthStatus = "healthy"
+
+	// HealthDegraded means requests are served but at least one sink is
+	// failing and its events are being dropped.
+	HealthDegraded HealthStatus = "degraded"
+)
+
+// Health reports the state of a component's observability sinks.
+type Health struct {
+	Status   HealthStatus      `json:"status"`
+	Degraded map[string]string `json:"degraded,omitempty"` // Failing sinks and their last error
+}
+
+// SinkEvent reports an observability sink becoming degraded or recovering.
+type SinkEvent struct {
+	Sink      string    // Name of the sink, e.g. "metrics"
+	Degraded  bool      // Whether the sink is now degraded
+	Err       error     // Failure that degraded the sink; nil on recovery
+	Timestamp time.Time // When the state changed
+}
+
+// sinkState tracks the failures of a single sink.
+type sinkState struct {
+	err     error
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// SinkSet keeps failing observability sinks, such as metrics collectors and
+// audit logs, off the request path. A sink that returns an error or panics is
+// marked degraded and its events are dropped until a retry, with exponential
+// backoff, succeeds. Callbacks fire once per state change, not per event.
+type SinkSet struct {
+	mu        sync.Mutex
+	logger    Logger
+	tasks     *TaskTracker
+	degraded  map[string]*sinkState
+	callbacks []func(SinkEvent)
+	errors    uint64
+}
+
+// NewSinkSet creates a sink set that logs state changes to logger and runs
+// callbacks under tasks.
+func NewSinkSet(logger Logger, tasks *TaskTracker) *SinkSet {
+	return &SinkSet{
+		logger:   logger,
+		tasks:    tasks,
+		degraded: make(map[string]*sinkState),
+	}
+}
+
+// OnChange registers a callback for sinks becoming degraded or recovering.
+func (s *SinkSet) OnChange(callback func(SinkEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+// Do delivers an event to the named sink by calling fn, unless the sink is
+// degraded and not yet due for a retry. Errors and panics from fn are recorded
+// against the sink and never returned.
+func (s *SinkSet) Do(name string, fn func() error) {
+	s.mu.Lock()
+	state, degraded := s.degraded[name]
+	if degraded && time.Now().Before(state.retryAt) {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	err := callSink(fn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, degraded = s.degraded[name]
+	switch {
+	case err != nil && !degraded:
+		atomic.AddUint64(&s.errors, 1)
+		s.degraded[name] = &sinkState{err: err, backoff: sinkRetryMin, retryAt: time.Now().Add(sinkRetryMin)}
+		s.logger.Warn("Observability sink degraded", "sink", name, LogFieldError, err)
+		s.notifyLocked(SinkEvent{Sink: name, Degraded: true, Err: err, Timestamp: time.Now()})
+	case err != nil:
+		atomic.AddUint64(&s.errors, 1)
+		state.err = err
+		state.backoff = min(state.backoff*2, sinkRetryMax)
+		state.retryAt = time.Now().Add(state.backoff)
+	case degraded:
+		delete(s.degraded, name)
+		s.logger.Info("Observability sink recovered", "sink", name)
+		s.notifyLocked(SinkEvent{Sink: name, Timestamp: time.Now()})
+	}
+}
+
+// callSink calls fn, turning a panic into an error.
+func callSink(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// notifyLocked runs the callbacks for event. The caller must hold s.mu.
+func (s *SinkSet) notifyLocked(event SinkEvent) {
+	for _, callback := range s.callbacks {
+		callback := callback
+		s.tasks.Go(TaskEvents, func() { callback(event) })
+	}
+}
+
+// Errors returns the number of failed sink deliveries so far.
+func (s *SinkSet) Errors() uint64 {
+	return atomic.LoadUint64(&s.errors)
+}
+
+// Health reports which sinks are degraded.
+func (s *SinkSet) Health() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.degraded) == 0 {
+		return Health{Status: HealthHealthy}
+	}
+	health := Health{Status: HealthDegraded, Degraded: make(map[string]string, len(s.degraded))}
+	for name, state := range s.degraded {
+		health.Degraded[name] = state.err.Error()
+	}
+	return health
+}
+
+// Metrics wraps collector so its panics degrade the named sink instead of
+// failing the caller.
+func (s *SinkSet) Metrics(name string, collector MetricsCollector) MetricsCollector {
+	return guardedMetrics{sinks: s, name: name, collector: collector}
+}
+
+// guardedMetrics delivers measurements through a SinkSet.
+type guardedMetrics struct {
+	sinks     *SinkSet
+	name      string
+	collector MetricsCollector
+}
+
+func (m guardedMetrics) ConnectionOpened(addr string) {
+	m.sinks.Do(m.name, func() error { m.collector.ConnectionOpened(addr); return nil })
+}
+
+func (m guardedMetrics) ConnectionClosed(addr string) {
+	m.sinks.Do(m.name, func() error { m.collector.ConnectionClosed(addr); return nil })
+}
+
+func (m guardedMetrics) RequestStarted(method string) {
+	m.sinks.Do(m.name, func() error { m.collector.RequestStarted(method); return nil })
+}
+
+func (m guardedMetrics) RequestCompleted(method string, duration time.Duration, success bool) {
+	m.sinks.Do(m.name, func() error { m.collector.RequestCompleted(method, duration, success); return nil })
+}
+
+func (m guardedMetrics) PayloadSize(method string, bytesIn, bytesOut int) {
+	m.sinks.Do(m.name, func() error { m.collector.PayloadSize(method, bytesIn, bytesOut); return nil })
+}
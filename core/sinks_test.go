@@ -0,0 +1,96 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSinkSetDegradeAndRecover(t *testing.T) {
+	sinks := NewSinkSet(NopLogger(), NewTaskTracker(NopLogger(), nil))
+
+	var mu sync.Mutex
+	var events []SinkEvent
+	sinks.OnChange(func(event SinkEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	recorded := func() []SinkEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]SinkEvent(nil), events...)
+	}
+
+	assert.Equal(t, Health{Status: HealthHealthy}, sinks.Health(), "New sink set should be healthy")
+
+	// A failure degrades the sink and later events are dropped until the retry
+	failure := errors.New("disk full")
+	calls := 0
+	for i := 0; i < 5; i++ {
+		sinks.Do("audit", func() error {
+			calls++
+			return failure
+		})
+	}
+	assert.Equal(t, 1, calls, "Events should be dropped while waiting to retry")
+	assert.Equal(t, uint64(1), sinks.Errors(), "The failed delivery should be counted")
+	assert.Equal(t, Health{
+		Status:   HealthDegraded,
+		Degraded: map[string]string{"audit": "disk full"},
+	}, sinks.Health(), "Health should name the degraded sink")
+
+	// Panics are failures too, and do not affect other sinks
+	sinks.Do("metrics", func() error { panic("collector bug") })
+	assert.Contains(t, sinks.Health().Degraded["metrics"], "collector bug", "A panic should degrade its sink")
+
+	// A successful retry recovers the sink
+	assert.Eventually(t, func() bool {
+		sinks.Do("audit", func() error { return nil })
+		_, degraded := sinks.Health().Degraded["audit"]
+		return !degraded
+	}, time.Second, 10*time.Millisecond, "Sink should recover once a retry succeeds")
+
+	assert.Eventually(t, func() bool {
+		return len(recorded()) == 3
+	}, time.Second, 10*time.Millisecond, "Callbacks should fire once per state change")
+	got := recorded()
+	var audit []SinkEvent
+	for _, event := range got {
+		if event.Sink == "audit" {
+			audit = append(audit, event)
+		}
+	}
+	if assert.Len(t, audit, 2, "Audit sink should change state twice") {
+		// Callbacks run concurrently, so order by state rather than arrival
+		if !audit[0].Degraded {
+			audit[0], audit[1] = audit[1], audit[0]
+		}
+		assert.Equal(t, failure, audit[0].Err, "Degraded event should carry the failure")
+		assert.False(t, audit[1].Degraded, "Recovery event should report the sink healthy")
+		assert.NoError(t, audit[1].Err, "Recovery event should carry no error")
+	}
+}
+
+func TestSinkSetBackoff(t *testing.T) {
+	sinks := NewSinkSet(NopLogger(), NewTaskTracker(NopLogger(), nil))
+
+	// Each failed retry doubles the wait before the next one
+	calls := 0
+	fail := func() error {
+		calls++
+		return errors.New("unavailable")
+	}
+	deadline := time.Now().Add(400 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		sinks.Do("audit", fail)
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Attempts at roughly 0, 50, 150 and 350ms
+	assert.GreaterOrEqual(t, calls, 3, "Sink should be retried")
+	assert.LessOrEqual(t, calls, 5, "Retries should back off exponentially")
+	assert.Equal(t, uint64(calls), sinks.Errors(), "Every failed delivery should be counted")
+}
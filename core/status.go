@@ -1,7 +1,14 @@
 // Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
 package core
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
 
 // Status represents the operational status of an MCP component.
 // It uses enumerated values to indicate the component's current state.
@@ -24,19 +31,101 @@ const (
 	StatusFailed
 )
 
+// StatusIdle is another name for StatusStopped: a component that has not
+// started, or has stopped, is idle.
+const StatusIdle = StatusStopped
+
+// statusNames holds the name of each status, indexed by its value.
+var statusNames = [...]string{"Stopped", "Starting", "Running", "Stopping", "Failed"}
+
 // String returns a string representation of the status.
 // This implements the Stringer interface for the Status type.
+// Values outside the defined statuses are written as Status(n).
 func (s Status) String() string {
-	return [...]string{"Stopped", "Starting", "Running", "Stopping", "Failed"}[s]
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+	return statusNames[s]
+}
+
+// ParseStatus returns the status with the given name, such as "Running",
+// ignoring case. "Idle" names StatusStopped.
+func ParseStatus(name string) (Status, error) {
+	if strings.EqualFold(name, "Idle") {
+		return StatusIdle, nil
+	}
+	for i, known := range statusNames {
+		if strings.EqualFold(name, known) {
+			return Status(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status %q, want one of %s", name, strings.Join(statusNames[:], ", "))
+}
+
+// MarshalText writes the status as its name, so statuses appear by name in
+// JSON payloads and configuration files. Values outside the defined statuses
+// fail.
+func (s Status) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(statusNames) {
+		return nil, fmt.Errorf("invalid status %d", int(s))
+	}
+	return []byte(statusNames[s]), nil
+}
+
+// UnmarshalText reads a status name as ParseStatus does.
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// UnmarshalJSON reads a status written by name, or by number as statuses
+// were before they were written by name.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+		return s.UnmarshalText([]byte(name))
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid status %s: want a name or a number", data)
+	}
+	if n < 0 || n >= len(statusNames) {
+		return fmt.Errorf("invalid status %d", n)
+	}
+	*s = Status(n)
+	return nil
 }
 
 // StatusChangeEvent represents a status change notification.
-// It contains the previous and new status, the time of the change, and any associated error.
+// It contains the previous and new status, the time of the change, any associated error,
+// and the component that changed.
 type StatusChangeEvent struct {
-	OldStatus Status    // Status before the change
-	NewStatus Status    // Status after the change
-	Timestamp time.Time // When the status change occurred
-	Error     error     // Error that caused the status change, if any
+	OldStatus Status       // Status before the change
+	NewStatus Status       // Status after the change
+	Timestamp time.Time    // When the status change occurred
+	Error     error        // Error that caused the status change, if any
+	Source    StatusSource // Component whose status changed; empty for components that do not say
+}
+
+// ConnectionEvent describes a client connecting to the server, losing a
+// connection, or failing an attempt to reconnect. Unlike status changes,
+// these are reported while the client keeps running.
+type ConnectionEvent struct {
+	RemoteAddr string    // Address of the server connected to, lost, or dialed
+	Connection int       // Pooled connection the event concerns, from 0
+	Attempt    int       // Reconnection attempt, from 1; zero for connections made by Start and for drops
+	Timestamp  time.Time // When the event happened
+	Error      error     // Why the connection dropped or the attempt failed; nil for connects and for connections closed by Stop
 }
 
 // Component defines the interface for MCP components.
@@ -56,5 +145,128 @@ type Component interface {
 
 	// OnStatusChange registers a callback function to be called when the component's status changes.
 	// The callback receives a StatusChangeEvent containing details about the change.
-	OnStatusChange(callback func(StatusChangeEvent))
+	// Calling the returned function removes the callback.
+	OnStatusChange(callback func(StatusChangeEvent)) (cancel func())
+}
+
+// statusQueueSize is how many status changes a StatusNotifier holds for
+// its callbacks before dropping the oldest.
+const statusQueueSize = 64
+
+// StatusNotifier delivers status changes to callbacks. Events are delivered
+// one at a time, in the order they were published, to each callback in the
+// order it was registered, by a single goroutine that runs while events are
+// pending. Publishing never waits for callbacks: when they fall more than
+// statusQueueSize events behind, the oldest pending event is dropped.
+type StatusNotifier struct {
+	mu          sync.Mutex
+	logger      Logger
+	tasks       *TaskTracker
+	subscribers []*statusSubscriber
+	queue       []StatusChangeEvent
+	delivering  bool // Whether the dispatcher goroutine is running
+	dropped     uint64
+}
+
+// statusSubscriber is a registered callback.
+type statusSubscriber struct {
+	callback  func(StatusChangeEvent)
+	cancelled bool // Guarded by StatusNotifier.mu
+}
+
+// NewStatusNotifier creates a notifier that runs its dispatcher under tasks
+// and logs dropped events and panicking callbacks to logger.
+func NewStatusNotifier(logger Logger, tasks *TaskTracker) *StatusNotifier {
+	return &StatusNotifier{logger: logger, tasks: tasks}
+}
+
+// Subscribe registers callback for the events published after it returns.
+// Calling cancel removes it; an event being delivered when cancel is called
+// may still reach it, later ones do not. Calling cancel again does nothing.
+func (n *StatusNotifier) Subscribe(callback func(StatusChangeEvent)) (cancel func()) {
+	sub := &statusSubscriber{callback: callback}
+	n.mu.Lock()
+	n.subscribers = append(n.subscribers, sub)
+	n.mu.Unlock()
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if sub.cancelled {
+			return
+		}
+		sub.cancelled = true
+		for i, s := range n.subscribers {
+			if s == sub {
+				n.subscribers = append(n.subscribers[:i:i], n.subscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Publish queues event for the callbacks and returns without waiting for
+// them. It is safe to call with the publisher's own locks held.
+func (n *StatusNotifier) Publish(event StatusChangeEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.subscribers) == 0 {
+		return
+	}
+	if len(n.queue) == statusQueueSize {
+		oldest := n.queue[0]
+		n.queue = n.queue[1:]
+		n.dropped++
+		n.logger.Warn("Status change callbacks are falling behind, dropping event",
+			"status", oldest.NewStatus.String(), "dropped", n.dropped)
+	}
+	n.queue = append(n.queue, event)
+	if !n.delivering {
+		n.delivering = true
+		n.tasks.Go(TaskEvents, n.dispatch)
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func (n *StatusNotifier) Dropped() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.dropped
+}
+
+// dispatch delivers queued events until none are left.
+func (n *StatusNotifier) dispatch() {
+	for {
+		n.mu.Lock()
+		if len(n.queue) == 0 {
+			n.delivering = false
+			n.queue = nil
+			n.mu.Unlock()
+			return
+		}
+		event := n.queue[0]
+		n.queue = n.queue[1:]
+		subscribers := append([]*statusSubscriber(nil), n.subscribers...)
+		n.mu.Unlock()
+
+		for _, sub := range subscribers {
+			n.mu.Lock()
+			cancelled := sub.cancelled
+			n.mu.Unlock()
+			if !cancelled {
+				n.deliver(sub.callback, event)
+			}
+		}
+	}
+}
+
+// deliver calls callback with event, logging rather than propagating a panic
+// so one callback cannot stop delivery to the others.
+func (n *StatusNotifier) deliver(callback func(StatusChangeEvent), event StatusChangeEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			n.logger.Error("Status change callback panicked", LogFieldError, r)
+		}
+	}()
+	callback(event)
 }
@@ -1,10 +1,14 @@
 package core
 
 import (
+	"encoding/json"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStatusString(t *testing.T) {
@@ -28,6 +32,85 @@ func TestStatusString(t *testing.T) {
 	}
 }
 
+func TestStatusStringOutOfRange(t *testing.T) {
+	assert.Equal(t, "Status(7)", Status(7).String(), "An undefined status should be written by number")
+	assert.Equal(t, "Status(-1)", Status(-1).String(), "A negative status should be written by number")
+}
+
+func TestStatusIdle(t *testing.T) {
+	assert.Equal(t, StatusStopped, StatusIdle, "StatusIdle should be StatusStopped")
+	assert.Equal(t, "Stopped", StatusIdle.String(), "StatusIdle should be written as Stopped")
+}
+
+func TestParseStatus(t *testing.T) {
+	for name, want := range map[string]Status{
+		"Stopped":  StatusStopped,
+		"starting": StatusStarting,
+		"RUNNING":  StatusRunning,
+		"Stopping": StatusStopping,
+		"failed":   StatusFailed,
+		"Idle":     StatusIdle,
+	} {
+		got, err := ParseStatus(name)
+		require.NoError(t, err, "Parsing %q should succeed", name)
+		assert.Equal(t, want, got, "%q should name its status", name)
+	}
+
+	for status := StatusStopped; status <= StatusFailed; status++ {
+		got, err := ParseStatus(status.String())
+		require.NoError(t, err, "Parsing %q should succeed", status)
+		assert.Equal(t, status, got, "Parsing a status's name should return the status")
+	}
+
+	_, err := ParseStatus("Sleeping")
+	require.Error(t, err, "An unknown name should fail")
+	assert.Contains(t, err.Error(), `unknown status "Sleeping"`, "The error should name the value")
+	assert.Contains(t, err.Error(), "Running", "The error should list the known statuses")
+}
+
+func TestStatusJSON(t *testing.T) {
+	data, err := json.Marshal(HealthResponse{Status: StatusRunning})
+	require.NoError(t, err, "Marshaling should succeed")
+	assert.Contains(t, string(data), `"status":"Running"`, "The status should be written by name")
+
+	var health HealthResponse
+	require.NoError(t, json.Unmarshal(data, &health), "Unmarshaling should succeed")
+	assert.Equal(t, StatusRunning, health.Status, "The status should survive a round trip")
+
+	for input, want := range map[string]Status{
+		`"Failed"`:   StatusFailed,
+		`"stopping"`: StatusStopping,
+		`1`:          StatusStarting,
+	} {
+		var status Status
+		require.NoError(t, json.Unmarshal([]byte(input), &status), "Unmarshaling %s should succeed", input)
+		assert.Equal(t, want, status, "%s should decode to its status", input)
+	}
+
+	for _, input := range []string{`"Sleeping"`, `9`, `-1`, `1.5`, `true`} {
+		var status Status
+		assert.Error(t, json.Unmarshal([]byte(input), &status), "Unmarshaling %s should fail", input)
+	}
+
+	_, err = json.Marshal(Status(9))
+	assert.Error(t, err, "Marshaling an undefined status should fail")
+}
+
+func TestStatusText(t *testing.T) {
+	text, err := StatusStopping.MarshalText()
+	require.NoError(t, err, "Marshaling should succeed")
+	assert.Equal(t, "Stopping", string(text), "The status should be written by name")
+
+	var status Status
+	require.NoError(t, status.UnmarshalText([]byte("running")), "Unmarshaling should succeed")
+	assert.Equal(t, StatusRunning, status, "The name should decode to its status")
+	assert.Error(t, status.UnmarshalText([]byte("2")), "A number should not be a status name")
+
+	data, err := json.Marshal(map[Status]int{StatusFailed: 1})
+	require.NoError(t, err, "Marshaling a map keyed by status should succeed")
+	assert.JSONEq(t, `{"Failed": 1}`, string(data), "Map keys should be written by name")
+}
+
 // MockComponent implements the Component interface for testing
 type MockComponent struct {
 	status       Status
@@ -73,8 +156,9 @@ func (m *MockComponent) Status() Status {
 	return m.status
 }
 
-func (m *MockComponent) OnStatusChange(callback func(StatusChangeEvent)) {
+func (m *MockComponent) OnStatusChange(callback func(StatusChangeEvent)) func() {
 	m.callbacks = append(m.callbacks, callback)
+	return func() {}
 }
 
 func (m *MockComponent) notifyStatusChange(oldStatus, newStatus Status, err error) {
@@ -152,3 +236,133 @@ func TestComponentErrors(t *testing.T) {
 	// Verify that no status change events were received (since the mock doesn't change status on error)
 	assert.Len(t, receivedEvents, 0, "Should not have received any status change events")
 }
+
+// statusRecorder collects the events a StatusNotifier delivers, tagged with
+// the callback that received them
+type statusRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *statusRecorder) callback(tag string) func(StatusChangeEvent) {
+	return func(event StatusChangeEvent) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, tag+":"+event.NewStatus.String())
+	}
+}
+
+func (r *statusRecorder) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestStatusNotifierOrder(t *testing.T) {
+	notifier := NewStatusNotifier(NopLogger(), NewTaskTracker(NopLogger(), nil))
+	recorder := &statusRecorder{}
+	notifier.Subscribe(recorder.callback("a"))
+	cancelB := notifier.Subscribe(recorder.callback("b"))
+
+	for _, status := range []Status{StatusStarting, StatusRunning, StatusStopping} {
+		notifier.Publish(StatusChangeEvent{NewStatus: status})
+	}
+	want := []string{"a:Starting", "b:Starting", "a:Running", "b:Running", "a:Stopping", "b:Stopping"}
+	require.Eventually(t, func() bool { return len(recorder.snapshot()) == len(want) }, time.Second, 5*time.Millisecond,
+		"Every callback should receive every event")
+	assert.Equal(t, want, recorder.snapshot(), "Events should arrive in order, to callbacks in registration order")
+
+	cancelB()
+	cancelB()
+	notifier.Publish(StatusChangeEvent{NewStatus: StatusStopped})
+	require.Eventually(t, func() bool { return len(recorder.snapshot()) == len(want)+1 }, time.Second, 5*time.Millisecond,
+		"Remaining callback should receive the event")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, "a:Stopped", recorder.snapshot()[len(want)], "Cancelled callback should not be called")
+	assert.Len(t, recorder.snapshot(), len(want)+1, "Cancelled callback should not be called")
+}
+
+func TestStatusNotifierSlowCallback(t *testing.T) {
+	notifier := NewStatusNotifier(NopLogger(), NewTaskTracker(NopLogger(), nil))
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var mu sync.Mutex
+	var received []Status
+	notifier.Subscribe(func(event StatusChangeEvent) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event.NewStatus)
+	})
+
+	notifier.Publish(StatusChangeEvent{NewStatus: StatusStarting})
+	<-started
+
+	// Publishing never waits for the blocked callback
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < statusQueueSize+9; i++ {
+			notifier.Publish(StatusChangeEvent{NewStatus: StatusRunning})
+		}
+		notifier.Publish(StatusChangeEvent{NewStatus: StatusStopped})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish should not block on a slow callback")
+	}
+	assert.Equal(t, uint64(10), notifier.Dropped(), "Events beyond the queue should be dropped")
+
+	close(release)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) > 0 && received[len(received)-1] == StatusStopped
+	}, time.Second, 5*time.Millisecond, "Latest event should still be delivered")
+}
+
+func TestStatusNotifierConcurrentSubscribe(t *testing.T) {
+	notifier := NewStatusNotifier(NopLogger(), NewTaskTracker(NopLogger(), nil))
+	recorder := &statusRecorder{}
+	notifier.Subscribe(recorder.callback("steady"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cancel := notifier.Subscribe(func(StatusChangeEvent) {})
+				cancel()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				notifier.Publish(StatusChangeEvent{NewStatus: StatusRunning})
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Eventually(t, func() bool { return len(recorder.snapshot()) == 40 }, time.Second, 5*time.Millisecond,
+		"Steady callback should receive every event")
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	assert.Len(t, notifier.subscribers, 1, "Cancelled callbacks should be removed")
+}
+
+func TestStatusNotifierRecoversPanics(t *testing.T) {
+	notifier := NewStatusNotifier(NopLogger(), NewTaskTracker(NopLogger(), nil))
+	recorder := &statusRecorder{}
+	notifier.Subscribe(func(StatusChangeEvent) { panic("callback exploded") })
+	notifier.Subscribe(recorder.callback("after"))
+
+	notifier.Publish(StatusChangeEvent{NewStatus: StatusRunning})
+	require.Eventually(t, func() bool { return len(recorder.snapshot()) == 1 }, time.Second, 5*time.Millisecond,
+		"A panicking callback should not stop delivery to the others")
+}
@@ -0,0 +1,17 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+// NotifyModelChunk is the notification carrying a ModelChunk of a
+// MethodProcessModelStream call. The server sends every chunk of a request
+// before its reply, on the same connection and in order.
+const NotifyModelChunk = "mcp.modelChunk"
+
+// ModelChunks describes the NotifyModelChunk notification.
+var ModelChunks = RegisterNotification[ModelChunk](NotifyModelChunk)
+
+// ModelChunk is a partial result of a streamed model request.
+type ModelChunk struct {
+	RequestID string                 `json:"requestId"` // ID of the ModelRequest the chunk belongs to
+	Sequence  int                    `json:"sequence"`  // Position of the chunk in its stream, from zero
+	Data      map[string]interface{} `json:"data"`
+}
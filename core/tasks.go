@@ -0,0 +1,183 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// TaskFeature names the feature a background goroutine or timer belongs to.
+type TaskFeature string
+
+// Features that clients and servers start background work for.
+const (
+	TaskConnection TaskFeature = "connection" // Serving or monitoring a connection
+	TaskAccept     TaskFeature = "accept"     // Accepting connections on a listener
+	TaskReconnect  TaskFeature = "reconnect"  // Re-establishing a lost connection
+	TaskKeepalive  TaskFeature = "keepalive"  // Sending heartbeats
+	TaskMetrics    TaskFeature = "metrics"    // Serving the metrics listener
+	TaskAdmin      TaskFeature = "admin"      // Serving HTTP on a shared port
+	TaskJobs       TaskFeature = "jobs"       // Running handlers off the connection, e.g. batch items
+	TaskEvents     TaskFeature = "events"     // Dispatching status change callbacks
+	TaskHandoff    TaskFeature = "handoff"    // Draining connections for a successor
+	TaskHealth     TaskFeature = "health"     // Serving the health check listener
+	TaskResources  TaskFeature = "resources"  // Watching resources for changes
+	TaskTimeouts   TaskFeature = "timeouts"   // Running handlers under a handler timeout
+)
+
+// maxStackSamples is how many task stacks a budget warning includes.
+const maxStackSamples = 3
+
+// TaskCount is the number of live goroutines and timers of one feature.
+type TaskCount struct {
+	Goroutines int `json:"goroutines"`
+	Timers     int `json:"timers"`
+}
+
+// TaskTracker counts the goroutines and timers a client or server has running,
+// by feature. When budgets are set, starting a task that takes a feature over
+// its budget logs a warning with the stacks that started the live tasks.
+type TaskTracker struct {
+	mu      sync.Mutex
+	logger  Logger
+	budgets map[TaskFeature]int
+	counts  map[TaskFeature]TaskCount
+	stacks  map[TaskFeature]map[uint64][]byte // Creation stacks, kept only when budgets are set
+	nextID  uint64
+}
+
+// NewTaskTracker creates a tracker that reports budget overruns to logger.
+// A feature's budget limits its goroutines and timers combined; features
+// without a budget are only counted.
+func NewTaskTracker(logger Logger, budgets map[TaskFeature]int) *TaskTracker {
+	return &TaskTracker{
+		logger:  logger,
+		budgets: budgets,
+		counts:  make(map[TaskFeature]TaskCount),
+		stacks:  make(map[TaskFeature]map[uint64][]byte),
+	}
+}
+
+// Go runs fn in a new goroutine counted under feature until fn returns.
+func (t *TaskTracker) Go(feature TaskFeature, fn func()) {
+	id := t.start(feature, func(c *TaskCount) { c.Goroutines++ })
+	go func() {
+		defer t.finish(feature, id, func(c *TaskCount) { c.Goroutines-- })
+		fn()
+	}()
+}
+
+// Ticker is a time.Ticker counted by a TaskTracker until it is stopped.
+type Ticker struct {
+	*time.Ticker
+	stop sync.Once
+	done func()
+}
+
+// Stop stops the ticker and releases it from the tracker.
+func (t *Ticker) Stop() {
+	t.Ticker.Stop()
+	t.stop.Do(t.done)
+}
+
+// NewTicker returns a ticker counted under feature until Stop is called.
+func (t *TaskTracker) NewTicker(feature TaskFeature, d time.Duration) *Ticker {
+	id := t.start(feature, func(c *TaskCount) { c.Timers++ })
+	return &Ticker{
+		Ticker: time.NewTicker(d),
+		done:   func() { t.finish(feature, id, func(c *TaskCount) { c.Timers-- }) },
+	}
+}
+
+// Timer is a time.Timer counted by a TaskTracker until it is stopped.
+type Timer struct {
+	*time.Timer
+	stop sync.Once
+	done func()
+}
+
+// Stop stops the timer and releases it from the tracker. It reports whether
+// the timer was stopped before it fired.
+func (t *Timer) Stop() bool {
+	stopped := t.Timer.Stop()
+	t.stop.Do(t.done)
+	return stopped
+}
+
+// NewTimer returns a timer counted under feature until Stop is called, even
+// after it has fired.
+func (t *TaskTracker) NewTimer(feature TaskFeature, d time.Duration) *Timer {
+	id := t.start(feature, func(c *TaskCount) { c.Timers++ })
+	return &Timer{
+		Timer: time.NewTimer(d),
+		done:  func() { t.finish(feature, id, func(c *TaskCount) { c.Timers-- }) },
+	}
+}
+
+// Counts returns the live tasks of every feature that has any.
+func (t *TaskTracker) Counts() map[TaskFeature]TaskCount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := make(map[TaskFeature]TaskCount, len(t.counts))
+	for feature, count := range t.counts {
+		counts[feature] = count
+	}
+	return counts
+}
+
+// start counts a new task and checks the feature's budget.
+func (t *TaskTracker) start(feature TaskFeature, inc func(*TaskCount)) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.counts[feature]
+	inc(&count)
+	t.counts[feature] = count
+
+	budget, limited := t.budgets[feature]
+	if !limited {
+		return 0
+	}
+
+	t.nextID++
+	id := t.nextID
+	if t.stacks[feature] == nil {
+		t.stacks[feature] = make(map[uint64][]byte)
+	}
+	t.stacks[feature][id] = debug.Stack()
+
+	if total := count.Goroutines + count.Timers; total > budget {
+		samples := []string{string(t.stacks[feature][id])}
+		for other, stack := range t.stacks[feature] {
+			if len(samples) == maxStackSamples {
+				break
+			}
+			if other != id {
+				samples = append(samples, string(stack))
+			}
+		}
+		t.logger.Warn("Task budget exceeded",
+			"feature", string(feature),
+			"tasks", total,
+			"budget", budget,
+			"stacks", samples)
+	}
+	return id
+}
+
+// finish releases a task started with start.
+func (t *TaskTracker) finish(feature TaskFeature, id uint64, dec func(*TaskCount)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.counts[feature]
+	dec(&count)
+	if count == (TaskCount{}) {
+		delete(t.counts, feature)
+	} else {
+		t.counts[feature] = count
+	}
+	delete(t.stacks[feature], id)
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskTrackerCounts(t *testing.T) {
+	tracker := NewTaskTracker(NopLogger(), nil)
+	assert.Empty(t, tracker.Counts(), "New tracker should count nothing")
+
+	release := make(chan struct{})
+	exited := make(chan struct{})
+	tracker.Go(TaskJobs, func() {
+		<-release
+		close(exited)
+	})
+	ticker := tracker.NewTicker(TaskKeepalive, time.Hour)
+	timer := tracker.NewTimer(TaskReconnect, time.Hour)
+
+	assert.Equal(t, map[TaskFeature]TaskCount{
+		TaskJobs:      {Goroutines: 1},
+		TaskKeepalive: {Timers: 1},
+		TaskReconnect: {Timers: 1},
+	}, tracker.Counts(), "Each task should be counted under its feature")
+
+	close(release)
+	<-exited
+	ticker.Stop()
+	ticker.Stop()
+	assert.True(t, timer.Stop(), "Timer should stop before firing")
+
+	assert.Eventually(t, func() bool {
+		return len(tracker.Counts()) == 0
+	}, time.Second, 10*time.Millisecond, "Finished tasks should be released, once each")
+}
+
+func TestTaskTrackerBudget(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	tracker := NewTaskTracker(logger, map[TaskFeature]int{TaskKeepalive: 1})
+
+	first := tracker.NewTicker(TaskKeepalive, time.Hour)
+	defer first.Stop()
+	assert.Empty(t, buf.String(), "Staying within the budget should not warn")
+
+	// Unbudgeted features are only counted
+	other := tracker.NewTicker(TaskMetrics, time.Hour)
+	other.Stop()
+	assert.Empty(t, buf.String(), "Features without a budget should not warn")
+
+	second := tracker.NewTicker(TaskKeepalive, time.Hour)
+	defer second.Stop()
+	out := buf.String()
+	assert.Contains(t, out, "Task budget exceeded", "Exceeding the budget should warn")
+	assert.Contains(t, out, `"feature":"keepalive"`, "Warning should name the feature")
+	assert.Contains(t, out, "TestTaskTrackerBudget", "Warning should include the stacks that started the tasks")
+	assert.Equal(t, 2, strings.Count(out, "[running]"), "Warning should sample every live task")
+}
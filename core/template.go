@@ -0,0 +1,233 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// RequestTemplate builds requests of the same shape from a prototype whose
+// model data and parameter values contain placeholders such as "${runID}".
+// The prototype is parsed once, so each Instantiate only copies it and fills
+// in the variables. A string that is a single placeholder and nothing else is
+// replaced by the variable's value as is, keeping its type; placeholders
+// within longer strings are replaced by the value formatted with fmt.Sprint.
+// Maps of type map[string]interface{} and slices of type []interface{} are
+// copied for every request; values of other types are shared between them.
+type RequestTemplate struct {
+	modelData  map[string]templateValue
+	parameters []templateParameter
+	blobRefs   []BlobID
+	metadata   map[string]string
+	vars       []string // Names of every placeholder, sorted
+	next       uint64   // Sequence distinguishing IDs generated in the same second; accessed atomically
+}
+
+// templateParameter is a parameter whose value may hold placeholders.
+type templateParameter struct {
+	name  string
+	typ   ParamType
+	value templateValue
+}
+
+// templateValue is a parsed prototype value.
+type templateValue struct {
+	literal interface{}              // Value without placeholders, when items and fields are nil and parts empty
+	whole   string                   // Variable the value is replaced by, if it is a single placeholder
+	parts   []templatePart           // Text and placeholders of a string with placeholders
+	fields  map[string]templateValue // Members of a map
+	items   []templateValue          // Elements of a slice
+	isMap   bool
+	isSlice bool
+}
+
+// templatePart is a run of text in a string, or a placeholder if name is set.
+type templatePart struct {
+	text string
+	name string
+}
+
+// NewRequestTemplate compiles proto into a template. It returns an error if a
+// placeholder is not closed or has no name.
+func NewRequestTemplate(proto *ModelRequest) (*RequestTemplate, error) {
+	vars := make(map[string]struct{})
+	t := &RequestTemplate{modelData: make(map[string]templateValue, len(proto.ModelData))}
+	for key, value := range proto.ModelData {
+		parsed, err := parseTemplateValue(value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("modelData.%s: %w", key, err)
+		}
+		t.modelData[key] = parsed
+	}
+	for _, param := range proto.Parameters {
+		parsed, err := parseTemplateValue(param.Value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+		t.parameters = append(t.parameters, templateParameter{name: param.Name, typ: param.Type, value: parsed})
+	}
+	t.blobRefs = append([]BlobID(nil), proto.BlobRefs...)
+	if len(proto.Metadata) > 0 {
+		t.metadata = make(map[string]string, len(proto.Metadata))
+		for key, value := range proto.Metadata {
+			t.metadata[key] = value
+		}
+	}
+	for name := range vars {
+		t.vars = append(t.vars, name)
+	}
+	sort.Strings(t.vars)
+	return t, nil
+}
+
+// Vars returns the names of the template's placeholders, sorted.
+func (t *RequestTemplate) Vars() []string {
+	return append([]string(nil), t.vars...)
+}
+
+// Instantiate returns a new request from the template with the placeholders
+// replaced by vars and a fresh ID. It returns an error naming every
+// placeholder without a value in vars; variables the template does not use
+// are ignored.
+func (t *RequestTemplate) Instantiate(vars map[string]interface{}) (*ModelRequest, error) {
+	var missing []string
+	for _, name := range t.vars {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+
+	req := &ModelRequest{
+		ID:         generateID() + "-" + strconv.FormatUint(atomic.AddUint64(&t.next, 1), 10),
+		ModelData:  make(map[string]interface{}, len(t.modelData)),
+		Parameters: make([]Parameter, len(t.parameters)),
+	}
+	for key, value := range t.modelData {
+		req.ModelData[key] = value.build(vars)
+	}
+	for i, param := range t.parameters {
+		req.Parameters[i] = Parameter{Name: param.name, Type: param.typ, Value: param.value.build(vars)}
+	}
+	if len(t.blobRefs) > 0 {
+		req.BlobRefs = append([]BlobID(nil), t.blobRefs...)
+	}
+	if t.metadata != nil {
+		req.Metadata = make(map[string]string, len(t.metadata))
+		for key, value := range t.metadata {
+			req.Metadata[key] = value
+		}
+	}
+	return req, nil
+}
+
+// build returns a copy of the value with placeholders replaced by vars, all
+// of which are present.
+func (v templateValue) build(vars map[string]interface{}) interface{} {
+	switch {
+	case v.whole != "":
+		return vars[v.whole]
+	case v.parts != nil:
+		var b strings.Builder
+		for _, part := range v.parts {
+			if part.name == "" {
+				b.WriteString(part.text)
+			} else if s, ok := vars[part.name].(string); ok {
+				b.WriteString(s)
+			} else {
+				fmt.Fprint(&b, vars[part.name])
+			}
+		}
+		return b.String()
+	case v.isMap:
+		m := make(map[string]interface{}, len(v.fields))
+		for key, field := range v.fields {
+			m[key] = field.build(vars)
+		}
+		return m
+	case v.isSlice:
+		s := make([]interface{}, len(v.items))
+		for i, item := range v.items {
+			s[i] = item.build(vars)
+		}
+		return s
+	}
+	return v.literal
+}
+
+// parseTemplateValue parses value, adding the names of its placeholders to vars.
+func parseTemplateValue(value interface{}, vars map[string]struct{}) (templateValue, error) {
+	switch value := value.(type) {
+	case string:
+		parts, err := parsePlaceholders(value)
+		if err != nil || parts == nil {
+			return templateValue{literal: value}, err
+		}
+		for _, part := range parts {
+			if part.name != "" {
+				vars[part.name] = struct{}{}
+			}
+		}
+		if len(parts) == 1 {
+			return templateValue{whole: parts[0].name}, nil
+		}
+		return templateValue{parts: parts}, nil
+	case map[string]interface{}:
+		parsed := templateValue{isMap: true, fields: make(map[string]templateValue, len(value))}
+		for key, field := range value {
+			f, err := parseTemplateValue(field, vars)
+			if err != nil {
+				return templateValue{}, fmt.Errorf("%s: %w", key, err)
+			}
+			parsed.fields[key] = f
+		}
+		return parsed, nil
+	case []interface{}:
+		parsed := templateValue{isSlice: true, items: make([]templateValue, len(value))}
+		for i, item := range value {
+			it, err := parseTemplateValue(item, vars)
+			if err != nil {
+				return templateValue{}, fmt.Errorf("[%d]: %w", i, err)
+			}
+			parsed.items[i] = it
+		}
+		return parsed, nil
+	}
+	return templateValue{literal: value}, nil
+}
+
+// parsePlaceholders splits s into text and placeholders. It returns nil if s
+// has no placeholders.
+func parsePlaceholders(s string) ([]templatePart, error) {
+	if !strings.Contains(s, "${") {
+		return nil, nil
+	}
+	var parts []templatePart
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			if s != "" {
+				parts = append(parts, templatePart{text: s})
+			}
+			return parts, nil
+		}
+		if start > 0 {
+			parts = append(parts, templatePart{text: s[:start]})
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", s)
+		}
+		name := s[start+2 : start+end]
+		if name == "" {
+			return nil, fmt.Errorf("empty placeholder in %q", s)
+		}
+		parts = append(parts, templatePart{name: name})
+		s = s[start+end+1:]
+	}
+}
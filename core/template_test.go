@@ -0,0 +1,95 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// templatePrototype returns a request with placeholders at several depths
+func templatePrototype() *ModelRequest {
+	return &ModelRequest{
+		ModelData: map[string]interface{}{
+			"run":   "${runID}",
+			"label": "run ${runID} of ${suite}",
+			"input": map[string]interface{}{
+				"weights": []interface{}{"${w1}", 0.5, map[string]interface{}{"bias": "${bias}"}},
+				"mode":    "fast",
+			},
+		},
+		Parameters: []Parameter{
+			{Name: "threshold", Type: "float", Value: "${threshold}"},
+			{Name: "verbose", Type: "bool", Value: true},
+		},
+		BlobRefs: []BlobID{"blob-weights"},
+		Metadata: map[string]string{"tenant": "acme"},
+	}
+}
+
+func TestRequestTemplateSubstitution(t *testing.T) {
+	tmpl, err := NewRequestTemplate(templatePrototype())
+	require.NoError(t, err, "Prototype should compile")
+	assert.Equal(t, []string{"bias", "runID", "suite", "threshold", "w1"}, tmpl.Vars(), "Every placeholder should be found")
+
+	req, err := tmpl.Instantiate(map[string]interface{}{
+		"runID":     42,
+		"suite":     "nightly",
+		"w1":        1.25,
+		"bias":      []interface{}{1, 2},
+		"threshold": 0.9,
+	})
+	require.NoError(t, err, "Instantiating with every variable should succeed")
+
+	assert.Equal(t, 42, req.ModelData["run"], "Whole-value placeholder should keep the variable's type")
+	assert.Equal(t, "run 42 of nightly", req.ModelData["label"], "Placeholders within text should be formatted")
+	input := req.ModelData["input"].(map[string]interface{})
+	assert.Equal(t, "fast", input["mode"], "Literal values should be copied")
+	weights := input["weights"].([]interface{})
+	assert.Equal(t, 1.25, weights[0], "Placeholders in slices should be replaced")
+	assert.Equal(t, 0.5, weights[1], "Literal slice elements should be copied")
+	assert.Equal(t, map[string]interface{}{"bias": []interface{}{1, 2}}, weights[2], "Nested placeholders should be replaced")
+	assert.Equal(t, Parameters{
+		{Name: "threshold", Type: "float", Value: 0.9},
+		{Name: "verbose", Type: "bool", Value: true},
+	}, req.Parameters, "Parameter values should be substituted")
+	assert.Equal(t, []BlobID{"blob-weights"}, req.BlobRefs, "Blob references should be copied")
+	assert.Equal(t, map[string]string{"tenant": "acme"}, req.Metadata, "Metadata should be copied")
+}
+
+func TestRequestTemplateCopies(t *testing.T) {
+	tmpl, err := NewRequestTemplate(templatePrototype())
+	require.NoError(t, err, "Prototype should compile")
+	vars := map[string]interface{}{"runID": "a", "suite": "s", "w1": 1, "bias": 0, "threshold": 0.1}
+
+	first, err := tmpl.Instantiate(vars)
+	require.NoError(t, err, "Instantiate should succeed")
+	first.ModelData["input"].(map[string]interface{})["mode"] = "changed"
+	first.ModelData["input"].(map[string]interface{})["weights"].([]interface{})[1] = "changed"
+	first.Metadata["tenant"] = "changed"
+	first.BlobRefs[0] = "changed"
+
+	second, err := tmpl.Instantiate(vars)
+	require.NoError(t, err, "Instantiate should succeed")
+	assert.NotEqual(t, first.ID, second.ID, "Each request should get a fresh ID")
+	assert.Equal(t, "fast", second.ModelData["input"].(map[string]interface{})["mode"], "Requests should not share maps")
+	assert.Equal(t, 0.5, second.ModelData["input"].(map[string]interface{})["weights"].([]interface{})[1], "Requests should not share slices")
+	assert.Equal(t, "acme", second.Metadata["tenant"], "Requests should not share metadata")
+	assert.Equal(t, BlobID("blob-weights"), second.BlobRefs[0], "Requests should not share blob references")
+}
+
+func TestRequestTemplateMissingVars(t *testing.T) {
+	tmpl, err := NewRequestTemplate(templatePrototype())
+	require.NoError(t, err, "Prototype should compile")
+
+	_, err = tmpl.Instantiate(map[string]interface{}{"runID": 1, "suite": "s", "w1": 1})
+	assert.EqualError(t, err, "missing template variables: bias, threshold", "Every missing variable should be named")
+}
+
+func TestRequestTemplateInvalid(t *testing.T) {
+	_, err := NewRequestTemplate(&ModelRequest{ModelData: map[string]interface{}{"run": "${runID"}})
+	assert.Error(t, err, "Unclosed placeholder should be refused")
+
+	_, err = NewRequestTemplate(&ModelRequest{Parameters: []Parameter{{Name: "p", Value: "${}"}}})
+	assert.Error(t, err, "Empty placeholder should be refused")
+}
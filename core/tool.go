@@ -0,0 +1,47 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Method names for tools, named functions a server exposes alongside model
+// processing. Tools take arbitrary JSON arguments and return an arbitrary
+// JSON result.
+const (
+	// MethodListTools returns a ListToolsResponse describing the tools a
+	// server offers.
+	MethodListTools = "mcp.listTools"
+
+	// MethodCallTool runs the tool named by a CallToolRequest and returns its
+	// result.
+	MethodCallTool = "mcp.callTool"
+)
+
+// CodeToolNotFound is the JSON-RPC error code returned for a call to a tool
+// the server does not offer. Clients report it as ErrToolNotFound.
+const CodeToolNotFound int64 = -32011
+
+// ErrToolNotFound is returned for a call to a tool the server does not offer.
+var ErrToolNotFound = errors.New("tool not found")
+
+// ToolInfo describes a tool. InputSchema is the JSON Schema its arguments
+// are expected to match, as given when the tool was registered.
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// ListToolsResponse is the result returned for a MethodListTools call.
+type ListToolsResponse struct {
+	Tools []ToolInfo `json:"tools"`
+}
+
+// CallToolRequest names the tool of a MethodCallTool call and carries its
+// arguments.
+type CallToolRequest struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
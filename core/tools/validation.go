@@ -16,8 +16,8 @@ type ValidationResult struct {
 // ValidationError represents a specific error found during validation.
 // It identifies both the field that failed validation and the reason.
 type ValidationError struct {
-	Field   string // Name of the field that failed validation
-	Message string // Description of why validation failed
+	Field   string `json:"field"`   // Name of the field that failed validation
+	Message string `json:"message"` // Description of why validation failed
 }
 
 // NewValidationResult creates a new, valid validation result with no errors.
@@ -0,0 +1,52 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "context"
+
+// Method names for topic subscriptions. A server publishes some notifications
+// on a topic, sending them only to the connections subscribed to it.
+// Subscriptions belong to the connection, so a client subscribes again after
+// reconnecting.
+const (
+	// MethodSubscribe subscribes the connection to the topic of a
+	// SubscribeRequest. Subscribing again replaces the subscription's filter.
+	MethodSubscribe = "mcp.subscribe"
+
+	// MethodUnsubscribe ends the connection's subscription to the topic of a
+	// SubscribeRequest.
+	MethodUnsubscribe = "mcp.unsubscribe"
+)
+
+// SubscribeRequest names the topic of a MethodSubscribe or MethodUnsubscribe call.
+//
+// A durable subscription outlives the connection: while no connection holds
+// it, the server buffers the notifications published on its topic, up to a
+// limit, and replays them in order, marked with NotificationMeta, when a
+// connection subscribes with the same SubscriptionID.
+type SubscribeRequest struct {
+	Topic          string `json:"topic"`
+	Filter         string `json:"filter,omitempty"`         // Expression the server matches payloads against before sending, see Filter; empty for every notification
+	Durable        bool   `json:"durable,omitempty"`        // Whether the server buffers notifications missed while disconnected
+	SubscriptionID string `json:"subscriptionId,omitempty"` // Client-chosen name of a durable subscription, unique within the server
+}
+
+// NotificationMeta is the meta object of a notification replayed from the
+// buffer of a durable subscription.
+type NotificationMeta struct {
+	Replayed       bool   `json:"replayed"`
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+}
+
+type notificationMetaKey struct{}
+
+// ContextWithNotificationMeta returns a copy of ctx carrying meta.
+func ContextWithNotificationMeta(ctx context.Context, meta NotificationMeta) context.Context {
+	return context.WithValue(ctx, notificationMetaKey{}, meta)
+}
+
+// NotificationMetaFromContext returns the meta of the notification a handler
+// is receiving; its Replayed field tells replayed notifications from live ones.
+func NotificationMetaFromContext(ctx context.Context) NotificationMeta {
+	meta, _ := ctx.Value(notificationMetaKey{}).(NotificationMeta)
+	return meta
+}
@@ -0,0 +1,39 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import "context"
+
+// SpanKind tells a Tracer which side of a round trip a span covers.
+type SpanKind int
+
+const (
+	// SpanClient covers a call from sending the request to receiving the reply.
+	SpanClient SpanKind = iota
+
+	// SpanServer covers the execution of a handler.
+	SpanServer
+)
+
+// Tracer starts spans around requests. The client starts a client span
+// before sending each request and sends the metadata of the returned context
+// along with it, so a Tracer propagates its trace context by adding it to
+// the metadata (see ContextWithMetadata). The server starts a server span
+// with the incoming metadata in ctx and hands the returned context to the
+// handler. The otelmcp package provides an OpenTelemetry implementation.
+type Tracer interface {
+	// StartSpan starts a span for method and returns a context carrying it
+	// and a function that ends it; err is nil if the request succeeded.
+	// requestID is the ID of the model request, or "" for a batch.
+	StartSpan(ctx context.Context, kind SpanKind, method, requestID string) (context.Context, func(err error))
+}
+
+// NopTracer returns a Tracer that records nothing.
+func NopTracer() Tracer {
+	return nopTracer{}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) StartSpan(ctx context.Context, _ SpanKind, _, _ string) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
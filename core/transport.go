@@ -0,0 +1,189 @@
+// Package core provides the fundamental models and interfaces for the Model Context Protocol (MCP).
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+// Transport abstracts the network that carries MCP connections.
+// Servers call Listen and clients call Dial; the address is the configured
+// host:port, which transports without a notion of ports may ignore.
+type Transport interface {
+	// Listen creates a listener accepting client connections.
+	Listen(address string) (net.Listener, error)
+
+	// Dial connects to a server listening on the transport.
+	Dial(ctx context.Context, address string) (net.Conn, error)
+}
+
+// TCPTransport carries connections over TCP. It is the default transport.
+type TCPTransport struct{}
+
+// Listen implements Transport.
+func (TCPTransport) Listen(address string) (net.Listener, error) {
+	return net.Listen("tcp", address)
+}
+
+// Dial implements Transport.
+func (TCPTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// String returns the transport name.
+func (TCPTransport) String() string {
+	return "tcp"
+}
+
+// UnixTransport carries connections over a unix domain socket at Path,
+// avoiding TCP overhead and port management for co-located processes.
+type UnixTransport struct {
+	Path string // Filesystem path of the socket
+}
+
+// Listen implements Transport. A stale socket file left behind by a crashed
+// server is removed first; a socket with a live listener is left alone.
+// The socket file is removed again when the listener is closed.
+func (t UnixTransport) Listen(string) (net.Listener, error) {
+	if err := t.removeStale(); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", t.Path)
+}
+
+// Dial implements Transport.
+func (t UnixTransport) Dial(ctx context.Context, _ string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "unix", t.Path)
+}
+
+// String returns the transport name and socket path.
+func (t UnixTransport) String() string {
+	return "unix:" + t.Path
+}
+
+func (t UnixTransport) removeStale() error {
+	info, err := os.Stat(t.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", t.Path)
+	}
+
+	// A successful dial means another server still owns the socket
+	if conn, err := net.Dial("unix", t.Path); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use", t.Path)
+	}
+	return os.Remove(t.Path)
+}
+
+// errNotListening is returned when dialing an in-process transport without an open listener.
+var errNotListening = errors.New("in-process transport is not listening")
+
+// InProcessTransport connects clients to a server in the same process over
+// net.Pipe, without opening sockets or allocating ports. Pass the same
+// instance to the server and its clients; the address is ignored.
+type InProcessTransport struct {
+	mu       sync.Mutex
+	listener *pipeListener
+}
+
+// NewInProcessTransport creates an in-process transport with no listener.
+func NewInProcessTransport() *InProcessTransport {
+	return &InProcessTransport{}
+}
+
+// Listen implements Transport. Only one listener may be open at a time.
+func (t *InProcessTransport) Listen(string) (net.Listener, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.listener != nil && !t.listener.closed() {
+		return nil, errors.New("in-process transport is already listening")
+	}
+	t.listener = &pipeListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	return t.listener, nil
+}
+
+// Dial implements Transport. It blocks until the listener accepts the connection.
+func (t *InProcessTransport) Dial(ctx context.Context, _ string) (net.Conn, error) {
+	t.mu.Lock()
+	listener := t.listener
+	t.mu.Unlock()
+
+	if listener == nil || listener.closed() {
+		return nil, errNotListening
+	}
+
+	serverConn, clientConn := net.Pipe()
+	select {
+	case listener.conns <- serverConn:
+		return clientConn, nil
+	case <-listener.done:
+		serverConn.Close()
+		clientConn.Close()
+		return nil, errNotListening
+	case <-ctx.Done():
+		serverConn.Close()
+		clientConn.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// String returns the transport name.
+func (t *InProcessTransport) String() string {
+	return "inprocess"
+}
+
+// pipeListener is the net.Listener side of an InProcessTransport.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+func (l *pipeListener) closed() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// pipeAddr is the address of an in-process listener.
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "inprocess" }
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixTransportRemovesStaleSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain sockets are not supported on this platform")
+	}
+
+	path := filepath.Join(t.TempDir(), "mcp.sock")
+
+	// Leave a socket file behind as a crashed server would
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+	assert.FileExists(t, path, "Stale socket file should remain after close")
+
+	transport := UnixTransport{Path: path}
+	listener, err := transport.Listen("")
+	require.NoError(t, err, "Listen should replace a stale socket")
+
+	conn, err := transport.Dial(context.Background(), "")
+	require.NoError(t, err, "Dial should reach the new listener")
+	conn.Close()
+
+	// A live socket must not be taken over by a second listener
+	_, err = transport.Listen("")
+	assert.Error(t, err, "Listen should refuse a socket with a live listener")
+
+	require.NoError(t, listener.Close())
+	assert.NoFileExists(t, path, "Socket file should be removed when the listener closes")
+}
+
+func TestInProcessTransport(t *testing.T) {
+	transport := NewInProcessTransport()
+
+	_, err := transport.Dial(context.Background(), "")
+	assert.Error(t, err, "Dial should fail before Listen")
+
+	listener, err := transport.Listen("")
+	require.NoError(t, err, "Listen should succeed")
+
+	_, err = transport.Listen("")
+	assert.Error(t, err, "A second Listen should fail while the first listener is open")
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4)
+		n, _ := conn.Read(buf)
+		conn.Write(buf[:n])
+	}()
+
+	conn, err := transport.Dial(context.Background(), "")
+	require.NoError(t, err, "Dial should reach the listener")
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf), "Data should round-trip over the pipe")
+	conn.Close()
+
+	require.NoError(t, listener.Close())
+	_, err = listener.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed, "Accept should fail once the listener is closed")
+	_, err = transport.Dial(context.Background(), "")
+	assert.Error(t, err, "Dial should fail once the listener is closed")
+
+	_, err = transport.Listen("")
+	assert.NoError(t, err, "Listen should succeed again after the listener is closed")
+}
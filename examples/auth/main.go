@@ -0,0 +1,136 @@
+// Example authentication setup for the Model Context Protocol (MCP).
+// This demonstrates a server accepting static tokens, HMAC app keys and
+// HS256 JWTs at the same time, with the JWT scheme plugged in through the
+// AuthVerifier hook. Client and server run in one process for brevity.
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+)
+
+// jwtClaims holds the registered claims this example understands.
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Roles     []string `json:"roles"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// jwtVerifier verifies HS256-signed JWTs presented in the "token" credential.
+// A production deployment would verify OIDC tokens against the issuer's keys
+// with the authjwt package.
+type jwtVerifier struct {
+	secret []byte
+}
+
+func (v jwtVerifier) Verify(_ context.Context, credentials map[string]string) (*core.Principal, error) {
+	parts := strings.Split(credentials[core.CredentialToken], ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+
+	principal := &core.Principal{ID: claims.Subject, Roles: claims.Roles, ExpiresAt: time.Unix(claims.ExpiresAt, 0)}
+	if principal.Expired(time.Now()) {
+		return nil, errors.New("token expired")
+	}
+	return principal, nil
+}
+
+// signJWT issues an HS256 token standing in for one from an identity provider.
+func signJWT(secret []byte, claims jwtClaims) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	body, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(body)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func main() {
+	jwtSecret := []byte("identity-provider-secret")
+	appKey := []byte("billing-service-secret")
+	transport := core.NewInProcessTransport()
+
+	// Create a server accepting three auth schemes
+	srv := server.New(server.WithTransport(transport))
+	if err := srv.RegisterHandler(server.NewDefaultModelHandler()); err != nil {
+		log.Fatalf("Failed to register handler: %v", err)
+	}
+	schemes := map[string]server.AuthVerifier{
+		"token": server.NewStaticTokenVerifier(map[string]core.Principal{
+			"ci-secret": {ID: "ci", Roles: []string{"builder"}},
+		}, 0),
+		"hmac": server.NewHMACVerifier(map[string]server.HMACKey{
+			"billing": {Secret: appKey, Principal: core.Principal{ID: "billing-service"}},
+		}, time.Minute, time.Hour),
+		"jwt": jwtVerifier{secret: jwtSecret},
+	}
+	for name, verifier := range schemes {
+		if err := srv.RegisterAuthScheme(name, verifier); err != nil {
+			log.Fatalf("Failed to register auth scheme %s: %v", name, err)
+		}
+	}
+
+	// Start the server
+	if err := srv.Start(); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+	defer srv.Stop()
+
+	// Connect one client per scheme
+	token := signJWT(jwtSecret, jwtClaims{Subject: "alice", Roles: []string{"admin"}, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	clients := []*client.Client{
+		client.New(client.WithTransport(transport), client.WithAuth("token", map[string]string{core.CredentialToken: "ci-secret"})),
+		client.New(client.WithTransport(transport), client.WithAuthProvider("hmac", func(context.Context) (map[string]string, error) {
+			return core.SignHMAC("billing", appKey, time.Now()), nil
+		})),
+		client.New(client.WithTransport(transport), client.WithAuth("jwt", map[string]string{core.CredentialToken: token})),
+	}
+
+	for _, c := range clients {
+		if err := c.Start(); err != nil {
+			log.Fatalf("Failed to start client: %v", err)
+		}
+		log.Printf("Authenticated as %s with roles %v", c.Principal().ID, c.Principal().Roles)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		resp, err := c.ProcessModel(ctx, core.NewModelRequest())
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to process model: %v", err)
+		}
+		log.Printf("Response: %+v", resp)
+
+		if err := c.Stop(); err != nil {
+			log.Fatalf("Failed to stop client: %v", err)
+		}
+	}
+}
@@ -41,11 +41,7 @@ func main() {
 	req := core.NewModelRequest()
 	req.ModelData["name"] = "Test Model"
 	req.ModelData["value"] = 42
-	req.Parameters = append(req.Parameters, core.Parameter{
-		Name:  "param1",
-		Value: "value1",
-		Type:  "string",
-	})
+	req.Parameters = append(req.Parameters, core.StringParam("param1", "value1"))
 
 	// Send the request
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,383 @@
+// Example of the Model Context Protocol (MCP) features working together.
+// The server serves over TLS with a development certificate generated at
+// startup, authenticates clients by token, runs requests as jobs reporting
+// their progress, offers a tool and the files of a directory as resources,
+// bounds the requests it handles at once with a queue for the excess, runs
+// retried requests once, and exposes Prometheus metrics, the admin endpoint
+// and liveness and readiness probes. The client reconnects with backoff after
+// losing the server, retries requests whose connection failed under an
+// idempotency key, watches the progress of the jobs it submits and
+// subscribes to changes to a resource.
+//
+// The wiring lives in functions of its own so that the example's test can
+// exercise the same configuration main runs.
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/mcpprom"
+	"github.com/narcolepticfox/mcp/middleware"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// exampleToken is the token the example's client authenticates with.
+const exampleToken = "example-secret"
+
+// certificates are the files of a development certificate and a pool
+// trusting it, for clients to verify the server with.
+type certificates struct {
+	certPath string
+	keyPath  string
+	pool     *x509.CertPool
+}
+
+// generateCertificates writes a self-signed certificate valid for localhost
+// into dir. It stands in for a certificate issued by a real CA, which clients
+// would trust without being handed a pool.
+func generateCertificates(dir string) (*certificates, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	certs := &certificates{
+		certPath: filepath.Join(dir, "cert.pem"),
+		keyPath:  filepath.Join(dir, "key.pem"),
+		pool:     x509.NewCertPool(),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certs.certPath, certPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write certificate: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certs.keyPath, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key: %w", err)
+	}
+	certs.pool.AppendCertsFromPEM(certPEM)
+	return certs, nil
+}
+
+// wordCountHandler counts the words of the "text" model data, reporting its
+// progress after each of a number of steps. Counting has no side effects, so
+// its requests are safe to retry.
+type wordCountHandler struct {
+	steps     int
+	stepDelay time.Duration
+}
+
+func (h wordCountHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h wordCountHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	text, ok := req.ModelData["text"].(string)
+	if !ok {
+		return nil, core.NewModelError(core.ErrInvalidModel, errors.New("text must be a string"))
+	}
+
+	progress := server.ProgressFromContext(ctx)
+	for step := 1; step <= h.steps; step++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(h.stepDelay):
+		}
+		progress.ReportProgress(float64(step*100/h.steps), fmt.Sprintf("step %d of %d", step, h.steps))
+	}
+
+	resp := core.NewModelResponse(req)
+	resp.Results["words"] = len(strings.Fields(text))
+	return resp, nil
+}
+
+// Limits of the example's server and client.
+const (
+	maxConcurrentRequests = 2  // Requests the server handles at once
+	requestQueueSize      = 2  // Requests waiting for a handler before the server refuses more as busy
+	connectionPoolSize    = 8  // Connections the client spreads its calls over, each carrying one at a time
+	retryAttempts         = 20 // Times the client sends a request again after losing the server
+)
+
+// uppercaseArgs are the arguments of the uppercase tool.
+type uppercaseArgs struct {
+	Text string `json:"text"`
+}
+
+// newServer returns a server listening on addr with TLS, token
+// authentication, metrics and the admin endpoint on an ephemeral port, and
+// health probes on another. It handles a bounded number of requests at once,
+// queueing a few more, serves the files in resourceDir as resources, and
+// runs each idempotency key's request once, keeping the responses in store so
+// a server restarted with it answers the retries of requests it handled
+// before.
+func newServer(addr string, certs *certificates, resourceDir string, store middleware.Store) (*server.Server, error) {
+	collector, err := mcpprom.New(prometheus.NewRegistry())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics collector: %w", err)
+	}
+
+	srv := server.New(
+		server.WithListenAddrs(addr),
+		server.WithTLSFiles(certs.certPath, certs.keyPath),
+		server.WithMetrics(collector),
+		server.WithMetricsAddr("127.0.0.1:0"),
+		server.WithHealthAddr("127.0.0.1:0"),
+		server.WithMaxConcurrentRequests(maxConcurrentRequests),
+		server.WithRequestQueueSize(requestQueueSize),
+		server.WithMiddleware(middleware.Idempotency(store, time.Hour)),
+	)
+	if err := srv.RegisterHandler(wordCountHandler{steps: 4, stepDelay: 50 * time.Millisecond}); err != nil {
+		return nil, fmt.Errorf("failed to register handler: %w", err)
+	}
+
+	schema := &core.Schema{
+		Type:       "object",
+		Properties: map[string]*core.Schema{"text": {Type: "string"}},
+		Required:   []string{"text"},
+	}
+	err = srv.RegisterTool("uppercase", "Converts text to upper case", schema, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var args uppercaseArgs
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, core.NewModelError(core.ErrInvalidParameter, err)
+		}
+		return strings.ToUpper(args.Text), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register tool: %w", err)
+	}
+
+	resources, err := server.NewFileResources(resourceDir, 50*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve resources: %w", err)
+	}
+	if err := srv.RegisterResourceHandler(resources); err != nil {
+		return nil, fmt.Errorf("failed to register resources: %w", err)
+	}
+
+	verifier := server.NewStaticTokenVerifier(map[string]core.Principal{
+		exampleToken: {ID: "example-client", Roles: []string{"analyst"}},
+	}, 0)
+	if err := srv.RegisterAuthScheme(core.AuthSchemeToken, verifier); err != nil {
+		return nil, fmt.Errorf("failed to register auth scheme: %w", err)
+	}
+	return srv, nil
+}
+
+// newClient returns a client for the server at addr, trusting certs and
+// authenticating with token, over a pool of connections. It reconnects after reconnectDelay, doubling
+// the delay with each attempt up to twenty times that, and sends a model
+// request whose connection fails again, up to retryAttempts times, under an
+// idempotency key so the server does not run it twice.
+func newClient(addr string, certs *certificates, token string, reconnectDelay time.Duration) *client.Client {
+	return client.New(
+		client.WithServerAddr(addr),
+		client.WithTLSConfig(&tls.Config{RootCAs: certs.pool, ServerName: "localhost"}),
+		client.WithAuthToken(token),
+		client.WithConnectionPoolSize(connectionPoolSize),
+		client.WithAutoReconnect(true),
+		client.WithReconnectDelay(reconnectDelay),
+		client.WithReconnectBackoff(20*reconnectDelay),
+		client.WithMaxReconnectAttempts(100),
+		client.WithRetry(retryAttempts, reconnectDelay),
+	)
+}
+
+// countWords submits text as a job, logging its progress, and returns the
+// number of words counted.
+func countWords(ctx context.Context, c *client.Client, text string) (int, error) {
+	req := core.NewModelRequest()
+	req.ModelData["text"] = text
+	id, err := c.SubmitModel(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	events, err := c.WatchJob(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	for event := range events {
+		switch {
+		case event.Err != nil:
+			return 0, event.Err
+		case event.Status != nil:
+			if err := event.Status.Response.Err(); err != nil {
+				return 0, err
+			}
+			words, _ := event.Status.Response.Results["words"].(float64)
+			return int(words), nil
+		default:
+			log.Printf("Job %s: %.0f%% (%s)", id, event.Progress.Percent, event.Progress.Message)
+		}
+	}
+	return 0, errors.New("job watch ended without a status")
+}
+
+func main() {
+	dir, err := os.MkdirTemp("", "mcp-full")
+	if err != nil {
+		log.Fatalf("Failed to create working directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certs, err := generateCertificates(dir)
+	if err != nil {
+		log.Fatalf("Failed to generate certificates: %v", err)
+	}
+	resourceDir := filepath.Join(dir, "resources")
+	if err := os.Mkdir(resourceDir, 0o700); err != nil {
+		log.Fatalf("Failed to create resource directory: %v", err)
+	}
+	notes := filepath.Join(resourceDir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("first draft"), 0o600); err != nil {
+		log.Fatalf("Failed to write resource: %v", err)
+	}
+	store := middleware.NewMemoryStore(1000)
+
+	// Start the server
+	srv, err := newServer("127.0.0.1:0", certs, resourceDir, store)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+	addr := srv.Addr().String()
+	log.Printf("Serving on %s, metrics on http://%s/metrics, probes on http://%s/readyz", addr, srv.MetricsAddr(), srv.HealthAddr())
+
+	// A client with the wrong token is turned away
+	intruder := newClient(addr, certs, "wrong-secret", 100*time.Millisecond)
+	if err := intruder.Start(); err != nil {
+		log.Printf("Client with the wrong token was rejected: %v", err)
+	} else {
+		intruder.Stop()
+		log.Fatalf("Client with the wrong token was accepted")
+	}
+
+	// Connect the real client
+	c := newClient(addr, certs, exampleToken, 100*time.Millisecond)
+	if err := c.Start(); err != nil {
+		log.Fatalf("Failed to start client: %v", err)
+	}
+	defer c.Stop()
+	log.Printf("Authenticated as %s", c.Principal().ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Submit a job and follow its progress
+	words, err := countWords(ctx, c, "the quick brown fox jumps over the lazy dog")
+	if err != nil {
+		log.Fatalf("Failed to count words: %v", err)
+	}
+	log.Printf("Counted %d words", words)
+
+	// Call a tool
+	var shouted string
+	if err := c.CallTool(ctx, "uppercase", uppercaseArgs{Text: "hello"}, &shouted); err != nil {
+		log.Fatalf("Failed to call tool: %v", err)
+	}
+	log.Printf("Tool answered %q", shouted)
+
+	// Read a resource and watch it change
+	resources, err := c.ListResources(ctx)
+	if err != nil || len(resources) == 0 {
+		log.Fatalf("Failed to list resources: %v", err)
+	}
+	content, err := c.ReadResource(ctx, resources[0].URI)
+	if err != nil {
+		log.Fatalf("Failed to read resource: %v", err)
+	}
+	log.Printf("Resource %s holds %q", resources[0].Name, content.Text)
+	changed := make(chan struct{}, 1)
+	unsubscribe, err := c.SubscribeResource(ctx, resources[0].URI, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to subscribe to resource: %v", err)
+	}
+	if err := os.WriteFile(notes, []byte("second draft, revised"), 0o600); err != nil {
+		log.Fatalf("Failed to write resource: %v", err)
+	}
+	select {
+	case <-changed:
+		log.Printf("Resource %s changed", resources[0].Name)
+	case <-ctx.Done():
+		log.Fatalf("Resource change was not reported")
+	}
+	unsubscribe()
+
+	// Restart the server; the request is retried until the client reconnects
+	if err := srv.Stop(); err != nil {
+		log.Fatalf("Failed to stop server: %v", err)
+	}
+	log.Printf("Server stopped")
+	srv, err = newServer(addr, certs, resourceDir, store)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+	restarted := make(chan error, 1)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		restarted <- srv.Start()
+	}()
+
+	req := core.NewModelRequest()
+	req.ModelData["text"] = "still here"
+	resp, err := c.ProcessModel(ctx, req)
+	if err != nil {
+		log.Fatalf("Failed to process model after the restart: %v", err)
+	}
+	if err := <-restarted; err != nil {
+		log.Fatalf("Failed to restart server: %v", err)
+	}
+	log.Printf("Response after the restart: %+v", resp.Results)
+
+	if err := srv.Stop(); err != nil {
+		log.Fatalf("Failed to stop server: %v", err)
+	}
+}
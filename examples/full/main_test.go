@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/middleware"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// example is the example's server with what it was started from
+type example struct {
+	srv         *server.Server
+	certs       *certificates
+	resourceDir string
+	store       middleware.Store
+}
+
+// startExample starts the example's server on an ephemeral port, serving a
+// notes.txt resource
+func startExample(t *testing.T) *example {
+	certs, err := generateCertificates(t.TempDir())
+	require.NoError(t, err, "Certificates should be generated")
+	ex := &example{certs: certs, resourceDir: t.TempDir(), store: middleware.NewMemoryStore(100)}
+	require.NoError(t, os.WriteFile(filepath.Join(ex.resourceDir, "notes.txt"), []byte("first draft"), 0o600), "Resource should be written")
+
+	ex.srv, err = newServer("127.0.0.1:0", certs, ex.resourceDir, ex.store)
+	require.NoError(t, err, "Server should be created")
+	require.NoError(t, ex.srv.Start(), "Server should start")
+	t.Cleanup(func() { ex.srv.Stop() })
+	return ex
+}
+
+func TestExampleRejectsWrongToken(t *testing.T) {
+	ex := startExample(t)
+
+	c := newClient(ex.srv.Addr().String(), ex.certs, "wrong-secret", 50*time.Millisecond)
+	err := c.Start()
+	require.Error(t, err, "Client with the wrong token should be rejected")
+	assert.Contains(t, err.Error(), "authentication failed", "Error should report the authentication failure")
+	assert.Equal(t, core.StatusFailed, c.Status(), "Rejected client should be failed")
+}
+
+func TestExampleJobProgress(t *testing.T) {
+	ex := startExample(t)
+	c := newClient(ex.srv.Addr().String(), ex.certs, exampleToken, 50*time.Millisecond)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	assert.Equal(t, "example-client", c.Principal().ID, "Client should be authenticated")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := core.NewModelRequest()
+	req.ModelData["text"] = "one two three"
+	id, err := c.SubmitModel(ctx, req)
+	require.NoError(t, err, "Job should be submitted")
+	events, err := c.WatchJob(ctx, id)
+	require.NoError(t, err, "Job should be watched")
+
+	var percents []float64
+	var status *core.JobStatus
+	for event := range events {
+		require.NoError(t, event.Err, "Watch should not fail")
+		if event.Status != nil {
+			status = event.Status
+			break
+		}
+		percents = append(percents, event.Progress.Percent)
+	}
+	require.NotNil(t, status, "Watch should end with the final status")
+	assert.Equal(t, core.JobDone, status.State, "Job should be done")
+	assert.Equal(t, float64(3), status.Response.Results["words"], "Job should count the words")
+	assert.IsIncreasing(t, percents, "Progress should only advance")
+	require.NotEmpty(t, percents, "Progress should be reported before the job finishes")
+	assert.Greater(t, percents[len(percents)-1], float64(0), "Reported progress should advance")
+}
+
+func TestExampleTool(t *testing.T) {
+	ex := startExample(t)
+	c := newClient(ex.srv.Addr().String(), ex.certs, exampleToken, 50*time.Millisecond)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tools, err := c.ListTools(ctx)
+	require.NoError(t, err, "Tools should be listed")
+	require.Len(t, tools, 1, "The example should offer one tool")
+	assert.Equal(t, "uppercase", tools[0].Name, "The tool should be listed by name")
+	assert.NotEmpty(t, tools[0].InputSchema, "The tool should publish its input schema")
+
+	var shouted string
+	require.NoError(t, c.CallTool(ctx, "uppercase", uppercaseArgs{Text: "hello"}, &shouted), "Tool should be called")
+	assert.Equal(t, "HELLO", shouted, "Tool should convert the text")
+}
+
+func TestExampleResources(t *testing.T) {
+	ex := startExample(t)
+	c := newClient(ex.srv.Addr().String(), ex.certs, exampleToken, 50*time.Millisecond)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resources, err := c.ListResources(ctx)
+	require.NoError(t, err, "Resources should be listed")
+	require.Len(t, resources, 1, "The resource directory's file should be listed")
+	assert.Equal(t, "notes.txt", resources[0].Name, "The resource should be named by its path")
+	content, err := c.ReadResource(ctx, resources[0].URI)
+	require.NoError(t, err, "Resource should be read")
+	assert.Equal(t, "first draft", content.Text, "Resource should hold the file's text")
+
+	changed := make(chan struct{}, 1)
+	unsubscribe, err := c.SubscribeResource(ctx, resources[0].URI, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	require.NoError(t, err, "Resource should be subscribed to")
+	defer unsubscribe()
+	require.NoError(t, os.WriteFile(filepath.Join(ex.resourceDir, "notes.txt"), []byte("second draft, revised"), 0o600), "Resource should be rewritten")
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		t.Fatal("The change to the resource should be reported")
+	}
+	content, err = c.ReadResource(ctx, resources[0].URI)
+	require.NoError(t, err, "Resource should be read again")
+	assert.Equal(t, "second draft, revised", content.Text, "Resource should hold the new text")
+}
+
+func TestExampleRequestQueue(t *testing.T) {
+	ex := startExample(t)
+	c := newClient(ex.srv.Addr().String(), ex.certs, exampleToken, 50*time.Millisecond)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// More requests than the server handles and queues arrive at once, one
+	// on each pooled connection, each taking a while to handle
+	const requests = connectionPoolSize
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var handled, busy int
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := core.NewModelRequest()
+			req.ModelData["text"] = "queued request"
+			_, err := c.ProcessModel(ctx, req)
+			mu.Lock()
+			defer mu.Unlock()
+			var rpcErr *jsonrpc2.Error
+			switch {
+			case err == nil:
+				handled++
+			case errors.As(err, &rpcErr) && rpcErr.Code == core.CodeServerBusy:
+				busy++
+			default:
+				t.Errorf("Request should be handled or refused as busy, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.GreaterOrEqual(t, handled, maxConcurrentRequests+requestQueueSize, "Requests within the limit and the queue should be handled")
+	assert.Positive(t, busy, "Requests beyond the queue should be refused as busy")
+	assert.Equal(t, requests, handled+busy, "Every request should be answered")
+}
+
+func TestExampleIdempotentRetries(t *testing.T) {
+	ex := startExample(t)
+	c := newClient(ex.srv.Addr().String(), ex.certs, exampleToken, 50*time.Millisecond)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Equal(t, retryAttempts, c.Options().RetryAttempts, "Client should retry requests")
+
+	// A retry carries its request's idempotency key, so the server answers it
+	// with the response it kept instead of running the handler again
+	req := core.NewModelRequest()
+	req.ModelData["text"] = "run me once"
+	req.Metadata = map[string]string{core.MetadataIdempotencyKey: "example-key"}
+	first, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should succeed")
+	retried, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Retry should succeed")
+	assert.Equal(t, first.Timestamp, retried.Timestamp, "Retry should get the kept response, not a new one")
+}
+
+func TestExampleAdminEndpoints(t *testing.T) {
+	ex := startExample(t)
+
+	for _, url := range []string{
+		fmt.Sprintf("http://%s/metrics", ex.srv.MetricsAddr()),
+		fmt.Sprintf("http://%s/stats", ex.srv.MetricsAddr()),
+		fmt.Sprintf("http://%s/readyz", ex.srv.HealthAddr()),
+	} {
+		resp, err := http.Get(url)
+		require.NoError(t, err, "%s should be served", url)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "%s should succeed", url)
+	}
+}
+
+func TestExampleReconnectAcrossRestart(t *testing.T) {
+	ex := startExample(t)
+	addr := ex.srv.Addr().String()
+	c := newClient(addr, ex.certs, exampleToken, 50*time.Millisecond)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	assert.Equal(t, 20*50*time.Millisecond, c.Options().MaxReconnectDelay, "Client should back off between reconnection attempts")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req := core.NewModelRequest()
+	req.ModelData["text"] = "before the restart"
+	_, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should succeed before the restart")
+
+	// Replace the server on the same address while a request is retried
+	require.NoError(t, ex.srv.Stop(), "Server should stop")
+	restarted, err := newServer(addr, ex.certs, ex.resourceDir, ex.store)
+	require.NoError(t, err, "Replacement server should be created")
+	started := make(chan error, 1)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		started <- restarted.Start()
+	}()
+	defer restarted.Stop()
+
+	req = core.NewModelRequest()
+	req.ModelData["text"] = "after the restart"
+	resp, err := c.ProcessModel(ctx, req)
+	require.NoError(t, <-started, "Replacement server should start")
+	require.NoError(t, err, "Request should be retried until the client reconnects")
+	assert.Equal(t, float64(3), resp.Results["words"], "Replacement server should answer")
+	assert.True(t, c.IsConnected(), "Client should be reconnected")
+}
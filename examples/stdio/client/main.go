@@ -0,0 +1,55 @@
+// Example stdio client for the Model Context Protocol (MCP).
+// This demonstrates spawning a server as a subprocess and sending
+// model processing requests over its standard input and output.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+)
+
+func main() {
+	// The server command defaults to the stdio example server
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"go", "run", "./examples/stdio/server"}
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+
+	// Spawn the server, restarting it if it exits
+	c := client.NewStdioClient(cmd, client.WithAutoReconnect(true))
+
+	// Start the client
+	if err := c.Start(); err != nil {
+		log.Fatalf("Failed to start client: %v", err)
+	}
+
+	// Create a model request
+	req := core.NewModelRequest()
+	req.ModelData["name"] = "Test Model"
+	req.ModelData["value"] = 42
+
+	// Send the request
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, req)
+	if err != nil {
+		log.Fatalf("Failed to process model: %v", err)
+	}
+
+	log.Printf("Response: %+v", resp)
+
+	// Stop the client, which also stops the server process
+	if err := c.Stop(); err != nil {
+		log.Fatalf("Failed to stop client: %v", err)
+	}
+}
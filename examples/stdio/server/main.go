@@ -0,0 +1,35 @@
+// Example stdio server for the Model Context Protocol (MCP).
+// This demonstrates a server meant to be spawned as a subprocess,
+// speaking JSON-RPC over its standard input and output.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/narcolepticfox/mcp/server"
+)
+
+func main() {
+	// Create a server; no network options are needed for stdio. Subprocess
+	// mode finishes requests in flight when a parent server shuts us down.
+	srv := server.New(server.WithSubprocessMode(true))
+
+	// Register the default model handler
+	handler := server.NewDefaultModelHandler()
+	if err := srv.RegisterHandler(handler); err != nil {
+		log.Fatalf("Failed to register handler: %v", err)
+	}
+
+	// Stop serving on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Serve until the parent closes our stdin; logs go to stderr
+	if err := srv.ServeStdio(ctx); err != nil && err != context.Canceled {
+		log.Fatalf("Failed to serve stdio: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"bufio"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modulePath returns the module path declared by go.mod.
+func modulePath(t *testing.T) string {
+	t.Helper()
+	f, err := os.Open("go.mod")
+	require.NoError(t, err, "go.mod should be readable")
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`)
+		}
+	}
+	require.NoError(t, scanner.Err(), "go.mod should be readable")
+	t.Fatal("go.mod should declare the module path")
+	return ""
+}
+
+// foreignImport reports whether importPath names one of the module's
+// packages, given by their directories, under another module path, such as
+// github.com/yourorg/mcp/core for github.com/narcolepticfox/mcp/core, and
+// returns the path it should have.
+func foreignImport(module, importPath string, packages map[string]bool) (string, bool) {
+	if importPath == module || strings.HasPrefix(importPath, module+"/") {
+		return "", false
+	}
+	for dir := range packages {
+		if strings.HasSuffix(importPath, "/"+path.Base(module)+"/"+dir) {
+			return module + "/" + dir, true
+		}
+	}
+	return "", false
+}
+
+func TestForeignImport(t *testing.T) {
+	const module = "github.com/narcolepticfox/mcp"
+	packages := map[string]bool{"core": true, "server": true, "examples/stdio/server": true}
+
+	for importPath, want := range map[string]string{
+		"github.com/yourorg/mcp/core":                  module + "/core",
+		"github.com/yourorg/mcp/examples/stdio/server": module + "/examples/stdio/server",
+		"example.com/mcp/server":                       module + "/server",
+	} {
+		got, foreign := foreignImport(module, importPath, packages)
+		assert.True(t, foreign, "%s should be a foreign import", importPath)
+		assert.Equal(t, want, got, "%s should be corrected to the module's package", importPath)
+	}
+	for _, importPath := range []string{
+		module + "/core",
+		"github.com/sourcegraph/jsonrpc2",
+		"github.com/other/server",
+		"github.com/other/notmcp/core",
+		"net/http",
+	} {
+		_, foreign := foreignImport(module, importPath, packages)
+		assert.False(t, foreign, "%s should not be a foreign import", importPath)
+	}
+}
+
+// TestImportPaths fails if any file imports one of the module's packages
+// under another module path, as files copied from templates have done. Every
+// .go file is checked, test files and those under testdata included, as
+// templates are copied into those too; only hidden directories such as .git
+// are skipped.
+func TestImportPaths(t *testing.T) {
+	module := modulePath(t)
+
+	var files []string
+	packages := make(map[string]bool)
+	err := filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != "." && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(p, ".go") {
+			files = append(files, p)
+			if dir := filepath.ToSlash(filepath.Dir(p)); dir != "." {
+				packages[dir] = true
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err, "The module's source should be readable")
+	require.NotEmpty(t, files, "The module should have source files")
+
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+		require.NoError(t, err, "%s should parse", file)
+		for _, spec := range f.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			require.NoError(t, err, "%s should have valid import paths", file)
+			if want, foreign := foreignImport(module, importPath, packages); foreign {
+				t.Errorf("%s imports %q, which should be %q", fset.Position(spec.Pos()), importPath, want)
+			}
+		}
+	}
+}
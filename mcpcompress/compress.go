@@ -0,0 +1,122 @@
+// Package mcpcompress registers the zstd and snappy compression algorithms
+// with core.RegisterCompressor, so clients and servers can negotiate them
+// besides gzip. It is kept separate from core so that only programs using
+// these algorithms depend on their implementation. Importing the package
+// registers both:
+//
+//	srv := server.New(server.WithCompression(mcpcompress.Zstd, core.CompressionGzip))
+//	c := client.New(client.WithCompression(mcpcompress.Zstd, mcpcompress.Snappy, core.CompressionGzip))
+//
+// zstd at its fastest level compresses about as well as gzip for a fraction
+// of the CPU time; snappy compresses less but faster still.
+package mcpcompress
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Algorithms registered by this package.
+const (
+	// Zstd compresses message bodies with Zstandard at its fastest level.
+	Zstd core.Compression = "zstd"
+
+	// Snappy compresses message bodies in the snappy framing format.
+	Snappy core.Compression = "snappy"
+)
+
+func init() {
+	core.RegisterCompressor(Zstd, zstdCompressor{})
+	core.RegisterCompressor(Snappy, snappyCompressor{})
+}
+
+// zstdEncoder compresses whole messages; EncodeAll may be called
+// concurrently.
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+
+// zstdDecoders reuses stream decoders across messages. With a concurrency of
+// one they decode on the reading goroutine and start none of their own.
+var zstdDecoders = sync.Pool{
+	New: func() interface{} {
+		d, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		return d
+	},
+}
+
+// zstdCompressor implements Zstd.
+type zstdCompressor struct{}
+
+// Compress implements core.Compressor.
+func (zstdCompressor) Compress(data []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/4)), nil
+}
+
+// NewReader implements core.Compressor.
+func (zstdCompressor) NewReader(data []byte) (io.ReadCloser, error) {
+	d := zstdDecoders.Get().(*zstd.Decoder)
+	if err := d.Reset(bytes.NewReader(data)); err != nil {
+		zstdDecoders.Put(d)
+		return nil, err
+	}
+	return &pooledReader{Reader: d, pool: &zstdDecoders, item: d}, nil
+}
+
+// snappyWriters and snappyReaders reuse stream encoders and decoders across
+// messages.
+var (
+	snappyWriters = sync.Pool{
+		New: func() interface{} {
+			return s2.NewWriter(nil, s2.WriterSnappyCompat(), s2.WriterConcurrency(1))
+		},
+	}
+	snappyReaders = sync.Pool{
+		New: func() interface{} {
+			return s2.NewReader(nil)
+		},
+	}
+)
+
+// snappyCompressor implements Snappy.
+type snappyCompressor struct{}
+
+// Compress implements core.Compressor.
+func (snappyCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 2)
+	w := snappyWriters.Get().(*s2.Writer)
+	defer snappyWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewReader implements core.Compressor.
+func (snappyCompressor) NewReader(data []byte) (io.ReadCloser, error) {
+	r := snappyReaders.Get().(*s2.Reader)
+	r.Reset(bytes.NewReader(data))
+	return &pooledReader{Reader: r, pool: &snappyReaders, item: r}, nil
+}
+
+// pooledReader returns a decoder to its pool when closed.
+type pooledReader struct {
+	io.Reader
+	pool *sync.Pool
+	item interface{}
+	once sync.Once
+}
+
+// Close implements io.Closer.
+func (r *pooledReader) Close() error {
+	r.once.Do(func() { r.pool.Put(r.item) })
+	return nil
+}
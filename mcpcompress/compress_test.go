@@ -0,0 +1,169 @@
+package mcpcompress
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoHandler returns the payload of each request in its response
+type echoHandler struct{}
+
+func (h *echoHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *echoHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	resp.Results["payload"] = req.ModelData["payload"]
+	return resp, nil
+}
+
+// startServer starts a server echoing payloads with the given compression
+func startServer(t *testing.T, algorithms ...core.Compression) (*server.Server, *core.InProcessTransport) {
+	transport := core.NewInProcessTransport()
+	srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()), server.WithCompression(algorithms...))
+	require.NoError(t, srv.RegisterHandler(&echoHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	t.Cleanup(func() { srv.Stop() })
+	return srv, transport
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	msg := map[string]string{"name": strings.Repeat("compressible ", 1000)}
+	for _, algorithm := range []core.Compression{Zstd, Snappy} {
+		algorithm := algorithm
+		t.Run(string(algorithm), func(t *testing.T) {
+			require.True(t, algorithm.Supported(), "Algorithm should be registered")
+			codec := core.FrameCodec{Compression: algorithm}
+
+			var buf bytes.Buffer
+			require.NoError(t, codec.WriteObject(&buf, msg), "Writing a compressed frame should succeed")
+			assert.Contains(t, buf.String(), fmt.Sprintf("Content-Encoding: %s\r\n", algorithm), "Frame should name its compression")
+			assert.Less(t, buf.Len(), len(msg["name"])/4, "Compressed frame should be far smaller than its body")
+
+			var got map[string]string
+			require.NoError(t, codec.ReadObject(bufio.NewReader(&buf), &got), "Reading a compressed frame should succeed")
+			assert.Equal(t, msg, got, "Compressed frame should round trip")
+		})
+	}
+}
+
+func TestFrameSizeLimit(t *testing.T) {
+	for _, algorithm := range []core.Compression{Zstd, Snappy} {
+		algorithm := algorithm
+		t.Run(string(algorithm), func(t *testing.T) {
+			req := &jsonrpc2.Request{Method: core.MethodProcessModel, ID: jsonrpc2.ID{Num: 7}}
+			require.NoError(t, req.SetParams(map[string]string{"payload": strings.Repeat("x", 1<<18)}), "Params should encode")
+
+			var buf bytes.Buffer
+			require.NoError(t, core.FrameCodec{Compression: algorithm}.WriteObject(&buf, req), "Writing a compressed frame should succeed")
+			var got jsonrpc2.Request
+			err := core.FrameCodec{MaxBytes: 1 << 16}.ReadObject(bufio.NewReader(&buf), &got)
+			var tooLarge *core.FrameTooLargeError
+			require.True(t, errors.As(err, &tooLarge), "Body expanding past the limit should be refused")
+			require.NotNil(t, tooLarge.ID, "Refusal should name the request")
+			assert.Equal(t, req.ID, *tooLarge.ID, "Refusal should name the request")
+		})
+	}
+}
+
+func TestFrameCorruptBody(t *testing.T) {
+	for _, algorithm := range []core.Compression{Zstd, Snappy} {
+		frame := fmt.Sprintf("Content-Length: 8\r\nContent-Encoding: %s\r\n\r\nnotvalid", algorithm)
+		var got map[string]string
+		err := core.FrameCodec{}.ReadObject(bufio.NewReader(strings.NewReader(frame)), &got)
+		assert.ErrorContains(t, err, fmt.Sprintf("invalid %s body", algorithm), "Corrupt %s body should be rejected", algorithm)
+	}
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   []core.Compression
+		client   []core.Compression
+		expected core.Compression
+	}{
+		{"BothPreferZstd", []core.Compression{Zstd, core.CompressionGzip}, []core.Compression{Zstd, core.CompressionGzip}, Zstd},
+		{"ClientOrderWins", []core.Compression{Snappy, Zstd}, []core.Compression{Zstd, Snappy}, Zstd},
+		{"FallBackToGzip", []core.Compression{core.CompressionGzip}, []core.Compression{Zstd, Snappy, core.CompressionGzip}, core.CompressionGzip},
+		{"UnknownNameSkipped", []core.Compression{"br", Snappy}, []core.Compression{"br", Snappy}, Snappy},
+		{"NothingInCommon", []core.Compression{Zstd}, []core.Compression{Snappy, core.CompressionGzip}, core.CompressionNone},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv, transport := startServer(t, tt.server...)
+			c := client.New(client.WithTransport(transport), client.WithLogger(core.NopLogger()),
+				client.WithAutoReconnect(false), client.WithCompression(tt.client...))
+			require.NoError(t, c.Start(), "Client should connect")
+			defer c.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			payload := strings.Repeat(`{"name":"compressible","values":[1,2,3]}`, 1<<12)
+			req := core.NewModelRequest()
+			req.ModelData["payload"] = payload
+			resp, err := c.ProcessModel(ctx, req)
+			require.NoError(t, err, "ProcessModel should succeed")
+			assert.Equal(t, payload, resp.Results["payload"], "Payload should round trip")
+
+			assert.Equal(t, tt.expected, c.ConnectionState().Compression, "Client should report the negotiated compression")
+			require.Len(t, srv.Connections(), 1, "Server should list the connection")
+			assert.Equal(t, tt.expected, srv.Connections()[0].Compression, "Server should report the negotiated compression")
+		})
+	}
+}
+
+func TestUndecodableFrameClosesConnection(t *testing.T) {
+	srv, transport := startServer(t, Zstd)
+	conn, err := transport.Dial(context.Background(), "")
+	require.NoError(t, err, "Dial should succeed")
+	defer conn.Close()
+
+	// The peer negotiates zstd by hand...
+	codec := jsonrpc2.VSCodeObjectCodec{}
+	negotiate := &jsonrpc2.Request{Method: core.MethodNegotiate, ID: jsonrpc2.ID{Num: 1}}
+	require.NoError(t, negotiate.SetParams(core.NegotiateRequest{Compression: []core.Compression{Zstd}}), "Params should encode")
+	require.NoError(t, codec.WriteObject(conn, negotiate), "Negotiation should be sent")
+	r := bufio.NewReader(conn)
+	var reply struct {
+		Result core.NegotiateResponse `json:"result"`
+	}
+	require.NoError(t, codec.ReadObject(r, &reply), "Negotiation should be answered")
+	require.Equal(t, Zstd, reply.Result.Compression, "Server should choose zstd")
+
+	// ...then sends a body it claims is zstd but is not
+	_, err = io.WriteString(conn, "Content-Length: 8\r\nContent-Encoding: zstd\r\n\r\nnotvalid")
+	require.NoError(t, err, "Frame should be sent")
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = io.ReadAll(r)
+	assert.NoError(t, err, "Server should close the connection rather than leave it hanging")
+	assert.Eventually(t, func() bool {
+		return len(srv.Connections()) == 0
+	}, 2*time.Second, 10*time.Millisecond, "Server should drop the connection")
+
+	// Other clients are unaffected
+	c := client.New(client.WithTransport(transport), client.WithLogger(core.NopLogger()),
+		client.WithAutoReconnect(false), client.WithCompression(Zstd))
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = c.ProcessModel(ctx, core.NewModelRequest())
+	assert.NoError(t, err, "Other clients should still be served")
+}
@@ -0,0 +1,217 @@
+// Package mcpprom exports MCP client and server metrics to Prometheus. It is
+// kept separate from the metrics package so that only programs using
+// Prometheus depend on its client library.
+package mcpprom
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Options holds configuration parameters for a Collector.
+type Options struct {
+	Namespace string    // Prefix of every metric name
+	Subsystem string    // Optional second name component, e.g. "client" or "server"
+	Buckets   []float64 // Upper bounds in seconds of the latency histogram buckets
+}
+
+// DefaultOptions returns the default collector options.
+func DefaultOptions() Options {
+	return Options{
+		Namespace: "mcp",
+		Buckets:   prometheus.DefBuckets,
+	}
+}
+
+// Option is a function type that modifies Options.
+type Option func(*Options)
+
+// WithNamespace sets the prefix of every metric name.
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.Namespace = namespace
+	}
+}
+
+// WithSubsystem adds a second component to every metric name, so that a client
+// and a server in the same process can share a registry.
+func WithSubsystem(subsystem string) Option {
+	return func(o *Options) {
+		o.Subsystem = subsystem
+	}
+}
+
+// WithBuckets sets the latency histogram buckets, in seconds.
+func WithBuckets(buckets []float64) Option {
+	return func(o *Options) {
+		o.Buckets = buckets
+	}
+}
+
+// Collector records MCP metrics as Prometheus series. It implements
+// core.MetricsCollector, so it can be passed to server.WithMetrics or
+// client.WithMetrics, and http.Handler, serving the registry it was created
+// with in the Prometheus exposition format.
+//
+// With the default options it registers:
+//
+//	mcp_requests_total{method,status}           counter, status is "ok" or "error"
+//	mcp_request_duration_seconds{method}        histogram
+//	mcp_payload_bytes_total{method,direction}   counter, direction is "in" or "out"
+//	mcp_active_connections                      gauge
+//	mcp_connection_stalls_total                 counter
+//	mcp_requests_cancelled_total{method,cause}  counter, cause is a core.Cause
+//	mcp_status{status}                          gauge, 1 for the current status of tracked components
+type Collector struct {
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	payload     *prometheus.CounterVec
+	connections prometheus.Gauge
+	stalls      prometheus.Counter
+	cancelled   *prometheus.CounterVec
+	status      *prometheus.GaugeVec
+	handler     http.Handler
+
+	statusMu sync.Mutex
+}
+
+// New creates a collector and registers its metrics with reg. If reg is also a
+// prometheus.Gatherer, such as a *prometheus.Registry, the collector serves it;
+// otherwise it serves prometheus.DefaultGatherer.
+func New(reg prometheus.Registerer, options ...Option) (*Collector, error) {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	c := &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "requests_total",
+			Help:      "MCP requests by method and outcome.",
+		}, []string{"method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Time to handle an MCP request.",
+			Buckets:   opts.Buckets,
+		}, []string{"method"}),
+		payload: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "payload_bytes_total",
+			Help:      "Encoded JSON bytes of MCP requests and replies.",
+		}, []string{"method", "direction"}),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "active_connections",
+			Help:      "Open MCP connections.",
+		}),
+		stalls: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "connection_stalls_total",
+			Help:      "MCP connections whose peer stopped sending partway through a frame.",
+		}),
+		cancelled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "requests_cancelled_total",
+			Help:      "MCP requests cancelled before they completed, by method and cause.",
+		}, []string{"method", "cause"}),
+		status: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "status",
+			Help:      "Status of the tracked MCP component; 1 for the current status.",
+		}, []string{"status"}),
+	}
+
+	for _, collector := range []prometheus.Collector{c.requests, c.duration, c.payload, c.connections, c.stalls, c.cancelled, c.status} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	gatherer, ok := reg.(prometheus.Gatherer)
+	if !ok {
+		gatherer = prometheus.DefaultGatherer
+	}
+	c.handler = promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	return c, nil
+}
+
+// ConnectionOpened implements core.MetricsCollector.
+func (c *Collector) ConnectionOpened(string) {
+	c.connections.Inc()
+}
+
+// ConnectionClosed implements core.MetricsCollector.
+func (c *Collector) ConnectionClosed(string) {
+	c.connections.Dec()
+}
+
+// ConnectionStalled implements core.StallCollector.
+func (c *Collector) ConnectionStalled(string, time.Duration) {
+	c.stalls.Inc()
+}
+
+// RequestCancelled implements core.CancelCollector.
+func (c *Collector) RequestCancelled(method string, cause core.Cause) {
+	c.cancelled.WithLabelValues(method, string(cause)).Inc()
+}
+
+// RequestStarted implements core.MetricsCollector. Requests are counted when
+// they complete, labelled with their outcome.
+func (c *Collector) RequestStarted(string) {}
+
+// RequestCompleted implements core.MetricsCollector.
+func (c *Collector) RequestCompleted(method string, duration time.Duration, success bool) {
+	status := "ok"
+	if !success {
+		status = "error"
+	}
+	c.requests.WithLabelValues(method, status).Inc()
+	c.duration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// PayloadSize implements core.MetricsCollector.
+func (c *Collector) PayloadSize(method string, bytesIn, bytesOut int) {
+	c.payload.WithLabelValues(method, "in").Add(float64(bytesIn))
+	c.payload.WithLabelValues(method, "out").Add(float64(bytesOut))
+}
+
+// TrackStatus reports the status of component, usually the server the
+// collector is attached to, in the status gauge.
+func (c *Collector) TrackStatus(component core.Component) {
+	c.setStatus(component.Status())
+	component.OnStatusChange(func(core.StatusChangeEvent) {
+		// Events may be dropped if callbacks fall behind, so read the status afresh
+		c.setStatus(component.Status())
+	})
+}
+
+func (c *Collector) setStatus(current core.Status) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	for status := core.StatusStopped; status <= core.StatusFailed; status++ {
+		value := 0.0
+		if status == current {
+			value = 1
+		}
+		c.status.WithLabelValues(status.String()).Set(value)
+	}
+}
+
+// ServeHTTP serves the collector's registry in the Prometheus exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.handler.ServeHTTP(w, r)
+}
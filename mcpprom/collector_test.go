@@ -0,0 +1,114 @@
+package mcpprom
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape fetches and parses the metrics served at url
+func scrape(t *testing.T, url string) map[string]*dto.MetricFamily {
+	resp, err := http.Get(url)
+	require.NoError(t, err, "Metrics endpoint should be reachable")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "Metrics endpoint should succeed")
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	require.NoError(t, err, "Metrics should be in the Prometheus text format")
+	return families
+}
+
+// series returns the metric in family whose labels match
+func series(t *testing.T, family *dto.MetricFamily, labels map[string]string) *dto.Metric {
+	require.NotNil(t, family, "Metric family should exist")
+	for _, metric := range family.GetMetric() {
+		matched := 0
+		for _, pair := range metric.GetLabel() {
+			if labels[pair.GetName()] == pair.GetValue() {
+				matched++
+			}
+		}
+		if matched == len(labels) {
+			return metric
+		}
+	}
+	t.Fatalf("no %s series with labels %v", family.GetName(), labels)
+	return nil
+}
+
+func TestCollectorServesMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	serverMetrics, err := New(registry)
+	require.NoError(t, err, "Server collector should register")
+	clientMetrics, err := New(registry, WithSubsystem("client"))
+	require.NoError(t, err, "Client collector should register alongside the server")
+
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithMetrics(clientMetrics))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithMetrics(serverMetrics), server.WithMetricsAddr("127.0.0.1:0"))
+			require.NoError(t, srv.RegisterHandler(&testutil.FlakyModelHandler{}), "Handler registration should succeed")
+			serverMetrics.TrackStatus(srv)
+			return srv
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "ProcessModel should succeed")
+	}
+	failing := testutil.CreateTestModelRequest()
+	failing.ModelData["fail"] = true
+	_, err = c.ProcessModel(ctx, failing)
+	require.Error(t, err, "Failing request should return an error")
+
+	require.NotNil(t, srv.MetricsAddr(), "Metrics listener should be running")
+	url := "http://" + srv.MetricsAddr().String() + "/metrics"
+
+	// The server records each request just after replying
+	var families map[string]*dto.MetricFamily
+	require.Eventually(t, func() bool {
+		families = scrape(t, url)
+		family := families["mcp_request_duration_seconds"]
+		return family != nil && len(family.GetMetric()) == 1 && family.GetMetric()[0].GetHistogram().GetSampleCount() == 4
+	}, time.Second, 10*time.Millisecond, "Server should time every request")
+
+	ok := map[string]string{"method": core.MethodProcessModel, "status": "ok"}
+	failed := map[string]string{"method": core.MethodProcessModel, "status": "error"}
+	assert.Equal(t, 3.0, series(t, families["mcp_requests_total"], ok).GetCounter().GetValue(), "Successful requests should be counted")
+	assert.Equal(t, 1.0, series(t, families["mcp_requests_total"], failed).GetCounter().GetValue(), "Failed requests should be counted")
+	assert.Equal(t, 1.0, series(t, families["mcp_active_connections"], nil).GetGauge().GetValue(), "One connection should be open")
+	assert.Equal(t, 0.0, series(t, families["mcp_connection_stalls_total"], nil).GetCounter().GetValue(), "No connection should have stalled")
+	assert.Equal(t, 1.0, series(t, families["mcp_status"], map[string]string{"status": "Running"}).GetGauge().GetValue(), "Server should be reported running")
+	assert.Equal(t, 0.0, series(t, families["mcp_status"], map[string]string{"status": "Stopped"}).GetGauge().GetValue(), "Only the current status should be set")
+	assert.Positive(t, series(t, families["mcp_payload_bytes_total"], map[string]string{"direction": "in"}).GetCounter().GetValue(), "Request bytes should be counted")
+
+	// The client's series share the registry under their own subsystem
+	assert.Equal(t, 3.0, series(t, families["mcp_client_requests_total"], ok).GetCounter().GetValue(), "Client should count its calls")
+	assert.Equal(t, uint64(4), series(t, families["mcp_client_request_duration_seconds"], nil).GetHistogram().GetSampleCount(), "Client should time its calls")
+}
+
+func TestCollectorDuplicateRegistration(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	_, err := New(registry)
+	require.NoError(t, err, "First collector should register")
+
+	_, err = New(registry)
+	assert.Error(t, err, "Second collector with the same names should be rejected")
+}
@@ -0,0 +1,196 @@
+// Package metrics provides ready-made core.MetricsCollector implementations
+// for MCP clients and servers.
+package metrics
+
+import (
+	"expvar"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ExpvarCollector publishes connection and request metrics through expvar, so
+// they are served as JSON on /debug/vars by any program that imports expvar's
+// HTTP handler. It implements core.MetricsCollector.
+//
+// The published map holds:
+//
+//	connections_opened, connections_closed, connections_active  counters and gauge
+//	connection_stalls                                           counter
+//	requests_in_flight                                          gauge
+//	requests, errors, bytes_in, bytes_out                       maps keyed by method
+//	cancellations                                               map keyed by core.Cause
+//	handler_timeouts                                            map keyed by method
+//	stuck_handlers                                              counter
+//	latency                                                     histograms keyed by method
+type ExpvarCollector struct {
+	vars *expvar.Map
+
+	connectionsOpened expvar.Int
+	connectionsClosed expvar.Int
+	connectionsActive expvar.Int
+	connectionStalls  expvar.Int
+	inFlight          expvar.Int
+	requests          expvar.Map
+	errors            expvar.Map
+	bytesIn           expvar.Map
+	bytesOut          expvar.Map
+	latency           expvar.Map
+	cancellations     expvar.Map
+	handlerTimeouts   expvar.Map
+	stuckHandlers     expvar.Int
+
+	buckets     []time.Duration
+	histogramMu sync.Mutex
+}
+
+// NewExpvarCollector creates a collector with DefaultLatencyBuckets and
+// publishes it under name. Like expvar.Publish, it panics if name is taken.
+func NewExpvarCollector(name string) *ExpvarCollector {
+	return NewExpvarCollectorWithBuckets(name, DefaultLatencyBuckets)
+}
+
+// NewExpvarCollectorWithBuckets creates a collector whose latency histograms
+// use the given ascending bucket upper bounds and publishes it under name.
+func NewExpvarCollectorWithBuckets(name string, buckets []time.Duration) *ExpvarCollector {
+	c := &ExpvarCollector{buckets: buckets}
+	c.requests.Init()
+	c.errors.Init()
+	c.bytesIn.Init()
+	c.bytesOut.Init()
+	c.latency.Init()
+	c.cancellations.Init()
+	c.handlerTimeouts.Init()
+
+	c.vars = expvar.NewMap(name)
+	c.vars.Set("connections_opened", &c.connectionsOpened)
+	c.vars.Set("connections_closed", &c.connectionsClosed)
+	c.vars.Set("connections_active", &c.connectionsActive)
+	c.vars.Set("connection_stalls", &c.connectionStalls)
+	c.vars.Set("requests_in_flight", &c.inFlight)
+	c.vars.Set("requests", &c.requests)
+	c.vars.Set("errors", &c.errors)
+	c.vars.Set("bytes_in", &c.bytesIn)
+	c.vars.Set("bytes_out", &c.bytesOut)
+	c.vars.Set("latency", &c.latency)
+	c.vars.Set("cancellations", &c.cancellations)
+	c.vars.Set("handler_timeouts", &c.handlerTimeouts)
+	c.vars.Set("stuck_handlers", &c.stuckHandlers)
+	return c
+}
+
+// ConnectionOpened implements core.MetricsCollector.
+func (c *ExpvarCollector) ConnectionOpened(string) {
+	c.connectionsOpened.Add(1)
+	c.connectionsActive.Add(1)
+}
+
+// ConnectionClosed implements core.MetricsCollector.
+func (c *ExpvarCollector) ConnectionClosed(string) {
+	c.connectionsClosed.Add(1)
+	c.connectionsActive.Add(-1)
+}
+
+// ConnectionStalled implements core.StallCollector.
+func (c *ExpvarCollector) ConnectionStalled(string, time.Duration) {
+	c.connectionStalls.Add(1)
+}
+
+// RequestStarted implements core.MetricsCollector.
+func (c *ExpvarCollector) RequestStarted(method string) {
+	c.inFlight.Add(1)
+	c.requests.Add(method, 1)
+}
+
+// RequestCompleted implements core.MetricsCollector.
+func (c *ExpvarCollector) RequestCompleted(method string, duration time.Duration, success bool) {
+	c.inFlight.Add(-1)
+	if !success {
+		c.errors.Add(method, 1)
+	}
+	c.histogram(method).Observe(duration)
+}
+
+// PayloadSize implements core.MetricsCollector.
+func (c *ExpvarCollector) PayloadSize(method string, bytesIn, bytesOut int) {
+	c.bytesIn.Add(method, int64(bytesIn))
+	c.bytesOut.Add(method, int64(bytesOut))
+}
+
+// RequestCancelled implements core.CancelCollector.
+func (c *ExpvarCollector) RequestCancelled(_ string, cause core.Cause) {
+	c.cancellations.Add(string(cause), 1)
+}
+
+// HandlerTimedOut implements core.TimeoutCollector.
+func (c *ExpvarCollector) HandlerTimedOut(method string, stuck bool) {
+	c.handlerTimeouts.Add(method, 1)
+	if stuck {
+		c.stuckHandlers.Add(1)
+	}
+}
+
+// Var returns the published map.
+func (c *ExpvarCollector) Var() *expvar.Map {
+	return c.vars
+}
+
+// ServeHTTP serves the published map as JSON, so the collector can also be
+// exposed with server.WithMetricsAddr.
+func (c *ExpvarCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	io.WriteString(w, c.vars.String())
+}
+
+// Requests returns the number of requests started for method.
+func (c *ExpvarCollector) Requests(method string) int64 {
+	return mapValue(&c.requests, method)
+}
+
+// Errors returns the number of requests for method that completed with an error.
+func (c *ExpvarCollector) Errors(method string) int64 {
+	return mapValue(&c.errors, method)
+}
+
+// Latency returns the latency histogram for method, or nil if no request for
+// method has completed.
+func (c *ExpvarCollector) Latency(method string) *Histogram {
+	h, _ := c.latency.Get(method).(*Histogram)
+	return h
+}
+
+// Cancellations returns the number of requests cancelled with cause.
+func (c *ExpvarCollector) Cancellations(cause core.Cause) int64 {
+	return mapValue(&c.cancellations, string(cause))
+}
+
+// ActiveConnections returns the number of open connections.
+func (c *ExpvarCollector) ActiveConnections() int64 {
+	return c.connectionsActive.Value()
+}
+
+// histogram returns the latency histogram for method, creating it on first use.
+func (c *ExpvarCollector) histogram(method string) *Histogram {
+	if h := c.Latency(method); h != nil {
+		return h
+	}
+
+	c.histogramMu.Lock()
+	defer c.histogramMu.Unlock()
+	if h := c.Latency(method); h != nil {
+		return h
+	}
+	h := NewHistogram(c.buckets)
+	c.latency.Set(method, h)
+	return h
+}
+
+func mapValue(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
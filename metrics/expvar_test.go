@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvarCollectorCountsRequests(t *testing.T) {
+	serverMetrics := NewExpvarCollector("mcp_test_server")
+	clientMetrics := NewExpvarCollector("mcp_test_client")
+
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithMetrics(clientMetrics))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithMetrics(serverMetrics))
+			require.NoError(t, srv.RegisterHandler(&testutil.FlakyModelHandler{}), "Handler registration should succeed")
+			return srv
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := 0; i < 4; i++ {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "ProcessModel should succeed")
+	}
+	failing := testutil.CreateTestModelRequest()
+	failing.ModelData["fail"] = true
+	_, err := c.ProcessModel(ctx, failing)
+	require.Error(t, err, "Failing request should return an error")
+
+	// The server records its side just after replying
+	require.Eventually(t, func() bool {
+		latency := serverMetrics.Latency(core.MethodProcessModel)
+		return latency != nil && latency.Count() == 5
+	}, time.Second, 10*time.Millisecond, "Server should time every request")
+
+	for name, collector := range map[string]*ExpvarCollector{"server": serverMetrics, "client": clientMetrics} {
+		assert.Equal(t, int64(5), collector.Requests(core.MethodProcessModel), name+" should count every request")
+		assert.Equal(t, int64(1), collector.Errors(core.MethodProcessModel), name+" should count the failed request")
+		assert.Equal(t, int64(1), collector.ActiveConnections(), name+" should see one open connection")
+
+		latency := collector.Latency(core.MethodProcessModel)
+		require.NotNil(t, latency, name+" should have a latency histogram")
+		assert.Equal(t, int64(5), latency.Count(), name+" histogram should hold every request")
+		buckets := latency.Buckets()
+		assert.Len(t, buckets, len(DefaultLatencyBuckets)+1, name+" histogram should have an overflow bucket")
+		assert.Equal(t, int64(5), buckets[len(buckets)-1].Count, name+" overflow bucket should be cumulative")
+
+		assert.Positive(t, mapValue(&collector.bytesIn, core.MethodProcessModel), name+" should count bytes in")
+		assert.Positive(t, mapValue(&collector.bytesOut, core.MethodProcessModel), name+" should count bytes out")
+	}
+
+	// What the server receives is what the client sent
+	assert.Equal(t, mapValue(&clientMetrics.bytesOut, core.MethodProcessModel), mapValue(&serverMetrics.bytesIn, core.MethodProcessModel),
+		"Request sizes should agree on both sides")
+
+	// The published map is valid JSON
+	var published map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(serverMetrics.Var().String()), &published), "Published metrics should be JSON")
+	assert.Contains(t, published, "latency", "Published metrics should include latency")
+
+	// Closing the connection is seen by both sides
+	require.NoError(t, c.Stop(), "Client should stop")
+	require.NoError(t, srv.Stop(), "Server should stop")
+	assert.Eventually(t, func() bool {
+		return serverMetrics.ActiveConnections() == 0
+	}, time.Second, 10*time.Millisecond, "Server should see the connection close")
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the histogram bucket upper bounds used by
+// NewExpvarCollector, from one millisecond to ten seconds.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Bucket is a cumulative histogram bucket: Count observations were at most UpperBound.
+type Bucket struct {
+	UpperBound time.Duration // math.MaxInt64 for the overflow bucket
+	Count      int64
+}
+
+// Histogram counts durations into fixed buckets. It implements expvar.Var,
+// rendering as {"count": n, "sum": seconds, "buckets": {"<le seconds>": n, "+Inf": n}}
+// with cumulative bucket counts.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []time.Duration
+	counts []int64 // Non-cumulative; the last entry counts overflows
+	count  int64
+	sum    time.Duration
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds.
+func NewHistogram(bounds []time.Duration) *Histogram {
+	sorted := append([]time.Duration(nil), bounds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &Histogram{
+		bounds: sorted,
+		counts: make([]int64, len(sorted)+1),
+	}
+}
+
+// Observe records a duration.
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += d
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Sum returns the total of all observations.
+func (h *Histogram) Sum() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+// Buckets returns the cumulative bucket counts, ending with the overflow bucket.
+func (h *Histogram) Buckets() []Bucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make([]Bucket, len(h.counts))
+	var total int64
+	for i, n := range h.counts {
+		total += n
+		buckets[i] = Bucket{UpperBound: math.MaxInt64, Count: total}
+		if i < len(h.bounds) {
+			buckets[i].UpperBound = h.bounds[i]
+		}
+	}
+	return buckets
+}
+
+// String implements expvar.Var.
+func (h *Histogram) String() string {
+	buckets := make(map[string]int64)
+	for _, bucket := range h.Buckets() {
+		key := "+Inf"
+		if bucket.UpperBound != math.MaxInt64 {
+			key = strconv.FormatFloat(bucket.UpperBound.Seconds(), 'g', -1, 64)
+		}
+		buckets[key] = bucket.Count
+	}
+
+	data, _ := json.Marshal(struct {
+		Count   int64            `json:"count"`
+		Sum     float64          `json:"sum"`
+		Buckets map[string]int64 `json:"buckets"`
+	}{h.Count(), h.Sum().Seconds(), buckets})
+	return string(data)
+}
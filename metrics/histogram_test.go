@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]time.Duration{10 * time.Millisecond, time.Millisecond})
+
+	h.Observe(500 * time.Microsecond)
+	h.Observe(time.Millisecond)
+	h.Observe(5 * time.Millisecond)
+	h.Observe(time.Second)
+
+	assert.Equal(t, int64(4), h.Count(), "Count should include every observation")
+	assert.Equal(t, 1006500*time.Microsecond, h.Sum(), "Sum should total the observations")
+	assert.Equal(t, []Bucket{
+		{UpperBound: time.Millisecond, Count: 2},
+		{UpperBound: 10 * time.Millisecond, Count: 3},
+		{UpperBound: math.MaxInt64, Count: 4},
+	}, h.Buckets(), "Buckets should be sorted and cumulative")
+
+	var rendered struct {
+		Count   int64            `json:"count"`
+		Buckets map[string]int64 `json:"buckets"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(h.String()), &rendered), "String should render JSON")
+	assert.Equal(t, int64(4), rendered.Count, "Rendered count should match")
+	assert.Equal(t, map[string]int64{"0.001": 2, "0.01": 3, "+Inf": 4}, rendered.Buckets, "Rendered buckets should be keyed by seconds")
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ResultCached is the key of the result Cache adds, set to true, to the
+// responses it answers from its store.
+const ResultCached = "cached"
+
+// CacheOptions holds configuration parameters for Cache.
+type CacheOptions struct {
+	Methods []string // Methods whose responses are memoized
+}
+
+// DefaultCacheOptions returns the default cache options, memoizing
+// mcp.processModel, which batch items are processed as too.
+func DefaultCacheOptions() CacheOptions {
+	return CacheOptions{
+		Methods: []string{core.MethodProcessModel},
+	}
+}
+
+// CacheOption is a function type that modifies CacheOptions.
+type CacheOption func(*CacheOptions)
+
+// WithCachedMethods sets the methods whose responses are memoized, replacing
+// the default of mcp.processModel. Streams, whose chunks cannot be replayed,
+// should not be listed.
+func WithCachedMethods(methods ...string) CacheOption {
+	return func(o *CacheOptions) {
+		o.Methods = methods
+	}
+}
+
+// Cache returns server middleware memoizing the successful responses of
+// handlers that are pure functions of their request: a request with the same
+// model data and parameters as an earlier one, whatever its ID and metadata,
+// is answered from store for ttl without running the handler. Answers from
+// the store carry the request's ID, a fresh Timestamp and a ResultCached
+// result, and echo the request's values of the metadata keys the first
+// response carried. Requests setting core.MetadataCache to core.CacheBypass
+// or core.CacheRefresh run the handler; a refreshed response replaces the
+// stored one. Errors from store are treated as misses.
+func Cache(store Store, ttl time.Duration, options ...CacheOption) core.Middleware {
+	opts := DefaultCacheOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	cached := make(map[string]bool, len(opts.Methods))
+	for _, method := range opts.Methods {
+		cached[method] = true
+	}
+
+	return func(method string, next core.ProcessFunc) core.ProcessFunc {
+		if !cached[method] {
+			return next
+		}
+		return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			if req == nil || req.Metadata[core.MetadataCache] == core.CacheBypass {
+				return next(ctx, req)
+			}
+			key, err := requestKey(method, req)
+			if err != nil {
+				return next(ctx, req)
+			}
+			if req.Metadata[core.MetadataCache] != core.CacheRefresh {
+				if resp, ok := lookup(ctx, store, key, req); ok {
+					return resp, nil
+				}
+			}
+
+			resp, err := next(ctx, req)
+			if err == nil && resp != nil && resp.Success {
+				if encoded, err := json.Marshal(resp); err == nil {
+					store.Set(ctx, key, encoded, ttl)
+				}
+			}
+			return resp, err
+		}
+	}
+}
+
+// requestKey identifies the requests to method answered alike: those with
+// the same model data and parameters. Maps are encoded with sorted keys, so
+// the key does not depend on the order they were filled in.
+func requestKey(method string, req *core.ModelRequest) (string, error) {
+	content, err := json.Marshal(struct {
+		Method     string                 `json:"method"`
+		ModelData  map[string]interface{} `json:"modelData"`
+		Parameters []core.Parameter       `json:"parameters"`
+		BlobRefs   []core.BlobID          `json:"blobRefs,omitempty"`
+	}{method, req.ModelData, req.Parameters, req.BlobRefs})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return "mcp:" + hex.EncodeToString(sum[:]), nil
+}
+
+// lookup returns the response stored under key, made the answer to req.
+func lookup(ctx context.Context, store Store, key string, req *core.ModelRequest) (*core.ModelResponse, bool) {
+	encoded, ok, err := store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	var resp core.ModelResponse
+	if json.Unmarshal(encoded, &resp) != nil {
+		return nil, false
+	}
+
+	resp.ID = req.ID
+	resp.Timestamp = time.Now()
+	if resp.Results == nil {
+		resp.Results = make(map[string]interface{})
+	}
+	resp.Results[ResultCached] = true
+	for key := range resp.Metadata {
+		if value, ok := req.Metadata[key]; ok {
+			resp.Metadata[key] = value
+		}
+	}
+	return &resp, true
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHandler counts the requests it processes, returning the count
+type countingHandler struct {
+	calls atomic.Int32
+}
+
+func (h *countingHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *countingHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	resp.Results["call"] = h.calls.Add(1)
+	return resp, nil
+}
+
+// startCachedPair starts a server memoizing a counting handler's responses
+// for ttl, and a client connected to it
+func startCachedPair(t *testing.T, ttl time.Duration) (*client.Client, *countingHandler) {
+	handler := &countingHandler{}
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()),
+				server.WithMiddleware(Cache(NewMemoryStore(10), ttl)))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c, handler
+}
+
+func TestCacheHit(t *testing.T) {
+	c, handler := startCachedPair(t, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first := core.NewModelRequest()
+	first.ModelData["name"] = "model"
+	first.ModelData["layers"] = map[string]interface{}{"input": 3, "output": 1}
+	first.Metadata = map[string]string{core.MetadataTraceID: "trace-1"}
+	resp, err := c.ProcessModel(ctx, first)
+	require.NoError(t, err, "First request should succeed")
+	assert.Nil(t, resp.Results[ResultCached], "Handler's response should not be marked cached")
+
+	// The same content, filled in another order, is answered from the store
+	second := core.NewModelRequest()
+	second.ModelData["layers"] = map[string]interface{}{"output": 1, "input": 3}
+	second.ModelData["name"] = "model"
+	second.Metadata = map[string]string{core.MetadataTraceID: "trace-2"}
+	cached, err := c.ProcessModel(ctx, second)
+	require.NoError(t, err, "Repeated request should succeed")
+	assert.Equal(t, int32(1), handler.calls.Load(), "Repeated request should not reach the handler")
+	assert.Equal(t, second.ID, cached.ID, "Cached response should carry the request's ID")
+	assert.Equal(t, true, cached.Results[ResultCached], "Cached response should be marked")
+	assert.Equal(t, resp.Results["call"], cached.Results["call"], "Cached response should have the handler's results")
+	assert.True(t, cached.Timestamp.After(resp.Timestamp), "Cached response should get a fresh timestamp")
+	assert.Equal(t, "trace-2", cached.Metadata[core.MetadataTraceID], "Cached response should echo the request's metadata")
+
+	// Different model data misses
+	third := core.NewModelRequest()
+	third.ModelData["name"] = "other model"
+	resp, err = c.ProcessModel(ctx, third)
+	require.NoError(t, err, "Request with other model data should succeed")
+	assert.Equal(t, int32(2), handler.calls.Load(), "Request with other model data should reach the handler")
+	assert.Nil(t, resp.Results[ResultCached], "Handler's response should not be marked cached")
+
+	// Bypassing runs the handler
+	bypass := core.NewModelRequest()
+	bypass.ModelData["name"] = "other model"
+	bypass.Metadata = map[string]string{core.MetadataCache: core.CacheBypass}
+	_, err = c.ProcessModel(ctx, bypass)
+	require.NoError(t, err, "Bypassing request should succeed")
+	assert.Equal(t, int32(3), handler.calls.Load(), "Bypassing request should reach the handler")
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, handler := startCachedPair(t, 50*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "Request should succeed")
+	}
+	assert.Equal(t, int32(1), handler.calls.Load(), "Repeated request should be answered from the store")
+
+	time.Sleep(60 * time.Millisecond)
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request after expiry should succeed")
+	assert.Equal(t, int32(2), handler.calls.Load(), "Expired response should invoke the handler again")
+	assert.Nil(t, resp.Results[ResultCached], "Fresh response should not be marked cached")
+}
+
+func TestCacheMethods(t *testing.T) {
+	store := NewMemoryStore(10)
+	next := func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	}
+	req := testutil.CreateTestModelRequest()
+
+	process := Cache(store, time.Minute)(core.MethodProcessModelStream, next)
+	_, err := process(context.Background(), req)
+	require.NoError(t, err, "Request should succeed")
+	assert.Zero(t, store.Len(), "Methods not opted in should not be memoized")
+
+	process = Cache(store, time.Minute, WithCachedMethods("custom.predict"))("custom.predict", next)
+	_, err = process(context.Background(), req)
+	require.NoError(t, err, "Request should succeed")
+	assert.Equal(t, 1, store.Len(), "Methods opted in should be memoized")
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2)
+	require.NoError(t, store.Set(ctx, "a", []byte("1"), 0), "Set should succeed")
+	require.NoError(t, store.Set(ctx, "b", []byte("2"), 0), "Set should succeed")
+
+	value, ok, err := store.Get(ctx, "a")
+	require.NoError(t, err, "Get should succeed")
+	require.True(t, ok, "Value should be stored")
+	assert.Equal(t, []byte("1"), value, "Stored value should be returned")
+
+	require.NoError(t, store.Set(ctx, "c", []byte("3"), 0), "Set should succeed")
+	_, ok, _ = store.Get(ctx, "b")
+	assert.False(t, ok, "Least recently used value should be dropped")
+	_, ok, _ = store.Get(ctx, "a")
+	assert.True(t, ok, "Recently used value should be kept")
+
+	require.NoError(t, store.Set(ctx, "d", []byte("4"), time.Nanosecond), "Set should succeed")
+	time.Sleep(time.Millisecond)
+	_, ok, _ = store.Get(ctx, "d")
+	assert.False(t, ok, "Expired value should not be returned")
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// IdempotencyOptions holds configuration parameters for Idempotency.
+type IdempotencyOptions struct {
+	Methods []string // Methods whose requests are deduplicated
+}
+
+// DefaultIdempotencyOptions returns the default idempotency options,
+// deduplicating mcp.processModel, which batch items are processed as too.
+func DefaultIdempotencyOptions() IdempotencyOptions {
+	return IdempotencyOptions{
+		Methods: []string{core.MethodProcessModel},
+	}
+}
+
+// IdempotencyOption is a function type that modifies IdempotencyOptions.
+type IdempotencyOption func(*IdempotencyOptions)
+
+// WithIdempotentMethods sets the methods whose requests are deduplicated,
+// replacing the default of mcp.processModel.
+func WithIdempotentMethods(methods ...string) IdempotencyOption {
+	return func(o *IdempotencyOptions) {
+		o.Methods = methods
+	}
+}
+
+// idempotentEntry is what Idempotency stores for a key.
+type idempotentEntry struct {
+	Hash     string              `json:"hash"` // Of the request's content, without its metadata
+	Response *core.ModelResponse `json:"response"`
+}
+
+// Idempotency returns server middleware running each request carrying a
+// core.MetadataIdempotencyKey at most once, so a client retrying a request
+// whose answer was lost does not run its handler again. The response to the
+// first request with a key, failed or not, is kept in store for ttl and
+// returned to later requests with the same key without running the handler.
+// A request arriving while another with its key is being processed waits
+// for it. Requests without a key, and those whose handler returned an
+// error, are not recorded, so they run again when retried. A key reused for
+// a request with other model data or parameters fails with
+// core.ErrInvalidParameter. Errors from store are treated as misses.
+//
+// Waiting is within a server: servers sharing store replay each other's
+// responses, but may process concurrent duplicates each.
+func Idempotency(store Store, ttl time.Duration, options ...IdempotencyOption) core.Middleware {
+	opts := DefaultIdempotencyOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	idempotent := make(map[string]bool, len(opts.Methods))
+	for _, method := range opts.Methods {
+		idempotent[method] = true
+	}
+
+	var mu sync.Mutex
+	inFlight := make(map[string]chan struct{}) // Closed when the request with the key is done
+
+	return func(method string, next core.ProcessFunc) core.ProcessFunc {
+		if !idempotent[method] {
+			return next
+		}
+		return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			if req == nil || req.Metadata[core.MetadataIdempotencyKey] == "" {
+				return next(ctx, req)
+			}
+			content := *req
+			content.Metadata = nil
+			hash, err := content.Hash()
+			if err != nil {
+				return next(ctx, req)
+			}
+			key := "mcp:idempotency:" + method + ":" + req.Metadata[core.MetadataIdempotencyKey]
+
+			for {
+				mu.Lock()
+				if done, ok := inFlight[key]; ok {
+					mu.Unlock()
+					select {
+					case <-done:
+						continue
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+				}
+				done := make(chan struct{})
+				inFlight[key] = done
+				mu.Unlock()
+
+				resp, err := replay(ctx, store, key, hash, req)
+				if resp == nil && err == nil {
+					resp, err = next(ctx, req)
+					if err == nil && resp != nil {
+						if encoded, err := json.Marshal(idempotentEntry{Hash: hash, Response: resp}); err == nil {
+							store.Set(ctx, key, encoded, ttl)
+						}
+					}
+				}
+
+				mu.Lock()
+				delete(inFlight, key)
+				mu.Unlock()
+				close(done)
+				return resp, err
+			}
+		}
+	}
+}
+
+// replay returns the response stored under key, made the answer to req, or
+// nil if there is none. It fails if the stored response answered a request
+// with other content.
+func replay(ctx context.Context, store Store, key, hash string, req *core.ModelRequest) (*core.ModelResponse, error) {
+	encoded, ok, err := store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, nil
+	}
+	var entry idempotentEntry
+	if json.Unmarshal(encoded, &entry) != nil || entry.Response == nil {
+		return nil, nil
+	}
+	if entry.Hash != hash {
+		return nil, core.NewModelError(core.ErrInvalidParameter,
+			fmt.Errorf("idempotency key %q was used for another request", req.Metadata[core.MetadataIdempotencyKey]))
+	}
+	entry.Response.ID = req.ID
+	return entry.Response, nil
+}
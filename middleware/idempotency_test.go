@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// idempotentRequest returns a request carrying the idempotency key
+func idempotentRequest(key string) *core.ModelRequest {
+	req := core.NewModelRequest()
+	req.ModelData["name"] = "model"
+	req.Metadata = map[string]string{core.MetadataIdempotencyKey: key}
+	return req
+}
+
+func TestIdempotencyRetryStorm(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	process := Idempotency(NewMemoryStore(10), time.Minute)(core.MethodProcessModel,
+		func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			<-release
+			resp := core.NewModelResponse(req)
+			resp.Results["call"] = float64(calls.Add(1))
+			return resp, nil
+		})
+
+	req := idempotentRequest("key-1")
+	const retries = 20
+	responses := make([]*core.ModelResponse, retries)
+	var wg sync.WaitGroup
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := process(context.Background(), req.Clone())
+			assert.NoError(t, err, "Retry %d should succeed", i)
+			responses[i] = resp
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	later, err := process(context.Background(), req.Clone())
+	require.NoError(t, err, "A later retry should succeed")
+	responses = append(responses, later)
+
+	assert.Equal(t, int32(1), calls.Load(), "The handler should run once for all retries")
+	for i, resp := range responses {
+		require.NotNil(t, resp, "Retry %d should get a response", i)
+		assert.Equal(t, responses[0].Results, resp.Results, "Retry %d should get the first response's results", i)
+		assert.True(t, responses[0].Timestamp.Equal(resp.Timestamp), "Retry %d should get the first response", i)
+		assert.Equal(t, req.ID, resp.ID, "Retry %d should get a response to its request", i)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	var calls atomic.Int32
+	process := Idempotency(NewMemoryStore(10), time.Minute)(core.MethodProcessModel,
+		func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			calls.Add(1)
+			if req.ModelData["fail"] == true {
+				return nil, errors.New("unavailable")
+			}
+			return core.NewModelResponse(req), nil
+		})
+	ctx := context.Background()
+
+	_, err := process(ctx, idempotentRequest("key-1"))
+	require.NoError(t, err, "The first request should succeed")
+	_, err = process(ctx, idempotentRequest("key-2"))
+	require.NoError(t, err, "A request with another key should succeed")
+	assert.Equal(t, int32(2), calls.Load(), "Requests with different keys should each run")
+
+	for i := 0; i < 2; i++ {
+		_, err = process(ctx, core.NewModelRequest())
+		require.NoError(t, err, "A request without a key should succeed")
+	}
+	assert.Equal(t, int32(4), calls.Load(), "Requests without a key should each run")
+
+	failing := idempotentRequest("key-3")
+	failing.ModelData["fail"] = true
+	for i := 0; i < 2; i++ {
+		_, err = process(ctx, failing.Clone())
+		assert.Error(t, err, "The failing request should fail")
+	}
+	assert.Equal(t, int32(6), calls.Load(), "Requests whose handler erred should run again when retried")
+
+	reused := idempotentRequest("key-1")
+	reused.ModelData["name"] = "other"
+	_, err = process(ctx, reused)
+	var modelErr *core.ModelError
+	require.ErrorAs(t, err, &modelErr, "A reused key should fail with a model error")
+	assert.Equal(t, core.ErrInvalidParameter, modelErr.Code, "A reused key should fail as an invalid parameter")
+	assert.Equal(t, int32(6), calls.Load(), "A request reusing a key should not run")
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	var calls atomic.Int32
+	process := Idempotency(NewMemoryStore(10), 20*time.Millisecond)(core.MethodProcessModel,
+		func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			calls.Add(1)
+			return core.NewModelResponse(req), nil
+		})
+
+	_, err := process(context.Background(), idempotentRequest("key-1"))
+	require.NoError(t, err, "The first request should succeed")
+	time.Sleep(40 * time.Millisecond)
+	_, err = process(context.Background(), idempotentRequest("key-1"))
+	require.NoError(t, err, "A retry after the TTL should succeed")
+	assert.Equal(t, int32(2), calls.Load(), "A retry after the TTL should run again")
+}
+
+func TestWithIdempotentMethods(t *testing.T) {
+	opts := DefaultIdempotencyOptions()
+	WithIdempotentMethods("custom.method")(&opts)
+	assert.Equal(t, []string{"custom.method"}, opts.Methods, "WithIdempotentMethods should set the methods")
+}
@@ -0,0 +1,210 @@
+// Package middleware provides ready-made core.Middleware, to install on
+// servers with server.WithMiddleware and on clients with
+// client.WithInterceptors.
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Field names used in the records Logging writes, besides core.LogFieldMethod,
+// core.LogFieldRequestID and core.LogFieldError.
+const (
+	LogFieldDuration      = "duration"       // Time from the request entering the middleware to its outcome
+	LogFieldOutcome       = "outcome"        // One of OutcomeSuccess, OutcomeFailure or OutcomeError
+	LogFieldRequestBytes  = "request_bytes"  // Size of the request encoded as JSON
+	LogFieldResponseBytes = "response_bytes" // Size of the response encoded as JSON; absent on errors
+	LogFieldRequest       = "request"        // Request as JSON, when payloads are included
+	LogFieldResponse      = "response"       // Response as JSON, when payloads are included
+)
+
+// Outcomes of a logged request.
+const (
+	OutcomeSuccess = "success" // The response reports success
+	OutcomeFailure = "failure" // The response reports a failure of the model
+	OutcomeError   = "error"   // No response: the request was invalid, or processing or the call failed
+)
+
+// LoggingOptions holds configuration parameters for Logging.
+type LoggingOptions struct {
+	Level           slog.Level // Level of records of successful requests
+	FailureLevel    slog.Level // Level of records of failures and errors
+	SampleEvery     int        // Log one request in this many; values below two log every request
+	IncludePayloads bool       // Whether records carry the request and response as JSON
+	MaxValueLength  int        // Longest encoded ModelData or Results value in payloads before it is truncated; zero keeps values whole
+}
+
+// DefaultLoggingOptions returns the default logging options: every request
+// logged, successes at Info and failures at Warn, without payloads.
+func DefaultLoggingOptions() LoggingOptions {
+	return LoggingOptions{
+		Level:          slog.LevelInfo,
+		FailureLevel:   slog.LevelWarn,
+		MaxValueLength: 256,
+	}
+}
+
+// LoggingOption is a function type that modifies LoggingOptions.
+type LoggingOption func(*LoggingOptions)
+
+// WithLevel sets the level of records of successful requests.
+func WithLevel(level slog.Level) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.Level = level
+	}
+}
+
+// WithFailureLevel sets the level of records of requests that fail, whether
+// with a failed response or an error.
+func WithFailureLevel(level slog.Level) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.FailureLevel = level
+	}
+}
+
+// WithSampling logs only the first of every n requests, whatever their
+// outcome. Requests left out cost no more than a counter increment.
+func WithSampling(n int) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.SampleEvery = n
+	}
+}
+
+// WithPayloads adds the request and the response, encoded as JSON, to every
+// record. ModelData and Results values longer than maxValueLength once
+// encoded are cut short; zero keeps them whole.
+func WithPayloads(maxValueLength int) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.IncludePayloads = true
+		o.MaxValueLength = maxValueLength
+	}
+}
+
+// Logging returns middleware writing one record to logger for every model
+// request, once its outcome is known, with the method, request ID, duration,
+// payload sizes and outcome. Installed on a server it also sees requests
+// failing schema validation; on a client, the time includes the round trip.
+func Logging(logger core.Logger, options ...LoggingOption) core.Middleware {
+	opts := DefaultLoggingOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+	var seen atomic.Uint64
+
+	return func(method string, next core.ProcessFunc) core.ProcessFunc {
+		return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			if opts.SampleEvery > 1 && (seen.Add(1)-1)%uint64(opts.SampleEvery) != 0 {
+				return next(ctx, req)
+			}
+			start := time.Now()
+			resp, err := next(ctx, req)
+			logRequest(logger, opts, method, req, resp, err, time.Since(start))
+			return resp, err
+		}
+	}
+}
+
+// logRequest writes the record of one request.
+func logRequest(logger core.Logger, opts LoggingOptions, method string, req *core.ModelRequest, resp *core.ModelResponse, err error, elapsed time.Duration) {
+	requestID := ""
+	if req != nil {
+		requestID = req.ID
+	}
+	fields := []any{
+		core.LogFieldMethod, method,
+		core.LogFieldRequestID, requestID,
+		LogFieldDuration, elapsed,
+		LogFieldRequestBytes, encodedSize(req),
+	}
+
+	level, outcome := opts.Level, OutcomeSuccess
+	switch {
+	case err != nil:
+		level, outcome = opts.FailureLevel, OutcomeError
+		fields = append(fields, core.LogFieldError, err.Error())
+	case resp == nil:
+		level, outcome = opts.FailureLevel, OutcomeError
+		fields = append(fields, core.LogFieldError, "no response")
+	case !resp.Success:
+		level, outcome = opts.FailureLevel, OutcomeFailure
+		fields = append(fields, core.LogFieldError, resp.ErrorMessage)
+	}
+	fields = append(fields, LogFieldOutcome, outcome)
+	if resp != nil {
+		fields = append(fields, LogFieldResponseBytes, encodedSize(resp))
+	}
+
+	if opts.IncludePayloads {
+		if req != nil {
+			shown := *req
+			shown.ModelData = truncateValues(req.ModelData, opts.MaxValueLength)
+			fields = append(fields, LogFieldRequest, encode(&shown))
+		}
+		if resp != nil {
+			shown := *resp
+			shown.Results = truncateValues(resp.Results, opts.MaxValueLength)
+			fields = append(fields, LogFieldResponse, encode(&shown))
+		}
+	}
+
+	logAt(logger, level, "Model request", fields...)
+}
+
+// logAt writes a record at the core.Logger method closest to level.
+func logAt(logger core.Logger, level slog.Level, msg string, fields ...any) {
+	switch {
+	case level < slog.LevelInfo:
+		logger.Debug(msg, fields...)
+	case level < slog.LevelWarn:
+		logger.Info(msg, fields...)
+	case level < slog.LevelError:
+		logger.Warn(msg, fields...)
+	default:
+		logger.Error(msg, fields...)
+	}
+}
+
+// encodedSize returns the size of v encoded as JSON, or -1 if it cannot be
+// encoded.
+func encodedSize(v interface{}) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return -1
+	}
+	return len(data)
+}
+
+// encode returns v encoded as JSON, or why it cannot be.
+func encode(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<unencodable: %v>", err)
+	}
+	return string(data)
+}
+
+// truncateValues returns values with those longer than max once encoded as
+// JSON replaced by the start of their encoding and the number of bytes cut.
+// The map given is not modified.
+func truncateValues(values map[string]interface{}, max int) map[string]interface{} {
+	if max <= 0 || len(values) == 0 {
+		return values
+	}
+	shown := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil || len(data) <= max {
+			shown[key] = value
+			continue
+		}
+		shown[key] = fmt.Sprintf("%s... (%d more bytes)", data[:max], len(data)-max)
+	}
+	return shown
+}
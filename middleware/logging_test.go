@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingHandler accepts model data of at most 100, like the schema it
+// declares, and fails requests for the model named "broken"
+type failingHandler struct {
+	*testutil.SchemaHandler
+}
+
+func (h failingHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if name, _ := req.GetString("name"); name == "broken" {
+		return nil, errors.New("model is broken")
+	}
+	return h.SchemaHandler.ProcessModel(ctx, req)
+}
+
+// startLoggedPair starts a server logging its requests to a capture logger
+// with the given options, and a client without local validation
+func startLoggedPair(t *testing.T, options ...LoggingOption) (*client.Client, *testutil.CaptureLogger) {
+	maxValue := 100.0
+	handler := failingHandler{testutil.NewSchemaHandler(&core.Schema{
+		Type: "object",
+		Properties: map[string]*core.Schema{
+			"value": {Type: "number", Maximum: &maxValue},
+		},
+	})}
+	logger := testutil.NewCaptureLogger()
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()),
+				server.WithMiddleware(Logging(logger, options...)))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c, logger
+}
+
+// requestRecords returns the records Logging wrote
+func requestRecords(logger *testutil.CaptureLogger) []testutil.LogRecord {
+	var records []testutil.LogRecord
+	for _, record := range logger.Records() {
+		if record.Message == "Model request" {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestLoggingOutcomes(t *testing.T) {
+	c, logger := startLoggedPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ok := testutil.CreateTestModelRequest()
+	_, err := c.ProcessModel(ctx, ok)
+	require.NoError(t, err, "Valid request should succeed")
+
+	broken := testutil.CreateTestModelRequest()
+	broken.ModelData["name"] = "broken"
+	_, err = c.ProcessModel(ctx, broken)
+	require.Error(t, err, "Handler error should fail the request")
+
+	invalid := testutil.CreateTestModelRequest()
+	invalid.ModelData["value"] = 1000
+	_, err = c.ProcessModel(ctx, invalid)
+	require.Error(t, err, "Invalid request should fail")
+
+	records := requestRecords(logger)
+	require.Len(t, records, 3, "Every request should be logged once")
+
+	success := records[0]
+	assert.Equal(t, "info", success.Level, "Success should be logged at Info")
+	assert.Equal(t, core.MethodProcessModel, success.Fields[core.LogFieldMethod], "Method should be logged")
+	assert.Equal(t, ok.ID, success.Fields[core.LogFieldRequestID], "Request ID should be logged")
+	assert.Equal(t, OutcomeSuccess, success.Fields[LogFieldOutcome], "Outcome should be success")
+	assert.Greater(t, success.Fields[LogFieldDuration], time.Duration(0), "Duration should be logged")
+	assert.Greater(t, success.Fields[LogFieldRequestBytes], 0, "Request size should be logged")
+	assert.Greater(t, success.Fields[LogFieldResponseBytes], 0, "Response size should be logged")
+	assert.NotContains(t, success.Fields, LogFieldRequest, "Payloads should be left out by default")
+
+	failed := records[1]
+	assert.Equal(t, "warn", failed.Level, "Handler error should be logged at Warn")
+	assert.Equal(t, broken.ID, failed.Fields[core.LogFieldRequestID], "Request ID should be logged")
+	assert.Equal(t, OutcomeError, failed.Fields[LogFieldOutcome], "Outcome should be error")
+	assert.Contains(t, failed.Fields[core.LogFieldError], "model is broken", "Handler error should be logged")
+	assert.NotContains(t, failed.Fields, LogFieldResponseBytes, "No response size should be logged")
+
+	rejected := records[2]
+	assert.Equal(t, "warn", rejected.Level, "Validation failure should be logged at Warn")
+	assert.Equal(t, invalid.ID, rejected.Fields[core.LogFieldRequestID], "Request ID should be logged")
+	assert.Equal(t, OutcomeError, rejected.Fields[LogFieldOutcome], "Outcome should be error")
+	assert.Contains(t, rejected.Fields[core.LogFieldError], "value", "Violation should be logged")
+}
+
+func TestLoggingSampling(t *testing.T) {
+	c, logger := startLoggedPair(t, WithSampling(3))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := 0; i < 7; i++ {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "Request should succeed")
+	}
+	assert.Len(t, requestRecords(logger), 3, "Only the first of every three requests should be logged")
+}
+
+func TestLoggingPayloads(t *testing.T) {
+	c, logger := startLoggedPair(t, WithPayloads(16), WithLevel(slog.LevelDebug))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["notes"] = strings.Repeat("x", 100)
+	_, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should succeed")
+
+	records := requestRecords(logger)
+	require.Len(t, records, 1, "Request should be logged")
+	assert.Equal(t, "debug", records[0].Level, "Success should be logged at the configured level")
+	logged, _ := records[0].Fields[LogFieldRequest].(string)
+	assert.Contains(t, logged, `"name":"Test Model"`, "Short values should be logged whole")
+	assert.Contains(t, logged, "(86 more bytes)", "Long values should be truncated")
+	assert.NotContains(t, logged, strings.Repeat("x", 50), "Long values should not be logged whole")
+	assert.Equal(t, 100, len(req.ModelData["notes"].(string)), "Request should not be modified")
+	assert.Contains(t, records[0].Fields, LogFieldResponse, "Response should be logged")
+}
+
+func TestLoggingInterceptor(t *testing.T) {
+	logger := testutil.NewCaptureLogger()
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false),
+				client.WithInterceptors(Logging(logger)))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithLogger(core.NopLogger()))
+			require.NoError(t, srv.RegisterHandler(testutil.NewSchemaHandler(nil)), "Handler registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	_, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should succeed")
+
+	records := requestRecords(logger)
+	require.Len(t, records, 1, "Request should be logged by the client")
+	assert.Equal(t, req.ID, records[0].Fields[core.LogFieldRequestID], "Request ID should be logged")
+	assert.Equal(t, OutcomeSuccess, records[0].Fields[LogFieldOutcome], "Outcome should be success")
+}
+
+func TestLoggingOptions(t *testing.T) {
+	options := DefaultLoggingOptions()
+	assert.Equal(t, slog.LevelInfo, options.Level, "Successes should be logged at Info")
+	assert.Equal(t, slog.LevelWarn, options.FailureLevel, "Failures should be logged at Warn")
+	assert.Zero(t, options.SampleEvery, "Every request should be logged")
+	assert.False(t, options.IncludePayloads, "Payloads should be left out")
+
+	for _, opt := range []LoggingOption{WithFailureLevel(slog.LevelError), WithSampling(10), WithPayloads(0)} {
+		opt(&options)
+	}
+	assert.Equal(t, slog.LevelError, options.FailureLevel, "FailureLevel should be updated")
+	assert.Equal(t, 10, options.SampleEvery, "SampleEvery should be updated")
+	assert.True(t, options.IncludePayloads, "IncludePayloads should be enabled")
+	assert.Zero(t, options.MaxValueLength, "MaxValueLength should be updated")
+}
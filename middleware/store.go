@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds the responses Cache memoizes, encoded, by key. Implementations
+// must be safe for concurrent use; they may be backed by a shared service
+// such as Redis, so that servers share their cache.
+type Store interface {
+	// Get returns the value stored under key, reporting false if there is
+	// none or it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores value under key for ttl, or until evicted if ttl is zero.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// MemoryStore is a Store in memory, holding up to a fixed number of values
+// and dropping the least recently used first.
+type MemoryStore struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Of *storeEntry, most recently used first
+}
+
+// storeEntry is a value held by a MemoryStore.
+type storeEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // Zero if the value does not expire
+}
+
+// NewMemoryStore creates a store holding up to maxEntries values. A
+// maxEntries below one holds a single value.
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		size:    max(maxEntries, 1),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the value stored under key unless it has expired.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*storeEntry)
+	if !entry.expires.IsZero() && !time.Now().Before(entry.expires) {
+		s.remove(elem)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+// Set stores value under key for ttl, dropping the least recently used
+// value if the store is full.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	entry := &storeEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Len returns the number of values held, including expired ones not yet
+// dropped.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// remove drops a value. The caller must hold mu.
+func (s *MemoryStore) remove(elem *list.Element) {
+	delete(s.entries, elem.Value.(*storeEntry).key)
+	s.order.Remove(elem)
+}
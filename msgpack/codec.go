@@ -0,0 +1,462 @@
+// Package msgpack encodes MCP messages with MessagePack instead of JSON. Pass
+// Codec to client.WithCodec and server.WithCodec; the two sides agree on it
+// when the client connects, so peers that only speak JSON keep working:
+//
+//	srv := server.New(server.WithCodec(msgpack.Codec))
+//	c := client.New(client.WithCodec(msgpack.Codec))
+//
+// core.Binary values travel as MessagePack binary data rather than base64,
+// which is where most of the saving over JSON comes from. The codec is
+// written against the MessagePack specification with only the standard
+// library, so using it adds no dependencies.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ContentType names the codec in frame headers and negotiation.
+const ContentType = "application/msgpack"
+
+// Codec encodes messages as MessagePack.
+var Codec core.Codec = codec{}
+
+// errTruncated is returned for data that ends inside a value.
+var errTruncated = errors.New("msgpack: unexpected end of data")
+
+type codec struct{}
+
+// ContentType implements core.Codec.
+func (codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal implements core.Codec. JSON-RPC requests and responses are
+// encoded field by field, with their params, results and metadata transcoded
+// from the JSON they hold straight into MessagePack. Anything else is encoded
+// as JSON first and transcoded the same way.
+func (codec) Marshal(obj interface{}) ([]byte, error) {
+	if req, resp, ok := envelope(obj); ok {
+		switch {
+		case *req != nil && *resp == nil:
+			obj = *req
+		case *req == nil && *resp != nil:
+			obj = *resp
+		default:
+			return nil, errors.New("msgpack: message must have exactly one of the request or response fields set")
+		}
+	}
+	switch msg := obj.(type) {
+	case *jsonrpc2.Request:
+		return appendRequest(make([]byte, 0, messageSize(msg.Params, msg.Meta)), msg)
+	case *jsonrpc2.Response:
+		return appendResponse(make([]byte, 0, messageSize(msg.Result, msg.Meta)), msg)
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return appendJSON(make([]byte, 0, len(data)), data)
+}
+
+// messageSize estimates the encoded size of a message holding raw values:
+// about the length of their JSON, less the third base64 adds to binary data.
+func messageSize(raws ...*json.RawMessage) int {
+	n := 64
+	for _, raw := range raws {
+		if raw == nil {
+			continue
+		}
+		n += len(*raw) + len(*raw)/16
+		for rest := []byte(*raw); ; {
+			i := bytes.Index(rest, binaryKey)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(binaryKey):]
+			start := bytes.IndexByte(rest, '"')
+			if start < 0 {
+				break
+			}
+			end := bytes.IndexByte(rest[start+1:], '"')
+			if end < 0 {
+				break
+			}
+			n -= end / 4
+			rest = rest[start+1+end:]
+		}
+	}
+	return n
+}
+
+// Unmarshal implements core.Codec. A request or response is decoded field by
+// field, with its params, results and metadata transcoded to JSON, and a
+// *json.RawMessage gets the whole message transcoded. Anything else is
+// decoded from that JSON with encoding/json. Binary data is transcoded to
+// core.Binary objects.
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	d := decoder{data: data}
+	if isMessage(v) && d.atMap() {
+		m, err := d.message()
+		if err == nil {
+			err = d.end()
+		}
+		if err != nil {
+			return err
+		}
+		return m.store(v)
+	}
+
+	out, err := d.transcode()
+	if err == nil {
+		err = d.end()
+	}
+	if err != nil {
+		return err
+	}
+	if raw, ok := v.(*json.RawMessage); ok {
+		*raw = out
+		return nil
+	}
+	return json.Unmarshal(out, v)
+}
+
+// decoder transcodes MessagePack to JSON.
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+// end checks that the data has been read to its end.
+func (d *decoder) end() error {
+	if d.pos != len(d.data) {
+		return fmt.Errorf("msgpack: %d bytes after the message", len(d.data)-d.pos)
+	}
+	return nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// length reads a size-byte length that must fit in the data left.
+func (d *decoder) length(size int) (int, error) {
+	n, err := d.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.pos) {
+		return 0, errTruncated
+	}
+	return int(n), nil
+}
+
+func (d *decoder) value(out *output) error {
+	b, err := d.next(1)
+	if err != nil {
+		return err
+	}
+	t := b[0]
+	switch {
+	case t <= 0x7f:
+		out.writeInt(int64(t))
+		return nil
+	case t >= 0xe0:
+		out.writeInt(int64(int8(t)))
+		return nil
+	case t&0xe0 == 0xa0:
+		return d.str(out, int(t&0x1f))
+	case t&0xf0 == 0x90:
+		return d.array(out, int(t&0x0f))
+	case t&0xf0 == 0x80:
+		return d.object(out, int(t&0x0f))
+	}
+
+	switch t {
+	case 0xc0:
+		out.writeString("null")
+	case 0xc2:
+		out.writeString("false")
+	case 0xc3:
+		out.writeString("true")
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (t - 0xcc))
+		if err != nil {
+			return err
+		}
+		out.writeUint(u)
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (t - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return err
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*size
+		out.writeInt(int64(u<<shift) >> shift)
+	case 0xca:
+		u, err := d.uint(4)
+		if err != nil {
+			return err
+		}
+		return writeFloat(out, float64(math.Float32frombits(uint32(u))))
+	case 0xcb:
+		u, err := d.uint(8)
+		if err != nil {
+			return err
+		}
+		return writeFloat(out, math.Float64frombits(u))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (t - 0xd9))
+		if err != nil {
+			return err
+		}
+		return d.str(out, n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (t - 0xc4))
+		if err != nil {
+			return err
+		}
+		data, err := d.next(n)
+		if err != nil {
+			return err
+		}
+		out.writeByte('{')
+		out.write(binaryKey)
+		out.writeString(`:"`)
+		out.writeBase64(data)
+		out.writeString(`"}`)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (t - 0xdc))
+		if err != nil {
+			return err
+		}
+		return d.array(out, n)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (t - 0xde))
+		if err != nil {
+			return err
+		}
+		return d.object(out, n)
+	default:
+		return fmt.Errorf("msgpack: unsupported type 0x%02x", t)
+	}
+	return nil
+}
+
+func (d *decoder) str(out *output, n int) error {
+	s, err := d.next(n)
+	if err != nil {
+		return err
+	}
+	out.quote(s)
+	return nil
+}
+
+// quote writes s as a JSON string, escaping what encoding/json would
+// bar HTML characters, and replacing invalid UTF-8 as it does.
+func (out *output) quote(s []byte) {
+	const hex = "0123456789abcdef"
+	out.writeByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' && c < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if c < utf8.RuneSelf {
+			out.write(s[start:i])
+			switch c {
+			case '"', '\\':
+				out.writeByte('\\')
+				out.writeByte(c)
+			case '\n':
+				out.writeString(`\n`)
+			case '\r':
+				out.writeString(`\r`)
+			case '\t':
+				out.writeString(`\t`)
+			default:
+				out.writeString(`\u00`)
+				out.writeByte(hex[c>>4])
+				out.writeByte(hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			out.write(s[start:i])
+			out.writeString(`\ufffd`)
+			i++
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			out.write(s[start:i])
+			out.writeString(`\u202`)
+			out.writeByte(hex[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	out.write(s[start:])
+	out.writeByte('"')
+}
+
+func (d *decoder) array(out *output, n int) error {
+	out.writeByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out.writeByte(',')
+		}
+		if err := d.value(out); err != nil {
+			return err
+		}
+	}
+	out.writeByte(']')
+	return nil
+}
+
+func (d *decoder) object(out *output, n int) error {
+	out.writeByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out.writeByte(',')
+		}
+		b, err := d.next(1)
+		if err != nil {
+			return err
+		}
+		var size int
+		switch t := b[0]; {
+		case t&0xe0 == 0xa0:
+			size = int(t & 0x1f)
+		case t >= 0xd9 && t <= 0xdb:
+			if size, err = d.length(1 << (t - 0xd9)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("msgpack: map keys must be strings, got type 0x%02x", t)
+		}
+		if err := d.str(out, size); err != nil {
+			return err
+		}
+		out.writeByte(':')
+		if err := d.value(out); err != nil {
+			return err
+		}
+	}
+	out.writeByte('}')
+	return nil
+}
+
+func writeFloat(out *output, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("msgpack: %v cannot be represented in a message", f)
+	}
+	var buf [32]byte
+	out.write(strconv.AppendFloat(buf[:0], f, 'g', -1, 64))
+	return nil
+}
+
+// output is where a decoder writes JSON. When sizing it only counts what it
+// would write, so that the JSON can then be written into a buffer of its
+// exact size.
+type output struct {
+	buf    []byte
+	n      int
+	sizing bool
+}
+
+// transcode transcodes the next value to JSON, sizing it first.
+func (d *decoder) transcode() ([]byte, error) {
+	start := d.pos
+	sizer := output{sizing: true}
+	if err := d.value(&sizer); err != nil {
+		return nil, err
+	}
+	d.pos = start
+	out := output{buf: make([]byte, 0, sizer.n)}
+	if err := d.value(&out); err != nil {
+		return nil, err
+	}
+	return out.buf, nil
+}
+
+func (o *output) writeByte(c byte) {
+	if !o.sizing {
+		o.buf = append(o.buf, c)
+	}
+	o.n++
+}
+
+func (o *output) write(b []byte) {
+	if !o.sizing {
+		o.buf = append(o.buf, b...)
+	}
+	o.n += len(b)
+}
+
+func (o *output) writeString(s string) {
+	if !o.sizing {
+		o.buf = append(o.buf, s...)
+	}
+	o.n += len(s)
+}
+
+func (o *output) writeInt(i int64) {
+	var buf [20]byte
+	o.write(strconv.AppendInt(buf[:0], i, 10))
+}
+
+func (o *output) writeUint(u uint64) {
+	var buf [20]byte
+	o.write(strconv.AppendUint(buf[:0], u, 10))
+}
+
+func (o *output) writeBase64(data []byte) {
+	n := base64.StdEncoding.EncodedLen(len(data))
+	if !o.sizing {
+		o.buf = append(o.buf, make([]byte, n)...)
+		base64.StdEncoding.Encode(o.buf[len(o.buf)-n:], data)
+	}
+	o.n += n
+}
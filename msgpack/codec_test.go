@@ -0,0 +1,153 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	values := map[string]interface{}{
+		"null":     nil,
+		"true":     true,
+		"false":    false,
+		"small":    7,
+		"negative": -5,
+		"int8":     -100,
+		"int16":    -30000,
+		"int32":    70000,
+		"int64":    int64(1) << 40,
+		"uint64":   uint64(1) << 63,
+		"float":    3.25,
+		"short":    "short",
+		"string":   strings.Repeat("long ", 100),
+		"escaped":  "quote \" and <tag>",
+		"array":    []interface{}{1, "two", []interface{}{3}},
+		"object":   map[string]interface{}{"nested": map[string]interface{}{"deep": true}},
+		"wide":     strings.Split(strings.Repeat("x,", 20), ","),
+	}
+
+	data, err := Codec.Marshal(values)
+	require.NoError(t, err, "Marshal should succeed")
+	var got map[string]interface{}
+	require.NoError(t, Codec.Unmarshal(data, &got), "Unmarshal should succeed")
+
+	want, err := json.Marshal(values)
+	require.NoError(t, err, "Encoding the values as JSON should succeed")
+	roundTripped, err := json.Marshal(got)
+	require.NoError(t, err, "Encoding the result as JSON should succeed")
+	assert.JSONEq(t, string(want), string(roundTripped), "Values should survive a round trip")
+}
+
+func TestCodecBinary(t *testing.T) {
+	blob := bytes.Repeat([]byte{0, 1, 2, 0xff}, 1<<10)
+	params, err := json.Marshal(map[string]interface{}{"blob": core.Binary(blob)})
+	require.NoError(t, err, "Marshaling params should succeed")
+	raw := json.RawMessage(params)
+	req := &jsonrpc2.Request{Method: core.MethodProcessModel, Params: &raw}
+
+	data, err := Codec.Marshal(req)
+	require.NoError(t, err, "Marshal should succeed")
+	encoded, err := json.Marshal(req)
+	require.NoError(t, err, "JSON marshal should succeed")
+	assert.Less(t, len(data), len(blob)+64, "Binary data should be carried raw")
+	assert.Less(t, len(data), len(encoded)*4/5, "MessagePack should be smaller than JSON for binary data")
+
+	var got jsonrpc2.Request
+	require.NoError(t, Codec.Unmarshal(data, &got), "Unmarshal should succeed")
+	assert.Equal(t, core.MethodProcessModel, got.Method, "Method should round trip")
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(*got.Params, &decoded), "Params should be JSON")
+	value, ok := core.AsBinary(decoded["blob"])
+	require.True(t, ok, "Binary data should decode as a Binary object")
+	assert.Equal(t, blob, value, "Binary data should round trip")
+}
+
+func TestCodecInvalid(t *testing.T) {
+	var got interface{}
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"Truncated", []byte{0xa5, 'a'}, "msgpack: unexpected end of data"},
+		{"LengthBeyondData", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, "msgpack: unexpected end of data"},
+		{"Trailing", []byte{0xc0, 0xc0}, "msgpack: 1 bytes after the message"},
+		{"NonStringKey", []byte{0x81, 0x01, 0x02}, "msgpack: map keys must be strings, got type 0x01"},
+		{"Extension", []byte{0xd4, 0x01, 0x00}, "msgpack: unsupported type 0xd4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, Codec.Unmarshal(tt.data, &got), tt.err, "Invalid data should be rejected")
+		})
+	}
+}
+
+func TestCodecMessages(t *testing.T) {
+	raw := func(s string) *json.RawMessage {
+		r := json.RawMessage(s)
+		return &r
+	}
+	messages := map[string]interface{}{
+		"Call":         &jsonrpc2.Request{Method: "call", Params: raw(`{"text":"tab\tquote\"","n":-7,"f":0.5}`), ID: jsonrpc2.ID{Num: 300}},
+		"StringID":     &jsonrpc2.Request{Method: "call", Params: raw(`[1,2,3]`), ID: jsonrpc2.ID{Str: "42", IsString: true}},
+		"Notification": &jsonrpc2.Request{Method: "notify", Notif: true},
+		"NullParams":   &jsonrpc2.Request{Method: "call", Params: raw(`null`), ID: jsonrpc2.ID{Num: 1}},
+		"Meta":         &jsonrpc2.Request{Method: "call", ID: jsonrpc2.ID{Num: 1}, Meta: raw(`{"trace":"abc"}`)},
+		"Result":       &jsonrpc2.Response{ID: jsonrpc2.ID{Num: 7}, Result: raw(`{"ok":true,"emoji":"😀"}`)},
+		"NullResult":   &jsonrpc2.Response{ID: jsonrpc2.ID{Num: 7}, Result: raw(`null`)},
+		"Error":        &jsonrpc2.Response{ID: jsonrpc2.ID{Num: 7}, Error: &jsonrpc2.Error{Code: -32000, Message: "failed", Data: raw(`{"why":"because"}`)}},
+	}
+	for name, msg := range messages {
+		t.Run(name, func(t *testing.T) {
+			data, err := Codec.Marshal(msg)
+			require.NoError(t, err, "Marshal should succeed")
+
+			var got interface{}
+			switch msg.(type) {
+			case *jsonrpc2.Request:
+				got = new(jsonrpc2.Request)
+			default:
+				got = new(jsonrpc2.Response)
+			}
+			require.NoError(t, Codec.Unmarshal(data, got), "Unmarshal should succeed")
+			want, err := json.Marshal(msg)
+			require.NoError(t, err, "Encoding the message as JSON should succeed")
+			roundTripped, err := json.Marshal(got)
+			require.NoError(t, err, "Encoding the result as JSON should succeed")
+			assert.JSONEq(t, string(want), string(roundTripped), "Message should survive a round trip")
+
+			var transcoded json.RawMessage
+			require.NoError(t, Codec.Unmarshal(data, &transcoded), "Unmarshal into a RawMessage should succeed")
+			assert.JSONEq(t, string(want), string(transcoded), "Message should be transcoded to its JSON")
+		})
+	}
+}
+
+func TestCodecStrings(t *testing.T) {
+	for _, s := range []string{
+		"plain",
+		"control \x00\x01\x1f and \t\r\n",
+		"quote \" and backslash \\",
+		"unicode é € 😀    ",
+		strings.Repeat("long with \"escapes\" ", 20),
+	} {
+		data, err := Codec.Marshal(map[string]string{"s": s})
+		require.NoError(t, err, "Marshal should succeed")
+		var got map[string]string
+		require.NoError(t, Codec.Unmarshal(data, &got), "Unmarshal should succeed")
+		assert.Equal(t, s, got["s"], "String should survive a round trip")
+	}
+}
+
+func TestCodecInvalidParams(t *testing.T) {
+	params := json.RawMessage(`{"a":}`)
+	_, err := Codec.Marshal(&jsonrpc2.Request{Method: "call", Params: &params})
+	assert.EqualError(t, err, `msgpack: invalid JSON: unexpected '}' at offset 5`, "Invalid params should be rejected")
+}
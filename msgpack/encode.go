@@ -0,0 +1,426 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// binaryKey is BinaryKey as it starts the JSON encoding of a core.Binary.
+var binaryKey = []byte(strconv.Quote(core.BinaryKey))
+
+// encoder transcodes JSON to MessagePack in one pass, without decoding it
+// into Go values first.
+type encoder struct {
+	data []byte
+	pos  int
+}
+
+// appendJSON appends the MessagePack encoding of data, a single JSON value.
+func appendJSON(out []byte, data []byte) ([]byte, error) {
+	e := encoder{data: data}
+	out, err := e.value(out)
+	if err != nil {
+		return nil, err
+	}
+	if e.skipSpace(); e.pos != len(data) {
+		return nil, e.invalid()
+	}
+	return out, nil
+}
+
+func (e *encoder) invalid() error {
+	if e.pos >= len(e.data) {
+		return fmt.Errorf("msgpack: invalid JSON: unexpected end of data")
+	}
+	return fmt.Errorf("msgpack: invalid JSON: unexpected %q at offset %d", e.data[e.pos], e.pos)
+}
+
+func (e *encoder) skipSpace() {
+	for e.pos < len(e.data) {
+		switch e.data[e.pos] {
+		case ' ', '\t', '\r', '\n':
+			e.pos++
+		default:
+			return
+		}
+	}
+}
+
+// consume skips space and then c, reporting whether c was there.
+func (e *encoder) consume(c byte) bool {
+	e.skipSpace()
+	if e.pos < len(e.data) && e.data[e.pos] == c {
+		e.pos++
+		return true
+	}
+	return false
+}
+
+func (e *encoder) value(out []byte) ([]byte, error) {
+	e.skipSpace()
+	if e.pos >= len(e.data) {
+		return nil, e.invalid()
+	}
+	switch c := e.data[e.pos]; {
+	case c == '{':
+		if out, ok := e.binary(out); ok {
+			return out, nil
+		}
+		return e.object(out)
+	case c == '[':
+		return e.array(out)
+	case c == '"':
+		return e.string(out)
+	case c == '-' || c >= '0' && c <= '9':
+		start := e.pos
+		for e.pos < len(e.data) && isNumberByte(e.data[e.pos]) {
+			e.pos++
+		}
+		return appendNumber(out, e.data[start:e.pos])
+	}
+	switch {
+	case e.literal("null"):
+		return append(out, 0xc0), nil
+	case e.literal("true"):
+		return append(out, 0xc3), nil
+	case e.literal("false"):
+		return append(out, 0xc2), nil
+	}
+	return nil, e.invalid()
+}
+
+// literal skips text if the data continues with it.
+func (e *encoder) literal(text string) bool {
+	if len(e.data)-e.pos < len(text) || string(e.data[e.pos:e.pos+len(text)]) != text {
+		return false
+	}
+	e.pos += len(text)
+	return true
+}
+
+func isNumberByte(c byte) bool {
+	return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+// string appends the JSON string at the current position as a MessagePack
+// string, unescaping it in place.
+func (e *encoder) string(out []byte) ([]byte, error) {
+	start := e.pos + 1
+	end := start
+	escaped := false
+	for ; end < len(e.data) && e.data[end] != '"'; end++ {
+		if e.data[end] == '\\' {
+			escaped = true
+			end++
+		}
+	}
+	if end >= len(e.data) {
+		e.pos = len(e.data)
+		return nil, e.invalid()
+	}
+	e.pos = end + 1
+	raw := e.data[start:end]
+	if !escaped {
+		return appendString(out, raw), nil
+	}
+
+	// The string is no longer than its escaped form, so write it after a
+	// header for that length and shorten the header once it is known
+	mark := len(out)
+	out = appendStringHeader(out, len(raw))
+	headerLen := len(out) - mark
+	out, err := unescape(out, raw)
+	if err != nil {
+		return nil, err
+	}
+	n := len(out) - mark - headerLen
+	var buf [5]byte
+	header := appendStringHeader(buf[:0], n)
+	copy(out[mark+len(header):], out[mark+headerLen:])
+	copy(out[mark:], header)
+	return out[:mark+len(header)+n], nil
+}
+
+// unescape appends the JSON string content raw with its escapes resolved.
+func unescape(out []byte, raw []byte) ([]byte, error) {
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' {
+			out = append(out, c)
+			continue
+		}
+		if i++; i >= len(raw) {
+			return nil, errors.New("msgpack: invalid JSON: unterminated escape")
+		}
+		switch raw[i] {
+		case '"', '\\', '/':
+			out = append(out, raw[i])
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'u':
+			r, ok := hexRune(raw[i+1:])
+			if !ok {
+				return nil, errors.New("msgpack: invalid JSON: invalid \\u escape")
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				low, ok := rune(-1), false
+				if i+2 < len(raw) && raw[i+1] == '\\' && raw[i+2] == 'u' {
+					low, ok = hexRune(raw[i+3:])
+				}
+				if r = utf16.DecodeRune(r, low); ok && r != utf8.RuneError {
+					i += 6
+				}
+			}
+			out = utf8.AppendRune(out, r)
+		default:
+			return nil, fmt.Errorf("msgpack: invalid JSON: invalid escape %q", raw[i-1:i+1])
+		}
+	}
+	return out, nil
+}
+
+// hexRune parses the four hex digits of a \u escape.
+func hexRune(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+// binary appends the data of a core.Binary object starting at the current
+// position as MessagePack binary data, decoding its base64 in place. It
+// reports false, leaving the position as it was, for any other object.
+func (e *encoder) binary(out []byte) ([]byte, bool) {
+	start := e.pos
+	e.pos++
+	if !e.consume('"') || !bytes.HasPrefix(e.data[e.pos-1:], binaryKey) {
+		e.pos = start
+		return out, false
+	}
+	e.pos += len(binaryKey) - 1
+	if !e.consume(':') || !e.consume('"') {
+		e.pos = start
+		return out, false
+	}
+	end := bytes.IndexByte(e.data[e.pos:], '"')
+	if end < 0 {
+		e.pos = start
+		return out, false
+	}
+	encoded := e.data[e.pos : e.pos+end]
+	e.pos += end + 1
+	if !e.consume('}') || bytes.IndexByte(encoded, '\\') >= 0 {
+		e.pos = start
+		return out, false
+	}
+
+	n := base64.StdEncoding.DecodedLen(len(encoded)) - bytes.Count(encoded[max(len(encoded)-2, 0):], []byte("="))
+	mark := len(out)
+	out = appendBinaryHeader(out, n)
+	out = append(out, make([]byte, n)...)
+	if decoded, err := base64.StdEncoding.Decode(out[len(out)-n:], encoded); err != nil || decoded != n {
+		e.pos = start
+		return out[:mark], false
+	}
+	return out, true
+}
+
+func (e *encoder) object(out []byte) ([]byte, error) {
+	e.pos++
+	mark := len(out)
+	out = append(out, 0x80)
+	n := 0
+	if e.consume('}') {
+		return out, nil
+	}
+	for {
+		if !e.consume('"') {
+			return nil, e.invalid()
+		}
+		e.pos--
+		var err error
+		if out, err = e.string(out); err != nil {
+			return nil, err
+		}
+		if !e.consume(':') {
+			return nil, e.invalid()
+		}
+		if out, err = e.value(out); err != nil {
+			return nil, err
+		}
+		n++
+		if e.consume('}') {
+			return patchLength(out, mark, n, 0x80, 0xde, 0xdf), nil
+		}
+		if !e.consume(',') {
+			return nil, e.invalid()
+		}
+	}
+}
+
+func (e *encoder) array(out []byte) ([]byte, error) {
+	e.pos++
+	mark := len(out)
+	out = append(out, 0x90)
+	n := 0
+	if e.consume(']') {
+		return out, nil
+	}
+	for {
+		var err error
+		if out, err = e.value(out); err != nil {
+			return nil, err
+		}
+		n++
+		if e.consume(']') {
+			return patchLength(out, mark, n, 0x90, 0xdc, 0xdd), nil
+		}
+		if !e.consume(',') {
+			return nil, e.invalid()
+		}
+	}
+}
+
+// patchLength rewrites the one byte header written at mark for a container
+// whose length was not yet known, moving its contents along if n needs a
+// longer header.
+func patchLength(out []byte, mark, n int, fix, b16, b32 byte) []byte {
+	var buf [5]byte
+	header := appendLength(buf[:0], n, fix, b16, b32)
+	if len(header) == 1 {
+		out[mark] = header[0]
+		return out
+	}
+	out = append(out, header[1:]...)
+	copy(out[mark+len(header):], out[mark+1:len(out)-len(header)+1])
+	copy(out[mark:], header)
+	return out
+}
+
+// appendNumber encodes integers in the fewest bytes and everything else as a
+// 64-bit float.
+func appendNumber(out []byte, n []byte) ([]byte, error) {
+	if i, ok := parseInt(n); ok {
+		return appendInt(out, i), nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return binary.BigEndian.AppendUint64(append(out, 0xcf), u), nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: invalid number %q", n)
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xcb), math.Float64bits(f)), nil
+}
+
+// parseInt parses n as strconv.ParseInt(string(n), 10, 64) does for JSON
+// integers, without allocating.
+func parseInt(n []byte) (int64, bool) {
+	digits := n
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		// Longer integers may overflow, so leave them to strconv
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		return i, err == nil
+	}
+	var i int64
+	for _, d := range digits {
+		if d < '0' || d > '9' {
+			return 0, false
+		}
+		i = i*10 + int64(d-'0')
+	}
+	if len(digits) < len(n) {
+		i = -i
+	}
+	return i, true
+}
+
+func appendInt(out []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		return append(out, byte(i))
+	case i < 0 && i >= -32:
+		return append(out, byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(out, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(out, 0xd1), uint16(int16(i)))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(out, 0xd2), uint32(int32(i)))
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xd3), uint64(i))
+}
+
+func appendUint(out []byte, u uint64) []byte {
+	if u <= math.MaxInt64 {
+		return appendInt(out, int64(u))
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xcf), u)
+}
+
+func appendString[S string | []byte](out []byte, s S) []byte {
+	return append(appendStringHeader(out, len(s)), s...)
+}
+
+func appendStringHeader(out []byte, n int) []byte {
+	if n <= 31 {
+		return append(out, 0xa0|byte(n))
+	} else if n <= math.MaxUint8 {
+		return append(out, 0xd9, byte(n))
+	}
+	return appendLength(out, n, 0, 0xda, 0xdb)
+}
+
+func appendBinaryHeader(out []byte, n int) []byte {
+	if n <= math.MaxUint8 {
+		return append(out, 0xc4, byte(n))
+	}
+	return appendLength(out, n, 0, 0xc5, 0xc6)
+}
+
+// appendLength appends the header of a value of length n, in its fix form
+// when fix is non-zero and n is under 16, or else with a 16 or 32 bit length.
+func appendLength(out []byte, n int, fix, b16, b32 byte) []byte {
+	switch {
+	case fix != 0 && n < 16:
+		return append(out, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(out, b16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(out, b32), uint32(n))
+}
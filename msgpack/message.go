@@ -0,0 +1,385 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// jsonNull is the result of a response that has none, as jsonrpc2 sets it.
+var jsonNull = json.RawMessage("null")
+
+var (
+	requestType  = reflect.TypeOf((*jsonrpc2.Request)(nil))
+	responseType = reflect.TypeOf((*jsonrpc2.Response)(nil))
+)
+
+// envelope returns where the request and response of v are kept if v points
+// to the message type jsonrpc2 hands to its ObjectStream, which holds one or
+// the other in unexported fields. Reaching them lets messages be encoded and
+// decoded field by field rather than through their JSON methods.
+func envelope(v interface{}) (**jsonrpc2.Request, **jsonrpc2.Response, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, nil, false
+	}
+	rv = rv.Elem()
+	t := rv.Type()
+	if t.Kind() != reflect.Struct || t.PkgPath() != requestType.Elem().PkgPath() || t.NumField() != 2 ||
+		t.Field(0).Type != requestType || t.Field(1).Type != responseType {
+		return nil, nil, false
+	}
+	req := (**jsonrpc2.Request)(unsafe.Pointer(rv.Field(0).UnsafeAddr()))
+	resp := (**jsonrpc2.Response)(unsafe.Pointer(rv.Field(1).UnsafeAddr()))
+	return req, resp, true
+}
+
+// appendRequest appends req as a MessagePack map with the fields of its JSON
+// encoding.
+func appendRequest(out []byte, req *jsonrpc2.Request) ([]byte, error) {
+	fields := 2
+	if req.Params != nil {
+		fields++
+	}
+	if !req.Notif {
+		fields++
+	}
+	if req.Meta != nil {
+		fields++
+	}
+	out = appendLength(out, fields, 0x80, 0xde, 0xdf)
+	out = appendString(out, "jsonrpc")
+	out = appendString(out, "2.0")
+	out = appendString(out, "method")
+	out = appendString(out, req.Method)
+	var err error
+	if req.Params != nil {
+		out = appendString(out, "params")
+		if out, err = appendRaw(out, *req.Params); err != nil {
+			return nil, err
+		}
+	}
+	if !req.Notif {
+		out = appendString(out, "id")
+		out = appendID(out, req.ID)
+	}
+	if req.Meta != nil {
+		out = appendString(out, "meta")
+		if out, err = appendRaw(out, *req.Meta); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// appendResponse appends resp as a MessagePack map with the fields of its
+// JSON encoding.
+func appendResponse(out []byte, resp *jsonrpc2.Response) ([]byte, error) {
+	if (resp.Result == nil || len(*resp.Result) == 0) && resp.Error == nil {
+		return nil, errors.New("msgpack: a response must have a result or an error")
+	}
+	fields := 3
+	if resp.Meta != nil {
+		fields++
+	}
+	out = appendLength(out, fields, 0x80, 0xde, 0xdf)
+	out = appendString(out, "jsonrpc")
+	out = appendString(out, "2.0")
+	out = appendString(out, "id")
+	out = appendID(out, resp.ID)
+	var err error
+	if resp.Error != nil {
+		out = appendString(out, "error")
+		out = appendLength(out, 3, 0x80, 0xde, 0xdf)
+		out = appendString(out, "code")
+		out = appendInt(out, resp.Error.Code)
+		out = appendString(out, "message")
+		out = appendString(out, resp.Error.Message)
+		out = appendString(out, "data")
+		if resp.Error.Data == nil {
+			out = append(out, 0xc0)
+		} else if out, err = appendRaw(out, *resp.Error.Data); err != nil {
+			return nil, err
+		}
+	} else {
+		out = appendString(out, "result")
+		if out, err = appendRaw(out, *resp.Result); err != nil {
+			return nil, err
+		}
+	}
+	if resp.Meta != nil {
+		out = appendString(out, "meta")
+		if out, err = appendRaw(out, *resp.Meta); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// appendRaw appends a JSON value as MessagePack, with an empty one as null.
+func appendRaw(out []byte, raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 {
+		return append(out, 0xc0), nil
+	}
+	return appendJSON(out, raw)
+}
+
+func appendID(out []byte, id jsonrpc2.ID) []byte {
+	if id.IsString {
+		return appendString(out, id.Str)
+	}
+	return appendUint(out, id.Num)
+}
+
+// message is a request or response as read field by field.
+type message struct {
+	method    *string
+	id        *jsonrpc2.ID
+	params    *json.RawMessage
+	result    *json.RawMessage
+	err       *jsonrpc2.Error
+	meta      *json.RawMessage
+	hasParams bool
+	hasResult bool
+}
+
+// message decodes a MessagePack map holding a request or response.
+func (d *decoder) message() (*message, error) {
+	n, err := d.mapLength()
+	if err != nil {
+		return nil, err
+	}
+	m := new(message)
+	for i := 0; i < n; i++ {
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		switch string(key) {
+		case "method":
+			s, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			m.method = &s
+		case "id":
+			if m.id, err = d.id(); err != nil {
+				return nil, err
+			}
+		case "params":
+			m.hasParams = true
+			if m.params, err = d.raw(); err != nil {
+				return nil, err
+			}
+		case "result":
+			m.hasResult = true
+			if m.result, err = d.raw(); err != nil {
+				return nil, err
+			}
+		case "error":
+			raw, err := d.raw()
+			if err != nil {
+				return nil, err
+			}
+			if raw != nil {
+				m.err = new(jsonrpc2.Error)
+				if err := json.Unmarshal(*raw, m.err); err != nil {
+					return nil, err
+				}
+			}
+		case "meta":
+			if m.meta, err = d.raw(); err != nil {
+				return nil, err
+			}
+		default:
+			if _, err := d.raw(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	isRequest := m.method != nil
+	isResponse := m.hasResult || m.err != nil
+	if isRequest == isResponse {
+		return nil, errors.New("msgpack: unable to determine message type (request or response)")
+	}
+	return m, nil
+}
+
+// isMessage reports whether v takes a single request or response.
+func isMessage(v interface{}) bool {
+	switch v.(type) {
+	case *jsonrpc2.Request, *jsonrpc2.Response:
+		return true
+	}
+	_, _, ok := envelope(v)
+	return ok
+}
+
+// store sets v, which isMessage, to m.
+func (m *message) store(v interface{}) error {
+	var err error
+	switch target := v.(type) {
+	case *jsonrpc2.Request:
+		var req *jsonrpc2.Request
+		if req, err = m.request(); err == nil {
+			*target = *req
+		}
+	case *jsonrpc2.Response:
+		var resp *jsonrpc2.Response
+		if resp, err = m.response(); err == nil {
+			*target = *resp
+		}
+	default:
+		req, resp, _ := envelope(v)
+		if m.method != nil {
+			*req, err = m.request()
+		} else {
+			*resp, err = m.response()
+		}
+	}
+	return err
+}
+
+// request returns m as a request, as jsonrpc2 decodes one from JSON.
+func (m *message) request() (*jsonrpc2.Request, error) {
+	if m.method == nil {
+		return nil, errors.New("msgpack: message is not a request")
+	}
+	req := &jsonrpc2.Request{Method: *m.method, Params: m.params, Meta: m.meta}
+	if m.hasParams && m.params == nil {
+		req.Params = &jsonNull
+	}
+	if m.id == nil {
+		req.Notif = true
+	} else {
+		req.ID = *m.id
+	}
+	return req, nil
+}
+
+// response returns m as a response, as jsonrpc2 decodes one from JSON.
+func (m *message) response() (*jsonrpc2.Response, error) {
+	if m.method != nil {
+		return nil, errors.New("msgpack: message is not a response")
+	}
+	resp := &jsonrpc2.Response{Result: m.result, Error: m.err, Meta: m.meta}
+	if m.id != nil {
+		resp.ID = *m.id
+	}
+	if resp.Result == nil && resp.Error == nil {
+		resp.Result = &jsonNull
+	}
+	return resp, nil
+}
+
+// atMap reports whether the next value is a map. Anything else, such as a
+// batch, is not a single message.
+func (d *decoder) atMap() bool {
+	if d.pos >= len(d.data) {
+		return false
+	}
+	t := d.data[d.pos]
+	return t&0xf0 == 0x80 || t == 0xde || t == 0xdf
+}
+
+// mapLength reads the header of a map.
+func (d *decoder) mapLength() (int, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	switch t := b[0]; {
+	case t&0xf0 == 0x80:
+		return int(t & 0x0f), nil
+	case t == 0xde || t == 0xdf:
+		return d.length(2 << (t - 0xde))
+	default:
+		return 0, fmt.Errorf("msgpack: message must be a map, got type 0x%02x", t)
+	}
+}
+
+// key reads a map key, which must be a string.
+func (d *decoder) key() ([]byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	var size int
+	switch t := b[0]; {
+	case t&0xe0 == 0xa0:
+		size = int(t & 0x1f)
+	case t >= 0xd9 && t <= 0xdb:
+		if size, err = d.length(1 << (t - 0xd9)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("msgpack: map keys must be strings, got type 0x%02x", t)
+	}
+	return d.next(size)
+}
+
+// string reads a string value.
+func (d *decoder) string() (string, error) {
+	if d.pos < len(d.data) && d.data[d.pos] == 0xc0 {
+		return "", errors.New("msgpack: expected a string, got null")
+	}
+	s, err := d.key()
+	if err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+// id reads a request ID, a non-negative integer or a string, or null.
+func (d *decoder) id() (*jsonrpc2.ID, error) {
+	if d.pos >= len(d.data) {
+		return nil, errTruncated
+	}
+	switch t := d.data[d.pos]; {
+	case t == 0xc0:
+		d.pos++
+		return nil, nil
+	case t&0xe0 == 0xa0 || t >= 0xd9 && t <= 0xdb:
+		s, err := d.string()
+		return &jsonrpc2.ID{Str: s, IsString: true}, err
+	case t <= 0x7f:
+		d.pos++
+		return &jsonrpc2.ID{Num: uint64(t)}, nil
+	case t >= 0xcc && t <= 0xcf:
+		d.pos++
+		u, err := d.uint(1 << (t - 0xcc))
+		return &jsonrpc2.ID{Num: u}, err
+	case t >= 0xd0 && t <= 0xd3:
+		d.pos++
+		size := 1 << (t - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		if shift := 64 - 8*size; int64(u<<shift)>>shift >= 0 {
+			return &jsonrpc2.ID{Num: u}, nil
+		}
+		return nil, errors.New("msgpack: id must not be negative")
+	default:
+		return nil, fmt.Errorf("msgpack: id must be a non-negative integer or a string, got type 0x%02x", t)
+	}
+}
+
+// raw transcodes the next value to JSON, returning nil for null.
+func (d *decoder) raw() (*json.RawMessage, error) {
+	if d.pos < len(d.data) && d.data[d.pos] == 0xc0 {
+		d.pos++
+		return nil, nil
+	}
+	out, err := d.transcode()
+	if err != nil {
+		return nil, err
+	}
+	raw := json.RawMessage(out)
+	return &raw, nil
+}
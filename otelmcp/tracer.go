@@ -0,0 +1,124 @@
+// Package otelmcp traces MCP round trips with OpenTelemetry. It is kept
+// separate from the core, client and server packages so that only programs
+// using OpenTelemetry depend on it.
+package otelmcp
+
+import (
+	"context"
+
+	"github.com/narcolepticfox/mcp/core"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the source of its spans.
+const instrumentationName = "github.com/narcolepticfox/mcp/otelmcp"
+
+// Span attribute keys.
+const (
+	AttrSystem    = attribute.Key("rpc.system")     // Always "mcp"
+	AttrMethod    = attribute.Key("rpc.method")     // JSON-RPC method, e.g. mcp.processModel
+	AttrRequestID = attribute.Key("mcp.request_id") // ID of the model request
+	AttrSuccess   = attribute.Key("mcp.success")    // Whether the request succeeded
+)
+
+// Options holds configuration parameters for a Tracer.
+type Options struct {
+	TracerProvider trace.TracerProvider          // Source of the tracer; defaults to the global provider
+	Propagator     propagation.TextMapPropagator // Format of the trace context in request metadata
+}
+
+// DefaultOptions returns the default tracer options: the global tracer
+// provider and W3C trace context propagation.
+func DefaultOptions() Options {
+	return Options{
+		TracerProvider: otel.GetTracerProvider(),
+		Propagator:     propagation.TraceContext{},
+	}
+}
+
+// Option is a function type that modifies Options.
+type Option func(*Options)
+
+// WithTracerProvider sets the provider spans are created with.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *Options) {
+		o.TracerProvider = provider
+	}
+}
+
+// WithPropagator sets how the trace context is written to and read from
+// request metadata.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(o *Options) {
+		o.Propagator = propagator
+	}
+}
+
+// Tracer implements core.Tracer with OpenTelemetry, so it can be passed to
+// client.WithTracer and server.WithTracer. The client side injects the span's
+// trace context into the request metadata, also setting core.MetadataTraceID
+// if the request has none; the server side extracts it and starts a child span
+// around the handler, which can continue the trace from its context.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// New creates a tracer with the given options.
+func New(options ...Option) *Tracer {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	return &Tracer{
+		tracer:     opts.TracerProvider.Tracer(instrumentationName),
+		propagator: opts.Propagator,
+	}
+}
+
+// StartSpan implements core.Tracer.
+func (t *Tracer) StartSpan(ctx context.Context, kind core.SpanKind, method, requestID string) (context.Context, func(error)) {
+	attrs := []attribute.KeyValue{AttrSystem.String("mcp"), AttrMethod.String(method)}
+	if requestID != "" {
+		attrs = append(attrs, AttrRequestID.String(requestID))
+	}
+
+	spanKind := trace.SpanKindClient
+	if kind == core.SpanServer {
+		spanKind = trace.SpanKindServer
+		ctx = t.propagator.Extract(ctx, propagation.MapCarrier(metadata(ctx)))
+	}
+
+	ctx, span := t.tracer.Start(ctx, method, trace.WithSpanKind(spanKind), trace.WithAttributes(attrs...))
+
+	if kind == core.SpanClient {
+		md := metadata(ctx)
+		t.propagator.Inject(ctx, propagation.MapCarrier(md))
+		if _, ok := md[core.MetadataTraceID]; !ok {
+			md[core.MetadataTraceID] = span.SpanContext().TraceID().String()
+		}
+		ctx = core.ContextWithMetadata(ctx, md)
+	}
+
+	return ctx, func(err error) {
+		span.SetAttributes(AttrSuccess.Bool(err == nil))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// metadata returns a copy of the request metadata in ctx, never nil.
+func metadata(ctx context.Context) map[string]string {
+	if md := core.MetadataFromContext(ctx); md != nil {
+		return md
+	}
+	return make(map[string]string)
+}
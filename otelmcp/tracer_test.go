@@ -0,0 +1,127 @@
+package otelmcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedModelHandler reports the span it runs under and fails on request
+type tracedModelHandler struct{}
+
+func (h *tracedModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *tracedModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if req.ModelData["fail"] == true {
+		return nil, errors.New("asked to fail")
+	}
+	resp := core.NewModelResponse(req)
+	resp.Results["span"] = trace.SpanContextFromContext(ctx).SpanID().String()
+	return resp, nil
+}
+
+// startTracedPair starts a server and client that export spans to the returned recorder
+func startTracedPair(t *testing.T) (*client.Client, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false),
+				client.WithTracer(New(WithTracerProvider(provider))))
+		},
+		func(transport core.Transport) *server.Server {
+			srv := server.New(server.WithTransport(transport), server.WithTracer(New(WithTracerProvider(provider))))
+			require.NoError(t, srv.RegisterHandler(&tracedModelHandler{}), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c, recorder
+}
+
+// spanOfKind returns the single ended span of the given kind
+func spanOfKind(t *testing.T, recorder *tracetest.SpanRecorder, kind trace.SpanKind) sdktrace.ReadOnlySpan {
+	var found []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == kind {
+			found = append(found, span)
+		}
+	}
+	require.Len(t, found, 1, "There should be one %s span", kind)
+	return found[0]
+}
+
+// attributes returns the attributes of span as a map
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracerRoundTrip(t *testing.T) {
+	c, recorder := startTracedPair(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	resp, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "ProcessModel should succeed")
+
+	clientSpan := spanOfKind(t, recorder, trace.SpanKindClient)
+	serverSpan := spanOfKind(t, recorder, trace.SpanKindServer)
+
+	// The server span continues the client's trace
+	assert.Equal(t, clientSpan.SpanContext().TraceID(), serverSpan.SpanContext().TraceID(), "Spans should share a trace")
+	assert.Equal(t, clientSpan.SpanContext().SpanID(), serverSpan.Parent().SpanID(), "Server span should be a child of the client span")
+	assert.True(t, serverSpan.Parent().IsRemote(), "Parent should arrive from the client")
+	assert.Equal(t, serverSpan.SpanContext().SpanID().String(), resp.Results["span"], "Handler should run under the server span")
+	assert.Equal(t, clientSpan.SpanContext().TraceID().String(), resp.Metadata[core.MetadataTraceID], "Trace ID should be echoed")
+
+	for _, span := range []sdktrace.ReadOnlySpan{clientSpan, serverSpan} {
+		assert.Equal(t, core.MethodProcessModel, span.Name(), "Span should be named after the method")
+		attrs := attributes(span)
+		assert.Equal(t, core.MethodProcessModel, attrs[AttrMethod].AsString(), "Span should record the method")
+		assert.Equal(t, req.ID, attrs[AttrRequestID].AsString(), "Span should record the request ID")
+		assert.True(t, attrs[AttrSuccess].AsBool(), "Span should record success")
+		assert.Equal(t, codes.Unset, span.Status().Code, "Successful span should have no error status")
+	}
+}
+
+func TestTracerRecordsErrors(t *testing.T) {
+	c, recorder := startTracedPair(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["fail"] = true
+	_, err := c.ProcessModel(ctx, req)
+	require.Error(t, err, "ProcessModel should fail")
+
+	serverSpan := spanOfKind(t, recorder, trace.SpanKindServer)
+	assert.False(t, attributes(serverSpan)[AttrSuccess].AsBool(), "Server span should record the failure")
+	assert.Equal(t, codes.Error, serverSpan.Status().Code, "Server span should have an error status")
+	assert.Contains(t, serverSpan.Status().Description, "asked to fail", "Server span should carry the handler error")
+
+	clientSpan := spanOfKind(t, recorder, trace.SpanKindClient)
+	assert.Equal(t, codes.Error, clientSpan.Status().Code, "Client span should have an error status")
+}
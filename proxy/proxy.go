@@ -0,0 +1,172 @@
+// Package proxy forwards model requests from an MCP server to a set of
+// backend servers. A Proxy is a server.ModelHandler: register it with a server
+// and it routes each request to a healthy backend chosen by the route's
+// Strategy.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ErrNoHealthyBackend is returned when every backend of the matching route is unhealthy.
+var ErrNoHealthyBackend = errors.New("no healthy backend")
+
+// ModelProcessor is the connection to a backend. *client.Client satisfies it.
+type ModelProcessor interface {
+	ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error)
+	IsConnected() bool
+}
+
+// Backend is a named server requests can be forwarded to. It is healthy while
+// its client is connected.
+type Backend struct {
+	Name   string         // Unique name, used for hashing and in stats
+	Client ModelProcessor // Connection to the backend server
+}
+
+// Route sends the requests it matches to one of its backends.
+type Route struct {
+	Name     string                            // Name for log records
+	Match    func(req *core.ModelRequest) bool // Selects the requests for this route; nil matches all
+	Backends []*Backend                        // Backends requests may be sent to
+	Strategy Strategy                          // Picks a backend for each request; nil picks at random
+}
+
+// BackendStats reports the requests a proxy has sent to one backend.
+type BackendStats struct {
+	Healthy  bool   `json:"healthy"`  // Whether the backend is currently connected
+	Requests uint64 `json:"requests"` // Requests forwarded to the backend
+	Failures uint64 `json:"failures"` // Forwarded requests that returned an error
+}
+
+// Options holds configuration parameters for a Proxy.
+type Options struct {
+	Logger core.Logger // Receives structured log records; defaults to slog.Default
+	Routes []Route     // Routes tried in order; the first match handles the request
+}
+
+// DefaultOptions returns the default proxy options, with no routes.
+func DefaultOptions() Options {
+	return Options{
+		Logger: core.DefaultLogger(),
+	}
+}
+
+// Option is a function type that modifies Options.
+type Option func(*Options)
+
+// WithLogger sets the logger that receives the proxy's log records.
+func WithLogger(logger core.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithRoute adds a route after those already configured.
+func WithRoute(route Route) Option {
+	return func(o *Options) {
+		o.Routes = append(o.Routes, route)
+	}
+}
+
+// backendCounters accumulates the stats of one backend.
+type backendCounters struct {
+	requests uint64
+	failures uint64
+}
+
+// Proxy forwards mcp.processModel requests to backends.
+type Proxy struct {
+	options  Options
+	counters map[*Backend]*backendCounters // Fixed once created
+}
+
+// New creates a proxy with the given options.
+func New(options ...Option) *Proxy {
+	opts := DefaultOptions()
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	counters := make(map[*Backend]*backendCounters)
+	for i := range opts.Routes {
+		if opts.Routes[i].Strategy == nil {
+			opts.Routes[i].Strategy = Random()
+		}
+		for _, backend := range opts.Routes[i].Backends {
+			counters[backend] = &backendCounters{}
+		}
+	}
+
+	return &Proxy{
+		options:  opts,
+		counters: counters,
+	}
+}
+
+// Methods returns the model processing method.
+func (p *Proxy) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+// ProcessModel forwards req to a healthy backend of the first route matching it.
+func (p *Proxy) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	route, ok := p.route(req)
+	if !ok {
+		return nil, fmt.Errorf("no route for request %s", req.ID)
+	}
+
+	healthy := make([]*Backend, 0, len(route.Backends))
+	for _, backend := range route.Backends {
+		if backend.Client.IsConnected() {
+			healthy = append(healthy, backend)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("route %s: %w", route.Name, ErrNoHealthyBackend)
+	}
+
+	backend := route.Strategy.Pick(req, healthy)
+	counters := p.counters[backend]
+	atomic.AddUint64(&counters.requests, 1)
+
+	resp, err := backend.Client.ProcessModel(ctx, req)
+	if err != nil {
+		atomic.AddUint64(&counters.failures, 1)
+		p.options.Logger.Warn("Backend request failed",
+			"route", route.Name,
+			"backend", backend.Name,
+			core.LogFieldRequestID, req.ID,
+			core.LogFieldError, err)
+		return nil, fmt.Errorf("backend %s: %w", backend.Name, err)
+	}
+	return resp, nil
+}
+
+// route returns the first route matching req.
+func (p *Proxy) route(req *core.ModelRequest) (Route, bool) {
+	for _, route := range p.options.Routes {
+		if route.Match == nil || route.Match(req) {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// Stats returns the distribution of forwarded requests, by backend name.
+func (p *Proxy) Stats() map[string]BackendStats {
+	stats := make(map[string]BackendStats, len(p.counters))
+	for backend, counters := range p.counters {
+		stats[backend.Name] = BackendStats{
+			Healthy:  backend.Client.IsConnected(),
+			Requests: atomic.LoadUint64(&counters.requests),
+			Failures: atomic.LoadUint64(&counters.failures),
+		}
+	}
+	return stats
+}
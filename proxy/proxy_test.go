@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend answers every request with its own name and can be marked unhealthy
+type fakeBackend struct {
+	name     string
+	down     atomic.Bool
+	failWith error
+}
+
+func (b *fakeBackend) IsConnected() bool { return !b.down.Load() }
+
+func (b *fakeBackend) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if b.failWith != nil {
+		return nil, b.failWith
+	}
+	resp := core.NewModelResponse(req)
+	resp.Results["backend"] = b.name
+	return resp, nil
+}
+
+// newBackends creates n fake backends named b0, b1, ...
+func newBackends(n int) ([]*Backend, []*fakeBackend) {
+	backends := make([]*Backend, n)
+	fakes := make([]*fakeBackend, n)
+	for i := range backends {
+		fakes[i] = &fakeBackend{name: fmt.Sprintf("b%d", i)}
+		backends[i] = &Backend{Name: fakes[i].name, Client: fakes[i]}
+	}
+	return backends, fakes
+}
+
+// modelRequest creates a request for the named model
+func modelRequest(name string) *core.ModelRequest {
+	req := core.NewModelRequest()
+	req.ModelData["name"] = name
+	return req
+}
+
+// assignments routes a request for each model and returns the backend that answered it
+func assignments(t *testing.T, p *Proxy, models []string) map[string]string {
+	owners := make(map[string]string, len(models))
+	for _, model := range models {
+		resp, err := p.ProcessModel(context.Background(), modelRequest(model))
+		require.NoError(t, err, "Request should be forwarded")
+		owners[model] = resp.Results["backend"].(string)
+	}
+	return owners
+}
+
+// testModels returns a fixed set of model names
+func testModels() []string {
+	models := make([]string, 1000)
+	for i := range models {
+		models[i] = fmt.Sprintf("model-%d", i)
+	}
+	return models
+}
+
+func TestConsistentHashStableAssignment(t *testing.T) {
+	backends, _ := newBackends(4)
+	p := New(WithRoute(Route{Name: "models", Backends: backends, Strategy: ConsistentHash(ModelDataKey("name"))}))
+
+	models := testModels()
+	first := assignments(t, p, models)
+	assert.Equal(t, first, assignments(t, p, models), "Same keys should reach the same backends")
+
+	// The same ring in a new proxy assigns keys identically
+	other := New(WithRoute(Route{Name: "models", Backends: backends, Strategy: ConsistentHash(ModelDataKey("name"))}))
+	assert.Equal(t, first, assignments(t, other, models), "Assignment should not depend on the proxy instance")
+
+	// Every backend takes a reasonable share
+	stats := p.Stats()
+	for _, backend := range backends {
+		share := float64(stats[backend.Name].Requests) / float64(2*len(models))
+		assert.InDelta(t, 0.25, share, 0.1, "Backend %s should get about a quarter of the keys", backend.Name)
+		assert.True(t, stats[backend.Name].Healthy, "Backend should be reported healthy")
+	}
+}
+
+func TestConsistentHashFailover(t *testing.T) {
+	backends, fakes := newBackends(4)
+	p := New(WithRoute(Route{Name: "models", Backends: backends, Strategy: ConsistentHash(ModelDataKey("name"))}))
+
+	models := testModels()
+	before := assignments(t, p, models)
+
+	// Only keys of the unhealthy backend move
+	fakes[2].down.Store(true)
+	during := assignments(t, p, models)
+	for _, model := range models {
+		if before[model] == "b2" {
+			assert.NotEqual(t, "b2", during[model], "Keys should fail over from the unhealthy backend")
+		} else {
+			assert.Equal(t, before[model], during[model], "Keys of healthy backends should not move")
+		}
+	}
+	assert.False(t, p.Stats()["b2"].Healthy, "Unhealthy backend should be reported")
+
+	// They return once it recovers
+	fakes[2].down.Store(false)
+	assert.Equal(t, before, assignments(t, p, models), "Keys should return to the recovered backend")
+}
+
+func TestProxyRouting(t *testing.T) {
+	backends, fakes := newBackends(2)
+	fakes[1].failWith = errors.New("backend crashed")
+	p := New(
+		WithLogger(core.NopLogger()),
+		WithRoute(Route{
+			Name:     "pinned",
+			Match:    func(req *core.ModelRequest) bool { return req.ModelData["name"] == "pinned" },
+			Backends: backends[1:],
+		}),
+		WithRoute(Route{Name: "default", Backends: backends[:1]}),
+	)
+
+	// Routes are tried in order and default to random picks
+	resp, err := p.ProcessModel(context.Background(), modelRequest("anything"))
+	require.NoError(t, err, "Default route should forward")
+	assert.Equal(t, "b0", resp.Results["backend"], "Unmatched request should take the default route")
+
+	_, err = p.ProcessModel(context.Background(), modelRequest("pinned"))
+	assert.ErrorContains(t, err, "backend b1: backend crashed", "Backend errors should be returned")
+	assert.Equal(t, BackendStats{Healthy: true, Requests: 1, Failures: 1}, p.Stats()["b1"], "Failure should be counted")
+
+	fakes[0].down.Store(true)
+	_, err = p.ProcessModel(context.Background(), modelRequest("anything"))
+	assert.ErrorIs(t, err, ErrNoHealthyBackend, "Route without healthy backends should fail")
+
+	_, err = New().ProcessModel(context.Background(), modelRequest("anything"))
+	assert.ErrorContains(t, err, "no route", "Proxy without routes should fail")
+}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// defaultReplicas is how many points each backend gets on a hash ring.
+const defaultReplicas = 100
+
+// Strategy picks the backend for a request from the route's healthy
+// backends, of which there is always at least one.
+type Strategy interface {
+	Pick(req *core.ModelRequest, healthy []*Backend) *Backend
+}
+
+// StrategyFunc adapts a function to the Strategy interface.
+type StrategyFunc func(req *core.ModelRequest, healthy []*Backend) *Backend
+
+// Pick calls f(req, healthy).
+func (f StrategyFunc) Pick(req *core.ModelRequest, healthy []*Backend) *Backend {
+	return f(req, healthy)
+}
+
+// Random returns the default strategy, which picks a healthy backend at random.
+func Random() Strategy {
+	return StrategyFunc(func(_ *core.ModelRequest, healthy []*Backend) *Backend {
+		return healthy[rand.Intn(len(healthy))]
+	})
+}
+
+// KeyFunc extracts the affinity key of a request. Requests with the same key
+// are sent to the same backend while it is healthy.
+type KeyFunc func(req *core.ModelRequest) string
+
+// ModelDataKey returns a KeyFunc using the ModelData field of the given name,
+// e.g. "name", or "" when the field is absent.
+func ModelDataKey(field string) KeyFunc {
+	return func(req *core.ModelRequest) string {
+		value, ok := req.ModelData[field]
+		if !ok || value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+}
+
+// ConsistentHash returns a strategy that hashes each request's key onto a
+// ring of the healthy backends, so requests with the same key reach the same
+// backend and its caches stay warm. When a backend becomes unhealthy only its
+// keys move, spread over the others, and they move back once it recovers.
+// Requests without a key are sent to a random backend.
+func ConsistentHash(key KeyFunc) Strategy {
+	return &consistentHash{key: key, replicas: defaultReplicas}
+}
+
+// consistentHash implements ConsistentHash, caching the ring for the last
+// set of healthy backends.
+type consistentHash struct {
+	key      KeyFunc
+	replicas int
+
+	mu      sync.Mutex
+	members string
+	ring    *hashRing
+}
+
+// Pick returns the backend owning the request's key.
+func (c *consistentHash) Pick(req *core.ModelRequest, healthy []*Backend) *Backend {
+	key := c.key(req)
+	if key == "" {
+		return healthy[rand.Intn(len(healthy))]
+	}
+	return c.ringFor(healthy).owner(key)
+}
+
+// ringFor returns the ring of the given backends, rebuilding it only when the
+// set of healthy backends has changed.
+func (c *consistentHash) ringFor(backends []*Backend) *hashRing {
+	names := make([]string, len(backends))
+	for i, backend := range backends {
+		names[i] = backend.Name
+	}
+	sort.Strings(names)
+	members := strings.Join(names, "\x00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ring == nil || c.members != members {
+		c.ring = newHashRing(backends, c.replicas)
+		c.members = members
+	}
+	return c.ring
+}
+
+// hashRing places several points per backend on a 64-bit hash circle.
+type hashRing struct {
+	points []uint64
+	owners map[uint64]*Backend
+}
+
+func newHashRing(backends []*Backend, replicas int) *hashRing {
+	ring := &hashRing{
+		points: make([]uint64, 0, len(backends)*replicas),
+		owners: make(map[uint64]*Backend, len(backends)*replicas),
+	}
+	for _, backend := range backends {
+		for i := 0; i < replicas; i++ {
+			point := hashKey(backend.Name + "#" + strconv.Itoa(i))
+			if _, taken := ring.owners[point]; taken {
+				continue
+			}
+			ring.owners[point] = backend
+			ring.points = append(ring.points, point)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// owner returns the backend of the first point at or after the key's hash.
+func (r *hashRing) owner(key string) *Backend {
+	hash := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// hashKey hashes s with FNV-1a, mixing the result so that similar strings,
+// such as the points of one backend, land far apart on the ring.
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
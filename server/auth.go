@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// AuthVerifier checks the credentials a client presents for one auth scheme.
+// It returns the authenticated principal, whose ExpiresAt bounds how long the
+// session is trusted before the credentials are verified again. Verifiers
+// that return a *core.AuthError have its reason passed on to the client.
+type AuthVerifier interface {
+	Verify(ctx context.Context, credentials map[string]string) (*core.Principal, error)
+}
+
+// AuthVerifierFunc adapts a function to the AuthVerifier interface.
+type AuthVerifierFunc func(ctx context.Context, credentials map[string]string) (*core.Principal, error)
+
+// Verify calls f(ctx, credentials).
+func (f AuthVerifierFunc) Verify(ctx context.Context, credentials map[string]string) (*core.Principal, error) {
+	return f(ctx, credentials)
+}
+
+// Authenticator accepts or rejects the credentials a client presents,
+// returning the principal handlers will see for the connection.
+type Authenticator func(ctx context.Context, credentials core.Credentials) (core.Principal, error)
+
+// RegisterAuthScheme registers a verifier for the named auth scheme. Once any
+// scheme is registered, clients must authenticate with one of them before
+// calling other methods. Returns an error if the scheme is already registered.
+func (s *Server) RegisterAuthScheme(name string, verifier AuthVerifier) error {
+	if _, exists := s.authSchemes[name]; exists {
+		return fmt.Errorf("auth scheme %s already registered", name)
+	}
+	s.authSchemes[name] = verifier
+	return nil
+}
+
+// authRequired reports whether clients must authenticate before calling
+// methods other than ping.
+func (s *Server) authRequired() bool {
+	return len(s.authSchemes) > 0 || s.options.Authenticator != nil
+}
+
+// verifier returns the verifier for scheme: the one registered for it, or
+// else the authenticator set with WithAuthenticator.
+func (s *Server) verifier(scheme string) (AuthVerifier, bool) {
+	if verifier, ok := s.authSchemes[scheme]; ok {
+		return verifier, true
+	}
+	authenticate := s.options.Authenticator
+	if authenticate == nil {
+		return nil, false
+	}
+	return AuthVerifierFunc(func(ctx context.Context, credentials map[string]string) (*core.Principal, error) {
+		principal, err := authenticate(ctx, core.Credentials{Scheme: scheme, Values: credentials})
+		if err != nil {
+			return nil, err
+		}
+		return &principal, nil
+	}), true
+}
+
+// session holds the authentication and handshake state of one connection.
+type session struct {
+	mu           sync.Mutex
+	scheme       string
+	credentials  map[string]string
+	principal    *core.Principal
+	capabilities *core.Capabilities // Negotiated in the handshake; nil until the client initializes
+}
+
+// handleAuthenticate verifies the credentials a client presents and caches
+// the resulting principal on the connection's session.
+func (h *rpcHandler) handleAuthenticate(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	var authReq core.AuthRequest
+	if req.Params == nil {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing credentials")
+		return
+	}
+	if err := json.Unmarshal(*req.Params, &authReq); err != nil {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+		return
+	}
+
+	verifier, ok := h.server.verifier(authReq.Scheme)
+	if !ok {
+		h.replyError(ctx, conn, req, core.CodeUnauthenticated, fmt.Sprintf("unknown auth scheme: %s", authReq.Scheme))
+		return
+	}
+
+	principal, err := verifier.Verify(ctx, authReq.Credentials)
+	if err != nil {
+		h.server.options.Logger.Warn("Authentication failed",
+			core.LogFieldRemoteAddr, h.remoteAddr,
+			"scheme", authReq.Scheme,
+			core.LogFieldError, err)
+		rpcErr := &jsonrpc2.Error{Code: core.CodeUnauthenticated, Message: "authentication failed"}
+		var authErr *core.AuthError
+		if errors.As(err, &authErr) {
+			rpcErr.Message += ": " + authErr.Reason
+			rpcErr.SetError(core.AuthErrorData{Reason: authErr.Reason})
+		}
+		h.replyRPCError(ctx, conn, req, rpcErr)
+		return
+	}
+
+	h.session.mu.Lock()
+	h.session.scheme = authReq.Scheme
+	h.session.credentials = authReq.Credentials
+	h.session.principal = principal
+	h.session.mu.Unlock()
+
+	h.server.options.Logger.Debug("Client authenticated",
+		core.LogFieldRemoteAddr, h.remoteAddr,
+		"scheme", authReq.Scheme,
+		"principal", principal.ID)
+	h.reply(ctx, conn, req, core.AuthResponse{Principal: *principal})
+}
+
+// authorize returns the principal for the connection, verifying the cached
+// credentials again once the principal has expired. It returns an error if
+// the connection is not authenticated or the credentials no longer verify.
+func (h *rpcHandler) authorize(ctx context.Context) (*core.Principal, error) {
+	h.session.mu.Lock()
+	defer h.session.mu.Unlock()
+
+	if h.session.principal == nil {
+		return nil, errors.New("authentication required")
+	}
+	if !h.session.principal.Expired(time.Now()) {
+		return h.session.principal, nil
+	}
+
+	verifier, _ := h.server.verifier(h.session.scheme)
+	principal, err := verifier.Verify(ctx, h.session.credentials)
+	if err != nil || principal.Expired(time.Now()) {
+		h.session.principal = nil
+		return nil, errors.New("session expired")
+	}
+	h.session.principal = principal
+	return principal, nil
+}
+
+// StaticTokenVerifier authenticates clients presenting one of a fixed set of
+// tokens in the "token" credential, e.g. for CI systems.
+type StaticTokenVerifier struct {
+	tokens map[string]core.Principal
+	ttl    time.Duration
+}
+
+// NewStaticTokenVerifier creates a verifier mapping each token to its principal.
+// A positive ttl makes sessions expire and the token be checked again after ttl,
+// so revoking a token takes effect on open connections.
+func NewStaticTokenVerifier(tokens map[string]core.Principal, ttl time.Duration) *StaticTokenVerifier {
+	return &StaticTokenVerifier{tokens: tokens, ttl: ttl}
+}
+
+// Verify implements AuthVerifier.
+func (v *StaticTokenVerifier) Verify(_ context.Context, credentials map[string]string) (*core.Principal, error) {
+	presented := []byte(credentials[core.CredentialToken])
+
+	var match *core.Principal
+	for token, principal := range v.tokens {
+		if subtle.ConstantTimeCompare(presented, []byte(token)) == 1 {
+			principal := principal
+			match = &principal
+		}
+	}
+	if match == nil {
+		return nil, errors.New("invalid token")
+	}
+
+	if v.ttl > 0 {
+		match.ExpiresAt = time.Now().Add(v.ttl)
+	}
+	return match, nil
+}
+
+// HMACKey is a shared secret for the HMAC auth scheme and the principal it identifies.
+type HMACKey struct {
+	Secret    []byte
+	Principal core.Principal
+}
+
+// HMACVerifier authenticates services signing their key ID and the current
+// time with a shared secret, as produced by core.SignHMAC. Signatures older
+// or newer than the allowed clock skew are rejected, so captured credentials
+// cannot be replayed later.
+type HMACVerifier struct {
+	keys    map[string]HMACKey
+	maxSkew time.Duration
+	ttl     time.Duration
+}
+
+// NewHMACVerifier creates a verifier for the given keys, indexed by key ID.
+// Signatures must be within maxSkew of the server clock. Sessions expire after
+// ttl, after which the client must sign fresh credentials; zero disables expiry.
+func NewHMACVerifier(keys map[string]HMACKey, maxSkew, ttl time.Duration) *HMACVerifier {
+	return &HMACVerifier{keys: keys, maxSkew: maxSkew, ttl: ttl}
+}
+
+// Verify implements AuthVerifier.
+func (v *HMACVerifier) Verify(_ context.Context, credentials map[string]string) (*core.Principal, error) {
+	keyID := credentials[core.CredentialKeyID]
+	key, ok := v.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+
+	timestamp := credentials[core.CredentialTimestamp]
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	skew := time.Since(time.UnixMilli(millis))
+	if skew < -v.maxSkew || skew > v.maxSkew {
+		return nil, errors.New("signature outside the allowed clock skew")
+	}
+
+	signature, err := hex.DecodeString(credentials[core.CredentialSignature])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !hmac.Equal(signature, core.HMACSignature(key.Secret, keyID, timestamp)) {
+		return nil, errors.New("signature mismatch")
+	}
+
+	principal := key.Principal
+	if v.ttl > 0 {
+		principal.ExpiresAt = time.Now().Add(v.ttl)
+	}
+	return &principal, nil
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHMACSecret = []byte("service-secret")
+
+// WhoAmIHandler reports the principal the request was authenticated as
+type WhoAmIHandler struct{}
+
+func (h *WhoAmIHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *WhoAmIHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	if principal := core.PrincipalFromContext(ctx); principal != nil {
+		resp.Results["principal"] = principal.ID
+	}
+	return resp, nil
+}
+
+// startAuthServer starts a server with static token and HMAC schemes and returns its transport
+func startAuthServer(t *testing.T, tokenTTL, hmacSkew, hmacTTL time.Duration) core.Transport {
+	transport := core.NewInProcessTransport()
+
+	srv := New(WithTransport(transport))
+	require.NoError(t, srv.RegisterHandler(&WhoAmIHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.RegisterAuthScheme("token", NewStaticTokenVerifier(map[string]core.Principal{
+		"ci-token": {ID: "ci", Roles: []string{"builder"}},
+	}, tokenTTL)), "Token scheme registration should succeed")
+	require.NoError(t, srv.RegisterAuthScheme("hmac", NewHMACVerifier(map[string]HMACKey{
+		"svc": {Secret: testHMACSecret, Principal: core.Principal{ID: "billing"}},
+	}, hmacSkew, hmacTTL)), "HMAC scheme registration should succeed")
+
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+	return transport
+}
+
+// whoAmI returns the principal ID the server saw for a request
+func whoAmI(t *testing.T, c *client.Client) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed once authenticated")
+	return fmt.Sprint(resp.Results["principal"])
+}
+
+func TestAuthMultipleSchemes(t *testing.T) {
+	transport := startAuthServer(t, 0, time.Minute, 0)
+
+	ci := client.New(client.WithTransport(transport), client.WithAuth("token", map[string]string{
+		core.CredentialToken: "ci-token",
+	}))
+	require.NoError(t, ci.Start(), "Token client should authenticate")
+	defer ci.Stop()
+
+	svc := client.New(client.WithTransport(transport), client.WithAuthProvider("hmac", func(context.Context) (map[string]string, error) {
+		return core.SignHMAC("svc", testHMACSecret, time.Now()), nil
+	}))
+	require.NoError(t, svc.Start(), "HMAC client should authenticate")
+	defer svc.Stop()
+
+	assert.Equal(t, "ci", whoAmI(t, ci), "Handler should see the token principal")
+	assert.Equal(t, []string{"builder"}, ci.Principal().Roles, "Client should learn its roles")
+	assert.Equal(t, "billing", whoAmI(t, svc), "Handler should see the HMAC principal")
+}
+
+func TestAuthRejections(t *testing.T) {
+	transport := startAuthServer(t, 0, time.Minute, 0)
+
+	cases := []struct {
+		name    string
+		options []client.Option
+	}{
+		{"UnknownScheme", []client.Option{client.WithAuth("kerberos", map[string]string{"ticket": "x"})}},
+		{"BadToken", []client.Option{client.WithAuth("token", map[string]string{core.CredentialToken: "wrong"})}},
+		{"BadSignature", []client.Option{client.WithAuth("hmac", core.SignHMAC("svc", []byte("guess"), time.Now()))}},
+		{"StaleSignature", []client.Option{client.WithAuth("hmac", core.SignHMAC("svc", testHMACSecret, time.Now().Add(-time.Hour)))}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := client.New(append([]client.Option{client.WithTransport(transport), client.WithAutoReconnect(false)}, tc.options...)...)
+			err := c.Start()
+			require.Error(t, err, "Client should fail to authenticate")
+			assert.Contains(t, err.Error(), "authentication failed", "Error should report the failed authentication")
+		})
+	}
+
+	// Skipping authentication leaves every method but ping locked
+	c := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Unauthenticated client should still connect")
+	defer c.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.Error(t, err, "Unauthenticated request should be rejected")
+	assert.Contains(t, err.Error(), "authentication required", "Error should ask for authentication")
+}
+
+func TestAuthReverifiesAfterExpiry(t *testing.T) {
+	transport := startAuthServer(t, 50*time.Millisecond, 100*time.Millisecond, 50*time.Millisecond)
+
+	// A static token is verified again by the server without involving the client
+	var tokenCalls int32
+	ci := client.New(client.WithTransport(transport), client.WithAuthProvider("token", func(context.Context) (map[string]string, error) {
+		atomic.AddInt32(&tokenCalls, 1)
+		return map[string]string{core.CredentialToken: "ci-token"}, nil
+	}))
+	require.NoError(t, ci.Start(), "Token client should authenticate")
+	defer ci.Stop()
+
+	// HMAC credentials go stale, so the client must sign fresh ones
+	var hmacCalls int32
+	svc := client.New(client.WithTransport(transport), client.WithAuthProvider("hmac", func(context.Context) (map[string]string, error) {
+		atomic.AddInt32(&hmacCalls, 1)
+		return core.SignHMAC("svc", testHMACSecret, time.Now()), nil
+	}))
+	require.NoError(t, svc.Start(), "HMAC client should authenticate")
+	defer svc.Stop()
+
+	first := ci.Principal().ExpiresAt
+	time.Sleep(200 * time.Millisecond)
+
+	assert.Equal(t, "ci", whoAmI(t, ci), "Token session should survive expiry")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokenCalls), "Server should re-verify the cached token itself")
+
+	assert.Equal(t, "billing", whoAmI(t, svc), "HMAC session should recover after expiry")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hmacCalls), "Client should re-authenticate with fresh credentials")
+	assert.True(t, svc.Principal().ExpiresAt.After(first), "Client should learn the renewed expiry")
+}
+
+func TestStaticTokenVerifier(t *testing.T) {
+	verifier := NewStaticTokenVerifier(map[string]core.Principal{"secret": {ID: "ci"}}, time.Minute)
+
+	principal, err := verifier.Verify(context.Background(), map[string]string{core.CredentialToken: "secret"})
+	require.NoError(t, err, "Known token should verify")
+	assert.Equal(t, "ci", principal.ID, "Principal should match the token")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), principal.ExpiresAt, time.Second, "Principal should expire after the TTL")
+
+	_, err = verifier.Verify(context.Background(), map[string]string{core.CredentialToken: "secre"})
+	assert.Error(t, err, "Unknown token should be rejected")
+	_, err = verifier.Verify(context.Background(), nil)
+	assert.Error(t, err, "Missing token should be rejected")
+}
+
+func TestHMACVerifier(t *testing.T) {
+	verifier := NewHMACVerifier(map[string]HMACKey{"svc": {Secret: testHMACSecret, Principal: core.Principal{ID: "billing"}}}, time.Minute, 0)
+
+	principal, err := verifier.Verify(context.Background(), core.SignHMAC("svc", testHMACSecret, time.Now()))
+	require.NoError(t, err, "Valid signature should verify")
+	assert.Equal(t, "billing", principal.ID, "Principal should match the key")
+	assert.True(t, principal.ExpiresAt.IsZero(), "Principal should not expire without a TTL")
+
+	_, err = verifier.Verify(context.Background(), core.SignHMAC("other", testHMACSecret, time.Now()))
+	assert.Error(t, err, "Unknown key should be rejected")
+
+	tampered := core.SignHMAC("svc", testHMACSecret, time.Now())
+	tampered[core.CredentialTimestamp] = fmt.Sprint(time.Now().Add(time.Second).UnixMilli())
+	_, err = verifier.Verify(context.Background(), tampered)
+	assert.Error(t, err, "Signature over a different timestamp should be rejected")
+
+	_, err = verifier.Verify(context.Background(), core.SignHMAC("svc", testHMACSecret, time.Now().Add(2*time.Minute)))
+	assert.Error(t, err, "Signature from the future should be rejected")
+}
+
+// countingAuthenticator accepts the token "secret" as principal "alice" and
+// counts the authentications it performs
+func countingAuthenticator(calls *int32) Authenticator {
+	return func(ctx context.Context, credentials core.Credentials) (core.Principal, error) {
+		atomic.AddInt32(calls, 1)
+		if credentials.Scheme != core.AuthSchemeToken || credentials.Token() != "secret" {
+			return core.Principal{}, fmt.Errorf("bad token")
+		}
+		return core.Principal{ID: "alice"}, nil
+	}
+}
+
+// startAuthenticatorServer starts a server using WithAuthenticator on transport
+func startAuthenticatorServer(t *testing.T, transport core.Transport, calls *int32) *Server {
+	srv := New(WithTransport(transport), WithAuthenticator(countingAuthenticator(calls)))
+	require.NoError(t, srv.RegisterHandler(&WhoAmIHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	return srv
+}
+
+func TestAuthenticatorToken(t *testing.T) {
+	var calls int32
+	transport := core.NewInProcessTransport()
+	srv := startAuthenticatorServer(t, transport, &calls)
+	defer srv.Stop()
+
+	// The token is presented on connect and the principal reaches the handler
+	c := client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithAuthToken("secret"))
+	require.NoError(t, c.Start(), "Client with a valid token should connect")
+	defer c.Stop()
+	assert.Equal(t, "alice", whoAmI(t, c), "Handler should see the authenticated principal")
+	assert.Equal(t, "alice", c.Principal().ID, "Client should learn its principal")
+
+	// A bad token is rejected during Start
+	bad := client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithAuthToken("guess"))
+	err := bad.Start()
+	require.Error(t, err, "Client with a bad token should fail to start")
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Rejection should be a JSON-RPC error")
+	assert.Equal(t, core.CodeUnauthenticated, rpcErr.Code, "Rejection should use the unauthenticated code")
+
+	// Requests sent before authenticating are denied
+	anon := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, anon.Start(), "Client without a token should still connect")
+	defer anon.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = anon.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.ErrorAs(t, err, &rpcErr, "Unauthenticated request should be a JSON-RPC error")
+	assert.Equal(t, core.CodeUnauthenticated, rpcErr.Code, "Unauthenticated request should use the unauthenticated code")
+}
+
+func TestAuthenticatorReconnect(t *testing.T) {
+	var calls int32
+	transport := core.NewInProcessTransport()
+	srv := startAuthenticatorServer(t, transport, &calls)
+
+	c := client.New(client.WithTransport(transport), client.WithAuthToken("secret"),
+		client.WithReconnectDelay(20*time.Millisecond), client.WithMaxReconnectAttempts(50))
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	assert.Equal(t, "alice", whoAmI(t, c), "Handler should see the authenticated principal")
+
+	// Replace the server; the client reconnects and authenticates again
+	require.NoError(t, srv.Stop(), "Server should stop")
+	srv = startAuthenticatorServer(t, transport, &calls)
+	defer srv.Stop()
+
+	assert.Eventually(t, func() bool {
+		return c.Stats().Connections == 2 && c.IsConnected()
+	}, 2*time.Second, 10*time.Millisecond, "Client should reconnect")
+	assert.Equal(t, "alice", whoAmI(t, c), "Reconnected client should be authenticated")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "Client should authenticate once per connection")
+}
+
+func TestRegisterAuthSchemeDuplicate(t *testing.T) {
+	srv := New()
+	verifier := NewStaticTokenVerifier(nil, 0)
+	require.NoError(t, srv.RegisterAuthScheme("token", verifier), "First registration should succeed")
+	assert.Error(t, srv.RegisterAuthScheme("token", verifier), "Duplicate registration should fail")
+}
+
+func TestAuthRejectionReason(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport))
+	require.NoError(t, srv.RegisterHandler(&WhoAmIHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.RegisterAuthScheme("jwt", AuthVerifierFunc(func(context.Context, map[string]string) (*core.Principal, error) {
+		return nil, &core.AuthError{Reason: core.AuthReasonExpired, Err: fmt.Errorf("token for alice expired")}
+	})), "Scheme registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	defer srv.Stop()
+
+	c := client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithAuth("jwt", map[string]string{
+		core.CredentialToken: "expired-token",
+	}))
+	err := c.Start()
+	require.Error(t, err, "Client should fail to authenticate")
+
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Rejection should be a JSON-RPC error")
+	assert.Equal(t, core.CodeUnauthenticated, rpcErr.Code, "Rejection should use the unauthenticated code")
+	assert.Equal(t, "authentication failed: expired", rpcErr.Message, "Message should name the reason category")
+	assert.NotContains(t, rpcErr.Message, "alice", "Message should not describe the credentials")
+
+	var data core.AuthErrorData
+	require.NotNil(t, rpcErr.Data, "Rejection should carry error data")
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &data), "Error data should decode")
+	assert.Equal(t, core.AuthReasonExpired, data.Reason, "Error data should carry the reason")
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Authorizer decides whether principal may call method. A non-nil error
+// denies the call with core.CodeUnauthorized and the error's message.
+// Connections that have not authenticated present the zero Principal.
+type Authorizer func(ctx context.Context, principal core.Principal, method string) error
+
+// NewRoleAuthorizer returns an Authorizer allowing a method when one of the
+// principal's roles has a pattern matching it. Patterns are glob-style, as in
+// path.Match: "mcp.*" allows every protocol method and "*" allows anything.
+// Malformed patterns match nothing.
+func NewRoleAuthorizer(roles map[string][]string) Authorizer {
+	return func(_ context.Context, principal core.Principal, method string) error {
+		for _, role := range principal.Roles {
+			for _, pattern := range roles[role] {
+				if ok, _ := path.Match(pattern, method); ok {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%s is not allowed to call %s", describePrincipal(principal), method)
+	}
+}
+
+// describePrincipal names principal in authorization errors.
+func describePrincipal(principal core.Principal) string {
+	if principal.ID == "" {
+		return "anonymous caller"
+	}
+	return "principal " + principal.ID
+}
+
+// permit checks the configured Authorizer, if any, for a call to method.
+func (s *Server) permit(ctx context.Context, method string) error {
+	if s.options.Authorizer == nil {
+		return nil
+	}
+	var principal core.Principal
+	if p := core.PrincipalFromContext(ctx); p != nil {
+		principal = *p
+	}
+	return s.options.Authorizer(ctx, principal, method)
+}
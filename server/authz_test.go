@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleAuthorizer(t *testing.T) {
+	authorize := NewRoleAuthorizer(map[string][]string{
+		"builder": {core.MethodProcessModel, core.MethodProcessModelBatch},
+		"admin":   {"mcp.admin.*"},
+		"broken":  {"["},
+	})
+	ctx := context.Background()
+	builder := core.Principal{ID: "ci", Roles: []string{"builder"}}
+	admin := core.Principal{ID: "ops", Roles: []string{"builder", "admin"}}
+
+	// Listed methods are allowed
+	assert.NoError(t, authorize(ctx, builder, core.MethodProcessModel), "Builder should process models")
+	assert.NoError(t, authorize(ctx, admin, core.MethodProcessModelBatch), "Any matching role should allow the call")
+
+	// Everything else is denied
+	err := authorize(ctx, builder, "mcp.admin.reset")
+	require.Error(t, err, "Builder should not call admin methods")
+	assert.Equal(t, "principal ci is not allowed to call mcp.admin.reset", err.Error(), "Denial should name the principal and method")
+	assert.Error(t, authorize(ctx, core.Principal{}, core.MethodProcessModel), "Principal without roles should be denied")
+	assert.Error(t, authorize(ctx, core.Principal{Roles: []string{"broken"}}, "["), "Malformed pattern should match nothing")
+
+	// Patterns match by glob
+	assert.NoError(t, authorize(ctx, admin, "mcp.admin.reset"), "Admin pattern should match admin methods")
+	assert.Error(t, authorize(ctx, admin, "mcp.administer"), "Pattern should not match beyond its literal prefix")
+}
+
+func TestAuthorizerDeniesMethods(t *testing.T) {
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithAuthToken("secret"))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport),
+				WithAuthenticator(func(ctx context.Context, credentials core.Credentials) (core.Principal, error) {
+					return core.Principal{ID: "viewer", Roles: []string{"viewer"}}, nil
+				}),
+				WithAuthorizer(NewRoleAuthorizer(map[string][]string{"viewer": {"mcp.list*"}})))
+			require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+			return srv
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Authentication is never subject to authorization, and matching methods are allowed
+	_, err := c.FetchMethodSchemas(ctx)
+	assert.NoError(t, err, "Viewer should list methods")
+
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Denial should be a JSON-RPC error")
+	assert.Equal(t, core.CodeUnauthorized, rpcErr.Code, "Denial should use the unauthorized code")
+	assert.Contains(t, rpcErr.Message, "not allowed to call mcp.processModel", "Denial should name the method")
+}
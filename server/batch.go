@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleProcessModelBatch processes each request of a batch with the handler
+// registered for core.MethodProcessModel. Items start in order, up to the
+// configured batch parallelism at a time, each with a slice of the batch
+// deadline chosen by the deadline strategy; an item that fails or overruns its
+// slice gets an error response while the rest continue. Responses keep the
+// order of the requests.
+func (h *rpcHandler) handleProcessModelBatch(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, registered interface{}) {
+	handler, ok := registered.(ModelHandler)
+	if !ok {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+		return
+	}
+
+	var batch core.BatchRequest
+	if req.Params == nil {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing batch")
+		return
+	}
+	if err := json.Unmarshal(*req.Params, &batch); err != nil {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+		return
+	}
+
+	strategy := h.server.options.BatchDeadlineStrategy
+	if batch.DeadlineStrategy != "" {
+		strategy = batch.DeadlineStrategy
+	}
+
+	batchCtx := ctx
+	if batch.Timeout > 0 {
+		var cancel context.CancelFunc
+		batchCtx, cancel = context.WithTimeoutCause(ctx, batch.Timeout, core.CauseDeadline)
+		defer cancel()
+	}
+
+	resp := &core.BatchResponse{
+		Responses: make([]*core.ModelResponse, len(batch.Requests)),
+		Timings:   make([]core.BatchItemTiming, len(batch.Requests)),
+	}
+	parallelism := max(h.server.options.BatchParallelism, 1)
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, item := range batch.Requests {
+		i, item := i, item
+		slots <- struct{}{}
+		budget := itemBudget(batchCtx, strategy, lane(batch.Requests[i:], parallelism))
+
+		wg.Add(1)
+		h.server.tasks.Go(core.TaskJobs, func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			itemCtx := batchCtx
+			if item != nil {
+				itemCtx = core.ContextWithMetadata(batchCtx, item.Metadata)
+			}
+			resp.Responses[i], resp.Timings[i] = h.processBatchItem(itemCtx, req.Method, handler, item, budget)
+			h.server.echoMetadata(itemCtx, resp.Responses[i])
+		})
+	}
+	wg.Wait()
+
+	h.reply(ctx, conn, req, resp)
+}
+
+// processBatchItem runs one batch item of method within its budget, through
+// the middleware and processModel as a single request is. The handler runs on
+// its own goroutine so an item that ignores its context still cannot hold up
+// the rest of the batch.
+func (h *rpcHandler) processBatchItem(ctx context.Context, method string, handler ModelHandler, req *core.ModelRequest, budget time.Duration) (*core.ModelResponse, core.BatchItemTiming) {
+	timing := core.BatchItemTiming{Budget: budget}
+	if req == nil {
+		req = core.NewModelRequest()
+	}
+
+	itemCtx := ctx
+	if budget > 0 {
+		var cancel context.CancelFunc
+		itemCtx, cancel = context.WithTimeoutCause(ctx, budget, core.CauseDeadline)
+		defer cancel()
+	}
+	itemCtx, cancel := withRequestCancel(itemCtx)
+	defer cancel(nil)
+
+	type result struct {
+		resp *core.ModelResponse
+		err  error
+	}
+	done := make(chan result, 1)
+
+	// The handler gets its own copy, which it may go on changing after the
+	// item's budget runs out and the batch has moved on
+	start := time.Now()
+	handlerReq := req.Clone()
+	h.server.tasks.Go(core.TaskJobs, func() {
+		process := h.server.withMiddleware(core.MethodProcessModel, func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			return h.server.processModel(ctx, method, handler, req, handler.ProcessModel)
+		})
+		process = h.server.recoverPanics(core.MethodProcessModel, process, core.LogFieldRemoteAddr, h.remoteAddr)
+		resp, err := withHandlerTimeout(h.server, itemCtx, core.MethodProcessModel, handlerReq.ID, func(ctx context.Context) (*core.ModelResponse, error) {
+			return process(ctx, handlerReq)
+		})
+		done <- result{resp, err}
+	})
+
+	var res result
+	select {
+	case res = <-done:
+	case <-itemCtx.Done():
+		res = result{err: itemCtx.Err()}
+	}
+	timing.Elapsed = time.Since(start)
+
+	if itemCtx.Err() == context.DeadlineExceeded {
+		timing.DeadlineExceeded = true
+		return core.ErrorResponse(req, fmt.Errorf("batch item deadline exceeded after %s", timing.Elapsed.Round(time.Millisecond))), timing
+	}
+	var processErr *processingError
+	if errors.As(res.err, &processErr) {
+		res.err = processErr.err
+	}
+	if rpcErr, ok := res.err.(*jsonrpc2.Error); ok {
+		return core.ErrorResponse(req, errors.New(rpcErr.Message)), timing
+	}
+	if res.err != nil {
+		return core.ErrorResponse(req, res.err), timing
+	}
+	if res.resp == nil {
+		return core.ErrorResponse(req, fmt.Errorf("handler returned no response")), timing
+	}
+	return res.resp, timing
+}
+
+// itemBudget returns the time allotted to the first of the remaining items
+// under the given strategy, or zero if the batch has no deadline.
+func itemBudget(ctx context.Context, strategy core.DeadlineStrategy, remaining []*core.ModelRequest) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		return time.Nanosecond
+	}
+
+	switch strategy {
+	case core.DeadlineEqual:
+		return left / time.Duration(len(remaining))
+	case core.DeadlineWeighted:
+		total := 0.0
+		for _, item := range remaining {
+			total += deadlineWeight(item)
+		}
+		return time.Duration(float64(left) * deadlineWeight(remaining[0]) / total)
+	default:
+		return left
+	}
+}
+
+// lane returns the remaining items that will run one after another with the
+// first when parallelism items run at a time: every parallelism-th item. The
+// deadline strategies divide the batch deadline among these.
+func lane(remaining []*core.ModelRequest, parallelism int) []*core.ModelRequest {
+	if parallelism <= 1 {
+		return remaining
+	}
+	items := make([]*core.ModelRequest, 0, (len(remaining)+parallelism-1)/parallelism)
+	for i := 0; i < len(remaining); i += parallelism {
+		items = append(items, remaining[i])
+	}
+	return items
+}
+
+// deadlineWeight returns the item's weight for core.DeadlineWeighted.
+func deadlineWeight(req *core.ModelRequest) float64 {
+	if req == nil {
+		return 1
+	}
+	weight, err := strconv.ParseFloat(req.Metadata[core.MetadataDeadlineWeight], 64)
+	if err != nil || weight <= 0 {
+		return 1
+	}
+	return weight
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SleepyModelHandler sleeps for the duration given in the "sleep" model data,
+// returning early if its context is done, and fails with the "fail" model data
+type SleepyModelHandler struct{}
+
+func (h *SleepyModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *SleepyModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if msg, ok := req.ModelData["fail"].(string); ok {
+		return nil, errors.New(msg)
+	}
+	if sleep, ok := req.ModelData["sleep"].(string); ok {
+		d, err := time.ParseDuration(sleep)
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return core.NewModelResponse(req), nil
+}
+
+// newBatch builds a batch whose first item sleeps for slow and the rest return immediately
+func newBatch(n int, slow time.Duration) *core.BatchRequest {
+	batch := &core.BatchRequest{}
+	for i := 0; i < n; i++ {
+		req := core.NewModelRequest()
+		if i == 0 {
+			req.ModelData["sleep"] = slow.String()
+		}
+		batch.Requests = append(batch.Requests, req)
+	}
+	return batch
+}
+
+func TestBatchFirstComeAllStarvesLaterItems(t *testing.T) {
+	_, c := startServerWithHandler(t, &SleepyModelHandler{})
+
+	batch := newBatch(4, time.Second)
+	batch.Timeout = 300 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := c.ProcessBatch(ctx, batch)
+	require.NoError(t, err, "ProcessBatch should succeed")
+	require.Len(t, resp.Responses, 4, "Every item should get a response")
+
+	for i, r := range resp.Responses {
+		assert.False(t, r.Success, "Item %d should fail once the slow item consumed the budget", i)
+		assert.True(t, resp.Timings[i].DeadlineExceeded, "Item %d should report an exceeded deadline", i)
+	}
+}
+
+func TestBatchEqualDeadlineIsolatesSlowItem(t *testing.T) {
+	_, c := startServerWithHandler(t, &SleepyModelHandler{}, WithBatchDeadlineStrategy(core.DeadlineEqual))
+
+	batch := newBatch(4, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp, err := c.ProcessBatch(ctx, batch)
+	require.NoError(t, err, "ProcessBatch should finish within the caller's deadline")
+	assert.Less(t, time.Since(start), 400*time.Millisecond, "Batch should respect the overall deadline")
+	require.Len(t, resp.Responses, 4, "Every item should get a response")
+
+	assert.False(t, resp.Responses[0].Success, "Slow item should fail")
+	assert.True(t, resp.Timings[0].DeadlineExceeded, "Slow item should report an exceeded deadline")
+	assert.Contains(t, resp.Responses[0].ErrorMessage, "deadline exceeded", "Slow item should carry a deadline error")
+	assert.LessOrEqual(t, resp.Timings[0].Budget, 100*time.Millisecond, "Slow item should only get its equal share")
+
+	for i := 1; i < 4; i++ {
+		assert.True(t, resp.Responses[i].Success, "Item %d should succeed despite the slow item", i)
+		assert.Equal(t, batch.Requests[i].ID, resp.Responses[i].ID, "Responses should keep request order")
+	}
+}
+
+func TestBatchClientStrategyOverride(t *testing.T) {
+	_, c := startServerWithHandler(t, &SleepyModelHandler{})
+
+	batch := newBatch(3, time.Second)
+	batch.Timeout = 300 * time.Millisecond
+	batch.DeadlineStrategy = core.DeadlineEqual
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := c.ProcessBatch(ctx, batch)
+	require.NoError(t, err, "ProcessBatch should succeed")
+
+	assert.False(t, resp.Responses[0].Success, "Slow item should fail")
+	assert.True(t, resp.Responses[1].Success, "Fast items should succeed under the client's strategy")
+	assert.True(t, resp.Responses[2].Success, "Fast items should succeed under the client's strategy")
+}
+
+func TestProcessModelBatchItemError(t *testing.T) {
+	_, c := startServerWithHandler(t, &SleepyModelHandler{})
+
+	reqs := newBatch(5, 0).Requests
+	reqs[2].ModelData["fail"] = "item rejected"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resps, err := c.ProcessModelBatch(ctx, reqs)
+	require.NoError(t, err, "A failing item should not fail the batch")
+	require.Len(t, resps, 5, "Every item should get a response")
+
+	for i, r := range resps {
+		assert.Equal(t, reqs[i].ID, r.ID, "Responses should keep request order")
+		if i == 2 {
+			assert.False(t, r.Success, "Failing item should get an error response")
+			assert.Equal(t, "item rejected", r.ErrorMessage, "Error response should carry the handler error")
+			continue
+		}
+		assert.True(t, r.Success, "Item %d should succeed despite the failing item", i)
+	}
+}
+
+func TestBatchItemsProcessedAsRequests(t *testing.T) {
+	recorder := testutil.NewExchangeRecorder()
+	_, c := startServerWithHandler(t, &SleepyModelHandler{}, WithRecorder(recorder))
+
+	// One item gives up early, one refers to a blob the server does not
+	// have, and one succeeds
+	reqs := newBatch(3, time.Second).Requests
+	reqs[0].Metadata = map[string]string{core.MetadataTimeout: core.FormatTimeout(50 * time.Millisecond)}
+	reqs[1].BlobRefs = []core.BlobID{"missing"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	resps, err := c.ProcessModelBatch(ctx, reqs)
+	require.NoError(t, err, "Failing items should not fail the batch")
+	require.Len(t, resps, 3, "Every item should get a response")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "The item's own timeout should stop its handler")
+	assert.False(t, resps[0].Success, "Item past its timeout should fail")
+	assert.False(t, resps[1].Success, "Item with a missing blob should fail")
+	assert.Contains(t, resps[1].ErrorMessage, "blob not found", "Item should say which blob is missing")
+	assert.True(t, resps[2].Success, "Plain item should succeed")
+
+	exchanges := recorder.Exchanges()
+	require.Len(t, exchanges, 1, "The successful item should be recorded")
+	assert.Equal(t, reqs[2].ID, exchanges[0].Request.ID, "The recorded exchange should be the successful item")
+}
+
+func TestBatchParallelism(t *testing.T) {
+	_, c := startServerWithHandler(t, &SleepyModelHandler{}, WithBatchParallelism(4))
+
+	reqs := make([]*core.ModelRequest, 8)
+	for i := range reqs {
+		reqs[i] = core.NewModelRequest()
+		reqs[i].ID = fmt.Sprintf("item-%d", i)
+		reqs[i].ModelData["sleep"] = (time.Duration(8-i) * 20 * time.Millisecond).String()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	resps, err := c.ProcessModelBatch(ctx, reqs)
+	require.NoError(t, err, "ProcessModelBatch should succeed")
+	assert.Less(t, time.Since(start), 600*time.Millisecond, "Items should run four at a time")
+
+	require.Len(t, resps, len(reqs), "Every item should get a response")
+	for i, r := range resps {
+		assert.True(t, r.Success, "Item %d should succeed", i)
+		assert.Equal(t, reqs[i].ID, r.ID, "Responses should keep request order even when later items finish first")
+	}
+}
+
+func TestLane(t *testing.T) {
+	items := newBatch(5, 0).Requests
+
+	assert.Equal(t, items, lane(items, 1), "Sequential batches should share one lane")
+	assert.Equal(t, []*core.ModelRequest{items[0], items[2], items[4]}, lane(items, 2), "Every second item should share the first lane")
+	assert.Equal(t, items[:1], lane(items, 8), "Items beyond the parallelism should each get a lane")
+}
+
+func TestItemBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	heavy := core.NewModelRequest()
+	heavy.Metadata = map[string]string{core.MetadataDeadlineWeight: "3"}
+	items := []*core.ModelRequest{heavy, core.NewModelRequest()}
+
+	within := func(got, want time.Duration) bool {
+		return got <= want && got > want-50*time.Millisecond
+	}
+
+	assert.True(t, within(itemBudget(ctx, core.DeadlineFirstComeAll, items), time.Second), "FirstComeAll should get the whole deadline")
+	assert.True(t, within(itemBudget(ctx, core.DeadlineEqual, items), 500*time.Millisecond), "Equal should split the deadline evenly")
+	assert.True(t, within(itemBudget(ctx, core.DeadlineWeighted, items), 750*time.Millisecond), "Weighted should split by weight")
+	assert.Zero(t, itemBudget(context.Background(), core.DeadlineEqual, items), "No deadline should mean no budget")
+}
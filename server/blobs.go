@@ -0,0 +1,352 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// maxBlobChunk bounds the chunk an mcp.blob.get call returns.
+const maxBlobChunk = 16 << 20
+
+// BlobStore keeps the blobs clients upload with mcp.blob.put and download
+// with mcp.blob.get. Methods return an error wrapping core.ErrBlobNotFound
+// for a blob the store does not hold. FileBlobStore keeps blobs on disk.
+type BlobStore interface {
+	// Create starts storing the blob id, which Open and Stat do not find
+	// until the writer is committed.
+	Create(ctx context.Context, id core.BlobID, meta core.BlobMeta) (BlobWriter, error)
+	// Open returns the content of the blob id, which the caller closes, and
+	// its metadata.
+	Open(ctx context.Context, id core.BlobID) (io.ReadSeekCloser, core.BlobMeta, error)
+	// Stat returns the metadata of the blob id.
+	Stat(ctx context.Context, id core.BlobID) (core.BlobMeta, error)
+	// Delete removes the blob id.
+	Delete(ctx context.Context, id core.BlobID) error
+}
+
+// BlobWriter receives the content of a blob being uploaded. Exactly one of
+// Commit and Abort is called once the upload ends.
+type BlobWriter interface {
+	io.Writer
+	// Commit stores the blob with meta, whose Size and SHA256 describe what
+	// was written.
+	Commit(meta core.BlobMeta) error
+	// Abort discards what was written.
+	Abort() error
+}
+
+type blobStoreKey struct{}
+
+// BlobsFromContext returns the BlobStore of the server handling the request
+// ctx belongs to, which handlers open the blobs of core.ModelRequest.BlobRefs
+// from, or nil if the server stores no blobs.
+func BlobsFromContext(ctx context.Context) BlobStore {
+	store, _ := ctx.Value(blobStoreKey{}).(BlobStore)
+	return store
+}
+
+// withBlobs returns a copy of ctx carrying the server's blob store, failing
+// with core.CodeBlobNotFound if any of refs is not stored.
+func (s *Server) withBlobs(ctx context.Context, refs []core.BlobID) (context.Context, error) {
+	store := s.options.BlobStore
+	for _, id := range refs {
+		if store == nil {
+			return nil, blobNotFound(id)
+		}
+		if _, err := store.Stat(ctx, id); errors.Is(err, core.ErrBlobNotFound) {
+			return nil, blobNotFound(id)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if store == nil {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, blobStoreKey{}, store), nil
+}
+
+// blobNotFound returns the error a request for the missing blob id fails with.
+func blobNotFound(id core.BlobID) *jsonrpc2.Error {
+	return &jsonrpc2.Error{Code: core.CodeBlobNotFound, Message: fmt.Sprintf("blob not found: %s", id)}
+}
+
+// blobInvalid returns the error a blob request with invalid params fails with.
+func blobInvalid(format string, args ...interface{}) *jsonrpc2.Error {
+	return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "invalid params: " + fmt.Sprintf(format, args...)}
+}
+
+// handleBlobRequest answers mcp.blob.put and mcp.blob.get, taking a handler
+// slot like any model request. Without a blob store the methods are not
+// found.
+func (h *rpcHandler) handleBlobRequest(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	store := h.server.options.BlobStore
+	if store == nil {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+		return
+	}
+	if req.Params == nil {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing blob request")
+		return
+	}
+
+	if !h.server.pool.acquire(ctx) {
+		h.replyError(ctx, conn, req, core.CodeServerBusy, "server busy, too many requests in flight")
+		return
+	}
+	defer h.server.pool.release()
+
+	var result interface{}
+	var err error
+	if req.Method == core.MethodBlobPut {
+		var put core.BlobPutRequest
+		if err := json.Unmarshal(*req.Params, &put); err != nil {
+			h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+			return
+		}
+		result, err = h.server.uploads.put(ctx, store, &put, h.server.options.BlobUploadTTL)
+	} else {
+		var get core.BlobGetRequest
+		if err := json.Unmarshal(*req.Params, &get); err != nil {
+			h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+			return
+		}
+		result, err = getBlob(ctx, store, &get)
+	}
+	if err != nil {
+		h.replyProcessError(ctx, conn, req, err)
+		return
+	}
+	h.reply(ctx, conn, req, result)
+}
+
+// getBlob reads the chunk of a stored blob that get asks for.
+func getBlob(ctx context.Context, store BlobStore, get *core.BlobGetRequest) (*core.BlobGetResponse, error) {
+	if !get.ID.Valid() {
+		return nil, blobInvalid("invalid blob ID %q", get.ID)
+	}
+	if get.Offset < 0 || get.Length <= 0 {
+		return nil, blobInvalid("invalid range of %d bytes at %d", get.Length, get.Offset)
+	}
+	content, meta, err := store.Open(ctx, get.ID)
+	if errors.Is(err, core.ErrBlobNotFound) {
+		return nil, blobNotFound(get.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	if _, err := content.Seek(get.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, min(get.Length, maxBlobChunk))
+	n, err := io.ReadFull(content, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	data = data[:n]
+	return &core.BlobGetResponse{
+		Data:     data,
+		Checksum: core.BlobChecksum(data),
+		Meta:     meta,
+		EOF:      get.Offset+int64(n) >= meta.Size,
+	}, nil
+}
+
+// blobUpload is a blob being uploaded, waiting for its next chunk.
+type blobUpload struct {
+	mu       sync.Mutex
+	writer   BlobWriter
+	meta     core.BlobMeta
+	digest   hash.Hash
+	received int64
+	closed   bool  // Committed or aborted
+	touched  int64 // Unix nanoseconds of the last chunk; accessed atomically
+}
+
+// abort discards the upload, unless it has ended already.
+func (u *blobUpload) abort() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.closed {
+		u.closed = true
+		u.writer.Abort()
+	}
+}
+
+// blobUploads tracks the blobs being uploaded, by ID. Uploads outlive the
+// connection sending them, so a client that reconnects can carry on.
+type blobUploads struct {
+	mu      sync.Mutex
+	uploads map[core.BlobID]*blobUpload
+}
+
+// start returns the upload of id, creating it in store if put is its first
+// chunk. It returns nil if the upload has ended, or never began.
+func (b *blobUploads) start(ctx context.Context, store BlobStore, put *core.BlobPutRequest, ttl time.Duration) (*blobUpload, error) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweepLocked(now, ttl)
+
+	if upload, ok := b.uploads[put.ID]; ok {
+		atomic.StoreInt64(&upload.touched, now.UnixNano())
+		return upload, nil
+	}
+	if put.Offset != 0 {
+		return nil, nil
+	}
+	if _, err := store.Stat(ctx, put.ID); err == nil {
+		return nil, nil
+	} else if !errors.Is(err, core.ErrBlobNotFound) {
+		return nil, err
+	}
+
+	var meta core.BlobMeta
+	if put.Meta != nil {
+		meta = *put.Meta
+	}
+	writer, err := store.Create(ctx, put.ID, meta)
+	if err != nil {
+		return nil, err
+	}
+	upload := &blobUpload{writer: writer, meta: meta, digest: sha256.New(), touched: now.UnixNano()}
+	if b.uploads == nil {
+		b.uploads = make(map[core.BlobID]*blobUpload)
+	}
+	b.uploads[put.ID] = upload
+	return upload, nil
+}
+
+// put appends the chunk of put to its upload, storing the blob if the chunk
+// is the final one. A chunk the upload already has is acknowledged without
+// being written, as is the final chunk of a blob already stored, so a client
+// may send again a chunk whose reply it lost.
+func (b *blobUploads) put(ctx context.Context, store BlobStore, put *core.BlobPutRequest, ttl time.Duration) (*core.BlobPutResponse, error) {
+	if !put.ID.Valid() {
+		return nil, blobInvalid("invalid blob ID %q", put.ID)
+	}
+	if core.BlobChecksum(put.Data) != put.Checksum {
+		return nil, blobInvalid("chunk at %d of blob %s: %v", put.Offset, put.ID, core.ErrBlobChecksum)
+	}
+
+	upload, err := b.start(ctx, store, put, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if upload == nil {
+		return resentFinal(ctx, store, put)
+	}
+
+	upload.mu.Lock()
+	if upload.closed {
+		upload.mu.Unlock()
+		return resentFinal(ctx, store, put)
+	}
+	resp, done, err := upload.putLocked(put)
+	upload.mu.Unlock()
+	if done {
+		b.remove(put.ID, upload)
+	}
+	return resp, err
+}
+
+// putLocked appends the chunk of put to the upload, reporting whether the
+// upload has ended. The caller holds u.mu.
+func (u *blobUpload) putLocked(put *core.BlobPutRequest) (*core.BlobPutResponse, bool, error) {
+	end := put.Offset + int64(len(put.Data))
+	switch {
+	case put.Offset == u.received:
+		if _, err := u.writer.Write(put.Data); err != nil {
+			u.closed = true
+			u.writer.Abort()
+			return nil, true, fmt.Errorf("failed to write blob %s: %w", put.ID, err)
+		}
+		u.digest.Write(put.Data)
+		u.received = end
+	case end > u.received:
+		return nil, false, blobInvalid("chunk at %d of blob %s does not follow the %d bytes received", put.Offset, put.ID, u.received)
+	}
+
+	resp := &core.BlobPutResponse{ID: put.ID, Received: u.received}
+	if !put.Final || end < u.received {
+		return resp, false, nil
+	}
+
+	u.closed = true
+	sum := hex.EncodeToString(u.digest.Sum(nil))
+	if put.SHA256 != "" && put.SHA256 != sum {
+		u.writer.Abort()
+		return nil, true, blobInvalid("blob %s: %v", put.ID, core.ErrBlobChecksum)
+	}
+	meta := u.meta
+	meta.Size = u.received
+	meta.SHA256 = sum
+	if err := u.writer.Commit(meta); err != nil {
+		return nil, true, fmt.Errorf("failed to store blob %s: %w", put.ID, err)
+	}
+	resp.Meta = &meta
+	return resp, true, nil
+}
+
+// resentFinal acknowledges put if it is the final chunk of a blob already
+// stored, and fails it as belonging to no upload otherwise.
+func resentFinal(ctx context.Context, store BlobStore, put *core.BlobPutRequest) (*core.BlobPutResponse, error) {
+	meta, err := store.Stat(ctx, put.ID)
+	if errors.Is(err, core.ErrBlobNotFound) {
+		return nil, blobInvalid("no upload of blob %s in progress", put.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !put.Final || put.Offset+int64(len(put.Data)) != meta.Size || (put.SHA256 != "" && put.SHA256 != meta.SHA256) {
+		return nil, blobInvalid("blob %s already exists", put.ID)
+	}
+	return &core.BlobPutResponse{ID: put.ID, Received: meta.Size, Meta: &meta}, nil
+}
+
+// remove forgets the upload of id, if it is still upload.
+func (b *blobUploads) remove(id core.BlobID, upload *blobUpload) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.uploads[id] == upload {
+		delete(b.uploads, id)
+	}
+}
+
+// sweepLocked aborts uploads that have waited longer than ttl for their
+// next chunk. The caller holds b.mu.
+func (b *blobUploads) sweepLocked(now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	for id, upload := range b.uploads {
+		if now.Sub(time.Unix(0, atomic.LoadInt64(&upload.touched))) > ttl {
+			delete(b.uploads, id)
+			upload.abort()
+		}
+	}
+}
+
+// abortAll aborts every upload in progress.
+func (b *blobUploads) abortAll() {
+	b.mu.Lock()
+	uploads := b.uploads
+	b.uploads = nil
+	b.mu.Unlock()
+	for _, upload := range uploads {
+		upload.abort()
+	}
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BlobHashingHandler answers requests with the SHA-256 of each blob they
+// refer to, read from the server's blob store
+type BlobHashingHandler struct{}
+
+func (BlobHashingHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (BlobHashingHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	for _, id := range req.BlobRefs {
+		content, _, err := BlobsFromContext(ctx).Open(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.New()
+		_, err = io.Copy(digest, content)
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Results[string(id)] = hex.EncodeToString(digest.Sum(nil))
+	}
+	return resp, nil
+}
+
+// DisconnectingBlobStore disconnects every client while the chunk numbered
+// disconnectAt is being written
+type DisconnectingBlobStore struct {
+	*FileBlobStore
+	server       *Server
+	disconnectAt int32
+	writes       int32
+}
+
+func (s *DisconnectingBlobStore) Create(ctx context.Context, id core.BlobID, meta core.BlobMeta) (BlobWriter, error) {
+	writer, err := s.FileBlobStore.Create(ctx, id, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &disconnectingWriter{BlobWriter: writer, store: s}, nil
+}
+
+type disconnectingWriter struct {
+	BlobWriter
+	store *DisconnectingBlobStore
+}
+
+func (w *disconnectingWriter) Write(p []byte) (int, error) {
+	if atomic.AddInt32(&w.store.writes, 1) == w.store.disconnectAt {
+		for _, info := range w.store.server.Clients() {
+			w.store.server.DisconnectClient(info.ID)
+		}
+	}
+	return w.BlobWriter.Write(p)
+}
+
+// startBlobPair connects a client to a server keeping blobs in store
+func startBlobPair(t *testing.T, store BlobStore, clientOptions []client.Option, options ...Option) (*client.Client, *Server) {
+	return testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(append([]client.Option{client.WithTransport(transport), client.WithLogger(core.NopLogger())}, clientOptions...)...)
+		},
+		func(transport core.Transport) *Server {
+			srv := New(append(options, WithTransport(transport), WithLogger(core.NopLogger()), WithBlobStore(store))...)
+			require.NoError(t, srv.RegisterHandler(BlobHashingHandler{}), "Handler registration should succeed")
+			return srv
+		},
+	)
+}
+
+// randomBlob returns size random bytes and their hex encoded SHA-256
+func randomBlob(t *testing.T, size int) ([]byte, string) {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err, "Generating the blob should succeed")
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:])
+}
+
+func TestUploadBlob(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	c, _ := startBlobPair(t, store, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	data, sum := randomBlob(t, 20<<20)
+	id, err := c.UploadBlob(ctx, bytes.NewReader(data), core.BlobMeta{Name: "weights.bin", MimeType: "application/octet-stream"})
+	require.NoError(t, err, "Upload should succeed")
+
+	meta, err := store.Stat(ctx, id)
+	require.NoError(t, err, "Blob should be stored")
+	assert.Equal(t, sum, meta.SHA256, "Stored hash should match the blob")
+	assert.Equal(t, int64(len(data)), meta.Size, "Stored size should match the blob")
+	assert.Equal(t, "weights.bin", meta.Name, "Uploader's metadata should be kept")
+
+	// Handlers open the blobs requests refer to
+	req := core.NewModelRequest()
+	req.BlobRefs = []core.BlobID{id}
+	resp, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request referring to the blob should succeed")
+	assert.Equal(t, sum, resp.Results[string(id)], "Handler should read the uploaded blob")
+
+	var downloaded bytes.Buffer
+	meta, err = c.DownloadBlob(ctx, id, &downloaded)
+	require.NoError(t, err, "Download should succeed")
+	assert.True(t, bytes.Equal(data, downloaded.Bytes()), "Downloaded blob should match the upload")
+	assert.Equal(t, sum, meta.SHA256, "Downloaded metadata should carry the hash")
+}
+
+func TestUploadEmptyBlob(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	c, _ := startBlobPair(t, store, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, err := c.UploadBlob(ctx, strings.NewReader(""), core.BlobMeta{})
+	require.NoError(t, err, "Upload should succeed")
+	var downloaded bytes.Buffer
+	meta, err := c.DownloadBlob(ctx, id, &downloaded)
+	require.NoError(t, err, "Download should succeed")
+	assert.Zero(t, meta.Size, "Blob should be empty")
+	assert.Zero(t, downloaded.Len(), "Nothing should be downloaded")
+}
+
+func TestMissingBlob(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	c, _ := startBlobPair(t, store, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := core.NewModelRequest()
+	req.BlobRefs = []core.BlobID{"blob-missing"}
+	_, err = c.ProcessModel(ctx, req)
+	assert.ErrorIs(t, err, core.ErrBlobNotFound, "Request referring to a missing blob should fail")
+
+	_, err = c.DownloadBlob(ctx, "blob-missing", io.Discard)
+	assert.ErrorIs(t, err, core.ErrBlobNotFound, "Download of a missing blob should fail")
+}
+
+func TestUploadBlobResumesAfterDisconnect(t *testing.T) {
+	files, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	store := &DisconnectingBlobStore{FileBlobStore: files, disconnectAt: 3}
+	c, srv := startBlobPair(t, store, []client.Option{
+		client.WithBlobChunkSize(256 << 10),
+		client.WithStaticTuning(),
+		client.WithReconnectDelay(10 * time.Millisecond),
+		client.WithMaxReconnectAttempts(10),
+	})
+	store.server = srv
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, sum := randomBlob(t, 2<<20+1000)
+	id, err := c.UploadBlob(ctx, bytes.NewReader(data), core.BlobMeta{})
+	require.NoError(t, err, "Upload should carry on after reconnecting")
+	assert.Equal(t, int32(9), atomic.LoadInt32(&store.writes), "Each chunk should be written once")
+
+	meta, err := store.Stat(ctx, id)
+	require.NoError(t, err, "Blob should be stored")
+	assert.Equal(t, sum, meta.SHA256, "Stored hash should match the blob")
+}
+
+func TestUploadBlobFailsWithoutReconnect(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewFileBlobStore(dir)
+	require.NoError(t, err, "Creating the store should succeed")
+	store := &DisconnectingBlobStore{FileBlobStore: files, disconnectAt: 2}
+	c, srv := startBlobPair(t, store,
+		[]client.Option{client.WithBlobChunkSize(64 << 10), client.WithAutoReconnect(false)},
+		WithBlobUploadTTL(50*time.Millisecond))
+	store.server = srv
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, _ := randomBlob(t, 256<<10)
+	_, err = c.UploadBlob(ctx, bytes.NewReader(data), core.BlobMeta{})
+	require.Error(t, err, "Upload should fail once the connection is lost")
+
+	// The unfinished upload is discarded once it has waited out its TTL
+	time.Sleep(100 * time.Millisecond)
+	srv.uploads.mu.Lock()
+	srv.uploads.sweepLocked(time.Now(), srv.Options().BlobUploadTTL)
+	assert.Empty(t, srv.uploads.uploads, "Unfinished upload should be discarded")
+	srv.uploads.mu.Unlock()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err, "Reading the store should succeed")
+	assert.Empty(t, entries, "Nothing of the upload should be left")
+}
+
+func TestBlobPutChunks(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	ctx := context.Background()
+	var uploads blobUploads
+	chunk := func(offset int64, data string, final bool) *core.BlobPutRequest {
+		return &core.BlobPutRequest{ID: "blob-1", Offset: offset, Data: []byte(data), Checksum: core.BlobChecksum([]byte(data)), Final: final}
+	}
+
+	resp, err := uploads.put(ctx, store, chunk(0, "hello ", false), time.Minute)
+	require.NoError(t, err, "First chunk should be accepted")
+	assert.Equal(t, int64(6), resp.Received, "First chunk should be received")
+
+	bad := chunk(6, "world", false)
+	bad.Checksum = core.BlobChecksum([]byte("other"))
+	_, err = uploads.put(ctx, store, bad, time.Minute)
+	assert.ErrorContains(t, err, core.ErrBlobChecksum.Error(), "Chunk not matching its checksum should be refused")
+
+	_, err = uploads.put(ctx, store, chunk(8, "rld", false), time.Minute)
+	assert.ErrorContains(t, err, "does not follow", "Chunk leaving a gap should be refused")
+
+	resp, err = uploads.put(ctx, store, chunk(0, "hello ", false), time.Minute)
+	require.NoError(t, err, "Chunk sent again should be acknowledged")
+	assert.Equal(t, int64(6), resp.Received, "Chunk sent again should not be written twice")
+
+	final := chunk(6, "world", true)
+	final.SHA256 = core.BlobChecksum([]byte("hello world"))
+	resp, err = uploads.put(ctx, store, final, time.Minute)
+	require.NoError(t, err, "Final chunk should be accepted")
+	require.NotNil(t, resp.Meta, "Final chunk should store the blob")
+	assert.Equal(t, int64(11), resp.Meta.Size, "Stored size should cover every chunk")
+
+	resp, err = uploads.put(ctx, store, final, time.Minute)
+	require.NoError(t, err, "Final chunk sent again should be acknowledged")
+	assert.Equal(t, final.SHA256, resp.Meta.SHA256, "Final chunk sent again should report the stored blob")
+
+	_, err = uploads.put(ctx, store, chunk(0, "again", true), time.Minute)
+	assert.ErrorContains(t, err, "already exists", "Stored blob should not be overwritten")
+
+	content, _, err := store.Open(ctx, "blob-1")
+	require.NoError(t, err, "Stored blob should open")
+	defer content.Close()
+	stored, err := io.ReadAll(content)
+	require.NoError(t, err, "Stored blob should be readable")
+	assert.Equal(t, "hello world", string(stored), "Stored blob should hold every chunk once")
+}
+
+func TestBlobPutWholeChecksum(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	ctx := context.Background()
+	var uploads blobUploads
+
+	put := &core.BlobPutRequest{ID: "blob-2", Data: []byte("data"), Checksum: core.BlobChecksum([]byte("data")), Final: true, SHA256: core.BlobChecksum([]byte("other"))}
+	_, err = uploads.put(ctx, store, put, time.Minute)
+	assert.ErrorContains(t, err, core.ErrBlobChecksum.Error(), "Blob not matching its checksum should be refused")
+	_, err = store.Stat(ctx, "blob-2")
+	assert.ErrorIs(t, err, core.ErrBlobNotFound, "Refused blob should not be stored")
+}
+
+func TestFileBlobStore(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	ctx := context.Background()
+
+	_, err = store.Create(ctx, "../escape", core.BlobMeta{})
+	assert.Error(t, err, "IDs that are not file names should be refused")
+
+	writer, err := store.Create(ctx, "blob-3", core.BlobMeta{})
+	require.NoError(t, err, "Create should succeed")
+	_, err = writer.Write([]byte("content"))
+	require.NoError(t, err, "Write should succeed")
+	_, err = store.Stat(ctx, "blob-3")
+	assert.ErrorIs(t, err, core.ErrBlobNotFound, "Uncommitted blob should not be found")
+	require.NoError(t, writer.Commit(core.BlobMeta{Name: "c", Size: 7}), "Commit should succeed")
+
+	meta, err := store.Stat(ctx, "blob-3")
+	require.NoError(t, err, "Committed blob should be found")
+	assert.Equal(t, "c", meta.Name, "Metadata should be stored")
+
+	require.NoError(t, store.Delete(ctx, "blob-3"), "Delete should succeed")
+	_, _, err = store.Open(ctx, "blob-3")
+	assert.ErrorIs(t, err, core.ErrBlobNotFound, "Deleted blob should not be found")
+	assert.ErrorIs(t, store.Delete(ctx, "blob-3"), core.ErrBlobNotFound, "Deleting a missing blob should fail")
+}
+
+func TestBlobsFromContext(t *testing.T) {
+	assert.Nil(t, BlobsFromContext(context.Background()), "Contexts outside a server should carry no store")
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// requestCancelKey is the context key of the function cancelling the request
+// being handled.
+type requestCancelKey struct{}
+
+// inflightRequests tracks the cancel functions of the model requests being
+// handled, by request ID, for CancelRequest. Clients choose request IDs, so
+// several requests may share one.
+type inflightRequests struct {
+	mu   sync.Mutex
+	byID map[string]map[*context.CancelCauseFunc]struct{}
+}
+
+// add tracks cancel under id until the returned function is called.
+func (r *inflightRequests) add(id string, cancel context.CancelCauseFunc) (remove func()) {
+	entry := &cancel
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.byID == nil {
+		r.byID = make(map[string]map[*context.CancelCauseFunc]struct{})
+	}
+	if r.byID[id] == nil {
+		r.byID[id] = make(map[*context.CancelCauseFunc]struct{})
+	}
+	r.byID[id][entry] = struct{}{}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.byID[id], entry)
+		if len(r.byID[id]) == 0 {
+			delete(r.byID, id)
+		}
+	}
+}
+
+// cancel cancels every request with id with cause, returning how many there were.
+func (r *inflightRequests) cancel(id string, cause core.Cause) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for entry := range r.byID[id] {
+		(*entry)(cause)
+	}
+	return len(r.byID[id])
+}
+
+// callCancels tracks the cancel functions of the calls a connection is
+// handling, by JSON-RPC ID, for core.MethodCancelRequest.
+type callCancels struct {
+	mu   sync.Mutex
+	byID map[jsonrpc2.ID]*context.CancelCauseFunc
+}
+
+// add tracks cancel under id until the returned function is called.
+func (c *callCancels) add(id jsonrpc2.ID, cancel context.CancelCauseFunc) (remove func()) {
+	entry := &cancel
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byID == nil {
+		c.byID = make(map[jsonrpc2.ID]*context.CancelCauseFunc)
+	}
+	c.byID[id] = entry
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.byID[id] == entry {
+			delete(c.byID, id)
+		}
+	}
+}
+
+// cancel cancels the call with id with core.CauseClientCancel. Calls that
+// have been answered are no longer tracked, so cancelling them does nothing.
+func (c *callCancels) cancel(id jsonrpc2.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.byID[id]; ok {
+		(*entry)(core.CauseClientCancel)
+	}
+}
+
+// withRequestCancel returns a copy of ctx that the request's handler sees,
+// cancellable with a core.Cause.
+func withRequestCancel(ctx context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return context.WithValue(ctx, requestCancelKey{}, cancel), cancel
+}
+
+// trackRequest makes the request handled with ctx cancellable by
+// CancelRequest under id until the returned function is called.
+func (s *Server) trackRequest(ctx context.Context, id string) (untrack func()) {
+	cancel, ok := ctx.Value(requestCancelKey{}).(context.CancelCauseFunc)
+	if !ok {
+		return func() {}
+	}
+	return s.inflight.add(id, cancel)
+}
+
+// CancelRequest cancels the context of the model request with the given ID,
+// and of every batch item and job with it, with core.CauseAdminKill. Handlers
+// see the cause with core.CancelCause; the request fails with whatever its
+// handler returns. It returns an error if no such request is being handled.
+func (s *Server) CancelRequest(requestID string) error {
+	if s.inflight.cancel(requestID, core.CauseAdminKill) == 0 {
+		return fmt.Errorf("no request with ID %s in flight", requestID)
+	}
+	s.options.Logger.Warn("Request cancelled by operator", core.LogFieldRequestID, requestID)
+	return nil
+}
+
+// serveCancelRequest cancels the request named by the id query parameter of
+// a POST, answering 404 if it is not in flight.
+func (s *Server) serveCancelRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing request id", http.StatusBadRequest)
+		return
+	}
+	if err := s.CancelRequest(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// countCancelled reports the cause a request was cancelled with, if any, to a
+// metrics collector implementing core.CancelCollector.
+func (h *rpcHandler) countCancelled(ctx context.Context, method string) {
+	collector, ok := h.server.options.Metrics.(core.CancelCollector)
+	if !ok {
+		return
+	}
+	if cause := core.CancelCause(ctx); cause != "" {
+		h.server.sinks.Do(sinkMetrics, func() error {
+			collector.RequestCancelled(method, cause)
+			return nil
+		})
+	}
+}
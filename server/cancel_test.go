@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/metrics"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// CauseModelHandler blocks until its context is done and reports the cause
+type CauseModelHandler struct {
+	started chan string // Request IDs
+	causes  chan core.Cause
+}
+
+func newCauseModelHandler() *CauseModelHandler {
+	return &CauseModelHandler{started: make(chan string, 16), causes: make(chan core.Cause, 16)}
+}
+
+func (h *CauseModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *CauseModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	h.started <- req.ID
+	<-ctx.Done()
+	h.causes <- core.CancelCause(ctx)
+	return nil, ctx.Err()
+}
+
+// requireCause waits for the handler to report a cause and checks it
+func (h *CauseModelHandler) requireCause(t *testing.T, expected core.Cause, msg string) {
+	select {
+	case cause := <-h.causes:
+		assert.Equal(t, expected, cause, msg)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "Handler should be cancelled", msg)
+	}
+}
+
+// requireAuditedCause checks that the one audit event for method carries cause
+func requireAuditedCause(t *testing.T, logger *auditRecorder, method string, cause core.Cause) {
+	var event *core.AuditEvent
+	require.Eventually(t, func() bool {
+		for _, e := range logger.Events() {
+			if e.Method == method {
+				e := e
+				event = &e
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 5*time.Millisecond, "Cancelled %s should be audited", method)
+	assert.Equal(t, cause, event.CancelCause, "Audit event should record the cause")
+	assert.False(t, event.Success, "Cancelled request should not be audited as a success")
+}
+
+func TestCancelCauseClientCancel(t *testing.T) {
+	handler := newCauseModelHandler()
+	_, c := startJobServer(t, handler)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	<-handler.started
+	_, err = c.CancelJob(ctx, id)
+	require.NoError(t, err, "Cancelling should succeed")
+
+	// Jobs end without a reply, so there is no audit event to check
+	handler.requireCause(t, core.CauseClientCancel, "Cancelling a job should be a client cancel")
+	status, err := c.WaitForJob(ctx, id)
+	require.NoError(t, err, "Waiting for the cancelled job should succeed")
+	assert.Equal(t, core.JobCancelled, status.State, "Job should be cancelled")
+}
+
+func TestCancelCauseDeadline(t *testing.T) {
+	handler := newCauseModelHandler()
+	_, c := startServerWithHandler(t, handler)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	batch := &core.BatchRequest{Requests: []*core.ModelRequest{core.NewModelRequest()}, Timeout: 50 * time.Millisecond}
+	resp, err := c.ProcessBatch(ctx, batch)
+	require.NoError(t, err, "Batch should complete")
+	assert.False(t, resp.Responses[0].Success, "Item running out of time should fail")
+	handler.requireCause(t, core.CauseDeadline, "Batch timeout should be a deadline")
+}
+
+func TestCancelCauseShutdown(t *testing.T) {
+	handler := newCauseModelHandler()
+	logger := &auditRecorder{}
+	srv, c := startServerWithHandler(t, handler, WithAuditSink(logger))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	inFlight := processAsync(ctx, c)
+	<-handler.started
+	require.NoError(t, srv.Stop(), "Server should stop")
+
+	handler.requireCause(t, core.CauseShutdown, "Stopping the server should be a shutdown")
+	<-inFlight
+	requireAuditedCause(t, logger, core.MethodProcessModel, core.CauseShutdown)
+}
+
+func TestCancelCauseAdminKill(t *testing.T) {
+	handler := newCauseModelHandler()
+	logger := &auditRecorder{}
+	srv, c := startServerWithHandler(t, handler, WithAuditSink(logger))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	inFlight := processAsync(ctx, c)
+	id := <-handler.started
+	require.NoError(t, srv.CancelRequest(id), "Request in flight should be cancelled")
+
+	handler.requireCause(t, core.CauseAdminKill, "CancelRequest should be an admin kill")
+	assert.Error(t, <-inFlight, "Killed request should fail")
+	requireAuditedCause(t, logger, core.MethodProcessModel, core.CauseAdminKill)
+	assert.Error(t, srv.CancelRequest(id), "Finished request should not be cancelled")
+}
+
+func TestCancelRequestEndpoint(t *testing.T) {
+	handler := newCauseModelHandler()
+	collector := metrics.NewExpvarCollector("mcp_server_cancel_request_test")
+	srv, c := startServerWithHandler(t, handler, WithMetrics(collector), WithMetricsAddr("127.0.0.1:0"))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	endpoint := "http://" + srv.MetricsAddr().String() + "/requests/cancel?id="
+
+	resp, err := http.Post(endpoint+"unknown", "", nil)
+	require.NoError(t, err, "Endpoint should be reachable")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "Unknown request should not be found")
+
+	inFlight := processAsync(ctx, c)
+	id := <-handler.started
+	resp, err = http.Post(endpoint+id, "", nil)
+	require.NoError(t, err, "Endpoint should be reachable")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, "Request in flight should be cancelled")
+
+	handler.requireCause(t, core.CauseAdminKill, "Endpoint should kill the request")
+	<-inFlight
+	assert.Eventually(t, func() bool {
+		return collector.Cancellations(core.CauseAdminKill) == 1
+	}, 2*time.Second, 5*time.Millisecond, "Cancellation should be counted by cause")
+}
+
+func TestCancelRequestNotification(t *testing.T) {
+	handler := newCauseModelHandler()
+	logger := &auditRecorder{}
+	_, c := startServerWithHandler(t, handler, WithAuditSink(logger))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancelling the call's context reaches the handler while it runs
+	inFlight := processAsync(ctx, c)
+	<-handler.started
+	cancelled := time.Now()
+	cancel()
+
+	handler.requireCause(t, core.CauseClientCancel, "Cancelling the call should be a client cancel")
+	assert.Less(t, time.Since(cancelled), 500*time.Millisecond, "Handler should be cancelled promptly")
+	assert.ErrorIs(t, <-inFlight, context.Canceled, "Cancelled call should fail with the context's error")
+	requireAuditedCause(t, logger, core.MethodProcessModel, core.CauseClientCancel)
+}
+
+func TestCancelRequestReply(t *testing.T) {
+	handler := newCauseModelHandler()
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	conn := dialRaw(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id := jsonrpc2.ID{Str: "call-1", IsString: true}
+	call, err := conn.DispatchCall(ctx, core.MethodProcessModel, testutil.CreateTestModelRequest(), jsonrpc2.PickID(id))
+	require.NoError(t, err, "Call should be sent")
+	<-handler.started
+	require.NoError(t, conn.Notify(ctx, core.MethodCancelRequest, core.CancelRequestParams{ID: id}), "Cancellation should be sent")
+
+	handler.requireCause(t, core.CauseClientCancel, "Cancellation should reach the handler")
+	requireCode(t, call.Wait(ctx, nil), core.CodeRequestCancelled, "Cancelled call should be answered with CodeRequestCancelled")
+}
+
+func TestCancelRequestAfterReply(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+
+	conn := dialRaw(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id := jsonrpc2.ID{Num: 1}
+	var resp core.ModelResponse
+	require.NoError(t, conn.Call(ctx, core.MethodProcessModel, testutil.CreateTestModelRequest(), &resp, jsonrpc2.PickID(id)), "Call should succeed")
+
+	// Cancelling answered or unknown calls does nothing, not even to a later
+	// call reusing the ID
+	require.NoError(t, conn.Notify(ctx, core.MethodCancelRequest, core.CancelRequestParams{ID: id}), "Cancellation should be sent")
+	require.NoError(t, conn.Notify(ctx, core.MethodCancelRequest, core.CancelRequestParams{ID: jsonrpc2.ID{Num: 99}}), "Cancellation should be sent")
+	require.NoError(t, conn.Notify(ctx, core.MethodCancelRequest, "malformed"), "Cancellation should be sent")
+	require.NoError(t, conn.Call(ctx, core.MethodProcessModel, testutil.CreateTestModelRequest(), &resp, jsonrpc2.PickID(id)), "Call reusing the ID should succeed")
+	assert.True(t, resp.Success, "Call reusing the ID should be processed")
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// capabilities returns what the server announces in the handshake.
+func (s *Server) capabilities() core.Capabilities {
+	features := core.Capabilities{ProtocolVersion: core.ProtocolVersion, Features: s.options.Features}
+	// Negotiating with itself sorts the features and drops duplicates
+	return core.Negotiate(features, features)
+}
+
+// handleInitialize answers the client's handshake, refusing a protocol version
+// below the minimum, and records the capabilities both sides share.
+func (h *rpcHandler) handleInitialize(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	var err error
+	if caps, rpcErr := h.initialize(req); rpcErr != nil {
+		err = conn.ReplyWithError(ctx, req.ID, rpcErr)
+	} else {
+		err = conn.Reply(ctx, req.ID, caps)
+	}
+	if err != nil {
+		h.logError("Error replying to client", req, err)
+	}
+}
+
+// initialize returns the server's capabilities in reply to the handshake req,
+// or the error to refuse it with.
+func (h *rpcHandler) initialize(req *jsonrpc2.Request) (core.Capabilities, *jsonrpc2.Error) {
+	var clientCaps core.Capabilities
+	if req.Params == nil {
+		return clientCaps, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "invalid params: missing capabilities"}
+	}
+	if err := json.Unmarshal(*req.Params, &clientCaps); err != nil {
+		return clientCaps, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+
+	if minimum := h.server.options.MinProtocolVersion; clientCaps.ProtocolVersion < minimum {
+		h.server.options.Logger.Warn("Refusing client with an old protocol version",
+			core.LogFieldRemoteAddr, h.remoteAddr,
+			"version", clientCaps.ProtocolVersion,
+			"minimum", minimum)
+		return clientCaps, &jsonrpc2.Error{
+			Code:    core.CodeUnsupportedVersion,
+			Message: fmt.Sprintf("protocol version %d is below the minimum %d", clientCaps.ProtocolVersion, minimum),
+		}
+	}
+
+	serverCaps := h.server.capabilities()
+	negotiated := core.Negotiate(clientCaps, serverCaps)
+	h.session.mu.Lock()
+	h.session.capabilities = &negotiated
+	h.session.mu.Unlock()
+	return serverCaps, nil
+}
+
+// negotiated returns the capabilities negotiated on the connection, or nil if
+// the client did not initialize.
+func (h *rpcHandler) negotiated() *core.Capabilities {
+	h.session.mu.Lock()
+	defer h.session.mu.Unlock()
+	return h.session.capabilities
+}
+
+// requireFeature returns an error if method needs a feature the server does
+// not announce, or the connection did not negotiate.
+func (h *rpcHandler) requireFeature(method string) error {
+	var feature core.Feature
+	switch method {
+	case core.MethodProcessModelBatch:
+		feature = core.FeatureBatch
+	case core.MethodProcessModelStream:
+		feature = core.FeatureStreaming
+	default:
+		return nil
+	}
+
+	serverCaps := h.server.capabilities()
+	if !serverCaps.Supports(feature) {
+		return fmt.Errorf("%s is not supported by the server", feature)
+	}
+	if caps := h.negotiated(); caps != nil && !caps.Supports(feature) {
+		return fmt.Errorf("%s was not negotiated on the connection", feature)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// CapabilitiesHandler reports the capabilities negotiated on the request's connection
+type CapabilitiesHandler struct{}
+
+func (h *CapabilitiesHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *CapabilitiesHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	if caps := core.CapabilitiesFromContext(ctx); caps != nil {
+		resp.Results["version"] = caps.ProtocolVersion
+		resp.Results["batch"] = caps.Supports(core.FeatureBatch)
+	}
+	return resp, nil
+}
+
+// startCapabilitiesServer starts a server with a CapabilitiesHandler and returns its transport
+func startCapabilitiesServer(t *testing.T, options ...Option) (*Server, core.Transport) {
+	srv, transport := startDurableServer(t, options...)
+	require.NoError(t, srv.RegisterHandler(&CapabilitiesHandler{}), "Handler registration should succeed")
+	return srv, transport
+}
+
+// rpcCode returns the JSON-RPC error code of err, or zero
+func rpcCode(err error) int64 {
+	if rpcErr, ok := err.(*jsonrpc2.Error); ok {
+		return rpcErr.Code
+	}
+	return 0
+}
+
+func TestHandshakeMatched(t *testing.T) {
+	srv, transport := startCapabilitiesServer(t)
+	c := startWatchClient(t, transport)
+
+	all := &core.Capabilities{ProtocolVersion: core.ProtocolVersion, Features: core.AllFeatures()}
+	assert.Equal(t, all, c.ServerCapabilities(), "Peers with every feature should negotiate them all")
+	require.Len(t, srv.Connections(), 1, "One connection should be served")
+	assert.Equal(t, all, srv.Connections()[0].Capabilities, "The server should record the same capabilities")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := c.ProcessModel(ctx, core.NewModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed")
+	assert.EqualValues(t, core.ProtocolVersion, resp.Results["version"], "Handlers should see the negotiated version")
+	assert.Equal(t, true, resp.Results["batch"], "Handlers should see the negotiated features")
+
+	_, err = c.ProcessModelBatch(ctx, []*core.ModelRequest{core.NewModelRequest()})
+	assert.NoError(t, err, "Batches should be allowed once negotiated")
+}
+
+func TestHandshakeDegraded(t *testing.T) {
+	_, transport := startCapabilitiesServer(t, WithFeatures(core.FeatureStreaming, core.FeatureCompression))
+	c := startWatchClient(t, transport, client.WithFeatures(core.FeatureBatch, core.FeatureCompression))
+
+	assert.Equal(t, &core.Capabilities{ProtocolVersion: core.ProtocolVersion, Features: []core.Feature{core.FeatureCompression}},
+		c.ServerCapabilities(), "Only features both sides support should be negotiated")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := c.ProcessModelBatch(ctx, []*core.ModelRequest{core.NewModelRequest()})
+	assert.ErrorIs(t, err, core.ErrUnsupportedCapability, "Batches should be refused when the server lacks them")
+	_, err = c.ProcessModelStream(ctx, core.NewModelRequest(), func(core.ModelChunk) {})
+	assert.ErrorIs(t, err, core.ErrUnsupportedCapability, "Streams should be refused when the client left them out")
+	resp, err := c.ProcessModel(ctx, core.NewModelRequest())
+	require.NoError(t, err, "Methods outside any feature should still work")
+	assert.Equal(t, false, resp.Results["batch"], "Handlers should see the missing feature")
+
+	// The server refuses what was not negotiated even if the client asks anyway
+	raw := dialRaw(t, transport)
+	var caps core.Capabilities
+	require.NoError(t, raw.Call(ctx, core.MethodInitialize, core.Capabilities{ProtocolVersion: core.ProtocolVersion}, &caps),
+		"Initialize should succeed")
+	assert.Equal(t, []core.Feature{core.FeatureCompression, core.FeatureStreaming}, caps.Features, "The server should announce its own features")
+	var batchResp core.BatchResponse
+	err = raw.Call(ctx, core.MethodProcessModelBatch, core.BatchRequest{Requests: []*core.ModelRequest{core.NewModelRequest()}}, &batchResp)
+	assert.Equal(t, core.CodeUnsupportedCapability, rpcCode(err), "Unsupported batches should be refused by the server")
+	var streamResp core.ModelResponse
+	err = raw.Call(ctx, core.MethodProcessModelStream, core.NewModelRequest(), &streamResp)
+	assert.Equal(t, core.CodeUnsupportedCapability, rpcCode(err), "Features the client did not announce should be refused")
+}
+
+func TestHandshakeRejected(t *testing.T) {
+	_, transport := startCapabilitiesServer(t, WithMinProtocolVersion(core.ProtocolVersion+1))
+
+	c := client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithLogger(core.NopLogger()))
+	err := c.Start()
+	assert.ErrorIs(t, err, core.ErrUnsupportedVersion, "A client below the minimum version should be refused")
+	assert.Equal(t, core.StatusFailed, c.Status(), "A refused client should not run")
+
+	// Clients that skip the handshake are refused too
+	raw := dialRaw(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var resp core.ModelResponse
+	err = raw.Call(ctx, core.MethodProcessModel, core.NewModelRequest(), &resp)
+	assert.Equal(t, core.CodeUnsupportedVersion, rpcCode(err), "Requests before the handshake should be refused")
+	var pong core.PingResponse
+	assert.NoError(t, raw.Call(ctx, core.MethodPing, nil, &pong), "Pings should still be answered")
+}
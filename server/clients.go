@@ -0,0 +1,124 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ErrClientDisconnected is the error OnClientDisconnect callbacks receive
+// for a client dropped by DisconnectClient.
+var ErrClientDisconnected = errors.New("disconnected by server")
+
+// OnClientConnect registers a callback invoked when the server begins
+// serving a client, before its first request is handled. Callbacks run in
+// the connection's goroutine, so the client waits for them.
+func (s *Server) OnClientConnect(callback func(core.ClientInfo)) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	s.connectCallbacks = append(s.connectCallbacks, callback)
+}
+
+// OnClientDisconnect registers a callback invoked once the server has stopped
+// serving a client. The error is nil if the client closed the connection,
+// ErrClientDisconnected if DisconnectClient dropped it, and the context's
+// error if the server stopped.
+func (s *Server) OnClientDisconnect(callback func(core.ClientInfo, error)) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	s.disconnectCallbacks = append(s.disconnectCallbacks, callback)
+}
+
+// Clients returns every client being served.
+func (s *Server) Clients() []core.ClientInfo {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	clients := make([]core.ClientInfo, 0, len(s.sessions))
+	for h := range s.sessions {
+		// Sessions of a TestInvoker have no client behind them
+		if h.id != "" {
+			clients = append(clients, h.clientInfo())
+		}
+	}
+	return clients
+}
+
+// DisconnectClient closes the connection of the client with the given ID. It
+// returns an error if no such client is being served.
+func (s *Server) DisconnectClient(id string) error {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	for h := range s.sessions {
+		if h.id != "" && h.id == id {
+			h.idleMu.Lock()
+			h.disconnectErr = ErrClientDisconnected
+			h.idleMu.Unlock()
+			return h.closer.Close()
+		}
+	}
+	return fmt.Errorf("no client with ID %s", id)
+}
+
+// newClientID returns the ID of the next connection served.
+func (s *Server) newClientID() string {
+	return strconv.FormatUint(atomic.AddUint64(&s.nextClientID, 1), 10)
+}
+
+// clientConnected runs the OnClientConnect callbacks for h.
+func (s *Server) clientConnected(h *rpcHandler) {
+	s.clientsMu.Lock()
+	callbacks := append([]func(core.ClientInfo){}, s.connectCallbacks...)
+	s.clientsMu.Unlock()
+
+	if len(callbacks) == 0 {
+		return
+	}
+	info := h.clientInfo()
+	for _, callback := range callbacks {
+		callback(info)
+	}
+}
+
+// clientDisconnected runs the OnClientDisconnect callbacks for h, whose
+// connection ended with err unless the server dropped it.
+func (s *Server) clientDisconnected(h *rpcHandler, err error) {
+	s.clientsMu.Lock()
+	callbacks := append([]func(core.ClientInfo, error){}, s.disconnectCallbacks...)
+	s.clientsMu.Unlock()
+
+	if len(callbacks) == 0 {
+		return
+	}
+	h.idleMu.Lock()
+	if h.disconnectErr != nil {
+		err = h.disconnectErr
+	}
+	h.idleMu.Unlock()
+	info := h.clientInfo()
+	for _, callback := range callbacks {
+		callback(info, err)
+	}
+}
+
+// clientInfo describes the client h serves.
+func (h *rpcHandler) clientInfo() core.ClientInfo {
+	info := core.ClientInfo{ID: h.id, RemoteAddr: h.remoteAddr, ConnectedAt: h.connectedAt}
+	h.session.mu.Lock()
+	if h.session.principal != nil {
+		principal := *h.session.principal
+		info.Principal = &principal
+	}
+	h.session.mu.Unlock()
+	return info
+}
+
+// peerInfo returns the PeerInfo handlers see for requests on the connection,
+// which negotiated caps.
+func (h *rpcHandler) peerInfo(caps *core.Capabilities) core.PeerInfo {
+	return core.PeerInfo{ClientInfo: h.clientInfo(), Capabilities: caps}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialRaw opens a JSON-RPC connection to the server behind transport without a client
+func dialRaw(t *testing.T, transport core.Transport) *jsonrpc2.Conn {
+	netConn, err := transport.Dial(context.Background(), "")
+	require.NoError(t, err, "Dial should succeed")
+	stream := jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{})
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(
+		func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) { return nil, nil },
+	))
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// disconnectEvent is an OnClientDisconnect callback's arguments
+type disconnectEvent struct {
+	info core.ClientInfo
+	err  error
+}
+
+func TestClientLifecycleEvents(t *testing.T) {
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterAuthScheme("token", NewStaticTokenVerifier(map[string]core.Principal{
+		"ci-token": {ID: "ci"},
+	}, 0)), "Token scheme registration should succeed")
+
+	connected := make(chan core.ClientInfo, 2)
+	disconnected := make(chan disconnectEvent, 2)
+	srv.OnClientConnect(func(info core.ClientInfo) { connected <- info })
+	srv.OnClientDisconnect(func(info core.ClientInfo, err error) { disconnected <- disconnectEvent{info, err} })
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+
+	waitConnect := func() core.ClientInfo {
+		select {
+		case info := <-connected:
+			return info
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnClientConnect should fire")
+			return core.ClientInfo{}
+		}
+	}
+
+	before := time.Now()
+	first := dialRaw(t, transport)
+	firstInfo := waitConnect()
+	second := dialRaw(t, transport)
+	secondInfo := waitConnect()
+
+	assert.NotEmpty(t, firstInfo.ID, "Clients should get an ID")
+	assert.NotEqual(t, firstInfo.ID, secondInfo.ID, "Clients should get distinct IDs")
+	assert.False(t, firstInfo.ConnectedAt.Before(before), "Connect time should be recorded")
+	assert.Nil(t, firstInfo.Principal, "Clients should connect unauthenticated")
+
+	// Clients reports the principal once the client authenticates
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var authResp core.AuthResponse
+	require.NoError(t, second.Call(ctx, core.MethodAuthenticate, core.AuthRequest{
+		Scheme:      "token",
+		Credentials: map[string]string{"token": "ci-token"},
+	}, &authResp), "Authentication should succeed")
+
+	clients := srv.Clients()
+	require.Len(t, clients, 2, "Clients should list both clients")
+	byID := map[string]core.ClientInfo{}
+	for _, info := range clients {
+		byID[info.ID] = info
+	}
+	assert.Nil(t, byID[firstInfo.ID].Principal, "Unauthenticated client should have no principal")
+	require.NotNil(t, byID[secondInfo.ID].Principal, "Authenticated client should report its principal")
+	assert.Equal(t, "ci", byID[secondInfo.ID].Principal.ID, "Authenticated client should report its principal")
+
+	// Dropping a client closes only its connection
+	assert.Error(t, srv.DisconnectClient("unknown"), "Unknown client IDs should be refused")
+	require.NoError(t, srv.DisconnectClient(firstInfo.ID), "DisconnectClient should succeed")
+	select {
+	case <-first.DisconnectNotify():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Dropped client's connection should close")
+	}
+	select {
+	case event := <-disconnected:
+		assert.Equal(t, firstInfo.ID, event.info.ID, "OnClientDisconnect should name the dropped client")
+		assert.ErrorIs(t, event.err, ErrClientDisconnected, "OnClientDisconnect should report the server dropped the client")
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnClientDisconnect should fire")
+	}
+	select {
+	case <-second.DisconnectNotify():
+		t.Fatal("Other client should stay connected")
+	default:
+	}
+
+	// A client closing its own connection is reported without an error
+	second.Close()
+	select {
+	case event := <-disconnected:
+		assert.Equal(t, secondInfo.ID, event.info.ID, "OnClientDisconnect should name the departed client")
+		assert.NoError(t, event.err, "Client closing its connection should not be an error")
+		require.NotNil(t, event.info.Principal, "Disconnect should report the client's principal")
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnClientDisconnect should fire")
+	}
+	assert.Empty(t, srv.Clients(), "Departed clients should not be listed")
+}
+
+// peerHandler answers with the peer of the request
+type peerHandler struct{}
+
+func (peerHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (peerHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	peer, ok := core.PeerFromContext(ctx)
+	if !ok {
+		return nil, errors.New("no peer in context")
+	}
+	resp := core.NewModelResponse(req)
+	resp.Results["id"] = peer.ID
+	resp.Results["remoteAddr"] = peer.RemoteAddr
+	resp.Results["connectedAt"] = peer.ConnectedAt
+	resp.Results["principal"] = peer.Principal.ID
+	resp.Results["initialized"] = peer.Capabilities != nil
+	return resp, nil
+}
+
+func TestPeerFromContext(t *testing.T) {
+	srv := New(WithPort(0), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(peerHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.RegisterAuthScheme("token", NewStaticTokenVerifier(map[string]core.Principal{
+		"ci-token": {ID: "ci"},
+	}, 0)), "Token scheme registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+
+	_, ok := core.PeerFromContext(context.Background())
+	assert.False(t, ok, "Context outside a request should have no peer")
+
+	netConn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err, "Dial should succeed")
+	stream := jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{})
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(
+		func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) { return nil, nil },
+	))
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var authResp core.AuthResponse
+	require.NoError(t, conn.Call(ctx, core.MethodAuthenticate, core.AuthRequest{
+		Scheme:      "token",
+		Credentials: map[string]string{"token": "ci-token"},
+	}, &authResp), "Authentication should succeed")
+
+	// The handler sees the connection the request arrived on
+	var resp core.ModelResponse
+	require.NoError(t, conn.Call(ctx, core.MethodProcessModel, core.NewModelRequest(), &resp), "Request should succeed")
+	clients := srv.Clients()
+	require.Len(t, clients, 1, "One client should be connected")
+	assert.Equal(t, netConn.LocalAddr().String(), resp.Results["remoteAddr"], "Peer should be the client's address")
+	assert.Equal(t, clients[0].ID, resp.Results["id"], "Peer should carry the connection ID")
+	assert.NotEmpty(t, resp.Results["connectedAt"], "Peer should carry the connect time")
+	assert.Equal(t, "ci", resp.Results["principal"], "Peer should carry the principal")
+	assert.Equal(t, false, resp.Results["initialized"], "Peer without a handshake should have no capabilities")
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// CompressionStats reports what compressing messages sent to clients
+// achieved. Its byte counts cover message bodies, not frame headers.
+type CompressionStats struct {
+	Messages        uint64        `json:"messages"`        // Messages compressed
+	Skipped         uint64        `json:"skipped"`         // Messages over the threshold sent uncompressed as adaptive compression had disabled their method
+	OriginalBytes   uint64        `json:"originalBytes"`   // Encoded size of the messages compressed
+	CompressedBytes uint64        `json:"compressedBytes"` // Size of their compressed bodies, as sent
+	CPUTime         time.Duration `json:"cpuTime"`         // Time spent compressing them
+}
+
+// Ratio returns the compressed size of the messages over their original
+// size, or zero if none was compressed.
+func (s CompressionStats) Ratio() float64 {
+	if s.OriginalBytes == 0 {
+		return 0
+	}
+	return float64(s.CompressedBytes) / float64(s.OriginalBytes)
+}
+
+// add counts a message of size bytes compressed to compressed bytes.
+func (s *CompressionStats) add(size, compressed int, elapsed time.Duration) {
+	s.Messages++
+	s.OriginalBytes += uint64(size)
+	s.CompressedBytes += uint64(compressed)
+	s.CPUTime += elapsed
+}
+
+// MethodCompression reports the compression of one method's messages, its
+// requests and notifications and the replies to its calls, across
+// connections.
+type MethodCompression struct {
+	CompressionStats
+	Disabled     bool      `json:"disabled"`             // Whether adaptive compression sends the method's messages uncompressed
+	RollingRatio float64   `json:"rollingRatio"`         // Average ratio of the method's last messages compressed, as adaptive compression judged it
+	Reprobe      time.Time `json:"reprobe,omitempty"`    // When a disabled method is next compressed, to see whether it shrinks again
+	DisabledAt   time.Time `json:"disabledAt,omitempty"` // When adaptive compression last disabled the method
+}
+
+// methodCompression is the compression of one method's messages and the
+// ratios of its last messages compressed.
+type methodCompression struct {
+	stats      CompressionStats
+	ratios     []float64 // Ratios of the last messages compressed, oldest overwritten first
+	next       int       // Index in ratios the next ratio is written to
+	disabledAt time.Time // Zero while the method is compressed
+}
+
+// rollingRatio returns the average of the ratios kept.
+func (m *methodCompression) rollingRatio() float64 {
+	if len(m.ratios) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, ratio := range m.ratios {
+		sum += ratio
+	}
+	return sum / float64(len(m.ratios))
+}
+
+// compressionTracker accounts for the compression of every connection by
+// method and, with WithAdaptiveCompression, decides which methods are
+// compressed: one whose last window messages shrank on average to more than
+// ratio of their size is sent uncompressed until reprobe has passed, when
+// its messages are compressed again to see whether they shrink now.
+type compressionTracker struct {
+	ratio   float64
+	window  int
+	reprobe time.Duration
+
+	mu      sync.Mutex
+	methods map[string]*methodCompression
+}
+
+func newCompressionTracker(o Options) *compressionTracker {
+	return &compressionTracker{
+		ratio:   o.CompressionRatioLimit,
+		window:  o.CompressionWindow,
+		reprobe: o.CompressionReprobe,
+		methods: make(map[string]*methodCompression),
+	}
+}
+
+// method returns the compression of method's messages, adding it if new.
+// The caller holds t.mu.
+func (t *compressionTracker) method(method string) *methodCompression {
+	m, ok := t.methods[method]
+	if !ok {
+		m = &methodCompression{}
+		t.methods[method] = m
+	}
+	return m
+}
+
+// compress reports whether a message of method over the threshold should be
+// compressed, counting it skipped if not. A disabled method whose reprobe
+// time has come is enabled again, its window starting afresh.
+func (t *compressionTracker) compress(method string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := t.method(method)
+	if m.disabledAt.IsZero() {
+		return true
+	}
+	if now.Sub(m.disabledAt) >= t.reprobe {
+		m.disabledAt = time.Time{}
+		m.ratios, m.next = m.ratios[:0], 0
+		return true
+	}
+	m.stats.Skipped++
+	return false
+}
+
+// record counts a message of method compressed from size to compressed
+// bytes, disabling the method if its last window messages shrank too little.
+func (t *compressionTracker) record(method string, size, compressed int, elapsed time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := t.method(method)
+	m.stats.add(size, compressed, elapsed)
+	if t.ratio <= 0 || t.window <= 0 || size == 0 {
+		return
+	}
+
+	ratio := float64(compressed) / float64(size)
+	if len(m.ratios) < t.window {
+		m.ratios = append(m.ratios, ratio)
+	} else {
+		m.ratios[m.next] = ratio
+	}
+	m.next = (m.next + 1) % t.window
+	if len(m.ratios) == t.window && m.rollingRatio() > t.ratio {
+		m.disabledAt = now
+	}
+}
+
+// snapshot returns the compression of every method and the totals across
+// them.
+func (t *compressionTracker) snapshot() (CompressionStats, map[string]MethodCompression) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total CompressionStats
+	if len(t.methods) == 0 {
+		return total, nil
+	}
+	methods := make(map[string]MethodCompression, len(t.methods))
+	for name, m := range t.methods {
+		method := MethodCompression{
+			CompressionStats: m.stats,
+			Disabled:         !m.disabledAt.IsZero(),
+			RollingRatio:     m.rollingRatio(),
+		}
+		if method.Disabled {
+			method.DisabledAt = m.disabledAt
+			method.Reprobe = m.disabledAt.Add(t.reprobe)
+		}
+		methods[name] = method
+		total.Messages += m.stats.Messages
+		total.Skipped += m.stats.Skipped
+		total.OriginalBytes += m.stats.OriginalBytes
+		total.CompressedBytes += m.stats.CompressedBytes
+		total.CPUTime += m.stats.CPUTime
+	}
+	return total, methods
+}
+
+// connCompression is the core.CompressionAdvisor of one connection. It
+// attributes each message to a method, replies by the request they answer,
+// and accounts for it both on the connection and in the server's tracker.
+type connCompression struct {
+	tracker   *compressionTracker
+	threshold int
+
+	mu      sync.Mutex
+	stats   CompressionStats
+	pending map[jsonrpc2.ID]string // Methods of the requests awaiting a reply
+}
+
+func newConnCompression(tracker *compressionTracker, threshold int) *connCompression {
+	return &connCompression{
+		tracker:   tracker,
+		threshold: threshold,
+		pending:   make(map[jsonrpc2.ID]string),
+	}
+}
+
+// expect notes the method of a request, so its reply is accounted to it.
+func (c *connCompression) expect(id jsonrpc2.ID, method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[id] = method
+}
+
+// expectReply notes the method of req, a call, so the reply to it is
+// accounted to the method once the connection is compressed.
+func (h *rpcHandler) expectReply(req *jsonrpc2.Request) {
+	if h.compression == nil {
+		return
+	}
+	if compression, _ := h.frames.negotiated(); compression != core.CompressionNone {
+		h.compression.expect(req.ID, req.Method)
+	}
+}
+
+// Advise implements core.CompressionAdvisor.
+func (c *connCompression) Advise(obj interface{}, data []byte) func(int, time.Duration) {
+	method := c.method(obj, data)
+	if len(data) < c.threshold {
+		return nil
+	}
+	if !c.tracker.compress(method, time.Now()) {
+		c.mu.Lock()
+		c.stats.Skipped++
+		c.mu.Unlock()
+		return nil
+	}
+	return func(compressed int, elapsed time.Duration) {
+		c.mu.Lock()
+		c.stats.add(len(data), compressed, elapsed)
+		c.mu.Unlock()
+		c.tracker.record(method, len(data), compressed, elapsed, time.Now())
+	}
+}
+
+// method returns the method obj, encoded as data, is accounted to: its own
+// for requests and notifications, that of the request it answers for
+// replies. Messages whose method cannot be told are accounted to "".
+func (c *connCompression) method(obj interface{}, data []byte) string {
+	if len(data) == 0 || data[0] != '{' {
+		// Read bodies of other codecs from their JSON encoding, which costs
+		// a second encoding
+		var err error
+		if data, err = json.Marshal(obj); err != nil {
+			return ""
+		}
+	}
+
+	// Requests name their method first and replies their ID, so there is no
+	// need to decode the rest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return ""
+	}
+	key, err := dec.Token()
+	if err != nil {
+		return ""
+	}
+	switch key {
+	case "method":
+		var method string
+		if dec.Decode(&method) != nil {
+			return ""
+		}
+		return method
+	case "id":
+		var id jsonrpc2.ID
+		if dec.Decode(&id) != nil {
+			return ""
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		method := c.pending[id]
+		delete(c.pending, id)
+		return method
+	}
+	return ""
+}
+
+// snapshot returns the connection's compression so far.
+func (c *connCompression) snapshot() CompressionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// CompressionByMethod returns the compression of each method's messages to
+// clients, including whether adaptive compression has disabled it.
+func (s *Server) CompressionByMethod() map[string]MethodCompression {
+	_, methods := s.compression.snapshot()
+	return methods
+}
+
+// serveCompression writes CompressionByMethod as JSON.
+func (s *Server) serveCompression(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.CompressionByMethod())
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/msgpack"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingTransport keeps a copy of everything the clients dialing through
+// it read, the frames the server wrote to them
+type capturingTransport struct {
+	core.Transport
+	mu   sync.Mutex
+	read bytes.Buffer
+}
+
+func (t *capturingTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := t.Transport.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &capturingConn{Conn: conn, transport: t}, nil
+}
+
+// captured returns a copy of the bytes read so far
+func (t *capturingTransport) captured() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return bytes.Clone(t.read.Bytes())
+}
+
+type capturingConn struct {
+	net.Conn
+	transport *capturingTransport
+}
+
+func (c *capturingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.transport.mu.Lock()
+	c.transport.read.Write(p[:n])
+	c.transport.mu.Unlock()
+	return n, err
+}
+
+// wireCompression adds up the gzip frames in data as CompressionStats,
+// their bodies as sent and once decompressed
+func wireCompression(t *testing.T, data []byte) CompressionStats {
+	var stats CompressionStats
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			return stats
+		}
+		require.NoError(t, err, "Captured headers should parse")
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		require.NoError(t, err, "Captured frames should have a length")
+		body := make([]byte, length)
+		_, err = io.ReadFull(r.R, body)
+		require.NoError(t, err, "Captured frames should be complete")
+		if header.Get("Content-Encoding") != string(core.CompressionGzip) {
+			continue
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err, "Compressed bodies should be gzip")
+		original, err := io.ReadAll(zr)
+		require.NoError(t, err, "Compressed bodies should decompress")
+		stats.Messages++
+		stats.OriginalBytes += uint64(len(original))
+		stats.CompressedBytes += uint64(length)
+	}
+}
+
+func TestCompressionTracker(t *testing.T) {
+	options := DefaultOptions()
+	WithAdaptiveCompression(0.95, 3, time.Minute)(&options)
+	tracker := newCompressionTracker(options)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		require.True(t, tracker.compress("text", now), "A method should start compressed")
+		tracker.record("text", 1000, 100, time.Millisecond, now)
+	}
+	for i := 0; i < 2; i++ {
+		require.True(t, tracker.compress("blob", now), "A method should start compressed")
+		tracker.record("blob", 1000, 1010, time.Millisecond, now)
+	}
+	tracker.record("blob", 1000, 500, time.Millisecond, now)
+	_, methods := tracker.snapshot()
+	assert.False(t, methods["text"].Disabled, "A method that shrinks should stay compressed")
+	assert.InDelta(t, 0.1, methods["text"].RollingRatio, 0.001, "The rolling ratio should average the last messages")
+	assert.False(t, methods["blob"].Disabled, "A window averaging under the limit should keep the method compressed")
+
+	for i := 0; i < 3; i++ {
+		tracker.record("blob", 1000, 1010, time.Millisecond, now)
+	}
+	total, methods := tracker.snapshot()
+	assert.True(t, methods["blob"].Disabled, "A window averaging over the limit should disable the method")
+	assert.InDelta(t, 1.01, methods["blob"].RollingRatio, 0.001, "The ratio that disabled the method should be reported")
+	assert.Equal(t, now.Add(time.Minute), methods["blob"].Reprobe, "The next probe should be reported")
+	assert.False(t, tracker.compress("blob", now.Add(time.Second)), "A disabled method should be sent uncompressed")
+	assert.True(t, tracker.compress("text", now.Add(time.Second)), "Other methods should stay compressed")
+	assert.Equal(t, CompressionStats{Messages: 9, OriginalBytes: 9000, CompressedBytes: 5850, CPUTime: 9 * time.Millisecond}, total,
+		"Totals should add up every method")
+
+	// The probe finds the payloads compressible again
+	later := now.Add(time.Minute)
+	require.True(t, tracker.compress("blob", later), "A disabled method should be compressed again once its reprobe time comes")
+	for i := 0; i < 3; i++ {
+		tracker.record("blob", 1000, 100, time.Millisecond, later)
+		require.True(t, tracker.compress("blob", later), "A method shrinking again should stay compressed")
+	}
+	_, methods = tracker.snapshot()
+	assert.False(t, methods["blob"].Disabled, "The probe should enable the method again")
+	assert.Equal(t, uint64(1), methods["blob"].Skipped, "Messages sent uncompressed should be counted")
+
+	static := newCompressionTracker(DefaultOptions())
+	for i := 0; i < 10; i++ {
+		static.record("blob", 1000, 1010, time.Millisecond, now)
+	}
+	assert.True(t, static.compress("blob", now), "Without adaptive compression every method should stay compressed")
+}
+
+func TestAdaptiveCompression(t *testing.T) {
+	const reprobe = 500 * time.Millisecond
+	capture := &capturingTransport{}
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			capture.Transport = transport
+			return client.New(client.WithTransport(capture), client.WithLogger(core.NopLogger()), client.WithAutoReconnect(false),
+				client.WithCompression(core.CompressionGzip), client.WithCodec(msgpack.Codec))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()),
+				WithCompression(core.CompressionGzip), WithCodec(msgpack.Codec), WithAdaptiveCompression(0.95, 4, reprobe))
+			require.NoError(t, srv.RegisterHandler(&EchoModelHandler{}), "Handler registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	random := make([]byte, 64<<10)
+	_, err := rand.Read(random)
+	require.NoError(t, err, "Generating the payload should succeed")
+	incompressible := core.Binary(random)
+	compressible := strings.Repeat(`{"name":"compressible","values":[1,2,3]}`, 1<<11)
+	echo := func(payload interface{}, times int) {
+		for i := 0; i < times; i++ {
+			req := core.NewModelRequest()
+			req.ModelData["payload"] = payload
+			_, err := c.ProcessModel(ctx, req)
+			require.NoError(t, err, "ProcessModel should succeed")
+		}
+	}
+	decisions := func() map[string]MethodCompression {
+		rec := httptest.NewRecorder()
+		srv.serveCompression(rec, httptest.NewRequest("GET", "/compression", nil))
+		var methods map[string]MethodCompression
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &methods), "The admin endpoint should serve JSON")
+		return methods
+	}
+
+	echo(compressible, 4)
+	assert.False(t, decisions()[core.MethodProcessModel].Disabled, "Compressible replies should stay compressed")
+	echo(incompressible, 4)
+	processModel := decisions()[core.MethodProcessModel]
+	assert.True(t, processModel.Disabled, "A window of incompressible replies should disable compression")
+	assert.Greater(t, processModel.RollingRatio, 0.0, "The ratio that disabled the method should be reported")
+	echo(incompressible, 2)
+	assert.Equal(t, uint64(8), srv.CompressionByMethod()[core.MethodProcessModel].Messages, "Replies of a disabled method should not be compressed")
+	assert.Equal(t, uint64(2), srv.CompressionByMethod()[core.MethodProcessModel].Skipped, "Replies of a disabled method should be counted as skipped")
+
+	// Other methods are decided on their own
+	items := make([]*core.ModelRequest, 4)
+	for i := range items {
+		items[i] = core.NewModelRequest()
+		items[i].ModelData["payload"] = compressible
+	}
+	_, err = c.ProcessModelBatch(ctx, items)
+	require.NoError(t, err, "ProcessModelBatch should succeed")
+	assert.Equal(t, uint64(1), srv.CompressionByMethod()[core.MethodProcessModelBatch].Messages, "Other methods should stay compressed")
+
+	time.Sleep(reprobe)
+	echo(compressible, 4)
+	processModel = decisions()[core.MethodProcessModel]
+	assert.False(t, processModel.Disabled, "Compressible replies after the reprobe should enable compression again")
+	assert.Equal(t, uint64(12), processModel.Messages, "Replies should be compressed again after the reprobe")
+
+	// The accounting matches what reached the client, which has read every
+	// reply by the time its call returns
+	stats := srv.Stats()
+	wire := wireCompression(t, capture.captured())
+	assert.Equal(t, wire.Messages, stats.Compression.Messages, "Every compressed frame should be counted")
+	assert.Equal(t, wire.CompressedBytes, stats.Compression.CompressedBytes, "Compressed bytes should match the frames on the wire")
+	assert.Equal(t, wire.OriginalBytes, stats.Compression.OriginalBytes, "Original bytes should match the frames decompressed")
+	assert.Positive(t, stats.Compression.CPUTime, "The time spent compressing should be counted")
+	assert.Equal(t, stats.CompressionMethods, srv.CompressionByMethod(), "Stats should report the decisions too")
+	require.Len(t, srv.Connections(), 1, "Server should list the connection")
+	assert.Equal(t, stats.Compression, srv.Connections()[0].Compressed, "The only connection should account for every message")
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// fileConfig is the server configuration read by OptionsFromFile and
+// OptionsFromEnv. Fields are nil unless set.
+type fileConfig struct {
+	Host                  *string        `config:"host" env:"MCP_SERVER_HOST"`
+	Port                  *int           `config:"port" env:"MCP_SERVER_PORT"`
+	ListenAddrs           *[]string      `config:"listenAddrs" env:"MCP_LISTEN_ADDRS"`
+	TLSCert               *string        `config:"tlsCert" env:"MCP_TLS_CERT"`
+	TLSKey                *string        `config:"tlsKey" env:"MCP_TLS_KEY"`
+	MaxConcurrentClients  *int           `config:"maxConcurrentClients" env:"MCP_MAX_CONCURRENT_CLIENTS"`
+	MaxConcurrentRequests *int           `config:"maxConcurrentRequests" env:"MCP_MAX_CONCURRENT_REQUESTS"`
+	RequestQueueSize      *int           `config:"requestQueueSize" env:"MCP_REQUEST_QUEUE_SIZE"`
+	MaxRequestBytes       *int64         `config:"maxRequestBytes" env:"MCP_MAX_REQUEST_BYTES"`
+	ConnectionTimeout     *time.Duration `config:"connectionTimeout" env:"MCP_CONNECTION_TIMEOUT"`
+	IdleTimeout           *time.Duration `config:"idleTimeout" env:"MCP_IDLE_TIMEOUT"`
+	SessionTTL            *time.Duration `config:"sessionTTL" env:"MCP_SESSION_TTL"`
+	JobRetention          *time.Duration `config:"jobRetention" env:"MCP_JOB_RETENTION"`
+	MaxConcurrentJobs     *int           `config:"maxConcurrentJobs" env:"MCP_MAX_CONCURRENT_JOBS"`
+	MetricsAddr           *string        `config:"metricsAddr" env:"MCP_METRICS_ADDR"`
+	HealthAddr            *string        `config:"healthAddr" env:"MCP_HEALTH_ADDR"`
+	JournalDir            *string        `config:"journalDir" env:"MCP_JOURNAL_DIR"`
+}
+
+// OptionsFromFile reads server options from the YAML or JSON file at path,
+// named by their extension. Keys mirror the Options they set: host, port,
+// listenAddrs, tlsCert and tlsKey, maxConcurrentClients,
+// maxConcurrentRequests, requestQueueSize, maxRequestBytes,
+// connectionTimeout, idleTimeout, sessionTTL, jobRetention,
+// maxConcurrentJobs, metricsAddr, healthAddr and journalDir. Durations are
+// strings such as "30s". An unknown key fails, naming the closest known one.
+//
+// Options passed to New apply in order, so explicit options placed after
+// those read override them:
+//
+//	fileOpts, err := server.OptionsFromFile("mcp.yaml")
+//	envOpts, err := server.OptionsFromEnv()
+//	srv := server.New(append(append(fileOpts, envOpts...), server.WithLogger(logger))...)
+func OptionsFromFile(path string) ([]Option, error) {
+	var cfg fileConfig
+	if err := core.LoadConfigFile(path, &cfg); err != nil {
+		return nil, err
+	}
+	options, err := cfg.options()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return options, nil
+}
+
+// OptionsFromEnv reads server options from the environment: MCP_SERVER_HOST,
+// MCP_SERVER_PORT, MCP_LISTEN_ADDRS (comma separated), MCP_TLS_CERT and
+// MCP_TLS_KEY, MCP_MAX_CONCURRENT_CLIENTS, MCP_MAX_CONCURRENT_REQUESTS,
+// MCP_REQUEST_QUEUE_SIZE, MCP_MAX_REQUEST_BYTES, MCP_CONNECTION_TIMEOUT,
+// MCP_IDLE_TIMEOUT, MCP_SESSION_TTL, MCP_JOB_RETENTION,
+// MCP_MAX_CONCURRENT_JOBS, MCP_METRICS_ADDR, MCP_HEALTH_ADDR and
+// MCP_JOURNAL_DIR, set as OptionsFromFile does. Variables that are not set
+// leave their options alone.
+func OptionsFromEnv() ([]Option, error) {
+	var cfg fileConfig
+	if err := core.LoadConfigEnv(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.options()
+}
+
+// options returns the options setting what the configuration sets, failing
+// for values the options would not accept.
+func (c *fileConfig) options() ([]Option, error) {
+	for name, n := range map[string]*int{
+		"max concurrent requests": c.MaxConcurrentRequests,
+		"request queue size":      c.RequestQueueSize,
+		"max concurrent jobs":     c.MaxConcurrentJobs,
+	} {
+		if n != nil && *n < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %d", name, *n)
+		}
+	}
+	for name, d := range map[string]*time.Duration{
+		"connection timeout": c.ConnectionTimeout,
+		"idle timeout":       c.IdleTimeout,
+		"session TTL":        c.SessionTTL,
+		"job retention":      c.JobRetention,
+	} {
+		if d != nil && *d < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %s", name, *d)
+		}
+	}
+	if c.MaxRequestBytes != nil && *c.MaxRequestBytes < 0 {
+		return nil, fmt.Errorf("max request bytes must not be negative, got %d", *c.MaxRequestBytes)
+	}
+
+	var options []Option
+	if c.Host != nil {
+		options = append(options, WithHost(*c.Host))
+	}
+	if c.Port != nil {
+		if *c.Port < 0 || *c.Port > 65535 {
+			return nil, fmt.Errorf("port must be between 0 and 65535, got %d", *c.Port)
+		}
+		options = append(options, WithPort(*c.Port))
+	}
+	if c.ListenAddrs != nil {
+		options = append(options, WithListenAddrs(*c.ListenAddrs...))
+	}
+	if (c.TLSCert == nil) != (c.TLSKey == nil) {
+		return nil, errors.New("the TLS certificate and key must be set together")
+	}
+	if c.TLSCert != nil {
+		options = append(options, WithTLSFiles(*c.TLSCert, *c.TLSKey))
+	}
+	if c.MaxConcurrentClients != nil {
+		if *c.MaxConcurrentClients < 1 {
+			return nil, fmt.Errorf("max concurrent clients must be positive, got %d", *c.MaxConcurrentClients)
+		}
+		options = append(options, WithMaxConcurrentClients(*c.MaxConcurrentClients))
+	}
+	if c.MaxConcurrentRequests != nil {
+		options = append(options, WithMaxConcurrentRequests(*c.MaxConcurrentRequests))
+	}
+	if c.RequestQueueSize != nil {
+		options = append(options, WithRequestQueueSize(*c.RequestQueueSize))
+	}
+	if c.MaxRequestBytes != nil {
+		options = append(options, WithMaxRequestBytes(*c.MaxRequestBytes))
+	}
+	if c.ConnectionTimeout != nil {
+		options = append(options, WithConnectionTimeout(*c.ConnectionTimeout))
+	}
+	if c.IdleTimeout != nil {
+		options = append(options, WithIdleTimeout(*c.IdleTimeout))
+	}
+	if c.SessionTTL != nil {
+		options = append(options, WithSessionTTL(*c.SessionTTL))
+	}
+	if c.JobRetention != nil {
+		options = append(options, WithJobRetention(*c.JobRetention))
+	}
+	if c.MaxConcurrentJobs != nil {
+		options = append(options, WithMaxConcurrentJobs(*c.MaxConcurrentJobs))
+	}
+	if c.MetricsAddr != nil {
+		options = append(options, WithMetricsAddr(*c.MetricsAddr))
+	}
+	if c.HealthAddr != nil {
+		options = append(options, WithHealthAddr(*c.HealthAddr))
+	}
+	if c.JournalDir != nil {
+		options = append(options, WithJournal(*c.JournalDir))
+	}
+	return options, nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFromFileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`host: 0.0.0.0
+port: 6000
+maxConcurrentClients: 50
+connectionTimeout: 10s
+listenAddrs:
+  - 127.0.0.1:6001
+`), 0o600), "Writing the configuration should succeed")
+	t.Setenv("MCP_SERVER_PORT", "7000")
+	t.Setenv("MCP_IDLE_TIMEOUT", "2m")
+
+	fileOpts, err := OptionsFromFile(path)
+	require.NoError(t, err, "Reading the file should succeed")
+	envOpts, err := OptionsFromEnv()
+	require.NoError(t, err, "Reading the environment should succeed")
+
+	options := DefaultOptions()
+	for _, opt := range append(append(fileOpts, envOpts...), WithMaxConcurrentClients(5)) {
+		opt(&options)
+	}
+
+	assert.Equal(t, "0.0.0.0", options.Host, "Host should come from the file")
+	assert.Equal(t, 7000, options.Port, "The environment should override the file")
+	assert.Equal(t, 5, options.MaxConcurrentClients, "An explicit option should override the file")
+	assert.Equal(t, 10*time.Second, options.ConnectionTimeout, "ConnectionTimeout should come from the file")
+	assert.Equal(t, 2*time.Minute, options.IdleTimeout, "IdleTimeout should come from the environment")
+	assert.Equal(t, []string{"127.0.0.1:6001"}, options.ListenAddrs, "ListenAddrs should come from the file")
+	assert.Equal(t, DefaultOptions().SessionTTL, options.SessionTTL, "Options left out should keep their defaults")
+}
+
+func TestOptionsFromFileTLS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tlsCert": "cert.pem", "tlsKey": "key.pem"}`), 0o600), "Writing the configuration should succeed")
+
+	opts, err := OptionsFromFile(path)
+	require.NoError(t, err, "Reading the file should succeed")
+	options := DefaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	assert.True(t, options.EnableTLS, "TLS should be enabled")
+	assert.Equal(t, "cert.pem", options.CertificatePath, "CertificatePath should come from the file")
+	assert.Equal(t, "key.pem", options.CertificateKeyPath, "CertificateKeyPath should come from the file")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"tlsCert": "cert.pem"}`), 0o600), "Writing the configuration should succeed")
+	_, err = OptionsFromFile(path)
+	require.Error(t, err, "A certificate without a key should fail")
+	assert.Contains(t, err.Error(), "must be set together", "The error should say what is missing")
+}
+
+func TestOptionsFromFileErrors(t *testing.T) {
+	for content, want := range map[string]string{
+		"prot: 6000\n":                `unknown key "prot", did you mean "port"?`,
+		"maxConcurentClients: 5\n":    `did you mean "maxConcurrentClients"?`,
+		"port: 70000\n":               "port must be between 0 and 65535",
+		"port: http\n":                "invalid port",
+		"maxConcurrentClients: 0\n":   "max concurrent clients must be positive",
+		"idleTimeout: -1s\n":          "idle timeout must not be negative",
+		"connectionTimeout: 30\n":     "invalid connectionTimeout",
+		"maxConcurrentRequests: -1\n": "max concurrent requests must not be negative",
+	} {
+		path := filepath.Join(t.TempDir(), "mcp.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600), "Writing the configuration should succeed")
+
+		_, err := OptionsFromFile(path)
+		require.Error(t, err, "Reading %q should fail", content)
+		assert.Contains(t, err.Error(), want, "The error for %q should say what is wrong", content)
+		assert.Contains(t, err.Error(), path, "The error for %q should name the file", content)
+	}
+}
+
+func TestOptionsFromEnvErrors(t *testing.T) {
+	t.Setenv("MCP_SERVER_PORT", "http")
+	_, err := OptionsFromEnv()
+	require.Error(t, err, "An invalid port should fail")
+	assert.Contains(t, err.Error(), "invalid MCP_SERVER_PORT", "The error should name the variable")
+}
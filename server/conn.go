@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// deadlineConn wraps a net.Conn and pushes its deadlines forward before every
+// read and write. A peer that sends nothing for the read timeout, or takes
+// nothing written to it for the write timeout, gets disconnected, while a
+// quiet but healthy one, such as a client sending heartbeats, stays connected.
+// Zero timeouts leave that direction without a deadline.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// Read implements io.Reader, refreshing the read deadline first.
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if c.readTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(p)
+}
+
+// Write implements io.Writer, refreshing the write deadline first. A write
+// that times out may have sent part of a frame, so the connection is closed
+// rather than left for the next write to garble.
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.writeTimeout <= 0 {
+		return c.Conn.Write(p)
+	}
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		c.Conn.Close()
+	}
+	return n, err
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// DurableStats describes the durable subscriptions in Stats.
+type DurableStats struct {
+	Subscriptions int    `json:"subscriptions"` // Durable subscriptions, held or detached
+	Detached      int    `json:"detached"`      // Durable subscriptions no connection holds
+	Buffered      int    `json:"buffered"`      // Notifications waiting in the buffers of detached subscriptions
+	BufferedBytes int    `json:"bufferedBytes"` // Size of the buffered payloads
+	Dropped       uint64 `json:"dropped"`       // Notifications dropped from full buffers
+	Expired       uint64 `json:"expired"`       // Detached subscriptions discarded after DurableTTL
+}
+
+// errDurableTopic is returned by attach for a subscription ID in use for
+// another topic.
+var errDurableTopic = errors.New("subscription is for another topic")
+
+// durableSubscriptions holds the durable subscriptions by ID.
+type durableSubscriptions struct {
+	maxCount int
+	maxBytes int
+	ttl      time.Duration
+
+	mu      sync.Mutex // Taken before any durableSubscription.mu
+	subs    map[string]*durableSubscription
+	dropped uint64 // Accessed atomically
+	expired uint64
+}
+
+// durableSubscription is a subscription the server keeps across connections.
+type durableSubscription struct {
+	id    string
+	topic string
+
+	// Held while notifications are sent to the holder, so a replay is over
+	// before live notifications are sent
+	mu       sync.Mutex
+	filter   *core.Filter
+	holder   *rpcHandler // Connection receiving the notifications; nil while detached
+	conn     rpcConn
+	detached time.Time
+	buffer   []bufferedNotification // Oldest first
+	bytes    int
+}
+
+// bufferedNotification is a notification published while its durable
+// subscription was detached.
+type bufferedNotification struct {
+	method string
+	params json.RawMessage
+}
+
+func newDurableSubscriptions(maxCount, maxBytes int, ttl time.Duration) *durableSubscriptions {
+	return &durableSubscriptions{maxCount: maxCount, maxBytes: maxBytes, ttl: ttl, subs: make(map[string]*durableSubscription)}
+}
+
+// attach gives the durable subscription named by req to h, creating it if it
+// does not exist, and replays on conn what was buffered while it was detached.
+// It returns an error if the subscription exists for another topic, or if
+// the replay could not be sent, in which case the rest stays buffered.
+func (d *durableSubscriptions) attach(ctx context.Context, h *rpcHandler, conn rpcConn, req core.SubscribeRequest, filter *core.Filter) error {
+	d.mu.Lock()
+	d.expireLocked(time.Now())
+	sub, ok := d.subs[req.SubscriptionID]
+	if !ok {
+		sub = &durableSubscription{id: req.SubscriptionID, topic: req.Topic}
+		d.subs[sub.id] = sub
+	}
+	if sub.topic != req.Topic {
+		d.mu.Unlock()
+		return fmt.Errorf("%w: %s is for topic %s", errDurableTopic, sub.id, sub.topic)
+	}
+	sub.mu.Lock()
+	d.mu.Unlock()
+	defer sub.mu.Unlock()
+
+	// A connection taking over the subscription ends the previous one's
+	if sub.holder != nil && sub.holder != h {
+		sub.holder.subscriptions.remove(sub.topic)
+	}
+	sub.filter, sub.holder, sub.conn = filter, h, conn
+
+	buffered := sub.buffer
+	sub.buffer, sub.bytes = nil, 0
+	meta := jsonrpc2.Meta(core.NotificationMeta{Replayed: true, SubscriptionID: sub.id})
+	for i, n := range buffered {
+		params := n.params
+		if err := conn.Notify(ctx, n.method, &params, meta); err != nil {
+			// Keep what was not delivered for the next connection
+			sub.holder, sub.conn, sub.detached = nil, nil, time.Now()
+			sub.buffer = buffered[i:]
+			for _, rest := range sub.buffer {
+				sub.bytes += len(rest.params)
+			}
+			return fmt.Errorf("replaying subscription %s: %w", sub.id, err)
+		}
+	}
+	return nil
+}
+
+// detach releases the durable subscriptions h holds, which buffer
+// notifications from now on.
+func (d *durableSubscriptions) detach(h *rpcHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for _, sub := range d.subs {
+		sub.mu.Lock()
+		if sub.holder == h {
+			sub.holder, sub.conn, sub.detached = nil, nil, now
+		}
+		sub.mu.Unlock()
+	}
+}
+
+// remove ends the durable subscription id, discarding its buffer.
+func (d *durableSubscriptions) remove(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.subs, id)
+}
+
+// publish sends a notification to the holders of the durable subscriptions to
+// topic, or buffers it for those that are detached. decode returns the payload
+// in the form filters match against.
+func (d *durableSubscriptions) publish(ctx context.Context, topic, method string, payload interface{}, decode func() (interface{}, error)) error {
+	d.mu.Lock()
+	d.expireLocked(time.Now())
+	var subs []*durableSubscription
+	for _, sub := range d.subs {
+		if sub.topic == topic {
+			subs = append(subs, sub)
+		}
+	}
+	d.mu.Unlock()
+
+	var params json.RawMessage
+	var errs []error
+	for _, sub := range subs {
+		sub.mu.Lock()
+		if sub.filter != nil {
+			decoded, err := decode()
+			if err != nil || !sub.filter.Match(decoded) {
+				sub.mu.Unlock()
+				continue
+			}
+		}
+		if sub.conn != nil {
+			if err := sub.conn.Notify(ctx, method, payload); err != nil {
+				errs = append(errs, err)
+			}
+			sub.mu.Unlock()
+			continue
+		}
+		if params == nil {
+			var err error
+			if params, err = json.Marshal(payload); err != nil {
+				sub.mu.Unlock()
+				return err
+			}
+		}
+		sub.buffer = append(sub.buffer, bufferedNotification{method: method, params: params})
+		sub.bytes += len(params)
+		for len(sub.buffer) > 0 && (len(sub.buffer) > d.maxCount || sub.bytes > d.maxBytes) {
+			sub.bytes -= len(sub.buffer[0].params)
+			sub.buffer = sub.buffer[1:]
+			atomic.AddUint64(&d.dropped, 1)
+		}
+		sub.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// expireLocked discards the subscriptions detached for longer than the TTL.
+// The caller holds d.mu.
+func (d *durableSubscriptions) expireLocked(now time.Time) {
+	for id, sub := range d.subs {
+		sub.mu.Lock()
+		expired := sub.holder == nil && now.Sub(sub.detached) > d.ttl
+		sub.mu.Unlock()
+		if expired {
+			delete(d.subs, id)
+			d.expired++
+		}
+	}
+}
+
+// stats returns a snapshot of the durable subscriptions for Stats.
+func (d *durableSubscriptions) stats() DurableStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expireLocked(time.Now())
+	stats := DurableStats{Subscriptions: len(d.subs), Dropped: atomic.LoadUint64(&d.dropped), Expired: d.expired}
+	for _, sub := range d.subs {
+		sub.mu.Lock()
+		if sub.holder == nil {
+			stats.Detached++
+			stats.Buffered += len(sub.buffer)
+			stats.BufferedBytes += sub.bytes
+		}
+		sub.mu.Unlock()
+	}
+	return stats
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deliveredEvent is a job event a durable subscriber received
+type deliveredEvent struct {
+	seq      int
+	replayed bool
+}
+
+// startDurableServer starts a server with the given options and returns it with its transport
+func startDurableServer(t *testing.T, options ...Option) (*Server, core.Transport) {
+	transport := core.NewInProcessTransport()
+	srv := New(append([]Option{WithTransport(transport), WithLogger(core.NopLogger())}, options...)...)
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+	return srv, transport
+}
+
+// durableSubscriber connects a client holding the durable subscription id to
+// "jobs" and returns it with the events it receives
+func durableSubscriber(t *testing.T, transport core.Transport, id string) (*client.Client, <-chan deliveredEvent) {
+	c := startWatchClient(t, transport)
+	events := make(chan deliveredEvent, 16)
+	client.OnNotificationTyped(c, jobEventNotification, func(ctx context.Context, e jobEvent) {
+		events <- deliveredEvent{seq: e.Seq, replayed: core.NotificationMetaFromContext(ctx).Replayed}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, c.Subscribe(ctx, "jobs", client.WithSubscribeDurable(id)), "Durable subscription should succeed")
+	return c, events
+}
+
+// detach disconnects c and waits for the server to release its durable subscriptions
+func detach(t *testing.T, srv *Server, c *client.Client, detached int) {
+	require.NoError(t, c.Stop(), "Client should stop")
+	require.Eventually(t, func() bool { return srv.Stats().Durable.Detached == detached }, 2*time.Second, 10*time.Millisecond,
+		"Server should notice the subscriber leaving")
+}
+
+func publishJobs(t *testing.T, srv *Server, seqs ...int) {
+	for _, seq := range seqs {
+		require.NoError(t, PublishTopic(srv, "jobs", jobEventNotification, jobEvent{Seq: seq, Status: "done"}), "Publishing should succeed")
+	}
+}
+
+func TestDurableSubscriptionReplay(t *testing.T) {
+	srv, transport := startDurableServer(t)
+	first, events := durableSubscriber(t, transport, "reporter")
+
+	publishJobs(t, srv, 1)
+	assert.Equal(t, deliveredEvent{seq: 1}, receive(t, events, "live notification"), "Attached subscriber should receive live notifications")
+
+	detach(t, srv, first, 1)
+	publishJobs(t, srv, 2, 3, 4)
+	stats := srv.Stats().Durable
+	assert.Equal(t, 1, stats.Subscriptions, "Stats should count the durable subscription")
+	assert.Equal(t, 3, stats.Buffered, "Missed notifications should be buffered")
+	assert.Positive(t, stats.BufferedBytes, "Stats should report the buffered bytes")
+
+	// The same subscription ID resumes the subscription from another client
+	_, resumed := durableSubscriber(t, transport, "reporter")
+	publishJobs(t, srv, 5)
+	// The client runs handlers concurrently; TestDurableSubscriptionReplayOrder checks the order
+	var got []deliveredEvent
+	for i := 0; i < 4; i++ {
+		got = append(got, receive(t, resumed, "durable notification"))
+	}
+	assert.ElementsMatch(t, []deliveredEvent{{2, true}, {3, true}, {4, true}, {5, false}}, got,
+		"Missed notifications should be replayed, marked as such, along with live ones")
+	assert.Equal(t, DurableStats{Subscriptions: 1}, srv.Stats().Durable, "Replayed buffer should be emptied")
+}
+
+func TestDurableSubscriptionReplayOrder(t *testing.T) {
+	srv, transport := startDurableServer(t)
+	first, _ := durableSubscriber(t, transport, "reporter")
+	detach(t, srv, first, 1)
+	publishJobs(t, srv, 1, 2, 3)
+
+	// A raw connection sees notifications in the order they were sent
+	received := make(chan *jsonrpc2.Request, 8)
+	netConn, err := transport.Dial(context.Background(), "")
+	require.NoError(t, err, "Dial should succeed")
+	conn := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+			received <- req
+			return nil, nil
+		}))
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, conn.Call(ctx, core.MethodSubscribe, core.SubscribeRequest{Topic: "jobs", Durable: true, SubscriptionID: "reporter"}, &struct{}{}),
+		"Resuming the subscription should succeed")
+	publishJobs(t, srv, 4)
+
+	for _, want := range []deliveredEvent{{1, true}, {2, true}, {3, true}, {4, false}} {
+		req := receive(t, received, "durable notification")
+		var e jobEvent
+		require.NoError(t, json.Unmarshal(*req.Params, &e), "Payload should decode")
+		var meta core.NotificationMeta
+		if req.Meta != nil {
+			require.NoError(t, json.Unmarshal(*req.Meta, &meta), "Meta should decode")
+		}
+		assert.Equal(t, want, deliveredEvent{seq: e.Seq, replayed: meta.Replayed}, "Missed notifications should be replayed in order before live ones")
+		if meta.Replayed {
+			assert.Equal(t, "reporter", meta.SubscriptionID, "Replayed notifications should name their subscription")
+		}
+	}
+}
+
+func TestDurableSubscriptionOverflow(t *testing.T) {
+	srv, transport := startDurableServer(t, WithDurableBuffer(3, 1<<20))
+	first, _ := durableSubscriber(t, transport, "reporter")
+
+	detach(t, srv, first, 1)
+	publishJobs(t, srv, 1, 2, 3, 4, 5)
+	stats := srv.Stats().Durable
+	assert.Equal(t, 3, stats.Buffered, "Buffer should hold at most its count")
+	assert.Equal(t, uint64(2), stats.Dropped, "Dropped notifications should be counted")
+
+	_, resumed := durableSubscriber(t, transport, "reporter")
+	var got []deliveredEvent
+	for i := 0; i < 3; i++ {
+		got = append(got, receive(t, resumed, "replayed notification"))
+	}
+	assert.ElementsMatch(t, []deliveredEvent{{3, true}, {4, true}, {5, true}}, got, "Oldest notifications should be dropped")
+}
+
+func TestDurableSubscriptionByteCap(t *testing.T) {
+	size := len(`{"seq":1,"status":"done","tenant":""}`)
+	srv, transport := startDurableServer(t, WithDurableBuffer(100, 2*size))
+	first, _ := durableSubscriber(t, transport, "reporter")
+
+	detach(t, srv, first, 1)
+	publishJobs(t, srv, 1, 2, 3)
+	stats := srv.Stats().Durable
+	assert.Equal(t, 2, stats.Buffered, "Buffer should hold at most its bytes")
+	assert.Equal(t, 2*size, stats.BufferedBytes, "Buffered bytes should stay within the cap")
+	assert.Equal(t, uint64(1), stats.Dropped, "Dropped notifications should be counted")
+}
+
+func TestDurableSubscriptionExpiry(t *testing.T) {
+	srv, transport := startDurableServer(t, WithDurableTTL(50*time.Millisecond))
+	first, _ := durableSubscriber(t, transport, "reporter")
+
+	detach(t, srv, first, 1)
+	publishJobs(t, srv, 1)
+	time.Sleep(100 * time.Millisecond)
+	stats := srv.Stats().Durable
+	assert.Equal(t, 0, stats.Subscriptions, "Expired subscriptions should be discarded")
+	assert.Equal(t, uint64(1), stats.Expired, "Expired subscriptions should be counted")
+
+	_, resumed := durableSubscriber(t, transport, "reporter")
+	publishJobs(t, srv, 2)
+	assert.Equal(t, deliveredEvent{seq: 2}, receive(t, resumed, "live notification"), "Expired buffer should not be replayed")
+}
+
+func TestDurableSubscriptionValidation(t *testing.T) {
+	srv, transport := startDurableServer(t)
+	durableSubscriber(t, transport, "reporter")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn := dialRaw(t, transport)
+	err := conn.Call(ctx, core.MethodSubscribe, core.SubscribeRequest{Topic: "jobs", Durable: true}, &struct{}{})
+	assert.ErrorContains(t, err, "durable subscriptions need a subscription ID", "Durable subscriptions should need an ID")
+	err = conn.Call(ctx, core.MethodSubscribe, core.SubscribeRequest{Topic: "other", Durable: true, SubscriptionID: "reporter"}, &struct{}{})
+	assert.ErrorContains(t, err, "reporter is for topic jobs", "Subscription IDs should keep their topic")
+
+	// Unsubscribing discards the durable subscription
+	c, _ := durableSubscriber(t, transport, "leaving")
+	require.Equal(t, 2, srv.Stats().Durable.Subscriptions, "Stats should count both subscriptions")
+	require.NoError(t, c.Unsubscribe(ctx, "jobs"), "Unsubscribing should succeed")
+	assert.Equal(t, 1, srv.Stats().Durable.Subscriptions, "Unsubscribed durable subscription should be discarded")
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// FileBlobStore is a BlobStore keeping each blob in a file under a directory,
+// named by its ID, with its metadata in a JSON file beside it. Blobs being
+// uploaded are written to temporary files, renamed into place once
+// committed.
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore creates a FileBlobStore keeping blobs in dir, creating the
+// directory if needed.
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileBlobStore{dir: root}, nil
+}
+
+// Create writes the blob id to a temporary file until it is committed.
+func (f *FileBlobStore) Create(ctx context.Context, id core.BlobID, meta core.BlobMeta) (BlobWriter, error) {
+	if !id.Valid() {
+		return nil, fmt.Errorf("invalid blob ID %q", id)
+	}
+	file, err := os.CreateTemp(f.dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &fileBlobWriter{store: f, id: id, file: file}, nil
+}
+
+// Open opens the file of the blob id.
+func (f *FileBlobStore) Open(ctx context.Context, id core.BlobID) (io.ReadSeekCloser, core.BlobMeta, error) {
+	meta, err := f.Stat(ctx, id)
+	if err != nil {
+		return nil, core.BlobMeta{}, err
+	}
+	file, err := os.Open(f.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, core.BlobMeta{}, fmt.Errorf("%w: %s", core.ErrBlobNotFound, id)
+	}
+	if err != nil {
+		return nil, core.BlobMeta{}, err
+	}
+	return file, meta, nil
+}
+
+// Stat reads the metadata of the blob id.
+func (f *FileBlobStore) Stat(ctx context.Context, id core.BlobID) (core.BlobMeta, error) {
+	if !id.Valid() {
+		return core.BlobMeta{}, fmt.Errorf("%w: %s", core.ErrBlobNotFound, id)
+	}
+	data, err := os.ReadFile(f.metaPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return core.BlobMeta{}, fmt.Errorf("%w: %s", core.ErrBlobNotFound, id)
+	}
+	if err != nil {
+		return core.BlobMeta{}, err
+	}
+	var meta core.BlobMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return core.BlobMeta{}, fmt.Errorf("invalid metadata of blob %s: %w", id, err)
+	}
+	return meta, nil
+}
+
+// Delete removes the files of the blob id.
+func (f *FileBlobStore) Delete(ctx context.Context, id core.BlobID) error {
+	if !id.Valid() {
+		return fmt.Errorf("%w: %s", core.ErrBlobNotFound, id)
+	}
+	// Without its metadata the blob is no longer found, whatever is left
+	if err := os.Remove(f.metaPath(id)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", core.ErrBlobNotFound, id)
+	} else if err != nil {
+		return err
+	}
+	if err := os.Remove(f.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns the path of the content of the blob id.
+func (f *FileBlobStore) path(id core.BlobID) string {
+	return filepath.Join(f.dir, string(id))
+}
+
+// metaPath returns the path of the metadata of the blob id.
+func (f *FileBlobStore) metaPath(id core.BlobID) string {
+	return filepath.Join(f.dir, string(id)+".json")
+}
+
+// fileBlobWriter writes a blob to a temporary file.
+type fileBlobWriter struct {
+	store *FileBlobStore
+	id    core.BlobID
+	file  *os.File
+}
+
+func (w *fileBlobWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+// Commit moves the file into place and writes the blob's metadata, which
+// makes it visible.
+func (w *fileBlobWriter) Commit(meta core.BlobMeta) error {
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	if err := os.Rename(w.file.Name(), w.store.path(w.id)); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	tmp := w.store.metaPath(w.id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.store.metaPath(w.id))
+}
+
+// Abort removes the temporary file.
+func (w *fileBlobWriter) Abort() error {
+	w.file.Close()
+	return os.Remove(w.file.Name())
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// FileResources is a ResourceHandler serving the regular files under a
+// directory as file:// resources, named by their path relative to it.
+// Symbolic links are not followed. It is a ResourceWatcher, noticing files
+// that are created, modified or removed by polling their size and
+// modification time.
+type FileResources struct {
+	root     string
+	interval time.Duration
+}
+
+// NewFileResources creates a FileResources serving the files under dir,
+// polling for changes every pollInterval.
+func NewFileResources(dir string, pollInterval time.Duration) (*FileResources, error) {
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive, got %s", pollInterval)
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &FileResources{root: root, interval: pollInterval}, nil
+}
+
+// URI returns the URI of the file at path, relative to the directory served.
+func (f *FileResources) URI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(f.root, path))}).String()
+}
+
+// ListResources lists every regular file under the directory.
+func (f *FileResources) ListResources(ctx context.Context) ([]core.Resource, error) {
+	var resources []core.Resource
+	err := f.walk(func(path string, _ fs.FileInfo) {
+		rel, _ := filepath.Rel(f.root, path)
+		resources = append(resources, core.Resource{
+			URI:      f.URI(rel),
+			Name:     filepath.ToSlash(rel),
+			MimeType: fileMimeType(path),
+		})
+	})
+	return resources, err
+}
+
+// ReadResource reads the file named by uri. Files that are valid UTF-8 are
+// returned as text.
+func (f *FileResources) ReadResource(ctx context.Context, uri string) (*core.ResourceContent, error) {
+	path, ok := f.path(uri)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", core.ErrResourceNotFound, uri)
+	}
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", core.ErrResourceNotFound, uri)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content := &core.ResourceContent{URI: uri, MimeType: fileMimeType(path)}
+	if utf8.Valid(data) {
+		content.Text = string(data)
+	} else {
+		content.Blob = data
+	}
+	return content, nil
+}
+
+// WatchResources polls the directory until ctx ends, calling changed for
+// every file created, modified or removed since the last poll.
+func (f *FileResources) WatchResources(ctx context.Context, changed func(uri string)) {
+	seen := f.scan()
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := f.scan()
+		for uri, state := range current {
+			if previous, ok := seen[uri]; !ok || previous != state {
+				changed(uri)
+			}
+		}
+		for uri := range seen {
+			if _, ok := current[uri]; !ok {
+				changed(uri)
+			}
+		}
+		seen = current
+	}
+}
+
+// fileState is what a poll compares to tell whether a file changed.
+type fileState struct {
+	size    int64
+	modTime int64 // Unix nanoseconds
+}
+
+// scan returns the state of every regular file by URI. Files that cannot be
+// read are left out.
+func (f *FileResources) scan() map[string]fileState {
+	states := make(map[string]fileState)
+	f.walk(func(path string, info fs.FileInfo) {
+		rel, _ := filepath.Rel(f.root, path)
+		states[f.URI(rel)] = fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	})
+	return states
+}
+
+// walk calls fn for every regular file under the directory, in lexical order.
+func (f *FileResources) walk(fn func(path string, info fs.FileInfo)) error {
+	return filepath.WalkDir(f.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		fn(path, info)
+		return nil
+	})
+}
+
+// path returns the path of the file named by uri, if it lies under the
+// directory.
+func (f *FileResources) path(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Host != "" {
+		return "", false
+	}
+	path := filepath.Clean(filepath.FromSlash(u.Path))
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return path, true
+}
+
+// fileMimeType returns the MIME type of path by its extension.
+func fileMimeType(path string) string {
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,371 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Isolation selects where the handlers of a HandlerGroup run.
+type Isolation int
+
+const (
+	// InProcess runs the group's handlers in the server's process. Panics are
+	// recovered and count against the group's error budget.
+	InProcess Isolation = iota
+
+	// Subprocess forwards the group's requests to a child process started
+	// from GroupOptions.Command, which registers the same handlers and
+	// serves them with ServeStdio. A crash of the child fails only the
+	// requests it was handling; another is started for the next request,
+	// up to MaxRestarts within RestartWindow. On Stop the child is sent
+	// core.MethodShutdown and given DrainTimeout to answer its requests, as
+	// a server with WithSubprocessMode does, before it is signalled.
+	Subprocess
+)
+
+// GroupState is whether a handler group is admitting requests.
+type GroupState string
+
+const (
+	GroupActive  GroupState = "active"  // Requests are admitted
+	GroupPaused  GroupState = "paused"  // The group exceeded its error budget and refuses requests
+	GroupProbing GroupState = "probing" // A single request is admitted to test whether the group recovered
+	GroupUnready GroupState = "unready" // The group's child process exited more often than MaxRestarts allows and refuses requests
+)
+
+// Defaults for GroupOptions fields left zero.
+const (
+	defaultGroupErrorWindow   = 30 * time.Second
+	defaultGroupMinRequests   = 10
+	defaultGroupProbeInterval = 5 * time.Second
+	defaultGroupDrainTimeout  = 5 * time.Second
+	defaultGroupRestartWindow = time.Minute
+)
+
+// groupBuckets is the number of buckets the error window is measured in.
+const groupBuckets = 10
+
+// GroupOptions configures a HandlerGroup.
+type GroupOptions struct {
+	PoolSize      int           // Requests the group's handlers run at once, apart from the server's pool; 0 to share the server's
+	QueueDepth    int           // Requests waiting for a slot of the group's pool before more are refused
+	MaxErrorRate  float64       // Fraction of requests failing or panicking within ErrorWindow above which the group pauses; 0 never pauses
+	ErrorWindow   time.Duration // Period the error rate is measured over; 30s if zero
+	MinRequests   int           // Requests within ErrorWindow before the rate is judged; 10 if zero
+	ProbeInterval time.Duration // Time a paused group waits before admitting a probe request; 5s if zero
+	Isolation     Isolation     // Where the group's handlers run
+	Command       *exec.Cmd     // Child process serving the group's handlers; required with Subprocess
+	DrainTimeout  time.Duration // Time a stopping child process is given to answer its requests before it is signalled; 5s if zero
+	MaxRestarts   int           // Child processes started within RestartWindow to replace ones that exited before the group is unready; 0 is unlimited
+	RestartWindow time.Duration // Period MaxRestarts is counted over; 1m if zero
+}
+
+// GroupStats describes a handler group in Stats.
+type GroupStats struct {
+	State     GroupState `json:"state"`
+	InFlight  int        `json:"inFlight"`  // Requests holding a slot of the group's pool
+	Queued    int        `json:"queued"`    // Requests waiting for a slot of the group's pool
+	Requests  int        `json:"requests"`  // Requests finished within the error window
+	Failures  int        `json:"failures"`  // Requests within the error window that failed or panicked
+	ErrorRate float64    `json:"errorRate"` // Failures over requests within the error window
+}
+
+// HandlerGroup isolates the handlers registered in it from the rest of the
+// server: they run in a pool of their own, and when their error rate exceeds
+// the group's budget only the group is paused. A paused group refuses its
+// methods with core.CodeServerBusy until a probe request, admitted once
+// ProbeInterval has passed, succeeds. Register handlers in a group with
+// Server.RegisterHandlerInGroup.
+type HandlerGroup struct {
+	name    string
+	options GroupOptions
+	pool    *requestPool
+	process *subprocess // Child process of a Subprocess group
+
+	mu      sync.Mutex
+	state   GroupState
+	since   time.Time // When the group paused or began probing
+	buckets [groupBuckets]groupBucket
+}
+
+// groupBucket counts the requests finished in one slice of the error window.
+type groupBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// NewHandlerGroup creates a handler group with the given options.
+func NewHandlerGroup(name string, options GroupOptions) *HandlerGroup {
+	if options.ErrorWindow <= 0 {
+		options.ErrorWindow = defaultGroupErrorWindow
+	}
+	if options.MinRequests <= 0 {
+		options.MinRequests = defaultGroupMinRequests
+	}
+	if options.ProbeInterval <= 0 {
+		options.ProbeInterval = defaultGroupProbeInterval
+	}
+	if options.DrainTimeout <= 0 {
+		options.DrainTimeout = defaultGroupDrainTimeout
+	}
+	if options.RestartWindow <= 0 {
+		options.RestartWindow = defaultGroupRestartWindow
+	}
+	g := &HandlerGroup{
+		name:    name,
+		options: options,
+		pool:    newRequestPool(options.PoolSize, options.QueueDepth),
+		state:   GroupActive,
+	}
+	if options.Isolation == Subprocess && options.Command != nil {
+		g.process = &subprocess{
+			template:      options.Command,
+			drainTimeout:  options.DrainTimeout,
+			maxRestarts:   options.MaxRestarts,
+			restartWindow: options.RestartWindow,
+		}
+	}
+	return g
+}
+
+// Name returns the name of the group.
+func (g *HandlerGroup) Name() string {
+	return g.name
+}
+
+// State returns whether the group is admitting requests.
+func (g *HandlerGroup) State() GroupState {
+	if g.process != nil && g.process.unready(time.Now()) {
+		return GroupUnready
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state
+}
+
+// admit returns nil if a request may run in the group at now, or why it may
+// not. A paused group admits a single probe once ProbeInterval has passed,
+// and another if the probe has not finished within that time. An unready
+// group admits requests again once restarts have aged out of RestartWindow.
+func (g *HandlerGroup) admit(now time.Time) error {
+	if g.process != nil && g.process.unready(now) {
+		return fmt.Errorf("handler group %s unready after its process exited %d times within %s",
+			g.name, g.options.MaxRestarts, g.options.RestartWindow)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch g.state {
+	case GroupActive:
+		return nil
+	default:
+		if now.Sub(g.since) < g.options.ProbeInterval {
+			return fmt.Errorf("handler group %s paused after exceeding its error budget", g.name)
+		}
+		g.state, g.since = GroupProbing, now
+		return nil
+	}
+}
+
+// record counts a finished request, pausing the group if the error rate
+// within the window exceeds the budget, and settling a probe. It returns the
+// state the group moved to, or "" if it did not change.
+func (g *HandlerGroup) record(failed bool, now time.Time) GroupState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch g.state {
+	case GroupPaused:
+		// Requests admitted before the pause do not count
+		return ""
+	case GroupProbing:
+		if failed {
+			g.state, g.since = GroupPaused, now
+			return GroupPaused
+		}
+		g.state = GroupActive
+		g.buckets = [groupBuckets]groupBucket{}
+		return GroupActive
+	}
+
+	width := g.options.ErrorWindow / groupBuckets
+	start := now.Truncate(width)
+	bucket := &g.buckets[(start.UnixNano()/int64(width))%groupBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = groupBucket{start: start}
+	}
+	bucket.requests++
+	if failed {
+		bucket.failures++
+	}
+
+	if g.options.MaxErrorRate <= 0 {
+		return ""
+	}
+	requests, failures := g.countLocked(now)
+	if requests >= g.options.MinRequests && float64(failures)/float64(requests) > g.options.MaxErrorRate {
+		g.state, g.since = GroupPaused, now
+		return GroupPaused
+	}
+	return ""
+}
+
+// countLocked returns the requests and failures within the window ending at now.
+func (g *HandlerGroup) countLocked(now time.Time) (requests, failures int) {
+	for _, bucket := range g.buckets {
+		if now.Sub(bucket.start) < g.options.ErrorWindow {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+// stats returns a snapshot of the group for Stats.
+func (g *HandlerGroup) stats(now time.Time) GroupStats {
+	inFlight, queued := g.pool.counts()
+	unready := g.process != nil && g.process.unready(now)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	requests, failures := g.countLocked(now)
+	stats := GroupStats{State: g.state, InFlight: inFlight, Queued: queued, Requests: requests, Failures: failures}
+	if unready {
+		stats.State = GroupUnready
+	}
+	if requests > 0 {
+		stats.ErrorRate = float64(failures) / float64(requests)
+	}
+	return stats
+}
+
+// RegisterHandlerInGroup registers handler as RegisterHandler does, running
+// its methods in group. Batches run in the group of the core.MethodProcessModel
+// handler. A Subprocess group forwards core.MethodProcessModel requests to its
+// child process, so its handlers may not serve other methods.
+func (s *Server) RegisterHandlerInGroup(group *HandlerGroup, handler Handler) error {
+	if group.options.Isolation == Subprocess {
+		if group.process == nil {
+			return fmt.Errorf("handler group %s: subprocess isolation requires a command", group.name)
+		}
+		for _, method := range handler.Methods() {
+			if method != core.MethodProcessModel {
+				return fmt.Errorf("handler group %s: method %s cannot run in a subprocess", group.name, method)
+			}
+		}
+	}
+	return s.registerHandler(handler, group)
+}
+
+// groupInUseLocked reports whether any method still runs in group. The
+// caller holds handlersMu.
+func (s *Server) groupInUseLocked(group *HandlerGroup) bool {
+	for _, g := range s.groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// groupKey is the context key of the group dispatch resolved a request's
+// handler in.
+type groupKey struct{}
+
+// inGroup returns process, the handler's implementation of method, run the
+// way the method's group requires: forwarded to the group's child process,
+// and with each outcome counted against the group's budget. The group is the
+// one dispatch resolved the handler in, so a request is unaffected by the
+// handler being unregistered meanwhile; requests run outside dispatch, such
+// as jobs, look it up. Panics are recovered whether or not the method is in
+// a group, failing the request with a generic internal error.
+func (s *Server) inGroup(ctx context.Context, method string, process func(context.Context, *core.ModelRequest) (*core.ModelResponse, error)) func(context.Context, *core.ModelRequest) (*core.ModelResponse, error) {
+	group, resolved := ctx.Value(groupKey{}).(*HandlerGroup)
+	if !resolved {
+		_, group, _ = s.lookup(method)
+	}
+	if group != nil && group.process != nil {
+		process = group.process.processModel
+	}
+	return func(ctx context.Context, req *core.ModelRequest) (resp *core.ModelResponse, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				fields := []interface{}{core.LogFieldRequestID, req.ID}
+				if group != nil {
+					fields = append(fields, "group", group.name)
+				}
+				resp, err = nil, s.handlePanic(method, r, fields...)
+			}
+			if group != nil {
+				s.recordGroup(group, err != nil)
+			}
+		}()
+		return process(ctx, req)
+	}
+}
+
+// recordGroup counts an outcome of group, logging any change of state.
+func (s *Server) recordGroup(group *HandlerGroup, failed bool) {
+	switch group.record(failed, time.Now()) {
+	case GroupPaused:
+		s.options.Logger.Warn("Handler group paused", "group", group.name)
+	case GroupActive:
+		s.options.Logger.Info("Handler group resumed", "group", group.name)
+	}
+}
+
+// handlerGroups returns every registered group.
+func (s *Server) handlerGroups() []*HandlerGroup {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	groups := make([]*HandlerGroup, 0, len(s.groupsByName))
+	for _, group := range s.groupsByName {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// groupStats returns the stats of every group, or nil without groups.
+func (s *Server) groupStats() map[string]GroupStats {
+	groups := s.handlerGroups()
+	if len(groups) == 0 {
+		return nil
+	}
+	now := time.Now()
+	stats := make(map[string]GroupStats, len(groups))
+	for _, group := range groups {
+		stats[group.name] = group.stats(now)
+	}
+	return stats
+}
+
+// openGroups lets the Subprocess groups a previous run closed start child
+// processes again.
+func (s *Server) openGroups() {
+	for _, group := range s.handlerGroups() {
+		if group.process != nil {
+			group.process.open()
+		}
+	}
+}
+
+// closeGroups shuts the child processes of Subprocess groups down, draining
+// them all at once.
+func (s *Server) closeGroups() {
+	var wg sync.WaitGroup
+	for _, group := range s.handlerGroups() {
+		if group.process != nil {
+			wg.Add(1)
+			go func(process *subprocess) {
+				defer wg.Done()
+				process.close()
+			}(group.process)
+		}
+	}
+	wg.Wait()
+}
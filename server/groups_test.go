@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// PanickyModelHandler panics on every request while panicking is set
+type PanickyModelHandler struct {
+	panicking atomic.Bool
+}
+
+func (h *PanickyModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *PanickyModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if h.panicking.Load() {
+		panic("experimental model exploded")
+	}
+	return core.NewModelResponse(req), nil
+}
+
+// startGroupServer starts a server with the given groups and handlers and
+// returns it with n connected clients
+func startGroupServer(t *testing.T, n int, register func(srv *Server)) (*Server, []*client.Client) {
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+	register(srv)
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+
+	clients := make([]*client.Client, n)
+	for i := range clients {
+		clients[i] = client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		require.NoError(t, clients[i].Start(), "Client should connect")
+		c := clients[i]
+		t.Cleanup(func() { c.Stop() })
+	}
+	return srv, clients
+}
+
+// requireCode asserts err is a JSON-RPC error with code
+func requireCode(t *testing.T, err error, code int64, msg string) {
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), msg)
+	assert.Equal(t, code, rpcErr.Code, msg)
+}
+
+func TestHandlerGroupErrorBudget(t *testing.T) {
+	experimental := &PanickyModelHandler{}
+	srv, clients := startGroupServer(t, 1, func(srv *Server) {
+		group := NewHandlerGroup("experimental", GroupOptions{
+			MaxErrorRate:  0.5,
+			ErrorWindow:   time.Minute,
+			MinRequests:   4,
+			ProbeInterval: 100 * time.Millisecond,
+		})
+		require.NoError(t, srv.RegisterHandlerInGroup(group, experimental), "Grouped handler registration should succeed")
+		require.NoError(t, srv.RegisterHandlerInGroup(NewHandlerGroup("critical", GroupOptions{MaxErrorRate: 0.5}), &CountingStreamHandler{count: 3}),
+			"Grouped handler registration should succeed")
+	})
+	c := clients[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := func() error {
+		_, err := c.ProcessModelStream(ctx, testutil.CreateTestModelRequest(), func(core.ModelChunk) {})
+		return err
+	}
+
+	// Panics are recovered and count against the group's budget
+	experimental.panicking.Store(true)
+	for i := 0; i < 4; i++ {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		requireCode(t, err, jsonrpc2.CodeInternalError, "Panicking handler should fail the request")
+	}
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requireCode(t, err, core.CodeServerBusy, "Group over its budget should be paused")
+	stats := srv.Stats().Groups
+	assert.Equal(t, GroupPaused, stats["experimental"].State, "Stats should report the paused group")
+	assert.Equal(t, GroupActive, stats["critical"].State, "Other groups should stay active")
+	health := srv.Health()
+	assert.Equal(t, core.HealthDegraded, health.Status, "Paused group should degrade health")
+	assert.Equal(t, string(GroupPaused), health.Degraded["group:experimental"], "Health should name the paused group")
+
+	// The other group keeps serving
+	assert.NoError(t, stream(), "Other groups should be unaffected")
+
+	// A failed probe pauses the group again
+	time.Sleep(100 * time.Millisecond)
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requireCode(t, err, jsonrpc2.CodeInternalError, "Probe should reach the handler")
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requireCode(t, err, core.CodeServerBusy, "Failed probe should pause the group again")
+
+	// Once the handler recovers, the next probe resumes the group
+	experimental.panicking.Store(false)
+	time.Sleep(100 * time.Millisecond)
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Probe should succeed once the handler recovers")
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Resumed group should admit requests")
+	assert.Equal(t, GroupActive, srv.Stats().Groups["experimental"].State, "Stats should report the resumed group")
+	assert.Equal(t, core.HealthHealthy, srv.Health().Status, "Resumed group should restore health")
+	assert.NoError(t, stream(), "Other groups should be unaffected")
+}
+
+func TestHandlerGroupPool(t *testing.T) {
+	gated := newGatedModelHandler()
+	srv, clients := startGroupServer(t, 3, func(srv *Server) {
+		group := NewHandlerGroup("experimental", GroupOptions{PoolSize: 1})
+		require.NoError(t, srv.RegisterHandlerInGroup(group, gated), "Grouped handler registration should succeed")
+		require.NoError(t, srv.RegisterHandler(&CountingStreamHandler{count: 3}), "Handler registration should succeed")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first := processAsync(ctx, clients[0])
+	<-gated.started
+	_, err := clients[1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requireCode(t, err, core.CodeServerBusy, "Request beyond the group's pool should be refused")
+	assert.Equal(t, 1, srv.Stats().Groups["experimental"].InFlight, "Stats should report the group's pool")
+
+	// Ungrouped methods do not share the group's pool
+	_, err = clients[2].ProcessModelStream(ctx, testutil.CreateTestModelRequest(), func(core.ModelChunk) {})
+	assert.NoError(t, err, "Ungrouped methods should be unaffected")
+
+	close(gated.release)
+	assert.NoError(t, <-first, "Admitted request should succeed")
+}
+
+// buildStdioServer compiles the stdio server in dir into a temporary directory
+func buildStdioServer(t *testing.T, dir string) string {
+	goTool := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(goTool); err != nil {
+		t.Skip("go tool not available to build the stdio server")
+	}
+
+	binary := filepath.Join(t.TempDir(), "stdio-server")
+	build := exec.Command(goTool, "build", "-o", binary, dir)
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, "Stdio server should build: %s", out)
+	return binary
+}
+
+func TestHandlerGroupSubprocess(t *testing.T) {
+	binary := buildStdioServer(t, "../examples/stdio/server")
+	_, clients := startGroupServer(t, 1, func(srv *Server) {
+		group := NewHandlerGroup("isolated", GroupOptions{Isolation: Subprocess, Command: exec.Command(binary)})
+		require.NoError(t, srv.RegisterHandlerInGroup(group, NewDefaultModelHandler()), "Grouped handler registration should succeed")
+
+		streaming := NewHandlerGroup("streaming", GroupOptions{Isolation: Subprocess, Command: exec.Command(binary)})
+		assert.Error(t, srv.RegisterHandlerInGroup(streaming, &CountingStreamHandler{}), "Streaming methods cannot run in a subprocess")
+		assert.Error(t, srv.RegisterHandlerInGroup(NewHandlerGroup("commandless", GroupOptions{Isolation: Subprocess}), &CountingStreamHandler{}),
+			"Subprocess isolation should require a command")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	resp, err := clients[0].ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should be served by the child process")
+	assert.Equal(t, req.ID, resp.ID, "Response ID should match request ID")
+	assert.Equal(t, "processed", resp.Results["status"], "Child's handler should process the request")
+}
@@ -38,7 +38,7 @@ func NewDefaultModelHandler() *DefaultModelHandler {
 // Methods returns the list of method names that this handler implements.
 // For DefaultModelHandler, this includes only the model processing method.
 func (h *DefaultModelHandler) Methods() []string {
-	return []string{"mcp.processModel"}
+	return []string{core.MethodProcessModel}
 }
 
 // ProcessModel processes a model request and returns a successful response.
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ListenFDsEnv is the environment variable announcing inherited listeners, as
+// in systemd socket activation. Inherited descriptors start at listenFDsStart.
+const ListenFDsEnv = "LISTEN_FDS"
+
+const listenFDsStart = 3
+
+// Messages exchanged over the handoff control socket.
+const (
+	handoffReady   = "ready"
+	handoffDrained = "drained"
+)
+
+// drainPollInterval is how often Drain checks whether all sessions have ended.
+const drainPollInterval = 10 * time.Millisecond
+
+// InheritedListenerFD returns the descriptor of the first listener passed to
+// this process under the LISTEN_FDS convention, for WithInheritedListener.
+// A parent sets it up with exec.Cmd.ExtraFiles = []*os.File{file} and
+// LISTEN_FDS=1 in the child's environment.
+func InheritedListenerFD() (uintptr, bool) {
+	n, err := strconv.Atoi(os.Getenv(ListenFDsEnv))
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return listenFDsStart, true
+}
+
+// filer is implemented by listeners backed by a socket file.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// ListenerFile returns a duplicate of the listening socket, suitable for
+// exec.Cmd.ExtraFiles, so a successor process can accept on the same socket.
+// It fails for transports without a socket, such as the in-process transport.
+func (s *Server) ListenerFile() (*os.File, error) {
+	s.statusMu.RLock()
+	listener := s.rawListener
+	s.statusMu.RUnlock()
+
+	if listener == nil {
+		return nil, errors.New("server is not listening")
+	}
+	f, ok := listener.(filer)
+	if !ok {
+		return nil, fmt.Errorf("listener %T cannot be passed to another process", listener)
+	}
+	return f.File()
+}
+
+// listen opens a listener on addr, or adopts the inherited one.
+func (s *Server) listen(addr string) (net.Listener, error) {
+	if s.options.InheritedListenerFD == 0 {
+		return s.transportFor(addr).Listen(addr)
+	}
+
+	file := os.NewFile(s.options.InheritedListenerFD, "mcp-listener")
+	if file == nil {
+		return nil, fmt.Errorf("invalid inherited listener descriptor %d", s.options.InheritedListenerFD)
+	}
+	defer file.Close()
+	return net.FileListener(file)
+}
+
+// Drain stops accepting connections, closes each open connection once its
+// current request has been answered, and stops the server once every
+// connection is gone. Clients with auto-reconnect enabled reconnect to whoever
+// now owns the listening socket. If ctx is done first, the remaining
+// connections are closed immediately.
+func (s *Server) Drain(ctx context.Context) error {
+	if s.Status() != core.StatusRunning {
+		return fmt.Errorf("cannot drain server in %s state", s.Status())
+	}
+	s.options.Logger.Info("Draining connections")
+
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+
+	s.connsMu.Lock()
+	s.draining = true
+	for session := range s.sessions {
+		session.closeWhenIdle()
+	}
+	s.connsMu.Unlock()
+
+	ticker := s.tasks.NewTicker(core.TaskHandoff, drainPollInterval)
+	defer ticker.Stop()
+	for s.sessionCount() > 0 {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return s.Stop()
+}
+
+// sessionCount returns the number of connections being served.
+func (s *Server) sessionCount() int {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	return len(s.sessions)
+}
+
+// AwaitSuccessor listens on a unix control socket at path until a successor
+// started with the listener from ListenerFile calls NotifyReady, then drains
+// the server and acknowledges. It returns the result of Drain.
+func (s *Server) AwaitSuccessor(ctx context.Context, path string) error {
+	control, err := core.UnixTransport{Path: path}.Listen("")
+	if err != nil {
+		return fmt.Errorf("failed to listen for successor: %w", err)
+	}
+	defer control.Close()
+
+	// Unblock Accept if ctx ends first
+	stop := context.AfterFunc(ctx, func() { control.Close() })
+	defer stop()
+
+	for {
+		conn, err := control.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to accept successor: %w", err)
+		}
+
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || strings.TrimSpace(line) != handoffReady {
+			conn.Close()
+			continue
+		}
+
+		s.options.Logger.Info("Successor ready, handing off listener")
+		err = s.Drain(ctx)
+		io.WriteString(conn, handoffDrained+"\n")
+		conn.Close()
+		return err
+	}
+}
+
+// NotifyReady tells the server waiting in AwaitSuccessor at path that this
+// process is accepting connections, and waits until the old server has drained.
+func NotifyReady(ctx context.Context, path string) error {
+	conn, err := core.UnixTransport{Path: path}.Dial(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to reach predecessor: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := io.WriteString(conn, handoffReady+"\n"); err != nil {
+		return fmt.Errorf("failed to signal readiness: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("predecessor did not confirm drain: %w", err)
+	}
+	if strings.TrimSpace(line) != handoffDrained {
+		return fmt.Errorf("unexpected handoff reply %q", strings.TrimSpace(line))
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NamedModelHandler stamps responses with the name of the server that handled them
+type NamedModelHandler struct {
+	name string
+}
+
+func (h *NamedModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *NamedModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	resp.Results["server"] = h.name
+	return resp, nil
+}
+
+func newNamedServer(t *testing.T, name string, options ...Option) *Server {
+	srv := New(append([]Option{WithLogger(core.NopLogger())}, options...)...)
+	require.NoError(t, srv.RegisterHandler(&NamedModelHandler{name: name}), "Handler registration should succeed")
+	return srv
+}
+
+func TestListenerHandoff(t *testing.T) {
+	// Server A owns the socket to begin with
+	a := newNamedServer(t, "a", WithPort(0))
+	require.NoError(t, a.Start(), "Server A should start")
+
+	c := client.New(
+		client.WithServerAddr(a.Addr().String()),
+		client.WithReconnectDelay(20*time.Millisecond),
+		client.WithMaxReconnectAttempts(10),
+		client.WithLogger(core.NopLogger()),
+	)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+
+	// Issue requests continuously, waiting out reconnects as an application would
+	var (
+		mu       sync.Mutex
+		servedBy []string
+		failures []error
+	)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if !c.IsConnected() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+			cancel()
+
+			mu.Lock()
+			if err != nil {
+				failures = append(failures, err)
+			} else {
+				servedBy = append(servedBy, resp.Results["server"].(string))
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	// A waits for a successor on the control socket
+	control := filepath.Join(t.TempDir(), "handoff.sock")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	handedOff := make(chan error, 1)
+	go func() { handedOff <- a.AwaitSuccessor(ctx, control) }()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(control)
+		return err == nil
+	}, time.Second, 5*time.Millisecond, "Control socket should be created")
+
+	time.Sleep(50 * time.Millisecond)
+
+	// B starts on the inherited socket and tells A it is ready
+	file, err := a.ListenerFile()
+	require.NoError(t, err, "Listener should be exportable")
+	b := newNamedServer(t, "b", WithInheritedListener(file.Fd()))
+	require.NoError(t, b.Start(), "Server B should start on the inherited listener")
+	defer b.Stop()
+	file.Close()
+
+	require.NoError(t, NotifyReady(ctx, control), "A should confirm the handoff")
+	require.NoError(t, <-handedOff, "A should drain cleanly")
+	assert.Equal(t, core.StatusStopped, a.Status(), "A should stop after draining")
+
+	// Keep going until B has served a few requests
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(servedBy) > 0 && servedBy[len(servedBy)-1] == "b" && len(servedBy) > 10
+	}, 5*time.Second, 10*time.Millisecond, "B should take over serving requests")
+	close(done)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.LessOrEqual(t, len(failures), 1, "At most the request racing the close should fail: %v", failures)
+	assert.Equal(t, "a", servedBy[0], "A should serve the first requests")
+	switched := false
+	for _, name := range servedBy {
+		if name == "b" {
+			switched = true
+		}
+		if switched {
+			assert.Equal(t, "b", name, "No request should reach A after the handoff")
+		}
+	}
+	assert.Equal(t, uint64(2), c.Stats().Connections, "Client should reconnect exactly once")
+}
+
+func TestListenerFileRequiresSocket(t *testing.T) {
+	srv := New(WithTransport(core.NewInProcessTransport()))
+	_, err := srv.ListenerFile()
+	assert.Error(t, err, "Stopped server should have no listener")
+
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+	_, err = srv.ListenerFile()
+	assert.Error(t, err, "In-process listener cannot be passed to another process")
+}
+
+func TestInheritedListenerFD(t *testing.T) {
+	t.Setenv(ListenFDsEnv, "")
+	_, ok := InheritedListenerFD()
+	assert.False(t, ok, "Nothing should be inherited without LISTEN_FDS")
+
+	t.Setenv(ListenFDsEnv, "1")
+	fd, ok := InheritedListenerFD()
+	assert.True(t, ok, "LISTEN_FDS should announce an inherited listener")
+	assert.Equal(t, uintptr(3), fd, "Inherited descriptors should start at 3")
+}
+
+func TestDrainWaitsForInFlightRequest(t *testing.T) {
+	srv, c := startServerWithHandler(t, &SlowModelHandler{delay: 200 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		result <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, srv.Drain(ctx), "Drain should finish")
+	assert.NoError(t, <-result, "In-flight request should be answered before the connection closes")
+	assert.Equal(t, core.StatusStopped, srv.Status(), "Server should stop after draining")
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// healthResponse describes the server for a core.MethodHealth call and for
+// the health check listener.
+func (s *Server) healthResponse() core.HealthResponse {
+	s.statusMu.RLock()
+	status, startedAt := s.status, s.startedAt
+	s.statusMu.RUnlock()
+
+	health := core.HealthResponse{
+		Status:      status,
+		Connections: s.sessionCount(),
+		Handlers:    make(map[string][]string),
+	}
+	if status == core.StatusRunning {
+		health.Uptime = time.Since(startedAt)
+	}
+
+	s.handlersMu.RLock()
+	for method, handler := range s.handlers {
+		name := fmt.Sprintf("%T", handler)
+		health.Handlers[name] = append(health.Handlers[name], method)
+	}
+	s.handlersMu.RUnlock()
+	for _, methods := range health.Handlers {
+		sort.Strings(methods)
+	}
+	return health
+}
+
+// ready returns nil if the server is running, no handler group is unready and
+// its ReadinessCheck, if any, passes, or the reason it is not ready.
+func (s *Server) ready() error {
+	if status := s.Status(); status != core.StatusRunning {
+		return fmt.Errorf("server is %s", status)
+	}
+	for _, group := range s.handlerGroups() {
+		if group.State() == GroupUnready {
+			return fmt.Errorf("handler group %s is unready", group.name)
+		}
+	}
+	if check := s.options.ReadinessCheck; check != nil {
+		return check()
+	}
+	return nil
+}
+
+// serveHealthChecks starts the HTTP listener serving the liveness probe on
+// /healthz and the readiness probe on /readyz.
+func (s *Server) serveHealthChecks() error {
+	listener, err := net.Listen("tcp", s.options.HealthAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for health checks on %s: %w", s.options.HealthAddr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.serveLiveness)
+	mux.HandleFunc("/readyz", s.serveReadiness)
+	s.healthLn = listener
+	s.healthSrv = &http.Server{Handler: mux}
+
+	s.wg.Add(1)
+	s.tasks.Go(core.TaskHealth, func() {
+		defer s.wg.Done()
+		s.healthSrv.Serve(listener)
+	})
+	return nil
+}
+
+// closeHealthChecks stops the health check listener, if one is running.
+func (s *Server) closeHealthChecks() {
+	if s.healthSrv != nil {
+		s.healthSrv.Close()
+		s.healthSrv = nil
+	}
+}
+
+// serveLiveness writes the server's health as JSON; answering at all shows
+// the process is alive.
+func (s *Server) serveLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.healthResponse())
+}
+
+// serveReadiness answers 200 if the server is ready to serve requests, or
+// 503 with the reason it is not.
+func (s *Server) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	if err := s.ready(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// HealthAddr returns the address of the health check listener, or nil if
+// WithHealthAddr was not set or the server is not running.
+func (s *Server) HealthAddr() net.Addr {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	if s.healthLn == nil || s.status != core.StatusRunning {
+		return nil
+	}
+	return s.healthLn.Addr()
+}
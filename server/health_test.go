@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probe requests path from the health check listener at addr and returns the
+// status code.
+func probe(addr, path string) (int, error) {
+	resp, err := http.Get("http://" + addr + path)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestHealthChecks(t *testing.T) {
+	port, err := testutil.GetFreePort()
+	require.NoError(t, err, "Failed to get free port")
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+	var loaded atomic.Bool
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport), WithLogger(core.NopLogger()), WithHealthAddr(addr),
+		WithReadinessCheck(func() error {
+			if !loaded.Load() {
+				return errors.New("model not loaded")
+			}
+			return nil
+		}))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	c := client.New(client.WithTransport(transport), client.WithLogger(core.NopLogger()), client.WithAutoReconnect(false))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Before Start nothing answers
+	_, err = probe(addr, "/healthz")
+	assert.Error(t, err, "Liveness probe should fail before Start")
+	_, err = probe(addr, "/readyz")
+	assert.Error(t, err, "Readiness probe should fail before Start")
+	_, err = c.Ping(ctx)
+	assert.Error(t, err, "Ping should fail before Start")
+	assert.Nil(t, srv.HealthAddr(), "Health check listener should not be reported before Start")
+
+	require.NoError(t, srv.Start(), "Server should start")
+	require.NoError(t, c.Start(), "Client should connect")
+	require.NotNil(t, srv.HealthAddr(), "Health check listener should be running")
+	assert.Equal(t, addr, srv.HealthAddr().String(), "Health check listener should use the configured address")
+
+	code, err := probe(addr, "/healthz")
+	require.NoError(t, err, "Liveness probe should be answered while running")
+	assert.Equal(t, http.StatusOK, code, "Server should be alive while running")
+	code, err = probe(addr, "/readyz")
+	require.NoError(t, err, "Readiness probe should be answered while running")
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Server should not be ready until the readiness check passes")
+
+	loaded.Store(true)
+	code, err = probe(addr, "/readyz")
+	require.NoError(t, err, "Readiness probe should be answered while running")
+	assert.Equal(t, http.StatusOK, code, "Server should be ready once the readiness check passes")
+
+	health, err := c.Ping(ctx)
+	require.NoError(t, err, "Ping should succeed while running")
+	assert.Equal(t, core.StatusRunning, health.Status, "Health should report the server running")
+	assert.Positive(t, health.Uptime, "Health should report the uptime")
+	assert.Equal(t, 1, health.Connections, "Health should count the client's connection")
+	assert.Equal(t, map[string][]string{
+		"*server.DefaultModelHandler": {core.MethodProcessModel},
+	}, health.Handlers, "Health should list the methods of each handler")
+
+	resp, err := http.Get("http://" + addr + "/healthz")
+	require.NoError(t, err, "Liveness probe should be answered while running")
+	defer resp.Body.Close()
+	var served core.HealthResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&served), "Liveness probe should serve the health as JSON")
+	assert.Equal(t, health.Handlers, served.Handlers, "Liveness probe should serve the same health as the RPC")
+
+	// After Stop nothing answers again
+	require.NoError(t, srv.Stop(), "Server should stop")
+	_, err = probe(addr, "/healthz")
+	assert.Error(t, err, "Liveness probe should fail after Stop")
+	_, err = probe(addr, "/readyz")
+	assert.Error(t, err, "Readiness probe should fail after Stop")
+	_, err = c.Ping(ctx)
+	assert.Error(t, err, "Ping should fail after Stop")
+	assert.Nil(t, srv.HealthAddr(), "Health check listener should not be reported after Stop")
+	c.Stop()
+}
+
+func TestHealthResponseReportsStatus(t *testing.T) {
+	srv := New(WithTransport(core.NewInProcessTransport()))
+	health := srv.healthResponse()
+	assert.Equal(t, core.StatusStopped, health.Status, "Health should report a stopped server")
+	assert.Zero(t, health.Uptime, "Stopped server should have no uptime")
+	assert.Error(t, srv.ready(), "Stopped server should not be ready")
+}
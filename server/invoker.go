@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// TestInvoker runs requests through a server's dispatch pipeline without a
+// network or JSON-RPC connection, for unit tests of handlers and the
+// middleware around them: authentication, authorization, rate and
+// concurrency limits, validation, defaults and error mapping. The server need
+// not be started. Each Invoke is made on a connection of its own, which is
+// listed by Server.Connections and reached by Publish while the request is
+// handled.
+type TestInvoker struct {
+	server *Server
+	nextID uint64 // Request IDs; accessed atomically
+
+	mu            sync.Mutex
+	notifications []InvokedNotification
+	progress      []core.JobProgress
+}
+
+// InvokedNotification is a notification a request sent to its client during
+// TestInvoker.Invoke, such as a stream chunk or one sent with Notify.
+type InvokedNotification struct {
+	Method string
+	Params json.RawMessage
+}
+
+// InvokeOption configures a single TestInvoker.Invoke.
+type InvokeOption func(*invocation)
+
+// invocation holds the connection details of one Invoke.
+type invocation struct {
+	principal *core.Principal
+	info      ConnectionInfo
+	metadata  map[string]string
+}
+
+// WithInvokePrincipal makes the request on a connection authenticated as
+// principal, as checked when the server requires authentication.
+func WithInvokePrincipal(principal core.Principal) InvokeOption {
+	return func(inv *invocation) {
+		inv.principal = &principal
+	}
+}
+
+// WithInvokeConnection sets the connection details the request is made on:
+// its remote address, which handlers see in logs and audit records, the
+// compression and codec it reports as negotiated, and the capabilities the
+// client negotiated, which batches and streams need as on a real connection.
+// Without Capabilities the connection is one that did not initialize.
+func WithInvokeConnection(info ConnectionInfo) InvokeOption {
+	return func(inv *invocation) {
+		inv.info = info
+	}
+}
+
+// WithInvokeMetadata adds metadata to the request, as a client's
+// WithDefaultMetadata does. Values already in the request take precedence.
+func WithInvokeMetadata(md map[string]string) InvokeOption {
+	return func(inv *invocation) {
+		inv.metadata = md
+	}
+}
+
+// NewTestInvoker returns a TestInvoker for srv.
+func NewTestInvoker(srv *Server) *TestInvoker {
+	return &TestInvoker{server: srv}
+}
+
+// Invoke handles req as a call to method, e.g. core.MethodProcessModel, and
+// returns the response the client would receive. Requests the pipeline
+// refuses fail with the *jsonrpc2.Error a client would get. Progress reported
+// through ProgressFromContext and notifications sent to the client are kept
+// for Progress and Notifications.
+func (t *TestInvoker) Invoke(ctx context.Context, method string, req *core.ModelRequest, opts ...InvokeOption) (*core.ModelResponse, error) {
+	var inv invocation
+	for _, opt := range opts {
+		opt(&inv)
+	}
+
+	if len(inv.metadata) > 0 {
+		copied := *req
+		copied.Metadata = make(map[string]string, len(req.Metadata)+len(inv.metadata))
+		for key, value := range inv.metadata {
+			copied.Metadata[key] = value
+		}
+		for key, value := range req.Metadata {
+			copied.Metadata[key] = value
+		}
+		req = &copied
+	}
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	raw := json.RawMessage(params)
+	rpcReq := &jsonrpc2.Request{
+		Method: method,
+		Params: &raw,
+		ID:     jsonrpc2.ID{Num: atomic.AddUint64(&t.nextID, 1)},
+	}
+
+	t.mu.Lock()
+	t.notifications, t.progress = nil, nil
+	t.mu.Unlock()
+
+	conn := &recordingConn{invoker: t, done: make(chan struct{})}
+	defer close(conn.done)
+	h := &rpcHandler{
+		server:     t.server,
+		remoteAddr: inv.info.RemoteAddr,
+		limiter:    newConnLimiter(t.server.options.RateLimit, t.server.options.MethodRateLimits),
+		closer:     conn,
+		fixedInfo:  &inv.info,
+	}
+	h.session.principal = inv.principal
+	h.session.capabilities = inv.info.Capabilities
+	t.server.addSession(h, conn)
+	defer t.server.removeSession(h)
+
+	ctx = context.WithValue(ctx, progressKey{}, &recordingReporter{invoker: t})
+	h.dispatch(ctx, conn, rpcReq)
+
+	if conn.err != nil {
+		return nil, conn.err
+	}
+	if conn.result == nil {
+		return nil, errors.New("request was not answered")
+	}
+	var resp core.ModelResponse
+	if err := json.Unmarshal(*conn.result, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &resp, nil
+}
+
+// Notifications returns the notifications sent to the client during the last
+// Invoke, in order.
+func (t *TestInvoker) Notifications() []InvokedNotification {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]InvokedNotification(nil), t.notifications...)
+}
+
+// Progress returns the progress reported during the last Invoke, in order.
+func (t *TestInvoker) Progress() []core.JobProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]core.JobProgress(nil), t.progress...)
+}
+
+// recordingConn stands in for the client connection of an Invoke, keeping
+// the reply and notifications.
+type recordingConn struct {
+	invoker *TestInvoker
+	done    chan struct{}
+
+	result *json.RawMessage
+	err    *jsonrpc2.Error
+}
+
+func (c *recordingConn) Reply(ctx context.Context, id jsonrpc2.ID, result interface{}) error {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(payload)
+	c.result = &raw
+	return nil
+}
+
+func (c *recordingConn) ReplyWithError(ctx context.Context, id jsonrpc2.ID, respErr *jsonrpc2.Error) error {
+	c.err = respErr
+	return nil
+}
+
+func (c *recordingConn) SendResponse(ctx context.Context, resp *jsonrpc2.Response) error {
+	c.result = resp.Result
+	c.err = resp.Error
+	return nil
+}
+
+func (c *recordingConn) Notify(ctx context.Context, method string, params interface{}, opts ...jsonrpc2.CallOption) error {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.invoker.mu.Lock()
+	defer c.invoker.mu.Unlock()
+	c.invoker.notifications = append(c.invoker.notifications, InvokedNotification{Method: method, Params: payload})
+	return nil
+}
+
+func (c *recordingConn) DisconnectNotify() <-chan struct{} {
+	return c.done
+}
+
+// Close implements io.Closer, so that draining the server leaves the
+// invocation alone.
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+// recordingReporter keeps the progress a handler reports during an Invoke.
+type recordingReporter struct {
+	invoker *TestInvoker
+}
+
+func (r *recordingReporter) ReportProgress(percent float64, message string) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("progress %v%% is outside 0-100%%", percent)
+	}
+	r.invoker.mu.Lock()
+	defer r.invoker.mu.Unlock()
+	r.invoker.progress = append(r.invoker.progress, core.JobProgress{
+		State:     core.JobRunning,
+		Sequence:  len(r.invoker.progress) + 1,
+		Percent:   percent,
+		Message:   message,
+		UpdatedAt: time.Now(),
+	})
+	return nil
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/core/tools"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ReportingHandler reports progress and notifies the client before answering
+// with the connection and metadata it saw
+type ReportingHandler struct {
+	srv *Server
+}
+
+func (h *ReportingHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *ReportingHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	for _, percent := range []float64{25, 75} {
+		if err := ProgressFromContext(ctx).ReportProgress(percent, "working"); err != nil {
+			return nil, err
+		}
+	}
+	if err := Notify(ctx, progressNotification, progressUpdate{RequestID: req.ID, Percent: 75}); err != nil {
+		return nil, err
+	}
+	resp := core.NewModelResponse(req)
+	resp.Results["tenant"] = req.Metadata["tenant"]
+	if conns := h.srv.Connections(); len(conns) == 1 {
+		resp.Results["codec"] = conns[0].Codec
+	}
+	return resp, nil
+}
+
+func TestInvokerValidation(t *testing.T) {
+	handler := testutil.NewSchemaHandler(testModelSchema())
+	srv := New(WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	invoker := NewTestInvoker(srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := invoker.Invoke(ctx, core.MethodProcessModel, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Matching request should be processed")
+	assert.True(t, resp.Success, "Matching request should succeed")
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["value"] = 500
+	_, err = invoker.Invoke(ctx, core.MethodProcessModel, req)
+
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Rejection should be the JSON-RPC error a client would get")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Rejection should use the invalid params code")
+	require.NotNil(t, rpcErr.Data, "Rejection should carry the validation errors")
+	var errs []tools.ValidationError
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &errs), "Validation errors should decode")
+	assert.Equal(t, []tools.ValidationError{{Field: "modelData.value", Message: "must be at most 100"}}, errs, "Violation should be reported")
+	assert.Equal(t, 1, handler.Calls(), "Invalid request should not reach the handler")
+}
+
+func TestInvokerCapturesProgress(t *testing.T) {
+	srv := New(WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(&ReportingHandler{srv: srv}), "Handler registration should succeed")
+	invoker := NewTestInvoker(srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	resp, err := invoker.Invoke(ctx, core.MethodProcessModel, req,
+		WithInvokeMetadata(map[string]string{"tenant": "acme"}),
+		WithInvokeConnection(ConnectionInfo{RemoteAddr: "10.0.0.1:4000", Codec: "application/msgpack"}))
+	require.NoError(t, err, "Request should be processed")
+	assert.Equal(t, "acme", resp.Results["tenant"], "Handler should see the metadata")
+	assert.Equal(t, "application/msgpack", resp.Results["codec"], "Handler should see the connection")
+	assert.Empty(t, srv.Connections(), "Connection should be gone once the request is answered")
+
+	progress := invoker.Progress()
+	require.Len(t, progress, 2, "Every report should be captured")
+	for i, percent := range []float64{25, 75} {
+		assert.Equal(t, core.JobRunning, progress[i].State, "Progress should be reported while running")
+		assert.Equal(t, i+1, progress[i].Sequence, "Reports should be numbered in order")
+		assert.Equal(t, percent, progress[i].Percent, "Report should carry its percentage")
+		assert.Equal(t, "working", progress[i].Message, "Report should carry its message")
+	}
+
+	notifications := invoker.Notifications()
+	require.Len(t, notifications, 1, "Notification should be captured")
+	assert.Equal(t, "test.progress", notifications[0].Method, "Notification should be named")
+	assert.JSONEq(t, `{"requestId":"`+req.ID+`","percent":75}`, string(notifications[0].Params), "Notification should carry its payload")
+
+	// Each Invoke starts a fresh capture
+	_, err = invoker.Invoke(ctx, core.MethodProcessModel, req)
+	require.NoError(t, err, "Request should be processed")
+	assert.Len(t, invoker.Progress(), 2, "Reports of earlier calls should be dropped")
+}
+
+func TestInvokerCapabilities(t *testing.T) {
+	srv := New(WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(&ReportingHandler{srv: srv}), "Handler registration should succeed")
+	invoker := NewTestInvoker(srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// A connection that negotiated neither batches nor streams is refused both
+	plain := WithInvokeConnection(ConnectionInfo{Capabilities: &core.Capabilities{ProtocolVersion: core.ProtocolVersion}})
+	for _, method := range []string{core.MethodProcessModelBatch, core.MethodProcessModelStream} {
+		_, err := invoker.Invoke(ctx, method, testutil.CreateTestModelRequest(), plain)
+		var rpcErr *jsonrpc2.Error
+		require.True(t, errors.As(err, &rpcErr), "%s should be refused", method)
+		assert.Equal(t, core.CodeUnsupportedCapability, rpcErr.Code, "%s should be refused as not negotiated", method)
+		assert.Contains(t, rpcErr.Message, "was not negotiated on the connection", "%s refusal should name the connection", method)
+	}
+
+	// Once negotiated the feature is handled
+	batching := WithInvokeConnection(ConnectionInfo{Capabilities: &core.Capabilities{
+		ProtocolVersion: core.ProtocolVersion,
+		Features:        []core.Feature{core.FeatureBatch},
+	}})
+	_, err := invoker.Invoke(ctx, core.MethodProcessModelBatch, testutil.CreateTestModelRequest(), batching)
+	assert.NoError(t, err, "Negotiated batches should be handled")
+}
+
+func TestInvokerPrincipal(t *testing.T) {
+	srv := New(WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(&WhoAmIHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.RegisterAuthScheme("token", NewStaticTokenVerifier(nil, 0)), "Token scheme registration should succeed")
+	invoker := NewTestInvoker(srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := invoker.Invoke(ctx, core.MethodProcessModel, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Unauthenticated request should be refused")
+	assert.Equal(t, core.CodeUnauthenticated, rpcErr.Code, "Refusal should use CodeUnauthenticated")
+
+	resp, err := invoker.Invoke(ctx, core.MethodProcessModel, testutil.CreateTestModelRequest(),
+		WithInvokePrincipal(core.Principal{ID: "ci"}))
+	require.NoError(t, err, "Authenticated request should be processed")
+	assert.Equal(t, "ci", resp.Results["principal"], "Handler should see the principal")
+}
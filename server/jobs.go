@@ -0,0 +1,337 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// job is an asynchronous model request and what is known of its progress.
+// Every change of its state or progress is recorded as a new snapshot in
+// status.Progress.
+type job struct {
+	status    core.JobStatus
+	cancel    context.CancelCauseFunc
+	cancelled bool
+}
+
+// jobManager runs submitted model requests off the connection and keeps
+// their results in memory until retention has passed since they finished.
+// Expired jobs are discarded whenever the manager is used.
+type jobManager struct {
+	tasks     *core.TaskTracker
+	retention time.Duration
+	slots     chan struct{}          // Bounds running jobs; nil when unbounded
+	publish   func(core.JobProgress) // Announces new progress snapshots; nil discards them
+
+	mu   sync.Mutex
+	jobs map[core.JobID]*job
+}
+
+// newJobManager returns a manager running at most maxConcurrent jobs at once,
+// or any number if maxConcurrent is not positive.
+func newJobManager(tasks *core.TaskTracker, retention time.Duration, maxConcurrent int) *jobManager {
+	m := &jobManager{
+		tasks:     tasks,
+		retention: retention,
+		jobs:      make(map[core.JobID]*job),
+	}
+	if maxConcurrent > 0 {
+		m.slots = make(chan struct{}, maxConcurrent)
+	}
+	return m
+}
+
+// submit starts a job calling run with a context derived from ctx, which
+// cancel cancels and which carries the job's ProgressReporter. Handler errors
+// become an unsuccessful response to req.
+func (m *jobManager) submit(ctx context.Context, req *core.ModelRequest, run func(context.Context) (*core.ModelResponse, error)) core.JobID {
+	now := time.Now()
+	ctx, cancel := withRequestCancel(ctx)
+	j := &job{
+		status: core.JobStatus{JobID: newJobID(), State: core.JobPending, SubmittedAt: now},
+		cancel: cancel,
+	}
+	ctx = context.WithValue(ctx, progressKey{}, jobReporter{m, j})
+
+	m.mu.Lock()
+	m.sweepLocked(now)
+	m.jobs[j.status.JobID] = j
+	m.mu.Unlock()
+
+	m.tasks.Go(core.TaskJobs, func() {
+		defer cancel(nil)
+		if m.slots != nil {
+			select {
+			case m.slots <- struct{}{}:
+				defer func() { <-m.slots }()
+			case <-ctx.Done():
+				m.finish(j, core.ErrorResponse(req, ctx.Err()))
+				return
+			}
+		}
+		if !m.start(j) {
+			m.finish(j, nil)
+			return
+		}
+
+		resp, err := run(ctx)
+		if err != nil {
+			resp = core.ErrorResponse(req, err)
+		}
+		m.finish(j, resp)
+	})
+	return j.status.JobID
+}
+
+// start moves a pending job to running. It reports false if the job was
+// cancelled while it waited.
+func (m *jobManager) start(j *job) bool {
+	m.mu.Lock()
+	if j.cancelled {
+		m.mu.Unlock()
+		return false
+	}
+	j.status.State = core.JobRunning
+	progress := j.recordLocked(nil)
+	m.mu.Unlock()
+
+	m.announce(progress)
+	return true
+}
+
+// finish records the outcome of a job. A job cancelled before its handler
+// returned is reported as cancelled whatever the handler returned.
+func (m *jobManager) finish(j *job, resp *core.ModelResponse) {
+	m.mu.Lock()
+	j.status.FinishedAt = time.Now()
+	var progress core.JobProgress
+	if j.cancelled {
+		j.status.State = core.JobCancelled
+		progress = j.recordLocked(nil)
+	} else {
+		j.status.State = core.JobDone
+		j.status.Response = resp
+		progress = j.recordLocked(func(p *core.JobProgress) { p.Percent = 100 })
+	}
+	m.mu.Unlock()
+
+	m.announce(progress)
+}
+
+// report records progress reported by the handler of a running job.
+func (m *jobManager) report(j *job, percent float64, message string) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("progress %v%% is outside 0-100%%", percent)
+	}
+
+	m.mu.Lock()
+	if j.status.State != core.JobRunning {
+		m.mu.Unlock()
+		return fmt.Errorf("job %s is %s", j.status.JobID, j.status.State)
+	}
+	progress := j.recordLocked(func(p *core.JobProgress) {
+		p.Percent, p.Message = percent, message
+	})
+	m.mu.Unlock()
+
+	m.announce(progress)
+	return nil
+}
+
+// announce passes a progress snapshot to the publish callback.
+func (m *jobManager) announce(progress core.JobProgress) {
+	if m.publish != nil {
+		m.publish(progress)
+	}
+}
+
+// recordLocked stores a new progress snapshot of the job in its current
+// state, carrying over the last reported progress unless update changes it,
+// and returns the snapshot.
+func (j *job) recordLocked(update func(*core.JobProgress)) core.JobProgress {
+	var progress core.JobProgress
+	if j.status.Progress != nil {
+		progress = *j.status.Progress
+		progress.Sequence++
+	}
+	progress.JobID, progress.State, progress.UpdatedAt = j.status.JobID, j.status.State, time.Now()
+	if update != nil {
+		update(&progress)
+	}
+	j.status.Progress = &progress
+	return progress
+}
+
+// status returns the status of the job with id, if it is known.
+func (m *jobManager) status(id core.JobID) (core.JobStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepLocked(time.Now())
+	j, ok := m.jobs[id]
+	if !ok {
+		return core.JobStatus{}, false
+	}
+	return j.status, true
+}
+
+// cancelJob cancels the context of the job with id unless it has finished,
+// and returns its status.
+func (m *jobManager) cancelJob(id core.JobID) (core.JobStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepLocked(time.Now())
+	j, ok := m.jobs[id]
+	if !ok {
+		return core.JobStatus{}, false
+	}
+	if !j.status.State.Finished() && !j.cancelled {
+		j.cancelled = true
+		j.cancel(core.CauseClientCancel)
+	}
+	return j.status, true
+}
+
+// count returns the number of jobs held, running or waiting to expire.
+func (m *jobManager) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepLocked(time.Now())
+	return len(m.jobs)
+}
+
+// sweepLocked discards jobs that finished more than retention before now.
+func (m *jobManager) sweepLocked(now time.Time) {
+	for id, j := range m.jobs {
+		if j.status.State.Finished() && now.Sub(j.status.FinishedAt) >= m.retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// ProgressReporter lets a handler report how far it has got with a request.
+type ProgressReporter interface {
+	// ReportProgress records the share of the work done, from 0 to 100, and
+	// an optional message describing it.
+	ReportProgress(percent float64, message string) error
+}
+
+type progressKey struct{}
+
+// ProgressFromContext returns the ProgressReporter for the request ctx
+// belongs to. For a handler running as a job, the latest report appears in
+// the job's status and is published on its core.JobProgressTopic; elsewhere
+// reports are discarded, so handlers may report progress however they are
+// called.
+func ProgressFromContext(ctx context.Context) ProgressReporter {
+	if reporter, ok := ctx.Value(progressKey{}).(ProgressReporter); ok {
+		return reporter
+	}
+	return nopReporter{}
+}
+
+// jobReporter reports the progress of a job.
+type jobReporter struct {
+	m *jobManager
+	j *job
+}
+
+func (r jobReporter) ReportProgress(percent float64, message string) error {
+	return r.m.report(r.j, percent, message)
+}
+
+// nopReporter discards progress reports.
+type nopReporter struct{}
+
+func (nopReporter) ReportProgress(float64, string) error { return nil }
+
+// publishJobProgress sends a job's progress snapshot to the clients
+// subscribed to its topic.
+func (s *Server) publishJobProgress(progress core.JobProgress) {
+	if err := PublishTopic(s, core.JobProgressTopic(progress.JobID), core.JobProgressUpdates, progress); err != nil {
+		s.options.Logger.Debug("Failed to publish job progress", "job", progress.JobID, core.LogFieldError, err)
+	}
+}
+
+// newJobID returns a random job ID.
+func newJobID() core.JobID {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate job ID: %v", err))
+	}
+	return core.JobID("job-" + hex.EncodeToString(b[:]))
+}
+
+// handleSubmitModel starts a job running the mcp.processModel handler on the
+// request and replies with its ID straight away. The job outlives the
+// connection, keeping the caller's principal and, while it is open, the
+// connection for server.Notify.
+func (h *rpcHandler) handleSubmitModel(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	registered, _, _ := h.server.lookup(core.MethodProcessModel)
+	modelHandler, ok := registered.(ModelHandler)
+	if !ok {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+		return
+	}
+	modelReq, rpcErr := decodeModelRequest(req)
+	if rpcErr != nil {
+		h.replyRPCError(ctx, conn, req, rpcErr)
+		return
+	}
+	if result := h.server.validateRequest(core.MethodProcessModel, modelReq); result != nil {
+		h.replyRPCError(ctx, conn, req, core.InvalidParamsError(result))
+		return
+	}
+
+	jobCtx := context.WithValue(h.server.runContext(), connKey{}, conn)
+	jobCtx = context.WithValue(jobCtx, pendingReplyKey{}, ctx.Value(pendingReplyKey{}))
+	if principal := core.PrincipalFromContext(ctx); principal != nil {
+		jobCtx = core.ContextWithPrincipal(jobCtx, principal)
+	}
+	if peer, ok := core.PeerFromContext(ctx); ok {
+		jobCtx = core.ContextWithPeer(jobCtx, peer)
+	}
+	if session, ok := core.SessionFromContext(ctx); ok {
+		jobCtx = core.ContextWithSession(jobCtx, session)
+	}
+	// The request was validated above, so the job runs only the middleware
+	process := core.ChainMiddleware(core.MethodProcessModel, func(ctx context.Context, modelReq *core.ModelRequest) (*core.ModelResponse, error) {
+		return h.server.processModel(ctx, core.MethodProcessModel, modelHandler, modelReq, modelHandler.ProcessModel)
+	}, h.server.options.Middleware...)
+	process = h.server.recoverPanics(core.MethodProcessModel, process, core.LogFieldRemoteAddr, h.remoteAddr)
+	id := h.server.jobs.submit(jobCtx, modelReq, func(ctx context.Context) (*core.ModelResponse, error) {
+		return withHandlerTimeout(h.server, ctx, core.MethodSubmitModel, modelReq.ID, func(ctx context.Context) (*core.ModelResponse, error) {
+			return process(ctx, modelReq)
+		})
+	})
+	h.reply(ctx, conn, req, core.SubmitModelResponse{JobID: id})
+}
+
+// handleJobRequest answers mcp.jobStatus and mcp.cancelJob.
+func (h *rpcHandler) handleJobRequest(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	var jobReq core.JobRequest
+	if req.Params == nil || json.Unmarshal(*req.Params, &jobReq) != nil || jobReq.JobID == "" {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing job ID")
+		return
+	}
+
+	var status core.JobStatus
+	var ok bool
+	if req.Method == core.MethodCancelJob {
+		status, ok = h.server.jobs.cancelJob(jobReq.JobID)
+	} else {
+		status, ok = h.server.jobs.status(jobReq.JobID)
+	}
+	if !ok {
+		h.replyError(ctx, conn, req, core.CodeJobNotFound, fmt.Sprintf("job not found: %s", jobReq.JobID))
+		return
+	}
+	h.reply(ctx, conn, req, status)
+}
@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startJobServer starts a server with handler and the given options and
+// returns it with a client polling jobs every 10ms
+func startJobServer(t *testing.T, handler Handler, options ...Option) (*Server, *client.Client) {
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false),
+				client.WithJobPollInterval(10*time.Millisecond))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(append([]Option{WithTransport(transport)}, options...)...)
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return srv, c
+}
+
+func TestSubmitModelCompletes(t *testing.T) {
+	_, c := startJobServer(t, &SlowModelHandler{delay: 100 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	id, err := c.SubmitModel(ctx, req)
+	require.NoError(t, err, "Submitting should succeed")
+	require.NotEmpty(t, id, "Submitting should return a job ID")
+
+	status, err := c.JobStatus(ctx, id)
+	require.NoError(t, err, "Status of a new job should be available")
+	assert.Contains(t, []core.JobState{core.JobPending, core.JobRunning}, status.State, "Slow job should not be finished yet")
+	assert.Nil(t, status.Response, "Unfinished job should have no response")
+
+	status, err = c.WaitForJob(ctx, id)
+	require.NoError(t, err, "Waiting for the job should succeed")
+	assert.Equal(t, core.JobDone, status.State, "Job should be done")
+	require.NotNil(t, status.Response, "Done job should carry its response")
+	assert.Equal(t, req.ID, status.Response.ID, "Response should answer the submitted request")
+	assert.Equal(t, "processed after delay", status.Response.Results["status"], "Response should come from the handler")
+	assert.False(t, status.FinishedAt.Before(status.SubmittedAt), "Job should finish after it was submitted")
+}
+
+func TestCancelJob(t *testing.T) {
+	handler := newGatedModelHandler()
+	_, c := startJobServer(t, handler)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	<-handler.started
+
+	status, err := c.JobStatus(ctx, id)
+	require.NoError(t, err, "Status of a running job should be available")
+	assert.Equal(t, core.JobRunning, status.State, "Job should be running in its handler")
+
+	_, err = c.CancelJob(ctx, id)
+	require.NoError(t, err, "Cancelling should succeed")
+	status, err = c.WaitForJob(ctx, id)
+	require.NoError(t, err, "Waiting for the cancelled job should succeed")
+	assert.Equal(t, core.JobCancelled, status.State, "Handler context should be cancelled")
+	assert.Nil(t, status.Response, "Cancelled job should have no response")
+
+	status, err = c.CancelJob(ctx, id)
+	require.NoError(t, err, "Cancelling a finished job should succeed")
+	assert.Equal(t, core.JobCancelled, status.State, "Cancelling again should change nothing")
+}
+
+func TestMaxConcurrentJobs(t *testing.T) {
+	handler := newGatedModelHandler()
+	_, c := startJobServer(t, handler, WithMaxConcurrentJobs(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	<-handler.started
+	second, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting beyond the limit should succeed")
+
+	status, err := c.JobStatus(ctx, second)
+	require.NoError(t, err, "Status of a pending job should be available")
+	assert.Equal(t, core.JobPending, status.State, "Job beyond the limit should wait")
+
+	// A pending job can be cancelled before it starts
+	_, err = c.CancelJob(ctx, second)
+	require.NoError(t, err, "Cancelling a pending job should succeed")
+	close(handler.release)
+
+	status, err = c.WaitForJob(ctx, first)
+	require.NoError(t, err, "Waiting for the first job should succeed")
+	assert.Equal(t, core.JobDone, status.State, "First job should be done")
+	status, err = c.WaitForJob(ctx, second)
+	require.NoError(t, err, "Waiting for the second job should succeed")
+	assert.Equal(t, core.JobCancelled, status.State, "Pending job should be cancelled")
+}
+
+func TestJobRetention(t *testing.T) {
+	srv, c := startJobServer(t, NewDefaultModelHandler(), WithJobRetention(50*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	status, err := c.WaitForJob(ctx, id)
+	require.NoError(t, err, "Waiting for the job should succeed")
+	assert.Equal(t, core.JobDone, status.State, "Job should be done")
+	assert.Equal(t, 1, srv.Stats().Jobs, "Finished job should be kept for its retention")
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, srv.Stats().Jobs, "Expired job should be discarded")
+
+	_, err = c.JobStatus(ctx, id)
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Expired job should be unknown")
+	assert.Equal(t, core.CodeJobNotFound, rpcErr.Code, "Error should report the job was not found")
+}
+
+func TestSubmitModelValidation(t *testing.T) {
+	handler := testutil.NewSchemaHandler(&core.Schema{Type: "object", Required: []string{"name"}})
+	_, c := startJobServer(t, handler)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.SubmitModel(ctx, core.NewModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Invalid submission should be refused")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Submission should be validated like mcp.processModel")
+	assert.Zero(t, handler.Calls(), "Invalid submission should not reach the handler")
+}
+
+// SteppedJobHandler reports each progress value sent on steps, acknowledging
+// it on reported, and answers once steps is closed
+type SteppedJobHandler struct {
+	steps    chan float64
+	reported chan float64
+}
+
+func newSteppedJobHandler() *SteppedJobHandler {
+	return &SteppedJobHandler{steps: make(chan float64), reported: make(chan float64, 16)}
+}
+
+func (h *SteppedJobHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *SteppedJobHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	for percent := range h.steps {
+		if err := ProgressFromContext(ctx).ReportProgress(percent, fmt.Sprintf("step %v", percent)); err != nil {
+			return nil, err
+		}
+		h.reported <- percent
+	}
+	return core.NewModelResponse(req), nil
+}
+
+// step makes the handler report percent and waits until it has
+func (h *SteppedJobHandler) step(t *testing.T, percent float64) {
+	h.steps <- percent
+	assert.Equal(t, percent, receive(t, h.reported, "progress report"), "Handler should report the step")
+}
+
+// startWatchServer starts a server with handler for several clients and returns its transport
+func startWatchServer(t *testing.T, handler Handler) (*Server, core.Transport) {
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport))
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+	return srv, transport
+}
+
+func startWatchClient(t *testing.T, transport core.Transport, options ...client.Option) *client.Client {
+	c := client.New(append([]client.Option{client.WithTransport(transport), client.WithAutoReconnect(false)}, options...)...)
+	require.NoError(t, c.Start(), "Client should connect")
+	t.Cleanup(func() { c.Stop() })
+	return c
+}
+
+// awaitProgress reads events until one reports percent
+func awaitProgress(t *testing.T, events <-chan client.JobEvent, percent float64) client.JobEvent {
+	for {
+		event, ok := <-events
+		require.True(t, ok, "Watch should not end before reporting %v%%", percent)
+		require.NoError(t, event.Err, "Watch should not fail")
+		require.Nil(t, event.Status, "Job should not finish before reporting %v%%", percent)
+		if event.Progress.Percent == percent {
+			return event
+		}
+	}
+}
+
+// awaitFinal reads events until the final one and checks the channel is closed after it
+func awaitFinal(t *testing.T, events <-chan client.JobEvent) client.JobEvent {
+	for {
+		event := receive(t, events, "job event")
+		if event.Status != nil || event.Err != nil {
+			_, open := <-events
+			assert.False(t, open, "Channel should close after the final event")
+			return event
+		}
+	}
+}
+
+func TestWatchJobFromAnotherClient(t *testing.T) {
+	handler := newSteppedJobHandler()
+	srv, transport := startWatchServer(t, handler)
+	submitter, watcher := startWatchClient(t, transport), startWatchClient(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	id, err := submitter.SubmitModel(ctx, req)
+	require.NoError(t, err, "Submitting should succeed")
+	events, err := watcher.WatchJob(ctx, id)
+	require.NoError(t, err, "Watching a running job should succeed")
+
+	assert.Eventually(t, func() bool {
+		for _, info := range srv.Connections() {
+			for _, topic := range info.Topics {
+				if topic == core.JobProgressTopic(id) {
+					return true
+				}
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond, "Watcher should be subscribed to the job's topic")
+
+	handler.step(t, 25)
+	event := awaitProgress(t, events, 25)
+	assert.Equal(t, id, event.Progress.JobID, "Progress should name the job")
+	assert.Equal(t, core.JobRunning, event.Progress.State, "Job should be running")
+	assert.Equal(t, "step 25", event.Progress.Message, "Progress should carry the handler's message")
+
+	status, err := submitter.JobStatus(ctx, id)
+	require.NoError(t, err, "Status should be available")
+	require.NotNil(t, status.Progress, "Status should carry the latest progress")
+	assert.Equal(t, 25.0, status.Progress.Percent, "Status should carry the reported percent")
+
+	handler.step(t, 80)
+	awaitProgress(t, events, 80)
+	close(handler.steps)
+
+	final := awaitFinal(t, events)
+	require.NoError(t, final.Err, "Watch should end with the result")
+	assert.Equal(t, core.JobDone, final.Status.State, "Job should be done")
+	require.NotNil(t, final.Status.Response, "Final status should carry the response")
+	assert.Equal(t, req.ID, final.Status.Response.ID, "Response should answer the submitted request")
+	assert.Equal(t, 100.0, final.Progress.Percent, "Done job should report all work done")
+}
+
+func TestWatchJobReconnect(t *testing.T) {
+	handler := newSteppedJobHandler()
+	srv, transport := startWatchServer(t, handler)
+	submitter := startWatchClient(t, transport)
+	watcher := startWatchClient(t, transport, client.WithAutoReconnect(true),
+		client.WithReconnectDelay(200*time.Millisecond), client.WithMaxReconnectAttempts(20))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, err := submitter.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	events, err := watcher.WatchJob(ctx, id)
+	require.NoError(t, err, "Watching should succeed")
+	handler.step(t, 10)
+	awaitProgress(t, events, 10)
+
+	// Drop every connection; progress made meanwhile is only recorded
+	srv.connsMu.Lock()
+	for h := range srv.sessions {
+		h.closer.Close()
+	}
+	srv.connsMu.Unlock()
+	require.Eventually(t, func() bool { return !watcher.IsConnected() }, 2*time.Second, 10*time.Millisecond, "Watcher should notice the disconnect")
+	handler.step(t, 60)
+
+	event := awaitProgress(t, events, 60)
+	assert.Equal(t, "step 60", event.Progress.Message, "Watch should resume from the recorded progress")
+	assert.Equal(t, uint64(2), watcher.Stats().Connections, "Watcher should have reconnected")
+
+	handler.step(t, 90)
+	awaitProgress(t, events, 90)
+	close(handler.steps)
+
+	final := awaitFinal(t, events)
+	require.NoError(t, final.Err, "Watch should end with the result")
+	assert.Equal(t, core.JobDone, final.Status.State, "Job should be done")
+}
+
+func TestWatchJobFinishedOrUnknown(t *testing.T) {
+	srv, transport := startWatchServer(t, NewDefaultModelHandler())
+	c := startWatchClient(t, transport, client.WithJobPollInterval(10*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	_, err = c.WaitForJob(ctx, id)
+	require.NoError(t, err, "Waiting for the job should succeed")
+
+	events, err := c.WatchJob(ctx, id)
+	require.NoError(t, err, "Watching a finished job should succeed")
+	final := awaitFinal(t, events)
+	require.NotNil(t, final.Status, "Finished job should yield its final status straight away")
+	assert.Equal(t, core.JobDone, final.Status.State, "Job should be done")
+
+	_, err = c.WatchJob(ctx, "job-unknown")
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Watching an unknown job should fail")
+	assert.Equal(t, core.CodeJobNotFound, rpcErr.Code, "Error should report the job was not found")
+
+	assert.Eventually(t, func() bool {
+		infos := srv.Connections()
+		return len(infos) == 1 && len(infos[0].Topics) == 0
+	}, 2*time.Second, 10*time.Millisecond, "Ended watches should unsubscribe")
+}
+
+func TestProgressFromContext(t *testing.T) {
+	assert.NoError(t, ProgressFromContext(context.Background()).ReportProgress(50, ""), "Reports outside a job should be discarded")
+
+	m := newJobManager(core.NewTaskTracker(core.DefaultLogger(), nil), time.Minute, 0)
+	var published []core.JobProgress
+	m.publish = func(p core.JobProgress) { published = append(published, p) }
+
+	j := &job{status: core.JobStatus{JobID: "job-1", State: core.JobPending}}
+	assert.Error(t, m.report(j, 10, ""), "Pending jobs should not report progress")
+	require.True(t, m.start(j), "Job should start")
+	assert.NoError(t, m.report(j, 10, "warming up"), "Running jobs should report progress")
+	assert.Error(t, m.report(j, 120, ""), "Progress beyond 100% should be refused")
+	m.finish(j, core.NewModelResponse(core.NewModelRequest()))
+
+	require.Len(t, published, 3, "Start, report and finish should each publish a snapshot")
+	for i, p := range published {
+		assert.Equal(t, i, p.Sequence, "Snapshots should be numbered in order")
+	}
+	assert.Equal(t, "warming up", published[2].Message, "Final snapshot should keep the last message")
+	assert.Equal(t, core.JobDone, published[2].State, "Final snapshot should report the job done")
+}
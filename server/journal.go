@@ -0,0 +1,374 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// JournalSyncPolicy controls when journal writes are flushed to stable storage.
+type JournalSyncPolicy string
+
+const (
+	// JournalSyncAlways fsyncs after every entry, so an accepted request
+	// survives a machine crash. This is the default.
+	JournalSyncAlways JournalSyncPolicy = "always"
+
+	// JournalSyncNever leaves flushing to the operating system. Entries
+	// survive a process crash but may be lost if the machine goes down.
+	JournalSyncNever JournalSyncPolicy = "never"
+)
+
+// JournalEntry describes a request that was accepted for processing.
+type JournalEntry struct {
+	Seq         uint64          `json:"seq"`                   // Position in the journal, unique across restarts
+	RequestID   string          `json:"requestId"`             // JSON-RPC ID assigned by the client
+	Method      string          `json:"method"`                // Method that was called
+	Principal   string          `json:"principal,omitempty"`   // Authenticated caller, if any
+	Received    time.Time       `json:"received"`              // When the server accepted the request
+	PayloadHash string          `json:"payloadHash,omitempty"` // SHA-256 of the params, unless Payload is kept
+	Payload     json.RawMessage `json:"payload,omitempty"`     // Full params when JournalPayloads is enabled
+}
+
+// journalRecord is a line in a journal segment. A begin record carries the
+// entry; an end record marks the request with the same Seq as replied to.
+type journalRecord struct {
+	Op string `json:"op"`
+	JournalEntry
+}
+
+const (
+	journalBegin = "begin"
+	journalEnd   = "end"
+)
+
+// journalSeqKey is the context key holding a request's journal sequence number.
+type journalSeqKey struct{}
+
+// journalSegment is one file of the journal.
+type journalSegment struct {
+	index   int
+	file    *os.File
+	size    int64
+	pending int // Entries begun in this segment and not yet ended
+}
+
+// journal is a write-ahead log of accepted requests, split into segments that
+// are removed once every request begun in them has been replied to. End
+// records land in the current segment, so a segment is only removed together
+// with all older ones.
+type journal struct {
+	mu       sync.Mutex
+	dir      string
+	sync     JournalSyncPolicy
+	maxSize  int64
+	payloads bool
+	seq      uint64
+	current  *journalSegment
+	previous []*journalSegment          // Rotated segments still on disk, oldest first
+	segments map[uint64]*journalSegment // Pending entries by sequence number
+	closed   bool
+}
+
+// openJournal scans the segments in dir for requests that were begun but never
+// ended, removes the old segments and starts a fresh one. The unfinished
+// entries are returned in the order they were accepted.
+func openJournal(dir string, policy JournalSyncPolicy, maxSize int64, payloads bool) (*journal, []JournalEntry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "journal-*.log"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+
+	j := &journal{
+		dir:      dir,
+		sync:     policy,
+		maxSize:  maxSize,
+		payloads: payloads,
+		segments: make(map[uint64]*journalSegment),
+	}
+
+	pending := make(map[uint64]JournalEntry)
+	lastIndex := 0
+	for _, path := range paths {
+		var index int
+		if _, err := fmt.Sscanf(filepath.Base(path), "journal-%d.log", &index); err == nil && index > lastIndex {
+			lastIndex = index
+		}
+		if err := j.scan(path, pending); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	recovered := make([]JournalEntry, 0, len(pending))
+	for _, entry := range pending {
+		recovered = append(recovered, entry)
+	}
+	sort.Slice(recovered, func(a, b int) bool { return recovered[a].Seq < recovered[b].Seq })
+
+	// Unfinished entries are handed to the caller, so start from a clean slate
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			return nil, nil, fmt.Errorf("failed to remove journal segment: %w", err)
+		}
+	}
+	if err := j.rotate(lastIndex + 1); err != nil {
+		return nil, nil, err
+	}
+	return j, recovered, nil
+}
+
+// scan replays the records in a segment into pending. A torn final line left
+// by a crash mid-write is ignored.
+func (j *journal) scan(path string, pending map[uint64]JournalEntry) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open journal segment: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.Seq > j.seq {
+			j.seq = record.Seq
+		}
+		switch record.Op {
+		case journalBegin:
+			pending[record.Seq] = record.JournalEntry
+		case journalEnd:
+			delete(pending, record.Seq)
+		}
+	}
+	return scanner.Err()
+}
+
+// begin appends an entry for req and returns its sequence number.
+func (j *journal) begin(ctx context.Context, req *jsonrpc2.Request) (uint64, error) {
+	entry := JournalEntry{
+		RequestID: req.ID.String(),
+		Method:    req.Method,
+		Received:  time.Now().UTC(),
+	}
+	if principal := core.PrincipalFromContext(ctx); principal != nil {
+		entry.Principal = principal.ID
+	}
+	if req.Params != nil {
+		if j.payloads {
+			entry.Payload = *req.Params
+		} else {
+			sum := sha256.Sum256(*req.Params)
+			entry.PayloadHash = hex.EncodeToString(sum[:])
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return 0, os.ErrClosed
+	}
+	if j.maxSize > 0 && j.current.size >= j.maxSize {
+		if err := j.rotate(j.current.index + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	j.seq++
+	entry.Seq = j.seq
+	if err := j.write(journalRecord{Op: journalBegin, JournalEntry: entry}); err != nil {
+		return 0, err
+	}
+	j.current.pending++
+	j.segments[entry.Seq] = j.current
+	return entry.Seq, nil
+}
+
+// complete marks the entry with sequence number seq as replied to. Completing
+// after the journal is closed is a no-op, so the entry is recovered on restart.
+func (j *journal) complete(seq uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	segment, ok := j.segments[seq]
+	if j.closed || !ok {
+		return nil
+	}
+	delete(j.segments, seq)
+
+	if err := j.write(journalRecord{Op: journalEnd, JournalEntry: JournalEntry{Seq: seq}}); err != nil {
+		return err
+	}
+	segment.pending--
+	return j.prune()
+}
+
+// prune removes rotated segments, oldest first, until one still has pending entries.
+// The caller must hold j.mu.
+func (j *journal) prune() error {
+	for len(j.previous) > 0 && j.previous[0].pending == 0 {
+		if err := os.Remove(j.previous[0].file.Name()); err != nil {
+			return fmt.Errorf("failed to remove journal segment: %w", err)
+		}
+		j.previous = j.previous[1:]
+	}
+	return nil
+}
+
+// rotate closes the current segment and starts segment index.
+// The caller must hold j.mu or be the only user of j.
+func (j *journal) rotate(index int) error {
+	path := filepath.Join(j.dir, fmt.Sprintf("journal-%06d.log", index))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create journal segment: %w", err)
+	}
+
+	if previous := j.current; previous != nil {
+		previous.file.Close()
+		j.previous = append(j.previous, previous)
+	}
+	j.current = &journalSegment{index: index, file: file}
+	return j.prune()
+}
+
+// write appends a record to the current segment. The caller must hold j.mu.
+func (j *journal) write(record journalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	n, err := j.current.file.Write(line)
+	j.current.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	if j.sync == JournalSyncAlways {
+		return j.current.file.Sync()
+	}
+	return nil
+}
+
+// close closes the current segment. Entries still pending remain on disk.
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return nil
+	}
+	j.closed = true
+	return j.current.file.Close()
+}
+
+// journalRequest records req in the journal before dispatch and returns a
+// context carrying its sequence number for completeJournal. While the journal
+// is degraded, requests are served without being journaled.
+func (h *rpcHandler) journalRequest(ctx context.Context, req *jsonrpc2.Request) context.Context {
+	j := h.server.currentJournal()
+	if j == nil {
+		return ctx
+	}
+	var seq uint64
+	h.server.sinks.Do(sinkJournal, func() (err error) {
+		seq, err = j.begin(ctx, req)
+		return err
+	})
+	if seq == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, journalSeqKey{}, seq)
+}
+
+// completeJournal marks the request journaled in ctx as replied to.
+func (h *rpcHandler) completeJournal(ctx context.Context, req *jsonrpc2.Request) {
+	j := h.server.currentJournal()
+	seq, ok := ctx.Value(journalSeqKey{}).(uint64)
+	if j == nil || !ok {
+		return
+	}
+	h.server.sinks.Do(sinkJournal, func() error { return j.complete(seq) })
+}
+
+// RecoveredRequests returns the requests that a previous run accepted but did
+// not reply to, as found in the journal when the server started.
+func (s *Server) RecoveredRequests() []JournalEntry {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return append([]JournalEntry(nil), s.recovered...)
+}
+
+// OnRecovery registers a callback invoked during Start for every request a
+// previous run accepted but did not reply to. Each entry is reported once:
+// the journal forgets it after Start, so a decision to re-enqueue the work
+// must be made, or persisted elsewhere, by the callback.
+func (s *Server) OnRecovery(callback func(JournalEntry)) {
+	s.recoveryCallbacks = append(s.recoveryCallbacks, callback)
+}
+
+// openJournal opens the configured journal and reports unfinished requests.
+func (s *Server) openJournal() error {
+	j, recovered, err := openJournal(s.options.JournalDir, s.options.JournalSync, s.options.JournalMaxSize, s.options.JournalPayloads)
+	if err != nil {
+		return err
+	}
+
+	s.statusMu.Lock()
+	s.journal = j
+	s.recovered = recovered
+	s.statusMu.Unlock()
+
+	for _, entry := range recovered {
+		s.options.Logger.Warn("Recovered unfinished request",
+			core.LogFieldMethod, entry.Method,
+			core.LogFieldRequestID, entry.RequestID,
+			"seq", entry.Seq)
+		for _, callback := range s.recoveryCallbacks {
+			callback(entry)
+		}
+	}
+	return nil
+}
+
+// currentJournal returns the open journal, or nil when journaling is disabled.
+func (s *Server) currentJournal() *journal {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.journal
+}
+
+// closeJournal closes the journal, if one is open.
+func (s *Server) closeJournal() {
+	s.statusMu.Lock()
+	j := s.journal
+	s.journal = nil
+	s.statusMu.Unlock()
+
+	if j == nil {
+		return
+	}
+	if err := j.close(); err != nil {
+		s.options.Logger.Error("Failed to close journal", core.LogFieldError, err)
+	}
+}
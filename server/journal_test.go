@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// StuckModelHandler never finishes requests asking it to block until released,
+// standing in for a server that crashes mid-request
+type StuckModelHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newStuckModelHandler(t *testing.T) *StuckModelHandler {
+	h := &StuckModelHandler{started: make(chan struct{}, 1), release: make(chan struct{})}
+	t.Cleanup(func() { close(h.release) })
+	return h
+}
+
+func (h *StuckModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *StuckModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if req.ModelData["block"] == true {
+		h.started <- struct{}{}
+		<-h.release
+	}
+	return core.NewModelResponse(req), nil
+}
+
+// restartServer starts a fresh server on the journal in dir and returns the
+// entries it reports as recovered.
+func restartServer(t *testing.T, dir string, options ...Option) []JournalEntry {
+	srv := New(append([]Option{WithTransport(core.NewInProcessTransport()), WithJournal(dir)}, options...)...)
+
+	var reported []JournalEntry
+	srv.OnRecovery(func(entry JournalEntry) {
+		reported = append(reported, entry)
+	})
+	require.NoError(t, srv.Start(), "Server should start on the journal")
+	defer srv.Stop()
+
+	assert.Equal(t, reported, srv.RecoveredRequests(), "RecoveredRequests should match the callbacks")
+	return reported
+}
+
+// crashMidRequest serves one completed and one unfinished request on the journal
+// in dir, then stops the server before the unfinished one is replied to.
+func crashMidRequest(t *testing.T, dir string, options ...Option) *core.ModelRequest {
+	handler := newStuckModelHandler(t)
+	srv, c := startServerWithHandler(t, handler, append([]Option{WithJournal(dir)}, options...)...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Completed request should succeed")
+
+	stuck := testutil.CreateTestModelRequest()
+	stuck.ModelData["block"] = true
+	go c.ProcessModel(ctx, stuck)
+	<-handler.started
+
+	require.NoError(t, srv.Stop(), "Server should stop")
+	return stuck
+}
+
+func TestJournalRecoversUnfinishedRequest(t *testing.T) {
+	dir := t.TempDir()
+	crashMidRequest(t, dir)
+
+	recovered := restartServer(t, dir)
+	require.Len(t, recovered, 1, "Only the unfinished request should be recovered")
+	entry := recovered[0]
+	assert.Equal(t, core.MethodProcessModel, entry.Method, "Entry should record the method")
+	assert.NotEmpty(t, entry.RequestID, "Entry should record the request ID")
+	assert.WithinDuration(t, time.Now(), entry.Received, 5*time.Second, "Entry should record when it was received")
+	assert.Len(t, entry.PayloadHash, 64, "Entry should carry a SHA-256 payload hash by default")
+	assert.Empty(t, entry.Payload, "Payload should not be kept by default")
+
+	// A second restart does not report the request again
+	assert.Empty(t, restartServer(t, dir), "Recovered requests should be reported exactly once")
+}
+
+func TestJournalPayloads(t *testing.T) {
+	dir := t.TempDir()
+	stuck := crashMidRequest(t, dir, WithJournalPayloads(true))
+
+	recovered := restartServer(t, dir)
+	require.Len(t, recovered, 1, "Unfinished request should be recovered")
+
+	var req core.ModelRequest
+	require.NoError(t, json.Unmarshal(recovered[0].Payload, &req), "Payload should be the request params")
+	assert.Equal(t, stuck.ID, req.ID, "Payload should allow re-enqueueing the request")
+	assert.Empty(t, recovered[0].PayloadHash, "Hash should not be stored alongside the payload")
+}
+
+func TestJournalRecordsPrincipal(t *testing.T) {
+	dir := t.TempDir()
+	j, _, err := openJournal(dir, JournalSyncNever, 0, false)
+	require.NoError(t, err, "Journal should open")
+
+	ctx := core.ContextWithPrincipal(context.Background(), &core.Principal{ID: "alice"})
+	_, err = j.begin(ctx, journalTestRequest(1))
+	require.NoError(t, err, "Entry should be journaled")
+	require.NoError(t, j.close(), "Journal should close")
+
+	_, recovered, err := openJournal(dir, JournalSyncNever, 0, false)
+	require.NoError(t, err, "Journal should reopen")
+	require.Len(t, recovered, 1, "Entry should be recovered")
+	assert.Equal(t, "alice", recovered[0].Principal, "Entry should record the principal")
+}
+
+func journalTestRequest(id uint64) *jsonrpc2.Request {
+	params := json.RawMessage(`{"id":"request"}`)
+	return &jsonrpc2.Request{Method: core.MethodProcessModel, ID: jsonrpc2.ID{Num: id}, Params: &params}
+}
+
+func journalSegments(t *testing.T, dir string) []string {
+	paths, err := filepath.Glob(filepath.Join(dir, "journal-*.log"))
+	require.NoError(t, err, "Glob should succeed")
+	return paths
+}
+
+func TestJournalRotation(t *testing.T) {
+	dir := t.TempDir()
+	j, _, err := openJournal(dir, JournalSyncNever, 256, false)
+	require.NoError(t, err, "Journal should open")
+
+	// The first entry stays pending and pins its segment
+	first, err := j.begin(context.Background(), journalTestRequest(1))
+	require.NoError(t, err, "Entry should be journaled")
+
+	var seqs []uint64
+	for i := uint64(2); i <= 20; i++ {
+		seq, err := j.begin(context.Background(), journalTestRequest(i))
+		require.NoError(t, err, "Entry should be journaled")
+		seqs = append(seqs, seq)
+	}
+	assert.Greater(t, len(journalSegments(t, dir)), 2, "Journal should rotate into several segments")
+
+	for _, seq := range seqs {
+		require.NoError(t, j.complete(seq), "Entry should complete")
+	}
+	assert.Greater(t, len(journalSegments(t, dir)), 1, "Segments after a pending entry should be kept")
+
+	require.NoError(t, j.complete(first), "Entry should complete")
+	for i := uint64(21); i <= 30; i++ {
+		seq, err := j.begin(context.Background(), journalTestRequest(i))
+		require.NoError(t, err, "Entry should be journaled")
+		require.NoError(t, j.complete(seq), "Entry should complete")
+	}
+	assert.Len(t, journalSegments(t, dir), 1, "Completed segments should be removed")
+	require.NoError(t, j.close(), "Journal should close")
+
+	_, recovered, err := openJournal(dir, JournalSyncNever, 256, false)
+	require.NoError(t, err, "Journal should reopen")
+	assert.Empty(t, recovered, "Nothing should be recovered after every entry completed")
+}
+
+func TestJournalIgnoresTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	j, _, err := openJournal(dir, JournalSyncAlways, 0, false)
+	require.NoError(t, err, "Journal should open")
+	seq, err := j.begin(context.Background(), journalTestRequest(1))
+	require.NoError(t, err, "Entry should be journaled")
+	require.NoError(t, j.close(), "Journal should close")
+
+	// A crash mid-write leaves half a record behind
+	segments := journalSegments(t, dir)
+	require.Len(t, segments, 1, "Journal should have one segment")
+	file, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err, "Segment should open")
+	_, err = file.WriteString(`{"op":"end","se`)
+	require.NoError(t, err, "Torn record should be written")
+	file.Close()
+
+	j, recovered, err := openJournal(dir, JournalSyncAlways, 0, false)
+	require.NoError(t, err, "Torn record should not prevent opening")
+	require.Len(t, recovered, 1, "Begun entry should be recovered")
+	assert.Equal(t, seq, recovered[0].Seq, "Recovered entry should keep its sequence number")
+
+	// Sequence numbers keep increasing across restarts
+	next, err := j.begin(context.Background(), journalTestRequest(2))
+	require.NoError(t, err, "Entry should be journaled")
+	assert.Greater(t, next, seq, "Sequence numbers should not be reused")
+	j.close()
+}
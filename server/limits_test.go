@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startEchoPair starts an echo server and a client with the given options
+func startEchoPair(t *testing.T, serverOptions []Option, clientOptions ...client.Option) (*Server, *client.Client) {
+	transport := core.NewInProcessTransport()
+	srv := New(append([]Option{WithTransport(transport), WithLogger(core.NopLogger())}, serverOptions...)...)
+	require.NoError(t, srv.RegisterHandler(&EchoModelHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	t.Cleanup(func() { srv.Stop() })
+
+	c := client.New(append([]client.Option{
+		client.WithTransport(transport),
+		client.WithLogger(core.NopLogger()),
+		client.WithAutoReconnect(false),
+	}, clientOptions...)...)
+	require.NoError(t, c.Start(), "Client should connect")
+	t.Cleanup(func() { c.Stop() })
+	return srv, c
+}
+
+// echo sends a payload of size bytes and returns the error of the call
+func echo(ctx context.Context, c *client.Client, size int) error {
+	req := core.NewModelRequest()
+	req.ModelData["payload"] = strings.Repeat("p", size)
+	_, err := c.ProcessModel(ctx, req)
+	return err
+}
+
+// requireTooLarge asserts err refuses a message over limit
+func requireTooLarge(t *testing.T, err error, limit int64) {
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Oversized message should be refused with a JSON-RPC error")
+	assert.Equal(t, core.CodeRequestTooLarge, rpcErr.Code, "Refusal should use CodeRequestTooLarge")
+	require.NotNil(t, rpcErr.Data, "Refusal should carry the size and limit")
+	var data core.RequestTooLargeData
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &data), "Size and limit should decode")
+	assert.Equal(t, limit, data.Limit, "Refusal should report the limit")
+	assert.Greater(t, data.Size, limit, "Refusal should report the declared size")
+}
+
+func TestMaxRequestBytes(t *testing.T) {
+	const limit = 64 << 10
+	srv, c := startEchoPair(t, []Option{WithMaxRequestBytes(limit)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, echo(ctx, c, limit-1<<10), "Request just under the limit should succeed")
+	requireTooLarge(t, echo(ctx, c, limit+1<<10), limit)
+
+	// The connection survives the refusal
+	assert.True(t, c.IsConnected(), "Client should stay connected")
+	assert.NoError(t, echo(ctx, c, 16), "Requests after a refused one should succeed")
+	assert.Equal(t, 1, srv.Stats().Sessions, "Server should keep the session")
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	const limit = 32 << 10
+	_, c := startEchoPair(t, nil, client.WithMaxResponseBytes(limit))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	assert.NoError(t, echo(ctx, c, limit-1<<10), "Response just under the limit should be read")
+	requireTooLarge(t, echo(ctx, c, limit+1<<10), limit)
+
+	assert.True(t, c.IsConnected(), "Client should stay connected")
+	assert.NoError(t, echo(ctx, c, 16), "Calls after a refused response should succeed")
+}
+
+func TestMaxRequestBytesDisabled(t *testing.T) {
+	_, c := startEchoPair(t, []Option{WithMaxRequestBytes(0)}, client.WithMaxResponseBytes(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.NoError(t, echo(ctx, c, 1<<20), "Without limits any size should be accepted")
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// unixAddrPrefix marks an entry of ListenAddrs as the path of a unix domain
+// socket.
+const unixAddrPrefix = "unix:"
+
+// listenAddrs returns the addresses Start listens on. An inherited listener
+// stands in for the first of them, and the rest are not opened.
+func (s *Server) listenAddrs() []string {
+	addrs := s.options.ListenAddrs
+	if len(addrs) == 0 {
+		addrs = []string{net.JoinHostPort(s.options.Host, strconv.Itoa(s.options.Port))}
+	}
+	if s.options.InheritedListenerFD != 0 {
+		return addrs[:1]
+	}
+	return addrs
+}
+
+// transportFor returns the transport addr is listened on.
+func (s *Server) transportFor(addr string) core.Transport {
+	if path, ok := strings.CutPrefix(addr, unixAddrPrefix); ok {
+		return core.UnixTransport{Path: path}
+	}
+	return s.options.Transport
+}
+
+// openListeners opens a listener on each of the configured addresses, wrapped
+// in TLS if it is enabled, and returns them along with the first listener
+// unwrapped, for ListenerFile. If any address cannot be listened on, the
+// listeners already opened are closed again.
+func (s *Server) openListeners() ([]net.Listener, net.Listener, error) {
+	var config *tls.Config
+	if s.options.EnableTLS {
+		cert, err := tls.LoadX509KeyPair(s.options.CertificatePath, s.options.CertificateKeyPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		config = &tls.Config{
+			Certificates:           []tls.Certificate{cert},
+			SessionTicketsDisabled: !s.options.TLSSessionTickets,
+		}
+	}
+
+	var listeners []net.Listener
+	var raw net.Listener
+	for _, addr := range s.listenAddrs() {
+		listener, err := s.listen(addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		if raw == nil {
+			raw = listener
+		}
+
+		// Terminate TLS before anything else reads from the connection
+		if config != nil {
+			listener = tls.NewListener(listener, config)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, raw, nil
+}
+
+// Addrs returns the addresses the server listens on, in the order they were
+// configured, with the ports actually bound for those given port 0. It
+// returns nil if the server is not running.
+func (s *Server) Addrs() []net.Addr {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	if s.status != core.StatusRunning {
+		return nil
+	}
+	addrs := make([]net.Addr, len(s.listeners))
+	for i, listener := range s.listeners {
+		addrs[i] = listener.Addr()
+	}
+	return addrs
+}
+
+// Addr returns the address of the server's first listener, with the port
+// actually bound if it was given port 0, or nil if the server is not running.
+// Clients can be pointed at it with client.WithServerAddr(srv.Addr().String()).
+func (s *Server) Addr() net.Addr {
+	addrs := s.Addrs()
+	if len(addrs) == 0 {
+		return nil
+	}
+	return addrs[0]
+}
+
+// Port returns the TCP port of the server's first listener, or 0 if the
+// server is not running or does not listen on TCP.
+func (s *Server) Port() int {
+	addr, ok := s.Addr().(*net.TCPAddr)
+	if !ok {
+		return 0
+	}
+	return addr.Port
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processOn connects a client with the given options and processes a request
+func processOn(t *testing.T, options ...client.Option) {
+	c := client.New(append(options, client.WithAutoReconnect(false))...)
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Request should succeed")
+}
+
+func TestMultipleListeners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.sock")
+	srv := New(WithListenAddrs("127.0.0.1:0", "127.0.0.1:0", "unix:"+path))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+
+	assert.Nil(t, srv.Addrs(), "Stopped server should have no addresses")
+	require.NoError(t, srv.Start(), "Server should start on every address")
+
+	// Port 0 resolves to an ephemeral port on each TCP listener
+	addrs := srv.Addrs()
+	require.Len(t, addrs, 3, "Every listener should be reported")
+	first := addrs[0].(*net.TCPAddr).Port
+	second := addrs[1].(*net.TCPAddr).Port
+	assert.NotZero(t, first, "First port should be bound")
+	assert.NotZero(t, second, "Second port should be bound")
+	assert.NotEqual(t, first, second, "Each listener should have its own port")
+	assert.Equal(t, path, addrs[2].String(), "Unix socket should be reported by its path")
+
+	// The same server answers on each of them
+	processOn(t, client.WithServerPort(first))
+	processOn(t, client.WithServerPort(second))
+	processOn(t, client.WithUnixSocket(path))
+
+	// Stop closes every listener
+	require.NoError(t, srv.Stop(), "Server should stop")
+	assert.Nil(t, srv.Addrs(), "Stopped server should have no addresses")
+	for _, addr := range addrs[:2] {
+		_, err := net.DialTimeout("tcp", addr.String(), time.Second)
+		assert.Error(t, err, "Closed listener %s should refuse connections", addr)
+	}
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Unix socket should be removed")
+}
+
+func TestListenerRollback(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Probe listener should bind")
+	defer taken.Close()
+
+	// The socket opened before the failing address is closed again
+	path := filepath.Join(t.TempDir(), "mcp.sock")
+	srv := New(WithListenAddrs("unix:"+path, taken.Addr().String()))
+	err = srv.Start()
+	require.Error(t, err, "Start should fail when an address is taken")
+	assert.Contains(t, err.Error(), taken.Addr().String(), "Error should name the address")
+	assert.Equal(t, core.StatusFailed, srv.Status(), "Server should be failed")
+	assert.Nil(t, srv.Addrs(), "Failed server should have no addresses")
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Socket opened before the failure should be closed")
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// echoMetadata copies the metadata keys configured with WithEchoMetadata from
+// the request context into resp, including values appended by the handler.
+// Keys the handler already set on the response are left alone.
+func (s *Server) echoMetadata(ctx context.Context, resp *core.ModelResponse) {
+	if resp == nil {
+		return
+	}
+	resp.Metadata = s.echoedMetadata(ctx, resp.Metadata)
+}
+
+// echoedMetadata returns metadata with the keys echoMetadata copies added,
+// allocating it if there are any and it is nil.
+func (s *Server) echoedMetadata(ctx context.Context, metadata map[string]string) map[string]string {
+	for _, key := range s.options.EchoMetadata {
+		value, ok := core.MetadataValue(ctx, key)
+		if !ok {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		if _, set := metadata[key]; !set {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
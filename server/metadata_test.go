@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TracingModelHandler reports the trace ID it sees in its context and appends
+// a tenant ID to the request metadata
+type TracingModelHandler struct{}
+
+func (h *TracingModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *TracingModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	if traceID, ok := core.MetadataValue(ctx, core.MetadataTraceID); ok {
+		resp.Results["trace"] = traceID
+	}
+	core.AppendMetadata(ctx, core.MetadataTenantID, "acme")
+	return resp, nil
+}
+
+// startMetadataPair starts a server with the tracing handler and a client
+// that sends the given default metadata
+func startMetadataPair(t *testing.T, md map[string]string, options ...Option) *client.Client {
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithDefaultMetadata(md))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(append([]Option{WithTransport(transport)}, options...)...)
+			require.NoError(t, srv.RegisterHandler(&TracingModelHandler{}), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c
+}
+
+func TestMetadataPropagation(t *testing.T) {
+	c := startMetadataPair(t, map[string]string{core.MetadataTraceID: "trace-42"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	resp, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "ProcessModel should succeed")
+
+	assert.Equal(t, "trace-42", resp.Results["trace"], "Handler should see the trace ID in its context")
+	assert.Equal(t, map[string]string{core.MetadataTraceID: "trace-42"}, resp.Metadata, "Only the trace ID should be echoed by default")
+	assert.Nil(t, req.Metadata, "Caller's request should not be modified")
+
+	// Metadata on the context and the request take precedence over defaults
+	req.Metadata = map[string]string{core.MetadataTraceID: "trace-explicit"}
+	resp, err = c.ProcessModel(core.ContextWithMetadata(ctx, map[string]string{core.MetadataTraceID: "trace-ctx"}), req)
+	require.NoError(t, err, "ProcessModel should succeed")
+	assert.Equal(t, "trace-explicit", resp.Metadata[core.MetadataTraceID], "Request metadata should win")
+
+	resp, err = c.ProcessModel(core.ContextWithMetadata(ctx, map[string]string{core.MetadataTraceID: "trace-ctx"}), testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed")
+	assert.Equal(t, "trace-ctx", resp.Metadata[core.MetadataTraceID], "Context metadata should win over defaults")
+}
+
+func TestMetadataEchoAppended(t *testing.T) {
+	c := startMetadataPair(t, map[string]string{core.MetadataTraceID: "trace-7"},
+		WithEchoMetadata(core.MetadataTraceID, core.MetadataTenantID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	batch := &core.BatchRequest{Requests: []*core.ModelRequest{
+		testutil.CreateTestModelRequest(),
+		testutil.CreateTestModelRequest(),
+	}}
+	resp, err := c.ProcessBatch(ctx, batch)
+	require.NoError(t, err, "ProcessBatch should succeed")
+
+	for _, item := range resp.Responses {
+		assert.Equal(t, "trace-7", item.Results["trace"], "Batch items should see the trace ID")
+		assert.Equal(t, map[string]string{
+			core.MetadataTraceID:  "trace-7",
+			core.MetadataTenantID: "acme",
+		}, item.Metadata, "Appended metadata should be echoed")
+	}
+}
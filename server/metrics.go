@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// requestStartKey is the context key holding the time a request arrived.
+type requestStartKey struct{}
+
+// startRequest reports req to the metrics collector and records its arrival
+// time in the returned context for requestCompleted.
+func (h *rpcHandler) startRequest(ctx context.Context, req *jsonrpc2.Request) context.Context {
+	h.server.metrics.RequestStarted(req.Method)
+	return context.WithValue(ctx, requestStartKey{}, time.Now())
+}
+
+// requestCompleted reports the outcome and payload sizes of req to the
+// metrics collector and audit sink once its reply of bytesOut bytes has been
+// encoded.
+func (h *rpcHandler) requestCompleted(ctx context.Context, req *jsonrpc2.Request, success bool, bytesOut int) {
+	metrics := h.server.metrics
+
+	var duration time.Duration
+	if start, ok := ctx.Value(requestStartKey{}).(time.Time); ok {
+		duration = time.Since(start)
+	}
+	metrics.RequestCompleted(req.Method, duration, success)
+
+	bytesIn := 0
+	if req.Params != nil {
+		bytesIn = len(*req.Params)
+	}
+	metrics.PayloadSize(req.Method, bytesIn, bytesOut)
+	h.countCancelled(ctx, req.Method)
+
+	h.audit(ctx, req, success, duration)
+}
+
+// serveMetrics starts the HTTP listener serving the collector on /metrics, the
+// server's Stats and Health as JSON on /stats and /health, CompressionByMethod
+// on /compression, InFlightRequests
+// and LookupRequest on /requests and /requests/{id}, and CancelRequest on
+// /requests/cancel.
+func (s *Server) serveMetrics() error {
+	handler, ok := s.options.Metrics.(http.Handler)
+	if !ok {
+		return errors.New("metrics collector does not implement http.Handler")
+	}
+
+	listener, err := net.Listen("tcp", s.options.MetricsAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics on %s: %w", s.options.MetricsAddr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	mux.HandleFunc("/stats", s.serveStats)
+	mux.HandleFunc("/compression", s.serveCompression)
+	mux.HandleFunc("/health", s.serveHealth)
+	mux.HandleFunc("/requests", s.serveRequests)
+	mux.HandleFunc("/requests/", s.serveRequests)
+	mux.HandleFunc("/requests/cancel", s.serveCancelRequest)
+	s.metricsLn = listener
+	s.metricsSrv = &http.Server{Handler: mux}
+
+	s.wg.Add(1)
+	s.tasks.Go(core.TaskMetrics, func() {
+		defer s.wg.Done()
+		s.metricsSrv.Serve(listener)
+	})
+	return nil
+}
+
+// closeMetrics stops the metrics listener, if one is running.
+func (s *Server) closeMetrics() {
+	if s.metricsSrv != nil {
+		s.metricsSrv.Close()
+		s.metricsSrv = nil
+	}
+}
+
+// MetricsAddr returns the address of the metrics listener, or nil if
+// WithMetricsAddr was not set or the server is not running.
+func (s *Server) MetricsAddr() net.Addr {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	if s.metricsLn == nil || s.status != core.StatusRunning {
+		return nil
+	}
+	return s.metricsLn.Addr()
+}
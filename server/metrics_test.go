@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/metrics"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsAddr(t *testing.T) {
+	collector := metrics.NewExpvarCollector("mcp_server_metrics_addr_test")
+	srv, c := startServerWithHandler(t, NewDefaultModelHandler(), WithMetrics(collector), WithMetricsAddr("127.0.0.1:0"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed")
+
+	addr := srv.MetricsAddr()
+	require.NotNil(t, addr, "Metrics listener should be running")
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	require.NoError(t, err, "Metrics endpoint should be reachable")
+	defer resp.Body.Close()
+
+	var published map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&published), "Collector should be served on /metrics")
+	assert.Contains(t, published, "requests", "Served metrics should include request counts")
+
+	require.NoError(t, srv.Stop(), "Server should stop")
+	assert.Nil(t, srv.MetricsAddr(), "Metrics listener should be closed on Stop")
+}
+
+func TestMetricsAddrRequiresHandler(t *testing.T) {
+	srv := New(WithTransport(core.NewInProcessTransport()), WithMetricsAddr("127.0.0.1:0"))
+	err := srv.Start()
+	require.Error(t, err, "Start should fail when the collector cannot be served")
+	assert.Contains(t, err.Error(), "http.Handler", "Error should explain the requirement")
+	assert.Equal(t, core.StatusFailed, srv.Status(), "Server should be in failed state")
+}
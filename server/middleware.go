@@ -0,0 +1,20 @@
+package server
+
+import (
+	"context"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// withMiddleware wraps process in the configured middleware for requests to
+// method. Requests have their parameters coerced and are checked against the
+// method's declared schema inside the middleware, so it sees those that fail
+// validation too.
+func (s *Server) withMiddleware(method string, process core.ProcessFunc) core.ProcessFunc {
+	return core.ChainMiddleware(method, func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		if result := s.validateRequest(method, req); result != nil {
+			return nil, core.InvalidParamsError(result)
+		}
+		return process(ctx, req)
+	}, s.options.Middleware...)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tracingMiddleware appends name and the method to calls before and after
+// processing each request
+func tracingMiddleware(mu *sync.Mutex, calls *[]string, name string) core.Middleware {
+	return func(method string, next core.ProcessFunc) core.ProcessFunc {
+		return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			mu.Lock()
+			*calls = append(*calls, name+" "+method)
+			mu.Unlock()
+			resp, err := next(ctx, req)
+			mu.Lock()
+			*calls = append(*calls, name+" done")
+			mu.Unlock()
+			return resp, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	c, handler := startSchemaPair(t, WithLogger(core.NopLogger()),
+		WithMiddleware(tracingMiddleware(&mu, &calls, "outer")),
+		WithMiddleware(tracingMiddleware(&mu, &calls, "inner")))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request should succeed")
+
+	// Middleware sees requests failing validation, which never reach the handler
+	invalid := testutil.CreateTestModelRequest()
+	invalid.ModelData["value"] = 1000
+	_, err = c.ProcessModel(ctx, invalid)
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Invalid request should fail with a JSON-RPC error")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Invalid request should use the invalid params code")
+
+	// Batch items pass through the middleware one by one
+	responses, err := c.ProcessModelBatch(ctx, []*core.ModelRequest{invalid})
+	require.NoError(t, err, "Batch should be answered")
+	assert.False(t, responses[0].Success, "Invalid item should fail")
+	assert.Contains(t, responses[0].ErrorMessage, "value", "Item should fail with the violation")
+	assert.NotContains(t, responses[0].ErrorMessage, "jsonrpc2", "Item should fail with the plain message")
+
+	mu.Lock()
+	defer mu.Unlock()
+	step := []string{"outer " + core.MethodProcessModel, "inner " + core.MethodProcessModel, "inner done", "outer done"}
+	assert.Equal(t, append(append(append([]string{}, step...), step...), step...), calls, "Middleware should run first outermost")
+	assert.Equal(t, 1, handler.Calls(), "Only the valid request should reach the handler")
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	refuse := func(method string, next core.ProcessFunc) core.ProcessFunc {
+		return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			if _, ok := req.Metadata["ticket"]; !ok {
+				return nil, &jsonrpc2.Error{Code: core.CodeUnauthorized, Message: "no ticket"}
+			}
+			return next(ctx, req)
+		}
+	}
+	c, handler := startSchemaPair(t, WithLogger(core.NopLogger()), WithMiddleware(refuse))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Refused request should fail with a JSON-RPC error")
+	assert.Equal(t, int64(core.CodeUnauthorized), rpcErr.Code, "Middleware error should be replied as-is")
+	assert.Equal(t, "no ticket", rpcErr.Message, "Middleware error should be replied as-is")
+	assert.Zero(t, handler.Calls(), "Refused request should not reach the handler")
+
+	req := testutil.CreateTestModelRequest()
+	req.Metadata = map[string]string{"ticket": "1"}
+	_, err = c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request with a ticket should succeed")
+}
+
+func TestClientInterceptors(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false),
+				client.WithInterceptors(tracingMiddleware(&mu, &calls, "outer"), tracingMiddleware(&mu, &calls, "inner")))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+			require.NoError(t, srv.RegisterHandler(testutil.NewSchemaHandler(nil)), "Handler registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request should succeed")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"outer " + core.MethodProcessModel, "inner " + core.MethodProcessModel, "inner done", "outer done"},
+		calls, "Interceptors should run first outermost")
+}
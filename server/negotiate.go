@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// negotiates reports whether the server offers anything beyond plain JSON,
+// and so answers clients that negotiate.
+func (s *frameStream) negotiates() bool {
+	return len(s.compressions) > 0 ||
+		(s.offer.Codec != nil && s.offer.Codec.ContentType() != core.ContentTypeJSON)
+}
+
+// negotiate answers frame if it is the client's negotiation, choosing the
+// client's most preferred compression among those offered, and the offered
+// codec if the client accepts it. Once the
+// reply is written, both directions use the choice. It reports whether frame
+// was a negotiation, which jsonrpc2 never sees.
+func (s *frameStream) negotiate(frame json.RawMessage) (bool, error) {
+	var req struct {
+		ID     *jsonrpc2.ID           `json:"id"`
+		Method string                 `json:"method"`
+		Params *core.NegotiateRequest `json:"params"`
+	}
+	if err := json.Unmarshal(frame, &req); err != nil || req.Method != core.MethodNegotiate || req.ID == nil {
+		return false, nil
+	}
+
+	chosen := core.FrameCodec{MaxBytes: s.offer.MaxBytes}
+	var resp core.NegotiateResponse
+	if req.Params != nil {
+		// The client's order of preference wins; algorithms the server does
+		// not know are passed over
+		for _, algorithm := range req.Params.Compression {
+			if algorithm.Supported() && slices.Contains(s.compressions, algorithm) {
+				chosen.Compression = algorithm
+				chosen.Threshold = s.offer.Threshold
+				chosen.Advisor = s.offer.Advisor
+				resp.Compression = algorithm
+				break
+			}
+		}
+		for _, contentType := range req.Params.Codecs {
+			if s.offer.Codec != nil && contentType == s.offer.Codec.ContentType() && contentType != core.ContentTypeJSON {
+				chosen.Codec = s.offer.Codec
+				resp.Codec = contentType
+				break
+			}
+		}
+	}
+
+	result, err := json.Marshal(resp)
+	if err != nil {
+		return true, err
+	}
+	raw := json.RawMessage(result)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.codec.WriteObject(s.writer, &jsonrpc2.Response{ID: *req.ID, Result: &raw}); err != nil {
+		return true, err
+	}
+	if err := s.writer.Flush(); err != nil {
+		return true, err
+	}
+	if resp != (core.NegotiateResponse{}) {
+		s.codec = chosen
+		s.frameMu.Lock()
+		s.chosen = chosen
+		s.frameMu.Unlock()
+	}
+	return true, nil
+}
+
+// negotiated returns the compression and codec content type negotiated on
+// the stream, which are empty while it is plain JSON. It does not wait for a
+// write in progress.
+func (s *frameStream) negotiated() (core.Compression, string) {
+	s.frameMu.Lock()
+	defer s.frameMu.Unlock()
+	if s.chosen.Codec == nil {
+		return s.chosen.Compression, ""
+	}
+	return s.chosen.Compression, s.chosen.Codec.ContentType()
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/msgpack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// EchoModelHandler returns the payload of each request in its response
+type EchoModelHandler struct{}
+
+func (h *EchoModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *EchoModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	resp.Results["payload"] = req.ModelData["payload"]
+	return resp, nil
+}
+
+// countingTransport counts the bytes clients write to the connections it dials
+type countingTransport struct {
+	*core.InProcessTransport
+	written int64
+}
+
+func (t *countingTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := t.InProcessTransport.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, written: &t.written}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	written *int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+// echoNegotiated sends a compressible payload through a server and client
+// configured with the given options and returns the client, the server and
+// the bytes the client wrote
+func echoNegotiated(t *testing.T, serverOptions []Option, clientOptions ...client.Option) (*client.Client, *Server, int64) {
+	transport := &countingTransport{InProcessTransport: core.NewInProcessTransport()}
+	srv := New(append([]Option{WithTransport(transport), WithLogger(core.NopLogger())}, serverOptions...)...)
+	require.NoError(t, srv.RegisterHandler(&EchoModelHandler{}), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	t.Cleanup(func() { srv.Stop() })
+
+	c := client.New(append([]client.Option{
+		client.WithTransport(transport),
+		client.WithLogger(core.NopLogger()),
+		client.WithAutoReconnect(false),
+	}, clientOptions...)...)
+	require.NoError(t, c.Start(), "Client should connect")
+	t.Cleanup(func() { c.Stop() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	payload := strings.Repeat(`{"name":"compressible","values":[1,2,3]}`, 1<<12)
+	req := core.NewModelRequest()
+	req.ModelData["payload"] = payload
+	resp, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "ProcessModel should succeed")
+	assert.Equal(t, payload, resp.Results["payload"], "Payload should round trip")
+
+	// A second call shows the connection stays usable after negotiation
+	_, err = c.ProcessModel(ctx, core.NewModelRequest())
+	require.NoError(t, err, "Small request should succeed")
+	return c, srv, atomic.LoadInt64(&transport.written)
+}
+
+func TestCompressionNegotiated(t *testing.T) {
+	c, srv, written := echoNegotiated(t,
+		[]Option{WithCompression(core.CompressionGzip)},
+		client.WithCompression(core.CompressionGzip))
+
+	assert.Equal(t, core.CompressionGzip, c.ConnectionState().Compression, "Client should report the negotiated compression")
+	require.Len(t, srv.Connections(), 1, "Server should list the connection")
+	assert.Equal(t, core.CompressionGzip, srv.Connections()[0].Compression, "Server should report the negotiated compression")
+	assert.Less(t, written, int64(1<<16), "Compressed request should be far smaller than its payload")
+}
+
+func TestCompressionFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  []Option
+		clients []client.Option
+	}{
+		{"CompressedClientPlainServer", nil, []client.Option{client.WithCompression(core.CompressionGzip)}},
+		{"CompressedClientPlainStallServer", []Option{WithStallDetection(time.Second, false)}, []client.Option{client.WithCompression(core.CompressionGzip)}},
+		{"PlainClientCompressedServer", []Option{WithCompression(core.CompressionGzip)}, nil},
+		{"CompressedClientAuthServer", []Option{
+			WithAuthenticator(func(ctx context.Context, credentials core.Credentials) (core.Principal, error) {
+				return core.Principal{ID: "tester"}, nil
+			}),
+		}, []client.Option{client.WithCompression(core.CompressionGzip), client.WithAuthToken("token")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv, written := echoNegotiated(t, tt.server, tt.clients...)
+
+			assert.Equal(t, core.CompressionNone, c.ConnectionState().Compression, "Client should fall back to plain")
+			for _, info := range srv.Connections() {
+				assert.Equal(t, core.CompressionNone, info.Compression, "Server should serve the client plain")
+			}
+			assert.Greater(t, written, int64(40<<12), "Plain request should carry its whole payload")
+		})
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	_, _, written := echoNegotiated(t,
+		[]Option{WithCompression(core.CompressionGzip)},
+		client.WithCompression(core.CompressionGzip), client.WithCompressionThreshold(1<<20), client.WithStaticTuning())
+
+	assert.Greater(t, written, int64(40<<12), "Messages under the threshold should be sent plain")
+}
+
+func TestCodecNegotiated(t *testing.T) {
+	c, srv, _ := echoNegotiated(t,
+		[]Option{WithCodec(msgpack.Codec), WithCompression(core.CompressionGzip)},
+		client.WithCodec(msgpack.Codec), client.WithCompression(core.CompressionGzip))
+
+	state := c.ConnectionState()
+	assert.Equal(t, msgpack.ContentType, state.Codec, "Client should report the negotiated codec")
+	assert.Equal(t, core.CompressionGzip, state.Compression, "Compression should be negotiated alongside the codec")
+	require.Len(t, srv.Connections(), 1, "Server should list the connection")
+	assert.Equal(t, msgpack.ContentType, srv.Connections()[0].Codec, "Server should report the negotiated codec")
+
+	// Binary data survives the codec and is carried raw
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	blob := bytes.Repeat([]byte{0, 1, 2, 0xff}, 1<<10)
+	req := core.NewModelRequest()
+	req.ModelData["payload"] = core.Binary(blob)
+	resp, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "ProcessModel should succeed")
+	got, ok := core.AsBinary(resp.Results["payload"])
+	require.True(t, ok, "Binary payload should come back as binary")
+	assert.Equal(t, blob, got, "Binary payload should round trip")
+}
+
+func TestCodecFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  []Option
+		clients []client.Option
+	}{
+		{"MsgpackClientJSONServer", nil, []client.Option{client.WithCodec(msgpack.Codec)}},
+		{"MsgpackClientCompressedServer", []Option{WithCompression(core.CompressionGzip)}, []client.Option{client.WithCodec(msgpack.Codec)}},
+		{"JSONClientMsgpackServer", []Option{WithCodec(msgpack.Codec)}, nil},
+		{"CompressedClientMsgpackServer", []Option{WithCodec(msgpack.Codec)}, []client.Option{client.WithCompression(core.CompressionGzip)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv, _ := echoNegotiated(t, tt.server, tt.clients...)
+
+			assert.Empty(t, c.ConnectionState().Codec, "Client should fall back to JSON")
+			for _, info := range srv.Connections() {
+				assert.Empty(t, info.Codec, "Server should serve the client JSON")
+			}
+		})
+	}
+}
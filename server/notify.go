@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// connKey is the context key under which a request's connection is stored.
+type connKey struct{}
+
+// Publish sends a notification to every connected client. Notifications
+// listed in WithOrderedNotifications reach each client only after any reply
+// it is waiting for has been written. It returns an error if the
+// notification is not registered or could not be sent to some clients.
+func Publish[T any](s *Server, n core.Notification[T], payload T) error {
+	return publish(s, n, payload, nil)
+}
+
+// publish sends a notification to the clients include accepts, or to every
+// client if include is nil.
+func publish[T any](s *Server, n core.Notification[T], payload T, include func(*rpcHandler) bool) error {
+	if err := n.Check(); err != nil {
+		return err
+	}
+
+	ordered := s.ordered(n.Method())
+	var errs []error
+	for h, conn := range s.sessionConns() {
+		if include != nil && !include(h) {
+			continue
+		}
+		if ordered {
+			h.afterReply(nil, s.deferredNotify(h, conn, n.Method(), payload))
+			continue
+		}
+		if err := conn.Notify(s.runContext(), n.Method(), payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notify sends a notification to the client that made the request ctx
+// belongs to, e.g. to report progress from a handler.
+func Notify[T any](ctx context.Context, n core.Notification[T], payload T) error {
+	if err := n.Check(); err != nil {
+		return err
+	}
+	conn, ok := ctx.Value(connKey{}).(rpcConn)
+	if !ok {
+		return errors.New("no client connection in context")
+	}
+	return conn.Notify(ctx, n.Method(), payload)
+}
+
+// OnNotificationTyped registers handler for notifications described by n
+// that clients send. Payloads that fail to decode are reported to the
+// callbacks registered with Server.OnNotificationError.
+func OnNotificationTyped[T any](s *Server, n core.Notification[T], handler func(ctx context.Context, payload T)) {
+	core.HandleNotification(s.notifications, n, handler)
+}
+
+// OnNotification registers a handler for client notifications that have no
+// typed handler, receiving the raw payload.
+func (s *Server) OnNotification(handler func(ctx context.Context, method string, params json.RawMessage)) {
+	s.notifications.OnUnknown(handler)
+}
+
+// OnNotificationError registers a callback for client notifications whose
+// payload could not be decoded.
+func (s *Server) OnNotificationError(callback func(*core.NotificationError)) {
+	s.notifications.OnError(callback)
+}
+
+// handleNotification dispatches a notification from the client. Notifications
+// get no reply, so those the connection may not send are dropped.
+func (h *rpcHandler) handleNotification(ctx context.Context, req *jsonrpc2.Request) {
+	drop := func(reason string) {
+		h.server.options.Logger.Debug("Dropping notification",
+			core.LogFieldRemoteAddr, h.remoteAddr,
+			core.LogFieldMethod, req.Method,
+			"reason", reason)
+		h.requestCompleted(ctx, req, false, 0)
+	}
+
+	// The parent of a subprocess child asks it to wind down
+	if req.Method == core.MethodShutdown && h.server.options.SubprocessMode {
+		h.server.options.Logger.Info("Shutting down once idle", core.LogFieldRemoteAddr, h.remoteAddr)
+		h.closeWhenIdle()
+		h.requestCompleted(ctx, req, true, 0)
+		return
+	}
+
+	if _, ok := h.limiter.allow(req.Method, time.Now()); !ok {
+		drop("rate limit exceeded")
+		return
+	}
+	if h.server.authRequired() {
+		principal, err := h.authorize(ctx)
+		if err != nil {
+			drop(err.Error())
+			return
+		}
+		ctx = core.ContextWithPrincipal(ctx, principal)
+	}
+	if err := h.server.permit(ctx, req.Method); err != nil {
+		drop(err.Error())
+		return
+	}
+
+	err := h.server.notifications.Dispatch(ctx, req.Method, req.Params)
+	if err != nil {
+		h.logError("Invalid notification from client", req, err)
+	}
+	h.requestCompleted(ctx, req, err == nil, 0)
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type progressUpdate struct {
+	RequestID string `json:"requestId"`
+	Percent   int    `json:"percent"`
+}
+
+type chatMessage struct {
+	Text string `json:"text"`
+}
+
+var (
+	progressNotification = core.RegisterNotification[progressUpdate]("test.progress")
+	chatNotification     = core.RegisterNotification[chatMessage]("test.chat")
+)
+
+// ProgressHandler reports progress to the calling client before answering
+type ProgressHandler struct{}
+
+func (h *ProgressHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *ProgressHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if err := Notify(ctx, progressNotification, progressUpdate{RequestID: req.ID, Percent: 50}); err != nil {
+		return nil, err
+	}
+	return core.NewModelResponse(req), nil
+}
+
+// startNotifyPair starts a server with the progress handler and a client
+func startNotifyPair(t *testing.T) (*client.Client, *Server) {
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport))
+			require.NoError(t, srv.RegisterHandler(&ProgressHandler{}), "Handler registration should succeed")
+			return srv
+		},
+	)
+	require.Eventually(t, func() bool { return srv.Stats().Sessions == 1 }, 2*time.Second, 10*time.Millisecond, "Server should register the session")
+	return c, srv
+}
+
+// notifyRaw sends an arbitrary payload to every client, bypassing the typed API
+func notifyRaw(t *testing.T, srv *Server, method string, params interface{}) {
+	srv.connsMu.Lock()
+	defer srv.connsMu.Unlock()
+	for h := range srv.sessions {
+		require.NoError(t, h.conn.Notify(context.Background(), method, params), "Raw notification should be sent")
+	}
+}
+
+// receive waits for a value from ch
+func receive[T any](t *testing.T, ch <-chan T, what string) T {
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for %s", what)
+		var zero T
+		return zero
+	}
+}
+
+func TestTypedNotificationsToClient(t *testing.T) {
+	c, srv := startNotifyPair(t)
+
+	updates := make(chan progressUpdate, 2)
+	client.OnNotificationTyped(c, progressNotification, func(ctx context.Context, update progressUpdate) { updates <- update })
+
+	// Broadcast to every client
+	require.NoError(t, Publish(srv, progressNotification, progressUpdate{RequestID: "all", Percent: 100}), "Publish should succeed")
+	assert.Equal(t, progressUpdate{RequestID: "all", Percent: 100}, receive(t, updates, "published progress"), "Client should receive the published payload")
+
+	// Sent from a handler to the calling client
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req := testutil.CreateTestModelRequest()
+	_, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "ProcessModel should succeed")
+	assert.Equal(t, progressUpdate{RequestID: req.ID, Percent: 50}, receive(t, updates, "handler progress"), "Client should receive the handler's progress")
+
+	assert.Error(t, Publish(srv, core.Notification[progressUpdate]{}, progressUpdate{}), "Unregistered notification should be refused")
+	assert.Error(t, Notify(context.Background(), progressNotification, progressUpdate{}), "Notify outside a request should fail")
+}
+
+func TestTypedNotificationsToServer(t *testing.T) {
+	c, srv := startNotifyPair(t)
+
+	messages := make(chan chatMessage, 1)
+	OnNotificationTyped(srv, chatNotification, func(ctx context.Context, msg chatMessage) { messages <- msg })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, client.Notify(ctx, c, chatNotification, chatMessage{Text: "hello"}), "Client notification should be sent")
+	assert.Equal(t, chatMessage{Text: "hello"}, receive(t, messages, "chat message"), "Server should receive the typed payload")
+}
+
+func TestNotificationFallbackAndDecodeFailure(t *testing.T) {
+	c, srv := startNotifyPair(t)
+
+	client.OnNotificationTyped(c, progressNotification, func(ctx context.Context, update progressUpdate) {
+		t.Error("Undecodable payload should not reach the typed handler")
+	})
+	failures := make(chan *core.NotificationError, 1)
+	c.OnNotificationError(func(err *core.NotificationError) { failures <- err })
+	unknown := make(chan string, 1)
+	c.OnNotification(func(ctx context.Context, method string, params json.RawMessage) { unknown <- method })
+
+	// A payload of the wrong shape is reported, not dropped
+	notifyRaw(t, srv, progressNotification.Method(), map[string]string{"percent": "half"})
+	failure := receive(t, failures, "decode failure")
+	assert.Equal(t, progressNotification.Method(), failure.Method, "Failure should name the notification")
+	assert.JSONEq(t, `{"percent":"half"}`, string(failure.Params), "Failure should carry the payload")
+	assert.Error(t, failure.Err, "Failure should carry the decoding error")
+
+	// Notifications without a typed handler reach the raw fallback
+	notifyRaw(t, srv, "test.unknown", []int{1})
+	assert.Equal(t, "test.unknown", receive(t, unknown, "unknown notification"), "Fallback should receive unknown notifications")
+}
@@ -1,18 +1,94 @@
 // Package server provides a server implementation for the Model Context Protocol (MCP).
 package server
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
 
 // Options holds configuration parameters for the MCP server.
 // It defines network settings, connection limits, timeouts, and TLS configuration.
 type Options struct {
-	Host                 string        // Network interface to bind to, e.g., "127.0.0.1" for localhost only
-	Port                 int           // TCP port to listen on
-	MaxConcurrentClients int           // Maximum number of simultaneous client connections
-	ConnectionTimeout    time.Duration // Time limit for establishing connections
-	EnableTLS            bool          // Whether to use TLS encryption for connections
-	CertificatePath      string        // Path to the TLS certificate file when TLS is enabled
-	CertificateKeyPath   string        // Path to the TLS certificate key file when TLS is enabled
+	Name                      string                   // Names the server in the Source of its status change events
+	Logger                    core.Logger              // Receives structured log records; defaults to slog.Default
+	Metrics                   core.MetricsCollector    // Receives connection and request measurements
+	MetricsAddr               string                   // Address of an HTTP listener serving Metrics on /metrics; empty disables it
+	HealthAddr                string                   // Address of an HTTP listener serving /healthz and /readyz; empty disables it
+	ReadinessCheck            func() error             // Reports why a running server is not ready; nil when running is enough
+	Transport                 core.Transport           // Network carrying connections; defaults to TCP on Host:Port
+	InheritedListenerFD       uintptr                  // Descriptor of a listener inherited from a parent process; zero opens a new one
+	Host                      string                   // Network interface to bind to, e.g., "127.0.0.1" for localhost only
+	Port                      int                      // TCP port to listen on
+	ListenAddrs               []string                 // Addresses to listen on instead of Host:Port; "unix:" prefixes a socket path
+	MaxConcurrentClients      int                      // Maximum number of simultaneous client connections
+	MaxConcurrentRequests     int                      // Maximum number of requests handled at once across connections; zero is unbounded
+	RequestQueueSize          int                      // Requests that may wait for a handler when MaxConcurrentRequests are running
+	PrincipalConcurrency      int                      // Requests each principal may have in flight across its connections; zero is unbounded
+	PrincipalOverrides        map[string]int           // Limits of individual principals by ID, overriding PrincipalConcurrency
+	PrincipalReserve          int                      // Slots shared by high priority requests of principals at their limit
+	JobRetention              time.Duration            // How long finished asynchronous jobs are kept for mcp.jobStatus
+	MaxConcurrentJobs         int                      // Maximum number of asynchronous jobs running at once; zero is unbounded
+	BlobStore                 BlobStore                // Keeps the blobs clients upload with mcp.blob.put; nil refuses blob transfers
+	BlobUploadTTL             time.Duration            // How long an unfinished blob upload waits for its next chunk before it is discarded; zero keeps it
+	ConnectionTimeout         time.Duration            // Time limit for establishing connections
+	EnableTLS                 bool                     // Whether to use TLS encryption for connections
+	CertificatePath           string                   // Path to the TLS certificate file when TLS is enabled
+	CertificateKeyPath        string                   // Path to the TLS certificate key file when TLS is enabled
+	TLSSessionTickets         bool                     // Whether clients may resume TLS sessions using session tickets
+	IdleTimeout               time.Duration            // Drop connections that send nothing for this long; zero disables
+	WriteTimeout              time.Duration            // Drop connections that take nothing written to them for this long; zero disables
+	SessionTTL                time.Duration            // End the session of a connection that sends no request for this long; zero keeps it until the connection closes
+	HandlerTimeout            time.Duration            // Time a model handler may run before its request fails with core.CodeDeadlineExceeded; zero is unlimited
+	MethodTimeouts            map[string]time.Duration // Handler timeouts of individual methods, overriding HandlerTimeout
+	StallThreshold            time.Duration            // Report connections stuck this long in a partial frame; zero disables
+	StallClose                bool                     // Close connections found stalled instead of only reporting them
+	Compression               core.Compression         // Algorithm offered to clients that negotiate compression; empty keeps every connection plain
+	CompressionFallbacks      []core.Compression       // Further algorithms offered, for clients that do not accept Compression
+	CompressionThreshold      int                      // Smallest message, in bytes, that is compressed
+	CompressionRatioLimit     float64                  // Rolling average ratio of compressed to original size above which a method is sent uncompressed; zero always compresses
+	CompressionWindow         int                      // Messages of a method the rolling average ratio is taken over
+	CompressionReprobe        time.Duration            // How long a method is sent uncompressed before compression is tried on it again
+	MaxRequestBytes           int64                    // Largest request body, in bytes, the server reads; zero is unlimited
+	OutboundQueueSize         int                      // Replies and notifications each connection may have waiting to be written; zero makes senders write themselves
+	Codec                     core.Codec               // Codec offered to clients that negotiate one; nil serves every client JSON
+	ReplayMode                bool                     // Whether to honour replay metadata for deterministic handler runs
+	Recorder                  Recorder                 // Receives processed exchanges for later replay; nil disables recording
+	AuditSink                 core.AuditSink           // Receives an event for every reply; nil disables auditing
+	Tracer                    core.Tracer              // Starts a span around every handler run
+	Authenticator             Authenticator            // Verifies credentials for schemes without a registered verifier
+	Authorizer                Authorizer               // Decides which methods each principal may call; nil allows all
+	AdminHandler              http.Handler             // Serves HTTP requests arriving on the MCP port; nil disables port sharing
+	BatchDeadlineStrategy     core.DeadlineStrategy    // How a batch deadline is divided among its items
+	BatchParallelism          int                      // Batch items processed at once; values below one run them one at a time
+	SchemaValidation          bool                     // Whether to reject requests that do not match the schemas handlers declare
+	ParameterCoercion         bool                     // Whether to convert parameter values to their declared core.ParamType before handlers run
+	ResponseValidation        bool                     // Whether to check handler responses before sending them
+	ResponseValidationLogOnly bool                     // Log invalid responses but send them unchanged
+	MaxAnnotations            int                      // Metadata entries a validated response may carry; zero is unlimited
+	MaxAnnotationBytes        int                      // Bytes of metadata keys and values a validated response may carry; zero is unlimited
+	PanicHandler              PanicHandler             // Told of every panic recovered from a handler; nil logs it with the stack
+	Middleware                []core.Middleware        // Wraps the processing of every model request, the first outermost
+	EchoMetadata              []string                 // Request metadata keys copied into each response
+	OrderedNotifications      []string                 // Notification methods Publish holds back until a pending reply is written
+	TaskBudgets               map[core.TaskFeature]int // Per-feature goroutine and timer limits that log a warning when exceeded
+	RateLimit                 RateLimit                // Per-connection request rate limit; zero RPS disables it
+	MethodRateLimits          map[string]RateLimit     // Per-connection limits for individual methods, overriding RateLimit
+	JournalDir                string                   // Directory of the request journal; empty disables journaling
+	JournalSync               JournalSyncPolicy        // When journal writes are flushed to disk
+	JournalMaxSize            int64                    // Segment size in bytes after which the journal rotates
+	JournalPayloads           bool                     // Journal full request params instead of their hash
+	DurableBufferCount        int                      // Notifications buffered for each detached durable subscription before the oldest is dropped
+	DurableBufferBytes        int                      // Payload bytes buffered for each detached durable subscription before the oldest is dropped
+	DurableTTL                time.Duration            // How long a durable subscription outlives its connection
+	Features                  []core.Feature           // Features announced to clients in the handshake; methods of the others are refused
+	MinProtocolVersion        int                      // Lowest protocol version a client must initialize with; zero accepts clients that do not initialize
+	SubprocessMode            bool                     // Whether to wind a connection down when its client sends core.MethodShutdown, as the child of a Subprocess group does
 }
 
 // DefaultOptions returns the default server options.
@@ -20,18 +96,188 @@ type Options struct {
 // with the server listening only on localhost.
 func DefaultOptions() Options {
 	return Options{
-		Host:                 "127.0.0.1",
-		Port:                 5000,
-		MaxConcurrentClients: 10,
-		ConnectionTimeout:    30 * time.Second,
-		EnableTLS:            false,
+		Logger:                core.DefaultLogger(),
+		Metrics:               core.NopMetrics(),
+		Transport:             core.TCPTransport{},
+		Host:                  "127.0.0.1",
+		Port:                  5000,
+		MaxConcurrentClients:  10,
+		ConnectionTimeout:     30 * time.Second,
+		EnableTLS:             false,
+		TLSSessionTickets:     true,
+		BatchDeadlineStrategy: core.DeadlineFirstComeAll,
+		BatchParallelism:      1,
+		SchemaValidation:      true,
+		MaxAnnotations:        64,
+		MaxAnnotationBytes:    16 << 10,
+		EchoMetadata:          []string{core.MetadataTraceID},
+		Tracer:                core.NopTracer(),
+		JournalSync:           JournalSyncAlways,
+		JournalMaxSize:        64 << 20,
+		JobRetention:          10 * time.Minute,
+		BlobUploadTTL:         10 * time.Minute,
+		CompressionThreshold:  1 << 10,
+		MaxRequestBytes:       core.DefaultMaxMessageBytes,
+		OutboundQueueSize:     64,
+		DurableBufferCount:    1000,
+		DurableBufferBytes:    1 << 20,
+		DurableTTL:            10 * time.Minute,
+		Features:              core.AllFeatures(),
 	}
 }
 
+// clone returns a copy of the options that shares no slices or maps with
+// them. Interfaces and functions, such as the logger and the authenticator,
+// are shared.
+func (o Options) clone() Options {
+	o.ListenAddrs = slices.Clone(o.ListenAddrs)
+	o.PrincipalOverrides = maps.Clone(o.PrincipalOverrides)
+	o.CompressionFallbacks = slices.Clone(o.CompressionFallbacks)
+	o.Middleware = slices.Clone(o.Middleware)
+	o.EchoMetadata = slices.Clone(o.EchoMetadata)
+	o.OrderedNotifications = slices.Clone(o.OrderedNotifications)
+	o.TaskBudgets = maps.Clone(o.TaskBudgets)
+	o.MethodRateLimits = maps.Clone(o.MethodRateLimits)
+	o.MethodTimeouts = maps.Clone(o.MethodTimeouts)
+	o.Features = slices.Clone(o.Features)
+	return o
+}
+
+// Validate reports the settings the server cannot run with: a port outside
+// 0-65535, an empty Host without ListenAddrs, TLS without both a certificate
+// and a key, a certificate without a key or the other way round, negative
+// limits, sizes and durations, and fewer than one concurrent client. Each
+// problem found is joined into the error. Start calls it before opening any
+// listener.
+func (o Options) Validate() error {
+	var errs []error
+	if o.Port < 0 || o.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is outside 0-65535", o.Port))
+	}
+	if o.Host == "" && len(o.ListenAddrs) == 0 {
+		errs = append(errs, errors.New(`host is empty; use "0.0.0.0" to listen on every interface`))
+	}
+	switch {
+	case o.EnableTLS && o.CertificatePath == "" && o.CertificateKeyPath == "":
+		errs = append(errs, errors.New("TLS is enabled without a certificate and key"))
+	case o.CertificatePath != "" && o.CertificateKeyPath == "":
+		errs = append(errs, fmt.Errorf("TLS certificate %q has no key", o.CertificatePath))
+	case o.CertificatePath == "" && o.CertificateKeyPath != "":
+		errs = append(errs, fmt.Errorf("TLS key %q has no certificate", o.CertificateKeyPath))
+	}
+	if o.MaxConcurrentClients < 1 {
+		errs = append(errs, fmt.Errorf("max concurrent clients must be at least 1, got %d", o.MaxConcurrentClients))
+	}
+	for _, limit := range []struct {
+		name  string
+		value int64
+	}{
+		{"max concurrent requests", int64(o.MaxConcurrentRequests)},
+		{"request queue size", int64(o.RequestQueueSize)},
+		{"principal concurrency", int64(o.PrincipalConcurrency)},
+		{"principal reserve", int64(o.PrincipalReserve)},
+		{"max concurrent jobs", int64(o.MaxConcurrentJobs)},
+		{"max request bytes", o.MaxRequestBytes},
+		{"outbound queue size", int64(o.OutboundQueueSize)},
+		{"compression threshold", int64(o.CompressionThreshold)},
+		{"max annotations", int64(o.MaxAnnotations)},
+		{"max annotation bytes", int64(o.MaxAnnotationBytes)},
+	} {
+		if limit.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", limit.name, limit.value))
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"connection timeout", o.ConnectionTimeout},
+		{"idle timeout", o.IdleTimeout},
+		{"write timeout", o.WriteTimeout},
+		{"session TTL", o.SessionTTL},
+		{"job retention", o.JobRetention},
+		{"blob upload TTL", o.BlobUploadTTL},
+		{"durable TTL", o.DurableTTL},
+		{"handler timeout", o.HandlerTimeout},
+		{"compression reprobe", o.CompressionReprobe},
+	} {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", d.name, d.value))
+		}
+	}
+	if o.CompressionRatioLimit < 0 {
+		errs = append(errs, fmt.Errorf("compression ratio limit must not be negative, got %g", o.CompressionRatioLimit))
+	}
+	if o.CompressionRatioLimit > 0 && o.CompressionWindow < 1 {
+		errs = append(errs, fmt.Errorf("compression window must be at least 1, got %d", o.CompressionWindow))
+	}
+	for method, timeout := range o.MethodTimeouts {
+		if timeout < 0 {
+			errs = append(errs, fmt.Errorf("handler timeout of %s must not be negative, got %s", method, timeout))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Option is a function type that modifies Options.
 // It implements the functional options pattern for configuring the server.
 type Option func(*Options)
 
+// WithName names the server in the core.StatusSource of its status change
+// events, telling it apart from other components, e.g. in a
+// core.StatusAggregator.
+func WithName(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+// WithLogger sets the logger for server events. Connection events are logged at
+// Debug, lifecycle changes at Info and failures at Error. Use core.NopLogger to
+// silence the server.
+func WithLogger(logger core.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithMetrics sets a collector notified of every connection and request, e.g.
+// the expvar collector in the metrics package. Request durations cover
+// dispatch and encoding of the reply.
+func WithMetrics(collector core.MetricsCollector) Option {
+	return func(o *Options) {
+		o.Metrics = collector
+	}
+}
+
+// WithMetricsAddr serves the metrics collector on /metrics from a separate HTTP
+// listener at addr, e.g. ":9090". The collector must implement http.Handler,
+// as the Prometheus collector in the mcpprom package does.
+func WithMetricsAddr(addr string) Option {
+	return func(o *Options) {
+		o.MetricsAddr = addr
+	}
+}
+
+// WithHealthAddr serves liveness and readiness probes from a separate HTTP
+// listener at addr, e.g. ":8081". /healthz answers 200 while the listener is
+// up; /readyz answers 200 only while the server is running and its
+// ReadinessCheck, if any, passes, and 503 otherwise.
+func WithHealthAddr(addr string) Option {
+	return func(o *Options) {
+		o.HealthAddr = addr
+	}
+}
+
+// WithReadinessCheck sets a function /readyz consults once the server is
+// running, e.g. to wait for a model to load. A non-nil error marks the server
+// not ready, with the error as the reason.
+func WithReadinessCheck(check func() error) Option {
+	return func(o *Options) {
+		o.ReadinessCheck = check
+	}
+}
+
 // WithHost sets the host address for the server to bind to.
 // Use "0.0.0.0" to listen on all interfaces, or a specific IP to restrict access.
 func WithHost(host string) Option {
@@ -42,12 +288,57 @@ func WithHost(host string) Option {
 
 // WithPort sets the TCP port number for the server to listen on.
 // The port must be available and the process must have permission to bind to it.
+// Port 0 binds an ephemeral port, which Server.Port reports once started.
 func WithPort(port int) Option {
 	return func(o *Options) {
 		o.Port = port
 	}
 }
 
+// WithListenAddrs makes the server listen on every one of addrs, e.g.
+// "127.0.0.1:5000" and "[::1]:5000", instead of Host and Port. Addresses are
+// opened on the configured transport, except those of the form "unix:path",
+// which listen on a unix domain socket at path. Port 0 picks an ephemeral
+// port; Server.Addrs reports the ports bound.
+func WithListenAddrs(addrs ...string) Option {
+	return func(o *Options) {
+		o.ListenAddrs = addrs
+	}
+}
+
+// WithTransport sets the transport the server listens on, e.g. a
+// core.InProcessTransport shared with clients in the same process.
+func WithTransport(transport core.Transport) Option {
+	return func(o *Options) {
+		o.Transport = transport
+	}
+}
+
+// NewInProcessListener returns a pipe for serving clients in the same process
+// over net.Pipe, without sockets or ports. Pass it to WithTransport, and to
+// each client's client.WithInProcessConn.
+func NewInProcessListener() *core.InProcessTransport {
+	return core.NewInProcessTransport()
+}
+
+// WithInheritedListener makes the server accept on a listening socket inherited
+// from the process that started it, instead of opening its own. Use
+// InheritedListenerFD to pick up a descriptor passed under the LISTEN_FDS
+// convention, e.g. by a predecessor calling ListenerFile during a handoff.
+func WithInheritedListener(fd uintptr) Option {
+	return func(o *Options) {
+		o.InheritedListenerFD = fd
+	}
+}
+
+// WithUnixSocket makes the server listen on a unix domain socket at path instead of TCP.
+// A stale socket file is removed on Start and the socket is removed again on Stop.
+func WithUnixSocket(path string) Option {
+	return func(o *Options) {
+		o.Transport = core.UnixTransport{Path: path}
+	}
+}
+
 // WithMaxConcurrentClients sets the maximum number of concurrent client connections.
 // This helps prevent resource exhaustion by limiting the number of simultaneous
 // connections the server will accept.
@@ -57,6 +348,89 @@ func WithMaxConcurrentClients(max int) Option {
 	}
 }
 
+// WithMaxConcurrentRequests limits how many requests the server's handlers
+// run at once, across all connections. Pings, authentication, method listings
+// and notifications are not counted. When the limit is reached, requests wait
+// in a queue sized by WithRequestQueueSize and fail with core.CodeServerBusy
+// once it is full. Zero, the default, leaves handling unbounded.
+func WithMaxConcurrentRequests(max int) Option {
+	return func(o *Options) {
+		o.MaxConcurrentRequests = max
+	}
+}
+
+// WithRequestQueueSize sets how many requests may wait for a handler while
+// WithMaxConcurrentRequests are running. Zero, the default, refuses them with
+// core.CodeServerBusy straight away.
+func WithRequestQueueSize(size int) Option {
+	return func(o *Options) {
+		o.RequestQueueSize = size
+	}
+}
+
+// WithPrincipalConcurrencyLimit limits how many requests each authenticated
+// principal has in flight at once, across all of its connections, so one
+// tenant cannot take every handler. overrides sets the limits of individual
+// principals by ID; zero exempts a principal. Requests beyond the limit fail
+// with core.CodeServerBusy and a core.PrincipalBusyData. The requests counted
+// are those WithMaxConcurrentRequests counts; unauthenticated requests are
+// not limited.
+func WithPrincipalConcurrencyLimit(defaultLimit int, overrides map[string]int) Option {
+	return func(o *Options) {
+		o.PrincipalConcurrency = defaultLimit
+		o.PrincipalOverrides = overrides
+	}
+}
+
+// WithPrincipalReserve sets aside slots that requests with core.PriorityHigh
+// metadata may borrow when their principal is at the limit set by
+// WithPrincipalConcurrencyLimit. The reserve is shared by every principal.
+// Zero, the default, lets no request exceed its principal's limit.
+func WithPrincipalReserve(slots int) Option {
+	return func(o *Options) {
+		o.PrincipalReserve = slots
+	}
+}
+
+// WithJobRetention sets how long the result of an asynchronous job submitted
+// with mcp.submitModel is kept after it finishes. Later mcp.jobStatus calls
+// fail with core.CodeJobNotFound. The default is 10 minutes.
+func WithJobRetention(retention time.Duration) Option {
+	return func(o *Options) {
+		o.JobRetention = retention
+	}
+}
+
+// WithMaxConcurrentJobs limits how many asynchronous jobs run at once. Further
+// jobs stay pending until one finishes. Zero, the default, runs every job
+// straight away.
+func WithMaxConcurrentJobs(max int) Option {
+	return func(o *Options) {
+		o.MaxConcurrentJobs = max
+	}
+}
+
+// WithBlobStore keeps the blobs clients upload with mcp.blob.put in store,
+// for them to download with mcp.blob.get and for handlers to open from
+// BlobsFromContext. Model requests referring to blobs the store does not
+// hold fail with core.CodeBlobNotFound. Without a store, the default, blob
+// transfers are refused.
+func WithBlobStore(store BlobStore) Option {
+	return func(o *Options) {
+		o.BlobStore = store
+	}
+}
+
+// WithBlobUploadTTL sets how long an unfinished blob upload waits for its
+// next chunk, across reconnections of its client, before what it received
+// is discarded. The default is 10 minutes; zero keeps unfinished uploads
+// until the server stops.
+func WithBlobUploadTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.BlobUploadTTL = ttl
+	}
+}
+
 // WithConnectionTimeout sets the connection timeout.
 func WithConnectionTimeout(timeout time.Duration) Option {
 	return func(o *Options) {
@@ -64,8 +438,455 @@ func WithConnectionTimeout(timeout time.Duration) Option {
 	}
 }
 
-// WithTLS enables TLS with the specified certificate and key.
-func WithTLS(certPath, keyPath string) Option {
+// WithIdleTimeout sets how long a connection may stay silent before the server drops it.
+// It is the server's read timeout: the read deadline is renewed on every read, so any
+// inbound traffic, including heartbeat pings, resets the timer. Zero disables the check.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.IdleTimeout = timeout
+	}
+}
+
+// WithReadTimeout is WithIdleTimeout under the name client.WithReadTimeout
+// uses: it sets the same read deadline, renewed on every read.
+func WithReadTimeout(timeout time.Duration) Option {
+	return WithIdleTimeout(timeout)
+}
+
+// WithWriteTimeout sets how long a write to a connection may wait for the
+// peer to take it before the server drops the connection, so a client that
+// stops reading, or has gone without closing its socket, cannot hold replies
+// and their goroutines forever. The deadline is renewed for every write, so
+// quiet connections are not affected. Zero, the default, disables the check.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.WriteTimeout = timeout
+	}
+}
+
+// WithHandlerTimeout limits how long a handler may run on a model request,
+// batch item, tool call or submitted job, whatever deadline the client set.
+// The handler's context ends once timeout
+// has passed, and the request fails with core.CodeDeadlineExceeded. A
+// handler that ignores its context is abandoned still running, its result
+// discarded, and counted in Stats().StuckHandlers until it returns. Zero,
+// the default, lets handlers run as long as they like.
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.HandlerTimeout = timeout
+	}
+}
+
+// WithMethodTimeouts gives the listed methods their own handler timeouts
+// instead of the one set by WithHandlerTimeout. Zero exempts a method. Batch
+// items are limited as core.MethodProcessModel, tools as core.MethodCallTool
+// and submitted jobs as core.MethodSubmitModel.
+func WithMethodTimeouts(timeouts map[string]time.Duration) Option {
+	return func(o *Options) {
+		o.MethodTimeouts = timeouts
+	}
+}
+
+// WithSessionTTL ends the core.Session of a connection once no request has
+// run on it for ttl, running the OnSessionEnd callbacks, without closing the
+// connection; its next request starts a new session. Zero, the default,
+// keeps a session until its connection closes.
+func WithSessionTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.SessionTTL = ttl
+	}
+}
+
+// WithStallDetection reports connections whose peer starts a frame and then
+// stops sending it for at least threshold, such as a body cut short of its
+// Content-Length. Each stall is logged, counted in Stats and by collectors
+// implementing core.StallCollector, and passed to OnStall callbacks; with
+// closeConn set the connection is also closed. Unlike WithIdleTimeout, quiet
+// connections between frames are never reported.
+func WithStallDetection(threshold time.Duration, closeConn bool) Option {
+	return func(o *Options) {
+		o.StallThreshold = threshold
+		o.StallClose = closeConn
+	}
+}
+
+// WithCompression offers clients that negotiate compression to compress
+// messages with the given algorithms, e.g. core.CompressionGzip. A client is
+// served with the first algorithm in its own order of preference that is
+// offered; clients that do not ask for compression, or accept none of the
+// algorithms, are served plain. Algorithms other than gzip must be
+// registered, e.g. by importing the mcpcompress package.
+func WithCompression(algorithms ...core.Compression) Option {
+	return func(o *Options) {
+		o.Compression, o.CompressionFallbacks = core.CompressionNone, nil
+		if len(algorithms) > 0 {
+			o.Compression, o.CompressionFallbacks = algorithms[0], algorithms[1:]
+		}
+	}
+}
+
+// WithCompressionThreshold sets the size, in bytes, below which messages are
+// sent plain on compressed connections, where compressing costs more than it
+// saves. The default is 1KiB.
+func WithCompressionThreshold(bytes int) Option {
+	return func(o *Options) {
+		o.CompressionThreshold = bytes
+	}
+}
+
+// WithAdaptiveCompression stops compressing the messages of a method that
+// do not shrink, such as already compressed blobs, which cost CPU time for
+// nothing. Once the last window messages of a method compressed were on
+// average more than ratio of their size, e.g. 0.95, its messages are sent
+// uncompressed; after reprobe they are compressed again, and the method
+// stays compressed if they now shrink. Requests, notifications and the
+// replies to a method's calls count as its messages, and the decisions are
+// shared by every connection. Server.CompressionByMethod reports them, as
+// does /compression on the metrics listener. A ratio of zero, the default,
+// compresses every message over the threshold.
+func WithAdaptiveCompression(ratio float64, window int, reprobe time.Duration) Option {
+	return func(o *Options) {
+		o.CompressionRatioLimit = ratio
+		o.CompressionWindow = window
+		o.CompressionReprobe = reprobe
+	}
+}
+
+// WithMaxRequestBytes sets the largest request body, in bytes, the server
+// reads. A request declaring a larger Content-Length is discarded unread and
+// refused with core.CodeRequestTooLarge, and the connection stays usable.
+// Compressed bodies are held to the limit once decompressed too. The default
+// is core.DefaultMaxMessageBytes; zero disables the limit.
+func WithMaxRequestBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxRequestBytes = n
+	}
+}
+
+// WithOutboundQueueSize sets how many replies and notifications each
+// connection may have waiting for its writer, a goroutine of the connection's
+// own. A client slow to take a large frame then holds up only its own
+// connection; its senders wait once the queue is full. The default is 64;
+// zero makes every sender write to the connection itself.
+func WithOutboundQueueSize(size int) Option {
+	return func(o *Options) {
+		o.OutboundQueueSize = size
+	}
+}
+
+// WithCodec offers clients that negotiate it to encode messages with codec,
+// e.g. msgpack.Codec, instead of JSON. Clients that do not ask for the codec
+// are still served JSON.
+func WithCodec(codec core.Codec) Option {
+	return func(o *Options) {
+		o.Codec = codec
+	}
+}
+
+// WithReplayMode enables deterministic replay. Requests carrying replay metadata
+// are handled with a random source and clock pinned to the recorded values,
+// available to handlers through core.RandFromContext and core.ClockFromContext.
+func WithReplayMode(enabled bool) Option {
+	return func(o *Options) {
+		o.ReplayMode = enabled
+	}
+}
+
+// WithAuthenticator requires every client to authenticate before calling
+// methods other than ping, and accepts or rejects the credentials they present
+// with authenticate, for example by checking Credentials.Token. Schemes
+// registered with Server.RegisterAuthScheme take precedence. The principal
+// returned is available to handlers through core.PrincipalFromContext.
+func WithAuthenticator(authenticate Authenticator) Option {
+	return func(o *Options) {
+		o.Authenticator = authenticate
+	}
+}
+
+// WithAuthorizer checks every call other than ping and authentication with
+// authorize before it is dispatched, e.g. with NewRoleAuthorizer. Denied calls
+// fail with core.CodeUnauthorized.
+func WithAuthorizer(authorize Authorizer) Option {
+	return func(o *Options) {
+		o.Authorizer = authorize
+	}
+}
+
+// WithTracer sets the tracer that starts a server span around every handler
+// run, continuing the trace propagated in the request metadata, e.g. an
+// otelmcp.Tracer.
+func WithTracer(tracer core.Tracer) Option {
+	return func(o *Options) {
+		o.Tracer = tracer
+	}
+}
+
+// WithAuditSink sets a sink that receives an event for every request the
+// server replies to, e.g. core.NewAuditLog on an append-only file. Failures of
+// the sink are reported through Server.Health and Server.OnError and never
+// reach the client.
+func WithAuditSink(sink core.AuditSink) Option {
+	return func(o *Options) {
+		o.AuditSink = sink
+	}
+}
+
+// WithRecorder sets a recorder that captures every processed exchange.
+// Each recorded request is stamped with the seed and time injected into its
+// handler run so the exchange can be replayed byte for byte.
+func WithRecorder(recorder Recorder) Option {
+	return func(o *Options) {
+		o.Recorder = recorder
+	}
+}
+
+// WithPortSharing serves HTTP requests (health checks, metrics, debug endpoints)
+// on the same port as MCP. The server peeks at the first bytes of each connection
+// and routes HTTP traffic to adminMux; everything else is handled as JSON-RPC.
+// With TLS enabled, the handshake completes before the peek, so HTTPS works too.
+func WithPortSharing(adminMux http.Handler) Option {
+	return func(o *Options) {
+		o.AdminHandler = adminMux
+	}
+}
+
+// WithBatchDeadlineStrategy sets how the deadline of an mcp.processModelBatch call
+// is divided among its items. Clients may override it per batch.
+func WithBatchDeadlineStrategy(strategy core.DeadlineStrategy) Option {
+	return func(o *Options) {
+		o.BatchDeadlineStrategy = strategy
+	}
+}
+
+// WithBatchParallelism sets how many items of an mcp.processModelBatch call the
+// server processes at once. Items still start in order and their responses keep
+// request order; the deadline strategy divides the batch deadline among the
+// items that share each of the n lanes.
+func WithBatchParallelism(n int) Option {
+	return func(o *Options) {
+		o.BatchParallelism = n
+	}
+}
+
+// WithSchemaValidation sets whether requests are checked against the
+// parameters and model schema their handler declares through
+// MethodDescriber, and rejected with CodeInvalidParams before the handler
+// runs if they do not match. It is enabled by default; with it disabled,
+// declared schemas are only listed to clients.
+func WithSchemaValidation(enabled bool) Option {
+	return func(o *Options) {
+		o.SchemaValidation = enabled
+	}
+}
+
+// WithParameterCoercion sets whether the values of model request parameters
+// are converted to their declared core.ParamType, with
+// core.Parameters.Coerce, before the handler runs, so a ParamInt decoded
+// from JSON as a float64 reaches it as an int64. Requests with values that
+// do not fit their type are rejected with CodeInvalidParams. Coercion runs
+// before schema validation. It is disabled by default.
+func WithParameterCoercion(enabled bool) Option {
+	return func(o *Options) {
+		o.ParameterCoercion = enabled
+	}
+}
+
+// WithResponseValidation enables checks on handler responses before they are sent:
+// the ID must match the request, Success and ErrorMessage must agree, Results
+// must not be nil, and Metadata must keep within WithAnnotationLimits. Successful responses must match the result schema the
+// handler declares through MethodDescriber, if any, and handlers implementing
+// ResponseValidator add their own checks. Invalid responses are logged and replaced with an internal error.
+func WithResponseValidation(enabled bool) Option {
+	return func(o *Options) {
+		o.ResponseValidation = enabled
+	}
+}
+
+// WithResponseValidationLogOnly makes response validation log violations while
+// still sending the handler's response as-is. Enabling it also enables validation.
+func WithResponseValidationLogOnly(enabled bool) Option {
+	return func(o *Options) {
+		o.ResponseValidationLogOnly = enabled
+		if enabled {
+			o.ResponseValidation = true
+		}
+	}
+}
+
+// WithAnnotationLimits caps the Metadata annotations response validation lets
+// through, by number of entries and by the bytes of their keys and values
+// together. The defaults are 64 entries and 16KiB; zero lifts either limit.
+func WithAnnotationLimits(count, bytes int) Option {
+	return func(o *Options) {
+		o.MaxAnnotations = count
+		o.MaxAnnotationBytes = bytes
+	}
+}
+
+// WithPanicHandler sets a function told of every panic recovered while
+// handling a request, instead of logging it with its stack. The request
+// fails with a generic internal error either way, and the connection stays
+// open.
+func WithPanicHandler(handler PanicHandler) Option {
+	return func(o *Options) {
+		o.PanicHandler = handler
+	}
+}
+
+// WithMiddleware adds middleware wrapping the processing of every model
+// request: mcp.processModel, mcp.processModelStream, each item of
+// mcp.processModelBatch and the jobs of mcp.submitModel. Middleware runs in
+// the order given, the first outermost, after authentication and before
+// schema validation, so it sees requests that fail validation too.
+func WithMiddleware(middleware ...core.Middleware) Option {
+	return func(o *Options) {
+		o.Middleware = append(o.Middleware, middleware...)
+	}
+}
+
+// WithTaskBudgets turns on strict task accounting: starting a goroutine or
+// timer that takes a feature over its budget logs a warning with the stacks
+// that started the feature's live tasks. Features without a budget are only
+// counted, as they are by default. See Stats for the counts.
+func WithTaskBudgets(budgets map[core.TaskFeature]int) Option {
+	return func(o *Options) {
+		o.TaskBudgets = budgets
+	}
+}
+
+// WithRateLimit limits each connection to rps requests per second on average,
+// with bursts of up to burst requests. Requests over the limit, other than
+// pings, fail with core.CodeRateLimited and a core.RateLimitData hint of when
+// to retry.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(o *Options) {
+		o.RateLimit = RateLimit{RPS: rps, Burst: burst}
+	}
+}
+
+// WithMethodRateLimits gives the listed methods their own per-connection
+// buckets instead of the one set by WithRateLimit. A zero RPS exempts a method.
+func WithMethodRateLimits(limits map[string]RateLimit) Option {
+	return func(o *Options) {
+		o.MethodRateLimits = limits
+	}
+}
+
+// WithOrderedNotifications makes Publish hold back the listed notifications
+// from any client the server is handling a request for, until the reply to
+// that request has been written. Clients then always see a response before a
+// notification published while it was being prepared. Other notifications,
+// and clients with no request in progress, are sent to straight away.
+func WithOrderedNotifications(methods ...string) Option {
+	return func(o *Options) {
+		o.OrderedNotifications = methods
+	}
+}
+
+// WithEchoMetadata sets the request metadata keys the server copies into each
+// response, replacing the default of core.MetadataTraceID. Values appended by
+// the handler with core.AppendMetadata are echoed too. Call it with no keys to
+// echo nothing.
+func WithEchoMetadata(keys ...string) Option {
+	return func(o *Options) {
+		o.EchoMetadata = keys
+	}
+}
+
+// WithJournal enables request journaling in dir. Every request is appended to a
+// write-ahead log before dispatch and marked complete once replied to, so that
+// work accepted by a process that crashed is reported by the next Start through
+// RecoveredRequests and OnRecovery.
+func WithJournal(dir string) Option {
+	return func(o *Options) {
+		o.JournalDir = dir
+	}
+}
+
+// WithJournalSync sets when journal writes are fsynced. The default,
+// JournalSyncAlways, syncs every entry; JournalSyncNever trades durability
+// across machine crashes for throughput.
+func WithJournalSync(policy JournalSyncPolicy) Option {
+	return func(o *Options) {
+		o.JournalSync = policy
+	}
+}
+
+// WithJournalMaxSize sets the size in bytes at which the journal starts a new
+// segment. Segments are deleted once all requests in them have completed.
+// Zero disables rotation.
+func WithJournalMaxSize(size int64) Option {
+	return func(o *Options) {
+		o.JournalMaxSize = size
+	}
+}
+
+// WithJournalPayloads stores full request params in the journal so recovered
+// requests can be re-enqueued as-is. By default only a SHA-256 hash is stored.
+func WithJournalPayloads(enabled bool) Option {
+	return func(o *Options) {
+		o.JournalPayloads = enabled
+	}
+}
+
+// WithDurableBuffer limits the notifications buffered for each durable
+// subscription while no connection holds it, by count and by payload bytes.
+// Once either is exceeded the oldest are dropped and counted in Stats.
+func WithDurableBuffer(count, bytes int) Option {
+	return func(o *Options) {
+		o.DurableBufferCount = count
+		o.DurableBufferBytes = bytes
+	}
+}
+
+// WithDurableTTL sets how long a durable subscription and its buffer are kept
+// after its connection ends; one not resumed by then is discarded.
+func WithDurableTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.DurableTTL = ttl
+	}
+}
+
+// WithFeatures sets the features the server announces in the handshake.
+// Requests needing a feature left out, or one a client did not announce, are
+// refused with core.CodeUnsupportedCapability.
+func WithFeatures(features ...core.Feature) Option {
+	return func(o *Options) {
+		o.Features = features
+	}
+}
+
+// WithMinProtocolVersion refuses clients that initialize with a protocol
+// version below version, and clients that send requests without initializing,
+// with core.CodeUnsupportedVersion.
+func WithMinProtocolVersion(version int) Option {
+	return func(o *Options) {
+		o.MinProtocolVersion = version
+	}
+}
+
+// WithSubprocessMode makes the server behave as the child process of a
+// Subprocess handler group: when the parent sends core.MethodShutdown, the
+// server answers the requests it is handling on that connection and then
+// closes it, so ServeStdio returns and the child can exit before it is
+// signalled.
+func WithSubprocessMode(enabled bool) Option {
+	return func(o *Options) {
+		o.SubprocessMode = enabled
+	}
+}
+
+// WithTLSEnabled controls whether connections use TLS. TLS needs a
+// certificate and key, set with WithTLSFiles, which enables it too.
+func WithTLSEnabled(enabled bool) Option {
+	return func(o *Options) {
+		o.EnableTLS = enabled
+	}
+}
+
+// WithTLSFiles enables TLS with the PEM certificate and key files at the
+// given paths.
+func WithTLSFiles(certPath, keyPath string) Option {
 	return func(o *Options) {
 		o.EnableTLS = true
 		o.CertificatePath = certPath
@@ -73,16 +894,36 @@ func WithTLS(certPath, keyPath string) Option {
 	}
 }
 
-// Add your option functions here, e.g. WithHost, WithPort, etc.
+// WithTLS enables TLS with the specified certificate and key.
+//
+// Deprecated: Use WithTLSFiles, which WithTLS calls.
+func WithTLS(certPath, keyPath string) Option {
+	return WithTLSFiles(certPath, keyPath)
+}
+
+// WithTLSSessionTickets controls whether TLS clients may resume earlier sessions
+// via session tickets, letting reconnects skip most of the handshake. It is enabled
+// by default; disable it to force a full handshake on every connection.
+func WithTLSSessionTickets(enabled bool) Option {
+	return func(o *Options) {
+		o.TLSSessionTickets = enabled
+	}
+}
 
+// WithCertificatePath sets the path of the TLS certificate file without
+// enabling TLS.
+//
+// Deprecated: Use WithTLSFiles to set the certificate and key together.
 func WithCertificatePath(path string) Option {
 	return func(o *Options) {
 		o.CertificatePath = path
 	}
 }
 
-// Add your option functions here, e.g. WithHost, WithPort, etc.
-
+// WithCertificateKeyPath sets the path of the TLS key file without enabling
+// TLS.
+//
+// Deprecated: Use WithTLSFiles to set the certificate and key together.
 func WithCertificateKeyPath(path string) Option {
 	return func(o *Options) {
 		o.CertificateKeyPath = path
@@ -1,23 +1,212 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/metrics"
+	"github.com/narcolepticfox/mcp/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServerDefaultOptions(t *testing.T) {
 	options := DefaultOptions()
 
 	// Check that default options are set correctly
+	assert.Equal(t, core.DefaultLogger(), options.Logger, "Default Logger should write to slog")
+	assert.Equal(t, core.NopMetrics(), options.Metrics, "Default Metrics should discard measurements")
+	assert.Equal(t, core.TCPTransport{}, options.Transport, "Default Transport should be TCP")
+	assert.Zero(t, options.InheritedListenerFD, "Default InheritedListenerFD should open a new listener")
 	assert.Equal(t, "127.0.0.1", options.Host, "Default Host should be 127.0.0.1")
 	assert.Equal(t, 5000, options.Port, "Default Port should be 5000")
 	assert.Equal(t, 10, options.MaxConcurrentClients, "Default MaxConcurrentClients should be 10")
+	assert.Zero(t, options.MaxConcurrentRequests, "Default MaxConcurrentRequests should be unbounded")
+	assert.Zero(t, options.RequestQueueSize, "Default RequestQueueSize should be zero")
+	assert.Zero(t, options.PrincipalConcurrency, "Default PrincipalConcurrency should be zero")
+	assert.Zero(t, options.PrincipalReserve, "Default PrincipalReserve should be zero")
+	assert.Equal(t, 10*time.Minute, options.JobRetention, "Default JobRetention should be 10 minutes")
+	assert.Equal(t, core.CompressionNone, options.Compression, "Default Compression should be none")
+	assert.Empty(t, options.CompressionFallbacks, "Default CompressionFallbacks should be empty")
+	assert.Equal(t, 1024, options.CompressionThreshold, "Default CompressionThreshold should be 1KiB")
+	assert.Nil(t, options.Codec, "Default Codec should be nil")
+	assert.Equal(t, core.DefaultMaxMessageBytes, options.MaxRequestBytes, "Default MaxRequestBytes should be 32MiB")
+	assert.Zero(t, options.MaxConcurrentJobs, "Default MaxConcurrentJobs should be unbounded")
+	assert.Nil(t, options.BlobStore, "Default BlobStore should be nil")
+	assert.Equal(t, 10*time.Minute, options.BlobUploadTTL, "Default BlobUploadTTL should be 10 minutes")
 	assert.Equal(t, 30*time.Second, options.ConnectionTimeout, "Default ConnectionTimeout should be 30s")
 	assert.False(t, options.EnableTLS, "Default EnableTLS should be false")
+	assert.True(t, options.TLSSessionTickets, "Default TLSSessionTickets should be true")
 	assert.Empty(t, options.CertificatePath, "Default CertificatePath should be empty")
 	assert.Empty(t, options.CertificateKeyPath, "Default CertificateKeyPath should be empty")
+	assert.Zero(t, options.IdleTimeout, "Default IdleTimeout should disable the check")
+	assert.Zero(t, options.WriteTimeout, "Default WriteTimeout should disable the check")
+	assert.Zero(t, options.SessionTTL, "Default SessionTTL should keep sessions until the connection closes")
+	assert.Empty(t, options.OrderedNotifications, "Default OrderedNotifications should be empty")
+	assert.Zero(t, options.StallThreshold, "Default StallThreshold should disable stall detection")
+	assert.False(t, options.StallClose, "Default StallClose should be false")
+	assert.Equal(t, core.DeadlineFirstComeAll, options.BatchDeadlineStrategy, "Default BatchDeadlineStrategy should be FirstComeAll")
+	assert.Equal(t, 1, options.BatchParallelism, "Default BatchParallelism should be 1")
+	assert.True(t, options.SchemaValidation, "Default SchemaValidation should be true")
+	assert.False(t, options.ResponseValidation, "Default ResponseValidation should be false")
+	assert.Equal(t, 64, options.MaxAnnotations, "Default MaxAnnotations should be 64")
+	assert.Equal(t, 16<<10, options.MaxAnnotationBytes, "Default MaxAnnotationBytes should be 16KiB")
+	assert.Nil(t, options.PanicHandler, "Default PanicHandler should log panics")
+	assert.Empty(t, options.Middleware, "Default should install no middleware")
+	assert.Equal(t, []string{core.MetadataTraceID}, options.EchoMetadata, "Default EchoMetadata should be the trace ID")
+	assert.Empty(t, options.TaskBudgets, "Default TaskBudgets should not limit any feature")
+	assert.Nil(t, options.AuditSink, "Default AuditSink should disable auditing")
+	assert.Equal(t, core.NopTracer(), options.Tracer, "Default Tracer should record nothing")
+	assert.Nil(t, options.Authenticator, "Default Authenticator should not require authentication")
+	assert.Nil(t, options.Authorizer, "Default Authorizer should allow every method")
+	assert.Zero(t, options.RateLimit, "Default RateLimit should be unlimited")
+	assert.Empty(t, options.MethodRateLimits, "Default MethodRateLimits should be empty")
+	assert.Empty(t, options.MetricsAddr, "Default MetricsAddr should disable the metrics listener")
+	assert.Equal(t, 64, options.OutboundQueueSize, "Default OutboundQueueSize should be 64")
+	assert.Empty(t, options.HealthAddr, "Default HealthAddr should disable the health check listener")
+	assert.Nil(t, options.ReadinessCheck, "Default ReadinessCheck should be nil")
+	assert.Empty(t, options.JournalDir, "Default JournalDir should disable journaling")
+	assert.Equal(t, JournalSyncAlways, options.JournalSync, "Default JournalSync should be always")
+	assert.Equal(t, int64(64<<20), options.JournalMaxSize, "Default JournalMaxSize should be 64MiB")
+	assert.False(t, options.JournalPayloads, "Default JournalPayloads should be false")
+}
+
+func TestWithName(t *testing.T) {
+	options := DefaultOptions()
+	assert.Empty(t, options.Name, "Default Name should be empty")
+
+	WithName("primary")(&options)
+	assert.Equal(t, "primary", options.Name, "Name should be updated")
+}
+
+func TestWithLogger(t *testing.T) {
+	options := DefaultOptions()
+	logger := testutil.NewCaptureLogger()
+	option := WithLogger(logger)
+	option(&options)
+
+	assert.Same(t, logger, options.Logger, "Logger should be updated")
+}
+
+func TestWithMetrics(t *testing.T) {
+	options := DefaultOptions()
+	collector := metrics.NewExpvarCollector("mcp_options_test_server")
+	option := WithMetrics(collector)
+	option(&options)
+
+	assert.Same(t, collector, options.Metrics, "Metrics should be updated")
+}
+
+func TestWithAuthenticator(t *testing.T) {
+	options := DefaultOptions()
+	option := WithAuthenticator(func(context.Context, core.Credentials) (core.Principal, error) {
+		return core.Principal{ID: "alice"}, nil
+	})
+	option(&options)
+
+	require.NotNil(t, options.Authenticator, "Authenticator should be set")
+	principal, err := options.Authenticator(context.Background(), core.Credentials{})
+	assert.NoError(t, err, "Authenticator should be the one passed")
+	assert.Equal(t, "alice", principal.ID, "Authenticator should be the one passed")
+}
+
+func TestWithAuthorizer(t *testing.T) {
+	options := DefaultOptions()
+	option := WithAuthorizer(NewRoleAuthorizer(map[string][]string{"builder": {core.MethodProcessModel}}))
+	option(&options)
+
+	require.NotNil(t, options.Authorizer, "Authorizer should be set")
+	builder := core.Principal{ID: "ci", Roles: []string{"builder"}}
+	assert.NoError(t, options.Authorizer(context.Background(), builder, core.MethodProcessModel), "Authorizer should be the one passed")
+}
+
+func TestWithRateLimit(t *testing.T) {
+	options := DefaultOptions()
+	option := WithRateLimit(50, 10)
+	option(&options)
+
+	assert.Equal(t, RateLimit{RPS: 50, Burst: 10}, options.RateLimit, "RateLimit should be updated")
+}
+
+func TestWithMethodRateLimits(t *testing.T) {
+	options := DefaultOptions()
+	limits := map[string]RateLimit{core.MethodProcessModelBatch: {RPS: 1, Burst: 1}}
+	option := WithMethodRateLimits(limits)
+	option(&options)
+
+	assert.Equal(t, limits, options.MethodRateLimits, "MethodRateLimits should be updated")
+}
+
+func TestWithTracer(t *testing.T) {
+	options := DefaultOptions()
+	tracer := &struct{ core.Tracer }{core.NopTracer()}
+	option := WithTracer(tracer)
+	option(&options)
+
+	assert.Same(t, tracer, options.Tracer, "Tracer should be updated")
+}
+
+func TestWithAuditSink(t *testing.T) {
+	options := DefaultOptions()
+	sink := testutil.NewFlakySink()
+	option := WithAuditSink(sink)
+	option(&options)
+
+	assert.Same(t, sink, options.AuditSink, "AuditSink should be updated")
+}
+
+func TestWithTaskBudgets(t *testing.T) {
+	options := DefaultOptions()
+	budgets := map[core.TaskFeature]int{core.TaskEvents: 4}
+	option := WithTaskBudgets(budgets)
+	option(&options)
+
+	assert.Equal(t, budgets, options.TaskBudgets, "TaskBudgets should be updated")
+}
+
+func TestWithEchoMetadata(t *testing.T) {
+	options := DefaultOptions()
+	option := WithEchoMetadata(core.MetadataTenantID)
+	option(&options)
+
+	assert.Equal(t, []string{core.MetadataTenantID}, options.EchoMetadata, "EchoMetadata should be updated")
+}
+
+func TestWithMetricsAddr(t *testing.T) {
+	options := DefaultOptions()
+	option := WithMetricsAddr(":9090")
+	option(&options)
+
+	assert.Equal(t, ":9090", options.MetricsAddr, "MetricsAddr should be updated")
+}
+
+func TestWithHealthAddr(t *testing.T) {
+	options := DefaultOptions()
+	option := WithHealthAddr(":8081")
+	option(&options)
+
+	assert.Equal(t, ":8081", options.HealthAddr, "HealthAddr should be updated")
+}
+
+func TestWithReadinessCheck(t *testing.T) {
+	options := DefaultOptions()
+	errNotLoaded := errors.New("model not loaded")
+	option := WithReadinessCheck(func() error { return errNotLoaded })
+	option(&options)
+
+	require.NotNil(t, options.ReadinessCheck, "ReadinessCheck should be set")
+	assert.Equal(t, errNotLoaded, options.ReadinessCheck(), "ReadinessCheck should be updated")
+}
+
+func TestWithOutboundQueueSize(t *testing.T) {
+	options := DefaultOptions()
+	option := WithOutboundQueueSize(8)
+	option(&options)
+
+	assert.Equal(t, 8, options.OutboundQueueSize, "OutboundQueueSize should be updated")
 }
 
 func TestWithHost(t *testing.T) {
@@ -36,6 +225,39 @@ func TestWithPort(t *testing.T) {
 	assert.Equal(t, 9999, options.Port, "Port should be updated")
 }
 
+func TestWithListenAddrs(t *testing.T) {
+	options := DefaultOptions()
+	option := WithListenAddrs("127.0.0.1:5000", "unix:/tmp/mcp.sock")
+	option(&options)
+
+	assert.Equal(t, []string{"127.0.0.1:5000", "unix:/tmp/mcp.sock"}, options.ListenAddrs, "Listen addresses should be updated")
+}
+
+func TestWithSubprocessMode(t *testing.T) {
+	options := DefaultOptions()
+	option := WithSubprocessMode(true)
+	option(&options)
+
+	assert.True(t, options.SubprocessMode, "Subprocess mode should be enabled")
+}
+
+func TestWithTransport(t *testing.T) {
+	options := DefaultOptions()
+	transport := core.NewInProcessTransport()
+	option := WithTransport(transport)
+	option(&options)
+
+	assert.Same(t, transport, options.Transport, "Transport should be updated")
+}
+
+func TestWithUnixSocket(t *testing.T) {
+	options := DefaultOptions()
+	option := WithUnixSocket("/tmp/mcp.sock")
+	option(&options)
+
+	assert.Equal(t, core.UnixTransport{Path: "/tmp/mcp.sock"}, options.Transport, "Transport should be a unix socket")
+}
+
 func TestWithMaxConcurrentClients(t *testing.T) {
 	options := DefaultOptions()
 	option := WithMaxConcurrentClients(50)
@@ -44,6 +266,73 @@ func TestWithMaxConcurrentClients(t *testing.T) {
 	assert.Equal(t, 50, options.MaxConcurrentClients, "MaxConcurrentClients should be updated")
 }
 
+func TestWithMaxConcurrentRequests(t *testing.T) {
+	options := DefaultOptions()
+	option := WithMaxConcurrentRequests(8)
+	option(&options)
+
+	assert.Equal(t, 8, options.MaxConcurrentRequests, "MaxConcurrentRequests should be updated")
+}
+
+func TestWithRequestQueueSize(t *testing.T) {
+	options := DefaultOptions()
+	option := WithRequestQueueSize(32)
+	option(&options)
+
+	assert.Equal(t, 32, options.RequestQueueSize, "RequestQueueSize should be updated")
+}
+
+func TestWithPrincipalConcurrencyLimit(t *testing.T) {
+	options := DefaultOptions()
+	option := WithPrincipalConcurrencyLimit(4, map[string]int{"batch": 1})
+	option(&options)
+
+	assert.Equal(t, 4, options.PrincipalConcurrency, "PrincipalConcurrency should be updated")
+	assert.Equal(t, map[string]int{"batch": 1}, options.PrincipalOverrides, "PrincipalOverrides should be updated")
+}
+
+func TestWithPrincipalReserve(t *testing.T) {
+	options := DefaultOptions()
+	option := WithPrincipalReserve(2)
+	option(&options)
+
+	assert.Equal(t, 2, options.PrincipalReserve, "PrincipalReserve should be updated")
+}
+
+func TestWithJobRetention(t *testing.T) {
+	options := DefaultOptions()
+	option := WithJobRetention(time.Hour)
+	option(&options)
+
+	assert.Equal(t, time.Hour, options.JobRetention, "JobRetention should be updated")
+}
+
+func TestWithMaxConcurrentJobs(t *testing.T) {
+	options := DefaultOptions()
+	option := WithMaxConcurrentJobs(4)
+	option(&options)
+
+	assert.Equal(t, 4, options.MaxConcurrentJobs, "MaxConcurrentJobs should be updated")
+}
+
+func TestWithBlobStore(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	require.NoError(t, err, "Creating the store should succeed")
+	options := DefaultOptions()
+	option := WithBlobStore(store)
+	option(&options)
+
+	assert.Same(t, store, options.BlobStore, "BlobStore should be updated")
+}
+
+func TestWithBlobUploadTTL(t *testing.T) {
+	options := DefaultOptions()
+	option := WithBlobUploadTTL(time.Minute)
+	option(&options)
+
+	assert.Equal(t, time.Minute, options.BlobUploadTTL, "BlobUploadTTL should be updated")
+}
+
 func TestWithConnectionTimeout(t *testing.T) {
 	options := DefaultOptions()
 	timeout := 10 * time.Second
@@ -53,6 +342,149 @@ func TestWithConnectionTimeout(t *testing.T) {
 	assert.Equal(t, timeout, options.ConnectionTimeout, "ConnectionTimeout should be updated")
 }
 
+func TestWithSessionTTL(t *testing.T) {
+	options := DefaultOptions()
+	option := WithSessionTTL(time.Minute)
+	option(&options)
+
+	assert.Equal(t, time.Minute, options.SessionTTL, "SessionTTL should be updated")
+}
+
+func TestWithIdleTimeout(t *testing.T) {
+	options := DefaultOptions()
+	timeout := time.Minute
+	option := WithIdleTimeout(timeout)
+	option(&options)
+
+	assert.Equal(t, timeout, options.IdleTimeout, "IdleTimeout should be updated")
+}
+
+func TestWithReadTimeout(t *testing.T) {
+	options := DefaultOptions()
+	timeout := time.Minute
+	option := WithReadTimeout(timeout)
+	option(&options)
+
+	assert.Equal(t, timeout, options.IdleTimeout, "ReadTimeout should set the IdleTimeout")
+}
+
+func TestWithWriteTimeout(t *testing.T) {
+	options := DefaultOptions()
+	timeout := time.Minute
+	option := WithWriteTimeout(timeout)
+	option(&options)
+
+	assert.Equal(t, timeout, options.WriteTimeout, "WriteTimeout should be updated")
+}
+
+func TestWithHandlerTimeout(t *testing.T) {
+	options := DefaultOptions()
+	assert.Zero(t, options.HandlerTimeout, "Handlers should not be limited by default")
+
+	WithHandlerTimeout(5 * time.Second)(&options)
+	assert.Equal(t, 5*time.Second, options.HandlerTimeout, "HandlerTimeout should be updated")
+}
+
+func TestWithMethodTimeouts(t *testing.T) {
+	options := DefaultOptions()
+	timeouts := map[string]time.Duration{core.MethodProcessModel: time.Minute}
+	WithMethodTimeouts(timeouts)(&options)
+
+	assert.Equal(t, timeouts, options.MethodTimeouts, "MethodTimeouts should be updated")
+}
+
+func TestWithOrderedNotifications(t *testing.T) {
+	options := DefaultOptions()
+	option := WithOrderedNotifications("app.modelUpdated")
+	option(&options)
+
+	assert.Equal(t, []string{"app.modelUpdated"}, options.OrderedNotifications, "OrderedNotifications should be updated")
+}
+
+func TestWithStallDetection(t *testing.T) {
+	options := DefaultOptions()
+	option := WithStallDetection(5*time.Second, true)
+	option(&options)
+
+	assert.Equal(t, 5*time.Second, options.StallThreshold, "StallThreshold should be updated")
+	assert.True(t, options.StallClose, "StallClose should be enabled")
+}
+
+func TestWithCompression(t *testing.T) {
+	options := DefaultOptions()
+	option := WithCompression(core.CompressionGzip)
+	option(&options)
+
+	assert.Equal(t, core.CompressionGzip, options.Compression, "Compression should be updated")
+	assert.Empty(t, options.CompressionFallbacks, "A single algorithm should have no fallbacks")
+
+	WithCompression("zstd", core.CompressionGzip)(&options)
+	assert.Equal(t, core.Compression("zstd"), options.Compression, "First algorithm should be preferred")
+	assert.Equal(t, []core.Compression{core.CompressionGzip}, options.CompressionFallbacks, "Later algorithms should be fallbacks")
+
+	WithCompression()(&options)
+	assert.Equal(t, core.CompressionNone, options.Compression, "No algorithms should disable compression")
+	assert.Empty(t, options.CompressionFallbacks, "No algorithms should leave no fallbacks")
+}
+
+func TestWithCompressionThreshold(t *testing.T) {
+	options := DefaultOptions()
+	option := WithCompressionThreshold(4096)
+	option(&options)
+
+	assert.Equal(t, 4096, options.CompressionThreshold, "CompressionThreshold should be updated")
+}
+
+func TestWithAdaptiveCompression(t *testing.T) {
+	options := DefaultOptions()
+	option := WithAdaptiveCompression(0.95, 20, time.Minute)
+	option(&options)
+
+	assert.Equal(t, 0.95, options.CompressionRatioLimit, "CompressionRatioLimit should be updated")
+	assert.Equal(t, 20, options.CompressionWindow, "CompressionWindow should be updated")
+	assert.Equal(t, time.Minute, options.CompressionReprobe, "CompressionReprobe should be updated")
+}
+
+func TestWithMaxRequestBytes(t *testing.T) {
+	options := DefaultOptions()
+	option := WithMaxRequestBytes(0)
+	option(&options)
+
+	assert.Zero(t, options.MaxRequestBytes, "MaxRequestBytes should be disabled")
+}
+
+func TestWithCodec(t *testing.T) {
+	options := DefaultOptions()
+	option := WithCodec(core.JSONCodec)
+	option(&options)
+
+	assert.Equal(t, core.JSONCodec, options.Codec, "Codec should be updated")
+}
+
+func TestWithTLSEnabled(t *testing.T) {
+	options := DefaultOptions()
+	WithTLSFiles("/path/to/cert.pem", "/path/to/key.pem")(&options)
+	WithTLSEnabled(false)(&options)
+
+	assert.False(t, options.EnableTLS, "EnableTLS should be updated")
+	assert.Equal(t, "/path/to/cert.pem", options.CertificatePath, "Disabling TLS should keep the certificate path")
+
+	WithTLSEnabled(true)(&options)
+	assert.True(t, options.EnableTLS, "EnableTLS should be updated")
+}
+
+func TestWithTLSFiles(t *testing.T) {
+	options := DefaultOptions()
+	certPath := "/path/to/cert.pem"
+	keyPath := "/path/to/key.pem"
+	option := WithTLSFiles(certPath, keyPath)
+	option(&options)
+
+	assert.True(t, options.EnableTLS, "EnableTLS should be updated")
+	assert.Equal(t, certPath, options.CertificatePath, "CertificatePath should be updated")
+	assert.Equal(t, keyPath, options.CertificateKeyPath, "CertificateKeyPath should be updated")
+}
+
 func TestWithTLS(t *testing.T) {
 	options := DefaultOptions()
 	cerPath := "/path/to/cert.pem"
@@ -60,7 +492,17 @@ func TestWithTLS(t *testing.T) {
 	option := WithTLS(cerPath, keyPath)
 	option(&options)
 
-	assert.True(t, options.EnableTLS, "EnableTLS should be updated")
+	want := DefaultOptions()
+	WithTLSFiles(cerPath, keyPath)(&want)
+	assert.Equal(t, want, options, "WithTLS should set what WithTLSFiles sets")
+}
+
+func TestWithTLSSessionTickets(t *testing.T) {
+	options := DefaultOptions()
+	option := WithTLSSessionTickets(false)
+	option(&options)
+
+	assert.False(t, options.TLSSessionTickets, "TLSSessionTickets should be updated")
 }
 
 func TestWithCertificatePath(t *testing.T) {
@@ -70,6 +512,7 @@ func TestWithCertificatePath(t *testing.T) {
 	option(&options)
 
 	assert.Equal(t, path, options.CertificatePath, "CertificatePath should be updated")
+	assert.False(t, options.EnableTLS, "WithCertificatePath should not enable TLS")
 }
 
 func TestWithCertificateKeyPath(t *testing.T) {
@@ -79,6 +522,7 @@ func TestWithCertificateKeyPath(t *testing.T) {
 	option(&options)
 
 	assert.Equal(t, path, options.CertificateKeyPath, "CertificateKeyPath should be updated")
+	assert.False(t, options.EnableTLS, "WithCertificateKeyPath should not enable TLS")
 }
 
 func TestServerOptionChaining(t *testing.T) {
@@ -91,10 +535,256 @@ func TestServerOptionChaining(t *testing.T) {
 	)
 
 	// Extract options from server for testing
-	options := server.options
+	options := server.Options()
 
 	assert.Equal(t, "0.0.0.0", options.Host, "Host should be updated")
 	assert.Equal(t, 8888, options.Port, "Port should be updated")
 	assert.Equal(t, 20, options.MaxConcurrentClients, "MaxConcurrentClients should be updated")
 	assert.Equal(t, 15*time.Second, options.ConnectionTimeout, "ConnectionTimeout should be updated")
 }
+
+func TestServerOptionsCopy(t *testing.T) {
+	server := New(
+		WithListenAddrs("127.0.0.1:0"),
+		WithPrincipalConcurrencyLimit(2, map[string]int{"alice": 4}),
+		WithEchoMetadata("trace"),
+		WithFeatures(core.FeatureStreaming),
+	)
+
+	options := server.Options()
+	options.Port = 1
+	options.ListenAddrs[0] = "0.0.0.0:0"
+	options.PrincipalOverrides["alice"] = 40
+	options.EchoMetadata[0] = "tenant"
+	options.Features[0] = core.FeatureBatch
+
+	live := server.Options()
+	assert.NotEqual(t, 1, live.Port, "Changing the copy should not change the server's port")
+	assert.Equal(t, []string{"127.0.0.1:0"}, live.ListenAddrs, "Changing the copy should not change the server's addresses")
+	assert.Equal(t, map[string]int{"alice": 4}, live.PrincipalOverrides, "Changing the copy should not change the server's overrides")
+	assert.Equal(t, []string{"trace"}, live.EchoMetadata, "Changing the copy should not change the echoed metadata")
+	assert.Equal(t, []core.Feature{core.FeatureStreaming}, live.Features, "Changing the copy should not change the server's features")
+}
+
+func TestWithBatchDeadlineStrategy(t *testing.T) {
+	options := DefaultOptions()
+	option := WithBatchDeadlineStrategy(core.DeadlineWeighted)
+	option(&options)
+
+	assert.Equal(t, core.DeadlineWeighted, options.BatchDeadlineStrategy, "BatchDeadlineStrategy should be updated")
+}
+
+func TestWithBatchParallelism(t *testing.T) {
+	options := DefaultOptions()
+	option := WithBatchParallelism(8)
+	option(&options)
+
+	assert.Equal(t, 8, options.BatchParallelism, "BatchParallelism should be updated")
+}
+
+func TestWithParameterCoercion(t *testing.T) {
+	options := DefaultOptions()
+	assert.False(t, options.ParameterCoercion, "Parameter coercion should be disabled by default")
+
+	WithParameterCoercion(true)(&options)
+	assert.True(t, options.ParameterCoercion, "ParameterCoercion should be updated")
+}
+
+func TestWithSchemaValidation(t *testing.T) {
+	options := DefaultOptions()
+	option := WithSchemaValidation(false)
+	option(&options)
+
+	assert.False(t, options.SchemaValidation, "SchemaValidation should be disabled")
+}
+
+func TestWithResponseValidation(t *testing.T) {
+	options := DefaultOptions()
+	option := WithResponseValidation(true)
+	option(&options)
+
+	assert.True(t, options.ResponseValidation, "ResponseValidation should be enabled")
+	assert.False(t, options.ResponseValidationLogOnly, "ResponseValidationLogOnly should stay disabled")
+}
+
+func TestWithAnnotationLimits(t *testing.T) {
+	options := DefaultOptions()
+	option := WithAnnotationLimits(8, 512)
+	option(&options)
+
+	assert.Equal(t, 8, options.MaxAnnotations, "MaxAnnotations should be updated")
+	assert.Equal(t, 512, options.MaxAnnotationBytes, "MaxAnnotationBytes should be updated")
+}
+
+func TestWithPanicHandler(t *testing.T) {
+	var called bool
+	options := DefaultOptions()
+	option := WithPanicHandler(func(string, interface{}, []byte) { called = true })
+	option(&options)
+
+	require.NotNil(t, options.PanicHandler, "PanicHandler should be set")
+	options.PanicHandler(core.MethodProcessModel, "boom", nil)
+	assert.True(t, called, "PanicHandler should be the one given")
+}
+
+func TestWithMiddleware(t *testing.T) {
+	options := DefaultOptions()
+	nop := func(method string, next core.ProcessFunc) core.ProcessFunc { return next }
+	WithMiddleware(nop)(&options)
+	WithMiddleware(nop, nop)(&options)
+
+	assert.Len(t, options.Middleware, 3, "Middleware should be appended")
+}
+
+func TestWithResponseValidationLogOnly(t *testing.T) {
+	options := DefaultOptions()
+	option := WithResponseValidationLogOnly(true)
+	option(&options)
+
+	assert.True(t, options.ResponseValidation, "ResponseValidation should be enabled")
+	assert.True(t, options.ResponseValidationLogOnly, "ResponseValidationLogOnly should be enabled")
+}
+
+func TestWithJournal(t *testing.T) {
+	options := DefaultOptions()
+	option := WithJournal("/var/lib/mcp/journal")
+	option(&options)
+
+	assert.Equal(t, "/var/lib/mcp/journal", options.JournalDir, "JournalDir should be updated")
+}
+
+func TestWithJournalSync(t *testing.T) {
+	options := DefaultOptions()
+	option := WithJournalSync(JournalSyncNever)
+	option(&options)
+
+	assert.Equal(t, JournalSyncNever, options.JournalSync, "JournalSync should be updated")
+}
+
+func TestWithJournalMaxSize(t *testing.T) {
+	options := DefaultOptions()
+	option := WithJournalMaxSize(1 << 20)
+	option(&options)
+
+	assert.Equal(t, int64(1<<20), options.JournalMaxSize, "JournalMaxSize should be updated")
+}
+
+func TestWithJournalPayloads(t *testing.T) {
+	options := DefaultOptions()
+	option := WithJournalPayloads(true)
+	option(&options)
+
+	assert.True(t, options.JournalPayloads, "JournalPayloads should be updated")
+}
+
+func TestWithDurableBuffer(t *testing.T) {
+	options := DefaultOptions()
+	option := WithDurableBuffer(50, 4096)
+	option(&options)
+
+	assert.Equal(t, 50, options.DurableBufferCount, "DurableBufferCount should be updated")
+	assert.Equal(t, 4096, options.DurableBufferBytes, "DurableBufferBytes should be updated")
+}
+
+func TestWithDurableTTL(t *testing.T) {
+	options := DefaultOptions()
+	option := WithDurableTTL(time.Hour)
+	option(&options)
+
+	assert.Equal(t, time.Hour, options.DurableTTL, "DurableTTL should be updated")
+}
+
+func TestWithFeatures(t *testing.T) {
+	options := DefaultOptions()
+	option := WithFeatures(core.FeatureStreaming)
+	option(&options)
+
+	assert.Equal(t, []core.Feature{core.FeatureStreaming}, options.Features, "Features should be updated")
+}
+
+func TestWithMinProtocolVersion(t *testing.T) {
+	options := DefaultOptions()
+	option := WithMinProtocolVersion(2)
+	option(&options)
+
+	assert.Equal(t, 2, options.MinProtocolVersion, "MinProtocolVersion should be updated")
+}
+
+func TestWithInheritedListener(t *testing.T) {
+	options := DefaultOptions()
+	option := WithInheritedListener(3)
+	option(&options)
+
+	assert.Equal(t, uintptr(3), options.InheritedListenerFD, "InheritedListenerFD should be updated")
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.NoError(t, DefaultOptions().Validate(), "The default options should be valid")
+
+	for name, tc := range map[string]struct {
+		options []Option
+		want    string
+	}{
+		"negative port":        {[]Option{WithPort(-1)}, "port -1 is outside 0-65535"},
+		"port too large":       {[]Option{WithPort(65536)}, "port 65536 is outside 0-65535"},
+		"empty host":           {[]Option{WithHost("")}, "host is empty"},
+		"TLS without files":    {[]Option{WithTLSEnabled(true)}, "TLS is enabled without a certificate and key"},
+		"certificate only":     {[]Option{WithTLSFiles("cert.pem", "")}, `TLS certificate "cert.pem" has no key`},
+		"key only":             {[]Option{WithTLSFiles("", "key.pem")}, `TLS key "key.pem" has no certificate`},
+		"no clients":           {[]Option{WithMaxConcurrentClients(0)}, "max concurrent clients must be at least 1, got 0"},
+		"negative requests":    {[]Option{WithMaxConcurrentRequests(-2)}, "max concurrent requests must not be negative, got -2"},
+		"negative queue":       {[]Option{WithRequestQueueSize(-1)}, "request queue size must not be negative"},
+		"negative jobs":        {[]Option{WithMaxConcurrentJobs(-1)}, "max concurrent jobs must not be negative"},
+		"negative body limit":  {[]Option{WithMaxRequestBytes(-1)}, "max request bytes must not be negative"},
+		"negative annotations": {[]Option{WithAnnotationLimits(-1, 0)}, "max annotations must not be negative, got -1"},
+		"negative annot bytes": {[]Option{WithAnnotationLimits(0, -1)}, "max annotation bytes must not be negative, got -1"},
+		"negative timeout":     {[]Option{WithConnectionTimeout(-time.Second)}, "connection timeout must not be negative, got -1s"},
+		"negative idle":        {[]Option{WithIdleTimeout(-time.Second)}, "idle timeout must not be negative"},
+		"negative write":       {[]Option{WithWriteTimeout(-time.Second)}, "write timeout must not be negative"},
+		"negative session TTL": {[]Option{WithSessionTTL(-time.Second)}, "session TTL must not be negative"},
+		"negative handler":     {[]Option{WithHandlerTimeout(-time.Second)}, "handler timeout must not be negative, got -1s"},
+		"negative method":      {[]Option{WithMethodTimeouts(map[string]time.Duration{"custom.method": -time.Second})}, "handler timeout of custom.method must not be negative"},
+		"negative ratio limit": {[]Option{WithAdaptiveCompression(-1, 10, time.Minute)}, "compression ratio limit must not be negative, got -1"},
+		"empty window":         {[]Option{WithAdaptiveCompression(0.95, 0, time.Minute)}, "compression window must be at least 1, got 0"},
+		"negative reprobe":     {[]Option{WithAdaptiveCompression(0.95, 10, -time.Second)}, "compression reprobe must not be negative, got -1s"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			options := DefaultOptions()
+			for _, opt := range tc.options {
+				opt(&options)
+			}
+			err := options.Validate()
+			require.Error(t, err, "The options should not validate")
+			assert.Contains(t, err.Error(), tc.want, "The error should say what is wrong")
+		})
+	}
+}
+
+func TestOptionsValidateListenAddrs(t *testing.T) {
+	options := DefaultOptions()
+	WithHost("")(&options)
+	WithListenAddrs("127.0.0.1:0")(&options)
+
+	assert.NoError(t, options.Validate(), "An empty host should be valid with listen addresses")
+}
+
+func TestOptionsValidateReportsEveryProblem(t *testing.T) {
+	options := DefaultOptions()
+	WithPort(-1)(&options)
+	WithMaxConcurrentClients(0)(&options)
+
+	err := options.Validate()
+	require.Error(t, err, "The options should not validate")
+	assert.Contains(t, err.Error(), "port -1", "The error should name the port")
+	assert.Contains(t, err.Error(), "max concurrent clients", "The error should name the client limit")
+}
+
+func TestStartValidatesOptions(t *testing.T) {
+	srv := New(WithPort(70000), WithLogger(core.NopLogger()))
+
+	err := srv.Start()
+	require.Error(t, err, "Start should fail with invalid options")
+	assert.Contains(t, err.Error(), "invalid server options: port 70000 is outside 0-65535", "The error should say what is wrong")
+	assert.Equal(t, core.StatusStopped, srv.Status(), "The server should stay stopped")
+	assert.Nil(t, srv.Addr(), "The server should not listen")
+}
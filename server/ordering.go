@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// pendingReplyKey is the context key under which a request's pendingReply is stored.
+type pendingReplyKey struct{}
+
+// pendingReply holds the notifications to send on a connection once the
+// reply to the request being handled has been written.
+type pendingReply struct {
+	handler *rpcHandler
+	queued  []func()
+	done    bool
+}
+
+// beginReply marks a request as awaiting its reply on the connection.
+func (h *rpcHandler) beginReply(ctx context.Context) (context.Context, *pendingReply) {
+	h.replyMu.Lock()
+	defer h.replyMu.Unlock()
+	p := &pendingReply{handler: h}
+	h.pending = p
+	return context.WithValue(ctx, pendingReplyKey{}, p), p
+}
+
+// endReply sends the notifications queued behind the request's reply, which
+// has been written.
+func (h *rpcHandler) endReply(p *pendingReply) {
+	h.replyMu.Lock()
+	defer h.replyMu.Unlock()
+	p.done = true
+	if h.pending == p {
+		h.pending = nil
+	}
+	for _, send := range p.queued {
+		send()
+	}
+	p.queued = nil
+}
+
+// afterReply calls send once the reply to p has been written, or now if it
+// has been. A nil p means whichever request the connection is handling.
+func (h *rpcHandler) afterReply(p *pendingReply, send func()) {
+	h.replyMu.Lock()
+	defer h.replyMu.Unlock()
+	if p == nil {
+		p = h.pending
+	}
+	if p == nil || p.done {
+		send()
+		return
+	}
+	p.queued = append(p.queued, send)
+}
+
+// PublishAfterReply sends a notification to every connected client, like
+// Publish, except that the client that made the request ctx belongs to
+// receives it only once the reply to that request has been written. Clients
+// therefore see the response before a notification it caused. Other clients
+// are notified straight away. Errors sending to the requesting client after
+// the reply are logged rather than returned.
+func PublishAfterReply[T any](ctx context.Context, n core.Notification[T], payload T) error {
+	if err := n.Check(); err != nil {
+		return err
+	}
+	p, ok := ctx.Value(pendingReplyKey{}).(*pendingReply)
+	if !ok {
+		return errors.New("no client request in context")
+	}
+	s := p.handler.server
+
+	var errs []error
+	for h, conn := range s.sessionConns() {
+		if h != p.handler {
+			if err := conn.Notify(s.runContext(), n.Method(), payload); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		h.afterReply(p, s.deferredNotify(h, conn, n.Method(), payload))
+	}
+	return errors.Join(errs...)
+}
+
+// ordered reports whether notifications for method are held back until any
+// reply the client is waiting for has been written.
+func (s *Server) ordered(method string) bool {
+	for _, m := range s.options.OrderedNotifications {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionConns returns the connection of every session that has one.
+func (s *Server) sessionConns() map[*rpcHandler]rpcConn {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	conns := make(map[*rpcHandler]rpcConn, len(s.sessions))
+	for h := range s.sessions {
+		if h.conn != nil {
+			conns[h] = h.conn
+		}
+	}
+	return conns
+}
+
+// deferredNotify returns a function sending a notification on conn that logs,
+// rather than returns, a failure, for sends that happen after the publisher
+// has returned.
+func (s *Server) deferredNotify(h *rpcHandler, conn rpcConn, method string, payload interface{}) func() {
+	return func() {
+		if err := conn.Notify(s.runContext(), method, payload); err != nil {
+			s.options.Logger.Debug("Failed to send notification after reply",
+				core.LogFieldRemoteAddr, h.remoteAddr,
+				core.LogFieldMethod, method,
+				core.LogFieldError, err)
+		}
+	}
+}
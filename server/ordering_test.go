@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type modelUpdate struct {
+	RequestID string `json:"requestId"`
+}
+
+var modelUpdatedNotification = core.RegisterNotification[modelUpdate]("test.modelUpdated")
+
+// PublishingHandler publishes a model update while handling each request,
+// then waits for release if it is set
+type PublishingHandler struct {
+	publish func(ctx context.Context, update modelUpdate) error
+	release chan struct{}
+}
+
+func (h *PublishingHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *PublishingHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if err := h.publish(ctx, modelUpdate{RequestID: req.ID}); err != nil {
+		return nil, err
+	}
+	if h.release != nil {
+		<-h.release
+	}
+	return core.NewModelResponse(req), nil
+}
+
+// startPublishingServer starts a server with handler and returns its transport
+func startPublishingServer(t *testing.T, handler *PublishingHandler, options ...Option) (*Server, core.Transport) {
+	transport := core.NewInProcessTransport()
+	srv := New(append([]Option{WithTransport(transport)}, options...)...)
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+	return srv, transport
+}
+
+// captureFrames sends one mcp.processModel request over a raw connection and
+// returns the methods of the first two frames received, "reply" for the response
+func captureFrames(t *testing.T, srv *Server, transport core.Transport) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, err := transport.Dial(ctx, "")
+	require.NoError(t, err, "Raw connection should succeed")
+	defer conn.Close()
+	require.Eventually(t, func() bool { return srv.Stats().Sessions == 1 }, 2*time.Second, 10*time.Millisecond, "Server should register the session")
+
+	codec := jsonrpc2.VSCodeObjectCodec{}
+	params := testutil.CreateTestModelRequest()
+	require.NoError(t, codec.WriteObject(conn, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  core.MethodProcessModel,
+		"params":  params,
+	}), "Request frame should be written")
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	reader := bufio.NewReader(conn)
+	var order []string
+	for len(order) < 2 {
+		var frame struct {
+			ID     *int   `json:"id"`
+			Method string `json:"method"`
+		}
+		require.NoError(t, codec.ReadObject(reader, &frame), "Frame should be read")
+		if frame.ID != nil {
+			order = append(order, "reply")
+		} else {
+			order = append(order, frame.Method)
+		}
+	}
+	return order
+}
+
+func TestPublishAfterReplyOrdersSameConnection(t *testing.T) {
+	srv, transport := startPublishingServer(t, &PublishingHandler{
+		publish: func(ctx context.Context, update modelUpdate) error {
+			return PublishAfterReply(ctx, modelUpdatedNotification, update)
+		},
+	})
+
+	order := captureFrames(t, srv, transport)
+	assert.Equal(t, []string{"reply", modelUpdatedNotification.Method()}, order, "Notification should follow the reply on the wire")
+}
+
+func TestPublishRacesReply(t *testing.T) {
+	handler := &PublishingHandler{}
+	srv, transport := startPublishingServer(t, handler)
+	handler.publish = func(ctx context.Context, update modelUpdate) error {
+		return Publish(srv, modelUpdatedNotification, update)
+	}
+
+	order := captureFrames(t, srv, transport)
+	assert.Equal(t, []string{modelUpdatedNotification.Method(), "reply"}, order, "Unordered notification should be sent as soon as it is published")
+}
+
+func TestOrderedNotifications(t *testing.T) {
+	handler := &PublishingHandler{}
+	srv, transport := startPublishingServer(t, handler, WithOrderedNotifications(modelUpdatedNotification.Method()))
+	handler.publish = func(ctx context.Context, update modelUpdate) error {
+		return Publish(srv, modelUpdatedNotification, update)
+	}
+
+	order := captureFrames(t, srv, transport)
+	assert.Equal(t, []string{"reply", modelUpdatedNotification.Method()}, order, "Ordered notification should follow the pending reply")
+}
+
+func TestPublishAfterReplyNotifiesOthersPromptly(t *testing.T) {
+	release := make(chan struct{})
+	srv, transport := startPublishingServer(t, &PublishingHandler{
+		publish: func(ctx context.Context, update modelUpdate) error {
+			return PublishAfterReply(ctx, modelUpdatedNotification, update)
+		},
+		release: release,
+	})
+
+	caller := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, caller.Start(), "Calling client should connect")
+	defer caller.Stop()
+	observer := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, observer.Start(), "Observing client should connect")
+	defer observer.Stop()
+	require.Eventually(t, func() bool { return srv.Stats().Sessions == 2 }, 2*time.Second, 10*time.Millisecond, "Server should register both sessions")
+
+	callerUpdates := make(chan modelUpdate, 1)
+	client.OnNotificationTyped(caller, modelUpdatedNotification, func(ctx context.Context, update modelUpdate) { callerUpdates <- update })
+	observerUpdates := make(chan modelUpdate, 1)
+	client.OnNotificationTyped(observer, modelUpdatedNotification, func(ctx context.Context, update modelUpdate) { observerUpdates <- update })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req := testutil.CreateTestModelRequest()
+	result := make(chan error, 1)
+	go func() {
+		_, err := caller.ProcessModel(ctx, req)
+		result <- err
+	}()
+
+	// The handler is still running, so only the other client has been told
+	update := receive(t, observerUpdates, "update for the other client")
+	assert.Equal(t, req.ID, update.RequestID, "Other client should get the update while the request is in progress")
+	assert.Empty(t, callerUpdates, "Calling client should not get the update before its reply")
+
+	close(release)
+	require.NoError(t, <-result, "Request should complete")
+	update = receive(t, callerUpdates, "update for the calling client")
+	assert.Equal(t, req.ID, update.RequestID, "Calling client should get the update after its reply")
+}
+
+func TestPublishAfterReplyNeedsRequest(t *testing.T) {
+	err := PublishAfterReply(context.Background(), modelUpdatedNotification, modelUpdate{})
+	assert.Error(t, err, "Publishing after a reply should need a request in the context")
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// outboundChunkSize is the largest piece of a frame written to a connection
+// in one call.
+const outboundChunkSize = 64 << 10
+
+// outboundFlushTimeout bounds how long closing a connection waits for the
+// replies and notifications still queued for it to be written.
+const outboundFlushTimeout = 5 * time.Second
+
+// outboundStream queues the writes of a jsonrpc2 object stream for a writer
+// goroutine of the connection's own. Replies and notifications are sent in
+// the order they were queued, but a client slow to take a large frame holds
+// up only its own connection rather than the handler slot, the notification
+// being published or the caller that sent them. A sender waits only once
+// the connection's queue is full.
+type outboundStream struct {
+	jsonrpc2.ObjectStream // Reads, and the writes made by run
+
+	wire    *chunkedConn
+	queue   chan interface{}
+	depth   int64         // Objects queued or being written; accessed atomically
+	closing chan struct{} // Closed by Close; the writer drains the queue and exits
+	done    chan struct{} // Closed when the writer exits
+	once    sync.Once
+	err     error // Why the writer exited early; set before done is closed
+}
+
+// newOutboundStream returns a stream queueing up to size writes. The stream
+// built by wrap must write to the connection returned by conn.
+func newOutboundStream(rwc io.ReadWriteCloser, size int) *outboundStream {
+	return &outboundStream{
+		wire:    &chunkedConn{ReadWriteCloser: rwc, abort: make(chan struct{})},
+		queue:   make(chan interface{}, size),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// conn returns the connection the wrapped stream must write to, which
+// writes large frames in chunks.
+func (s *outboundStream) conn() io.ReadWriteCloser {
+	return s.wire
+}
+
+// wrap sets the stream whose writes are queued and returns s.
+func (s *outboundStream) wrap(stream jsonrpc2.ObjectStream) *outboundStream {
+	s.ObjectStream = stream
+	return s
+}
+
+// WriteObject implements jsonrpc2.ObjectStream, queueing obj for the writer.
+func (s *outboundStream) WriteObject(obj interface{}) error {
+	select {
+	case <-s.closing:
+		return io.ErrClosedPipe
+	case <-s.done:
+		return s.exitErr()
+	default:
+	}
+
+	atomic.AddInt64(&s.depth, 1)
+	select {
+	case s.queue <- obj:
+		return nil
+	case <-s.closing:
+		atomic.AddInt64(&s.depth, -1)
+		return io.ErrClosedPipe
+	case <-s.done:
+		atomic.AddInt64(&s.depth, -1)
+		return s.exitErr()
+	}
+}
+
+// exitErr returns why the writer exited; it must have.
+func (s *outboundStream) exitErr() error {
+	if s.err != nil {
+		return s.err
+	}
+	return io.ErrClosedPipe
+}
+
+// run writes queued objects until the stream is closed and its queue
+// drained, or a write fails.
+func (s *outboundStream) run() {
+	defer close(s.done)
+	for {
+		select {
+		case obj := <-s.queue:
+			if !s.write(obj) {
+				return
+			}
+		case <-s.closing:
+			for {
+				select {
+				case obj := <-s.queue:
+					if !s.write(obj) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes obj, closing the connection if it fails so the read loop
+// ends too.
+func (s *outboundStream) write(obj interface{}) bool {
+	err := s.ObjectStream.WriteObject(obj)
+	atomic.AddInt64(&s.depth, -1)
+	if err != nil {
+		s.err = err
+		s.ObjectStream.Close()
+		return false
+	}
+	return true
+}
+
+// len returns the number of objects queued or being written.
+func (s *outboundStream) len() int {
+	return int(atomic.LoadInt64(&s.depth))
+}
+
+// Close implements jsonrpc2.ObjectStream and io.Closer. It waits up to
+// outboundFlushTimeout for the queue to be written, then abandons what is
+// left, even partway through a frame, and closes the connection.
+func (s *outboundStream) Close() error {
+	s.once.Do(func() { close(s.closing) })
+
+	timer := time.NewTimer(outboundFlushTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.done:
+	case <-timer.C:
+		s.wire.stop()
+	}
+	return s.ObjectStream.Close()
+}
+
+// chunkedConn writes to a connection at most outboundChunkSize bytes at a
+// time, giving up between chunks once stopped. Closing some connections,
+// such as standard output, does not interrupt a write in progress.
+type chunkedConn struct {
+	io.ReadWriteCloser
+	abort chan struct{}
+	once  sync.Once
+}
+
+// Write implements io.Writer.
+func (c *chunkedConn) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		select {
+		case <-c.abort:
+			return n, io.ErrClosedPipe
+		default:
+		}
+		m, err := c.ReadWriteCloser.Write(p[:min(len(p), outboundChunkSize)])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// stop makes later writes fail between chunks.
+func (c *chunkedConn) stop() {
+	c.once.Do(func() { close(c.abort) })
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// HugeModelHandler answers requests whose model data asks for it with a
+// response of several megabytes
+type HugeModelHandler struct {
+	started chan string
+}
+
+func (h *HugeModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *HugeModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	if req.ModelData["huge"] == true {
+		resp.Results["blob"] = strings.Repeat("x", 8<<20)
+		h.started <- req.ID
+	}
+	return resp, nil
+}
+
+// sendUnread sends a request for a huge response over a new connection that
+// never reads, so the reply can never be written in full.
+func sendUnread(t *testing.T, transport core.Transport) {
+	netConn, err := transport.Dial(context.Background(), "")
+	require.NoError(t, err, "Dial should succeed")
+	t.Cleanup(func() { netConn.Close() })
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["huge"] = true
+	call := &jsonrpc2.Request{Method: core.MethodProcessModel, ID: jsonrpc2.ID{Num: 1}}
+	require.NoError(t, call.SetParams(req), "Params should encode")
+	require.NoError(t, jsonrpc2.VSCodeObjectCodec{}.WriteObject(netConn, call), "Request should be sent")
+}
+
+// maxOutbound returns the longest outbound queue of srv's connections
+func maxOutbound(srv *Server) int {
+	longest := 0
+	for _, info := range srv.Connections() {
+		longest = max(longest, info.Outbound)
+	}
+	return longest
+}
+
+func TestOutboundQueueFairness(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		queueSize int
+		starved   bool
+	}{
+		{"queued", DefaultOptions().OutboundQueueSize, false},
+		{"unqueued", 0, true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &HugeModelHandler{started: make(chan string, 1)}
+			transport := core.NewInProcessTransport()
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()),
+				WithMaxConcurrentRequests(1), WithOutboundQueueSize(tc.queueSize))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			require.NoError(t, srv.Start(), "Server should start")
+			defer srv.Stop()
+
+			c := client.New(client.WithTransport(transport), client.WithLogger(core.NopLogger()), client.WithAutoReconnect(false))
+			require.NoError(t, c.Start(), "Client should connect")
+			defer c.Stop()
+
+			// The only handler slot goes to a client that never reads its reply
+			sendUnread(t, transport)
+			receive(t, handler.started, "huge request")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if tc.starved {
+				// Writing the reply holds the slot, so other clients are refused
+				_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+				requireCode(t, err, core.CodeServerBusy, "Small request should be refused while the slot is held")
+				return
+			}
+
+			// The slot is released once the reply is queued
+			require.Eventually(t, func() bool {
+				return maxOutbound(srv) == 1
+			}, 2*time.Second, 10*time.Millisecond, "Unread reply should be queued")
+
+			for i := 0; i < 10; i++ {
+				_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+				require.NoError(t, err, "Small requests should be served beside the unread reply")
+			}
+			assert.Equal(t, 1, maxOutbound(srv), "Unread reply should still be waiting to be written")
+		})
+	}
+}
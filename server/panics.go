@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// PanicHandler is told of a panic recovered while handling a call to method,
+// with the value recovered and the stack of the panicking goroutine.
+type PanicHandler func(method string, recovered interface{}, stack []byte)
+
+// errPanicked is the error a request fails with when its handler panics. It
+// says nothing of the panic, whose value may reveal internals.
+var errPanicked = errors.New("internal error")
+
+// handlePanic reports a panic recovered while handling method to the
+// PanicHandler, or logs it with fields and the stack, and returns the error
+// to fail the request with. It must be called from the deferred function
+// that recovered, so the stack is the panic's.
+func (s *Server) handlePanic(method string, value interface{}, fields ...interface{}) error {
+	stack := debug.Stack()
+	if s.options.PanicHandler != nil {
+		s.options.PanicHandler(method, value, stack)
+		return errPanicked
+	}
+	fields = append([]interface{}{core.LogFieldMethod, method, core.LogFieldError, value}, fields...)
+	s.options.Logger.Error("Handler panicked", append(fields, "stack", string(stack))...)
+	return errPanicked
+}
+
+// recoverPanics returns process failing with the error handlePanic returns
+// when it panics, middleware included, instead of crashing the server.
+// Batch items and jobs run on goroutines of their own, out of reach of the
+// recover in dispatch, and need it. Fields are logged with the panic.
+func (s *Server) recoverPanics(method string, process core.ProcessFunc, fields ...interface{}) core.ProcessFunc {
+	return func(ctx context.Context, req *core.ModelRequest) (resp *core.ModelResponse, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, s.handlePanic(method, r, append([]interface{}{core.LogFieldRequestID, req.ID}, fields...)...)
+			}
+		}()
+		return process(ctx, req)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// panickyResources panics whenever its resources are listed
+type panickyResources struct{}
+
+func (panickyResources) ListResources(context.Context) ([]core.Resource, error) {
+	panic("resource index corrupted")
+}
+
+func (panickyResources) ReadResource(context.Context, string) (*core.ResourceContent, error) {
+	return nil, core.ErrResourceNotFound
+}
+
+// recordedPanic is a panic reported to a PanicHandler
+type recordedPanic struct {
+	method string
+	value  interface{}
+	stack  []byte
+}
+
+func TestHandlerPanicRecovered(t *testing.T) {
+	var mu sync.Mutex
+	var panics []recordedPanic
+	handler := &PanickyModelHandler{}
+	handler.panicking.Store(true)
+
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()),
+				WithPanicHandler(func(method string, recovered interface{}, stack []byte) {
+					mu.Lock()
+					defer mu.Unlock()
+					panics = append(panics, recordedPanic{method: method, value: recovered, stack: stack})
+				}))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			require.NoError(t, srv.RegisterResourceHandler(panickyResources{}), "Resource handler registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The panic fails the request with a generic error
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Panicking handler should fail the request")
+	assert.Equal(t, int64(jsonrpc2.CodeInternalError), rpcErr.Code, "Panic should be reported as an internal error")
+	assert.NotContains(t, rpcErr.Message, "exploded", "Panic value should not be revealed")
+
+	// Panics outside model handlers are recovered too
+	_, err = c.ListResources(ctx)
+	require.ErrorAs(t, err, &rpcErr, "Panicking resource handler should fail the request")
+	assert.Equal(t, int64(jsonrpc2.CodeInternalError), rpcErr.Code, "Panic should be reported as an internal error")
+	assert.NotContains(t, rpcErr.Message, "corrupted", "Panic value should not be revealed")
+
+	// The connection survives
+	handler.panicking.Store(false)
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Next request on the connection should succeed")
+	assert.True(t, c.IsConnected(), "Client should still be connected")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, panics, 2, "Both panics should be reported")
+	assert.Equal(t, core.MethodProcessModel, panics[0].method, "Panic should name the method")
+	assert.Equal(t, "experimental model exploded", panics[0].value, "Panic value should be reported")
+	assert.Contains(t, string(panics[0].stack), "ProcessModel", "Stack should lead to the handler")
+	assert.Equal(t, core.MethodListResources, panics[1].method, "Panic should name the method")
+	assert.Equal(t, "resource index corrupted", panics[1].value, "Panic value should be reported")
+}
+
+func TestBatchItemPanicRecovered(t *testing.T) {
+	handler := &PanickyModelHandler{}
+	handler.panicking.Store(true)
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()), WithBatchParallelism(2))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	responses, err := c.ProcessModelBatch(ctx, []*core.ModelRequest{
+		testutil.CreateTestModelRequest(),
+		testutil.CreateTestModelRequest(),
+	})
+	require.NoError(t, err, "Batch should be answered")
+	for _, resp := range responses {
+		assert.False(t, resp.Success, "Panicking item should fail")
+		assert.NotContains(t, resp.ErrorMessage, "exploded", "Panic value should not be revealed")
+	}
+
+	handler.panicking.Store(false)
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Next request on the connection should succeed")
+}
+
+// panickingMiddleware panics on every request it wraps
+func panickingMiddleware(method string, next core.ProcessFunc) core.ProcessFunc {
+	return func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		panic("middleware exploded")
+	}
+}
+
+func TestBatchMiddlewarePanicRecovered(t *testing.T) {
+	srv, c := startServerWithHandler(t, NewDefaultModelHandler(),
+		WithLogger(core.NopLogger()), WithMiddleware(panickingMiddleware))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	responses, err := c.ProcessModelBatch(ctx, []*core.ModelRequest{testutil.CreateTestModelRequest()})
+	require.NoError(t, err, "Batch should be answered")
+	require.Len(t, responses, 1, "Batch should answer its item")
+	assert.False(t, responses[0].Success, "Item whose middleware panicked should fail")
+	assert.NotContains(t, responses[0].ErrorMessage, "exploded", "Panic value should not be revealed")
+	assert.True(t, c.IsConnected(), "Client should still be connected")
+	assert.Equal(t, core.StatusRunning, srv.Status(), "Server should keep running")
+}
+
+func TestJobMiddlewarePanicRecovered(t *testing.T) {
+	srv, c := startJobServer(t, NewDefaultModelHandler(),
+		WithLogger(core.NopLogger()), WithMiddleware(panickingMiddleware))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	status, err := c.WaitForJob(ctx, id)
+	require.NoError(t, err, "Waiting for the job should succeed")
+	assert.Equal(t, core.JobDone, status.State, "Job should be done")
+	require.NotNil(t, status.Response, "Done job should carry its response")
+	assert.False(t, status.Response.Success, "Job whose middleware panicked should fail")
+	assert.NotContains(t, status.Response.ErrorMessage, "exploded", "Panic value should not be revealed")
+	assert.Equal(t, core.StatusRunning, srv.Status(), "Server should keep running")
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// requestPool bounds how many requests the server's handlers run at once,
+// across all connections. Requests arriving while every slot is taken wait
+// for one, up to queueSize at a time; further requests are refused. A nil
+// pool admits everything.
+type requestPool struct {
+	slots     chan struct{}
+	queueSize int
+
+	mu     sync.Mutex
+	queued int
+}
+
+// newRequestPool returns a pool of max slots, or nil if max is not positive.
+func newRequestPool(max, queueSize int) *requestPool {
+	if max <= 0 {
+		return nil
+	}
+	return &requestPool{slots: make(chan struct{}, max), queueSize: queueSize}
+}
+
+// acquire takes a slot, waiting in the queue if the pool is saturated and the
+// queue has room. It reports false if the request was refused or ctx ended
+// while it waited. Every successful acquire must be paired with a release.
+func (p *requestPool) acquire(ctx context.Context) bool {
+	if p == nil {
+		return true
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	p.mu.Lock()
+	if p.queued >= p.queueSize {
+		p.mu.Unlock()
+		return false
+	}
+	p.queued++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.queued--
+		p.mu.Unlock()
+	}()
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees a slot taken by acquire.
+func (p *requestPool) release() {
+	if p != nil {
+		<-p.slots
+	}
+}
+
+// counts returns the number of requests running and waiting for a slot.
+func (p *requestPool) counts() (inFlight, queued int) {
+	if p == nil {
+		return 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.slots), p.queued
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// GatedModelHandler holds every request until release is closed
+type GatedModelHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedModelHandler() *GatedModelHandler {
+	return &GatedModelHandler{started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (h *GatedModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *GatedModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	h.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-h.release:
+		return core.NewModelResponse(req), nil
+	}
+}
+
+// startPooledServer starts a server with a gated handler and the given
+// options and returns it with n connected clients
+func startPooledServer(t *testing.T, handler Handler, n int, options ...Option) (*Server, []*client.Client) {
+	transport := core.NewInProcessTransport()
+	srv := New(append([]Option{WithTransport(transport)}, options...)...)
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+
+	clients := make([]*client.Client, n)
+	for i := range clients {
+		clients[i] = client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		require.NoError(t, clients[i].Start(), "Client should connect")
+		t.Cleanup(func() { clients[i].Stop() })
+	}
+	return srv, clients
+}
+
+// processAsync sends a request from c and returns a channel receiving its error
+func processAsync(ctx context.Context, c *client.Client) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		result <- err
+	}()
+	return result
+}
+
+func TestRequestPoolSaturation(t *testing.T) {
+	handler := newGatedModelHandler()
+	srv, clients := startPooledServer(t, handler, 2, WithMaxConcurrentRequests(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first := processAsync(ctx, clients[0])
+	<-handler.started
+	assert.Equal(t, 1, srv.Stats().InFlight, "Running request should hold the only slot")
+
+	_, err := clients[1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Saturated server should refuse the request")
+	assert.Equal(t, core.CodeServerBusy, rpcErr.Code, "Refusal should report the server is busy")
+
+	_, err = clients[1].FetchMethodSchemas(ctx)
+	assert.NoError(t, err, "Requests the server answers itself should not need a slot")
+
+	close(handler.release)
+	assert.NoError(t, <-first, "Running request should complete")
+	require.Eventually(t, func() bool { return srv.Stats().InFlight == 0 }, time.Second, 5*time.Millisecond,
+		"Slot should be released after replying")
+	_, err = clients[1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Requests should be accepted once a slot frees")
+}
+
+func TestRequestPoolQueue(t *testing.T) {
+	handler := newGatedModelHandler()
+	srv, clients := startPooledServer(t, handler, 3, WithMaxConcurrentRequests(1), WithRequestQueueSize(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first := processAsync(ctx, clients[0])
+	<-handler.started
+	second := processAsync(ctx, clients[1])
+	require.Eventually(t, func() bool { return srv.Stats().Queued == 1 }, time.Second, 5*time.Millisecond,
+		"Second request should wait in the queue")
+
+	_, err := clients[2].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Request beyond the queue should be refused")
+	assert.Equal(t, core.CodeServerBusy, rpcErr.Code, "Refusal should report the server is busy")
+
+	close(handler.release)
+	assert.NoError(t, <-first, "Running request should complete")
+	assert.NoError(t, <-second, "Queued request should run once a slot frees")
+	require.Eventually(t, func() bool { return srv.Stats().InFlight == 0 }, time.Second, 5*time.Millisecond,
+		"Slots should be released after replying")
+	assert.Equal(t, 0, srv.Stats().Queued, "No request should be queued")
+}
+
+func TestRequestPoolUnbounded(t *testing.T) {
+	pool := newRequestPool(0, 10)
+	assert.Nil(t, pool, "No limit should need no pool")
+	assert.True(t, pool.acquire(context.Background()), "Nil pool should admit every request")
+	pool.release()
+	inFlight, queued := pool.counts()
+	assert.Zero(t, inFlight, "Nil pool should report nothing in flight")
+	assert.Zero(t, queued, "Nil pool should report nothing queued")
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+)
+
+// httpMethodPrefixes lists the first bytes of every HTTP/1.x request line.
+// JSON-RPC frames start with a "Content-Length" header and never match.
+var httpMethodPrefixes = [][]byte{
+	[]byte("GET "),
+	[]byte("HEAD"),
+	[]byte("POST"),
+	[]byte("PUT "),
+	[]byte("DELE"),
+	[]byte("OPTI"),
+	[]byte("PATC"),
+}
+
+// sniffHTTP peeks at the first bytes sent on conn and reports whether they
+// start an HTTP request. The returned connection replays the peeked bytes, so
+// it must be used in place of conn. Peeking happens after any TLS handshake,
+// since the TLS listener hands out already-wrapped connections.
+func sniffHTTP(conn net.Conn) (net.Conn, bool) {
+	r := bufio.NewReader(conn)
+	peeked := &peekedConn{Conn: conn, r: r}
+
+	prefix, err := r.Peek(4)
+	if err != nil {
+		// Let the JSON-RPC path observe the same error and close the connection
+		return peeked, false
+	}
+
+	for _, method := range httpMethodPrefixes {
+		if bytes.Equal(prefix, method) {
+			return peeked, true
+		}
+	}
+	return peeked, false
+}
+
+// peekedConn is a net.Conn whose reads drain the sniffing buffer first.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read implements io.Reader.
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// connQueue is a net.Listener fed with connections handed over by the main
+// accept loop. It lets an http.Server serve connections from a shared port.
+type connQueue struct {
+	addr   net.Addr
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newConnQueue(addr net.Addr) *connQueue {
+	return &connQueue{
+		addr:   addr,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// push hands a connection to the queue's consumer, closing it instead if the
+// queue has already been shut down.
+func (q *connQueue) push(conn net.Conn) {
+	select {
+	case q.conns <- conn:
+	case <-q.closed:
+		conn.Close()
+	}
+}
+
+// Accept implements net.Listener.
+func (q *connQueue) Accept() (net.Conn, error) {
+	select {
+	case conn := <-q.conns:
+		return conn, nil
+	case <-q.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener.
+func (q *connQueue) Close() error {
+	q.once.Do(func() { close(q.closed) })
+	return nil
+}
+
+// Addr implements net.Listener.
+func (q *connQueue) Addr() net.Addr {
+	return q.addr
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAdminMux returns an admin mux answering the usual probe endpoints
+func newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "mcp_requests_total 0\n")
+	})
+	return mux
+}
+
+// startSharedServer starts a port-sharing server with the default model handler
+func startSharedServer(t *testing.T, options ...Option) int {
+	srv := New(append([]Option{WithPort(0), WithPortSharing(newAdminMux())}, options...)...)
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+	return srv.Port()
+}
+
+func TestPortSharing(t *testing.T) {
+	port := startSharedServer(t)
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	c := client.New(client.WithServerHost("127.0.0.1"), client.WithServerPort(port), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Client should connect to the shared port")
+	defer c.Stop()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+
+	// MCP requests and HTTP probes hit the same port concurrently
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if _, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest()); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(base + "/healthz")
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != "ok" {
+				errs <- fmt.Errorf("unexpected health body %q", body)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err, "Shared-port traffic should succeed")
+	}
+
+	resp, err := http.Get(base + "/metrics")
+	require.NoError(t, err, "Metrics request should succeed")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Metrics endpoint should be served")
+}
+
+func TestPortSharingTLS(t *testing.T) {
+	certPath, keyPath, pool := testutil.GenerateTestCertificate(t)
+	port := startSharedServer(t, WithTLSFiles(certPath, keyPath))
+
+	// HTTPS admin requests work because TLS terminates before the peek
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := httpClient.Get(fmt.Sprintf("https://127.0.0.1:%d/healthz", port))
+	require.NoError(t, err, "HTTPS health request should succeed")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok", string(body), "Health endpoint should answer over TLS")
+
+	// MCP over TLS on the same port
+	c := client.New(
+		client.WithServerHost("127.0.0.1"),
+		client.WithServerPort(port),
+		client.WithAutoReconnect(false),
+		client.WithTLSConfig(&tls.Config{RootCAs: pool}),
+	)
+	require.NoError(t, c.Start(), "TLS client should connect")
+	defer c.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "ProcessModel should succeed over TLS")
+}
+
+func TestPortSharingMalformedPrefix(t *testing.T) {
+	port := startSharedServer(t)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	require.NoError(t, err, "Raw connection should succeed")
+	defer conn.Close()
+
+	// Bytes that are neither HTTP nor a JSON-RPC frame get the usual treatment: the connection is closed
+	_, err = conn.Write([]byte("\x00\x01garbage\r\n\r\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "Server should close a connection with a malformed first frame")
+}
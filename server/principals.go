@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// PrincipalUsage describes the requests one principal has in flight.
+type PrincipalUsage struct {
+	InFlight int `json:"inFlight"` // Requests being handled for the principal, borrowed ones included
+	Limit    int `json:"limit"`    // Requests the principal may have in flight before borrowing
+	Borrowed int `json:"borrowed"` // High priority requests running on the shared reserve
+}
+
+// principalLimiter bounds how many requests each principal has in flight
+// across all of its connections. High priority requests of a principal at its
+// limit may borrow a slot from a reserve shared by every principal. A nil
+// limiter admits everything.
+type principalLimiter struct {
+	defaultLimit int
+	overrides    map[string]int
+	reserve      int
+
+	mu       sync.Mutex
+	usage    map[string]*PrincipalUsage
+	borrowed int // Reserve slots in use across principals
+}
+
+// newPrincipalLimiter returns a limiter applying defaultLimit to principals
+// without an override, or nil if neither sets a limit.
+func newPrincipalLimiter(defaultLimit int, overrides map[string]int, reserve int) *principalLimiter {
+	if defaultLimit <= 0 && len(overrides) == 0 {
+		return nil
+	}
+	return &principalLimiter{
+		defaultLimit: defaultLimit,
+		overrides:    overrides,
+		reserve:      reserve,
+		usage:        make(map[string]*PrincipalUsage),
+	}
+}
+
+// limit returns the limit of the principal; zero leaves it unlimited.
+func (l *principalLimiter) limit(id string) int {
+	if limit, ok := l.overrides[id]; ok {
+		return limit
+	}
+	return l.defaultLimit
+}
+
+// acquire counts a request against the principal. A principal at its limit
+// borrows from the reserve if high reports the request is high priority,
+// which is only asked then. It reports whether the request was admitted,
+// whether it borrowed, and the principal's usage at the time. Every admitted
+// request must be released.
+func (l *principalLimiter) acquire(id string, high func() bool) (borrowed bool, usage PrincipalUsage, ok bool) {
+	if l == nil {
+		return false, PrincipalUsage{}, true
+	}
+	limit := l.limit(id)
+	if limit <= 0 {
+		return false, PrincipalUsage{}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u := l.usage[id]
+	if u == nil {
+		u = &PrincipalUsage{Limit: limit}
+	}
+	if u.InFlight-u.Borrowed >= limit {
+		if l.borrowed >= l.reserve || !high() {
+			return false, *u, false
+		}
+		l.borrowed++
+		u.Borrowed++
+		borrowed = true
+	}
+	u.InFlight++
+	l.usage[id] = u
+	return borrowed, *u, true
+}
+
+// release ends a request admitted by acquire.
+func (l *principalLimiter) release(id string, borrowed bool) {
+	if l == nil || l.limit(id) <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u := l.usage[id]
+	if u == nil {
+		return
+	}
+	u.InFlight--
+	if borrowed {
+		u.Borrowed--
+		l.borrowed--
+	}
+	if u.InFlight == 0 {
+		delete(l.usage, id)
+	}
+}
+
+// snapshot returns the usage of every principal with requests in flight.
+func (l *principalLimiter) snapshot() map[string]PrincipalUsage {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.usage) == 0 {
+		return nil
+	}
+	usage := make(map[string]PrincipalUsage, len(l.usage))
+	for id, u := range l.usage {
+		usage[id] = *u
+	}
+	return usage
+}
+
+// highPriority reports whether the params of req carry high priority metadata.
+func highPriority(req *jsonrpc2.Request) bool {
+	if req.Params == nil {
+		return false
+	}
+	var params struct {
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return false
+	}
+	return params.Metadata[core.MetadataPriority] == core.PriorityHigh
+}
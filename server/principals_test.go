@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTenantServer starts a server with a gated handler that authenticates
+// each token as the principal of that name, and returns it with n clients
+// for each of the given principals
+func startTenantServer(t *testing.T, handler Handler, principals []string, n int, options ...Option) (*Server, map[string][]*client.Client) {
+	transport := core.NewInProcessTransport()
+	srv := New(append([]Option{
+		WithTransport(transport),
+		WithLogger(core.NopLogger()),
+		WithMaxConcurrentRequests(4),
+		WithAuthenticator(func(ctx context.Context, credentials core.Credentials) (core.Principal, error) {
+			return core.Principal{ID: credentials.Token()}, nil
+		}),
+	}, options...)...)
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+
+	clients := make(map[string][]*client.Client)
+	for _, principal := range principals {
+		for i := 0; i < n; i++ {
+			c := client.New(client.WithTransport(transport), client.WithAutoReconnect(false), client.WithAuthToken(principal))
+			require.NoError(t, c.Start(), "Client should connect")
+			t.Cleanup(func() { c.Stop() })
+			clients[principal] = append(clients[principal], c)
+		}
+	}
+	return srv, clients
+}
+
+// processHighAsync sends a high priority request from c and returns a
+// channel receiving its error
+func processHighAsync(ctx context.Context, c *client.Client) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		req := testutil.CreateTestModelRequest()
+		req.Metadata = map[string]string{core.MetadataPriority: core.PriorityHigh}
+		_, err := c.ProcessModel(ctx, req)
+		result <- err
+	}()
+	return result
+}
+
+// requirePrincipalBusy asserts err refuses a request of principal at its limit
+func requirePrincipalBusy(t *testing.T, err error, principal string, inFlight, limit int) {
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Request over the principal's limit should be refused")
+	assert.Equal(t, core.CodeServerBusy, rpcErr.Code, "Refusal should use CodeServerBusy")
+	require.NotNil(t, rpcErr.Data, "Refusal should carry the principal's usage")
+	var data core.PrincipalBusyData
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &data), "Usage should decode")
+	assert.Equal(t, core.PrincipalBusyData{Principal: principal, InFlight: inFlight, Limit: limit}, data, "Usage should describe the principal")
+}
+
+func TestPrincipalConcurrencyLimit(t *testing.T) {
+	handler := newGatedModelHandler()
+	srv, clients := startTenantServer(t, handler, []string{"alice", "bob"}, 3,
+		WithPrincipalConcurrencyLimit(1, map[string]int{"bob": 2}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	alice := processAsync(ctx, clients["alice"][0])
+	<-handler.started
+	_, err := clients["alice"][1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requirePrincipalBusy(t, err, "alice", 1, 1)
+
+	// Alice being at her limit leaves bob his own
+	bob := []<-chan error{processAsync(ctx, clients["bob"][0]), processAsync(ctx, clients["bob"][1])}
+	<-handler.started
+	<-handler.started
+	_, err = clients["bob"][2].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requirePrincipalBusy(t, err, "bob", 2, 2)
+
+	stats := srv.Stats()
+	assert.Equal(t, map[string]PrincipalUsage{
+		"alice": {InFlight: 1, Limit: 1},
+		"bob":   {InFlight: 2, Limit: 2},
+	}, stats.Principals, "Stats should report usage by principal")
+	assert.Equal(t, 3, stats.InFlight, "Refused requests should not hold handler slots")
+
+	close(handler.release)
+	assert.NoError(t, <-alice, "Admitted request should succeed")
+	for _, result := range bob {
+		assert.NoError(t, <-result, "Admitted request should succeed")
+	}
+	assert.Eventually(t, func() bool { return len(srv.Stats().Principals) == 0 }, time.Second, 5*time.Millisecond,
+		"Finished requests should release their principal's slots")
+
+	// Once her request is done, alice may send another
+	_, err = clients["alice"][1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Request within the limit should succeed")
+}
+
+func TestPrincipalReserve(t *testing.T) {
+	handler := newGatedModelHandler()
+	srv, clients := startTenantServer(t, handler, []string{"alice", "bob"}, 3,
+		WithPrincipalConcurrencyLimit(1, nil), WithPrincipalReserve(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	results := []<-chan error{processAsync(ctx, clients["alice"][0]), processAsync(ctx, clients["bob"][0])}
+	<-handler.started
+	<-handler.started
+
+	// Normal priority requests do not borrow
+	_, err := clients["alice"][1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requirePrincipalBusy(t, err, "alice", 1, 1)
+
+	// A high priority request borrows the reserve slot
+	results = append(results, processHighAsync(ctx, clients["alice"][1]))
+	<-handler.started
+	assert.Equal(t, PrincipalUsage{InFlight: 2, Limit: 1, Borrowed: 1}, srv.Stats().Principals["alice"], "Borrowed request should be reported")
+
+	// The reserve is shared, so bob cannot borrow while alice holds it
+	err = <-processHighAsync(ctx, clients["bob"][1])
+	requirePrincipalBusy(t, err, "bob", 1, 1)
+
+	close(handler.release)
+	for _, result := range results {
+		assert.NoError(t, <-result, "Admitted request should succeed")
+	}
+	assert.Eventually(t, func() bool { return len(srv.Stats().Principals) == 0 }, time.Second, 5*time.Millisecond,
+		"Finished requests should return the reserve")
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket: requests are allowed at RPS per second on
+// average, with bursts of up to Burst requests. A zero RPS disables the limit.
+type RateLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// tokenBucket tracks the tokens left in one bucket.
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token if one is available at now. Otherwise it returns how
+// long until the next token.
+func (b *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	burst := math.Max(float64(b.limit.Burst), 1)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*b.limit.RPS)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / b.limit.RPS * float64(time.Second)), false
+}
+
+// connLimiter holds the buckets of one connection: one per method with an
+// override and a shared one for every other method. It is dropped with the
+// connection.
+type connLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	methods map[string]RateLimit
+	buckets map[string]*tokenBucket // Keyed by method, "" for the shared bucket
+}
+
+// newConnLimiter returns a limiter for a new connection, or nil if no limit
+// is configured.
+func newConnLimiter(limit RateLimit, methods map[string]RateLimit) *connLimiter {
+	if limit.RPS <= 0 && len(methods) == 0 {
+		return nil
+	}
+	return &connLimiter{
+		limit:   limit,
+		methods: methods,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow reports whether a call to method is allowed at now and, if not, how
+// long until it would be.
+func (l *connLimiter) allow(method string, now time.Time) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+
+	key, limit := "", l.limit
+	if override, ok := l.methods[method]; ok {
+		key, limit = method, override
+	}
+	if limit.RPS <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{limit: limit}
+		l.buckets[key] = bucket
+	}
+	return bucket.take(now)
+}
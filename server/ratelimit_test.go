@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnLimiter(t *testing.T) {
+	limiter := newConnLimiter(RateLimit{RPS: 10, Burst: 2}, map[string]RateLimit{
+		core.MethodListMethods:  {RPS: 1, Burst: 1},
+		core.MethodAuthenticate: {},
+	})
+	now := time.Now()
+
+	// The burst is allowed, then requests wait for tokens
+	for i := 0; i < 2; i++ {
+		_, ok := limiter.allow(core.MethodProcessModel, now)
+		assert.True(t, ok, "Requests within the burst should be allowed")
+	}
+	wait, ok := limiter.allow(core.MethodProcessModelBatch, now)
+	assert.False(t, ok, "Methods should share the default bucket")
+	assert.Equal(t, 100*time.Millisecond, wait, "Wait should be the time to the next token")
+
+	_, ok = limiter.allow(core.MethodProcessModel, now.Add(100*time.Millisecond))
+	assert.True(t, ok, "Request should be allowed once a token accrues")
+
+	// Overrides have their own buckets
+	_, ok = limiter.allow(core.MethodListMethods, now)
+	assert.True(t, ok, "Overridden method should not use the default bucket")
+	wait, ok = limiter.allow(core.MethodListMethods, now)
+	assert.False(t, ok, "Overridden method should have its own limit")
+	assert.Equal(t, time.Second, wait, "Wait should follow the override's rate")
+	for i := 0; i < 5; i++ {
+		_, ok = limiter.allow(core.MethodAuthenticate, now)
+		assert.True(t, ok, "Zero RPS override should exempt the method")
+	}
+
+	assert.Nil(t, newConnLimiter(RateLimit{}, nil), "No limits should need no limiter")
+}
+
+// startRateLimitedServer starts a server limited to 10 requests per second
+// with bursts of 3 and returns its transport
+func startRateLimitedServer(t *testing.T) core.Transport {
+	transport := core.NewInProcessTransport()
+	srv := New(WithTransport(transport), WithRateLimit(10, 3))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+	return transport
+}
+
+// hammer sends n requests back to back and returns the rate limit errors
+func hammer(t *testing.T, c *client.Client, n int) []*jsonrpc2.Error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var limited []*jsonrpc2.Error
+	for i := 0; i < n; i++ {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) && rpcErr.Code == core.CodeRateLimited {
+			limited = append(limited, rpcErr)
+			continue
+		}
+		require.NoError(t, err, "Requests under the limit should succeed")
+	}
+	return limited
+}
+
+func TestRateLimit(t *testing.T) {
+	transport := startRateLimitedServer(t)
+	c := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+
+	limited := hammer(t, c, 20)
+	require.NotEmpty(t, limited, "Requests over the limit should be refused")
+	assert.Less(t, len(limited), 20, "Requests within the burst should succeed")
+
+	require.NotNil(t, limited[0].Data, "Refusal should carry a retry hint")
+	var data core.RateLimitData
+	require.NoError(t, json.Unmarshal(*limited[0].Data, &data), "Retry hint should decode")
+	assert.Greater(t, data.RetryAfterMillis, int64(0), "Retry hint should be positive")
+	assert.LessOrEqual(t, data.RetryAfterMillis, int64(100), "Retry hint should not exceed one token interval")
+
+	// After a pause the bucket has refilled
+	time.Sleep(400 * time.Millisecond)
+	assert.Empty(t, hammer(t, c, 3), "Requests after a pause should succeed")
+}
+
+func TestRateLimitPerConnection(t *testing.T) {
+	transport := startRateLimitedServer(t)
+	first := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, first.Start(), "First client should connect")
+	defer first.Stop()
+	second := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, second.Start(), "Second client should connect")
+	defer second.Stop()
+
+	require.NotEmpty(t, hammer(t, first, 20), "First client should exhaust its bucket")
+	assert.Empty(t, hammer(t, second, 3), "Second client should have its own bucket")
+}
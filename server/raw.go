@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// RawModelHandler handles model requests without decoding their model data,
+// for handlers that pass large payloads on rather than read them. It takes
+// mcp.processModel requests in place of ModelHandler when a handler
+// implements both; batches, streams and jobs still need ModelHandler, as do
+// requests forwarded to a Subprocess group. Raw requests skip what needs the
+// decoded request: middleware, schema and response validation, and the
+// Recorder.
+type RawModelHandler interface {
+	Handler
+	// ProcessModelRaw processes the request with the given ID, whose model
+	// data is passed as the client encoded it, and returns the results to
+	// reply with, encoded as JSON; nil is sent as null. Clients decoding the
+	// response into a core.ModelResponse need the results to be an object.
+	// Returning a *core.ModelError fails the request with CodeModelError; any
+	// other error fails it as an internal error.
+	ProcessModelRaw(ctx context.Context, id string, raw json.RawMessage, params []core.Parameter) (json.RawMessage, error)
+}
+
+// rawHandler returns handler as a RawModelHandler if it is one and handles
+// the request of ctx itself.
+func rawHandler(ctx context.Context, handler interface{}) (RawModelHandler, bool) {
+	raw, ok := handler.(RawModelHandler)
+	if !ok {
+		return nil, false
+	}
+	if group, _ := ctx.Value(groupKey{}).(*HandlerGroup); group != nil && group.process != nil {
+		return nil, false
+	}
+	return raw, true
+}
+
+// handleProcessModelRaw answers mcp.processModel with a RawModelHandler,
+// which gets the model data as sent.
+func (h *rpcHandler) handleProcessModelRaw(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, handler RawModelHandler) {
+	if req.Params == nil {
+		h.replyRPCError(ctx, conn, req, invalidModelRequest(nil, errors.New("missing model request")))
+		return
+	}
+	var rawReq core.RawModelRequest
+	if err := json.Unmarshal(*req.Params, &rawReq); err != nil {
+		h.replyRPCError(ctx, conn, req, invalidModelRequest(req.Params, err))
+		return
+	}
+	traceFromContext(ctx).identify(rawReq.ID)
+
+	resp, err := withHandlerTimeout(h.server, ctx, req.Method, rawReq.ID, func(ctx context.Context) (*core.RawModelResponse, error) {
+		return h.server.processModelRaw(ctx, handler, &rawReq)
+	})
+	if err != nil {
+		h.replyProcessError(ctx, conn, req, err)
+		return
+	}
+	h.reply(ctx, conn, req, resp)
+}
+
+// processModelRaw runs handler for req as processModel runs a ModelHandler:
+// with the request's metadata, inside a span and accounted to the method's
+// handler group, recovering a panic.
+func (s *Server) processModelRaw(ctx context.Context, handler RawModelHandler, req *core.RawModelRequest) (resp *core.RawModelResponse, err error) {
+	// Let CancelRequest reach the handler
+	defer s.trackRequest(ctx, req.ID)()
+
+	// Expose request metadata to the handler and continue the caller's trace
+	ctx = core.ContextWithMetadata(ctx, req.Metadata)
+	ctx, endSpan := s.options.Tracer.StartSpan(ctx, core.SpanServer, core.MethodProcessModel, req.ID)
+
+	// Stop the handler once the caller has given up waiting
+	if timeout, ok := core.TimeoutFromMetadata(req.Metadata); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, core.CauseDeadline)
+		defer cancel()
+	}
+
+	group, _ := ctx.Value(groupKey{}).(*HandlerGroup)
+	defer func() {
+		if r := recover(); r != nil {
+			fields := []interface{}{core.LogFieldRequestID, req.ID}
+			if group != nil {
+				fields = append(fields, "group", group.name)
+			}
+			err = s.handlePanic(core.MethodProcessModel, r, fields...)
+		}
+		if group != nil {
+			s.recordGroup(group, err != nil)
+		}
+		endSpan(err)
+	}()
+
+	traceFromContext(ctx).mark(RequestHandlerRunning)
+	results, err := handler.ProcessModelRaw(ctx, req.ID, req.ModelData, req.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("processing error: %w", err)
+	}
+	return &core.RawModelResponse{
+		ID:        req.ID,
+		Success:   true,
+		Results:   results,
+		Timestamp: time.Now(),
+		Metadata:  s.echoedMetadata(ctx, nil),
+	}, nil
+}
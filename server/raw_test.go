@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ForwardingModelHandler sends the model data back as its results, keeping
+// what its raw path was given
+type ForwardingModelHandler struct {
+	mu       sync.Mutex
+	raw      []json.RawMessage
+	params   [][]core.Parameter
+	metadata []map[string]string
+	decoded  int
+}
+
+func (h *ForwardingModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *ForwardingModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.decoded++
+	resp := core.NewModelResponse(req)
+	resp.Results = req.ModelData
+	return resp, nil
+}
+
+func (h *ForwardingModelHandler) ProcessModelRaw(ctx context.Context, id string, raw json.RawMessage, params []core.Parameter) (json.RawMessage, error) {
+	if id == "fail" {
+		return nil, core.NewModelError(core.ErrInvalidModel, errors.New("payload rejected"))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.raw = append(h.raw, raw)
+	h.params = append(h.params, params)
+	h.metadata = append(h.metadata, core.MetadataFromContext(ctx))
+	return raw, nil
+}
+
+// startRawPair connects a client to a server running handler
+func startRawPair(t *testing.T, handler Handler, options ...Option) *client.Client {
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(append(options, WithTransport(transport), WithLogger(core.NopLogger()))...)
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c
+}
+
+func TestProcessModelRawPassthrough(t *testing.T) {
+	handler := &ForwardingModelHandler{}
+	c := startRawPair(t, handler)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	payload := json.RawMessage(`{"tensor":[1.5,-2,3e+21],"label":"café","nested":{"empty":{},"none":null},"flag":true}`)
+	params := []core.Parameter{{Name: "mode", Value: "fast", Type: "string"}}
+	results, err := c.ProcessModelRaw(core.ContextWithMetadata(ctx, map[string]string{"tenant": "acme"}), "raw-1", payload, params)
+	require.NoError(t, err, "Raw request should succeed")
+	assert.Equal(t, string(payload), string(results), "Results should come back byte for byte")
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	require.Len(t, handler.raw, 1, "Raw path should have handled the request")
+	assert.Equal(t, string(payload), string(handler.raw[0]), "Handler should get the model data byte for byte")
+	assert.Equal(t, params, handler.params[0], "Handler should get the parameters")
+	assert.Equal(t, "acme", handler.metadata[0]["tenant"], "Handler should see the request metadata")
+	assert.Contains(t, handler.metadata[0], core.MetadataTimeout, "Deadline should be passed on as for ProcessModel")
+	assert.Zero(t, handler.decoded, "Model data should not be decoded")
+}
+
+func TestProcessModelRawFailure(t *testing.T) {
+	c := startRawPair(t, &ForwardingModelHandler{})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModelRaw(ctx, "fail", json.RawMessage(`{}`), nil)
+	var modelErr *core.ModelError
+	require.ErrorAs(t, err, &modelErr, "Handler's model error should reach the client")
+	assert.Equal(t, core.ErrInvalidModel, modelErr.Code, "Error code should be kept")
+}
+
+func TestProcessModelToRawHandler(t *testing.T) {
+	handler := &ForwardingModelHandler{}
+	c := startRawPair(t, handler, WithEchoMetadata("tenant"))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Decoding clients are served by the raw path too
+	req := core.NewModelRequest()
+	req.ModelData["name"] = "forwarded"
+	resp, err := c.ProcessModel(core.ContextWithMetadata(ctx, map[string]string{"tenant": "acme"}), req)
+	require.NoError(t, err, "Request should succeed")
+	assert.True(t, resp.Success, "Response should succeed")
+	assert.Equal(t, req.ID, resp.ID, "Response should carry the request ID")
+	assert.Equal(t, "forwarded", resp.Results["name"], "Results should decode into the response")
+	assert.False(t, resp.Timestamp.IsZero(), "Response should be timestamped")
+	assert.Equal(t, "acme", resp.Metadata["tenant"], "Configured metadata should be echoed")
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Len(t, handler.raw, 1, "Raw path should be preferred")
+	assert.Zero(t, handler.decoded, "Decoding path should not be used")
+}
+
+func TestProcessModelRawToModelHandler(t *testing.T) {
+	c := startRawPair(t, &DecodingModelHandler{})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Servers without a raw handler decode the model data as usual
+	results, err := c.ProcessModelRaw(ctx, "raw-2", json.RawMessage(`{"name":"decoded"}`), nil)
+	require.NoError(t, err, "Raw request should succeed")
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(results, &decoded), "Results should be JSON")
+	assert.Equal(t, "decoded", decoded["name"], "Handler should have decoded the model data")
+}
+
+// DecodingModelHandler sends the model data back as its results, having
+// decoded it
+type DecodingModelHandler struct{}
+
+func (DecodingModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (DecodingModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	resp.Results = req.ModelData
+	return resp, nil
+}
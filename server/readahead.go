@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// readAheadFrames bounds how many frames a connection reads ahead of the
+// request being handled. A cancellation sent behind more requests than that
+// is seen only once the ones before it have been handled.
+const readAheadFrames = 16
+
+// readAheadStream reads the frames of a jsonrpc2 object stream on a
+// goroutine of the connection's own. jsonrpc2 handles each request on its
+// read loop before reading the next frame, so a core.MethodCancelRequest
+// notification would otherwise wait for the very call it cancels. The
+// notification is acted on as soon as it arrives and not passed on; every
+// other frame is, in order.
+type readAheadStream struct {
+	jsonrpc2.ObjectStream // Writes, and the reads made by run
+
+	cancel  func(jsonrpc2.ID) // Cancels the call a notification names
+	frames  chan readFrame
+	closing chan struct{} // Closed by Close; run exits without passing on what it read
+	once    sync.Once
+}
+
+// readFrame is a frame read ahead, or the error that ended the stream.
+type readFrame struct {
+	data json.RawMessage
+	err  error
+}
+
+// newReadAheadStream returns a stream reading stream ahead, calling cancel
+// with the ID named by each cancellation.
+func newReadAheadStream(stream jsonrpc2.ObjectStream, cancel func(jsonrpc2.ID)) *readAheadStream {
+	return &readAheadStream{
+		ObjectStream: stream,
+		cancel:       cancel,
+		frames:       make(chan readFrame, readAheadFrames),
+		closing:      make(chan struct{}),
+	}
+}
+
+// run reads frames until the stream fails or is closed.
+func (s *readAheadStream) run() {
+	for {
+		var frame readFrame
+		frame.err = s.ObjectStream.ReadObject(&frame.data)
+		if frame.err == nil && s.intercept(frame.data) {
+			continue
+		}
+
+		select {
+		case s.frames <- frame:
+		case <-s.closing:
+			return
+		}
+		if frame.err != nil {
+			return
+		}
+	}
+}
+
+// intercept cancels the call named by data if it is a cancellation,
+// reporting whether it was one. Malformed cancellations are dropped too.
+func (s *readAheadStream) intercept(data json.RawMessage) bool {
+	if !bytes.Contains(data, []byte(core.MethodCancelRequest)) {
+		return false
+	}
+	var req jsonrpc2.Request
+	if err := json.Unmarshal(data, &req); err != nil || !req.Notif || req.Method != core.MethodCancelRequest {
+		return false
+	}
+
+	var params core.CancelRequestParams
+	if req.Params != nil && json.Unmarshal(*req.Params, &params) == nil {
+		s.cancel(params.ID)
+	}
+	return true
+}
+
+// ReadObject implements jsonrpc2.ObjectStream, decoding the next frame read
+// ahead into v.
+func (s *readAheadStream) ReadObject(v interface{}) error {
+	select {
+	case frame := <-s.frames:
+		if frame.err != nil {
+			return frame.err
+		}
+		return core.JSONCodec.Unmarshal(frame.data, v)
+	case <-s.closing:
+		return io.ErrClosedPipe
+	}
+}
+
+// Close implements jsonrpc2.ObjectStream.
+func (s *readAheadStream) Close() error {
+	s.once.Do(func() { close(s.closing) })
+	return s.ObjectStream.Close()
+}
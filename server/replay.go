@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Recorder receives every successfully processed model exchange so it can be
+// replayed later. The request carries the seed and time that were injected
+// into the handler, making the recorded exchange reproducible.
+type Recorder interface {
+	Record(req *core.ModelRequest, resp *core.ModelResponse)
+}
+
+// determinismContext prepares the handler context for reproducible runs.
+// In replay mode, a request carrying replay metadata gets a random source and
+// clock pinned to the recorded values. When recording, a fresh seed and the
+// current time are injected and stamped onto the request for the recorder.
+func (s *Server) determinismContext(ctx context.Context, req *core.ModelRequest) context.Context {
+	if s.options.ReplayMode {
+		if seed, at, ok := req.ReplaySeed(); ok {
+			return core.ContextWithReplay(ctx, seed, at)
+		}
+		return ctx
+	}
+
+	if s.options.Recorder == nil {
+		return ctx
+	}
+
+	seed, at := core.NewSeed(), time.Now().UTC()
+	req.SetReplaySeed(seed, at)
+	return core.ContextWithReplay(ctx, seed, at)
+}
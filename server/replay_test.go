@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RandomModelHandler produces output that depends on randomness and time
+type RandomModelHandler struct{}
+
+func (h *RandomModelHandler) Methods() []string {
+	return []string{"mcp.processModel"}
+}
+
+func (h *RandomModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	rnd := core.RandFromContext(ctx)
+	resp := core.NewModelResponse(req)
+	resp.Results["roll"] = rnd.Int63()
+	resp.Results["shuffle"] = rnd.Perm(8)
+	resp.Results["at"] = core.ClockFromContext(ctx).Now().Format(time.RFC3339Nano)
+	return resp, nil
+}
+
+// startReplayServer starts a server with the random handler and a connected client
+func startReplayServer(t *testing.T, options ...Option) (*Server, *client.Client) {
+	return startServerWithHandler(t, &RandomModelHandler{}, options...)
+}
+
+func TestReplayReproducesResults(t *testing.T) {
+	recorder := testutil.NewExchangeRecorder()
+	_, recordClient := startReplayServer(t, WithRecorder(recorder))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Capture an exchange from the randomness-using handler
+	original, err := recordClient.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed while recording")
+
+	exchanges := recorder.Exchanges()
+	require.Len(t, exchanges, 1, "One exchange should be recorded")
+	recorded := exchanges[0].Request
+	_, _, ok := recorded.ReplaySeed()
+	assert.True(t, ok, "Recorded request should carry the injected seed and time")
+
+	// Replay the recorded request against a replay-mode server
+	_, replayClient := startReplayServer(t, WithReplayMode(true))
+	replayed, err := replayClient.ProcessModel(ctx, recorded)
+	require.NoError(t, err, "ProcessModel should succeed while replaying")
+
+	want, err := json.Marshal(original.Results)
+	require.NoError(t, err)
+	got, err := json.Marshal(replayed.Results)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "Replayed results should be byte-identical")
+}
+
+func TestReplayIgnoredOutsideReplayMode(t *testing.T) {
+	_, c := startReplayServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// A seed in the metadata must not pin anything unless replay mode is on
+	req := testutil.CreateTestModelRequest()
+	req.SetReplaySeed(42, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	first, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "First request should succeed")
+	second, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Second request should succeed")
+
+	assert.NotEqual(t, first.Results["at"], "2020-01-01T00:00:00Z", "Clock should be real outside replay mode")
+	assert.NotEqual(t, first.Results["roll"], second.Results["roll"], "Random source should not be seeded outside replay mode")
+}
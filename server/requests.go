@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// RequestState is how far a call has got through the server's pipeline.
+type RequestState string
+
+const (
+	RequestReceived       RequestState = "received"        // The call was read from the connection
+	RequestValidated      RequestState = "validated"       // The call passed authentication, authorization and capability checks and its method was resolved
+	RequestQueued         RequestState = "queued"          // The call is waiting for a handler slot
+	RequestDispatched     RequestState = "dispatched"      // The call holds a handler slot and its parameters are being decoded and validated
+	RequestHandlerRunning RequestState = "handler-running" // The handler is processing the call
+	RequestReplying       RequestState = "replying"        // The reply is being written or queued for the connection
+	RequestDone           RequestState = "done"            // The call has been answered
+)
+
+// requestStates lists the states in the order calls go through them. A call
+// may skip states, such as one refused before it reaches a handler.
+var requestStates = [...]RequestState{
+	RequestReceived, RequestValidated, RequestQueued, RequestDispatched,
+	RequestHandlerRunning, RequestReplying, RequestDone,
+}
+
+// recentRequests is the number of finished calls whose history is kept for
+// LookupRequest.
+const recentRequests = 64
+
+// RequestTransition is a state a call entered and when.
+type RequestTransition struct {
+	State RequestState `json:"state"`
+	At    time.Time    `json:"at"`
+}
+
+// RequestInfo describes a call being handled, or recently handled, by the
+// server.
+type RequestInfo struct {
+	ID         string              `json:"id,omitempty"` // Model request ID; empty for calls without a single model request
+	Call       string              `json:"call"`         // JSON-RPC ID of the call
+	Method     string              `json:"method"`
+	Client     string              `json:"client,omitempty"`     // ID of the client, as reported by Clients
+	Handler    string              `json:"handler,omitempty"`    // Type of the handler the method resolved to
+	Group      string              `json:"group,omitempty"`      // Handler group the method is registered in
+	Subprocess bool                `json:"subprocess,omitempty"` // Whether the group's handlers run in a child process
+	State      RequestState        `json:"state"`
+	Age        time.Duration       `json:"age"`     // Time since the call was received, until it was answered
+	History    []RequestTransition `json:"history"` // States entered so far, oldest first
+}
+
+// requestTrace records the states a call goes through. Each state's time is
+// kept in a fixed slot, so recording a transition does not allocate.
+type requestTrace struct {
+	call   string
+	method string
+	client string
+
+	mu      sync.Mutex
+	id      string
+	handler interface{}
+	group   *HandlerGroup
+	state   int // Index in requestStates of the latest state entered
+	times   [len(requestStates)]time.Time
+}
+
+// requestTraceKey is the context key of the trace of the call being handled.
+type requestTraceKey struct{}
+
+// mark records that the call entered state, unless it is already past it.
+// It may be called on a nil trace.
+func (t *requestTrace) mark(state RequestState) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := t.state + 1; i < len(requestStates); i++ {
+		if requestStates[i] == state {
+			t.state, t.times[i] = i, now
+			return
+		}
+	}
+}
+
+// resolved records the handler and group the call's method resolved to.
+func (t *requestTrace) resolved(handler interface{}, group *HandlerGroup) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler, t.group = handler, group
+}
+
+// identify records the ID of the model request the call carries. It may be
+// called on a nil trace.
+func (t *requestTrace) identify(id string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.id = id
+}
+
+// identifiedAs returns whether id is the call's JSON-RPC ID or that of the
+// model request it carries.
+func (t *requestTrace) identifiedAs(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.call == id || t.id == id
+}
+
+// info describes the call as of now.
+func (t *requestTrace) info(now time.Time) RequestInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info := RequestInfo{
+		ID:     t.id,
+		Call:   t.call,
+		Method: t.method,
+		Client: t.client,
+		State:  requestStates[t.state],
+	}
+	if t.handler != nil {
+		info.Handler = fmt.Sprintf("%T", t.handler)
+	}
+	if t.group != nil {
+		info.Group = t.group.name
+		info.Subprocess = t.group.process != nil
+	}
+	if info.State == RequestDone {
+		now = t.times[t.state]
+	}
+	info.Age = now.Sub(t.times[0])
+	for i, at := range t.times[:t.state+1] {
+		if !at.IsZero() {
+			info.History = append(info.History, RequestTransition{State: requestStates[i], At: at})
+		}
+	}
+	return info
+}
+
+// requestTraces tracks the calls being handled, and the last recentRequests
+// finished, for InFlightRequests and LookupRequest.
+type requestTraces struct {
+	mu     sync.Mutex
+	live   map[*requestTrace]struct{}
+	recent [recentRequests]*requestTrace
+	next   int // Slot of recent the next finished call goes in
+}
+
+// start begins tracing req, received from the client with the given ID.
+func (r *requestTraces) start(req *jsonrpc2.Request, client string) *requestTrace {
+	t := &requestTrace{call: req.ID.String(), method: req.Method, client: client}
+	t.times[0] = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.live == nil {
+		r.live = make(map[*requestTrace]struct{})
+	}
+	r.live[t] = struct{}{}
+	return t
+}
+
+// finish marks t done and keeps it among the recent calls.
+func (r *requestTraces) finish(t *requestTrace) {
+	t.mark(RequestDone)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.live, t)
+	r.recent[r.next] = t
+	r.next = (r.next + 1) % recentRequests
+}
+
+// inFlight describes the calls being handled, oldest first.
+func (r *requestTraces) inFlight() []RequestInfo {
+	r.mu.Lock()
+	traces := make([]*requestTrace, 0, len(r.live))
+	for t := range r.live {
+		traces = append(traces, t)
+	}
+	r.mu.Unlock()
+
+	now := time.Now()
+	infos := make([]RequestInfo, len(traces))
+	for i, t := range traces {
+		infos[i] = t.info(now)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Age > infos[j].Age
+	})
+	return infos
+}
+
+// lookup describes the call with JSON-RPC ID id or carrying the model
+// request with it, preferring one being handled over the most recently
+// finished.
+func (r *requestTraces) lookup(id string) (RequestInfo, bool) {
+	r.mu.Lock()
+	var found *requestTrace
+	for t := range r.live {
+		if t.identifiedAs(id) {
+			found = t
+			break
+		}
+	}
+	for i := 1; found == nil && i <= recentRequests; i++ {
+		t := r.recent[(r.next-i+recentRequests)%recentRequests]
+		if t != nil && t.identifiedAs(id) {
+			found = t
+		}
+	}
+	r.mu.Unlock()
+
+	if found == nil {
+		return RequestInfo{}, false
+	}
+	return found.info(time.Now()), true
+}
+
+// traceFromContext returns the trace of the call handled with ctx, or nil.
+func traceFromContext(ctx context.Context) *requestTrace {
+	t, _ := ctx.Value(requestTraceKey{}).(*requestTrace)
+	return t
+}
+
+// InFlightRequests describes the calls the server is handling, oldest first,
+// with the states each has gone through. Notifications from clients are not
+// tracked.
+func (s *Server) InFlightRequests() []RequestInfo {
+	return s.requests.inFlight()
+}
+
+// LookupRequest describes the call carrying the model request with the given
+// ID, or with it as its JSON-RPC ID: the one being handled if there is one,
+// or else the most recent of the last calls the server finished. Calls are
+// known by their model request ID only once it has been decoded, after they
+// get a handler slot.
+func (s *Server) LookupRequest(requestID string) (RequestInfo, bool) {
+	return s.requests.lookup(requestID)
+}
+
+// serveRequests writes InFlightRequests as JSON for /requests, and
+// LookupRequest for /requests/{id}, answering 404 if it finds nothing.
+func (s *Server) serveRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body interface{} = s.InFlightRequests()
+	if id := strings.TrimPrefix(r.URL.Path, "/requests/"); id != r.URL.Path && id != "" {
+		info, ok := s.LookupRequest(id)
+		if !ok {
+			http.Error(w, fmt.Sprintf("no request with ID %s", id), http.StatusNotFound)
+			return
+		}
+		body = info
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/metrics"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getJSON decodes the JSON served at url into v and returns the status code
+func getJSON(t *testing.T, url string, v interface{}) int {
+	resp, err := http.Get(url)
+	require.NoError(t, err, "Endpoint should be reachable")
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v), "Endpoint should serve JSON")
+	}
+	return resp.StatusCode
+}
+
+// states lists the states of a request's history
+func states(info RequestInfo) []RequestState {
+	var states []RequestState
+	for _, transition := range info.History {
+		states = append(states, transition.State)
+	}
+	return states
+}
+
+func TestInFlightRequests(t *testing.T) {
+	handler := newGatedModelHandler()
+	collector := metrics.NewExpvarCollector("mcp_server_inflight_requests_test")
+	srv, c := startServerWithHandler(t, handler, WithMetrics(collector), WithMetricsAddr("127.0.0.1:0"),
+		WithMaxConcurrentRequests(4))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	endpoint := "http://" + srv.MetricsAddr().String() + "/requests"
+
+	assert.Empty(t, srv.InFlightRequests(), "Idle server should have no requests in flight")
+
+	req := testutil.CreateTestModelRequest()
+	sent := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.ProcessModel(ctx, req)
+		done <- err
+	}()
+	receive(t, handler.started, "request")
+	time.Sleep(20 * time.Millisecond)
+
+	// A request held by its handler is reported running in it
+	var inFlight []RequestInfo
+	require.Equal(t, http.StatusOK, getJSON(t, endpoint, &inFlight), "In-flight requests should be listed")
+	require.Len(t, inFlight, 1, "The held request should be in flight")
+	info := inFlight[0]
+	assert.Equal(t, req.ID, info.ID, "Request should be identified by its model request ID")
+	assert.Equal(t, RequestHandlerRunning, info.State, "Held request should be running in its handler")
+	assert.Equal(t, "*server.GatedModelHandler", info.Handler, "Request should name its handler")
+	assert.GreaterOrEqual(t, info.Age, 20*time.Millisecond, "Age should cover the time held")
+	assert.LessOrEqual(t, info.Age, time.Since(sent), "Age should not exceed the time since sending")
+	assert.Equal(t, srv.InFlightRequests()[0].ID, info.ID, "InFlightRequests should report the same request")
+
+	close(handler.release)
+	require.NoError(t, <-done, "Released request should succeed")
+
+	// Once answered the request leaves the list, and keeps its full history
+	assert.Empty(t, srv.InFlightRequests(), "Answered request should no longer be in flight")
+	require.Equal(t, http.StatusOK, getJSON(t, endpoint+"/"+req.ID, &info), "Answered request should be found")
+	assert.Equal(t, RequestDone, info.State, "Answered request should be done")
+	assert.Equal(t, []RequestState{
+		RequestReceived, RequestValidated, RequestQueued, RequestDispatched,
+		RequestHandlerRunning, RequestReplying, RequestDone,
+	}, states(info), "History should list every transition in order")
+	for i := 1; i < len(info.History); i++ {
+		assert.False(t, info.History[i].At.Before(info.History[i-1].At), "Transitions should be in time order")
+	}
+	assert.InDelta(t, info.History[len(info.History)-1].At.Sub(info.History[0].At), info.Age, float64(time.Millisecond),
+		"Age of an answered request should stop when it was done")
+
+	_, ok := srv.LookupRequest("unknown")
+	assert.False(t, ok, "Unknown request should not be found")
+	assert.Equal(t, http.StatusNotFound, getJSON(t, endpoint+"/unknown", nil), "Unknown request should not be served")
+}
+
+func TestQueuedRequest(t *testing.T) {
+	handler := newGatedModelHandler()
+	srv, clients := startPooledServer(t, handler, 2, WithMaxConcurrentRequests(1), WithRequestQueueSize(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	held := processAsync(ctx, clients[0])
+	receive(t, handler.started, "held request")
+	queued := processAsync(ctx, clients[1])
+
+	// The request waiting for the slot is reported queued, behind the one holding it
+	var inFlight []RequestInfo
+	require.Eventually(t, func() bool {
+		inFlight = srv.InFlightRequests()
+		return len(inFlight) == 2 && inFlight[1].State == RequestQueued
+	}, 2*time.Second, 5*time.Millisecond, "Second request should be queued")
+	assert.Equal(t, RequestHandlerRunning, inFlight[0].State, "Oldest request should hold the slot")
+	assert.Empty(t, inFlight[1].ID, "Queued request should not be decoded yet")
+	call := inFlight[1].Call
+
+	close(handler.release)
+	require.NoError(t, <-held, "Held request should succeed")
+	require.NoError(t, <-queued, "Queued request should succeed once the slot is free")
+
+	// Calls can be looked up by their JSON-RPC ID too
+	info, ok := srv.LookupRequest(call)
+	require.True(t, ok, "Answered request should be found by its call ID")
+	assert.NotEmpty(t, info.ID, "Decoded request should be identified by its model request ID")
+	assert.Equal(t, RequestDone, info.State, "Answered request should be done")
+	assert.Contains(t, states(info), RequestQueued, "History should show the time queued")
+}
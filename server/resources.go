@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ResourceHandler exposes resources, named by URI, over mcp.listResources and
+// mcp.readResource. ReadResource returns an error wrapping
+// core.ErrResourceNotFound for a URI it does not serve.
+type ResourceHandler interface {
+	ListResources(ctx context.Context) ([]core.Resource, error)
+	ReadResource(ctx context.Context, uri string) (*core.ResourceContent, error)
+}
+
+// ResourceWatcher can be implemented by a ResourceHandler to report changes
+// to its resources. The server runs WatchResources while it is running,
+// publishing a core.ResourceChanges notification to the clients subscribed
+// to the resource for every call to changed. WatchResources returns once ctx
+// ends.
+type ResourceWatcher interface {
+	WatchResources(ctx context.Context, changed func(uri string))
+}
+
+// RegisterResourceHandler registers the handler serving the server's
+// resources. Only one may be registered. If it is a ResourceWatcher, its
+// watch starts now if the server is running, or when it starts.
+func (s *Server) RegisterResourceHandler(handler ResourceHandler) error {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	s.handlersMu.Lock()
+	if s.resources != nil {
+		s.handlersMu.Unlock()
+		return errors.New("resource handler already registered")
+	}
+	s.resources = handler
+	s.handlersMu.Unlock()
+
+	if s.Status() == core.StatusRunning {
+		s.watchResources()
+	}
+	return nil
+}
+
+// NotifyResourceChanged tells the clients subscribed to the resource uri that
+// it has changed. Handlers that are not a ResourceWatcher call it themselves.
+func (s *Server) NotifyResourceChanged(uri string) error {
+	return PublishTopic(s, core.ResourceTopic(uri), core.ResourceChanges, core.ResourceChanged{URI: uri})
+}
+
+// resourceHandler returns the registered resource handler, or nil.
+func (s *Server) resourceHandler() ResourceHandler {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.resources
+}
+
+// watchResources runs the resource handler's watch for the current run, if
+// it has one. The caller holds lifecycleMu, so Stop waits for the watch to
+// return.
+func (s *Server) watchResources() {
+	watcher, ok := s.resourceHandler().(ResourceWatcher)
+	if !ok {
+		return
+	}
+	ctx := s.runContext()
+	s.wg.Add(1)
+	s.tasks.Go(core.TaskResources, func() {
+		defer s.wg.Done()
+		watcher.WatchResources(ctx, func(uri string) {
+			if err := s.NotifyResourceChanged(uri); err != nil {
+				s.options.Logger.Warn("Failed to publish resource change", "uri", uri, core.LogFieldError, err)
+			}
+		})
+	})
+}
+
+// handleResourceRequest answers mcp.listResources and mcp.readResource,
+// calling the handler in a handler slot like any model request. Without a
+// resource handler the server offers no resources.
+func (h *rpcHandler) handleResourceRequest(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	var readReq core.ReadResourceRequest
+	if req.Method == core.MethodReadResource {
+		if req.Params == nil || json.Unmarshal(*req.Params, &readReq) != nil || readReq.URI == "" {
+			h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing resource URI")
+			return
+		}
+	}
+
+	handler := h.server.resourceHandler()
+	if handler == nil {
+		if req.Method == core.MethodListResources {
+			h.reply(ctx, conn, req, core.ListResourcesResponse{Resources: []core.Resource{}})
+		} else {
+			h.replyError(ctx, conn, req, core.CodeResourceNotFound, fmt.Sprintf("resource not found: %s", readReq.URI))
+		}
+		return
+	}
+
+	if !h.server.pool.acquire(ctx) {
+		h.replyError(ctx, conn, req, core.CodeServerBusy, "server busy, too many requests in flight")
+		return
+	}
+	defer h.server.pool.release()
+
+	if req.Method == core.MethodListResources {
+		resources, err := handler.ListResources(ctx)
+		if err != nil {
+			h.replyProcessError(ctx, conn, req, err)
+			return
+		}
+		if resources == nil {
+			resources = []core.Resource{}
+		}
+		h.reply(ctx, conn, req, core.ListResourcesResponse{Resources: resources})
+		return
+	}
+
+	content, err := handler.ReadResource(ctx, readReq.URI)
+	if errors.Is(err, core.ErrResourceNotFound) {
+		h.replyError(ctx, conn, req, core.CodeResourceNotFound, fmt.Sprintf("resource not found: %s", readReq.URI))
+		return
+	}
+	if err != nil {
+		h.replyProcessError(ctx, conn, req, err)
+		return
+	}
+	h.reply(ctx, conn, req, content)
+}
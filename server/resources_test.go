@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startResourcePair starts a server serving the files under a temporary
+// directory, and a client connected to it
+func startResourcePair(t *testing.T) (*client.Client, *Server, *FileResources, string) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("first draft"), 0o644), "File should be written")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "data"), 0o755), "Directory should be created")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data", "weights.bin"), []byte{0xff, 0x00, 0xfe}, 0o644), "File should be written")
+
+	files, err := NewFileResources(dir, 10*time.Millisecond)
+	require.NoError(t, err, "File resources should be created")
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+			require.NoError(t, srv.RegisterResourceHandler(files), "Resource handler registration should succeed")
+			return srv
+		},
+	)
+	return c, srv, files, dir
+}
+
+func TestListResources(t *testing.T) {
+	c, _, files, _ := startResourcePair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resources, err := c.ListResources(ctx)
+	require.NoError(t, err, "Listing resources should succeed")
+	require.Len(t, resources, 2, "Every file should be listed")
+	assert.Equal(t, core.Resource{URI: files.URI("data/weights.bin"), Name: "data/weights.bin", MimeType: "application/octet-stream"},
+		resources[0], "Nested file should be listed")
+	assert.Equal(t, "notes.txt", resources[1].Name, "Top-level file should be listed")
+	assert.Equal(t, "text/plain; charset=utf-8", resources[1].MimeType, "MIME type should follow the extension")
+}
+
+func TestReadResource(t *testing.T) {
+	c, _, files, _ := startResourcePair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	content, err := c.ReadResource(ctx, files.URI("notes.txt"))
+	require.NoError(t, err, "Reading a file should succeed")
+	assert.Equal(t, "first draft", content.Text, "Text file should be returned as text")
+	assert.Empty(t, content.Blob, "Text file should carry no blob")
+
+	content, err = c.ReadResource(ctx, files.URI("data/weights.bin"))
+	require.NoError(t, err, "Reading a binary file should succeed")
+	assert.Equal(t, []byte{0xff, 0x00, 0xfe}, content.Blob, "Binary file should be returned as a blob")
+
+	for _, uri := range []string{
+		files.URI("missing.txt"),
+		files.URI("data"),
+		files.URI("../outside.txt"),
+		"https://example.com/notes.txt",
+	} {
+		_, err = c.ReadResource(ctx, uri)
+		assert.ErrorIs(t, err, core.ErrResourceNotFound, "%s should not be found", uri)
+		var rpcErr *jsonrpc2.Error
+		if assert.ErrorAs(t, err, &rpcErr, "%s should fail with a JSON-RPC error", uri) {
+			assert.Equal(t, core.CodeResourceNotFound, rpcErr.Code, "%s should use the resource not found code", uri)
+		}
+	}
+}
+
+func TestSubscribeResource(t *testing.T) {
+	c, _, files, dir := startResourcePair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	unsubscribe, err := c.SubscribeResource(ctx, files.URI("notes.txt"), func() { changes <- struct{}{} })
+	require.NoError(t, err, "Subscribing should succeed")
+
+	// Changes to other files are not reported
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.txt"), []byte("unrelated"), 0o644), "File should be written")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("second draft, longer"), 0o644), "File should be modified")
+
+	select {
+	case <-changes:
+	case <-ctx.Done():
+		t.Fatal("Change to the file should be reported")
+	}
+	content, err := c.ReadResource(ctx, files.URI("notes.txt"))
+	require.NoError(t, err, "Reading the changed file should succeed")
+	assert.Equal(t, "second draft, longer", content.Text, "Changed content should be read")
+
+	// No more changes are reported once unsubscribed
+	unsubscribe()
+	require.Eventually(t, func() bool {
+		select {
+		case <-changes:
+		default:
+		}
+		if os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(time.Now().String()), 0o644) != nil {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+		return len(changes) == 0
+	}, time.Second, 10*time.Millisecond, "Changes should stop being reported")
+}
+
+func TestRegisterResourceHandlerWhileRunning(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewFileResources(dir, 10*time.Millisecond)
+	require.NoError(t, err, "File resources should be created")
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			return New(WithTransport(transport), WithLogger(core.NopLogger()))
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Without a handler there are no resources
+	resources, err := c.ListResources(ctx)
+	require.NoError(t, err, "Listing resources should succeed")
+	assert.Empty(t, resources, "No resources should be listed")
+	_, err = c.ReadResource(ctx, files.URI("new.txt"))
+	assert.ErrorIs(t, err, core.ErrResourceNotFound, "Reading should fail")
+
+	// A handler registered while running starts watching straight away
+	require.NoError(t, srv.RegisterResourceHandler(files), "Registration should succeed")
+	assert.Error(t, srv.RegisterResourceHandler(files), "Second registration should fail")
+
+	changes := make(chan struct{}, 10)
+	unsubscribe, err := c.SubscribeResource(ctx, files.URI("new.txt"), func() { changes <- struct{}{} })
+	require.NoError(t, err, "Subscribing should succeed")
+	defer unsubscribe()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("created"), 0o644), "File should be written")
+
+	select {
+	case <-changes:
+	case <-ctx.Done():
+		t.Fatal("Created file should be reported")
+	}
+}
+
+func TestNewFileResources(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, nil, 0o644), "File should be written")
+
+	_, err := NewFileResources(file, time.Second)
+	assert.Error(t, err, "File should not be served as a directory")
+	_, err = NewFileResources(filepath.Join(dir, "missing"), time.Second)
+	assert.Error(t, err, "Missing directory should be rejected")
+	_, err = NewFileResources(dir, 0)
+	assert.Error(t, err, "Zero poll interval should be rejected")
+}
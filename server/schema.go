@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/core/tools"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// MethodDescriber can be implemented by a Handler to declare the parameters
+// and model schema its methods accept. Declared methods are listed by
+// mcp.listMethods and mcp.describeMethod, and unless WithSchemaValidation is
+// disabled, requests that do not match are rejected with CodeInvalidParams
+// before the handler runs.
+type MethodDescriber interface {
+	DescribeMethods() []core.MethodDescription
+}
+
+// describeMethods returns a description of every registered method, sorted by
+// name. Methods whose handler declares nothing are listed by name alone.
+func (s *Server) describeMethods() []core.MethodDescription {
+	s.handlersMu.RLock()
+	registered := make([]string, 0, len(s.handlers))
+	for method := range s.handlers {
+		registered = append(registered, method)
+	}
+	s.handlersMu.RUnlock()
+
+	methods := make([]core.MethodDescription, 0, len(registered))
+	for _, method := range registered {
+		desc, ok := s.description(method)
+		if !ok {
+			desc = core.MethodDescription{Method: method}
+		}
+		methods = append(methods, desc)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Method < methods[j].Method })
+	return methods
+}
+
+// description returns the description the handler for method declares, if any.
+func (s *Server) description(method string) (core.MethodDescription, bool) {
+	handler, _, _ := s.lookup(method)
+	describer, ok := handler.(MethodDescriber)
+	if !ok {
+		return core.MethodDescription{}, false
+	}
+	for _, desc := range describer.DescribeMethods() {
+		if desc.Method == method {
+			return desc, true
+		}
+	}
+	return core.MethodDescription{}, false
+}
+
+// describeMethod returns the description of a registered method, naming
+// only the method if its handler declares nothing.
+func (s *Server) describeMethod(method string) (core.MethodDescription, bool) {
+	if _, _, ok := s.lookup(method); !ok {
+		return core.MethodDescription{}, false
+	}
+	if desc, ok := s.description(method); ok {
+		return desc, true
+	}
+	return core.MethodDescription{Method: method}, true
+}
+
+// handleDescribeMethod answers mcp.describeMethod.
+func (h *rpcHandler) handleDescribeMethod(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	var params core.DescribeMethodRequest
+	if req.Params == nil || json.Unmarshal(*req.Params, &params) != nil || params.Method == "" {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing method")
+		return
+	}
+	desc, ok := h.server.describeMethod(params.Method)
+	if !ok {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeMethodNotFound, fmt.Sprintf("method not found: %s", params.Method))
+		return
+	}
+	h.reply(ctx, conn, req, desc)
+}
+
+// validateRequest converts the parameters of req to their declared types
+// with parameter coercion enabled, and checks req against the declared
+// description of method. It returns nil if the request is valid, the method
+// declares nothing, or schema validation is disabled.
+func (s *Server) validateRequest(method string, req *core.ModelRequest) *tools.ValidationResult {
+	if s.options.ParameterCoercion {
+		if result := req.Parameters.Coerce(); !result.Valid {
+			return result
+		}
+	}
+	if !s.options.SchemaValidation {
+		return nil
+	}
+	desc, ok := s.description(method)
+	if !ok {
+		return nil
+	}
+	if result := desc.Validate(req); !result.Valid {
+		return result
+	}
+	return nil
+}
+
+// NotifyMethodsChanged tells every connected client that the methods the
+// server serves, or their declared schemas, have changed, so clients that
+// cache descriptions fetch them again. Handlers whose DescribeMethods result
+// changes while the server runs should call it.
+func (s *Server) NotifyMethodsChanged() {
+	if err := Publish(s, core.MethodsChanged, struct{}{}); err != nil {
+		s.options.Logger.Debug("Failed to notify client of method changes", core.LogFieldError, err)
+	}
+}
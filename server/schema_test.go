@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/core/tools"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testModelSchema accepts the model data of testutil.CreateTestModelRequest
+// as long as the value is at most 100
+func testModelSchema() *core.Schema {
+	maxValue := 100.0
+	return &core.Schema{
+		Type:     "object",
+		Required: []string{"name", "value"},
+		Properties: map[string]*core.Schema{
+			"name":  {Type: "string"},
+			"value": {Type: "number", Maximum: &maxValue},
+		},
+	}
+}
+
+// startSchemaPair starts a server with a schema handler and the given
+// options, and a client without local validation
+func startSchemaPair(t *testing.T, options ...Option) (*client.Client, *testutil.SchemaHandler) {
+	handler := testutil.NewSchemaHandler(testModelSchema())
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(append(options, WithTransport(transport))...)
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return c, handler
+}
+
+func TestListMethods(t *testing.T) {
+	c, _ := startSchemaPair(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	methods, err := c.FetchMethodSchemas(ctx)
+	require.NoError(t, err, "Listing methods should succeed")
+	require.Len(t, methods, 1, "Only the registered method should be listed")
+	assert.Equal(t, core.MethodProcessModel, methods[0].Method, "Method should be named")
+	assert.Equal(t, testModelSchema(), methods[0].ModelSchema, "Declared schema should be listed")
+}
+
+func TestDescribeMethod(t *testing.T) {
+	c, _ := startSchemaPair(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	desc, err := c.DescribeMethod(ctx, core.MethodProcessModel)
+	require.NoError(t, err, "Describing a registered method should succeed")
+	assert.Equal(t, core.MethodProcessModel, desc.Method, "Method should be named")
+	assert.Equal(t, testModelSchema(), desc.ModelSchema, "Declared schema should be returned")
+
+	_, err = c.DescribeMethod(ctx, "mcp.unknown")
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Unknown method should fail with a JSON-RPC error")
+	assert.Equal(t, int64(jsonrpc2.CodeMethodNotFound), rpcErr.Code, "Unknown method should use the method not found code")
+
+	_, err = c.DescribeMethod(ctx, "")
+	require.ErrorAs(t, err, &rpcErr, "Missing method should fail with a JSON-RPC error")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Missing method should use the invalid params code")
+}
+
+func TestSchemaValidationDisabled(t *testing.T) {
+	c, handler := startSchemaPair(t, WithSchemaValidation(false))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The schema is still listed, but requests are not held to it
+	desc, err := c.DescribeMethod(ctx, core.MethodProcessModel)
+	require.NoError(t, err, "Describing a registered method should succeed")
+	assert.NotNil(t, desc.ModelSchema, "Declared schema should be returned")
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["value"] = 500
+	_, err = c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should reach the handler")
+	assert.Equal(t, 1, handler.Calls(), "Handler should see the request")
+}
+
+func TestServerRejectsInvalidRequest(t *testing.T) {
+	c, handler := startSchemaPair(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Matching request should be processed")
+
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["value"] = 500
+	_, err = c.ProcessModel(ctx, req)
+
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Rejection should be a JSON-RPC error")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Rejection should use the invalid params code")
+	require.NotNil(t, rpcErr.Data, "Rejection should carry the validation errors")
+	var errs []tools.ValidationError
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &errs), "Validation errors should decode")
+	assert.Equal(t, []tools.ValidationError{{Field: "modelData.value", Message: "must be at most 100"}}, errs, "Violation should be reported")
+	assert.Equal(t, 1, handler.Calls(), "Invalid request should not reach the handler")
+
+	// Batch items are checked the same way
+	resp, err := c.ProcessBatch(ctx, &core.BatchRequest{Requests: []*core.ModelRequest{testutil.CreateTestModelRequest(), req}})
+	require.NoError(t, err, "Batch should be processed")
+	assert.True(t, resp.Responses[0].Success, "Matching item should succeed")
+	assert.False(t, resp.Responses[1].Success, "Invalid item should fail")
+	assert.Contains(t, resp.Responses[1].ErrorMessage, "modelData.value: must be at most 100", "Invalid item should report the violation")
+	assert.Equal(t, 2, handler.Calls(), "Invalid item should not reach the handler")
+}
+
+// ParamsModelHandler records the parameters of the requests it processes
+type ParamsModelHandler struct {
+	params chan core.Parameters
+}
+
+func (h *ParamsModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *ParamsModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	h.params <- req.Parameters
+	return core.NewModelResponse(req), nil
+}
+
+func TestParameterCoercion(t *testing.T) {
+	handler := &ParamsModelHandler{params: make(chan core.Parameters, 4)}
+	c, _ := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()), WithParameterCoercion(true))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	req.Parameters = core.Parameters{core.IntParam("max_tokens", 256), core.BytesParam("key", []byte("secret"))}
+	_, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "A request whose values fit their types should be processed")
+	params := <-handler.params
+	assert.Equal(t, int64(256), params[0].Value, "The handler should get the int as an int64")
+	assert.Equal(t, []byte("secret"), params[1].Value, "The handler should get the bytes decoded")
+
+	req = testutil.CreateTestModelRequest()
+	req.Parameters = core.Parameters{{Name: "max_tokens", Value: "many", Type: core.ParamInt}}
+	_, err = c.ProcessModel(ctx, req)
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Rejection should be a JSON-RPC error")
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Rejection should use the invalid params code")
+	require.NotNil(t, rpcErr.Data, "Rejection should carry the validation errors")
+	var errs []tools.ValidationError
+	require.NoError(t, json.Unmarshal(*rpcErr.Data, &errs), "Validation errors should decode")
+	assert.Equal(t, []tools.ValidationError{{Field: "parameters.max_tokens", Message: `must be of type int, got string "many"`}}, errs,
+		"The mismatch should be reported")
+	assert.Empty(t, handler.params, "A rejected request should not reach the handler")
+}
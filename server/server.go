@@ -5,9 +5,13 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"math"
 	"net"
+	"net/http"
+	"reflect"
 	"sync"
 	"time"
 
@@ -19,16 +23,63 @@ import (
 // routes requests to appropriate handlers. It manages the server lifecycle,
 // network listeners, and registered method handlers.
 type Server struct {
-	options   Options
-	status    core.Status
-	statusMu  sync.RWMutex
-	listeners []net.Listener
-	handlers  map[string]interface{}
-	callbacks []func(core.StatusChangeEvent)
+	options       Options
+	status        core.Status
+	statusMu      sync.RWMutex
+	listeners     []net.Listener
+	handlersMu    sync.RWMutex // Guards handlers, groups, groupsByName and resources
+	handlers      map[string]interface{}
+	resources     ResourceHandler
+	statusEvents  *core.StatusNotifier
+	authSchemes   map[string]AuthVerifier
+	admin         *http.Server
+	adminQ        *connQueue
+	metricsSrv    *http.Server
+	metricsLn     net.Listener
+	healthSrv     *http.Server
+	healthLn      net.Listener
+	startedAt     time.Time // When the server last started running; guarded by statusMu
+	conns         map[net.Conn]struct{}
+	sessions      map[*rpcHandler]struct{}
+	draining      bool
+	connsMu       sync.Mutex
+	rawListener   net.Listener
+	tasks         *core.TaskTracker
+	sinks         *core.SinkSet
+	metrics       core.MetricsCollector
+	notifications *core.NotificationRouter
+	pool          *requestPool
+	principals    *principalLimiter
+	groups        map[string]*HandlerGroup // Handler groups by method
+	groupsByName  map[string]*HandlerGroup
+	jobs          *jobManager
+	durable       *durableSubscriptions
+	inflight      inflightRequests // Model requests CancelRequest can reach
+	requests      requestTraces    // Calls InFlightRequests reports
+	tools         toolRegistry
+	uploads       blobUploads         // Blob uploads waiting for their next chunk
+	compression   *compressionTracker // Compression of messages to clients by method
 
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	stallCallbacks []func(StallEvent)
+	stalls         uint64
+
+	handlerTimeouts uint64 // Requests failed by withHandlerTimeout, accessed atomically
+	stuckHandlers   int64  // Handlers abandoned still running, accessed atomically
+
+	clientsMu           sync.Mutex // Guards connectCallbacks, disconnectCallbacks and sessionEndCallbacks
+	connectCallbacks    []func(core.ClientInfo)
+	disconnectCallbacks []func(core.ClientInfo, error)
+	sessionEndCallbacks []func(*core.Session)
+	nextClientID        uint64 // Accessed atomically
+
+	journal           *journal
+	recovered         []JournalEntry
+	recoveryCallbacks []func(JournalEntry)
+
+	lifecycleMu sync.Mutex              // Serializes Start and Stop
+	ctx         context.Context         // Context of the current run, made afresh by Start; guarded by statusMu
+	cancel      context.CancelCauseFunc // Cancels ctx; guarded by statusMu
+	wg          sync.WaitGroup
 }
 
 // New creates a new MCP server with the given options.
@@ -40,60 +91,243 @@ func New(options ...Option) *Server {
 		opt(&opts)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// Connections served with ServeConn before any Start run under this context
+	ctx, cancel := context.WithCancelCause(context.Background())
+	tasks := core.NewTaskTracker(opts.Logger, opts.TaskBudgets)
+	sinks := core.NewSinkSet(opts.Logger, tasks)
 
-	return &Server{
-		options:   opts,
-		status:    core.StatusStopped,
-		handlers:  make(map[string]interface{}),
-		callbacks: make([]func(core.StatusChangeEvent), 0),
-		ctx:       ctx,
-		cancel:    cancel,
+	s := &Server{
+		options:       opts,
+		status:        core.StatusStopped,
+		handlers:      make(map[string]interface{}),
+		groups:        make(map[string]*HandlerGroup),
+		groupsByName:  make(map[string]*HandlerGroup),
+		authSchemes:   make(map[string]AuthVerifier),
+		statusEvents:  core.NewStatusNotifier(opts.Logger, tasks),
+		conns:         make(map[net.Conn]struct{}),
+		sessions:      make(map[*rpcHandler]struct{}),
+		tasks:         tasks,
+		sinks:         sinks,
+		metrics:       sinks.Metrics(sinkMetrics, opts.Metrics),
+		notifications: core.NewNotificationRouter(tasks),
+		pool:          newRequestPool(opts.MaxConcurrentRequests, opts.RequestQueueSize),
+		principals:    newPrincipalLimiter(opts.PrincipalConcurrency, opts.PrincipalOverrides, opts.PrincipalReserve),
+		jobs:          newJobManager(tasks, opts.JobRetention, opts.MaxConcurrentJobs),
+		durable:       newDurableSubscriptions(opts.DurableBufferCount, opts.DurableBufferBytes, opts.DurableTTL),
+		compression:   newCompressionTracker(opts),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
+	s.jobs.publish = s.publishJobProgress
+	return s
 }
 
 // RegisterHandler registers a handler with the server for processing model requests.
 // It registers the handler for all methods it supports, checking for conflicts
-// with already registered handlers. Returns an error if a method is already registered.
+// with already registered handlers. Returns an error if a method is already registered,
+// in which case none of the handler's methods are registered. Handlers may be
+// registered while the server is running; connected clients are told the
+// methods changed.
 func (s *Server) RegisterHandler(handler Handler) error {
-	for _, method := range handler.Methods() {
+	return s.registerHandler(handler, nil)
+}
+
+// registerHandler registers handler for its methods, running them in group
+// if it is not nil.
+func (s *Server) registerHandler(handler Handler, group *HandlerGroup) error {
+	s.handlersMu.Lock()
+	methods := handler.Methods()
+	for _, method := range methods {
 		if _, exists := s.handlers[method]; exists {
+			s.handlersMu.Unlock()
 			return fmt.Errorf("handler for method %s already registered", method)
 		}
+	}
+	if group != nil {
+		if existing, ok := s.groupsByName[group.name]; ok && existing != group {
+			s.handlersMu.Unlock()
+			return fmt.Errorf("handler group %s already registered", group.name)
+		}
+		s.groupsByName[group.name] = group
+	}
+	for _, method := range methods {
 		s.handlers[method] = handler
+		if group != nil {
+			s.groups[method] = group
+		}
+	}
+	s.handlersMu.Unlock()
+
+	s.NotifyMethodsChanged()
+	return nil
+}
+
+// UnregisterHandler removes handler from every method it is registered for,
+// while the server is running or not. Requests already dispatched to it
+// complete; later ones get jsonrpc2.CodeMethodNotFound. It returns an error if
+// handler is not registered for any of its methods.
+func (s *Server) UnregisterHandler(handler Handler) error {
+	if !reflect.TypeOf(handler).Comparable() {
+		return fmt.Errorf("handler of type %T cannot be identified for removal; use UnregisterMethod", handler)
+	}
+	var methods []string
+	s.handlersMu.RLock()
+	for _, method := range handler.Methods() {
+		if registered, ok := s.handlers[method].(Handler); ok && registered == handler {
+			methods = append(methods, method)
+		}
+	}
+	s.handlersMu.RUnlock()
+	if len(methods) == 0 {
+		return fmt.Errorf("handler %T is not registered", handler)
 	}
+	s.unregister(methods...)
 	return nil
 }
 
+// UnregisterMethod removes the handler of method, as UnregisterHandler does
+// for all of a handler's methods. It returns an error if no handler is
+// registered for method.
+func (s *Server) UnregisterMethod(method string) error {
+	s.handlersMu.RLock()
+	_, ok := s.handlers[method]
+	s.handlersMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler registered for method %s", method)
+	}
+	s.unregister(method)
+	return nil
+}
+
+// unregister removes the handlers of methods, stopping the child process
+// of any Subprocess group left without methods, and tells connected clients.
+func (s *Server) unregister(methods ...string) {
+	s.handlersMu.Lock()
+	var emptied []*HandlerGroup
+	for _, method := range methods {
+		delete(s.handlers, method)
+		if group, ok := s.groups[method]; ok {
+			delete(s.groups, method)
+			if !s.groupInUseLocked(group) {
+				delete(s.groupsByName, group.name)
+				emptied = append(emptied, group)
+			}
+		}
+	}
+	s.handlersMu.Unlock()
+
+	for _, group := range emptied {
+		if group.process != nil {
+			group.process.close()
+		}
+	}
+	s.NotifyMethodsChanged()
+}
+
+// lookup returns the handler for method and the group it runs in. Batches
+// are served by the core.MethodProcessModel handler.
+func (s *Server) lookup(method string) (interface{}, *HandlerGroup, bool) {
+	if method == core.MethodProcessModelBatch {
+		method = core.MethodProcessModel
+	}
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	handler, ok := s.handlers[method]
+	return handler, s.groups[method], ok
+}
+
 // Start starts the server and begins listening for client connections.
 // It creates network listeners based on the configured options and handles
-// incoming client connections. Returns an error if the server is already
-// running or if it fails to set up the listeners.
+// incoming client connections. Returns an error if the options do not
+// validate, if the server is already running or if it fails to set up the
+// listeners.
 func (s *Server) Start() error {
+	if err := s.options.Validate(); err != nil {
+		return fmt.Errorf("invalid server options: %w", err)
+	}
+
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
 	s.statusMu.Lock()
 	if s.status != core.StatusStopped {
 		s.statusMu.Unlock()
 		return fmt.Errorf("cannot start server in %s state", s.status)
 	}
+	// Each run gets a context of its own, since Stop cancels the last one
+	s.ctx, s.cancel = context.WithCancelCause(context.Background())
 	s.updateStatusLocked(core.StatusStarting, nil)
 	s.statusMu.Unlock()
+	s.openGroups()
 
-	// Create TCP listener
-	addr := fmt.Sprintf("%s:%d", s.options.Host, s.options.Port)
-	listener, err := net.Listen("tcp", addr)
+	// Answer health checks from the start, so probes see the server starting
+	if s.options.HealthAddr != "" {
+		if err := s.serveHealthChecks(); err != nil {
+			s.updateStatus(core.StatusFailed, err)
+			return err
+		}
+	}
+
+	// Expose the metrics collector on its own HTTP listener
+	if s.options.MetricsAddr != "" {
+		if err := s.serveMetrics(); err != nil {
+			s.closeHealthChecks()
+			s.updateStatus(core.StatusFailed, err)
+			return err
+		}
+	}
+
+	// Report work a previous run accepted but never finished
+	if s.options.JournalDir != "" {
+		if err := s.openJournal(); err != nil {
+			s.closeMetrics()
+			s.closeHealthChecks()
+			s.updateStatus(core.StatusFailed, err)
+			return fmt.Errorf("failed to open journal: %w", err)
+		}
+	}
+
+	// Open a listener on each configured address
+	listeners, raw, err := s.openListeners()
 	if err != nil {
+		s.closeJournal()
+		s.closeMetrics()
+		s.closeHealthChecks()
 		s.updateStatus(core.StatusFailed, err)
-		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		return err
 	}
 
-	s.listeners = append(s.listeners, listener)
+	s.statusMu.Lock()
+	s.rawListener = raw
+	s.listeners = listeners
+	s.statusMu.Unlock()
+
+	// Serve HTTP requests arriving on the shared port
+	if s.options.AdminHandler != nil {
+		s.adminQ = newConnQueue(listeners[0].Addr())
+		s.admin = &http.Server{Handler: s.options.AdminHandler}
+		s.wg.Add(1)
+		s.tasks.Go(core.TaskAdmin, func() {
+			defer s.wg.Done()
+			s.admin.Serve(s.adminQ)
+		})
+	}
 
-	// Start accepting connections
-	s.wg.Add(1)
-	go s.acceptConnections(listener)
+	// Start accepting connections on every listener
+	for _, listener := range listeners {
+		listener := listener
+		s.wg.Add(1)
+		s.tasks.Go(core.TaskAccept, func() { s.acceptConnections(listener) })
+	}
 
-	s.updateStatus(core.StatusRunning, nil)
-	log.Printf("MCP server listening on %s", addr)
+	s.statusMu.Lock()
+	s.startedAt = time.Now()
+	s.updateStatusLocked(core.StatusRunning, nil)
+	s.statusMu.Unlock()
+	s.watchResources()
+	for i, addr := range s.listenAddrs() {
+		s.options.Logger.Info("MCP server listening", "addr", listeners[i].Addr().String(), "transport", fmt.Sprint(s.transportFor(addr)))
+	}
 
 	return nil
 }
@@ -104,70 +338,181 @@ func (s *Server) acceptConnections(listener net.Listener) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			// Check if we're shutting down
+			// Check if we're shutting down or draining
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			select {
-			case <-s.ctx.Done():
+			case <-s.runContext().Done():
 				return
 			default:
-				log.Printf("Error accepting connection: %v", err)
+				s.options.Logger.Error("Error accepting connection", core.LogFieldError, err)
 				continue
 			}
 		}
 
 		// Handle each connection in a goroutine
 		s.wg.Add(1)
-		go s.handleConnection(conn)
+		s.tasks.Go(core.TaskConnection, func() { s.handleConnection(conn) })
 	}
 }
 
 func (s *Server) handleConnection(conn net.Conn) {
 	defer s.wg.Done()
-	defer conn.Close()
 
-	log.Printf("Client connected from %s", conn.RemoteAddr())
+	// Track the raw connection so Stop can close it
+	s.trackConn(conn)
+	defer s.untrackConn(conn)
+
+	// Drop the connection if the peer goes silent or stops taking replies
+	if s.options.IdleTimeout > 0 || s.options.WriteTimeout > 0 {
+		conn = &deadlineConn{Conn: conn, readTimeout: s.options.IdleTimeout, writeTimeout: s.options.WriteTimeout}
+	}
+
+	// Hand HTTP requests on a shared port over to the admin handler
+	if s.adminQ != nil {
+		var isHTTP bool
+		if conn, isHTTP = sniffHTTP(conn); isHTTP {
+			s.adminQ.push(conn)
+			return
+		}
+	}
 
-	// Create JSON-RPC stream
-	stream := jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{})
+	s.options.Logger.Debug("Client connected", core.LogFieldRemoteAddr, conn.RemoteAddr().String())
 
-	// Create JSON-RPC handler
-	handler := &rpcHandler{server: s}
+	// Serve JSON-RPC until the client disconnects or the server stops
+	s.ServeConn(s.runContext(), conn)
+
+	s.options.Logger.Debug("Client disconnected", core.LogFieldRemoteAddr, conn.RemoteAddr().String())
+}
 
-	// Create JSON-RPC connection
-	rpcConn := jsonrpc2.NewConn(s.ctx, stream, handler)
+func (s *Server) trackConn(conn net.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	s.conns[conn] = struct{}{}
+}
 
-	// Wait for connection to close
-	<-rpcConn.DisconnectNotify()
+func (s *Server) untrackConn(conn net.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, conn)
+}
 
-	log.Printf("Client disconnected from %s", conn.RemoteAddr())
+// closeConns closes every connection still being served.
+func (s *Server) closeConns() {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
 }
 
-// Stop stops the server.
+// Stop stops the server. A server that failed to start, or is starting, is
+// stopped too, once Start returns; stopping a stopped server does nothing. A
+// stopped server can be started again.
 func (s *Server) Stop() error {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
 	s.statusMu.Lock()
-	if s.status != core.StatusRunning {
+	switch s.status {
+	case core.StatusStopped:
+		s.statusMu.Unlock()
+		return nil
+	case core.StatusFailed:
+		// Start rolled back whatever it had set up
+		s.cancel(core.CauseShutdown)
+		s.updateStatusLocked(core.StatusStopped, nil)
+		s.statusMu.Unlock()
+		return nil
+	case core.StatusRunning:
+	default:
 		s.statusMu.Unlock()
 		return fmt.Errorf("cannot stop server in %s state", s.status)
 	}
 	s.updateStatusLocked(core.StatusStopping, nil)
 	s.statusMu.Unlock()
 
-	// Cancel the context to signal shutdown
-	s.cancel()
+	// Let the child processes of isolated handler groups answer the
+	// requests they are handling before anything is cancelled
+	s.closeGroups()
+
+	// Cancel the context to signal shutdown, to requests in flight too
+	s.runCancel(core.CauseShutdown)
 
 	// Close all listeners
 	for _, listener := range s.listeners {
 		listener.Close()
 	}
 
+	// Close the shared-port HTTP server and its connections
+	if s.admin != nil {
+		s.adminQ.Close()
+		s.admin.Close()
+	}
+
+	// Close the metrics and health check listeners
+	s.closeMetrics()
+	s.closeHealthChecks()
+
+	// Disconnect clients that are still connected
+	s.closeConns()
+
 	// Wait for all goroutines to finish
 	s.wg.Wait()
 
+	// Requests still being processed stay unfinished in the journal
+	s.closeJournal()
+
+	// Uploads left unfinished cannot be carried on by the next run
+	s.uploads.abortAll()
+
+	// Forget the state of this run, so the next Start begins afresh
+	s.resetRun()
+
 	s.updateStatus(core.StatusStopped, nil)
-	log.Printf("MCP server stopped")
+	s.options.Logger.Info("MCP server stopped")
 
 	return nil
 }
 
+// resetRun clears what a run set up once Stop has torn it down.
+func (s *Server) resetRun() {
+	s.statusMu.Lock()
+	s.listeners = nil
+	s.rawListener = nil
+	s.statusMu.Unlock()
+
+	s.admin = nil
+	s.adminQ = nil
+
+	s.connsMu.Lock()
+	s.draining = false
+	s.connsMu.Unlock()
+}
+
+// runContext returns the context of the server's current run, which Stop
+// cancels.
+func (s *Server) runContext() context.Context {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.ctx
+}
+
+// runCancel cancels the context of the server's current run with cause.
+func (s *Server) runCancel(cause error) {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	s.cancel(cause)
+}
+
+// Options returns a copy of the options the server was created with, after
+// defaults and every Option were applied, for example to log the effective
+// configuration at startup. Changing the copy does not change the server.
+func (s *Server) Options() Options {
+	return s.options.clone()
+}
+
 // Status returns the current server status.
 func (s *Server) Status() core.Status {
 	s.statusMu.RLock()
@@ -175,9 +520,11 @@ func (s *Server) Status() core.Status {
 	return s.status
 }
 
-// OnStatusChange registers a callback for status changes.
-func (s *Server) OnStatusChange(callback func(core.StatusChangeEvent)) {
-	s.callbacks = append(s.callbacks, callback)
+// OnStatusChange registers a callback for status changes and returns a
+// function that removes it. Callbacks run one at a time, in registration
+// order, and see changes in the order they happened.
+func (s *Server) OnStatusChange(callback func(core.StatusChangeEvent)) (cancel func()) {
+	return s.statusEvents.Subscribe(callback)
 }
 
 func (s *Server) updateStatus(newStatus core.Status, err error) {
@@ -195,89 +542,541 @@ func (s *Server) updateStatusLocked(newStatus core.Status, err error) {
 		NewStatus: newStatus,
 		Timestamp: time.Now(),
 		Error:     err,
+		Source:    core.StatusSource{Kind: core.ComponentServer, Name: s.options.Name},
 	}
 
-	// Notify callbacks
-	for _, callback := range s.callbacks {
-		go callback(event)
-	}
+	s.statusEvents.Publish(event)
 }
 
-// rpcHandler implements jsonrpc2.Handler.
+// rpcHandler implements jsonrpc2.Handler. One is created per connection.
 type rpcHandler struct {
-	server *Server
+	server      *Server
+	id          string // Client ID reported by Clients; empty for a TestInvoker
+	remoteAddr  string
+	connectedAt time.Time
+	session     session
+	conn        rpcConn          // Set by addSession, under Server.connsMu
+	frames      *frameStream     // Instrumented read path; nil without stall detection, negotiation or a size limit
+	compression *connCompression // Compression accounting of the write path; nil when frames is
+	outbound    *outboundStream  // Queued write path; nil when senders write themselves
+	limiter     *connLimiter     // Request rate limits; nil when unlimited
+	fixedInfo   *ConnectionInfo  // Compression and codec a TestInvoker reports; nil for real connections
+
+	subscriptions subscriptions // Topics the client subscribed to
+	calls         callCancels   // Calls the client can cancel with core.MethodCancelRequest
+
+	// Ordered notifications wait for the reply to the request being handled
+	replyMu sync.Mutex
+	pending *pendingReply
+
+	// Draining closes the connection once no request is being handled
+	idleMu    sync.Mutex
+	active    int
+	idleClose bool
+	closer    io.Closer
+
+	// The session handlers see, expiring once idle for the session TTL
+	state         *core.Session // Nil until the next request; guarded by idleMu
+	lastActive    time.Time     // When the last request was answered; guarded by idleMu
+	sessionClosed bool          // Whether the connection has closed; guarded by idleMu
+
+	disconnectErr error // Reported to OnClientDisconnect callbacks; set by DisconnectClient, under idleMu
+}
+
+// rpcConn is the part of a client connection that requests are answered on.
+// *jsonrpc2.Conn implements it; a TestInvoker records what is sent instead.
+type rpcConn interface {
+	Reply(ctx context.Context, id jsonrpc2.ID, result interface{}) error
+	ReplyWithError(ctx context.Context, id jsonrpc2.ID, respErr *jsonrpc2.Error) error
+	SendResponse(ctx context.Context, resp *jsonrpc2.Response) error
+	Notify(ctx context.Context, method string, params interface{}, opts ...jsonrpc2.CallOption) error
+	DisconnectNotify() <-chan struct{}
 }
 
 // Handle handles JSON-RPC requests.
 func (h *rpcHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	// Find the appropriate handler
-	handler, ok := h.server.handlers[req.Method]
-	if !ok {
-		err := conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeMethodNotFound,
-			Message: fmt.Sprintf("method not found: %s", req.Method),
-		})
+	h.dispatch(ctx, conn, req)
+}
+
+// dispatch runs a request through the server's pipeline, answering it on conn.
+func (h *rpcHandler) dispatch(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	h.begin()
+	defer h.end()
+	if !req.Notif {
+		h.expectReply(req)
+	}
+
+	// The handshake sets the connection up, as negotiation does, so metrics
+	// and auditing never see it
+	if req.Method == core.MethodInitialize && !req.Notif {
+		h.handleInitialize(ctx, conn, req)
+		return
+	}
+
+	ctx = h.startRequest(ctx, req)
+	ctx = context.WithValue(ctx, connKey{}, conn)
+	ctx, cancel := withRequestCancel(ctx)
+	defer cancel(nil)
+
+	// Notifications from the client are dispatched without a reply
+	if req.Notif {
+		h.handleNotification(ctx, req)
+		return
+	}
+
+	// Record the call's progress for InFlightRequests
+	trace := h.server.requests.start(req, h.id)
+	defer h.server.requests.finish(trace)
+	ctx = context.WithValue(ctx, requestTraceKey{}, trace)
+
+	// Let the client give up on the call
+	defer h.calls.add(req.ID, cancel)()
+
+	// Notifications ordered after the reply are sent once Handle returns
+	ctx, pending := h.beginReply(ctx)
+	defer h.endReply(pending)
+
+	// A panic fails the call rather than the connection
+	defer func() {
+		if r := recover(); r != nil {
+			h.replyProcessError(ctx, conn, req, h.server.handlePanic(req.Method, r, core.LogFieldRemoteAddr, h.remoteAddr))
+		}
+	}()
+
+	// Keepalive probes are answered by the server itself
+	if req.Method == core.MethodPing {
+		h.reply(ctx, conn, req, core.PingResponse{Timestamp: time.Now()})
+		return
+	}
+
+	// A server with a minimum protocol version serves only clients that
+	// performed the handshake
+	caps := h.negotiated()
+	if minimum := h.server.options.MinProtocolVersion; caps == nil && minimum > 0 {
+		h.replyError(ctx, conn, req, core.CodeUnsupportedVersion,
+			fmt.Sprintf("initialize with protocol version %d or later first", minimum))
+		return
+	}
+	if caps != nil {
+		ctx = core.ContextWithCapabilities(ctx, caps)
+	}
+
+	// Refuse requests over the connection's rate limit, authentication included
+	if wait, ok := h.limiter.allow(req.Method, time.Now()); !ok {
+		h.replyRateLimited(ctx, conn, req, wait)
+		return
+	}
+
+	// Authentication establishes the connection's principal
+	if req.Method == core.MethodAuthenticate {
+		h.handleAuthenticate(ctx, conn, req)
+		return
+	}
+
+	// Everything else requires an authenticated session once auth is configured
+	if h.server.authRequired() {
+		principal, err := h.authorize(ctx)
 		if err != nil {
-			log.Printf("Error replying to client: %v", err)
+			h.replyError(ctx, conn, req, core.CodeUnauthenticated, err.Error())
+			return
+		}
+		ctx = core.ContextWithPrincipal(ctx, principal)
+	}
+	ctx = core.ContextWithPeer(ctx, h.peerInfo(caps))
+	if session := h.currentSession(); session != nil {
+		ctx = core.ContextWithSession(ctx, session)
+	}
+
+	// Check the caller may use the method before anything is dispatched
+	if err := h.server.permit(ctx, req.Method); err != nil {
+		h.server.options.Logger.Warn("Authorization denied",
+			core.LogFieldRemoteAddr, h.remoteAddr,
+			core.LogFieldMethod, req.Method,
+			core.LogFieldError, err)
+		h.replyError(ctx, conn, req, core.CodeUnauthorized, err.Error())
+		return
+	}
+
+	// Method descriptions and health are answered by the server itself
+	if req.Method == core.MethodListMethods {
+		h.reply(ctx, conn, req, core.ListMethodsResponse{Methods: h.server.describeMethods()})
+		return
+	}
+	if req.Method == core.MethodDescribeMethod {
+		h.handleDescribeMethod(ctx, conn, req)
+		return
+	}
+	if req.Method == core.MethodHealth {
+		h.reply(ctx, conn, req, h.server.healthResponse())
+		return
+	}
+
+	// Jobs, subscriptions, tools, resources and blobs are tracked by the
+	// server; only job handlers run off the connection
+	switch req.Method {
+	case core.MethodSubmitModel:
+		h.handleSubmitModel(ctx, conn, req)
+		return
+	case core.MethodJobStatus, core.MethodCancelJob:
+		h.handleJobRequest(ctx, conn, req)
+		return
+	case core.MethodSubscribe, core.MethodUnsubscribe:
+		h.handleSubscribe(ctx, conn, req)
+		return
+	case core.MethodListTools:
+		h.reply(ctx, conn, req, core.ListToolsResponse{Tools: h.server.Tools()})
+		return
+	case core.MethodCallTool:
+		h.handleCallTool(ctx, conn, req)
+		return
+	case core.MethodListResources, core.MethodReadResource:
+		h.handleResourceRequest(ctx, conn, req)
+		return
+	case core.MethodBlobPut, core.MethodBlobGet:
+		h.handleBlobRequest(ctx, conn, req)
+		return
+	}
+
+	// Optional parts of the protocol are refused unless both sides support them
+	if err := h.requireFeature(req.Method); err != nil {
+		h.replyError(ctx, conn, req, core.CodeUnsupportedCapability, err.Error())
+		return
+	}
+
+	// Resolve the handler once, so a request dispatched to it completes even if
+	// it is unregistered meanwhile. Batches fan out to the handler registered
+	// for single requests.
+	handler, group, ok := h.server.lookup(req.Method)
+	if !ok {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+		return
+	}
+	ctx = context.WithValue(ctx, groupKey{}, group)
+	trace.resolved(handler, group)
+	trace.mark(RequestValidated)
+
+	// Hold the principal to its share before it competes for a handler slot
+	if principal := core.PrincipalFromContext(ctx); principal != nil {
+		borrowed, usage, ok := h.server.principals.acquire(principal.ID, func() bool { return highPriority(req) })
+		if !ok {
+			h.replyPrincipalBusy(ctx, conn, req, principal.ID, usage)
+			return
+		}
+		defer h.server.principals.release(principal.ID, borrowed)
+	}
+
+	// Grouped methods are refused while their group is paused, and run in the
+	// group's own pool if it has one
+	pool := h.server.pool
+	if group != nil {
+		if err := group.admit(time.Now()); err != nil {
+			h.replyError(ctx, conn, req, core.CodeServerBusy, err.Error())
+			return
+		}
+		if group.pool != nil {
+			pool = group.pool
 		}
+	}
+
+	// Wait for a handler slot, or refuse the request if the queue is full too
+	trace.mark(RequestQueued)
+	if !pool.acquire(ctx) {
+		h.replyError(ctx, conn, req, core.CodeServerBusy, "server busy, too many requests in flight")
 		return
 	}
+	defer pool.release()
+	trace.mark(RequestDispatched)
+
+	if req.Method == core.MethodProcessModelBatch {
+		h.handleProcessModelBatch(h.journalRequest(ctx, req), conn, req, handler)
+		return
+	}
+
+	// Record the request so it can be recovered if we crash before replying
+	ctx = h.journalRequest(ctx, req)
 
 	// Handle the request based on the method
 	switch req.Method {
-	case "mcp.processModel":
+	case core.MethodProcessModel:
 		h.handleProcessModel(ctx, conn, req, handler)
+	case core.MethodProcessModelStream:
+		h.handleProcessModelStream(ctx, conn, req, handler)
 	default:
-		err := conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInvalidRequest,
-			Message: fmt.Sprintf("unknown method: %s", req.Method),
-		})
-		if err != nil {
-			log.Printf("Error replying to client: %v", err)
-		}
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidRequest, fmt.Sprintf("unknown method: %s", req.Method))
+	}
+}
+
+// begin marks a request as being handled on the connection.
+func (h *rpcHandler) begin() {
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+	h.active++
+}
+
+// end marks a request as answered, closing the connection if it is draining.
+func (h *rpcHandler) end() {
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+	h.active--
+	h.lastActive = time.Now()
+	if h.active == 0 && h.idleClose {
+		h.closer.Close()
 	}
 }
 
-func (h *rpcHandler) handleProcessModel(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, handler interface{}) {
+// closeWhenIdle closes the connection now if no request is being handled, or
+// once the current one has been answered.
+func (h *rpcHandler) closeWhenIdle() {
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+	h.idleClose = true
+	if h.active == 0 {
+		h.closer.Close()
+	}
+}
+
+// reply sends a JSON-RPC result, logging any failure to deliver it.
+func (h *rpcHandler) reply(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, result interface{}) {
+	// Encode up front so the metrics see the size of what is sent
+	payload, err := json.Marshal(result)
+	if err != nil {
+		h.logError("Error encoding reply", req, err)
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInternalError, "failed to encode result")
+		return
+	}
+	h.requestCompleted(ctx, req, true, len(payload))
+
+	// Send the encoding as is, where Reply would encode it again
+	traceFromContext(ctx).mark(RequestReplying)
+	encoded := json.RawMessage(payload)
+	if err := conn.SendResponse(ctx, &jsonrpc2.Response{ID: req.ID, Result: &encoded}); err != nil {
+		h.logError("Error replying to client", req, err)
+		return
+	}
+	h.completeJournal(ctx, req)
+}
+
+// replyError sends a JSON-RPC error reply, logging any failure to deliver it.
+func (h *rpcHandler) replyError(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, code int64, message string) {
+	h.replyRPCError(ctx, conn, req, &jsonrpc2.Error{
+		Code:    code,
+		Message: message,
+	})
+}
+
+// replyRPCError sends rpcErr, including any error data, as the reply to req.
+func (h *rpcHandler) replyRPCError(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, rpcErr *jsonrpc2.Error) {
+	payload, _ := json.Marshal(rpcErr)
+	h.requestCompleted(ctx, req, false, len(payload))
+
+	traceFromContext(ctx).mark(RequestReplying)
+	if err := conn.ReplyWithError(ctx, req.ID, rpcErr); err != nil {
+		h.logError("Error replying to client", req, err)
+		return
+	}
+	h.completeJournal(ctx, req)
+}
+
+// replyProcessError fails req with the error processing it returned: a
+// *jsonrpc2.Error, such as a validation failure, as-is, a ModelError as
+// CodeModelError with its code and details, anything else as an internal
+// error. Calls the client cancelled fail with CodeRequestCancelled whatever
+// the error.
+func (h *rpcHandler) replyProcessError(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, err error) {
+	if core.CancelCause(ctx) == core.CauseClientCancel {
+		h.replyError(ctx, conn, req, core.CodeRequestCancelled, "request cancelled by the client")
+		return
+	}
+	if rpcErr, ok := err.(*jsonrpc2.Error); ok {
+		h.replyRPCError(ctx, conn, req, rpcErr)
+		return
+	}
+	var modelErr *core.ModelError
+	if errors.As(err, &modelErr) {
+		h.replyRPCError(ctx, conn, req, modelErr.RPCError())
+		return
+	}
+	h.replyError(ctx, conn, req, jsonrpc2.CodeInternalError, err.Error())
+}
+
+// replyRateLimited refuses req with CodeRateLimited, telling the client how
+// long to wait before retrying.
+func (h *rpcHandler) replyRateLimited(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, wait time.Duration) {
+	h.server.options.Logger.Debug("Rate limit exceeded",
+		core.LogFieldRemoteAddr, h.remoteAddr,
+		core.LogFieldMethod, req.Method,
+		"retry_after", wait)
+
+	rpcErr := &jsonrpc2.Error{
+		Code:    core.CodeRateLimited,
+		Message: fmt.Sprintf("rate limit exceeded, retry after %s", wait.Round(time.Millisecond)),
+	}
+	rpcErr.SetError(core.RateLimitData{RetryAfterMillis: int64(math.Ceil(float64(wait) / float64(time.Millisecond)))})
+	h.replyRPCError(ctx, conn, req, rpcErr)
+}
+
+// replyPrincipalBusy refuses req with CodeServerBusy because its principal
+// has as many requests in flight as it may.
+func (h *rpcHandler) replyPrincipalBusy(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, principal string, usage PrincipalUsage) {
+	h.server.options.Logger.Debug("Principal concurrency limit reached",
+		core.LogFieldRemoteAddr, h.remoteAddr,
+		core.LogFieldMethod, req.Method,
+		"principal", principal)
+
+	rpcErr := &jsonrpc2.Error{
+		Code:    core.CodeServerBusy,
+		Message: fmt.Sprintf("server busy, principal %s has %d of %d requests in flight", principal, usage.InFlight, usage.Limit),
+	}
+	rpcErr.SetError(core.PrincipalBusyData{Principal: principal, InFlight: usage.InFlight, Limit: usage.Limit})
+	h.replyRPCError(ctx, conn, req, rpcErr)
+}
+
+// logTooLarge logs a request refused for exceeding the size limit.
+func (h *rpcHandler) logTooLarge(tooLarge *core.FrameTooLargeError) {
+	h.server.options.Logger.Warn("Request too large",
+		core.LogFieldRemoteAddr, h.remoteAddr,
+		"size", tooLarge.Size,
+		"limit", tooLarge.Limit)
+}
+
+// logError logs an error concerning req with the connection and request fields.
+func (h *rpcHandler) logError(msg string, req *jsonrpc2.Request, err error, args ...any) {
+	fields := []any{
+		core.LogFieldRemoteAddr, h.remoteAddr,
+		core.LogFieldMethod, req.Method,
+		core.LogFieldRequestID, req.ID.String(),
+		core.LogFieldError, err,
+	}
+	h.server.options.Logger.Error(msg, append(fields, args...)...)
+}
+
+func (h *rpcHandler) handleProcessModel(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, handler interface{}) {
+	// Handlers that pass the model data on get it undecoded
+	if rawHandler, ok := rawHandler(ctx, handler); ok {
+		h.handleProcessModelRaw(ctx, conn, req, rawHandler)
+		return
+	}
 	modelHandler, ok := handler.(ModelHandler)
 	if !ok {
-		err := conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInternalError,
-			Message: "handler is not a ModelHandler",
-		})
-		if err != nil {
-			log.Printf("Error replying to client: %v", err)
-		}
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInternalError, "handler is not a ModelHandler")
 		return
 	}
 	// Parse the request
-	var modelReq core.ModelRequest
-	if err := json.Unmarshal(*req.Params, &modelReq); err != nil {
-		err := conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInvalidParams,
-			Message: fmt.Sprintf("invalid params: %v", err),
-		})
-		if err != nil {
-			log.Printf("Error replying to client: %v", err)
-		}
+	modelReq, rpcErr := decodeModelRequest(req)
+	if rpcErr != nil {
+		h.replyRPCError(ctx, conn, req, rpcErr)
 		return
 	}
+	traceFromContext(ctx).identify(modelReq.ID)
 
-	// Process the request
-	resp, err := modelHandler.ProcessModel(ctx, &modelReq)
+	// Validate and process the request inside the middleware
+	process := h.server.withMiddleware(req.Method, func(ctx context.Context, modelReq *core.ModelRequest) (*core.ModelResponse, error) {
+		return h.server.processModel(ctx, req.Method, modelHandler, modelReq, modelHandler.ProcessModel)
+	})
+	resp, err := withHandlerTimeout(h.server, ctx, req.Method, modelReq.ID, func(ctx context.Context) (*core.ModelResponse, error) {
+		return process(ctx, modelReq)
+	})
 	if err != nil {
-		err := conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInternalError,
-			Message: fmt.Sprintf("processing error: %v", err),
-		})
-		if err != nil {
-			log.Printf("Error replying to client: %v", err)
-		}
+		h.replyProcessError(ctx, conn, req, err)
 		return
 	}
 
 	// Send the response
-	if err := conn.Reply(ctx, req.ID, resp); err != nil {
-		log.Printf("Error replying to client: %v", err)
+	h.reply(ctx, conn, req, resp)
+}
+
+// decodeModelRequest decodes the model request carried by req.
+func decodeModelRequest(req *jsonrpc2.Request) (*core.ModelRequest, *jsonrpc2.Error) {
+	if req.Params == nil {
+		return nil, invalidModelRequest(nil, errors.New("missing model request"))
+	}
+	var modelReq core.ModelRequest
+	if err := json.Unmarshal(*req.Params, &modelReq); err != nil {
+		return nil, invalidModelRequest(req.Params, err)
+	}
+	return &modelReq, nil
+}
+
+// invalidModelRequest returns the CodeInvalidParams error replying to a model
+// request that failed to decode with err. Its data is a failed
+// core.ModelResponse with code core.ErrInvalidModel and the ID of the request,
+// if params has one, which clients read with core.ModelResponseFromRPC.
+func invalidModelRequest(params *json.RawMessage, err error) *jsonrpc2.Error {
+	var partial struct {
+		ID string `json:"id"`
+	}
+	if params != nil {
+		_ = json.Unmarshal(*params, &partial)
+	}
+	resp := core.ErrorResponseWithCode(&core.ModelRequest{ID: partial.ID}, core.ErrInvalidModel, err)
+	rpcErr := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	rpcErr.SetError(resp)
+	return rpcErr
+}
+
+// processingError is the error processModel fails with when the handler
+// does. Batch items carry the handler's error alone.
+type processingError struct {
+	err error
+}
+
+func (e *processingError) Error() string { return "processing error: " + e.err.Error() }
+
+func (e *processingError) Unwrap() error { return e.err }
+
+// processModel runs process, a method of handler, for req with the request's
+// metadata, inside a span, with randomness and time pinned when recording or
+// replaying. The response is checked, has metadata echoed into it and is
+// recorded.
+func (s *Server) processModel(ctx context.Context, method string, handler Handler, req *core.ModelRequest, process func(context.Context, *core.ModelRequest) (*core.ModelResponse, error)) (*core.ModelResponse, error) {
+	// Let CancelRequest reach the handler
+	defer s.trackRequest(ctx, req.ID)()
+
+	// Expose request metadata to the handler and continue the caller's trace
+	ctx = core.ContextWithMetadata(ctx, req.Metadata)
+	ctx, endSpan := s.options.Tracer.StartSpan(ctx, core.SpanServer, method, req.ID)
+
+	// Stop the handler once the caller has given up waiting
+	if timeout, ok := core.TimeoutFromMetadata(req.Metadata); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, core.CauseDeadline)
+		defer cancel()
+	}
+
+	// Give the handler the blobs the request refers to
+	ctx, err := s.withBlobs(ctx, req.BlobRefs)
+	if err != nil {
+		endSpan(err)
+		return nil, err
+	}
+
+	// Pin randomness and time when recording or replaying
+	ctx = s.determinismContext(ctx, req)
+
+	// Process the request, in the method's handler group if it has one
+	traceFromContext(ctx).mark(RequestHandlerRunning)
+	resp, err := s.inGroup(ctx, method, process)(ctx, req)
+	if err == nil && resp != nil {
+		endSpan(resp.Err())
+	} else {
+		endSpan(err)
+	}
+	if err != nil {
+		return nil, &processingError{err}
+	}
+
+	// Catch incoherent responses before they confuse the client
+	if err := s.checkResponse(method, handler, req, resp); err != nil {
+		return nil, err
+	}
+
+	s.echoMetadata(ctx, resp)
+
+	if recorder := s.options.Recorder; recorder != nil {
+		s.sinks.Do(sinkRecorder, func() error {
+			recorder.Record(req, resp)
+			return nil
+		})
 	}
+	return resp, nil
 }
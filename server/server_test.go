@@ -2,39 +2,72 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/narcolepticfox/mcp/client"
 	"github.com/narcolepticfox/mcp/core"
 	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestServerLifecycle(t *testing.T) {
-	// Get a free port for testing
-	port, err := testutil.GetFreePort()
-	require.NoError(t, err, "Failed to get free port")
+// startServerWithHandler starts a server with the given handler and a client
+// connected to it over an in-process transport
+func startServerWithHandler(t *testing.T, handler Handler, options ...Option) (*Server, *client.Client) {
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(append([]Option{WithTransport(transport)}, options...)...)
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			return srv
+		},
+	)
+	return srv, c
+}
 
-	// Create a server
-	srv := New(WithPort(port))
+func TestServerLifecycle(t *testing.T) {
+	// Create a server on an ephemeral port
+	srv := New(WithPort(0))
 
 	// Verify initial state
 	assert.Equal(t, core.StatusStopped, srv.Status(), "Server should start in stopped state")
 
 	// Register for status change events
+	var eventsMu sync.Mutex
 	var statusEvents []core.StatusChangeEvent
 	srv.OnStatusChange(func(event core.StatusChangeEvent) {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
 		statusEvents = append(statusEvents, event)
 	})
+	eventCount := func() int {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		return len(statusEvents)
+	}
+
+	assert.Nil(t, srv.Addr(), "Stopped server should have no address")
 
 	// Start the server
-	err = srv.Start()
+	err := srv.Start()
 	assert.NoError(t, err, "Start should succeed")
 
-	// Server should be in running state
+	// Server should be in running state, on the port it bound
 	assert.Equal(t, core.StatusRunning, srv.Status(), "Server should be in running state after start")
+	assert.NotZero(t, srv.Port(), "Server should report the port it bound")
+	assert.Equal(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(srv.Port())), srv.Addr().String(), "Address should carry the bound port")
 
 	// Stop the server
 	err = srv.Stop()
@@ -44,7 +77,62 @@ func TestServerLifecycle(t *testing.T) {
 	assert.Equal(t, core.StatusStopped, srv.Status(), "Server should return to stopped state after stop")
 
 	// Check that at least two status events were recorded (idle->running, running->idle)
-	assert.GreaterOrEqual(t, len(statusEvents), 2, "At least two status events should have been emitted")
+	assert.Eventually(t, func() bool { return eventCount() >= 2 }, time.Second, 10*time.Millisecond, "At least two status events should have been emitted")
+}
+
+func TestInProcessListener(t *testing.T) {
+	// A server and client in the same process talk without opening a socket
+	pipe := NewInProcessListener()
+	srv := New(WithTransport(pipe), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	defer srv.Stop()
+	assert.Equal(t, "inprocess", srv.Addr().String(), "Server should listen on the pipe")
+
+	c := client.New(client.WithInProcessConn(pipe), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Client should connect over the pipe")
+	defer c.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request should be answered over the pipe")
+	assert.True(t, resp.Success, "Request should succeed")
+}
+
+func TestStatusChangeOrder(t *testing.T) {
+	srv := New(WithTransport(core.NewInProcessTransport()), WithLogger(core.NopLogger()))
+
+	var mu sync.Mutex
+	var statuses []core.Status
+	srv.OnStatusChange(func(event core.StatusChangeEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses = append(statuses, event.NewStatus)
+	})
+
+	// Subscribers coming and going while the status changes must not race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			cancel := srv.OnStatusChange(func(core.StatusChangeEvent) {})
+			cancel()
+		}
+	}()
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	require.NoError(t, srv.Stop(), "Server should stop successfully")
+	<-done
+
+	want := []core.Status{core.StatusStarting, core.StatusRunning, core.StatusStopping, core.StatusStopped}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(statuses) == len(want)
+	}, time.Second, 10*time.Millisecond, "Every status change should be delivered")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, want, statuses, "Status changes should be delivered in order")
 }
 
 func TestHandlerRegistration(t *testing.T) {
@@ -68,32 +156,96 @@ func TestHandlerRegistration(t *testing.T) {
 	assert.Error(t, err, "Registering a duplicate method should fail")
 }
 
-func TestServerWithClient(t *testing.T) {
-	// Get a free port for testing
-	port, err := testutil.GetFreePort()
-	require.NoError(t, err, "Failed to get free port")
+func TestHandlerRegistrationIsAtomic(t *testing.T) {
+	srv := New()
+	require.NoError(t, srv.RegisterHandler(&MockModelHandler{methods: []string{core.MethodProcessModel}}), "Handler registration should succeed")
 
-	// Create a server
-	srv := New(WithPort(port))
+	conflicting := &MockModelHandler{methods: []string{core.MethodProcessModelStream, core.MethodProcessModel}}
+	assert.Error(t, srv.RegisterHandler(conflicting), "Registering a duplicate method should fail")
+	assert.Error(t, srv.UnregisterMethod(core.MethodProcessModelStream), "Failed registration should register none of the methods")
+	assert.Error(t, srv.UnregisterHandler(conflicting), "Unregistered handler should not be removed")
+}
 
-	// Register a handler
-	handler := NewDefaultModelHandler()
-	err = srv.RegisterHandler(handler)
-	require.NoError(t, err, "Handler registration should succeed")
+func TestUnregisterWhileInFlight(t *testing.T) {
+	handler := newGatedModelHandler()
+	srv, clients := startPooledServer(t, handler, 2)
 
-	// Start the server
-	err = srv.Start()
-	require.NoError(t, err, "Server should start successfully")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
-	// Create a client that connects to our server
-	c := client.New(
-		client.WithServerPort(port),
-		client.WithConnectionTimeout(2*time.Second),
-	)
+	inFlight := processAsync(ctx, clients[0])
+	<-handler.started
+	require.NoError(t, srv.UnregisterHandler(handler), "Registered handler should be removed")
+
+	// The dispatched request completes; later ones find no handler
+	_, err := clients[1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Request after removal should be refused")
+	assert.Equal(t, int64(jsonrpc2.CodeMethodNotFound), rpcErr.Code, "Removed method should not be found")
+	close(handler.release)
+	assert.NoError(t, <-inFlight, "Request dispatched before removal should complete")
+
+	assert.Error(t, srv.UnregisterMethod(core.MethodProcessModel), "Removed method should not be removed twice")
+	require.NoError(t, srv.RegisterHandler(handler), "Handler should be registered again while running")
+	_, err = clients[1].ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Registered method should be served again")
+}
 
-	// Start the client
-	err = c.Start()
-	require.NoError(t, err, "Client should connect to server")
+func TestRegisterWhileRunning(t *testing.T) {
+	srv, clients := startPooledServer(t, NewDefaultModelHandler(), 4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Register and remove the streaming handler while clients hammer both methods
+	done := make(chan struct{})
+	toggled := make(chan int)
+	go func() {
+		count := 0
+		for {
+			select {
+			case <-done:
+				toggled <- count
+				return
+			default:
+			}
+			stream := &CountingStreamHandler{count: 1}
+			if srv.RegisterHandler(stream) == nil {
+				srv.UnregisterHandler(stream)
+				count++
+			}
+		}
+	}()
+
+	errs := make(chan error, len(clients))
+	for _, c := range clients {
+		c := c
+		go func() {
+			for i := 0; i < 50; i++ {
+				if _, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest()); err != nil {
+					errs <- err
+					return
+				}
+				_, err := c.ProcessModelStream(ctx, testutil.CreateTestModelRequest(), func(core.ModelChunk) {})
+				var rpcErr *jsonrpc2.Error
+				if err != nil && !(errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound) {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for range clients {
+		assert.NoError(t, <-errs, "Requests should either be served or find no method")
+	}
+	close(done)
+	assert.Positive(t, <-toggled, "Handler should have been registered and removed while serving")
+}
+
+func TestServerWithClient(t *testing.T) {
+	// Start a server with the default handler and a client connected in-process
+	_, c := startServerWithHandler(t, NewDefaultModelHandler())
 
 	// Wait for the client to fully connect
 	assert.True(t, testutil.WaitForCondition(2*time.Second, 100*time.Millisecond, func() bool {
@@ -115,23 +267,10 @@ func TestServerWithClient(t *testing.T) {
 	assert.Equal(t, req.ID, resp.ID, "Response ID should match request ID")
 	assert.True(t, resp.Success, "Response should indicate success")
 	assert.Equal(t, "processed", resp.Results["status"], "Status should be set to 'processed'")
-
-	err = c.Stop()
-	require.NoError(t, err, "Client should stop successfully")
-
-	err = srv.Stop()
-	require.NoError(t, err, "Server should stop successfully")
 }
 
 func TestServerRejectedRequest(t *testing.T) {
-	// Get a free port for testing
-	port, err := testutil.GetFreePort()
-	require.NoError(t, err, "Failed to get free port")
-
-	// Create a server
-	srv := New(WithPort(port))
-
-	// Register a custom handler that rejects requests
+	// Start a server with a custom handler that rejects requests
 	handler := &MockModelHandler{
 		methods: []string{"mcp.processModel"},
 		processResponse: &core.ModelResponse{
@@ -140,22 +279,7 @@ func TestServerRejectedRequest(t *testing.T) {
 			Results:      map[string]interface{}{},
 		},
 	}
-	err = srv.RegisterHandler(handler)
-	require.NoError(t, err, "Handler registration should succeed")
-
-	// Start the server
-	err = srv.Start()
-	require.NoError(t, err, "Server should start successfully")
-
-	// Create a client that connects to our server
-	c := client.New(
-		client.WithServerPort(port),
-		client.WithConnectionTimeout(2*time.Second),
-	)
-
-	// Start the client
-	err = c.Start()
-	require.NoError(t, err, "Client should connect to server")
+	_, c := startServerWithHandler(t, handler)
 
 	// Create a request
 	req := testutil.CreateTestModelRequest()
@@ -171,40 +295,46 @@ func TestServerRejectedRequest(t *testing.T) {
 	// Verify the response reflects the rejection
 	assert.False(t, resp.Success, "Response should indicate failure")
 	assert.Equal(t, "rejected request", resp.ErrorMessage, "Error message should be set")
-
-	err = c.Stop()
-	require.NoError(t, err, "Client should stop successfully")
-
-	err = srv.Stop()
-	require.NoError(t, err, "Server should stop successfully")
 }
 
-func TestServerRequestTimeout(t *testing.T) {
-	// Get a free port for testing
-	port, err := testutil.GetFreePort()
-	require.NoError(t, err, "Failed to get free port")
-
-	// Create a server with the specific port
-	srv := New(WithPort(port))
-
-	// Register a handler that sleeps for a period
-	handler := &SlowModelHandler{delay: 500 * time.Millisecond}
-	err = srv.RegisterHandler(handler)
-	require.NoError(t, err, "Handler registration should succeed")
+func TestServerModelError(t *testing.T) {
+	modelErr := &core.ModelError{
+		Code:    core.ErrNotFound,
+		Message: "model sentiment-v9 not found",
+		Details: map[string]interface{}{"model": "sentiment-v9"},
+	}
+	handler := &MockModelHandler{
+		methods:      []string{"mcp.processModel"},
+		processError: fmt.Errorf("loading model: %w", modelErr),
+	}
+	_, c := startServerWithHandler(t, handler)
 
-	// Start the server
-	err = srv.Start()
-	require.NoError(t, err, "Server should start successfully")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
-	// Create a client that connects to our server with the correct port
-	c := client.New(
-		client.WithServerPort(port),
-		client.WithConnectionTimeout(2*time.Second),
-	)
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	var got *core.ModelError
+	require.True(t, errors.As(err, &got), "Typed error should survive the round trip")
+	assert.Equal(t, core.ErrNotFound, got.Code, "Code should be carried")
+	assert.Equal(t, modelErr.Message, got.Message, "Message should be carried")
+	assert.Equal(t, modelErr.Details, got.Details, "Details should be carried")
+
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "Underlying JSON-RPC error should still be reachable")
+	assert.Equal(t, core.CodeModelError, rpcErr.Code, "Typed errors should use CodeModelError")
+
+	// Batch items carry the code in their response
+	resp, err := c.ProcessBatch(ctx, &core.BatchRequest{Requests: []*core.ModelRequest{testutil.CreateTestModelRequest()}})
+	require.NoError(t, err, "Batch should be processed")
+	assert.Equal(t, core.ErrNotFound, resp.Responses[0].ErrorCode, "Failed item should carry the code")
+	assert.Equal(t, modelErr.Details, resp.Responses[0].Details, "Failed item should carry the details")
+	require.True(t, errors.As(resp.Responses[0].Err(), &got), "Failed item should report a typed error")
+	assert.Equal(t, core.ErrNotFound, got.Code, "Failed item's error should carry the code")
+}
 
-	// Start the client
-	err = c.Start()
-	require.NoError(t, err, "Client should connect to server")
+func TestServerRequestTimeout(t *testing.T) {
+	// Start a server with a handler that sleeps for a period
+	_, c := startServerWithHandler(t, &SlowModelHandler{delay: 500 * time.Millisecond})
 
 	// Create a request
 	req := testutil.CreateTestModelRequest()
@@ -224,12 +354,6 @@ func TestServerRequestTimeout(t *testing.T) {
 	resp2, err2 := c.ProcessModel(ctx2, req)
 	assert.NoError(t, err2, "ProcessModel should succeed with sufficient timeout")
 	assert.NotNil(t, resp2, "Response should not be nil")
-
-	err = c.Stop()
-	require.NoError(t, err, "Client should stop successfully")
-
-	err = srv.Stop()
-	require.NoError(t, err, "Server should stop successfully")
 }
 
 // SlowModelHandler implements a handler that sleeps before responding
@@ -251,3 +375,332 @@ func (h *SlowModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequ
 		return resp, nil
 	}
 }
+
+// DeadlineModelHandler reports when its context ends and why, or answers
+// after a long delay if it never does
+type DeadlineModelHandler struct {
+	stopped chan error
+}
+
+func (h *DeadlineModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *DeadlineModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	select {
+	case <-ctx.Done():
+		h.stopped <- context.Cause(ctx)
+		return nil, ctx.Err()
+	case <-time.After(5 * time.Second):
+		return core.NewModelResponse(req), nil
+	}
+}
+
+func TestServerHandlerDeadline(t *testing.T) {
+	handler := &DeadlineModelHandler{stopped: make(chan error, 1)}
+	_, c := startServerWithHandler(t, handler)
+
+	// The handler's context ends close to when the client gives up
+	timeout := 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.Error(t, err, "ProcessModel should fail when the client times out")
+
+	cause := receive(t, handler.stopped, "handler to stop")
+	elapsed := time.Since(start)
+	// The client's cancellation may arrive before the deadline passes
+	assert.Contains(t, []error{core.CauseDeadline, core.CauseClientCancel}, cause, "Handler should be stopped because the caller gave up")
+	assert.GreaterOrEqual(t, elapsed, timeout-20*time.Millisecond, "Handler should not be stopped before the caller gives up")
+	assert.Less(t, elapsed, timeout+150*time.Millisecond, "Handler should be stopped soon after the caller gives up")
+}
+
+func TestServerRestartAfterFailedStart(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Probe listener should bind")
+	addr := taken.Addr().String()
+
+	// The server fails to start on a taken address
+	srv := New(WithListenAddrs(addr), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	require.Error(t, srv.Start(), "Start should fail on a taken address")
+	require.Equal(t, core.StatusFailed, srv.Status(), "Server should be in failed state after failed start")
+	assert.Error(t, srv.Start(), "Failed server should not start until stopped")
+
+	// A failed server can be stopped, and stopping it again does nothing
+	require.NoError(t, srv.Stop(), "Failed server should stop")
+	assert.Equal(t, core.StatusStopped, srv.Status(), "Server should be stopped")
+	assert.NoError(t, srv.Stop(), "Stopping a stopped server should succeed")
+
+	// Once the address is free the same server starts and serves requests
+	require.NoError(t, taken.Close(), "Probe listener should close")
+	require.NoError(t, srv.Start(), "Stopped server should start again")
+	defer srv.Stop()
+
+	c := client.New(client.WithServerAddr(srv.Addr().String()), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Client should connect to the restarted server")
+	defer c.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "Restarted server should serve requests")
+}
+
+func TestServerRestart(t *testing.T) {
+	srv := New(WithPort(0), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	var eventsMu sync.Mutex
+	var statuses []core.Status
+	srv.OnStatusChange(func(event core.StatusChangeEvent) {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		statuses = append(statuses, event.NewStatus)
+	})
+
+	// Each run serves requests on a listener of its own
+	for run := 1; run <= 2; run++ {
+		require.NoError(t, srv.Start(), "Run %d should start", run)
+		c := client.New(client.WithServerAddr(srv.Addr().String()), client.WithAutoReconnect(false))
+		require.NoError(t, c.Start(), "Client should connect in run %d", run)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		cancel()
+		assert.NoError(t, err, "Run %d should serve requests", run)
+		require.NoError(t, c.Stop(), "Client should stop")
+		require.NoError(t, srv.Stop(), "Run %d should stop", run)
+		assert.Nil(t, srv.Addrs(), "Stopped server should have no listeners")
+	}
+
+	want := []core.Status{
+		core.StatusStarting, core.StatusRunning, core.StatusStopping, core.StatusStopped,
+		core.StatusStarting, core.StatusRunning, core.StatusStopping, core.StatusStopped,
+	}
+	assert.Eventually(t, func() bool {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		return assert.ObjectsAreEqual(want, statuses)
+	}, time.Second, 10*time.Millisecond, "Each run should report starting, running, stopping and stopped in order")
+}
+
+func TestServerRestartAfterDrain(t *testing.T) {
+	srv := New(WithPort(0), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Drain(ctx), "Server should drain")
+
+	// Connections to the next run are not closed as if it were still draining
+	require.NoError(t, srv.Start(), "Drained server should start again")
+	defer srv.Stop()
+	c := client.New(client.WithServerAddr(srv.Addr().String()), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+	for i := 0; i < 2; i++ {
+		_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "Request %d should be served", i)
+	}
+	assert.True(t, c.IsConnected(), "Connection should stay open after its requests")
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	for name, option := range map[string]Option{
+		"idle timeout": WithIdleTimeout(200 * time.Millisecond),
+		"read timeout": WithReadTimeout(200 * time.Millisecond),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Create a server that drops silent connections quickly
+			srv := New(WithPort(0), option)
+			err := srv.Start()
+			require.NoError(t, err, "Server should start successfully")
+			defer srv.Stop()
+
+			// A raw connection that never sends anything should be closed by the server
+			conn, err := net.Dial("tcp", srv.Addr().String())
+			require.NoError(t, err, "Raw connection should succeed")
+			defer conn.Close()
+
+			start := time.Now()
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)), "Read deadline should be set")
+			_, err = conn.Read(make([]byte, 1))
+			assert.Equal(t, io.EOF, err, "Server should close the idle connection")
+			assert.Less(t, time.Since(start), time.Second, "Idle connection should be dropped within the timeout window")
+		})
+	}
+}
+
+func TestServerWriteTimeout(t *testing.T) {
+	// Create a server that drops connections that stop taking replies
+	handler := &HugeModelHandler{started: make(chan string, 1)}
+	srv := New(WithPort(0), WithLogger(core.NopLogger()), WithWriteTimeout(200*time.Millisecond))
+	require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	defer srv.Stop()
+
+	// A raw connection asks for a reply larger than the socket buffers and
+	// never reads it
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err, "Raw connection should succeed")
+	defer conn.Close()
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["huge"] = true
+	call := &jsonrpc2.Request{Method: core.MethodProcessModel, ID: jsonrpc2.ID{Num: 1}}
+	require.NoError(t, call.SetParams(req), "Params should encode")
+	require.NoError(t, jsonrpc2.VSCodeObjectCodec{}.WriteObject(conn, call), "Request should be sent")
+
+	receive(t, handler.started, "huge request")
+	start := time.Now()
+	require.Eventually(t, func() bool {
+		return len(srv.Connections()) == 0
+	}, 2*time.Second, 10*time.Millisecond, "Server should drop the connection that stopped reading")
+	assert.Less(t, time.Since(start), time.Second, "Connection should be dropped within the timeout window")
+}
+
+func TestServerHeartbeatKeepsConnectionAlive(t *testing.T) {
+	// Create a server with an idle timeout shorter than the test duration
+	srv := New(WithPort(0), WithIdleTimeout(200*time.Millisecond))
+	err := srv.RegisterHandler(NewDefaultModelHandler())
+	require.NoError(t, err, "Handler registration should succeed")
+	err = srv.Start()
+	require.NoError(t, err, "Server should start successfully")
+
+	// Heartbeats count as traffic, so the connection must survive
+	c := client.New(
+		client.WithServerAddr(srv.Addr().String()),
+		client.WithAutoReconnect(false),
+		client.WithHeartbeatInterval(50*time.Millisecond),
+	)
+	err = c.Start()
+	require.NoError(t, err, "Client should connect to server")
+
+	time.Sleep(600 * time.Millisecond)
+	assert.True(t, c.IsConnected(), "Heartbeats should keep the connection from idling out")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "ProcessModel should succeed on the kept-alive connection")
+	assert.NotNil(t, resp, "Response should not be nil")
+
+	err = c.Stop()
+	require.NoError(t, err, "Client should stop successfully")
+
+	err = srv.Stop()
+	require.NoError(t, err, "Server should stop successfully")
+}
+
+func TestStatusAggregator(t *testing.T) {
+	srv := New(WithName("api"), WithPort(0), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	defer srv.Stop()
+	c := client.New(client.WithName("worker"), client.WithServerAddr(srv.Addr().String()),
+		client.WithAutoReconnect(false), client.WithLogger(core.NopLogger()))
+
+	aggregator := core.NewStatusAggregator()
+	defer aggregator.Close()
+	aggregator.Add(srv)
+	aggregator.Add(c)
+	assert.False(t, aggregator.AllRunning(), "Aggregator should report the client that has not started")
+
+	require.NoError(t, c.Start(), "Client should connect to server")
+	defer c.Stop()
+	assert.True(t, aggregator.AllRunning(), "Aggregator should report both components running")
+
+	// Stopping the server degrades the group, and its event names it
+	require.NoError(t, srv.Stop(), "Server should stop successfully")
+	assert.False(t, aggregator.AllRunning(), "Aggregator should report the stopped server")
+
+	api := core.StatusSource{Kind: core.ComponentServer, Name: "api"}
+	sources := make(map[core.StatusSource]bool)
+	timeout := time.After(2 * time.Second)
+	for stopped := false; !stopped; {
+		select {
+		case event := <-aggregator.Events():
+			sources[event.Source] = true
+			stopped = event.Source == api && event.NewStatus == core.StatusStopped
+		case <-timeout:
+			t.Fatalf("Server stop should be reported, got events from %v", sources)
+		}
+	}
+	assert.True(t, sources[core.StatusSource{Kind: core.ComponentClient, Name: "worker"}], "Client events should name the client")
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix domain sockets are not supported on this platform")
+	}
+
+	path := filepath.Join(t.TempDir(), "mcp.sock")
+
+	// Create a server that listens on a unix socket
+	srv := New(WithUnixSocket(path))
+	err := srv.RegisterHandler(NewDefaultModelHandler())
+	require.NoError(t, err, "Handler registration should succeed")
+
+	err = srv.Start()
+	require.NoError(t, err, "Server should start on the unix socket")
+	assert.FileExists(t, path, "Socket file should exist while running")
+
+	// Create a client that dials the same socket
+	c := client.New(
+		client.WithUnixSocket(path),
+		client.WithAutoReconnect(false),
+	)
+	err = c.Start()
+	require.NoError(t, err, "Client should connect over the unix socket")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	resp, err := c.ProcessModel(ctx, req)
+	assert.NoError(t, err, "ProcessModel should succeed over the unix socket")
+	require.NotNil(t, resp, "Response should not be nil")
+	assert.Equal(t, req.ID, resp.ID, "Response ID should match request ID")
+
+	err = c.Stop()
+	require.NoError(t, err, "Client should stop successfully")
+
+	err = srv.Stop()
+	require.NoError(t, err, "Server should stop successfully")
+	assert.NoFileExists(t, path, "Socket file should be removed on Stop")
+}
+
+func TestMalformedModelRequest(t *testing.T) {
+	for name, handler := range map[string]Handler{
+		"ModelHandler":    NewDefaultModelHandler(),
+		"RawModelHandler": &ForwardingModelHandler{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transport := core.NewInProcessTransport()
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+			require.NoError(t, srv.RegisterHandler(handler), "Handler registration should succeed")
+			require.NoError(t, srv.Start(), "Server should start")
+			defer srv.Stop()
+
+			conn := dialRaw(t, transport)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			params := map[string]interface{}{"id": "req-7", "parameters": "not a list"}
+			err := conn.Call(ctx, core.MethodProcessModel, params, nil)
+			var rpcErr *jsonrpc2.Error
+			require.ErrorAs(t, err, &rpcErr, "Malformed params should fail with a JSON-RPC error")
+			assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code, "Malformed params should use the invalid params code")
+
+			resp := core.ModelResponseFromRPC(rpcErr)
+			require.NotNil(t, resp, "The error should carry a model response")
+			assert.Equal(t, "req-7", resp.ID, "The response should have the ID the request gave")
+			assert.False(t, resp.Success, "The response should be a failure")
+			assert.Equal(t, core.ErrInvalidModel, resp.ErrorCode, "The response should have the invalid model code")
+			assert.Contains(t, resp.ErrorMessage, "cannot unmarshal", "The response should say why decoding failed")
+
+			// The connection stays usable
+			var ok core.ModelResponse
+			require.NoError(t, conn.Call(ctx, core.MethodProcessModel, testutil.CreateTestModelRequest(), &ok), "A valid request should still succeed")
+		})
+	}
+}
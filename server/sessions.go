@@ -0,0 +1,93 @@
+package server
+
+import (
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// OnSessionEnd registers a callback invoked when a session ends, to release
+// what handlers kept in it: once its connection has closed, or when it
+// expires after WithSessionTTL. Callbacks run in registration order, on the
+// connection's goroutine for a closed connection.
+func (s *Server) OnSessionEnd(callback func(*core.Session)) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	s.sessionEndCallbacks = append(s.sessionEndCallbacks, callback)
+}
+
+// sessionEnded runs the OnSessionEnd callbacks for session.
+func (s *Server) sessionEnded(session *core.Session) {
+	s.clientsMu.Lock()
+	callbacks := append([]func(*core.Session){}, s.sessionEndCallbacks...)
+	s.clientsMu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(session)
+	}
+}
+
+// currentSession returns the session of the connection, starting one if it
+// has none, or nil once the connection has closed. It must be called between
+// begin and end, so the session cannot expire while the request runs.
+func (h *rpcHandler) currentSession() *core.Session {
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+	if h.sessionClosed {
+		return nil
+	}
+	if h.state == nil {
+		h.state = core.NewSession(h.id)
+	}
+	return h.state
+}
+
+// endSession ends the connection's session for good, once it has closed.
+func (h *rpcHandler) endSession() {
+	h.idleMu.Lock()
+	ended := h.state
+	h.state, h.sessionClosed = nil, true
+	h.idleMu.Unlock()
+
+	if ended != nil {
+		h.server.sessionEnded(ended)
+	}
+}
+
+// expireSessions ends the connection's session whenever no request has run
+// on it for ttl, until done is closed.
+func (h *rpcHandler) expireSessions(ttl time.Duration, done <-chan struct{}) {
+	timer := time.NewTimer(ttl)
+	defer timer.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+		expired, wait := h.expireIdleSession(ttl)
+		if expired != nil {
+			h.server.options.Logger.Debug("Session expired",
+				core.LogFieldRemoteAddr, h.remoteAddr,
+				"session", expired.ID())
+			h.server.sessionEnded(expired)
+		}
+		timer.Reset(wait)
+	}
+}
+
+// expireIdleSession takes the connection's session if it has been idle for
+// ttl, and returns how long to wait before checking again.
+func (h *rpcHandler) expireIdleSession(ttl time.Duration) (*core.Session, time.Duration) {
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+	if h.state == nil || h.active > 0 {
+		return nil, ttl
+	}
+	if idle := time.Since(h.lastActive); idle < ttl {
+		return nil, ttl - idle
+	}
+	expired := h.state
+	h.state = nil
+	return expired, ttl
+}
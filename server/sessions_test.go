@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// CountingModelHandler counts the requests of each session, adding the
+// request's "value" to a running total kept in the session
+type CountingModelHandler struct{}
+
+func (CountingModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (CountingModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	session, ok := core.SessionFromContext(ctx)
+	if !ok {
+		return nil, errors.New("request has no session")
+	}
+	total, _ := session.Get("total")
+	sum, _ := total.(int)
+	value, _ := req.GetInt("value")
+	session.Set("total", sum+value)
+
+	resp := core.NewModelResponse(req)
+	resp.Results["total"] = sum + value
+	resp.Results["session"] = session.ID()
+	return resp, nil
+}
+
+// startSessionServer starts a server counting per session, reporting ended
+// sessions on the returned channel
+func startSessionServer(t *testing.T, options ...Option) (*core.InProcessTransport, <-chan *core.Session) {
+	transport := core.NewInProcessTransport()
+	srv := New(append(options, WithTransport(transport), WithLogger(core.NopLogger()))...)
+	require.NoError(t, srv.RegisterHandler(CountingModelHandler{}), "Handler registration should succeed")
+	ended := make(chan *core.Session, 10)
+	srv.OnSessionEnd(func(session *core.Session) { ended <- session })
+	require.NoError(t, srv.Start(), "Server should start")
+	t.Cleanup(func() { srv.Stop() })
+	return transport, ended
+}
+
+// startSessionClient connects a client over transport
+func startSessionClient(t *testing.T, transport core.Transport) *client.Client {
+	c := client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Client should start")
+	t.Cleanup(func() { c.Stop() })
+	return c
+}
+
+// addValue sends value to be added to the session's total, returning the
+// response
+func addValue(ctx context.Context, t *testing.T, c *client.Client, value int) *core.ModelResponse {
+	req := core.NewModelRequest()
+	req.ModelData["value"] = value
+	resp, err := c.ProcessModel(ctx, req)
+	require.NoError(t, err, "Request should succeed")
+	return resp
+}
+
+func TestSessionsAreIsolated(t *testing.T) {
+	transport, ended := startSessionServer(t)
+	first := startSessionClient(t, transport)
+	second := startSessionClient(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Interleaved requests each add to their own connection's total
+	assert.Equal(t, float64(1), addValue(ctx, t, first, 1).Results["total"], "First client should start its own total")
+	assert.Equal(t, float64(10), addValue(ctx, t, second, 10).Results["total"], "Second client should start its own total")
+	assert.Equal(t, float64(3), addValue(ctx, t, first, 2).Results["total"], "First client should see only its own values")
+	last := addValue(ctx, t, second, 20)
+	assert.Equal(t, float64(30), last.Results["total"], "Second client should see only its own values")
+	assert.NotEqual(t, addValue(ctx, t, first, 0).Results["session"], last.Results["session"], "Clients should have distinct sessions")
+
+	// Closing a connection ends its session and no other
+	require.NoError(t, second.Stop(), "Client should stop")
+	select {
+	case session := <-ended:
+		assert.Equal(t, last.Results["session"], session.ID(), "Session of the closed connection should end")
+		total, _ := session.Get("total")
+		assert.Equal(t, 30, total, "Ended session should keep its state for cleanup")
+	case <-ctx.Done():
+		t.Fatal("Session should end when its connection closes")
+	}
+	assert.Equal(t, float64(6), addValue(ctx, t, first, 3).Results["total"], "Other session should carry on")
+	assert.Empty(t, ended, "Other session should not end")
+}
+
+func TestSessionTTL(t *testing.T) {
+	transport, ended := startSessionServer(t, WithSessionTTL(50*time.Millisecond))
+	c := startSessionClient(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	addValue(ctx, t, c, 5)
+	before := addValue(ctx, t, c, 5)
+	assert.Equal(t, float64(10), before.Results["total"], "Session should last across requests")
+
+	// The idle session expires while the connection stays open
+	select {
+	case session := <-ended:
+		assert.Equal(t, before.Results["session"], session.ID(), "Idle session should expire")
+	case <-ctx.Done():
+		t.Fatal("Idle session should expire")
+	}
+	assert.True(t, c.IsConnected(), "Connection should stay open")
+
+	after := addValue(ctx, t, c, 5)
+	assert.Equal(t, float64(5), after.Results["total"], "Next request should start a new session")
+	assert.NotEqual(t, before.Results["session"], after.Results["session"], "New session should have a new ID")
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Names of the observability sinks reported in Health and SinkEvent.
+const (
+	sinkMetrics  = "metrics"
+	sinkAudit    = "audit"
+	sinkJournal  = "journal"
+	sinkRecorder = "recorder"
+)
+
+// audit sends the outcome of req to the configured audit sink.
+func (h *rpcHandler) audit(ctx context.Context, req *jsonrpc2.Request, success bool, duration time.Duration) {
+	sink := h.server.options.AuditSink
+	if sink == nil {
+		return
+	}
+
+	event := core.AuditEvent{
+		Time:         time.Now().UTC(),
+		ConnectionID: h.id,
+		RemoteAddr:   h.remoteAddr,
+		Method:       req.Method,
+		RequestID:    req.ID.String(),
+		Success:      success,
+		Duration:     duration,
+		CancelCause:  core.CancelCause(ctx),
+	}
+	if req.Params != nil {
+		event.Params = *req.Params
+	}
+	if principal := core.PrincipalFromContext(ctx); principal != nil {
+		event.Principal = principal.ID
+	}
+	h.server.sinks.Do(sinkAudit, func() error { return sink.Audit(event) })
+}
+
+// Health reports whether the metrics collector, audit sink, journal and
+// recorder are accepting events. A failing sink never fails a request; it is
+// reported as degraded here until a retry succeeds. Paused handler groups are
+// reported as degraded too, under "group:" and their name.
+func (s *Server) Health() core.Health {
+	health := s.sinks.Health()
+	for _, group := range s.handlerGroups() {
+		if state := group.State(); state != GroupActive {
+			if health.Degraded == nil {
+				health.Degraded = make(map[string]string)
+			}
+			health.Degraded["group:"+group.name] = string(state)
+			health.Status = core.HealthDegraded
+		}
+	}
+	return health
+}
+
+// OnError registers a callback invoked when an observability sink starts
+// failing and again when it recovers, rather than for every failed event.
+func (s *Server) OnError(callback func(core.SinkEvent)) {
+	s.sinks.OnChange(callback)
+}
+
+// serveHealth writes the server's Health as JSON.
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Health())
+}
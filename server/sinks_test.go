@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSinkDegradation(t *testing.T) {
+	sink := testutil.NewFlakySink()
+	srv, c := startServerWithHandler(t, NewDefaultModelHandler(),
+		WithLogger(core.NopLogger()),
+		WithMetrics(sink),
+		WithAuditSink(sink),
+		WithRecorder(sink))
+
+	var mu sync.Mutex
+	var events []core.SinkEvent
+	srv.OnError(func(event core.SinkEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	eventCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events)
+	}
+
+	process := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+		require.NoError(t, err, "Requests should succeed whatever the sinks do")
+		assert.True(t, resp.Success, "Response should indicate success")
+	}
+
+	process()
+	assert.Equal(t, core.HealthHealthy, srv.Health().Status, "Server should start healthy")
+	assert.Positive(t, sink.Delivered(), "Sinks should receive events")
+
+	// Failing sinks never reach the request path
+	sink.SetFailing(true)
+	for i := 0; i < 10; i++ {
+		process()
+	}
+	health := srv.Health()
+	assert.Equal(t, core.HealthDegraded, health.Status, "Server should report degradation")
+	for _, name := range []string{"metrics", "audit", "recorder"} {
+		assert.Contains(t, health.Degraded, name, "Health should name the %s sink", name)
+	}
+	assert.Positive(t, srv.Stats().ObservabilityErrors, "Failures should be counted")
+	assert.Eventually(t, func() bool {
+		return eventCount() == 3
+	}, time.Second, 10*time.Millisecond, "OnError should fire once per degraded sink")
+
+	// Once the sinks accept events again, a retry recovers them
+	sink.SetFailing(false)
+	assert.Eventually(t, func() bool {
+		process()
+		return srv.Health().Status == core.HealthHealthy
+	}, 2*time.Second, 20*time.Millisecond, "Server should recover when the sinks do")
+	assert.Eventually(t, func() bool {
+		return eventCount() == 6
+	}, time.Second, 10*time.Millisecond, "OnError should fire once per recovered sink")
+}
+
+func TestServerAuditEvents(t *testing.T) {
+	logger := &auditRecorder{}
+	_, c := startServerWithHandler(t, NewDefaultModelHandler(), WithAuditSink(logger))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "ProcessModel should succeed")
+
+	events := logger.Events()
+	require.Len(t, events, 1, "One request should produce one audit event")
+	assert.Equal(t, core.MethodProcessModel, events[0].Method, "Event should name the method")
+	assert.Equal(t, "pipe", events[0].RemoteAddr, "Event should name the client")
+	assert.True(t, events[0].Success, "Event should report success")
+}
+
+// auditRecorder collects audit events in memory
+type auditRecorder struct {
+	mu     sync.Mutex
+	events []core.AuditEvent
+}
+
+func (r *auditRecorder) Audit(event core.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *auditRecorder) Events() []core.AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]core.AuditEvent(nil), r.events...)
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// StallEvent reports a connection whose peer started a frame and then
+// stopped sending it, such as a body shorter than its Content-Length header.
+type StallEvent struct {
+	RemoteAddr string        // Peer of the stalled connection
+	Stalled    time.Duration // Time spent in the partial frame when the stall was detected
+	Closed     bool          // Whether the server closed the connection
+	Timestamp  time.Time     // When the stall was detected
+}
+
+// ConnectionInfo describes a connection being served.
+type ConnectionInfo struct {
+	RemoteAddr   string             `json:"remoteAddr"`             // Peer of the connection; empty for in-process and stdio connections
+	Stall        time.Duration      `json:"stall"`                  // Time spent so far in a partial frame; zero between frames
+	LongestStall time.Duration      `json:"longestStall"`           // Longest time any frame took to arrive; zero without stall detection
+	Topics       []string           `json:"topics,omitempty"`       // Topics the client is subscribed to
+	Filters      map[string]string  `json:"filters,omitempty"`      // Filter expressions of the client's filtered subscriptions, by topic
+	Compression  core.Compression   `json:"compression,omitempty"`  // Algorithm negotiated with the client; empty for uncompressed connections
+	Codec        string             `json:"codec,omitempty"`        // Content type of the codec negotiated with the client; empty for JSON
+	Capabilities *core.Capabilities `json:"capabilities,omitempty"` // Negotiated in the handshake; nil if the client did not initialize
+	Outbound     int                `json:"outbound"`               // Replies and notifications queued or being written to the client
+	Compressed   CompressionStats   `json:"compressed"`             // What compressing the messages written to the client achieved
+}
+
+// frameStream is the jsonrpc2 object stream with the read path instrumented:
+// it notes when the first byte of a frame arrives and when the frame is
+// complete, so a watcher can tell a quiet connection from a stalled one. It
+// also answers the client's negotiation, if the server offers compression or
+// a codec other than JSON, and refuses requests over the size limit itself.
+type frameStream struct {
+	rwc    io.ReadWriteCloser
+	reader *bufio.Reader
+	codec  jsonrpc2.ObjectCodec // Replaced only by the read loop, under writeMu
+
+	offer        core.FrameCodec    // Codec the server offers, and its size limit
+	compressions []core.Compression // Algorithms the server offers
+	first        bool               // Whether the first frame, which may negotiate, is still to be read
+
+	onTooLarge func(*core.FrameTooLargeError) // Called for each oversized frame refused; may be nil
+
+	writeMu sync.Mutex
+	writer  *bufio.Writer
+
+	frameMu    sync.Mutex
+	frameStart time.Time // Zero between frames
+	reported   bool      // Whether the current frame has been reported as stalled
+	longest    time.Duration
+	chosen     core.FrameCodec // Compression and codec negotiated, for negotiated
+}
+
+func newFrameStream(rwc io.ReadWriteCloser, offer core.FrameCodec, compressions []core.Compression) *frameStream {
+	return &frameStream{
+		rwc:          rwc,
+		reader:       bufio.NewReader(rwc),
+		codec:        core.FrameCodec{MaxBytes: offer.MaxBytes},
+		offer:        offer,
+		compressions: compressions,
+		first:        true,
+		writer:       bufio.NewWriter(rwc),
+	}
+}
+
+// ReadObject implements jsonrpc2.ObjectStream.
+func (s *frameStream) ReadObject(v interface{}) error {
+	if !s.first || !s.negotiates() {
+		return s.readFrame(v)
+	}
+
+	// Only the first frame may negotiate
+	s.first = false
+	var first json.RawMessage
+	if err := s.readFrame(&first); err != nil {
+		return err
+	}
+	handled, err := s.negotiate(first)
+	if err != nil {
+		return err
+	}
+	if handled {
+		return s.readFrame(v)
+	}
+	return json.Unmarshal(first, v)
+}
+
+// readFrame reads the next frame into v, refusing oversized ones.
+func (s *frameStream) readFrame(v interface{}) error {
+	for {
+		err := s.timeFrame(v)
+		var tooLarge *core.FrameTooLargeError
+		if !errors.As(err, &tooLarge) {
+			return err
+		}
+		if err := s.refuse(tooLarge); err != nil {
+			return err
+		}
+	}
+}
+
+// refuse replies to an oversized request with CodeRequestTooLarge. Oversized
+// notifications are dropped.
+func (s *frameStream) refuse(tooLarge *core.FrameTooLargeError) error {
+	if s.onTooLarge != nil {
+		s.onTooLarge(tooLarge)
+	}
+	if tooLarge.ID == nil {
+		return nil
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.codec.WriteObject(s.writer, &jsonrpc2.Response{ID: *tooLarge.ID, Error: tooLarge.RPCError()}); err != nil {
+		return err
+	}
+	return s.writer.Flush()
+}
+
+// timeFrame reads the next frame into v, timing its arrival.
+func (s *frameStream) timeFrame(v interface{}) error {
+	// Wait for the frame to begin before timing it
+	if _, err := s.reader.Peek(1); err != nil {
+		return err
+	}
+	s.frameMu.Lock()
+	s.frameStart = time.Now()
+	s.reported = false
+	s.frameMu.Unlock()
+
+	err := s.codec.ReadObject(s.reader, v)
+
+	s.frameMu.Lock()
+	s.longest = max(s.longest, time.Since(s.frameStart))
+	s.frameStart = time.Time{}
+	s.frameMu.Unlock()
+	return err
+}
+
+// WriteObject implements jsonrpc2.ObjectStream.
+func (s *frameStream) WriteObject(obj interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.codec.WriteObject(s.writer, obj); err != nil {
+		return err
+	}
+	return s.writer.Flush()
+}
+
+// Close implements jsonrpc2.ObjectStream.
+func (s *frameStream) Close() error {
+	return s.rwc.Close()
+}
+
+// stall returns the time spent so far in the current frame and the longest
+// time a frame has taken.
+func (s *frameStream) stall(now time.Time) (current, longest time.Duration) {
+	s.frameMu.Lock()
+	defer s.frameMu.Unlock()
+	if !s.frameStart.IsZero() {
+		current = now.Sub(s.frameStart)
+	}
+	return current, max(s.longest, current)
+}
+
+// checkStall reports whether the current frame has been arriving for at
+// least threshold and has not been reported yet, marking it reported.
+func (s *frameStream) checkStall(now time.Time, threshold time.Duration) (time.Duration, bool) {
+	s.frameMu.Lock()
+	defer s.frameMu.Unlock()
+	if s.frameStart.IsZero() || s.reported {
+		return 0, false
+	}
+	stalled := now.Sub(s.frameStart)
+	if stalled < threshold {
+		return 0, false
+	}
+	s.reported = true
+	return stalled, true
+}
+
+// watchStalls checks the connection's read path until done is closed,
+// reporting each frame that takes longer than the stall threshold to arrive.
+func (h *rpcHandler) watchStalls(frames *frameStream, done <-chan struct{}) {
+	threshold := h.server.options.StallThreshold
+	ticker := h.server.tasks.NewTicker(core.TaskConnection, max(threshold/4, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if stalled, ok := frames.checkStall(now, threshold); ok {
+				h.server.connectionStalled(h, stalled)
+			}
+		}
+	}
+}
+
+// connectionStalled logs, counts and reports a stalled connection, closing it
+// if the server is configured to.
+func (s *Server) connectionStalled(h *rpcHandler, stalled time.Duration) {
+	closeConn := s.options.StallClose
+	s.options.Logger.Warn("Connection stalled mid-frame",
+		core.LogFieldRemoteAddr, h.remoteAddr,
+		"stalled", stalled,
+		"closing", closeConn)
+
+	atomic.AddUint64(&s.stalls, 1)
+	if collector, ok := s.options.Metrics.(core.StallCollector); ok {
+		s.sinks.Do(sinkMetrics, func() error {
+			collector.ConnectionStalled(h.remoteAddr, stalled)
+			return nil
+		})
+	}
+
+	if closeConn {
+		h.closer.Close()
+	}
+
+	event := StallEvent{
+		RemoteAddr: h.remoteAddr,
+		Stalled:    stalled,
+		Closed:     closeConn,
+		Timestamp:  time.Now(),
+	}
+	for _, callback := range s.stallCallbacks {
+		callback := callback
+		s.tasks.Go(core.TaskEvents, func() { callback(event) })
+	}
+}
+
+// OnStall registers a callback invoked when stall detection finds a
+// connection whose peer stopped sending partway through a frame. See
+// WithStallDetection.
+func (s *Server) OnStall(callback func(StallEvent)) {
+	s.stallCallbacks = append(s.stallCallbacks, callback)
+}
+
+// Connections returns a description of every connection being served.
+func (s *Server) Connections() []ConnectionInfo {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	now := time.Now()
+	infos := make([]ConnectionInfo, 0, len(s.sessions))
+	for h := range s.sessions {
+		info := ConnectionInfo{RemoteAddr: h.remoteAddr, Topics: h.subscriptions.list(), Filters: h.subscriptions.filters(), Capabilities: h.negotiated()}
+		if h.frames != nil {
+			if s.options.StallThreshold > 0 {
+				info.Stall, info.LongestStall = h.frames.stall(now)
+			}
+			info.Compression, info.Codec = h.frames.negotiated()
+			info.Compressed = h.compression.snapshot()
+		}
+		if h.outbound != nil {
+			info.Outbound = h.outbound.len()
+		}
+		if h.fixedInfo != nil {
+			info.Compression, info.Codec = h.fixedInfo.Compression, h.fixedInfo.Codec
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stallCountingMetrics counts the stalls reported to a collector
+type stallCountingMetrics struct {
+	core.MetricsCollector
+	stalls int32
+}
+
+func (m *stallCountingMetrics) ConnectionStalled(string, time.Duration) {
+	atomic.AddInt32(&m.stalls, 1)
+}
+
+// startStallServer starts a TCP server detecting stalls of 100ms and returns
+// it with its port and a channel receiving its stall events
+func startStallServer(t *testing.T, closeConn bool, options ...Option) (*Server, int, <-chan StallEvent) {
+	srv := New(append([]Option{WithPort(0), WithStallDetection(100*time.Millisecond, closeConn)}, options...)...)
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+	events := make(chan StallEvent, 4)
+	srv.OnStall(func(event StallEvent) { events <- event })
+	require.NoError(t, srv.Start(), "Server should start successfully")
+	t.Cleanup(func() { srv.Stop() })
+	return srv, srv.Port(), events
+}
+
+// sendHalfFrame opens a raw connection and sends a frame whose body stops
+// short of its Content-Length
+func sendHalfFrame(t *testing.T, port int) net.Conn {
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	require.NoError(t, err, "Raw connection should succeed")
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = conn.Write([]byte("Content-Length: 80\r\n\r\n{\"jsonrpc\":\"2.0\",\"id\":1,"))
+	require.NoError(t, err, "Half a frame should be written")
+	return conn
+}
+
+func TestStallDetectionCloses(t *testing.T) {
+	metrics := &stallCountingMetrics{MetricsCollector: core.NopMetrics()}
+	srv, port, events := startStallServer(t, true, WithMetrics(metrics))
+	conn := sendHalfFrame(t, port)
+
+	select {
+	case event := <-events:
+		assert.GreaterOrEqual(t, event.Stalled, 100*time.Millisecond, "Stall should be reported once it passes the threshold")
+		assert.Less(t, event.Stalled, time.Second, "Stall should be detected promptly")
+		assert.True(t, event.Closed, "Event should report the connection was closed")
+		assert.NotEmpty(t, event.RemoteAddr, "Event should name the peer")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stall should be detected")
+	}
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "Server should close the stalled connection")
+	assert.Equal(t, uint64(1), srv.Stats().Stalls, "Stall should be counted in Stats")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&metrics.stalls), "Stall should be reported to the collector")
+}
+
+func TestStallDetectionReportsOnly(t *testing.T) {
+	srv, port, events := startStallServer(t, false)
+	sendHalfFrame(t, port)
+
+	select {
+	case event := <-events:
+		assert.False(t, event.Closed, "Connection should be left open")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stall should be detected")
+	}
+
+	infos := srv.Connections()
+	require.Len(t, infos, 1, "Stalled connection should still be served")
+	assert.GreaterOrEqual(t, infos[0].Stall, 100*time.Millisecond, "Connection should report its current stall")
+	assert.Equal(t, infos[0].Stall, infos[0].LongestStall, "Current stall should be the longest")
+
+	select {
+	case <-events:
+		t.Fatal("A stall should be reported once")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestStallDetectionIgnoresQuietConnections(t *testing.T) {
+	srv, port, events := startStallServer(t, true)
+	c := client.New(client.WithServerHost("127.0.0.1"), client.WithServerPort(port), client.WithAutoReconnect(false))
+	require.NoError(t, c.Start(), "Client should connect")
+	defer c.Stop()
+
+	// Idle time between frames is not a stall
+	time.Sleep(300 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Requests over a quiet connection should succeed")
+
+	assert.Empty(t, events, "Quiet connection should not be reported")
+	infos := srv.Connections()
+	require.Len(t, infos, 1, "Client connection should be served")
+	assert.Zero(t, infos[0].Stall, "No frame should be in progress")
+	assert.Less(t, infos[0].LongestStall, 100*time.Millisecond, "Whole frames should arrive quickly")
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Stats describes the connections a server is serving and the background
+// goroutines and timers it has running.
+type Stats struct {
+	Sessions            int                                 `json:"sessions"`                     // Connections being served
+	Tasks               map[core.TaskFeature]core.TaskCount `json:"tasks"`                        // Live goroutines and timers by feature
+	ObservabilityErrors uint64                              `json:"observabilityErrors"`          // Failed deliveries to metrics, audit, journal or recorder
+	InFlight            int                                 `json:"inFlight"`                     // Requests holding a handler slot; zero without WithMaxConcurrentRequests
+	Queued              int                                 `json:"queued"`                       // Requests waiting for a handler slot
+	Principals          map[string]PrincipalUsage           `json:"principals,omitempty"`         // Requests in flight by principal; only with WithPrincipalConcurrencyLimit
+	Groups              map[string]GroupStats               `json:"groups,omitempty"`             // Handler groups by name
+	Jobs                int                                 `json:"jobs"`                         // Asynchronous jobs running, pending or kept for retention
+	Stalls              uint64                              `json:"stalls"`                       // Connections found stalled mid-frame
+	HandlerTimeouts     uint64                              `json:"handlerTimeouts"`              // Requests failed for running past their handler timeout
+	StuckHandlers       int                                 `json:"stuckHandlers"`                // Timed out handlers that ignored their context and are still running
+	Durable             DurableStats                        `json:"durable"`                      // Durable subscriptions and their buffers
+	Compression         CompressionStats                    `json:"compression"`                  // Compression of messages to clients across connections
+	CompressionMethods  map[string]MethodCompression        `json:"compressionMethods,omitempty"` // Compression of messages to clients by method, with adaptive compression's decisions
+}
+
+// Stats returns a snapshot of the server's sessions and background tasks.
+// Goroutines started by the JSON-RPC library and by handlers are not counted.
+func (s *Server) Stats() Stats {
+	inFlight, queued := s.pool.counts()
+	compression, methods := s.compression.snapshot()
+	return Stats{
+		Sessions:            s.sessionCount(),
+		Tasks:               s.tasks.Counts(),
+		ObservabilityErrors: s.sinks.Errors(),
+		InFlight:            inFlight,
+		Queued:              queued,
+		Principals:          s.principals.snapshot(),
+		Groups:              s.groupStats(),
+		Jobs:                s.jobs.count(),
+		Stalls:              atomic.LoadUint64(&s.stalls),
+		HandlerTimeouts:     atomic.LoadUint64(&s.handlerTimeouts),
+		StuckHandlers:       int(atomic.LoadInt64(&s.stuckHandlers)),
+		Durable:             s.durable.stats(),
+		Compression:         compression,
+		CompressionMethods:  methods,
+	}
+}
+
+// serveStats writes the server's Stats as JSON.
+func (s *Server) serveStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Stats())
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerIdleTasks(t *testing.T) {
+	// A default server with no clients only accepts connections
+	srv := New(WithTransport(core.NewInProcessTransport()), WithLogger(core.NopLogger()))
+	require.NoError(t, srv.Start(), "Server should start")
+	assert.Equal(t, Stats{
+		Tasks: map[core.TaskFeature]core.TaskCount{core.TaskAccept: {Goroutines: 1}},
+	}, srv.Stats(), "Idle server should run one accept goroutine")
+	require.NoError(t, srv.Stop(), "Server should stop")
+	assert.Empty(t, srv.Stats().Tasks, "Stopped server should run nothing")
+
+	// Each client adds a connection goroutine, its reader and its writer
+	srv, _ = startServerWithHandler(t, NewDefaultModelHandler(), WithLogger(core.NopLogger()))
+	assert.Eventually(t, func() bool {
+		return srv.Stats().Sessions == 1
+	}, time.Second, 10*time.Millisecond, "Server should serve the client")
+	assert.Equal(t, map[core.TaskFeature]core.TaskCount{
+		core.TaskAccept:     {Goroutines: 1},
+		core.TaskConnection: {Goroutines: 3},
+	}, srv.Stats().Tasks, "Server with one client should run a connection goroutine, a reader and a writer")
+
+	// Without an outbound queue senders write themselves
+	srv, _ = startServerWithHandler(t, NewDefaultModelHandler(), WithLogger(core.NopLogger()), WithOutboundQueueSize(0))
+	assert.Eventually(t, func() bool {
+		return srv.Stats().Sessions == 1
+	}, time.Second, 10*time.Millisecond, "Server should serve the client")
+	assert.Equal(t, core.TaskCount{Goroutines: 2}, srv.Stats().Tasks[core.TaskConnection], "Server without an outbound queue should run no writer")
+}
+
+func TestServerStatsEndpoint(t *testing.T) {
+	collector := metrics.NewExpvarCollector("mcp_server_stats_test")
+	srv, _ := startServerWithHandler(t, NewDefaultModelHandler(), WithMetrics(collector), WithMetricsAddr("127.0.0.1:0"))
+	assert.Eventually(t, func() bool {
+		return srv.Stats().Sessions == 1
+	}, time.Second, 10*time.Millisecond, "Server should serve the client")
+
+	resp, err := http.Get("http://" + srv.MetricsAddr().String() + "/stats")
+	require.NoError(t, err, "Stats endpoint should be reachable")
+	defer resp.Body.Close()
+
+	var stats Stats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats), "Stats should be served as JSON")
+	assert.Equal(t, 1, stats.Sessions, "Stats should count the session")
+	assert.Equal(t, core.TaskCount{Goroutines: 1}, stats.Tasks[core.TaskMetrics], "Metrics listener should be counted")
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// stdio joins standard input and output into a single stream.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+// Close closes standard input, ending the session from our side.
+func (s stdio) Close() error {
+	return os.Stdin.Close()
+}
+
+// ServeStdio serves a single client speaking JSON-RPC over the process's
+// standard input and output, as when the server is spawned as a subprocess.
+// It does not require Start and returns once stdin is closed or ctx is done.
+// Logs go to stderr by default and so do not interfere with the protocol.
+func (s *Server) ServeStdio(ctx context.Context) error {
+	return s.ServeConn(ctx, stdio{Reader: os.Stdin, Writer: os.Stdout})
+}
+
+// ServeConn serves a single client over rwc until the peer disconnects or ctx
+// is done, dispatching requests to the registered handlers. rwc is closed
+// before ServeConn returns.
+func (s *Server) ServeConn(ctx context.Context, rwc io.ReadWriteCloser) (err error) {
+	defer rwc.Close()
+
+	handler := &rpcHandler{
+		server:      s,
+		id:          s.newClientID(),
+		connectedAt: time.Now(),
+		closer:      rwc,
+		limiter:     newConnLimiter(s.options.RateLimit, s.options.MethodRateLimits),
+	}
+
+	// Writes are queued for a writer of the connection's own, so a client
+	// slow to take a large frame holds up no one else
+	wire := rwc
+	if s.options.OutboundQueueSize > 0 {
+		handler.outbound = newOutboundStream(rwc, s.options.OutboundQueueSize)
+		wire = handler.outbound.conn()
+	}
+
+	stream := jsonrpc2.NewBufferedStream(wire, jsonrpc2.VSCodeObjectCodec{})
+	compressions := core.CompressionPreference(s.options.Compression, s.options.CompressionFallbacks)
+	if s.options.StallThreshold > 0 || len(compressions) > 0 || s.options.Codec != nil || s.options.MaxRequestBytes > 0 {
+		handler.compression = newConnCompression(s.compression, s.options.CompressionThreshold)
+		handler.frames = newFrameStream(wire, core.FrameCodec{
+			Codec:     s.options.Codec,
+			Threshold: s.options.CompressionThreshold,
+			MaxBytes:  s.options.MaxRequestBytes,
+			Advisor:   handler.compression,
+		}, compressions)
+		handler.frames.onTooLarge = handler.logTooLarge
+		stream = handler.frames
+	}
+	if handler.outbound != nil {
+		stream = handler.outbound.wrap(stream)
+		handler.closer = handler.outbound
+		s.tasks.Go(core.TaskConnection, handler.outbound.run)
+	}
+
+	// Frames are read ahead of the request being handled, so the client can
+	// cancel it
+	ahead := newReadAheadStream(stream, handler.calls.cancel)
+	stream = ahead
+	s.tasks.Go(core.TaskConnection, ahead.run)
+	if netConn, ok := rwc.(net.Conn); ok {
+		handler.remoteAddr = netConn.RemoteAddr().String()
+	}
+	s.metrics.ConnectionOpened(handler.remoteAddr)
+	defer s.metrics.ConnectionClosed(handler.remoteAddr)
+
+	// Reported once the session is over and the connection closed
+	defer func() {
+		reason := err
+		if reason == nil {
+			reason = ctx.Err()
+		}
+		s.clientDisconnected(handler, reason)
+	}()
+
+	// Requests still being handled when the connection ends are cancelled
+	connCtx, cancelConn := context.WithCancelCause(ctx)
+	defer cancelConn(core.CauseDisconnect)
+
+	conn := jsonrpc2.NewConn(connCtx, stream, handler)
+	defer conn.Close()
+	s.addSession(handler, conn)
+	defer s.removeSession(handler)
+	s.clientConnected(handler)
+
+	if s.options.StallThreshold > 0 {
+		s.tasks.Go(core.TaskConnection, func() { handler.watchStalls(handler.frames, conn.DisconnectNotify()) })
+	}
+	if s.options.SessionTTL > 0 {
+		s.tasks.Go(core.TaskConnection, func() { handler.expireSessions(s.options.SessionTTL, conn.DisconnectNotify()) })
+	}
+
+	select {
+	case <-conn.DisconnectNotify():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// addSession tracks a connection being served so Drain can close it and
+// notifications can be sent to it.
+func (s *Server) addSession(h *rpcHandler, conn rpcConn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	h.conn = conn
+	s.sessions[h] = struct{}{}
+	if s.draining {
+		h.closeWhenIdle()
+	}
+}
+
+// removeSession stops tracking a connection, releasing its durable
+// subscriptions and ending its session.
+func (s *Server) removeSession(h *rpcHandler) {
+	s.connsMu.Lock()
+	delete(s.sessions, h)
+	s.connsMu.Unlock()
+	s.durable.detach(h)
+	h.endSession()
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeConn(t *testing.T) {
+	srv := New()
+	require.NoError(t, srv.RegisterHandler(NewDefaultModelHandler()), "Handler registration should succeed")
+
+	serverEnd, clientEnd := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- srv.ServeConn(context.Background(), serverEnd) }()
+
+	// Talk to the server without starting it, as the stdio path does
+	stream := jsonrpc2.NewBufferedStream(clientEnd, jsonrpc2.VSCodeObjectCodec{})
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(
+		func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (interface{}, error) { return nil, nil },
+	))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	var resp core.ModelResponse
+	require.NoError(t, conn.Call(ctx, core.MethodProcessModel, req, &resp), "Call should succeed")
+	assert.Equal(t, req.ID, resp.ID, "Response ID should match request ID")
+
+	// Closing our end ends the session
+	conn.Close()
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "ServeConn should return cleanly when the peer disconnects")
+	case <-time.After(time.Second):
+		t.Fatal("ServeConn should return after the peer disconnects")
+	}
+}
+
+func TestServeConnContextCancel(t *testing.T) {
+	srv := New()
+	serverEnd, clientEnd := net.Pipe()
+	defer clientEnd.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.ServeConn(ctx, serverEnd) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled, "ServeConn should report the cancellation")
+	case <-time.After(time.Second):
+		t.Fatal("ServeConn should return when its context is done")
+	}
+}
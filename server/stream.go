@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Errors returned by a stream's emit once it can take no more chunks.
+var (
+	errClientGone  = errors.New("client disconnected")
+	errStreamEnded = errors.New("stream has ended")
+)
+
+// StreamingModelHandler handles mcp.processModelStream requests, sending
+// partial results before the final response.
+type StreamingModelHandler interface {
+	Handler
+	// ProcessModelStream processes a model request, passing partial results to
+	// emit as they become available, and returns the final response. emit
+	// fills in the chunk's request ID and sequence number. It returns an error
+	// once the client has disconnected, the context is done or the handler has
+	// returned, and the handler should then stop.
+	ProcessModelStream(ctx context.Context, req *core.ModelRequest, emit func(chunk core.ModelChunk) error) (*core.ModelResponse, error)
+}
+
+// handleProcessModelStream runs a streaming handler, sending its chunks as
+// notifications on the connection before replying with the final response.
+func (h *rpcHandler) handleProcessModelStream(ctx context.Context, conn rpcConn, req *jsonrpc2.Request, handler interface{}) {
+	streamHandler, ok := handler.(StreamingModelHandler)
+	if !ok {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInternalError, "handler is not a StreamingModelHandler")
+		return
+	}
+	modelReq, rpcErr := decodeModelRequest(req)
+	if rpcErr != nil {
+		h.replyRPCError(ctx, conn, req, rpcErr)
+		return
+	}
+	traceFromContext(ctx).identify(modelReq.ID)
+
+	stream := &chunkStream{conn: conn, requestID: modelReq.ID}
+	process := h.server.withMiddleware(req.Method, func(ctx context.Context, modelReq *core.ModelRequest) (*core.ModelResponse, error) {
+		return h.server.processModel(ctx, req.Method, streamHandler, modelReq, func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+			return streamHandler.ProcessModelStream(ctx, req, func(chunk core.ModelChunk) error {
+				return stream.emit(ctx, chunk)
+			})
+		})
+	})
+	resp, err := withHandlerTimeout(h.server, ctx, req.Method, modelReq.ID, func(ctx context.Context) (*core.ModelResponse, error) {
+		return process(ctx, modelReq)
+	})
+	stream.end()
+	if err != nil {
+		h.replyProcessError(ctx, conn, req, err)
+		return
+	}
+
+	h.reply(ctx, conn, req, resp)
+}
+
+// chunkStream numbers the chunks of one request and sends them in order.
+type chunkStream struct {
+	conn      rpcConn
+	requestID string
+
+	mu       sync.Mutex
+	sequence int
+	ended    bool
+}
+
+// emit sends chunk as the next in the stream.
+func (s *chunkStream) emit(ctx context.Context, chunk core.ModelChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return errStreamEnded
+	}
+	select {
+	case <-s.conn.DisconnectNotify():
+		return errClientGone
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	chunk.RequestID = s.requestID
+	chunk.Sequence = s.sequence
+	if err := s.conn.Notify(ctx, core.NotifyModelChunk, chunk); err != nil {
+		return fmt.Errorf("failed to send chunk: %w", err)
+	}
+	s.sequence++
+	return nil
+}
+
+// end stops the stream taking chunks, so none follow the reply.
+func (s *chunkStream) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
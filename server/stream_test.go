@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// CountingStreamHandler streams count chunks numbered from zero, pausing
+// between them, and answers with their sum. The error that stopped a stream
+// early is sent on stopped.
+type CountingStreamHandler struct {
+	count   int
+	pause   time.Duration
+	stopped chan error
+}
+
+func (h *CountingStreamHandler) Methods() []string {
+	return []string{core.MethodProcessModelStream}
+}
+
+func (h *CountingStreamHandler) ProcessModelStream(ctx context.Context, req *core.ModelRequest, emit func(core.ModelChunk) error) (*core.ModelResponse, error) {
+	sum := 0
+	for i := 0; i < h.count; i++ {
+		if err := emit(core.ModelChunk{Data: map[string]interface{}{"index": i}}); err != nil {
+			if h.stopped != nil {
+				h.stopped <- err
+			}
+			return nil, err
+		}
+		sum += i
+		time.Sleep(h.pause)
+	}
+	resp := core.NewModelResponse(req)
+	resp.Results["chunks"] = h.count
+	resp.Results["sum"] = sum
+	return resp, nil
+}
+
+func TestProcessModelStream(t *testing.T) {
+	_, c := startServerWithHandler(t, &CountingStreamHandler{count: 100})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := testutil.CreateTestModelRequest()
+	var chunks []core.ModelChunk
+	resp, err := c.ProcessModelStream(ctx, req, func(chunk core.ModelChunk) {
+		chunks = append(chunks, chunk)
+	})
+	require.NoError(t, err, "Streaming should succeed")
+
+	require.Len(t, chunks, 100, "Every chunk should be delivered before the response")
+	sum := 0
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.Sequence, "Chunks should arrive in order")
+		assert.Equal(t, float64(i), chunk.Data["index"], "Chunk should carry the handler's data")
+		assert.Equal(t, req.ID, chunk.RequestID, "Chunk should name its request")
+		sum += i
+	}
+	assert.Equal(t, req.ID, resp.ID, "Final response should answer the request")
+	assert.Equal(t, float64(100), resp.Results["chunks"], "Final response should count the chunks")
+	assert.Equal(t, float64(sum), resp.Results["sum"], "Final response should aggregate the chunks")
+}
+
+func TestProcessModelStreamClientDisconnects(t *testing.T) {
+	handler := &CountingStreamHandler{count: 1000, pause: 5 * time.Millisecond, stopped: make(chan error, 1)}
+	_, c := startServerWithHandler(t, handler)
+
+	received := make(chan struct{}, 1000)
+	go c.ProcessModelStream(context.Background(), testutil.CreateTestModelRequest(), func(core.ModelChunk) {
+		received <- struct{}{}
+	})
+	for i := 0; i < 10; i++ {
+		receive(t, received, "chunk")
+	}
+	require.NoError(t, c.Stop(), "Client should stop")
+
+	err := receive(t, handler.stopped, "handler to stop")
+	assert.Error(t, err, "emit should fail once the client disconnects")
+}
+
+func TestProcessModelStreamCancelled(t *testing.T) {
+	_, c := startServerWithHandler(t, &CountingStreamHandler{count: 200, pause: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var delivered int32
+	_, err := c.ProcessModelStream(ctx, testutil.CreateTestModelRequest(), func(core.ModelChunk) {
+		if atomic.AddInt32(&delivered, 1) == 5 {
+			cancel()
+		}
+	})
+	require.ErrorIs(t, err, context.Canceled, "Cancelling should end the call")
+
+	// Chunks still arriving for the abandoned stream are dropped
+	seen := atomic.LoadInt32(&delivered)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, seen, atomic.LoadInt32(&delivered), "No chunk should be delivered after the call returns")
+
+	ctx, cancelCall := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelCall()
+	var sequences []int
+	_, err = c.ProcessModelStream(ctx, testutil.CreateTestModelRequest(), func(chunk core.ModelChunk) {
+		sequences = append(sequences, chunk.Sequence)
+	})
+	require.NoError(t, err, "Client should stream again after a cancelled call")
+	require.Len(t, sequences, 200, "New call should get only its own chunks")
+	assert.Equal(t, 0, sequences[0], "New call should start from the first chunk")
+}
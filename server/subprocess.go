@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// subprocessExitTimeout is how long a group's child process may take to exit
+// after it is sent SIGTERM before it is killed.
+const subprocessExitTimeout = 2 * time.Second
+
+// subprocessStdinGrace is how long a group's child process may take to exit
+// after its stdin is closed before it is sent SIGTERM.
+const subprocessStdinGrace = 100 * time.Millisecond
+
+// errRestartBudget is returned for requests to a group whose child process
+// exited more often than its restart budget allows.
+var errRestartBudget = errors.New("handler process restarted too often")
+
+// subprocess forwards requests to a child process serving them over stdio,
+// starting it on first use and again after it exits, within a restart budget.
+type subprocess struct {
+	template      *exec.Cmd // Used as-is for the first process, copied for later ones
+	drainTimeout  time.Duration
+	maxRestarts   int
+	restartWindow time.Duration
+
+	mu       sync.Mutex
+	started  bool
+	closed   bool
+	current  *childProcess
+	restarts []time.Time // When processes after the first were started, within restartWindow
+}
+
+// childProcess is a running child and the connection to it.
+type childProcess struct {
+	cmd      *exec.Cmd
+	stdin    *os.File
+	stdout   *os.File
+	conn     *jsonrpc2.Conn
+	exited   chan struct{}
+	inflight sync.WaitGroup // Requests forwarded and not yet answered; added to under subprocess.mu
+}
+
+// processModel forwards req to the child process. A ModelError the child's
+// handler returned is returned as such.
+func (p *subprocess) processModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	child, err := p.child()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start handler process: %w", err)
+	}
+	defer child.inflight.Done()
+	var resp core.ModelResponse
+	if err := child.conn.Call(ctx, core.MethodProcessModel, req, &resp); err != nil {
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) {
+			if modelErr := core.ModelErrorFromRPC(rpcErr); modelErr != nil {
+				return nil, modelErr
+			}
+		}
+		return nil, fmt.Errorf("handler process: %w", err)
+	}
+	return &resp, nil
+}
+
+// child returns the running child process, starting one if there is none,
+// with a request added to its inflight count.
+func (p *subprocess) child() (*childProcess, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errors.New("server stopped")
+	}
+	if p.current != nil {
+		select {
+		case <-p.current.conn.DisconnectNotify():
+			p.current.stop()
+			p.current = nil
+		default:
+			p.current.inflight.Add(1)
+			return p.current, nil
+		}
+	}
+
+	// A child that keeps exiting is not restarted beyond the budget
+	now := time.Now()
+	if p.started && p.maxRestarts > 0 {
+		if p.restartsLocked(now) >= p.maxRestarts {
+			return nil, errRestartBudget
+		}
+		p.restarts = append(p.restarts, now)
+	}
+
+	// An exec.Cmd can only run once, so later processes start from a copy
+	cmd := p.template
+	if p.started {
+		cmd = exec.Command(p.template.Path, p.template.Args[1:]...)
+		cmd.Env = p.template.Env
+		cmd.Dir = p.template.Dir
+		cmd.Stderr = p.template.Stderr
+	}
+	p.started = true
+
+	// Own the pipe ends so the process exiting never closes them under a pending read
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, err
+	}
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+	err = cmd.Start()
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, err
+	}
+
+	child := &childProcess{cmd: cmd, stdin: stdinW, stdout: stdoutR, exited: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(child.exited)
+	}()
+	stream := jsonrpc2.NewBufferedStream(child, jsonrpc2.VSCodeObjectCodec{})
+	child.conn = jsonrpc2.NewConn(context.Background(), stream, discardHandler{})
+	p.current = child
+	child.inflight.Add(1)
+	return child, nil
+}
+
+// restartsLocked returns the number of restarts within the window ending at
+// now, forgetting older ones. The caller holds mu.
+func (p *subprocess) restartsLocked(now time.Time) int {
+	recent := p.restarts[:0]
+	for _, at := range p.restarts {
+		if now.Sub(at) < p.restartWindow {
+			recent = append(recent, at)
+		}
+	}
+	p.restarts = recent
+	return len(recent)
+}
+
+// unready reports whether the child has exited and starting another would
+// exceed the restart budget.
+func (p *subprocess) unready(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || !p.started || p.maxRestarts <= 0 {
+		return false
+	}
+	if p.current != nil {
+		select {
+		case <-p.current.conn.DisconnectNotify():
+		default:
+			return false
+		}
+	}
+	return p.restartsLocked(now) >= p.maxRestarts
+}
+
+// open lets child processes be started again after close.
+func (p *subprocess) open() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = false
+}
+
+// close shuts the running child, if any, down gracefully; no more are
+// started.
+func (p *subprocess) close() {
+	p.mu.Lock()
+	p.closed = true
+	child := p.current
+	p.current = nil
+	p.mu.Unlock()
+
+	if child != nil {
+		child.shutdown(p.drainTimeout)
+	}
+}
+
+func (c *childProcess) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *childProcess) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Close closes the child's stdin, ending the connection from our side.
+func (c *childProcess) Close() error {
+	return c.stdin.Close()
+}
+
+// shutdown sends the child core.MethodShutdown and waits up to drain for the
+// requests forwarded to it to be answered, or for it to exit, before stopping
+// it. Requests still unanswered then fail.
+func (c *childProcess) shutdown(drain time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := c.conn.Notify(ctx, core.MethodShutdown, nil); err == nil {
+		drained := make(chan struct{})
+		go func() {
+			c.inflight.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-c.exited:
+		case <-ctx.Done():
+		}
+	}
+	c.stop()
+}
+
+// stop closes the child's stdin and sends it SIGTERM, killing it if it has
+// not exited within subprocessExitTimeout. A child that has already exited,
+// or exits once its stdin is closed, is not signalled.
+func (c *childProcess) stop() {
+	c.conn.Close()
+	c.stdin.Close()
+	select {
+	case <-c.exited:
+	case <-time.After(subprocessStdinGrace):
+		c.cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-c.exited:
+		case <-time.After(subprocessExitTimeout):
+			c.cmd.Process.Kill()
+			<-c.exited
+		}
+	}
+	c.stdout.Close()
+}
+
+// discardHandler ignores anything a child process sends unprompted.
+type discardHandler struct{}
+
+func (discardHandler) Handle(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) {}
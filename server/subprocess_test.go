@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startSlowChildServer starts a server forwarding mcp.processModel to the
+// slowchild test program, returning it with a client and the command of its
+// first child process
+func startSlowChildServer(t *testing.T, options GroupOptions) (*Server, *client.Client, *exec.Cmd) {
+	options.Isolation = Subprocess
+	options.Command = exec.Command(buildStdioServer(t, "./testdata/slowchild"))
+	srv, clients := startGroupServer(t, 1, func(srv *Server) {
+		group := NewHandlerGroup("isolated", options)
+		require.NoError(t, srv.RegisterHandlerInGroup(group, NewDefaultModelHandler()), "Grouped handler registration should succeed")
+	})
+	return srv, clients[0], options.Command
+}
+
+// childRequest returns a request the slowchild program handles with the
+// given model data
+func childRequest(key string, value interface{}) *core.ModelRequest {
+	req := testutil.CreateTestModelRequest()
+	req.ModelData[key] = value
+	return req
+}
+
+// processSlowly sends a request the child takes sleep to answer, returning
+// once the child is handling it
+func processSlowly(t *testing.T, ctx context.Context, srv *Server, c *client.Client, sleep string) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.ProcessModel(ctx, childRequest("sleep", sleep))
+		result <- err
+	}()
+	require.Eventually(t, func() bool {
+		inFlight := srv.InFlightRequests()
+		return len(inFlight) == 1 && inFlight[0].State == RequestHandlerRunning
+	}, 2*time.Second, 5*time.Millisecond, "Request should reach the child")
+	time.Sleep(50 * time.Millisecond)
+	return result
+}
+
+func TestSubprocessGracefulDrain(t *testing.T) {
+	srv, c, cmd := startSlowChildServer(t, GroupOptions{DrainTimeout: 5 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Start the child before timing the drain
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request should be served by the child process")
+	result := processSlowly(t, ctx, srv, c, "300ms")
+
+	// Stop waits for the child to answer, and the child exits without a signal
+	start := time.Now()
+	require.NoError(t, srv.Stop(), "Server should stop")
+	elapsed := time.Since(start)
+	assert.NoError(t, <-result, "Request in flight should be answered during the drain")
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond, "Stop should wait for the request")
+	assert.Less(t, elapsed, 2*time.Second, "Stop should not wait for the drain timeout")
+	require.NotNil(t, cmd.ProcessState, "Child should have exited")
+	assert.Equal(t, 0, cmd.ProcessState.ExitCode(), "Child should exit cleanly")
+}
+
+func TestSubprocessDrainTimeout(t *testing.T) {
+	srv, c, cmd := startSlowChildServer(t, GroupOptions{DrainTimeout: 200 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Request should be served by the child process")
+	result := processSlowly(t, ctx, srv, c, "10s")
+
+	// Once the drain times out the child is stopped under the request
+	start := time.Now()
+	require.NoError(t, srv.Stop(), "Server should stop")
+	elapsed := time.Since(start)
+	assert.Error(t, <-result, "Request outlasting the drain should fail")
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond, "Stop should wait out the drain timeout")
+	assert.Less(t, elapsed, 3*time.Second, "Stop should not wait for the request")
+	assert.NotNil(t, cmd.ProcessState, "Child should have exited")
+}
+
+func TestSubprocessRestartBudget(t *testing.T) {
+	srv, c, _ := startSlowChildServer(t, GroupOptions{MaxRestarts: 1, RestartWindow: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	crash := func() {
+		_, err := c.ProcessModel(ctx, childRequest("exit", true))
+		require.Error(t, err, "Request crashing the child should fail")
+	}
+
+	// A crashed child is replaced once within the budget
+	crash()
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Restarted child should serve requests")
+	assert.NotNil(t, resp.Results["pid"], "Restarted child should answer")
+	assert.NoError(t, srv.ready(), "Group within its budget should be ready")
+
+	// A second crash exhausts the budget
+	crash()
+	group := srv.handlerGroups()[0]
+	require.Eventually(t, func() bool { return group.State() == GroupUnready }, 2*time.Second, 5*time.Millisecond,
+		"Group should be unready once the budget is exhausted")
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requireCode(t, err, core.CodeServerBusy, "Unready group should refuse requests")
+	assert.Error(t, srv.ready(), "Server with an unready group should not be ready")
+	assert.Equal(t, core.HealthDegraded, srv.Health().Status, "Unready group should degrade health")
+	assert.Equal(t, GroupUnready, srv.Stats().Groups["isolated"].State, "Stats should report the group unready")
+}
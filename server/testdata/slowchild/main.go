@@ -0,0 +1,53 @@
+// Command slowchild serves mcp.processModel over stdio for the subprocess
+// tests. A request's "sleep" model data delays the reply by that duration,
+// regardless of cancellation, and "exit" makes the process exit at once.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/server"
+)
+
+// slowHandler sleeps or exits as the request asks.
+type slowHandler struct{}
+
+func (slowHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (slowHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if exit, _ := req.ModelData["exit"].(bool); exit {
+		os.Exit(1)
+	}
+	if sleep, ok := req.ModelData["sleep"].(string); ok {
+		d, err := time.ParseDuration(sleep)
+		if err != nil {
+			return nil, err
+		}
+		time.Sleep(d)
+	}
+	resp := core.NewModelResponse(req)
+	resp.Results["pid"] = os.Getpid()
+	return resp, nil
+}
+
+func main() {
+	srv := server.New(server.WithSubprocessMode(true), server.WithLogger(core.NopLogger()))
+	if err := srv.RegisterHandler(slowHandler{}); err != nil {
+		log.Fatalf("Failed to register handler: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	if err := srv.ServeStdio(ctx); err != nil && err != context.Canceled {
+		log.Fatalf("Failed to serve stdio: %v", err)
+	}
+}
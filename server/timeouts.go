@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handlerTimeoutGrace is how long a handler whose timeout has passed is given
+// to return before it is abandoned as stuck.
+const handlerTimeoutGrace = 100 * time.Millisecond
+
+// States of a handler run by withHandlerTimeout.
+const (
+	handlerRunning int32 = iota
+	handlerReturned
+	handlerAbandoned
+)
+
+// handlerTimeout returns how long a handler for method may run, or zero if
+// it is not limited.
+func (s *Server) handlerTimeout(method string) time.Duration {
+	if timeout, ok := s.options.MethodTimeouts[method]; ok {
+		return timeout
+	}
+	return s.options.HandlerTimeout
+}
+
+// withHandlerTimeout runs process for the request with the given ID to
+// method, failing with a core.CodeDeadlineExceeded error once the method's
+// handler timeout has passed. The context of process ends then with
+// core.CauseDeadline. A handler that has not returned handlerTimeoutGrace
+// later is left running and its result discarded; it is counted in
+// Stats().StuckHandlers until it returns.
+func withHandlerTimeout[T any](s *Server, ctx context.Context, method, id string, process func(context.Context) (T, error)) (T, error) {
+	timeout := s.handlerTimeout(method)
+	if timeout <= 0 {
+		return process(ctx)
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, core.CauseDeadline)
+	timedOut := func() bool {
+		return errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	var state atomic.Int32
+	s.tasks.Go(core.TaskTimeouts, func() {
+		defer cancel()
+		var r result
+		func() {
+			defer func() {
+				if v := recover(); v != nil {
+					r.err = s.handlePanic(method, v, core.LogFieldRequestID, id)
+				}
+			}()
+			r.value, r.err = process(ctx)
+		}()
+		if !state.CompareAndSwap(handlerRunning, handlerReturned) {
+			atomic.AddInt64(&s.stuckHandlers, -1)
+			s.options.Logger.Info("Abandoned handler returned",
+				core.LogFieldMethod, method,
+				core.LogFieldRequestID, id)
+		}
+		done <- r
+	})
+
+	select {
+	case r := <-done:
+		if !timedOut() {
+			return r.value, r.err
+		}
+		s.handlerTimedOut(method, id, false)
+	case <-ctx.Done():
+		if !timedOut() {
+			// Cancelled for another reason, which the handler answers
+			r := <-done
+			return r.value, r.err
+		}
+		grace := s.tasks.NewTimer(core.TaskTimeouts, handlerTimeoutGrace)
+		select {
+		case <-done:
+			s.handlerTimedOut(method, id, false)
+		case <-grace.C:
+			stuck := state.CompareAndSwap(handlerRunning, handlerAbandoned)
+			if stuck {
+				atomic.AddInt64(&s.stuckHandlers, 1)
+			}
+			s.handlerTimedOut(method, id, stuck)
+		}
+		grace.Stop()
+	}
+
+	var zero T
+	return zero, &jsonrpc2.Error{
+		Code:    core.CodeDeadlineExceeded,
+		Message: fmt.Sprintf("handler timed out after %s", timeout),
+	}
+}
+
+// handlerTimedOut logs, counts and reports a handler that ran past its
+// timeout, stuck if it was abandoned still running.
+func (s *Server) handlerTimedOut(method, id string, stuck bool) {
+	if stuck {
+		s.options.Logger.Warn("Handler ignored its timeout and was abandoned",
+			core.LogFieldMethod, method,
+			core.LogFieldRequestID, id)
+	} else {
+		s.options.Logger.Debug("Handler timed out",
+			core.LogFieldMethod, method,
+			core.LogFieldRequestID, id)
+	}
+
+	atomic.AddUint64(&s.handlerTimeouts, 1)
+	if collector, ok := s.options.Metrics.(core.TimeoutCollector); ok {
+		s.sinks.Do(sinkMetrics, func() error {
+			collector.HandlerTimedOut(method, stuck)
+			return nil
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timeoutMetrics records the handler timeouts reported to it
+type timeoutMetrics struct {
+	core.MetricsCollector
+	mu    sync.Mutex
+	stuck []bool
+}
+
+func (m *timeoutMetrics) HandlerTimedOut(method string, stuck bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stuck = append(m.stuck, stuck)
+}
+
+func (m *timeoutMetrics) reported() []bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]bool(nil), m.stuck...)
+}
+
+// DeafModelHandler ignores its context, returning only once released
+type DeafModelHandler struct {
+	release chan struct{}
+}
+
+func (h *DeafModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *DeafModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	<-h.release
+	return core.NewModelResponse(req), nil
+}
+
+func TestHandlerTimeoutCancelsHandler(t *testing.T) {
+	handler := newCauseModelHandler()
+	collector := &timeoutMetrics{MetricsCollector: core.NopMetrics()}
+	srv, c := startJobServer(t, handler, WithLogger(core.NopLogger()), WithMetrics(collector),
+		WithHandlerTimeout(50*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requireCode(t, err, core.CodeDeadlineExceeded, "A handler running past its timeout should fail with CodeDeadlineExceeded")
+	handler.requireCause(t, core.CauseDeadline, "The handler's context should end at its timeout")
+
+	stats := srv.Stats()
+	assert.Equal(t, uint64(1), stats.HandlerTimeouts, "The timeout should be counted")
+	assert.Zero(t, stats.StuckHandlers, "A handler honouring its context should not be stuck")
+	assert.Equal(t, []bool{false}, collector.reported(), "The timeout should be reported, not stuck")
+}
+
+func TestHandlerTimeoutAbandonsStuckHandler(t *testing.T) {
+	handler := &DeafModelHandler{release: make(chan struct{})}
+	collector := &timeoutMetrics{MetricsCollector: core.NopMetrics()}
+	srv, c := startJobServer(t, handler, WithLogger(core.NopLogger()), WithMetrics(collector),
+		WithHandlerTimeout(50*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	requireCode(t, err, core.CodeDeadlineExceeded, "A stuck handler's request should fail with CodeDeadlineExceeded")
+	assert.Less(t, time.Since(start), time.Second, "The client should not wait for a stuck handler")
+
+	stats := srv.Stats()
+	assert.Equal(t, uint64(1), stats.HandlerTimeouts, "The timeout should be counted")
+	assert.Equal(t, 1, stats.StuckHandlers, "The abandoned handler should be counted as stuck")
+	assert.Equal(t, []bool{true}, collector.reported(), "The timeout should be reported as stuck")
+	assert.Equal(t, 1, stats.Tasks[core.TaskTimeouts].Goroutines, "The abandoned handler should run as a timeouts task")
+	assert.Zero(t, stats.Tasks[core.TaskJobs].Goroutines, "Handler timeouts should not be counted as jobs")
+
+	// The connection goes on serving while the handler is stuck
+	_, err = c.Ping(ctx)
+	assert.NoError(t, err, "The connection should stay usable")
+
+	close(handler.release)
+	require.Eventually(t, func() bool { return srv.Stats().StuckHandlers == 0 }, 2*time.Second, 5*time.Millisecond,
+		"A stuck handler should stop being counted once it returns")
+}
+
+func TestMethodTimeouts(t *testing.T) {
+	srv, c := startJobServer(t, &SlowModelHandler{delay: 100 * time.Millisecond}, WithLogger(core.NopLogger()),
+		WithHandlerTimeout(20*time.Millisecond),
+		WithMethodTimeouts(map[string]time.Duration{core.MethodProcessModel: time.Second}))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "A method's own timeout should override the handler timeout")
+	assert.True(t, resp.Success, "The response should come from the handler")
+	assert.Zero(t, srv.Stats().HandlerTimeouts, "No timeout should be counted")
+}
+
+func TestHandlerTimeoutBatchItems(t *testing.T) {
+	srv, c := startServerWithHandler(t, &SleepyModelHandler{}, WithLogger(core.NopLogger()),
+		WithHandlerTimeout(50*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.ProcessBatch(ctx, newBatch(2, time.Second))
+	require.NoError(t, err, "ProcessBatch should succeed")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "The slow item should not hold up the batch")
+	require.Len(t, resp.Responses, 2, "Every item should get a response")
+	assert.False(t, resp.Responses[0].Success, "The item running past the handler timeout should fail")
+	assert.Contains(t, resp.Responses[0].ErrorMessage, "handler timed out", "The item should carry the timeout error")
+	assert.False(t, resp.Timings[0].DeadlineExceeded, "The handler timeout is not the item's batch deadline")
+	assert.True(t, resp.Responses[1].Success, "The quick item should succeed")
+	assert.Equal(t, uint64(1), srv.Stats().HandlerTimeouts, "The timeout should be counted")
+}
+
+func TestHandlerTimeoutTools(t *testing.T) {
+	c, srv := testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()),
+				WithHandlerTimeout(time.Second),
+				WithMethodTimeouts(map[string]time.Duration{core.MethodCallTool: 50 * time.Millisecond}))
+			require.NoError(t, srv.RegisterTool("wait", "", nil, func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}), "Tool registration should succeed")
+			return srv
+		},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := c.CallTool(ctx, "wait", nil, nil)
+	requireCode(t, err, core.CodeDeadlineExceeded, "A tool running past its timeout should fail with CodeDeadlineExceeded")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "The tool should be held to the timeout of its method")
+	assert.Equal(t, uint64(1), srv.Stats().HandlerTimeouts, "The timeout should be counted")
+}
+
+func TestHandlerTimeoutJobs(t *testing.T) {
+	handler := newCauseModelHandler()
+	srv, c := startJobServer(t, handler, WithLogger(core.NopLogger()),
+		WithHandlerTimeout(time.Second),
+		WithMethodTimeouts(map[string]time.Duration{core.MethodSubmitModel: 50 * time.Millisecond}))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	id, err := c.SubmitModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "Submitting should succeed")
+	status, err := c.WaitForJob(ctx, id)
+	require.NoError(t, err, "Waiting for the job should succeed")
+	require.NotNil(t, status.Response, "The finished job should carry a response")
+	assert.False(t, status.Response.Success, "A job running past its timeout should fail")
+	assert.Contains(t, status.Response.ErrorMessage, "handler timed out", "The job should carry the timeout error")
+	handler.requireCause(t, core.CauseDeadline, "The job's context should end at its timeout")
+	assert.Equal(t, uint64(1), srv.Stats().HandlerTimeouts, "The timeout should be counted")
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ToolFunc runs a tool registered with RegisterTool. It receives the
+// arguments of the call as sent, and returns a result encodable as JSON.
+// Returning a *core.ModelError fails the call with CodeModelError; any other
+// error fails it as an internal error.
+type ToolFunc func(ctx context.Context, args json.RawMessage) (interface{}, error)
+
+// tool is a registered tool.
+type tool struct {
+	info core.ToolInfo
+	fn   ToolFunc
+}
+
+// toolRegistry holds the tools registered with a server by name.
+type toolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*tool
+}
+
+// RegisterTool registers fn as the tool name, listed by mcp.listTools with
+// desc and inputSchema and run by mcp.callTool. The input schema is any
+// value encodable as a JSON Schema, such as a *core.Schema, a map or a
+// json.RawMessage, or nil; it is published for clients and not enforced.
+// Returns an error if a tool of the same name is already registered. Tools
+// may be registered while the server is running.
+func (s *Server) RegisterTool(name, desc string, inputSchema interface{}, fn ToolFunc) error {
+	if name == "" {
+		return errors.New("tool name must not be empty")
+	}
+	if fn == nil {
+		return fmt.Errorf("tool %s has no function", name)
+	}
+	info := core.ToolInfo{Name: name, Description: desc}
+	if inputSchema != nil {
+		schema, err := json.Marshal(inputSchema)
+		if err != nil {
+			return fmt.Errorf("failed to encode input schema of tool %s: %w", name, err)
+		}
+		info.InputSchema = schema
+	}
+
+	s.tools.mu.Lock()
+	defer s.tools.mu.Unlock()
+	if _, exists := s.tools.tools[name]; exists {
+		return fmt.Errorf("tool %s already registered", name)
+	}
+	if s.tools.tools == nil {
+		s.tools.tools = make(map[string]*tool)
+	}
+	s.tools.tools[name] = &tool{info: info, fn: fn}
+	return nil
+}
+
+// UnregisterTool removes the tool name. Calls already running complete.
+func (s *Server) UnregisterTool(name string) error {
+	s.tools.mu.Lock()
+	defer s.tools.mu.Unlock()
+	if _, ok := s.tools.tools[name]; !ok {
+		return fmt.Errorf("no tool registered as %s", name)
+	}
+	delete(s.tools.tools, name)
+	return nil
+}
+
+// Tools returns the descriptions of the registered tools, sorted by name.
+func (s *Server) Tools() []core.ToolInfo {
+	s.tools.mu.RLock()
+	defer s.tools.mu.RUnlock()
+	infos := make([]core.ToolInfo, 0, len(s.tools.tools))
+	for _, t := range s.tools.tools {
+		infos = append(infos, t.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// tool returns the tool registered as name.
+func (r *toolRegistry) tool(name string) (*tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// handleCallTool answers mcp.callTool, running the tool in a handler slot
+// and under the handler timeout like any model request. A panicking tool is
+// recovered by dispatch.
+func (h *rpcHandler) handleCallTool(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	var params core.CallToolRequest
+	if req.Params == nil || json.Unmarshal(*req.Params, &params) != nil || params.Name == "" {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing tool name")
+		return
+	}
+	t, ok := h.server.tools.tool(params.Name)
+	if !ok {
+		h.replyError(ctx, conn, req, core.CodeToolNotFound, fmt.Sprintf("tool not found: %s", params.Name))
+		return
+	}
+
+	if !h.server.pool.acquire(ctx) {
+		h.replyError(ctx, conn, req, core.CodeServerBusy, "server busy, too many requests in flight")
+		return
+	}
+	defer h.server.pool.release()
+
+	result, err := withHandlerTimeout(h.server, ctx, req.Method, req.ID.String(), func(ctx context.Context) (interface{}, error) {
+		return t.fn(ctx, params.Args)
+	})
+	if err != nil {
+		h.replyProcessError(ctx, conn, req, err)
+		return
+	}
+	h.reply(ctx, conn, req, result)
+}
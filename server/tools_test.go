@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type addArgs struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type addResult struct {
+	Sum int `json:"sum"`
+}
+
+// startToolPair starts a server offering an add and an echo tool, and a
+// client connected to it
+func startToolPair(t *testing.T) (*client.Client, *Server) {
+	return testutil.StartInProcessPair(t,
+		func(transport core.Transport) *client.Client {
+			return client.New(client.WithTransport(transport), client.WithAutoReconnect(false))
+		},
+		func(transport core.Transport) *Server {
+			srv := New(WithTransport(transport), WithLogger(core.NopLogger()))
+			schema := &core.Schema{
+				Type:     "object",
+				Required: []string{"a", "b"},
+				Properties: map[string]*core.Schema{
+					"a": {Type: "integer"},
+					"b": {Type: "integer"},
+				},
+			}
+			require.NoError(t, srv.RegisterTool("add", "Adds two integers", schema,
+				func(_ context.Context, raw json.RawMessage) (interface{}, error) {
+					var args addArgs
+					if err := json.Unmarshal(raw, &args); err != nil {
+						return nil, core.NewModelError(core.ErrInvalidParameter, err)
+					}
+					return addResult{Sum: args.A + args.B}, nil
+				}), "Tool registration should succeed")
+			require.NoError(t, srv.RegisterTool("echo", "Returns its arguments", nil,
+				func(_ context.Context, raw json.RawMessage) (interface{}, error) {
+					return raw, nil
+				}), "Tool registration should succeed")
+			return srv
+		},
+	)
+}
+
+func TestListTools(t *testing.T) {
+	c, _ := startToolPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	infos, err := c.ListTools(ctx)
+	require.NoError(t, err, "Listing tools should succeed")
+	require.Len(t, infos, 2, "Both tools should be listed")
+	assert.Equal(t, "add", infos[0].Name, "Tools should be sorted by name")
+	assert.Equal(t, "Adds two integers", infos[0].Description, "Description should be listed")
+	assert.JSONEq(t, `{"type":"object","required":["a","b"],"properties":{"a":{"type":"integer"},"b":{"type":"integer"}}}`,
+		string(infos[0].InputSchema), "Input schema should be listed")
+	assert.Equal(t, "echo", infos[1].Name, "Second tool should be listed")
+	assert.Empty(t, infos[1].InputSchema, "Tool without a schema should list none")
+}
+
+func TestCallTool(t *testing.T) {
+	c, _ := startToolPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var result addResult
+	require.NoError(t, c.CallTool(ctx, "add", addArgs{A: 2, B: 3}, &result), "Call should succeed")
+	assert.Equal(t, 5, result.Sum, "Tool result should be decoded")
+
+	var echoed map[string]interface{}
+	require.NoError(t, c.CallTool(ctx, "echo", map[string]string{"word": "hello"}, &echoed), "Call should succeed")
+	assert.Equal(t, map[string]interface{}{"word": "hello"}, echoed, "Arguments should reach the tool as sent")
+	assert.NoError(t, c.CallTool(ctx, "echo", nil, nil), "Call without arguments or result should succeed")
+
+	// Errors from the tool reach the caller
+	err := c.CallTool(ctx, "add", "not an object", &result)
+	var modelErr *core.ModelError
+	require.ErrorAs(t, err, &modelErr, "Tool error should be returned")
+	assert.Equal(t, core.ErrInvalidParameter, modelErr.Code, "Tool error should keep its code")
+}
+
+func TestCallUnknownTool(t *testing.T) {
+	c, srv := startToolPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := c.CallTool(ctx, "subtract", addArgs{A: 2, B: 3}, nil)
+	assert.ErrorIs(t, err, core.ErrToolNotFound, "Unknown tool should fail with ErrToolNotFound")
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Unknown tool should fail with a JSON-RPC error")
+	assert.Equal(t, core.CodeToolNotFound, rpcErr.Code, "Unknown tool should use the tool not found code")
+
+	// Unregistered tools are unknown too
+	require.NoError(t, srv.UnregisterTool("echo"), "Unregistering should succeed")
+	assert.ErrorIs(t, c.CallTool(ctx, "echo", nil, nil), core.ErrToolNotFound, "Unregistered tool should be unknown")
+	assert.Error(t, srv.UnregisterTool("echo"), "Unregistering twice should fail")
+}
+
+func TestCallToolPanic(t *testing.T) {
+	c, srv := startToolPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	require.NoError(t, srv.RegisterTool("explode", "", nil, func(context.Context, json.RawMessage) (interface{}, error) {
+		panic("boom")
+	}), "Tool registration should succeed")
+
+	err := c.CallTool(ctx, "explode", nil, nil)
+	var rpcErr *jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr, "Panicking tool should fail the call")
+	assert.Equal(t, int64(jsonrpc2.CodeInternalError), rpcErr.Code, "Panic should be reported as an internal error")
+	assert.NotContains(t, rpcErr.Message, "boom", "Panic value should not be revealed")
+
+	var result addResult
+	assert.NoError(t, c.CallTool(ctx, "add", addArgs{A: 1, B: 1}, &result), "Server should keep serving after a panic")
+}
+
+func TestRegisterTool(t *testing.T) {
+	srv := New()
+	noop := func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil }
+
+	require.NoError(t, srv.RegisterTool("noop", "", nil, noop), "Registration should succeed")
+	assert.Error(t, srv.RegisterTool("noop", "", nil, noop), "Duplicate name should be rejected")
+	assert.Error(t, srv.RegisterTool("", "", nil, noop), "Empty name should be rejected")
+	assert.Error(t, srv.RegisterTool("nil", "", nil, nil), "Missing function should be rejected")
+	assert.Error(t, srv.RegisterTool("bad", "", make(chan int), noop), "Unencodable schema should be rejected")
+	assert.Len(t, srv.Tools(), 1, "Only the valid tool should be registered")
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// subscriptions holds the topics a connection is subscribed to.
+type subscriptions struct {
+	mu     sync.Mutex
+	topics map[string]subscription
+}
+
+// subscription is a connection's subscription to a topic.
+type subscription struct {
+	filter  *core.Filter // nil to receive every notification
+	durable string       // ID of the durable subscription delivering the topic's notifications; empty for a plain one
+}
+
+func (s *subscriptions) add(topic string, sub subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.topics == nil {
+		s.topics = make(map[string]subscription)
+	}
+	s.topics[topic] = sub
+}
+
+func (s *subscriptions) remove(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.topics, topic)
+}
+
+// get returns the subscription to topic and whether there is one.
+func (s *subscriptions) get(topic string) (subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.topics[topic]
+	return sub, ok
+}
+
+// list returns the subscribed topics, sorted.
+func (s *subscriptions) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topics := make([]string, 0, len(s.topics))
+	for topic := range s.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// filters returns the expressions of the filtered subscriptions by topic, or
+// nil if there are none.
+func (s *subscriptions) filters() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var filters map[string]string
+	for topic, sub := range s.topics {
+		if sub.filter == nil {
+			continue
+		}
+		if filters == nil {
+			filters = make(map[string]string)
+		}
+		filters[topic] = sub.filter.String()
+	}
+	return filters
+}
+
+// PublishTopic sends a notification to every client subscribed to topic with
+// mcp.subscribe whose subscription's filter, if any, the payload passes.
+// Notifications listed in WithOrderedNotifications reach each client only
+// after any reply it is waiting for has been written. Durable subscriptions
+// no connection holds buffer the notification instead. It returns an error
+// if the notification is not registered or could not be sent to some
+// subscribers.
+func PublishTopic[T any](s *Server, topic string, n core.Notification[T], payload T) error {
+	if err := n.Check(); err != nil {
+		return err
+	}
+
+	// The payload is decoded for filters once, when the first is met
+	var decoded interface{}
+	var decodeErr error
+	var isDecoded bool
+	decode := func() (interface{}, error) {
+		if !isDecoded {
+			decoded, decodeErr = decodePayload(payload)
+			isDecoded = true
+		}
+		return decoded, decodeErr
+	}
+
+	err := publish(s, n, payload, func(h *rpcHandler) bool {
+		sub, subscribed := h.subscriptions.get(topic)
+		if !subscribed || sub.durable != "" {
+			return false
+		}
+		if sub.filter == nil {
+			return true
+		}
+		decoded, err := decode()
+		return err == nil && sub.filter.Match(decoded)
+	})
+	return errors.Join(err, s.durable.publish(s.runContext(), topic, n.Method(), payload, decode))
+}
+
+// decodePayload returns payload in the form filters match against: decoded
+// from its JSON encoding into interface{}.
+func decodePayload(payload interface{}) (interface{}, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(data, &decoded)
+	return decoded, err
+}
+
+// handleSubscribe answers mcp.subscribe and mcp.unsubscribe.
+func (h *rpcHandler) handleSubscribe(ctx context.Context, conn rpcConn, req *jsonrpc2.Request) {
+	var subReq core.SubscribeRequest
+	if req.Params == nil || json.Unmarshal(*req.Params, &subReq) != nil || subReq.Topic == "" {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: missing topic")
+		return
+	}
+
+	if req.Method == core.MethodUnsubscribe {
+		if sub, ok := h.subscriptions.get(subReq.Topic); ok && sub.durable != "" {
+			h.server.durable.remove(sub.durable)
+		}
+		h.subscriptions.remove(subReq.Topic)
+		h.reply(ctx, conn, req, struct{}{})
+		return
+	}
+	if subReq.Durable && subReq.SubscriptionID == "" {
+		h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: durable subscriptions need a subscription ID")
+		return
+	}
+
+	var filter *core.Filter
+	if subReq.Filter != "" {
+		var err error
+		if filter, err = core.ParseFilter(subReq.Filter); err != nil {
+			h.replyError(ctx, conn, req, jsonrpc2.CodeInvalidParams, "invalid params: invalid filter: "+err.Error())
+			return
+		}
+	}
+
+	sub := subscription{filter: filter}
+	if subReq.Durable {
+		// Missed notifications are replayed before the reply, and before
+		// any published later
+		if err := h.server.durable.attach(ctx, h, conn, subReq, filter); err != nil {
+			code := int64(jsonrpc2.CodeInternalError)
+			if errors.Is(err, errDurableTopic) {
+				code = jsonrpc2.CodeInvalidParams
+			}
+			h.replyError(ctx, conn, req, code, err.Error())
+			return
+		}
+		sub.durable = subReq.SubscriptionID
+	} else if prev, ok := h.subscriptions.get(subReq.Topic); ok && prev.durable != "" {
+		// A plain subscription replaces the durable one
+		h.server.durable.remove(prev.durable)
+	}
+	h.subscriptions.add(subReq.Topic, sub)
+	h.reply(ctx, conn, req, struct{}{})
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishTopic(t *testing.T) {
+	srv, transport := startWatchServer(t, NewDefaultModelHandler())
+	subscriber, other := startWatchClient(t, transport), startWatchClient(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	received := make(chan chatMessage, 4)
+	client.OnNotificationTyped(subscriber, chatNotification, func(ctx context.Context, msg chatMessage) { received <- msg })
+	leaked := make(chan chatMessage, 4)
+	client.OnNotificationTyped(other, chatNotification, func(ctx context.Context, msg chatMessage) { leaked <- msg })
+
+	require.NoError(t, subscriber.Subscribe(ctx, "rooms/general"), "Subscribing should succeed")
+	require.NoError(t, subscriber.Subscribe(ctx, "rooms/general"), "Subscribing twice should succeed")
+	require.NoError(t, PublishTopic(srv, "rooms/general", chatNotification, chatMessage{Text: "hello"}), "Publishing should succeed")
+	require.NoError(t, PublishTopic(srv, "rooms/random", chatNotification, chatMessage{Text: "elsewhere"}), "Publishing without subscribers should succeed")
+	assert.Equal(t, "hello", receive(t, received, "topic notification").Text, "Subscriber should receive the notification")
+
+	// Both subscriptions must end before the server stops publishing
+	require.NoError(t, subscriber.Unsubscribe(ctx, "rooms/general"), "Unsubscribing should succeed")
+	require.NoError(t, PublishTopic(srv, "rooms/general", chatNotification, chatMessage{Text: "still here"}), "Publishing should succeed")
+	assert.Equal(t, "still here", receive(t, received, "topic notification").Text, "Remaining subscription should still receive")
+	require.NoError(t, subscriber.Unsubscribe(ctx, "rooms/general"), "Unsubscribing should succeed")
+	require.NoError(t, PublishTopic(srv, "rooms/general", chatNotification, chatMessage{Text: "gone"}), "Publishing should succeed")
+
+	select {
+	case msg := <-received:
+		t.Fatalf("Unsubscribed client received %q", msg.Text)
+	case msg := <-leaked:
+		t.Fatalf("Client without a subscription received %q", msg.Text)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// jobEvent is a structured topic payload for filter tests
+type jobEvent struct {
+	Seq    int    `json:"seq"`
+	Status string `json:"status"`
+	Tenant string `json:"tenant"`
+}
+
+var jobEventNotification = core.RegisterNotification[jobEvent]("test.jobEvent")
+
+func TestPublishTopicFilters(t *testing.T) {
+	srv, transport := startWatchServer(t, NewDefaultModelHandler())
+	failures, acme := startWatchClient(t, transport), startWatchClient(t, transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	failed := make(chan jobEvent, 16)
+	client.OnNotificationTyped(failures, jobEventNotification, func(ctx context.Context, e jobEvent) { failed <- e })
+	tenant := make(chan jobEvent, 16)
+	client.OnNotificationTyped(acme, jobEventNotification, func(ctx context.Context, e jobEvent) { tenant <- e })
+
+	require.NoError(t, failures.Subscribe(ctx, "jobs", client.WithSubscribeFilter(`status == "failed"`)), "Filtered subscription should succeed")
+	require.NoError(t, acme.Subscribe(ctx, "jobs", client.WithSubscribeFilter(`tenant == "acme" AND (status == "done" OR seq > 6)`)),
+		"Filtered subscription should succeed")
+
+	assert.Error(t, failures.Subscribe(ctx, "jobs", client.WithSubscribeFilter(`status == "done"`)),
+		"Subscribing again with another filter should be refused")
+	assert.Error(t, failures.Subscribe(ctx, "other", client.WithSubscribeFilter(`status ==`)), "Invalid filters should be refused")
+
+	// The server refuses invalid filters itself too
+	err := dialRaw(t, transport).Call(ctx, core.MethodSubscribe, core.SubscribeRequest{Topic: "jobs", Filter: `status = "x"`}, &struct{}{})
+	requireCode(t, err, jsonrpc2.CodeInvalidParams, "Server should refuse an invalid filter")
+	assert.Contains(t, err.Error(), `invalid filter: offset 7: unknown operator "="`, "Error should say what is wrong with the filter")
+
+	events := []jobEvent{
+		{1, "running", "acme"}, {2, "failed", "acme"}, {3, "done", "acme"}, {4, "failed", "globex"},
+		{5, "done", "globex"}, {6, "running", "globex"}, {7, "running", "acme"}, {8, "failed", "acme"},
+	}
+	for _, e := range events {
+		require.NoError(t, PublishTopic(srv, "jobs", jobEventNotification, e), "Publishing should succeed")
+	}
+
+	// Client handlers run on their own goroutines, so they may see the events
+	// in any order
+	collect := func(ch <-chan jobEvent, n int) []int {
+		var seqs []int
+		for i := 0; i < n; i++ {
+			seqs = append(seqs, receive(t, ch, "filtered notification").Seq)
+		}
+		select {
+		case e := <-ch:
+			t.Fatalf("Unexpected notification %d", e.Seq)
+		case <-time.After(100 * time.Millisecond):
+		}
+		sort.Ints(seqs)
+		return seqs
+	}
+	assert.Equal(t, []int{2, 4, 8}, collect(failed, 3), "Subscriber should receive only failures")
+	assert.Equal(t, []int{3, 7, 8}, collect(tenant, 3), "Subscriber should receive only its tenant's matching events")
+
+	var filters []map[string]string
+	for _, conn := range srv.Connections() {
+		if conn.Filters != nil {
+			filters = append(filters, conn.Filters)
+		}
+	}
+	assert.ElementsMatch(t, []map[string]string{
+		{"jobs": `status == "failed"`},
+		{"jobs": `tenant == "acme" AND (status == "done" OR seq > 6)`},
+	}, filters, "Connections should list each subscription's filter")
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/core/tools"
+)
+
+// errInvalidResponse replaces a handler response that failed validation.
+var errInvalidResponse = errors.New("handler returned an invalid response")
+
+// ResponseValidator can be implemented by a model handler to add its own checks
+// on outgoing responses, e.g. on the shape of Results. It is only consulted
+// when response validation is enabled.
+type ResponseValidator interface {
+	ValidateResponse(req *core.ModelRequest, resp *core.ModelResponse) error
+}
+
+// validateResponse checks that a handler's response is coherent with the
+// request it answers, that its Metadata keeps within the annotation limits in
+// options and, if desc is not nil, that its Results match the declared result
+// schema.
+func validateResponse(options *Options, handler Handler, desc *core.MethodDescription, req *core.ModelRequest, resp *core.ModelResponse) *tools.ValidationResult {
+	result := tools.NewValidationResult()
+
+	if resp == nil {
+		result.AddError("response", "cannot be nil")
+		return result
+	}
+	if resp.ID != req.ID {
+		result.AddError("id", "must match the request ID "+req.ID)
+	}
+	if resp.Success && resp.ErrorMessage != "" {
+		result.AddError("errorMessage", "must be empty when success is true")
+	}
+	if !resp.Success && resp.ErrorMessage == "" {
+		result.AddError("errorMessage", "is required when success is false")
+	}
+	if resp.Results == nil {
+		result.AddError("results", "cannot be nil")
+	}
+	if limit := options.MaxAnnotations; limit > 0 && len(resp.Metadata) > limit {
+		result.AddError("metadata", fmt.Sprintf("must have at most %d annotations, got %d", limit, len(resp.Metadata)))
+	}
+	if limit := options.MaxAnnotationBytes; limit > 0 {
+		size := 0
+		for key, value := range resp.Metadata {
+			size += len(key) + len(value)
+		}
+		if size > limit {
+			result.AddError("metadata", fmt.Sprintf("must be at most %d bytes, got %d", limit, size))
+		}
+	}
+
+	if desc != nil {
+		for _, violation := range desc.ValidateResults(resp).Errors {
+			result.AddError(violation.Field, violation.Message)
+		}
+	}
+
+	if validator, ok := handler.(ResponseValidator); ok {
+		if err := validator.ValidateResponse(req, resp); err != nil {
+			result.AddError("response", err.Error())
+		}
+	}
+
+	return result
+}
+
+// checkResponse validates a response before it is sent when response
+// validation is enabled, including against the result schema the handler
+// declares for method. Violations are logged with the handler named; the
+// returned error is non-nil if the response must be replaced rather than sent.
+func (s *Server) checkResponse(method string, handler Handler, req *core.ModelRequest, resp *core.ModelResponse) error {
+	if !s.options.ResponseValidation {
+		return nil
+	}
+
+	// Batch items are mcp.processModel requests and declared as such
+	described := method
+	if method == core.MethodProcessModelBatch {
+		described = core.MethodProcessModel
+	}
+	var desc *core.MethodDescription
+	if d, ok := s.description(described); ok {
+		desc = &d
+	}
+
+	result := validateResponse(&s.options, handler, desc, req, resp)
+	if result.Valid {
+		return nil
+	}
+
+	s.options.Logger.Error("Invalid response from handler",
+		"handler", fmt.Sprintf("%T", handler),
+		core.LogFieldMethod, method,
+		core.LogFieldRequestID, req.ID,
+		core.LogFieldError, result.Error())
+	if s.options.ResponseValidationLogOnly {
+		return nil
+	}
+	return errInvalidResponse
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BuggyModelHandler returns a response with the defect named in the "bug" model data
+type BuggyModelHandler struct{}
+
+func (h *BuggyModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *BuggyModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	switch req.ModelData["bug"] {
+	case "id":
+		resp.ID = "someone-else"
+	case "success":
+		resp.ErrorMessage = "failed after all"
+	case "failure":
+		resp.Success = false
+	case "results":
+		resp.Results = nil
+	case "custom":
+		resp.Results["answer"] = "forty-two"
+	case "annotations":
+		resp.Metadata = map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}
+	case "annotation bytes":
+		resp.Metadata = map[string]string{"note": strings.Repeat("x", 300)}
+	}
+	return resp, nil
+}
+
+// ValidateResponse rejects non-numeric answers
+func (h *BuggyModelHandler) ValidateResponse(req *core.ModelRequest, resp *core.ModelResponse) error {
+	if answer, ok := resp.Results["answer"]; ok {
+		if _, isNumber := answer.(float64); !isNumber {
+			return errors.New("answer must be a number")
+		}
+	}
+	return nil
+}
+
+// AnsweringModelHandler answers with the "answer" model data and declares a
+// numeric answer in its result schema
+type AnsweringModelHandler struct{}
+
+func (h *AnsweringModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+func (h *AnsweringModelHandler) DescribeMethods() []core.MethodDescription {
+	return []core.MethodDescription{{
+		Method: core.MethodProcessModel,
+		ResultSchema: &core.Schema{
+			Type:       "object",
+			Required:   []string{"answer"},
+			Properties: map[string]*core.Schema{"answer": {Type: "number"}},
+		},
+	}}
+}
+
+func (h *AnsweringModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	resp := core.NewModelResponse(req)
+	if answer, ok := req.ModelData["answer"]; ok {
+		resp.Results["answer"] = answer
+	}
+	return resp, nil
+}
+
+func answerRequest(answer interface{}) *core.ModelRequest {
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["answer"] = answer
+	return req
+}
+
+// validationErrors returns the violations logged for invalid handler responses
+func validationErrors(logger *testutil.CaptureLogger) []string {
+	var violations []string
+	for _, record := range logger.Records() {
+		if record.Message == "Invalid response from handler" {
+			violations = append(violations, fmt.Sprintf("%s %v", record.Fields["handler"], record.Fields[core.LogFieldError]))
+		}
+	}
+	return violations
+}
+
+func buggyRequest(bug string) *core.ModelRequest {
+	req := testutil.CreateTestModelRequest()
+	req.ModelData["bug"] = bug
+	return req
+}
+
+func TestResponseValidationRejectsViolations(t *testing.T) {
+	logger := testutil.NewCaptureLogger()
+	_, c := startServerWithHandler(t, &BuggyModelHandler{}, WithResponseValidation(true), WithAnnotationLimits(4, 256), WithLogger(logger))
+
+	violations := map[string]string{
+		"id":               "id: must match the request ID",
+		"success":          "errorMessage: must be empty when success is true",
+		"failure":          "errorMessage: is required when success is false",
+		"results":          "results: cannot be nil",
+		"custom":           "response: answer must be a number",
+		"annotations":      "metadata: must have at most 4 annotations, got 5",
+		"annotation bytes": "metadata: must be at most 256 bytes, got 304",
+	}
+
+	for bug, want := range violations {
+		t.Run(bug, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			resp, err := c.ProcessModel(ctx, buggyRequest(bug))
+			require.Error(t, err, "Invalid response should be converted to an error")
+			assert.Nil(t, resp, "Invalid response should not reach the client")
+			assert.Contains(t, err.Error(), "handler returned an invalid response", "Client should see a clean internal error")
+			violations := validationErrors(logger)
+			require.NotEmpty(t, violations, "Violation should be logged")
+			assert.Contains(t, violations[len(violations)-1], want, "Violation should be logged")
+			assert.Contains(t, violations[len(violations)-1], "*server.BuggyModelHandler", "Log should name the handler")
+		})
+	}
+
+	// A coherent response passes through untouched
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := c.ProcessModel(ctx, buggyRequest(""))
+	require.NoError(t, err, "Valid response should be sent")
+	assert.True(t, resp.Success, "Valid response should be unchanged")
+}
+
+func TestResponseValidationLogOnly(t *testing.T) {
+	logger := testutil.NewCaptureLogger()
+	_, c := startServerWithHandler(t, &BuggyModelHandler{}, WithResponseValidationLogOnly(true), WithLogger(logger))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, buggyRequest("success"))
+	require.NoError(t, err, "Log-only mode should send the response")
+	assert.True(t, resp.Success, "Bad response should pass through unchanged")
+	assert.Equal(t, "failed after all", resp.ErrorMessage, "Bad response should pass through unchanged")
+	violations := validationErrors(logger)
+	require.Len(t, violations, 1, "Violation should still be logged")
+	assert.Contains(t, violations[0], "errorMessage: must be empty when success is true", "Violation should still be logged")
+}
+
+func TestResponseValidationDisabled(t *testing.T) {
+	_, c := startServerWithHandler(t, &BuggyModelHandler{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, buggyRequest("id"))
+	require.NoError(t, err, "Responses should not be checked by default")
+	assert.Equal(t, "someone-else", resp.ID, "Response should be sent as-is")
+}
+
+func TestResponseValidationInBatch(t *testing.T) {
+	_, c := startServerWithHandler(t, &BuggyModelHandler{}, WithResponseValidation(true), WithLogger(core.NopLogger()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	batch := &core.BatchRequest{Requests: []*core.ModelRequest{buggyRequest("results"), buggyRequest("")}}
+	resp, err := c.ProcessBatch(ctx, batch)
+	require.NoError(t, err, "ProcessBatch should succeed")
+
+	assert.False(t, resp.Responses[0].Success, "Invalid item response should be replaced")
+	assert.Contains(t, resp.Responses[0].ErrorMessage, "handler returned an invalid response", "Item should carry the validation error")
+	assert.True(t, resp.Responses[1].Success, "Valid item should be unaffected")
+}
+
+func TestResultSchemaValidation(t *testing.T) {
+	logger := testutil.NewCaptureLogger()
+	_, c := startServerWithHandler(t, &AnsweringModelHandler{}, WithResponseValidation(true), WithLogger(logger))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, answerRequest(42))
+	require.NoError(t, err, "Conforming results should be sent")
+	assert.Equal(t, 42.0, resp.Results["answer"], "Conforming results should be unchanged")
+	assert.Empty(t, validationErrors(logger), "Conforming results should not be logged")
+
+	for name, req := range map[string]*core.ModelRequest{
+		"wrong type": answerRequest("forty-two"),
+		"missing":    testutil.CreateTestModelRequest(),
+	} {
+		_, err := c.ProcessModel(ctx, req)
+		require.Error(t, err, "Results %s should be rejected", name)
+		assert.Contains(t, err.Error(), "handler returned an invalid response", "Client should see a clean internal error")
+	}
+	violations := validationErrors(logger)
+	require.Len(t, violations, 2, "Every violation should be logged")
+	assert.Contains(t, violations[0]+violations[1], "results.answer: must be of type number", "Type violation should be logged")
+	assert.Contains(t, violations[0]+violations[1], "results.answer: is required", "Missing result should be logged")
+
+	// Batch items are checked against the mcp.processModel declaration
+	batch := &core.BatchRequest{Requests: []*core.ModelRequest{answerRequest("forty-two"), answerRequest(1)}}
+	batchResp, err := c.ProcessBatch(ctx, batch)
+	require.NoError(t, err, "ProcessBatch should succeed")
+	assert.False(t, batchResp.Responses[0].Success, "Violating item should be replaced")
+	assert.True(t, batchResp.Responses[1].Success, "Conforming item should be unaffected")
+}
+
+func TestResultSchemaValidationLogOnly(t *testing.T) {
+	logger := testutil.NewCaptureLogger()
+	_, c := startServerWithHandler(t, &AnsweringModelHandler{}, WithResponseValidationLogOnly(true), WithLogger(logger))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := c.ProcessModel(ctx, answerRequest("forty-two"))
+	require.NoError(t, err, "Log-only mode should send the response")
+	assert.Equal(t, "forty-two", resp.Results["answer"], "Violating results should pass through unchanged")
+	violations := validationErrors(logger)
+	require.Len(t, violations, 1, "Violation should still be logged")
+	assert.Contains(t, violations[0], "results.answer: must be of type number", "Violation should name the result")
+}
@@ -0,0 +1,28 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ErrAskedToFail is the error FlakyModelHandler fails requests with.
+var ErrAskedToFail = errors.New("asked to fail")
+
+// FlakyModelHandler answers model requests, failing with ErrAskedToFail
+// those whose "fail" model data is true.
+type FlakyModelHandler struct{}
+
+// Methods returns the methods the handler serves.
+func (h *FlakyModelHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+// ProcessModel answers req, or fails if its model data asks it to.
+func (h *FlakyModelHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	if req.ModelData["fail"] == true {
+		return nil, ErrAskedToFail
+	}
+	return core.NewModelResponse(req), nil
+}
@@ -0,0 +1,32 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// StartInProcessPair starts a server and a client connected over a
+// core.InProcessTransport, so tests need no sockets or free ports. The
+// constructors receive the shared transport and should pass it to
+// server.WithTransport and client.WithTransport respectively. Both
+// components are stopped when the test finishes.
+func StartInProcessPair[C, S core.Component](t *testing.T, newClient func(core.Transport) C, newServer func(core.Transport) S) (C, S) {
+	t.Helper()
+
+	transport := core.NewInProcessTransport()
+
+	srv := newServer(transport)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("failed to start in-process server: %v", err)
+	}
+	t.Cleanup(func() { srv.Stop() })
+
+	c := newClient(transport)
+	if err := c.Start(); err != nil {
+		t.Fatalf("failed to start in-process client: %v", err)
+	}
+	t.Cleanup(func() { c.Stop() })
+
+	return c, srv
+}
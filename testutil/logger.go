@@ -0,0 +1,70 @@
+// Package testutil provides utilities for testing MCP components.
+package testutil
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LogRecord is a log call captured by a CaptureLogger.
+type LogRecord struct {
+	Level   string         // "debug", "info", "warn" or "error"
+	Message string         // The message passed to the logger
+	Fields  map[string]any // Key/value pairs passed after the message
+}
+
+// CaptureLogger records every log call in memory. It satisfies core.Logger.
+type CaptureLogger struct {
+	mu      sync.Mutex
+	records []LogRecord
+}
+
+// NewCaptureLogger creates an empty capture logger.
+func NewCaptureLogger() *CaptureLogger {
+	return &CaptureLogger{records: make([]LogRecord, 0)}
+}
+
+// Debug records a debug message.
+func (l *CaptureLogger) Debug(msg string, args ...any) { l.record("debug", msg, args) }
+
+// Info records an info message.
+func (l *CaptureLogger) Info(msg string, args ...any) { l.record("info", msg, args) }
+
+// Warn records a warning.
+func (l *CaptureLogger) Warn(msg string, args ...any) { l.record("warn", msg, args) }
+
+// Error records an error.
+func (l *CaptureLogger) Error(msg string, args ...any) { l.record("error", msg, args) }
+
+// Records returns the records captured so far, in call order.
+func (l *CaptureLogger) Records() []LogRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LogRecord(nil), l.records...)
+}
+
+// Find returns the first record with the given message.
+func (l *CaptureLogger) Find(msg string) (LogRecord, bool) {
+	for _, record := range l.Records() {
+		if record.Message == msg {
+			return record, true
+		}
+	}
+	return LogRecord{}, false
+}
+
+func (l *CaptureLogger) record(level, msg string, args []any) {
+	fields := make(map[string]any, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		key := fmt.Sprint(args[i])
+		if i+1 < len(args) {
+			fields[key] = args[i+1]
+		} else {
+			fields["!BADKEY"] = args[i]
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, LogRecord{Level: level, Message: msg, Fields: fields})
+}
@@ -15,7 +15,7 @@ type MockClient struct {
 	status           core.Status
 	statusMu         sync.RWMutex
 	isConnected      bool
-	callbacks        []func(core.StatusChangeEvent)
+	statusEvents     *core.StatusNotifier
 	processResponse  *core.ModelResponse
 	processError     error
 	startError       error
@@ -30,7 +30,7 @@ type MockClient struct {
 func NewMockClient() *MockClient {
 	return &MockClient{
 		status:           core.StatusStopped,
-		callbacks:        make([]func(core.StatusChangeEvent), 0),
+		statusEvents:     core.NewStatusNotifier(core.NopLogger(), core.NewTaskTracker(core.NopLogger(), nil)),
 		requestsReceived: make([]*core.ModelRequest, 0),
 	}
 }
@@ -97,11 +97,10 @@ func (c *MockClient) IsConnected() bool {
 	return c.isConnected
 }
 
-// OnStatusChange registers a callback for status changes.
-func (c *MockClient) OnStatusChange(callback func(core.StatusChangeEvent)) {
-	c.statusMu.Lock()
-	defer c.statusMu.Unlock()
-	c.callbacks = append(c.callbacks, callback)
+// OnStatusChange registers a callback for status changes and returns a
+// function that removes it.
+func (c *MockClient) OnStatusChange(callback func(core.StatusChangeEvent)) (cancel func()) {
+	return c.statusEvents.Subscribe(callback)
 }
 
 // ProcessModel simulates processing a model request.
@@ -221,13 +220,5 @@ func (c *MockClient) notifyStatusChange(oldStatus, newStatus core.Status, err er
 		Timestamp: time.Now(),
 		Error:     err,
 	}
-
-	// Copy callbacks to avoid holding the lock during callback execution
-	var callbacksCopy []func(core.StatusChangeEvent)
-	callbacksCopy = append(callbacksCopy, c.callbacks...)
-
-	// Execute callbacks without holding the lock
-	for _, callback := range callbacksCopy {
-		go callback(event)
-	}
+	c.statusEvents.Publish(event)
 }
@@ -4,88 +4,207 @@ package testutil
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/narcolepticfox/mcp/core"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-// MockServer provides a test implementation of an MCP server.
+// MockServer provides a test implementation of an MCP server. It serves any
+// number of connections at once, and tells handlers which one a request
+// arrived on through MockConnectionID. Model requests are recorded, answered
+// from a script of responses before the handler, and can be delayed, failed
+// or have their connection dropped to test how clients cope. It is a
+// core.Component: a stopped MockServer can be started again on the same
+// port, so clients can be watched reconnecting. All its methods are safe for
+// concurrent use.
 type MockServer struct {
-	t           *testing.T
-	listener    net.Listener
-	port        int
-	conn        *jsonrpc2.Conn
-	mutex       sync.Mutex
-	handler     func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error)
-	shouldError bool
+	t            *testing.T
+	listener     net.Listener
+	port         int
+	lifecycle    sync.Mutex // Serializes Start and Stop
+	mutex        sync.Mutex
+	status       core.Status
+	statusEvents *core.StatusNotifier
+	conns        map[uint64]*jsonrpc2.Conn // Open connections by ID
+	lastConn     uint64                    // ID of the most recent connection
+	ctx          context.Context           // Context of requests, cancelled by Stop and Close
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup // The accept loop and connection watchers
+	handler      func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error)
+	shouldError  bool
+	stalled      chan struct{}
+	recorded     []*core.ModelRequest // Model requests received, in order
+	script       []mockResponse       // Answers to the next model requests, before the handler
+	latency      time.Duration        // Delay before each model request is answered
+	failureRate  float64              // Chance of each model request failing
+	dropNext     bool                 // Whether to drop the connection of the next model request
 }
 
-// NewMockServer creates a new mock server for testing.
+// mockResponse is a scripted answer to a model request.
+type mockResponse struct {
+	resp *core.ModelResponse
+	err  error
+}
+
+// ErrMockFailure is the error model requests fail with when SetFailureRate
+// picks them.
+var ErrMockFailure = errors.New("mock server: injected failure")
+
+type mockConnKey struct{}
+
+// MockConnectionID returns the ID of the MockServer connection the request
+// being handled arrived on, reporting false outside a MockServer handler.
+// Connections are numbered from 1 in the order they are accepted.
+func MockConnectionID(ctx context.Context) (uint64, bool) {
+	id, ok := ctx.Value(mockConnKey{}).(uint64)
+	return id, ok
+}
+
+var _ core.Component = (*MockServer)(nil)
+
+// NewMockServer creates a new mock server for testing, listening on a free
+// port on localhost.
 func NewMockServer(t *testing.T) (*MockServer, error) {
 	port, err := GetFreePort()
 	if err != nil {
 		return nil, err
 	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
-	if err != nil {
-		return nil, err
-	}
-
+	logger := core.NopLogger()
 	mockServer := &MockServer{
-		t:        t,
-		listener: listener,
-		port:     port,
-		handler:  func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) { return nil, nil },
+		t:            t,
+		port:         port,
+		status:       core.StatusStopped,
+		statusEvents: core.NewStatusNotifier(logger, core.NewTaskTracker(logger, nil)),
+		conns:        make(map[uint64]*jsonrpc2.Conn),
+		handler:      func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) { return nil, nil },
+	}
+	if err := mockServer.Start(); err != nil {
+		return nil, err
 	}
-
-	go mockServer.serve()
-
 	return mockServer, nil
 }
 
-// serve handles connections to the mock server.
-func (m *MockServer) serve() {
+// serve accepts connections on listener until it is closed.
+func (m *MockServer) serve(listener net.Listener) {
+	defer m.wg.Done()
+
 	for {
-		conn, err := m.listener.Accept()
+		netConn, err := listener.Accept()
 		if err != nil {
-			// Expected when closing
+			// The listener was closed by Stop or Close
 			return
 		}
 
 		m.mutex.Lock()
-		m.conn = jsonrpc2.NewConn(
-			context.Background(),
-			jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}),
-			jsonrpc2.HandlerWithError(m.handle),
+		m.lastConn++
+		id := m.lastConn
+		ctx := context.WithValue(m.ctx, mockConnKey{}, id)
+		conn := jsonrpc2.NewConn(
+			ctx,
+			jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{}),
+			jsonrpc2.HandlerWithError(func(_ context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+				return m.handle(ctx, conn, req)
+			}),
 		)
+		m.conns[id] = conn
 		m.mutex.Unlock()
+
+		m.wg.Add(1)
+		go m.watch(id, conn)
+	}
+}
+
+// watch forgets the connection id once it closes.
+func (m *MockServer) watch(id uint64, conn *jsonrpc2.Conn) {
+	defer m.wg.Done()
+	<-conn.DisconnectNotify()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.conns[id] == conn {
+		delete(m.conns, id)
 	}
 }
 
 // handle processes JSON-RPC requests.
 func (m *MockServer) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
 	switch req.Method {
-	case "mcp.processModel":
-		if m.shouldError {
-			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "Test error"}
-		}
+	case core.MethodPing:
+		m.mutex.Lock()
+		stalled := m.stalled
+		m.mutex.Unlock()
 
+		// Block the read loop like an unresponsive peer would
+		if stalled != nil {
+			<-stalled
+		}
+		return core.PingResponse{Timestamp: time.Now()}, nil
+	case core.MethodProcessModel:
 		var modelReq core.ModelRequest
 		if err := json.Unmarshal(*req.Params, &modelReq); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal request params: %w", err)
 		}
-
-		return m.handler(ctx, &modelReq)
+		resp, err := m.processModel(ctx, conn, &modelReq)
+		var modelErr *core.ModelError
+		if errors.As(err, &modelErr) {
+			return nil, modelErr.RPCError()
+		}
+		return resp, err
 	default:
 		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: "Method not found"}
 	}
 }
 
+// processModel records req and answers it, after the faults configured: a
+// dropped connection, latency, an injected failure or the error flag, from
+// the script or else the handler.
+func (m *MockServer) processModel(ctx context.Context, conn *jsonrpc2.Conn, req *core.ModelRequest) (*core.ModelResponse, error) {
+	m.mutex.Lock()
+	m.recorded = append(m.recorded, req)
+	drop := m.dropNext
+	m.dropNext = false
+	latency, failureRate, shouldError, handler := m.latency, m.failureRate, m.shouldError, m.handler
+	m.mutex.Unlock()
+
+	if drop {
+		conn.Close()
+		return nil, errors.New("mock server: connection dropped")
+	}
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if failureRate > 0 && rand.Float64() < failureRate {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: ErrMockFailure.Error()}
+	}
+	if shouldError {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "Test error"}
+	}
+
+	m.mutex.Lock()
+	if len(m.script) > 0 {
+		next := m.script[0]
+		m.script = m.script[1:]
+		m.mutex.Unlock()
+		return next.resp, next.err
+	}
+	m.mutex.Unlock()
+	return handler(ctx, req)
+}
+
 // Check if this is correctly initialized
 func (m *MockServer) Port() int {
 	// Add nil check
@@ -102,6 +221,68 @@ func (m *MockServer) SetShouldError(shouldError bool) {
 	m.shouldError = shouldError
 }
 
+// SetStalled configures the mock server to stop answering pings, simulating a
+// half-open connection. While stalled, the affected connections process nothing
+// else either. Clearing the flag releases every blocked connection.
+func (m *MockServer) SetStalled(stalled bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.setStalledLocked(stalled)
+}
+
+func (m *MockServer) setStalledLocked(stalled bool) {
+	switch {
+	case stalled && m.stalled == nil:
+		m.stalled = make(chan struct{})
+	case !stalled && m.stalled != nil:
+		close(m.stalled)
+		m.stalled = nil
+	}
+}
+
+// RecordedRequests returns the model requests received so far, in the order
+// they arrived, including those failed or dropped by injected faults.
+func (m *MockServer) RecordedRequests() []*core.ModelRequest {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]*core.ModelRequest(nil), m.recorded...)
+}
+
+// EnqueueResponse scripts the answer to a model request: each call adds one,
+// and requests take them in order, the handler answering once none are left.
+// A *core.ModelError is sent as the server would send it; other errors as
+// they are.
+func (m *MockServer) EnqueueResponse(resp *core.ModelResponse, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.script = append(m.script, mockResponse{resp: resp, err: err})
+}
+
+// SetLatency delays the answer to every model request by d, holding up the
+// requests behind it on the same connection as a slow server would. Zero
+// answers at once.
+func (m *MockServer) SetLatency(d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.latency = d
+}
+
+// SetFailureRate fails each model request with probability p, between 0 and
+// 1, with an internal error whose message is that of ErrMockFailure.
+func (m *MockServer) SetFailureRate(p float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.failureRate = p
+}
+
+// DropNextConnection closes the connection the next model request arrives
+// on without answering it, as a server that goes away mid-call would.
+func (m *MockServer) DropNextConnection() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.dropNext = true
+}
+
 // SetupModelHandler configures a custom handler function for model processing requests.
 func (m *MockServer) SetupModelHandler(handler func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error)) {
 	m.mutex.Lock()
@@ -109,40 +290,123 @@ func (m *MockServer) SetupModelHandler(handler func(ctx context.Context, req *co
 	m.handler = handler
 }
 
-// Close shuts down the mock server.
-func (m *MockServer) Close() error {
+// ConnectionCount returns the number of connections open.
+func (m *MockServer) ConnectionCount() int {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
+	return len(m.conns)
+}
+
+// DisconnectAll closes every open connection, as a server dropping its
+// clients would, and keeps accepting new ones.
+func (m *MockServer) DisconnectAll() {
+	m.mutex.Lock()
+	conns := m.takeConnsLocked()
+	m.mutex.Unlock()
 
-	if m.conn != nil {
-		m.conn.Close()
-		m.conn = nil
+	for _, conn := range conns {
+		conn.Close()
 	}
+}
+
+// takeConnsLocked forgets the open connections and returns them.
+func (m *MockServer) takeConnsLocked() []*jsonrpc2.Conn {
+	conns := make([]*jsonrpc2.Conn, 0, len(m.conns))
+	for id, conn := range m.conns {
+		conns = append(conns, conn)
+		delete(m.conns, id)
+	}
+	return conns
+}
 
-	return m.listener.Close()
+// Close shuts down the mock server, as Stop does.
+func (m *MockServer) Close() error {
+	return m.Stop()
 }
 
-// Start starts the mock server.
+// Start listens on the server's port again after Stop. Starting a running
+// server does nothing, as NewMockServer starts it.
 func (m *MockServer) Start() error {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
+
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
-	// Server is already started by NewMockServer, no need to do anything here
+	if m.status == core.StatusRunning {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.updateStatusLocked(core.StatusStarting, nil)
+	m.mutex.Unlock()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", m.port))
+	if err != nil {
+		m.mutex.Lock()
+		m.updateStatusLocked(core.StatusFailed, err)
+		m.mutex.Unlock()
+		return err
+	}
+
+	m.mutex.Lock()
+	m.listener = listener
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.updateStatusLocked(core.StatusRunning, nil)
+	m.mutex.Unlock()
+
+	m.wg.Add(1)
+	go m.serve(listener)
 	return nil
 }
 
-// Stop stops the mock server.
+// Stop stops the mock server: it stops accepting connections, closes every
+// open one, and waits for them to wind down. Handlers still running see
+// their context cancelled. Stopping a stopped server does nothing.
 func (m *MockServer) Stop() error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
 
-	if m.conn != nil {
-		m.conn.Close()
-		m.conn = nil
+	m.mutex.Lock()
+	if m.status != core.StatusRunning {
+		m.updateStatusLocked(core.StatusStopped, nil)
+		m.mutex.Unlock()
+		return nil
 	}
+	m.updateStatusLocked(core.StatusStopping, nil)
+	m.setStalledLocked(false)
+	m.cancel()
+	conns := m.takeConnsLocked()
+	err := m.listener.Close()
+	m.listener = nil
+	m.mutex.Unlock()
 
-	if m.listener != nil {
-		return m.listener.Close()
+	for _, conn := range conns {
+		conn.Close()
 	}
+	m.wg.Wait()
 
-	return nil
+	m.mutex.Lock()
+	m.updateStatusLocked(core.StatusStopped, nil)
+	m.mutex.Unlock()
+	return err
+}
+
+// Status returns the current status of the mock server.
+func (m *MockServer) Status() core.Status {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.status
+}
+
+// OnStatusChange registers a callback for status changes and returns a
+// function that removes it.
+func (m *MockServer) OnStatusChange(callback func(core.StatusChangeEvent)) (cancel func()) {
+	return m.statusEvents.Subscribe(callback)
+}
+
+// updateStatusLocked moves the server to status, telling the callbacks.
+func (m *MockServer) updateStatusLocked(status core.Status, err error) {
+	if m.status == status {
+		return
+	}
+	m.statusEvents.Publish(core.StatusChangeEvent{OldStatus: m.status, NewStatus: status, Timestamp: time.Now(), Error: err})
+	m.status = status
 }
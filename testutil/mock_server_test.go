@@ -0,0 +1,213 @@
+package testutil_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narcolepticfox/mcp/client"
+	"github.com/narcolepticfox/mcp/core"
+	"github.com/narcolepticfox/mcp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordConnections makes mockServer answer model requests, recording the
+// connection each arrived on.
+func recordConnections(mockServer *testutil.MockServer) func() []uint64 {
+	var mu sync.Mutex
+	var ids []uint64
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		id, _ := testutil.MockConnectionID(ctx)
+		mu.Lock()
+		ids = append(ids, id)
+		mu.Unlock()
+		return core.NewModelResponse(req), nil
+	})
+	return func() []uint64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]uint64(nil), ids...)
+	}
+}
+
+func startMockClient(t *testing.T, mockServer *testutil.MockServer, options ...client.Option) *client.Client {
+	c := client.New(append([]client.Option{
+		client.WithServerHost("localhost"),
+		client.WithServerPort(mockServer.Port()),
+		client.WithLogger(core.NopLogger()),
+	}, options...)...)
+	require.NoError(t, c.Start(), "Client should start successfully")
+	t.Cleanup(func() { c.Stop() })
+	return c
+}
+
+func TestMockServerConcurrentClients(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	received := recordConnections(mockServer)
+
+	first := startMockClient(t, mockServer)
+	second := startMockClient(t, mockServer)
+	require.Eventually(t, func() bool { return mockServer.ConnectionCount() == 2 }, 2*time.Second, 10*time.Millisecond, "Both clients should be connected")
+
+	ctx, cancel := testutil.ContextWithTimeout(t, 2*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, c := range []*client.Client{first, second} {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+			assert.NoError(t, err, "Each client should be answered")
+		}()
+	}
+	wg.Wait()
+
+	ids := received()
+	require.Len(t, ids, 2, "Both requests should reach the handler")
+	assert.NotZero(t, ids[0], "The handler should see the connection of each request")
+	assert.NotEqual(t, ids[0], ids[1], "The requests should arrive on different connections")
+	assert.Equal(t, 2, mockServer.ConnectionCount(), "Answering should leave both connections open")
+}
+
+func TestMockServerReconnect(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	received := recordConnections(mockServer)
+
+	c := startMockClient(t, mockServer, client.WithReconnectDelay(20*time.Millisecond), client.WithMaxReconnectAttempts(20))
+	ctx, cancel := testutil.ContextWithTimeout(t, 2*time.Second)
+	defer cancel()
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "The first request should be answered")
+
+	mockServer.DisconnectAll()
+	assert.Zero(t, mockServer.ConnectionCount(), "Disconnecting should close every connection")
+	require.Eventually(t, func() bool { return mockServer.ConnectionCount() == 1 && c.IsConnected() }, 2*time.Second, 10*time.Millisecond, "The client should reconnect")
+
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "A request should be answered after reconnecting")
+	ids := received()
+	require.Len(t, ids, 2, "Both requests should reach the handler")
+	assert.Greater(t, ids[1], ids[0], "The reconnection should be a new connection")
+}
+
+func TestMockServerStopClosesConnections(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+
+	first := startMockClient(t, mockServer, client.WithAutoReconnect(false))
+	second := startMockClient(t, mockServer, client.WithAutoReconnect(false))
+	require.Eventually(t, func() bool { return mockServer.ConnectionCount() == 2 }, 2*time.Second, 10*time.Millisecond, "Both clients should be connected")
+
+	require.NoError(t, mockServer.Stop(), "Stop should succeed")
+	assert.Zero(t, mockServer.ConnectionCount(), "Stop should close every connection")
+	assert.Eventually(t, func() bool { return !first.IsConnected() && !second.IsConnected() }, 2*time.Second, 10*time.Millisecond, "Both clients should be disconnected")
+	assert.NoError(t, mockServer.Close(), "Closing a stopped server should succeed")
+}
+
+func TestMockServerScriptAndRecording(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return &core.ModelResponse{ID: "handler", Success: true}, nil
+	})
+	c := startMockClient(t, mockServer)
+
+	const scripted = 5
+	for i := 0; i < scripted; i++ {
+		mockServer.EnqueueResponse(&core.ModelResponse{ID: "scripted", Success: true}, nil)
+	}
+
+	// Concurrent calls take the scripted answers first, each exactly once
+	ctx, cancel := testutil.ContextWithTimeout(t, 5*time.Second)
+	defer cancel()
+	var mu sync.Mutex
+	answers := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 2*scripted; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+			if !assert.NoError(t, err, "Every call should be answered") {
+				return
+			}
+			mu.Lock()
+			answers[resp.ID]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"scripted": scripted, "handler": scripted}, answers, "Each scripted answer should be used once before the handler")
+	assert.Len(t, mockServer.RecordedRequests(), 2*scripted, "Every request should be recorded")
+}
+
+func TestMockServerLatency(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	c := startMockClient(t, mockServer)
+
+	mockServer.SetLatency(100 * time.Millisecond)
+	ctx, cancel := testutil.ContextWithTimeout(t, 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	require.NoError(t, err, "A delayed call should still be answered")
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "The answer should be delayed")
+}
+
+func TestMockServerRestart(t *testing.T) {
+	mockServer, err := testutil.NewMockServer(t)
+	require.NoError(t, err, "Failed to create mock server")
+	defer mockServer.Close()
+	assert.Equal(t, core.StatusRunning, mockServer.Status(), "A new mock server should be running")
+
+	var mu sync.Mutex
+	var changes []core.Status
+	mockServer.OnStatusChange(func(event core.StatusChangeEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, event.NewStatus)
+	})
+
+	port := mockServer.Port()
+	require.NoError(t, mockServer.Stop(), "Stop should succeed")
+	assert.Equal(t, core.StatusStopped, mockServer.Status(), "A stopped mock server should say so")
+	assert.NoError(t, mockServer.Stop(), "Stopping again should do nothing")
+
+	require.NoError(t, mockServer.Start(), "The mock server should start again")
+	assert.Equal(t, core.StatusRunning, mockServer.Status(), "A restarted mock server should be running")
+	assert.Equal(t, port, mockServer.Port(), "The mock server should listen on the same port")
+	assert.NoError(t, mockServer.Start(), "Starting a running server should do nothing")
+
+	// The restarted server answers requests
+	mockServer.SetupModelHandler(func(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+		return core.NewModelResponse(req), nil
+	})
+	c := startMockClient(t, mockServer)
+	ctx, cancel := testutil.ContextWithTimeout(t, 2*time.Second)
+	defer cancel()
+	_, err = c.ProcessModel(ctx, testutil.CreateTestModelRequest())
+	assert.NoError(t, err, "The restarted server should answer")
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(changes) == 4
+	}, time.Second, 10*time.Millisecond, "Every status change should be reported")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []core.Status{core.StatusStopping, core.StatusStopped, core.StatusStarting, core.StatusRunning}, changes, "Status changes should be reported in order")
+}
@@ -0,0 +1,39 @@
+// Package testutil provides utilities for testing MCP components.
+package testutil
+
+import (
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// Exchange is a recorded request/response pair.
+type Exchange struct {
+	Request  *core.ModelRequest
+	Response *core.ModelResponse
+}
+
+// ExchangeRecorder collects exchanges in memory. It satisfies server.Recorder.
+type ExchangeRecorder struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// NewExchangeRecorder creates an empty recorder.
+func NewExchangeRecorder() *ExchangeRecorder {
+	return &ExchangeRecorder{exchanges: make([]Exchange, 0)}
+}
+
+// Record stores an exchange.
+func (r *ExchangeRecorder) Record(req *core.ModelRequest, resp *core.ModelResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, Exchange{Request: req, Response: resp})
+}
+
+// Exchanges returns the exchanges recorded so far, in arrival order.
+func (r *ExchangeRecorder) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Exchange(nil), r.exchanges...)
+}
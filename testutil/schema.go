@@ -0,0 +1,71 @@
+// Package testutil provides utilities for testing MCP components.
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// SchemaHandler is a model handler that declares a model schema for
+// mcp.processModel. It satisfies server.MethodDescriber, and its schema can be
+// changed while a server is running.
+type SchemaHandler struct {
+	mu           sync.Mutex
+	schema       *core.Schema
+	resultSchema *core.Schema
+	calls        int
+}
+
+// NewSchemaHandler creates a handler declaring schema for the model data.
+func NewSchemaHandler(schema *core.Schema) *SchemaHandler {
+	return &SchemaHandler{schema: schema}
+}
+
+// Methods returns the model processing method.
+func (h *SchemaHandler) Methods() []string {
+	return []string{core.MethodProcessModel}
+}
+
+// DescribeMethods declares the current schemas for mcp.processModel.
+func (h *SchemaHandler) DescribeMethods() []core.MethodDescription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return []core.MethodDescription{{
+		Method:       core.MethodProcessModel,
+		Description:  "Processes model data matching the test schema",
+		ModelSchema:  h.schema,
+		ResultSchema: h.resultSchema,
+	}}
+}
+
+// SetSchema replaces the declared schema.
+func (h *SchemaHandler) SetSchema(schema *core.Schema) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.schema = schema
+}
+
+// SetResultSchema declares a schema for the results, which are those of
+// core.NewModelResponse.
+func (h *SchemaHandler) SetResultSchema(schema *core.Schema) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resultSchema = schema
+}
+
+// ProcessModel acknowledges the request.
+func (h *SchemaHandler) ProcessModel(ctx context.Context, req *core.ModelRequest) (*core.ModelResponse, error) {
+	h.mu.Lock()
+	h.calls++
+	h.mu.Unlock()
+	return core.NewModelResponse(req), nil
+}
+
+// Calls returns how many requests reached the handler.
+func (h *SchemaHandler) Calls() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
@@ -0,0 +1,76 @@
+package testutil
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/narcolepticfox/mcp/core"
+)
+
+// ErrSinkFailing is returned by a FlakySink that has been toggled into failure.
+var ErrSinkFailing = errors.New("sink failing")
+
+// FlakySink is an observability sink that can be toggled into failure. It
+// satisfies core.AuditSink, core.MetricsCollector and server.Recorder; while
+// failing, Audit returns ErrSinkFailing and the other methods panic with it.
+type FlakySink struct {
+	failing   atomic.Bool
+	mu        sync.Mutex
+	delivered int
+}
+
+// NewFlakySink creates a sink that accepts events until SetFailing(true).
+func NewFlakySink() *FlakySink {
+	return &FlakySink{}
+}
+
+// SetFailing toggles whether the sink rejects events.
+func (s *FlakySink) SetFailing(failing bool) {
+	s.failing.Store(failing)
+}
+
+// Delivered returns the number of events the sink has accepted.
+func (s *FlakySink) Delivered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delivered
+}
+
+func (s *FlakySink) accept() error {
+	if s.failing.Load() {
+		return ErrSinkFailing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered++
+	return nil
+}
+
+func (s *FlakySink) mustAccept() {
+	if err := s.accept(); err != nil {
+		panic(err)
+	}
+}
+
+// Audit implements core.AuditSink.
+func (s *FlakySink) Audit(core.AuditEvent) error { return s.accept() }
+
+// Record implements server.Recorder.
+func (s *FlakySink) Record(*core.ModelRequest, *core.ModelResponse) { s.mustAccept() }
+
+// ConnectionOpened implements core.MetricsCollector.
+func (s *FlakySink) ConnectionOpened(string) { s.mustAccept() }
+
+// ConnectionClosed implements core.MetricsCollector.
+func (s *FlakySink) ConnectionClosed(string) { s.mustAccept() }
+
+// RequestStarted implements core.MetricsCollector.
+func (s *FlakySink) RequestStarted(string) { s.mustAccept() }
+
+// RequestCompleted implements core.MetricsCollector.
+func (s *FlakySink) RequestCompleted(string, time.Duration, bool) { s.mustAccept() }
+
+// PayloadSize implements core.MetricsCollector.
+func (s *FlakySink) PayloadSize(string, int, int) { s.mustAccept() }
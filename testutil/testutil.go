@@ -30,11 +30,7 @@ func CreateTestModelRequest() *core.ModelRequest {
 	req := core.NewModelRequest()
 	req.ModelData["name"] = "Test Model"
 	req.ModelData["value"] = 42
-	req.Parameters = append(req.Parameters, core.Parameter{
-		Name:  "param1",
-		Value: "value1",
-		Type:  "string",
-	})
+	req.Parameters = append(req.Parameters, core.StringParam("param1", "value1"))
 	return req
 }
 